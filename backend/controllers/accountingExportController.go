@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================
+// ACCOUNTING EXPORT
+// ============================================
+
+// ExportAccounting - Download journals, invoices, expenses or the P&L
+// (?from_date=, ?to_date=, ?coa_id=1,2, ?format=csv|xlsx, ?profile=standard|accurate|jurnal for journals)
+func ExportAccounting(c *gin.Context) {
+	dataset := c.Param("dataset")
+	format := c.DefaultQuery("format", services.ExportFormatCSV)
+
+	period, err := reportPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := services.ExportFilter{Period: period, Profile: c.Query("profile")}
+	for _, raw := range strings.Split(c.Query("coa_id"), ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		id, err := strconv.Atoi(raw)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "coa_id must be a comma separated list of ids"})
+			return
+		}
+		filter.COAIDs = append(filter.COAIDs, uint(id))
+	}
+	if err := services.ValidateExport(dataset, filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := dataset
+	if filter.Profile != "" && filter.Profile != services.ExportProfileStandard {
+		name += "_" + filter.Profile
+	}
+	filename := fmt.Sprintf("%s_%s_%s.%s", name, period.From.Format("20060102"), period.To.Format("20060102"), format)
+
+	writer, err := services.NewTableWriter(c.Writer, format, dataset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == services.ExportFormatXLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure here can only be logged
+	if err := services.NewAccountingExportService().Export(dataset, filter, writer); err != nil {
+		log.Printf("⚠️ Accounting export %s failed: %v", filename, err)
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("⚠️ Accounting export %s failed: %v", filename, err)
+	}
+
+	helpers.LogAuditSimple(c.GetUint("userID"), "finance", "EXPORT", 0, "Exported "+filename)
+}
+
+// GetExportMappings - Postable accounts with their Accurate / Jurnal.id code (?target=accurate|jurnal)
+func GetExportMappings(c *gin.Context) {
+	rows, err := services.NewAccountingExportService().ListMappings(c.Query("target"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// SaveExportMappings - Upsert account codes for a target; an empty external_code removes the mapping
+func SaveExportMappings(c *gin.Context) {
+	var input struct {
+		Target   string                         `json:"target" binding:"required"`
+		Mappings []services.AccountMappingInput `json:"mappings" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.NewAccountingExportService().SaveMappings(input.Target, input.Mappings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	helpers.LogAuditSimple(c.GetUint("userID"), "finance", "UPDATE", 0, fmt.Sprintf("Updated %d %s export mappings", len(input.Mappings), input.Target))
+	c.JSON(http.StatusOK, gin.H{"message": "Mapping akun tersimpan"})
+}
@@ -316,11 +316,13 @@ func GetSupplier(c *gin.Context) {
 
 func CreateSupplier(c *gin.Context) {
 	var input struct {
-		Name    string `json:"name" binding:"required"`
-		Contact string `json:"contact"`
-		Email   string `json:"email"`
-		Phone   string `json:"phone"`
-		Address string `json:"address"`
+		Name         string `json:"name" binding:"required"`
+		Contact      string `json:"contact"`
+		Email        string `json:"email"`
+		Phone        string `json:"phone"`
+		Address      string `json:"address"`
+		TermDays     *int   `json:"term_days" binding:"omitempty,min=0"`
+		LeadTimeDays *int   `json:"lead_time_days" binding:"omitempty,min=0"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -329,12 +331,20 @@ func CreateSupplier(c *gin.Context) {
 	}
 
 	supplier := models.Supplier{
-		Name:    input.Name,
-		Contact: input.Contact,
-		Email:   input.Email,
-		Phone:   input.Phone,
-		Address: input.Address,
-		Active:  true,
+		Name:         input.Name,
+		Contact:      input.Contact,
+		Email:        input.Email,
+		Phone:        input.Phone,
+		Address:      input.Address,
+		Active:       true,
+		TermDays:     30,
+		LeadTimeDays: 14,
+	}
+	if input.TermDays != nil {
+		supplier.TermDays = *input.TermDays
+	}
+	if input.LeadTimeDays != nil {
+		supplier.LeadTimeDays = *input.LeadTimeDays
 	}
 
 	if err := config.DB.Create(&supplier).Error; err != nil {
@@ -360,12 +370,14 @@ func UpdateSupplier(c *gin.Context) {
 	oldData := supplier
 
 	var input struct {
-		Name    string `json:"name"`
-		Contact string `json:"contact"`
-		Email   string `json:"email"`
-		Phone   string `json:"phone"`
-		Address string `json:"address"`
-		Active  *bool  `json:"active"`
+		Name         string `json:"name"`
+		Contact      string `json:"contact"`
+		Email        string `json:"email"`
+		Phone        string `json:"phone"`
+		Address      string `json:"address"`
+		Active       *bool  `json:"active"`
+		TermDays     *int   `json:"term_days" binding:"omitempty,min=0"`
+		LeadTimeDays *int   `json:"lead_time_days" binding:"omitempty,min=0"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -383,6 +395,12 @@ func UpdateSupplier(c *gin.Context) {
 	if input.Active != nil {
 		supplier.Active = *input.Active
 	}
+	if input.TermDays != nil {
+		supplier.TermDays = *input.TermDays
+	}
+	if input.LeadTimeDays != nil {
+		supplier.LeadTimeDays = *input.LeadTimeDays
+	}
 
 	if err := config.DB.Save(&supplier).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update supplier"})
@@ -11,11 +11,13 @@ import (
 	"forzashop/backend/config"
 	"forzashop/backend/helpers"
 	"forzashop/backend/models"
+	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/api/idtoken"
+	"gorm.io/gorm"
 )
 
 func Register(c *gin.Context) {
@@ -59,6 +61,14 @@ func Register(c *gin.Context) {
 
 	if err := config.DB.Create(&user).Error; err != nil {
 		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "UNIQUE") {
+			var guest models.User
+			if config.DB.Where("LOWER(email) = ? AND status = ?", strings.ToLower(input.Email), models.UserStatusGuest).First(&guest).Error == nil {
+				c.JSON(http.StatusConflict, gin.H{
+					"error": "This email was used for a guest checkout. Request an account claim link from your order page instead.",
+					"code":  "GUEST_ACCOUNT",
+				})
+				return
+			}
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Username or Email already registered"})
 			return
 		}
@@ -170,6 +180,15 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if user.Status == models.UserStatusGuest {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Akun tamu belum diklaim",
+			"code":  "GUEST_ACCOUNT",
+			"email": user.Email,
+		})
+		return
+	}
+
 	token, err := generateToken(user.ID, user.Role.Slug)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal generate token sesi"})
@@ -261,6 +280,16 @@ func GoogleLogin(c *gin.Context) {
 			user.Status = "active"
 			config.DB.Save(&user)
 		}
+		// A guest signing in with Google claims their guest account
+		if user.Status == models.UserStatusGuest {
+			user.Status = "active"
+			config.DB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Save(&user).Error; err != nil {
+					return err
+				}
+				return services.NewGuestService().MergeGuestRecords(tx, user)
+			})
+		}
 	}
 
 	// Generate JWT
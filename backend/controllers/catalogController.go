@@ -39,6 +39,7 @@ func CreateCategory(c *gin.Context) {
 		Name        string `json:"name" binding:"required"`
 		Slug        string `json:"slug" binding:"required"`
 		Description string `json:"description"`
+		TaxExempt   bool   `json:"tax_exempt"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -50,6 +51,7 @@ func CreateCategory(c *gin.Context) {
 		Name:        input.Name,
 		Slug:        input.Slug,
 		Description: input.Description,
+		TaxExempt:   input.TaxExempt,
 	}
 
 	if err := config.DB.Create(&category).Error; err != nil {
@@ -79,6 +81,7 @@ func UpdateCategory(c *gin.Context) {
 		Name        string `json:"name"`
 		Slug        string `json:"slug"`
 		Description string `json:"description"`
+		TaxExempt   *bool  `json:"tax_exempt"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -93,6 +96,9 @@ func UpdateCategory(c *gin.Context) {
 		category.Slug = input.Slug
 	}
 	category.Description = input.Description
+	if input.TaxExempt != nil {
+		category.TaxExempt = *input.TaxExempt
+	}
 
 	if err := config.DB.Save(&category).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category"})
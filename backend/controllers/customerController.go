@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"image"
@@ -9,7 +8,6 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -34,6 +32,8 @@ func GetCustomerOrders(c *gin.Context) {
 
 	if err := config.DB.Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
 		return db.Unscoped()
+	}).Preload("Items.Variant", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
 	}).Where("user_id = ?", user.ID).Order("created_at desc").Find(&orders).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch orders"})
 		return
@@ -48,12 +48,14 @@ func GetCustomerOrderDetail(c *gin.Context) {
 	id := c.Param("id")
 	var order models.Order
 
-	// Support both numeric ID and order_number (e.g. "FORZA-1770794409")
+	// Support both numeric ID and order_number (e.g. "FORZA-2026-10-00042"; the prefix is configurable)
 	query := config.DB.Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
 		return db.Unscoped()
-	}).Preload("Logs", "is_customer_visible = ?", true).Preload("Invoices")
-	if strings.HasPrefix(id, "FORZA-") || strings.HasPrefix(id, "forza-") {
-		query = query.Where("user_id = ? AND order_number = ?", user.ID, id)
+	}).Preload("Items.Variant", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Preload("Logs", "is_customer_visible = ?", true).Preload("Invoices").Preload("Shipments.Items")
+	if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+		query = query.Where("user_id = ? AND UPPER(order_number) = ?", user.ID, strings.ToUpper(id))
 	} else {
 		query = query.Where("user_id = ? AND id = ?", user.ID, id)
 	}
@@ -64,7 +66,7 @@ func GetCustomerOrderDetail(c *gin.Context) {
 	}
 
 	// ---------------------------------------------------------
-	// AUTO-CHECK PRISMALINK STATUS (Active Inquiry for Polling)
+	// AUTO-CHECK GATEWAY STATUS (Active Inquiry for Polling)
 	// ---------------------------------------------------------
 	paymentService := services.NewPaymentService()
 	paymentService.CheckAndSyncStatus(&order)
@@ -82,6 +84,12 @@ func Checkout(c *gin.Context) {
 		return
 	}
 
+	// Guests check out with the email they verified, not whatever the form says
+	isGuest := user.Status == models.UserStatusGuest
+	if isGuest {
+		input.BillingEmail = user.Email
+	}
+
 	orderService := services.NewOrderService()
 	result, err := orderService.CreateOrder(input, user)
 	if err != nil {
@@ -93,20 +101,31 @@ func Checkout(c *gin.Context) {
 	// Generate structured payment data for frontend native UI
 	var paymentData *helpers.PaymentData
 	if len(result.Invoices) > 0 {
-		pd, pdErr := helpers.GeneratePaymentDirect(result.Order, result.Invoices[0], user, c.ClientIP())
+		pd, pdErr := services.CreatePaymentCharge(result.Order, result.Invoices[0], user, c.ClientIP())
 		if pdErr == nil {
 			paymentData = pd
 		}
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"message":      "Order created successfully",
 		"order":        result.Order,
 		"invoices":     result.Invoices,
 		"invoice":      result.Invoices[0],
 		"payment_link": result.PaymentLink,
 		"payment_data": paymentData,
-	})
+	}
+
+	// Guests get a signed link to track the order and download its invoices without an account
+	if isGuest {
+		guestService := services.NewGuestService()
+		if accessToken, err := guestService.OrderAccessToken(result.Order, user.Email); err == nil {
+			response["access_token"] = accessToken
+			guestService.SendOrderAccessLink(result.Order, user.Email, accessToken)
+		}
+	}
+
+	c.JSON(http.StatusCreated, response)
 }
 
 // GetCustomerProfile - Get user profile and preferences
@@ -290,31 +309,18 @@ func ConfirmOrderReceived(c *gin.Context) {
 		return
 	}
 
-	if order.Status != "shipped" {
+	if order.Status != "shipped" && order.FulfillmentStatus != "partial" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Only shipped orders can be confirmed"})
 		return
 	}
 
-	tx := config.DB.Begin()
-
-	order.Status = "completed"
-	if err := tx.Save(&order).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
+	// Confirms every parcel in transit; the order closes once nothing is left to ship
+	confirmed, err := services.NewShipmentService().ConfirmDelivery(order.ID, 0, user.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Log the event
-	log := models.OrderLog{
-		OrderID:           order.ID,
-		UserID:            user.ID,
-		Action:            "order_confirmed",
-		Note:              "User confirmed receipt of artifacts. Operational cycle complete.",
-		IsCustomerVisible: true,
-	}
-	tx.Create(&log)
-
-	tx.Commit()
+	order = *confirmed
 
 	c.JSON(http.StatusOK, gin.H{"message": "Manifest successfully closed. Thank you, Hunter.", "order": order})
 }
@@ -424,7 +430,7 @@ func SubmitPaymentProof(c *gin.Context) {
 	// 3. Log Payment Transaction (Manual)
 	paymentTx := models.PaymentTransaction{
 		InvoiceID:     invoice.ID,
-		Amount:        invoice.Amount,
+		Amount:        invoice.TransferAmount(),
 		Status:        "pending_verification",
 		Gateway:       "manual",
 		PaymentMethod: "manual_transfer",
@@ -496,7 +502,7 @@ func GetCustomerOrderInvoices(c *gin.Context) {
 
 		// Generate payment URL for unpaid invoices
 		if inv.Status == "unpaid" {
-			paymentURL, err := helpers.GeneratePaymentLink(order, inv, user, c.ClientIP())
+			paymentURL, err := services.CreatePaymentLink(order, inv, user, c.ClientIP())
 			if err == nil {
 				iwp.PaymentURL = paymentURL
 			}
@@ -534,7 +540,7 @@ func GetInvoicePaymentLink(c *gin.Context) {
 	}
 
 	// Generate structured payment data via Full API
-	paymentData, err := helpers.GeneratePaymentDirect(invoice.Order, invoice, user, c.ClientIP())
+	paymentData, err := services.CreatePaymentCharge(invoice.Order, invoice, user, c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -557,511 +563,36 @@ func GetInvoicePaymentLink(c *gin.Context) {
 	})
 }
 
-// getIsland menentukan pulau/zona dari prefix kode pos Indonesia
-func getIsland(prefix int) string {
-	switch {
-	case prefix >= 10 && prefix <= 19:
-		return "JAWA" // Jakarta & sekitarnya
-	case prefix >= 20 && prefix <= 29:
-		return "SUMATERA"
-	case prefix >= 30 && prefix <= 39:
-		return "JAWA" // Jawa Barat
-	case prefix >= 40 && prefix <= 49:
-		return "JAWA" // Jawa Tengah
-	case prefix >= 50 && prefix <= 59:
-		return "JAWA" // Jawa Tengah/DIY
-	case prefix >= 60 && prefix <= 65:
-		return "JAWA" // Jawa Timur
-	case prefix >= 66 && prefix <= 69:
-		return "KALIMANTAN" // Kalimantan Selatan
-	case prefix >= 70 && prefix <= 76:
-		return "KALIMANTAN" // Kalimantan
-	case prefix >= 77 && prefix <= 79:
-		return "SULAWESI" // Sulawesi (sebagian overlap)
-	case prefix >= 80 && prefix <= 84:
-		return "BALI_NUSA" // Bali, NTB
-	case prefix >= 85 && prefix <= 89:
-		return "BALI_NUSA" // NTT
-	case prefix >= 90 && prefix <= 92:
-		return "SULAWESI"
-	case prefix >= 93 && prefix <= 94:
-		return "MALUKU"
-	case prefix >= 95 && prefix <= 96:
-		return "SULAWESI" // Sulawesi Utara/Gorontalo
-	case prefix >= 97 && prefix <= 99:
-		return "PAPUA"
-	default:
-		return "JAWA" // Fallback
-	}
-}
-
 // GetShippingOptions - Returns available shipping methods and costs via Biteship
 func GetShippingOptions(c *gin.Context) {
-	var input struct {
-		TotalWeight float64 `json:"total_weight"`
-		Country     string  `json:"country"`
-		State       string  `json:"state"`
-		City        string  `json:"city"`
-		PostalCode  string  `json:"postal_code"`
-		Subtotal    float64 `json:"subtotal"` // For free_shipping threshold check
-	}
+	var input services.ShippingOptionsInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	type ShippingOption struct {
-		ID            string  `json:"id"`
-		Method        string  `json:"method"`
-		Name          string  `json:"name"` // Display Name
-		Cost          float64 `json:"cost"`
-		Description   string  `json:"description"`
-		EstDays       string  `json:"est_days"`
-		FormattedCost string  `json:"formatted_cost"`
-	}
-
-	// Default country ke Indonesia jika kosong (mayoritas customer domestik)
-	if input.Country == "" {
-		input.Country = "ID"
-	}
-
-	// --- NORMALIZE COUNTRY to ISO code ---
-	// Build a map of common country names → ISO code (handle full name vs ISO code)
-	countryNameToISO := map[string]string{
-		"indonesia": "ID", "malaysia": "MY", "singapore": "SG", "thailand": "TH",
-		"philippines": "PH", "vietnam": "VN", "myanmar": "MM", "cambodia": "KH",
-		"laos": "LA", "brunei": "BN", "timor-leste": "TL",
-		"australia": "AU", "new zealand": "NZ",
-		"united states": "US", "usa": "US", "america": "US",
-		"united kingdom": "GB", "uk": "GB", "britain": "GB",
-		"germany": "DE", "france": "FR", "italy": "IT", "spain": "ES",
-		"netherlands": "NL", "belgium": "BE", "austria": "AT", "switzerland": "CH",
-		"japan": "JP", "south korea": "KR", "china": "CN", "hong kong": "HK",
-		"taiwan": "TW", "india": "IN", "saudi arabia": "SA", "uae": "AE",
-		"united arab emirates": "AE",
-	}
-	// If input country is already 2-char ISO, use as-is; otherwise look up
-	countryISO := strings.ToUpper(strings.TrimSpace(input.Country))
-	if len(countryISO) > 2 {
-		if iso, found := countryNameToISO[strings.ToLower(strings.TrimSpace(input.Country))]; found {
-			countryISO = iso
-			log.Printf("Normalized country %s -> ISO code %s", input.Country, countryISO)
-		}
-	}
-
-	fmt.Printf("📦 Shipping Request: Weight=%.2fkg, Country=%s (ISO: %s), PostalCode=%s, City=%s\n",
-		input.TotalWeight, input.Country, countryISO, input.PostalCode, input.City)
+	options := services.NewShippingService().GetShippingOptions(input)
+	c.JSON(http.StatusOK, options)
+}
 
-	isDomestic := countryISO == "ID" || strings.EqualFold(input.Country, "indonesia")
+// CreateShippingQuote - Locks the selected shipping option and item prices for checkout
+func CreateShippingQuote(c *gin.Context) {
+	user := c.MustGet("currentUser").(models.User)
 
-	if input.PostalCode == "" && isDomestic {
-		// Provide empty list if postal code is missing for domestic
-		c.JSON(http.StatusOK, []interface{}{})
+	var input services.ShippingQuoteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
 
-	// --- 1. AMBIL KURIR AKTIF DARI DATABASE ---
-	var activeCarriers []models.CarrierTemplate
-	config.DB.Preload("Services").Where("active = ?", true).Find(&activeCarriers)
-	activeMap := make(map[string]bool)
-	activeServicesMap := make(map[string]map[string]bool)
-
-	for _, c := range activeCarriers {
-		cName := strings.ToLower(c.Name)
-		cCode := strings.ToLower(c.BiteshipCode)
-		if cCode == "" {
-			cCode = cName
-		}
-		activeMap[cName] = true
-		activeMap[cCode] = true
-
-		activeServicesMap[cCode] = make(map[string]bool)
-		for _, s := range c.Services {
-			if s.Active {
-				activeServicesMap[cCode][strings.ToLower(s.ServiceCode)] = true
-			}
-		}
-	}
-
-	// --- 2. AMBIL TARIF MANUAL DARI DATABASE (TETAP ADA SEBAGAI LEGACY UNTUK DOMESTIC) ---
-	var dbRates []models.ShippingRate
-
-	// Ambil tarif manual khusus (Lokal) berdasarkan modul yang aktif
-	cargoEnabled := helpers.GetSetting("cargo_logistics_enabled", "true")
-	biteshipEnabled := helpers.GetSetting("biteship_enabled", "true")
-
-	if isDomestic {
-		query := config.DB.Where("active = ?", true)
-
-		if cargoEnabled == "true" && biteshipEnabled == "true" {
-			// Keduanya aktif: Ambil semua yang relevan untuk domestic
-			query.Where("(country = ? OR country = 'ID' OR zone = 'CARGO' OR zone = 'ALL' OR zone = 'DOMESTIC')", input.Country).Find(&dbRates)
-		} else if cargoEnabled == "true" {
-			// Hanya Cargo aktif: Ambil AIR/SHIP/SEA atau CARGO zone
-			query.Where("(zone = 'CARGO' OR method IN ('AIR', 'SHIP', 'SEA'))").
-				Where("(country = ? OR country = 'ID' OR zone = 'CARGO' OR zone = 'ALL' OR zone = 'DOMESTIC')", input.Country).
-				Find(&dbRates)
-		} else if biteshipEnabled == "true" {
-			// Hanya Biteship aktif: Ambil standard manual/fallback (Exclude manual cargo)
-			query.Where("zone != 'CARGO' AND method NOT IN ('AIR', 'SHIP', 'SEA')").
-				Where("(country = ? OR country = 'ID' OR zone = 'DOMESTIC' OR zone = 'ALL')", input.Country).
-				Find(&dbRates)
-		}
-		// Jika keduanya OFF, dbRates tetap kosong
-	}
-
-	// --- 3. LOGIKA PENGIRIMAN INTERNASIONAL (NEW ZONES) ---
-	var intlOptions []ShippingOption
-	if !isDomestic {
-		var allZones []models.ShippingZone
-		config.DB.Preload("Methods", "is_active = ?", true).Where("is_active = ?", true).Find(&allZones)
-
-		var matchedZone *models.ShippingZone
-
-		for i, zone := range allZones {
-			if zone.PostalCodes != "" && isZipMatch(input.PostalCode, zone.PostalCodes) {
-				matchedZone = &allZones[i]
-				break
-			}
-		}
-
-		if matchedZone == nil {
-			for i, zone := range allZones {
-				if zone.Countries != "" {
-					var countries []string
-					json.Unmarshal([]byte(zone.Countries), &countries)
-					for _, zc := range countries {
-						// Match by ISO code OR full name (normalize both sides)
-						if strings.ToUpper(zc) == countryISO || strings.EqualFold(zc, input.Country) {
-							matchedZone = &allZones[i]
-							break
-						}
-					}
-				}
-				if matchedZone != nil {
-					break
-				}
-			}
-		}
-
-		if matchedZone == nil {
-			for i, zone := range allZones {
-				if zone.Countries == "" && zone.PostalCodes == "" {
-					matchedZone = &allZones[i]
-					break
-				}
-			}
-		}
-
-		if matchedZone != nil {
-			for _, m := range matchedZone.Methods {
-				price := 0.0
-				switch m.CalcType {
-				case "flat":
-					price = m.Rate
-				case "per_kg":
-					weightKg := input.TotalWeight // Already in KGs
-					if weightKg < m.MinWeight && m.MinWeight > 0 {
-						weightKg = m.MinWeight
-					}
-					price = weightKg * m.Rate
-				case "free_shipping":
-					// FIXED: Only free if subtotal >= minimum threshold
-					if m.MinSubtotal > 0 && input.Subtotal < m.MinSubtotal {
-						continue // Don't show if not eligible
-					}
-					price = 0
-				case "api":
-					biteship := services.NewBiteshipService()
-					originZip := helpers.GetSetting("store_postal_code", os.Getenv("STORE_POSTAL_CODE"))
-					if originZip == "" {
-						originZip = "10110"
-					}
-
-					rateReq := services.BiteshipRateRequest{
-						OriginPostalCode:      originZip,
-						DestinationPostalCode: input.PostalCode,
-						DestinationCountry:    input.Country,
-						Items: []services.BiteshipItem{{
-							Name: "Order Items", Quantity: 1, Weight: int(input.TotalWeight), Value: input.Subtotal,
-						}},
-					}
-
-					if live, err := biteship.GetRates(rateReq); err == nil && live.Success {
-						for _, r := range live.Results {
-							intlOptions = append(intlOptions, ShippingOption{
-								ID:          fmt.Sprintf("api_%s_%s", r.CourierCode, r.ServiceCode),
-								Method:      r.CourierCode,
-								Name:        fmt.Sprintf("[%s] %s (%s)", strings.ToUpper(m.CourierType), r.CourierName, r.ServiceName),
-								Cost:        r.Price,
-								Description: matchedZone.Name,
-								EstDays:     r.Duration,
-							})
-						}
-						continue
-					}
-				}
-
-				if m.CalcType != "api" {
-					intlOptions = append(intlOptions, ShippingOption{
-						ID:          strconv.Itoa(int(m.ID)),
-						Method:      m.CourierType,
-						Name:        fmt.Sprintf("[%s] %s", strings.ToUpper(m.CourierType), m.Name),
-						Cost:        price,
-						Description: matchedZone.Name,
-						EstDays:     m.EtaText,
-					})
-				}
-			}
-		}
-	}
-
-	// --- 4. LOGIKA BITESHIP (DOMESTIC) ---
-	var biteshipOptions []ShippingOption
-
-	if isDomestic && input.PostalCode != "" && biteshipEnabled == "true" {
-		apiKey := helpers.GetSetting("biteship_api_key", os.Getenv("BITESHIP_API_KEY"))
-		storePostalCode := helpers.GetSetting("store_postal_code", os.Getenv("STORE_POSTAL_CODE"))
-		if storePostalCode == "" {
-			storePostalCode = "12440"
-		}
-
-		// Building courier list dynamically from database
-		var activeCodes []string
-		for _, ac := range activeCarriers {
-			if ac.BiteshipCode != "" {
-				activeCodes = append(activeCodes, ac.BiteshipCode)
-			}
-		}
-		courierRequest := strings.Join(activeCodes, ",")
-		if courierRequest == "" {
-			courierRequest = "jne,sicepat,jnt" // Safety fallback
-		}
-
-		weightInGrams := int(input.TotalWeight * 1000)
-		if weightInGrams < 100 {
-			weightInGrams = 100
-		}
-
-		payloadMap := map[string]interface{}{
-			"origin_postal_code":      storePostalCode,
-			"destination_postal_code": input.PostalCode,
-			"couriers":                courierRequest,
-			"items": []map[string]interface{}{
-				{"name": "Order Items", "value": int(input.Subtotal), "weight": weightInGrams, "quantity": 1},
-			},
-		}
-
-		jsonData, _ := json.Marshal(payloadMap)
-		req, _ := http.NewRequest("POST", "https://api.biteship.com/v1/rates/couriers", bytes.NewBuffer(jsonData))
-		req.Header.Set("Authorization", apiKey)
-		req.Header.Set("Content-Type", "application/json")
-
-		client := &http.Client{Timeout: 8 * time.Second}
-		resp, err := client.Do(req)
-
-		if err == nil {
-			defer resp.Body.Close()
-			body, _ := io.ReadAll(resp.Body)
-			var biteshipRes struct {
-				Success bool `json:"success"`
-				Pricing []struct {
-					Company            string  `json:"company"`
-					CourierServiceName string  `json:"courier_service_name"`
-					CourierServiceCode string  `json:"courier_service_code"`
-					Price              float64 `json:"price"`
-					Duration           string  `json:"duration"`
-				} `json:"pricing"`
-				Error string `json:"error"`
-			}
-			json.Unmarshal(body, &biteshipRes)
-			fmt.Printf("🚚 Biteship API Success: %v, Pricing Count: %d\n", biteshipRes.Success, len(biteshipRes.Pricing))
-
-			if biteshipRes.Success {
-				for i, rate := range biteshipRes.Pricing {
-					cCompany := strings.ToLower(rate.Company)
-					cService := strings.ToLower(rate.CourierServiceCode)
-
-					// SINKRONISASI: Hanya ambil kurir yang HIJAU/AKTIF di Admin
-					if !activeMap[cCompany] {
-						continue
-					}
-
-					// SINKRONISASI: Cek Layanan Spesifik (jika ada data services di db, filter. Jika kosong/kurir baru, loloskan semua sementara untuk safe backward compatibility, atau enforce filter)
-					if servMap, exists := activeServicesMap[cCompany]; exists {
-						// Jika kurir ini punya daftar layanan di db, pastikan layanannya Aktif
-						if len(servMap) > 0 {
-							if !servMap[cService] {
-								continue
-							}
-						}
-					}
-
-					estDays := rate.Duration
-					if estDays == "" {
-						estDays = "1 - 3 Days" // Safe fallback
-					}
-					// Optional: capitalize unit for better UI
-					estDays = strings.ReplaceAll(estDays, "days", "DAYS")
-					estDays = strings.ReplaceAll(estDays, "hours", "HOURS")
-					biteshipOptions = append(biteshipOptions, ShippingOption{
-						ID:          fmt.Sprintf("biteship_%s_%d", rate.Company, i),
-						Method:      rate.Company,
-						Name:        fmt.Sprintf("%s - %s", strings.ToUpper(rate.Company), rate.CourierServiceName),
-						Cost:        rate.Price,
-						Description: fmt.Sprintf("Weight: %.2f kg", input.TotalWeight),
-						EstDays:     estDays,
-					})
-				}
-			}
-		}
-
-		// FALLBACK MOCK jika Biteship gagal/kosong tapi data kurir aktif ada
-		if len(biteshipOptions) == 0 {
-			// Tentukan zona domestik dari kode pos tujuan
-			// Prefix kode pos Indonesia:
-			// 10-19: Jakarta, 20-29: Sumatera, 30-39: Jawa Barat, 40-49: Jawa Tengah/Timur
-			// 50-59: Jawa Tengah/DIY, 60-69: Jawa Timur/Kalimantan, 70-79: Sulawesi
-			// 80-89: Bali/NTB/NTT, 90-99: Papua/Maluku
-			zoneMultiplier := 1.0
-			estDays := "2 - 4 Days"
-			zoneName := "JAWA"
-
-			if len(input.PostalCode) >= 2 {
-				prefix := input.PostalCode[:2]
-				prefixNum := 0
-				fmt.Sscanf(prefix, "%d", &prefixNum)
-
-				// Tentukan zona dari kode pos asal toko
-				originPrefix := 0
-				storePC := helpers.GetSetting("store_postal_code", os.Getenv("STORE_POSTAL_CODE"))
-				if len(storePC) >= 2 {
-					fmt.Sscanf(storePC[:2], "%d", &originPrefix)
-				}
-
-				// Cek apakah asal dan tujuan di pulau yang sama
-				originIsland := getIsland(originPrefix)
-				destIsland := getIsland(prefixNum)
-
-				if originIsland == destIsland {
-					// Sama pulau
-					zoneMultiplier = 1.0
-					estDays = "1 - 3 Days"
-					zoneName = destIsland
-				} else if destIsland == "SUMATERA" || destIsland == "BALI_NUSA" {
-					zoneMultiplier = 1.5
-					estDays = "3 - 5 Days"
-					zoneName = destIsland
-				} else if destIsland == "KALIMANTAN" || destIsland == "SULAWESI" {
-					zoneMultiplier = 2.0
-					estDays = "4 - 7 Days"
-					zoneName = destIsland
-				} else if destIsland == "PAPUA" || destIsland == "MALUKU" {
-					zoneMultiplier = 3.0
-					estDays = "7 - 14 Days"
-					zoneName = destIsland
-				} else {
-					zoneMultiplier = 1.2
-					estDays = "2 - 5 Days"
-					zoneName = destIsland
-				}
-			}
-
-			fmt.Printf("📍 Mock Zone: %s (multiplier: %.1fx)\n", zoneName, zoneMultiplier)
-
-			// Dynamic fallback rates from DB (carrier_templates.fallback_rate)
-			for _, ac := range activeCarriers {
-				code := strings.ToLower(ac.BiteshipCode)
-				if code == "" {
-					code = strings.ToLower(ac.Name)
-				}
-				base := ac.FallbackRate
-				if base <= 0 {
-					base = 20000 // Ultimate safety fallback
-				}
-				mockPrice := input.TotalWeight * base * zoneMultiplier
-				if mockPrice < base {
-					mockPrice = base
-				}
-				biteshipOptions = append(biteshipOptions, ShippingOption{
-					ID:          fmt.Sprintf("mock_%s", code),
-					Method:      code,
-					Name:        fmt.Sprintf("%s REGULAR - %s (SIMULATED)", strings.ToUpper(ac.Name), zoneName),
-					Cost:        mockPrice,
-					Description: fmt.Sprintf("Weight: %.2f kg | Zone: %s", input.TotalWeight, zoneName),
-					EstDays:     estDays,
-				})
-			}
-		}
-	}
-
-	// --- 4. GABUNGKAN DENGAN TARIF DATABASE (ASIA/Manual) ---
-	var options []ShippingOption
-	for _, rate := range dbRates {
-		// SINKRONISASI: Cek apakah Nama Kurir (misal JNE) Aktif di Admin
-		methodLower := strings.ToLower(rate.Method)
-		if strings.Contains(methodLower, "jne") {
-			methodLower = "jne"
-		}
-		if strings.Contains(methodLower, "sicepat") {
-			methodLower = "sicepat"
-		}
-		if strings.Contains(methodLower, "j&t") || strings.Contains(methodLower, "jnt") {
-			methodLower = "j&t"
-		}
-
-		isManualMethod := methodLower == "air" || methodLower == "sea" || methodLower == "ship" ||
-			rate.Zone == "CARGO" || rate.Zone == "DOMESTIC" || rate.Zone == "ALL"
-
-		if exists := activeMap[methodLower]; !exists && !isManualMethod {
-			// Skip jika kurir ini tidak terdaftar/aktif di Admin bos
-			continue
-		}
-
-		isCargoRate := strings.EqualFold(rate.Method, "AIR") || strings.EqualFold(rate.Method, "SHIP") || strings.EqualFold(rate.Method, "SEA") || strings.EqualFold(rate.Zone, "CARGO")
-
-		// FIX 1: Mencegah tarif ganda. Sembunyikan tarif fallback (Non-Cargo) jika API Biteship sudah merespon.
-		if len(biteshipOptions) > 0 && !isCargoRate {
-			continue
-		}
-
-		chargeWeight := input.TotalWeight
-		if chargeWeight < rate.MinChargeWeight {
-			chargeWeight = rate.MinChargeWeight
-		}
-
-		cost := rate.BaseCost + (chargeWeight * rate.CostPerKg)
-
-		displayName := fmt.Sprintf("%s - %s", rate.Zone, rate.Method)
-		if strings.EqualFold(rate.Method, "AIR") {
-			displayName = "📦 VIA AIR (ON-AIR)"
-		} else if strings.EqualFold(rate.Method, "SHIP") || strings.EqualFold(rate.Method, "SEA") {
-			displayName = "📦 VIA SHIP (LAUT)"
-		}
-
-		desc := fmt.Sprintf("Estimasi: %d-%d Hari", rate.EstDaysMin, rate.EstDaysMax)
-
-		// FIX 3: Tambahkan info minimum berat charge agar user tidak bingung.
-		if rate.MinChargeWeight > 0 {
-			desc += fmt.Sprintf("\n(Berlaku minimal %v KG)", rate.MinChargeWeight)
-		}
-
-		options = append(options, ShippingOption{
-			ID:          fmt.Sprintf("db_%d", rate.ID),
-			Method:      rate.Method,
-			Name:        displayName,
-			Cost:        cost,
-			Description: desc,
-			EstDays:     fmt.Sprintf("%d-%d Hari", rate.EstDaysMin, rate.EstDaysMax),
-		})
+	quote, err := services.NewShippingService().CreateQuote(input, user.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Gabungkan hasil dari Domestic (Biteship + DB Legacy) dan IntlOptions
-	options = append(options, biteshipOptions...)
-	options = append(options, intlOptions...)
-
-	c.JSON(http.StatusOK, options)
+	c.JSON(http.StatusOK, quote)
 }
 
 // GetSavedCards - List user's saved payment methods
@@ -1104,7 +635,8 @@ type TrackingEvent struct {
 	IsDone      bool   `json:"is_done"`
 }
 
-// GetOrderTracking - Returns real-time tracking info from Biteship or simulation
+// GetOrderTracking - Returns real-time tracking info from Biteship or simulation.
+// Multi-parcel orders track ?shipment_id= (default: the latest parcel) and list all parcels.
 func GetOrderTracking(c *gin.Context) {
 	user := c.MustGet("currentUser").(models.User)
 	orderID := c.Param("id")
@@ -1115,22 +647,46 @@ func GetOrderTracking(c *gin.Context) {
 		return
 	}
 
+	var shipments []models.Shipment
+	config.DB.Preload("Items").Where("order_id = ? AND status <> ?", order.ID, "cancelled").Order("id ASC").Find(&shipments)
+	var current *models.Shipment
+	for i := range shipments {
+		if c.Query("shipment_id") == "" || c.Query("shipment_id") == strconv.Itoa(int(shipments[i].ID)) {
+			current = &shipments[i]
+		}
+	}
+	if c.Query("shipment_id") != "" && current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+		return
+	}
+	if current != nil {
+		// Track the parcel: everything below reads the order's tracking fields
+		order.TrackingNumber = current.TrackingNumber
+		order.Carrier = current.Carrier
+	}
+
 	type TrackingResponse struct {
-		TrackingNumber string          `json:"tracking_number"`
-		Carrier        string          `json:"carrier"`
-		Status         string          `json:"status"`
-		StatusLabel    string          `json:"status_label"`
-		Origin         string          `json:"origin"`
-		Destination    string          `json:"destination"`
-		LastUpdate     string          `json:"last_update"`
-		Events         []TrackingEvent `json:"events"`
-		SimulationMode bool            `json:"simulation_mode"`
+		ShipmentID     uint              `json:"shipment_id,omitempty"`
+		ShipmentNumber string            `json:"shipment_number,omitempty"`
+		Shipments      []models.Shipment `json:"shipments,omitempty"`
+		TrackingNumber string            `json:"tracking_number"`
+		Carrier        string            `json:"carrier"`
+		Status         string            `json:"status"`
+		StatusLabel    string            `json:"status_label"`
+		Origin         string            `json:"origin"`
+		Destination    string            `json:"destination"`
+		LastUpdate     string            `json:"last_update"`
+		Events         []TrackingEvent   `json:"events"`
+		SimulationMode bool              `json:"simulation_mode"`
 	}
 
 	// If no tracking number yet, return pipeline status
 	if order.TrackingNumber == "" {
 		events := generateOrderPipelineEvents(order)
 		c.JSON(http.StatusOK, TrackingResponse{
+			ShipmentID:     shipmentIDOf(current),
+			ShipmentNumber: shipmentNumberOf(current),
+			Shipments:      shipments,
 			TrackingNumber: "-",
 			Carrier:        order.ShippingMethod,
 			Status:         order.Status,
@@ -1214,6 +770,9 @@ func GetOrderTracking(c *gin.Context) {
 			}
 
 			c.JSON(http.StatusOK, TrackingResponse{
+				ShipmentID:     shipmentIDOf(current),
+				ShipmentNumber: shipmentNumberOf(current),
+				Shipments:      shipments,
 				TrackingNumber: order.TrackingNumber,
 				Carrier:        obj.Courier.Company,
 				Status:         obj.Status,
@@ -1231,6 +790,9 @@ func GetOrderTracking(c *gin.Context) {
 	// --- FALLBACK: Simulasi berdasarkan status order ---
 	events := generateSimulatedTrackingEvents(order)
 	c.JSON(http.StatusOK, TrackingResponse{
+		ShipmentID:     shipmentIDOf(current),
+		ShipmentNumber: shipmentNumberOf(current),
+		Shipments:      shipments,
 		TrackingNumber: order.TrackingNumber,
 		Carrier:        order.Carrier,
 		Status:         order.Status,
@@ -1243,6 +805,20 @@ func GetOrderTracking(c *gin.Context) {
 	})
 }
 
+func shipmentIDOf(sh *models.Shipment) uint {
+	if sh == nil {
+		return 0
+	}
+	return sh.ID
+}
+
+func shipmentNumberOf(sh *models.Shipment) string {
+	if sh == nil {
+		return ""
+	}
+	return sh.ShipmentNumber
+}
+
 func getOrderStatusLabel(status string) string {
 	labels := map[string]string{
 		"pending":    "Awaiting Verification",
@@ -23,6 +23,8 @@ func GetOrderInvoiceForDownload(c *gin.Context) {
 	var order models.Order
 	if err := config.DB.Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
 		return db.Unscoped()
+	}).Preload("Items.Variant", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
 	}).Preload("User").Where("id = ? AND user_id = ?", orderID, user.ID).First(&order).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
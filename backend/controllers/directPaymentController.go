@@ -12,9 +12,9 @@ import (
 	"forzashop/backend/config"
 	"forzashop/backend/helpers"
 	"forzashop/backend/models"
+	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // PrismalinkDirectConfig holds configuration for direct API calls
@@ -98,10 +98,38 @@ func GetInvoiceDetails(c *gin.Context) {
 		"latest_transaction":   latestTx,
 		"enable_bank_transfer": transferEnabled.Value == "true",
 		"bank_account":         bankAccount.Value,
+		"transfer_amount":      invoice.TransferAmount(),
 	})
 }
 
-// GeneratePaymentCode - Generate VA number, QR code or CC form via PrismaLink Full API
+// ChooseBankTransfer - Customer picks manual bank transfer; the invoice gets a unique code added to the amount
+func ChooseBankTransfer(c *gin.Context) {
+	user := c.MustGet("currentUser").(models.User)
+	invoiceID, _ := strconv.Atoi(c.Param("id"))
+
+	invoice, err := services.NewBankTransferService().ChooseTransfer(uint(invoiceID), user.ID)
+	if err != nil {
+		switch err.Error() {
+		case "invoice not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		case "unauthorized":
+			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invoice_number":  invoice.InvoiceNumber,
+		"amount":          invoice.Amount,
+		"unique_code":     invoice.UniqueCode,
+		"transfer_amount": invoice.TransferAmount(),
+		"bank_account":    helpers.GetSetting("bank_account", ""),
+	})
+}
+
+// GeneratePaymentCode - Generate VA number, QR code or CC form on the active payment gateway
 func GeneratePaymentCode(c *gin.Context) {
 	user := c.MustGet("currentUser").(models.User)
 	invoiceIDStr := c.Param("id")
@@ -120,51 +148,27 @@ func GeneratePaymentCode(c *gin.Context) {
 		return
 	}
 
-	// Load Invoice + Order with items
-	var invoice models.Invoice
-	if err := config.DB.Preload("Order.Items.Product").Where("id = ?", invoiceID).First(&invoice).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
-		return
-	}
-
-	// Verify ownership
-	authorized := false
-	if invoice.UserID != 0 && invoice.UserID == user.ID {
-		authorized = true
-	} else if invoice.OrderID != nil && invoice.Order.UserID == user.ID {
-		authorized = true
-	}
-	if !authorized {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	if invoice.Status == "paid" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invoice already paid"})
-		return
-	}
-
-	// Map frontend method to Prismalink payment_method
-	order := invoice.Order
-	switch input.Method {
-	case "va":
-		order.PaymentMethod = "VA"
-	case "qris":
-		order.PaymentMethod = "QRIS"
-	case "cc":
-		order.PaymentMethod = "CC"
-	default:
-		order.PaymentMethod = "VA"
-	}
-
-	// PERSIST payment method to DB so inquiry/webhook can reference it later
-	config.DB.Model(&models.Order{}).Where("id = ?", order.ID).Update("payment_method", order.PaymentMethod)
-
-	// Use GeneratePaymentDirect for Full API integration
-	paymentData, err := helpers.GeneratePaymentDirect(order, invoice, user, c.ClientIP())
+	// Open the charge on the active payment gateway (ownership and payability are checked there)
+	paymentData, err := services.NewPaymentService().SubmitTransaction(services.PaymentCodeInput{
+		InvoiceID:       uint(invoiceID),
+		UserID:          user.ID,
+		Method:          input.Method,
+		Bank:            input.Bank,
+		InstallmentTerm: input.InstallmentTerm,
+		CardToken:       input.CardToken,
+		SaveCard:        input.SaveCard,
+		IPAddress:       c.ClientIP(),
+	})
 	if err != nil {
 		log.Printf("❌ Payment generation failed: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		switch err.Error() {
+		case "invoice not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		case "unauthorized":
+			c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
@@ -224,7 +228,7 @@ func GeneratePaymentCode(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// SubmitCreditCard - Step 2 of CC Direct API: Submit card details to Prismalink
+// SubmitCreditCard - Step 2 of CC Direct API: Submit card details to the gateway (two-step card gateways only)
 func SubmitCreditCard(c *gin.Context) {
 	user := c.MustGet("currentUser").(models.User)
 	invoiceIDStr := c.Param("id")
@@ -277,7 +281,23 @@ func SubmitCreditCard(c *gin.Context) {
 		}
 	}
 
-	configData := helpers.GetPrismalinkConfig()
+	gateway, err := services.ActivePaymentGateway()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var payTx models.PaymentTransaction
+	if err := config.DB.Where("merchant_ref_no = ?", merchantRefNo).First(&payTx).Error; err == nil {
+		if gateway, err = services.GatewayForTransaction(payTx); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	cardSubmitter, ok := gateway.(services.CardSubmitter)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Payment gateway does not accept direct card submission"})
+		return
+	}
 
 	cardData := map[string]string{
 		"card_number": input.CardNumber,
@@ -286,7 +306,7 @@ func SubmitCreditCard(c *gin.Context) {
 		"cvv":         input.CVV,
 	}
 
-	paymentData, err := helpers.SubmitCreditCard(configData, merchantRefNo, input.SessionToken, invoice, user, cardData, c.ClientIP())
+	paymentData, err := cardSubmitter.SubmitCard(merchantRefNo, input.SessionToken, invoice, user, cardData, c.ClientIP())
 	if err != nil {
 		log.Printf("❌ CC Submit failed: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -338,149 +358,8 @@ func CheckPaymentStatus(c *gin.Context) {
 	// If still unpaid/pending, check upstream to see if user actually paid
 	checkableStatuses := invoice.Status == "unpaid" || invoice.Status == "pending" || invoice.Status == "pending_arrival"
 	if checkableStatuses {
-		var pendingTxs []models.PaymentTransaction
-		// Find ALL pending transactions for this invoice
-		config.DB.Where("invoice_id = ? AND status = 'pending'", invoice.ID).Order("created_at desc").Find(&pendingTxs)
-
-		log.Printf("🔍 CheckPaymentStatus: Invoice %s (Status: %s) has %d pending transactions", invoice.InvoiceNumber, invoice.Status, len(pendingTxs))
-
-		for _, payTx := range pendingTxs {
-			log.Printf("🔍 Checking Upstream Status for Invoice %s (Ref: %s, GatewayTxID: %s, Method: %s)", invoice.InvoiceNumber, payTx.MerchantRefNo, payTx.GatewayTxID, payTx.PaymentMethod)
-
-			// SKIP transactions without gateway_tx_id (plink_ref_no) — inquiry will always fail
-			if payTx.GatewayTxID == "" {
-				log.Printf("⏭️ Skipping %s: No gateway_tx_id (plink_ref_no), inquiry would fail", payTx.MerchantRefNo)
-				continue
-			}
-
-			// Determine payment_method for inquiry: prefer PaymentTransaction's method, fallback to Order
-			pm := payTx.PaymentMethod
-			if pm == "" {
-				// Fallback to Order's payment method
-				if invoice.Order.PaymentMethod == "QRIS" {
-					pm = "QR"
-				} else if invoice.Order.PaymentMethod == "VA" {
-					pm = "VA"
-				} else if invoice.Order.PaymentMethod == "CC" {
-					pm = "CC"
-				} else {
-					pm = "VA" // Default to VA since it's the most common
-				}
-			}
-
-			// Normalize: Prismalink inquiry API expects "QR" not "QRIS"
-			if strings.EqualFold(pm, "qris") || strings.EqualFold(pm, "qr") {
-				pm = "QR"
-			}
-
-			log.Printf("🔍 Inquiry params: RefNo=%s, PlinkRef=%s, Amount=%.2f, Method=%s", payTx.MerchantRefNo, payTx.GatewayTxID, payTx.Amount, pm)
-
-			inquiryRes, err := helpers.CheckPrismalinkStatus(payTx.MerchantRefNo, payTx.GatewayTxID, payTx.Amount, pm)
-			if err != nil {
-				log.Printf("⚠️ Inquiry Failed for %s: %v", payTx.MerchantRefNo, err)
-				continue
-			}
-
-			// Check response code first
-			if rc, ok := inquiryRes["response_code"].(string); ok && rc != "PL000" && rc != "00" {
-				log.Printf("⚠️ Gateway returned error for %s: code=%s (full response: %v)", payTx.MerchantRefNo, rc, inquiryRes)
-				continue
-			}
-
-			// Check status fields (handle various formats from Prismalink)
-			// Prismalink inquiry returns "transaction_status" (NOT "payment_status")
-			upstreamStatus, _ := inquiryRes["transaction_status"].(string)
-			if upstreamStatus == "" {
-				upstreamStatus, _ = inquiryRes["payment_status"].(string)
-			}
-			if upstreamStatus == "" {
-				upstreamStatus, _ = inquiryRes["status"].(string)
-			}
-			// Support nested data object
-			if data, ok := inquiryRes["data"].(map[string]interface{}); ok {
-				if s, ok := data["transaction_status"].(string); ok && s != "" {
-					upstreamStatus = s
-				} else if s, ok := data["payment_status"].(string); ok && s != "" {
-					upstreamStatus = s
-				}
-			}
-
-			log.Printf("🔍 Inquiry Result for %s: status=%s", payTx.MerchantRefNo, upstreamStatus)
-
-			if upstreamStatus == "SETLD" || upstreamStatus == "SUCCESS" || upstreamStatus == "00" || upstreamStatus == "PAID" {
-				log.Printf("✅ Success payment found on Ref: %s! Updating...", payTx.MerchantRefNo)
-
-				// Perform same update logic as webhook
-				tx := config.DB.Begin()
-				now := time.Now()
-
-				invoice.Status = "paid"
-				invoice.PaidAt = &now
-				tx.Save(&invoice)
-
-				// Mark THIS transaction as success
-				tx.Model(&payTx).Update("status", "success")
-
-				helpers.RecordPaymentJournal(tx, &invoice, payTx.GatewayTxID)
-
-				if invoice.OrderID != nil {
-					var order models.Order
-					if err := tx.Preload("Items.Product").First(&order, *invoice.OrderID).Error; err == nil {
-						if invoice.Type == "deposit" {
-							order.DepositPaid += invoice.Amount
-							order.RemainingBalance -= invoice.Amount
-							order.PaymentStatus = "deposit_paid"
-							order.Status = "pre_order"
-						} else {
-							order.PaymentStatus = "paid"
-							order.RemainingBalance -= invoice.Amount
-							order.Status = "processing"
-
-							// SYNC: Finalize Stock Deduction
-							// Move from Reserved -> Sold (Decrease Stock & ReservedQty)
-							for _, item := range order.Items {
-								if err := tx.Model(&models.Product{}).
-									Where("id = ?", item.ProductID).
-									Updates(map[string]interface{}{
-										"stock":        gorm.Expr("stock - ?", item.Quantity),                     // Permanently reduce stock
-										"reserved_qty": gorm.Expr("GREATEST(reserved_qty - ?, 0)", item.Quantity), // Release reservation
-									}).Error; err != nil {
-									log.Printf("❌ Failed to deduct stock for product %d: %v", item.ProductID, err)
-								}
-							}
-						}
-						tx.Save(&order)
-						tx.Create(&models.OrderLog{
-							OrderID: order.ID,
-							UserID:  order.UserID,
-							Action:  "status_change",
-							Note:    fmt.Sprintf("Payment confirmed via Manual Check (%s). Status: %s. Stock Finalized.", payTx.MerchantRefNo, order.Status),
-						})
-					}
-				} else if invoice.Type == "topup" {
-					var u models.User
-					if err := tx.First(&u, invoice.UserID).Error; err == nil {
-						balanceBefore := u.Balance
-						u.Balance += invoice.Amount
-						tx.Save(&u)
-						tx.Create(&models.WalletTransaction{
-							UserID:        u.ID,
-							Type:          "credit",
-							Amount:        invoice.Amount,
-							Description:   fmt.Sprintf("Top Up via Manual Check - %s", payTx.MerchantRefNo),
-							ReferenceType: "invoice",
-							ReferenceID:   invoice.InvoiceNumber,
-							BalanceBefore: balanceBefore,
-							BalanceAfter:  u.Balance,
-						})
-					}
-				}
-				tx.Commit()
-				log.Printf("✅ Invoice %s successfully marked as PAID", invoice.InvoiceNumber)
-
-				// Stop checking other transactions for this invoice
-				break
-			}
+		if _, err := services.NewPaymentService().SyncInvoice(invoice.ID, "Manual Check"); err != nil {
+			log.Printf("⚠️ Payment sync failed for %s: %v", invoice.InvoiceNumber, err)
 		}
 	}
 
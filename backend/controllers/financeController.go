@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -241,6 +242,123 @@ func CreateJournalEntry(c *gin.Context) {
 	c.JSON(http.StatusCreated, entry)
 }
 
+// ReverseJournalEntry - Correct a posted journal with a mirrored entry (posted journals are never edited)
+func ReverseJournalEntry(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.GetUint("userID")
+
+	var input struct {
+		Date        string `json:"date"` // YYYY-MM-DD, default today
+		Reason      string `json:"reason"`
+		IsAdjusting bool   `json:"is_adjusting"`
+	}
+	c.ShouldBindJSON(&input)
+
+	reverse := services.ReverseJournalInput{Reason: input.Reason, IsAdjusting: input.IsAdjusting}
+	if input.Date != "" {
+		date, err := time.ParseInLocation("2006-01-02", input.Date, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+		reverse.Date = &date
+	}
+
+	entry, err := services.NewFinanceService().ReverseJournal(uint(id), reverse, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// ============================================
+// ACCOUNTING PERIODS
+// ============================================
+
+// GetAccountingPeriods - List periods (?year=)
+func GetAccountingPeriods(c *gin.Context) {
+	year, _ := strconv.Atoi(c.Query("year"))
+
+	periods, err := services.NewAccountingPeriodService().ListPeriods(year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accounting periods"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": periods})
+}
+
+// OpenAccountingPeriod - Create a monthly period or reopen a closed one
+func OpenAccountingPeriod(c *gin.Context) {
+	userID := c.GetUint("userID")
+
+	var input struct {
+		Year  int `json:"year" binding:"required"`
+		Month int `json:"month" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	period, err := services.NewAccountingPeriodService().OpenPeriod(input.Year, input.Month, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, period)
+}
+
+// CloseAccountingPeriod - Close a period (adjusting entries only from then on)
+func CloseAccountingPeriod(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.GetUint("userID")
+
+	var input struct {
+		Note string `json:"note"`
+	}
+	c.ShouldBindJSON(&input)
+
+	period, err := services.NewAccountingPeriodService().ClosePeriod(uint(id), userID, input.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, period)
+}
+
+// LockAccountingPeriod - Lock a closed period for good
+func LockAccountingPeriod(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.GetUint("userID")
+
+	period, err := services.NewAccountingPeriodService().LockPeriod(uint(id), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, period)
+}
+
+// CloseFiscalYear - Year-end close: P&L balances to retained earnings, periods locked
+func CloseFiscalYear(c *gin.Context) {
+	year, _ := strconv.Atoi(c.Param("year"))
+	userID := c.GetUint("userID")
+
+	entry, err := services.NewAccountingPeriodService().CloseYear(year, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
 // ============================================
 // EXPENSES (Simple Interface for Ops)
 // ============================================
@@ -302,12 +420,14 @@ func CreateExpense(c *gin.Context) {
 	userID := c.GetUint("userID")
 
 	var input struct {
-		COAID       uint    `json:"coa_id" binding:"required"`
-		Amount      float64 `json:"amount" binding:"required,gt=0"`
-		Description string  `json:"description" binding:"required"`
-		Vendor      string  `json:"vendor"`
-		Date        string  `json:"date"` // YYYY-MM-DD
-		Attachment  string  `json:"attachment"`
+		COAID        uint    `json:"coa_id" binding:"required"`
+		Amount       float64 `json:"amount" binding:"required,gt=0"`
+		Description  string  `json:"description" binding:"required"`
+		Vendor       string  `json:"vendor"`
+		Date         string  `json:"date"` // YYYY-MM-DD
+		Attachment   string  `json:"attachment"`
+		IsRecurring  bool    `json:"is_recurring"`
+		RecurringDay int     `json:"recurring_day" binding:"min=0,max=31"` // Monthly on this day; other frequencies via /recurring-expenses
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -322,17 +442,23 @@ func CreateExpense(c *gin.Context) {
 
 	svc := services.NewFinanceService()
 	expense, err := svc.CreateExpense(services.ExpenseInput{
-		COAID:       input.COAID,
-		Amount:      input.Amount,
-		Description: input.Description,
-		Vendor:      input.Vendor,
-		Date:        parsedDate,
-		UserID:      userID,
-		Attachment:  input.Attachment,
+		COAID:        input.COAID,
+		Amount:       input.Amount,
+		Description:  input.Description,
+		Vendor:       input.Vendor,
+		Date:         parsedDate,
+		UserID:       userID,
+		Attachment:   input.Attachment,
+		IsRecurring:  input.IsRecurring,
+		RecurringDay: input.RecurringDay,
 	})
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, helpers.ErrPeriodClosed) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -374,6 +500,10 @@ func UpdateExpense(c *gin.Context) {
 	}
 	if input.IsRecurring != nil {
 		expense.IsRecurring = *input.IsRecurring
+		// Unflagging stops the schedule started from this expense
+		if !expense.IsRecurring && expense.RecurringExpenseID != nil {
+			config.DB.Model(&models.RecurringExpense{}).Where("id = ?", *expense.RecurringExpenseID).Update("is_active", false)
+		}
 	}
 	if input.RecurringDay != nil {
 		expense.RecurringDay = *input.RecurringDay
@@ -396,7 +526,11 @@ func DeleteExpense(c *gin.Context) {
 
 	svc := services.NewFinanceService()
 	if err := svc.DeleteExpense(uint(id), userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, helpers.ErrPeriodClosed) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -492,7 +626,7 @@ func GetCashFlowTrend(c *gin.Context) {
 		Select("TO_CHAR(je.date, 'Mon') as month, COALESCE(SUM(ji.credit - ji.debit), 0) as total").
 		Joins("JOIN coas c ON ji.coa_id = c.id").
 		Joins("JOIN journal_entries je ON ji.journal_entry_id = je.id").
-		Where("c.type = ?", "REVENUE")
+		Where("c.type = ? AND je.reference_type <> ?", "REVENUE", helpers.JournalTypeClosing)
 
 	if isFiltered {
 		revQuery = revQuery.Where("je.date >= ?", sinceTime)
@@ -517,7 +651,7 @@ func GetCashFlowTrend(c *gin.Context) {
 		Select("TO_CHAR(je.date, 'Mon') as month, COALESCE(SUM(ji.debit - ji.credit), 0) as total").
 		Joins("JOIN coas c ON ji.coa_id = c.id").
 		Joins("JOIN journal_entries je ON ji.journal_entry_id = je.id").
-		Where("(c.type = ? OR c.type = ?)", "EXPENSE", "COGS")
+		Where("(c.type = ? OR c.type = ?) AND je.reference_type <> ?", "EXPENSE", "COGS", helpers.JournalTypeClosing)
 
 	if isFiltered {
 		expTrendQuery = expTrendQuery.Where("je.date >= ?", sinceTime)
@@ -571,7 +705,7 @@ func GetProfitLossReport(c *gin.Context) {
 		Select("c.code as coa_code, c.name as coa_name, COALESCE(SUM(ji.credit - ji.debit), 0) as total").
 		Joins("JOIN coas c ON ji.coa_id = c.id").
 		Joins("JOIN journal_entries je ON ji.journal_entry_id = je.id").
-		Where("c.type = ? AND je.date BETWEEN ? AND ?", "REVENUE", fromDate, toDate).
+		Where("c.type = ? AND je.date BETWEEN ? AND ? AND je.reference_type <> ?", "REVENUE", fromDate, toDate, helpers.JournalTypeClosing).
 		Group("c.id, c.code, c.name").
 		Scan(&revenues)
 
@@ -585,7 +719,7 @@ func GetProfitLossReport(c *gin.Context) {
 		Select("c.code as coa_code, c.name as coa_name, COALESCE(SUM(ji.debit - ji.credit), 0) as total").
 		Joins("JOIN coas c ON ji.coa_id = c.id").
 		Joins("JOIN journal_entries je ON ji.journal_entry_id = je.id").
-		Where("(c.type = ? OR c.type = ?) AND je.date BETWEEN ? AND ?", "EXPENSE", "COGS", fromDate, toDate).
+		Where("(c.type = ? OR c.type = ?) AND je.date BETWEEN ? AND ? AND je.reference_type <> ?", "EXPENSE", "COGS", fromDate, toDate, helpers.JournalTypeClosing).
 		Group("c.id, c.code, c.name").
 		Scan(&expenses)
 
@@ -609,6 +743,111 @@ func GetProfitLossReport(c *gin.Context) {
 	})
 }
 
+// reportDate reads a YYYY-MM-DD query parameter; fallback applies when it is absent
+func reportDate(c *gin.Context, key string, fallback time.Time) (time.Time, error) {
+	v := c.Query(key)
+	if v == "" {
+		return fallback, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("%s must be YYYY-MM-DD", key)
+	}
+	return t, nil
+}
+
+// reportPeriod reads from_date / to_date (default: this month up to today)
+func reportPeriod(c *gin.Context) (services.ReportPeriod, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from, err := reportDate(c, "from_date", today.AddDate(0, 0, 1-today.Day()))
+	if err != nil {
+		return services.ReportPeriod{}, err
+	}
+	to, err := reportDate(c, "to_date", today)
+	if err != nil {
+		return services.ReportPeriod{}, err
+	}
+	if to.Before(from) {
+		return services.ReportPeriod{}, fmt.Errorf("to_date is before from_date")
+	}
+	return services.ReportPeriod{From: &from, To: to}, nil
+}
+
+// asOfDates reads as_of (default today) and an optional compare_to date
+func asOfDates(c *gin.Context) (time.Time, *time.Time, error) {
+	now := time.Now()
+	asOf, err := reportDate(c, "as_of", time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+	if err != nil {
+		return asOf, nil, err
+	}
+	if c.Query("compare_to") == "" {
+		return asOf, nil, nil
+	}
+	compareTo, err := reportDate(c, "compare_to", asOf)
+	if err != nil {
+		return asOf, nil, err
+	}
+	return asOf, &compareTo, nil
+}
+
+// GetTrialBalance - Trial balance as of a date (?as_of=, ?compare_to=)
+func GetTrialBalance(c *gin.Context) {
+	asOf, compareTo, err := asOfDates(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewFinanceService().TrialBalance(asOf, compareTo))
+}
+
+// GetBalanceSheet - Balance sheet grouped by the COA hierarchy (?as_of=, ?compare_to=)
+func GetBalanceSheet(c *gin.Context) {
+	asOf, compareTo, err := asOfDates(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewFinanceService().BalanceSheet(asOf, compareTo))
+}
+
+// GetGeneralLedger - Postings with running balance (?coa_id=, ?from_date=, ?to_date=)
+func GetGeneralLedger(c *gin.Context) {
+	period, err := reportPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	coaID, _ := strconv.Atoi(c.Query("coa_id"))
+
+	report, err := services.NewFinanceService().GeneralLedger(period, uint(coaID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetCashFlowStatement - Cash flow statement, indirect method (?from_date=, ?to_date=)
+func GetCashFlowStatement(c *gin.Context) {
+	period, err := reportPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := services.NewFinanceService().CashFlow(period)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
 // Helper function to parse int
 func parseInt(s string) (int, error) {
 	var result int
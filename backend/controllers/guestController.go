@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"net/http"
+
+	"forzashop/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestGuestVerification - Sends a verification code to the email a guest wants to check out with
+func RequestGuestVerification(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.NewGuestService().SendVerificationCode(input.Email); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "code": "EMAIL_REGISTERED"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification code sent to your email."})
+}
+
+// VerifyGuestEmail - Exchanges the emailed code for a guest checkout token
+func VerifyGuestEmail(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+		Code  string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, user, err := services.NewGuestService().VerifyEmail(input.Email, input.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Email verified. You can continue to checkout.",
+		"token":      token,
+		"expires_in": int(services.GuestCheckoutTokenTTL.Seconds()),
+		"email":      user.Email,
+	})
+}
+
+// RequestGuestClaim - Emails an account claim link to a guest email (same answer whether or not it exists)
+func RequestGuestClaim(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.NewGuestService().SendClaimLink(input.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If this email has a guest account, a claim link has been sent"})
+}
+
+// ClaimGuestAccount - Turns the guest account into a regular account and logs it in
+func ClaimGuestAccount(c *gin.Context) {
+	var input services.ClaimGuestInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := services.NewGuestService().ClaimAccount(input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := generateToken(user.ID, user.Role.Slug)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal generate token sesi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Account claimed successfully",
+		"token":   token,
+		"user": gin.H{
+			"id":          user.ID,
+			"username":    user.Username,
+			"email":       user.Email,
+			"full_name":   user.FullName,
+			"phone":       user.Phone,
+			"role":        user.Role.Slug,
+			"permissions": user.Role.Permissions,
+		},
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"forzashop/backend/config"
@@ -32,6 +33,8 @@ func MarkInvoicePaid(c *gin.Context) {
 	tx := config.DB.Begin()
 
 	// 1. Update Invoice Status
+	// A bank transfer arrived with the invoice's unique code on top
+	isTransfer := invoice.UniqueCode > 0 && (invoice.PaymentMethod == "bank_transfer" || invoice.PaymentMethod == "manual_transfer")
 	now := time.Now()
 	invoice.Status = "paid"
 	invoice.PaidAt = &now
@@ -45,7 +48,11 @@ func MarkInvoicePaid(c *gin.Context) {
 
 	// 2. Journal Entry (Dr Bank / Cr Revenue/Liability)
 	// Use RecordPaymentJournal helper to ensure balances are updated correctly
-	if err := helpers.RecordPaymentJournal(tx, &invoice, "MANUAL-ADMIN"); err != nil {
+	journal := helpers.RecordPaymentJournal
+	if isTransfer {
+		journal = helpers.RecordTransferPaymentJournal
+	}
+	if err := journal(tx, &invoice, "MANUAL-ADMIN"); err != nil {
 		// Log error but don't fail the whole transaction?
 		// Better to fail so data is consistent
 		tx.Rollback()
@@ -103,7 +110,7 @@ func DownloadInvoicePDF(c *gin.Context) {
 	id := c.Param("id")
 	var invoice models.Invoice
 
-	if err := config.DB.Preload("Order.User").Preload("Order.Items.Product").First(&invoice, id).Error; err != nil {
+	if err := config.DB.Preload("Order.User").Preload("Order.Items.Product").Preload("Order.Items.Variant").First(&invoice, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
 		return
 	}
@@ -129,6 +136,7 @@ func DownloadInvoicePDF(c *gin.Context) {
 		Quantity    int     `json:"quantity"`
 		Price       float64 `json:"price"`
 		Total       float64 `json:"total"`
+		TaxAmount   float64 `json:"tax_amount"` // PPN on this line
 	}
 
 	items := make([]InvoiceItemData, 0)
@@ -140,13 +148,19 @@ func DownloadInvoicePDF(c *gin.Context) {
 
 		for i, item := range order.Items {
 			itemTotal := item.Price * float64(item.Quantity)
+			productName, sku := item.Product.Name, item.Product.SKU
+			if item.Variant != nil {
+				productName = item.Product.Name + " - " + item.Variant.Name
+				sku = item.Variant.SKU
+			}
 			items = append(items, InvoiceItemData{
 				No:          i + 1,
-				ProductName: item.Product.Name,
-				SKU:         item.Product.SKU,
+				ProductName: productName,
+				SKU:         sku,
 				Quantity:    item.Quantity,
 				Price:       item.Price,
 				Total:       itemTotal,
+				TaxAmount:   item.TaxAmount,
 			})
 			subtotal += itemTotal
 		}
@@ -195,6 +209,18 @@ func DownloadInvoicePDF(c *gin.Context) {
 		"shipping": shipping,
 		"total":    invoice.Amount,
 
+		// PPN: tax_base (DPP) + tax_amount = total of this invoice
+		"tax": gin.H{
+			"label":        fmt.Sprintf("PPN %s%%", strconv.FormatFloat(order.TaxRate, 'f', -1, 64)),
+			"rate":         order.TaxRate,
+			"inclusive":    order.TaxInclusive,
+			"tax_base":     invoice.Amount - invoice.TaxAmount,
+			"tax_amount":   invoice.TaxAmount,
+			"order_tax":    order.TaxAmount,
+			"tax_number":   helpers.GetSetting("company_npwp", ""),
+			"is_zero_rate": order.TaxAmount == 0,
+		},
+
 		"company":      helpers.GetCompanyInfo(),
 		"payment_info": helpers.GetBankInfo(),
 	}
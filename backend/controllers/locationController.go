@@ -0,0 +1,439 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================
+// STOCK LOCATIONS
+// ============================================
+
+// GetLocations - All locations in web checkout allocation order
+func GetLocations(c *gin.Context) {
+	locations, err := services.NewLocationService().ListLocations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch locations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": locations})
+}
+
+// CreateLocation - Add a shop, warehouse or consignment location
+func CreateLocation(c *gin.Context) {
+	var input services.LocationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	location, err := services.NewLocationService().CreateLocation(input, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, location)
+}
+
+// UpdateLocation - Edit a location
+func UpdateLocation(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input services.LocationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	location, err := services.NewLocationService().UpdateLocation(uint(id), input, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, location)
+}
+
+// DeleteLocation - Remove an empty location
+func DeleteLocation(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := services.NewLocationService().DeleteLocation(uint(id), c.GetUint("userID")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lokasi dihapus"})
+}
+
+// SetLocationPriority - Reorder web checkout allocation: {"location_ids": [3, 1, 2]}, first is tried first
+func SetLocationPriority(c *gin.Context) {
+	var input struct {
+		LocationIDs []uint `json:"location_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.NewLocationService().SetPriority(input.LocationIDs, c.GetUint("userID")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Urutan lokasi tersimpan"})
+}
+
+// GetStockLevels - Stock per location (?location_id=, ?product_id=, ?search=, ?in_stock=true, ?page=, ?limit=)
+func GetStockLevels(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	locationID, _ := strconv.Atoi(c.Query("location_id"))
+	productID, _ := strconv.Atoi(c.Query("product_id"))
+
+	rows, total, err := services.NewLocationService().ListLevels(services.LevelFilter{
+		LocationID: uint(locationID),
+		ProductID:  uint(productID),
+		Search:     c.Query("search"),
+		InStock:    c.Query("in_stock") == "true",
+		Page:       page,
+		Limit:      limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock levels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  rows,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// SetLocationMinStock - Low-stock threshold of a product/edition at one location
+func SetLocationMinStock(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input struct {
+		ProductID     uint  `json:"product_id" binding:"required"`
+		VariantID     *uint `json:"variant_id"`
+		MinStockLevel int   `json:"min_stock_level"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.NewLocationService().SetMinStock(uint(id), input.ProductID, input.VariantID, input.MinStockLevel, c.GetUint("userID")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Batas stok minimum tersimpan"})
+}
+
+// SyncStockLocations - Rebuild location levels from the product totals and active holds
+func SyncStockLocations(c *gin.Context) {
+	report, err := services.NewLocationService().Sync()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ============================================
+// STOCK TRANSFERS
+// ============================================
+
+// GetStockTransfers - List transfers (?status=, ?location_id=, ?page=, ?limit=)
+func GetStockTransfers(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 20
+	}
+	locationID, _ := strconv.Atoi(c.Query("location_id"))
+
+	transfers, total, err := services.NewStockTransferService().ListTransfers(services.TransferFilter{
+		Status:     c.Query("status"),
+		LocationID: uint(locationID),
+		Page:       page,
+		Limit:      limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock transfers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  transfers,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetStockTransfer - Transfer with its items
+func GetStockTransfer(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	transfer, err := services.NewStockTransferService().GetTransfer(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// CreateStockTransfer - Draft a transfer between two locations
+func CreateStockTransfer(c *gin.Context) {
+	var input services.TransferInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer, err := services.NewStockTransferService().CreateTransfer(input, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// DispatchStockTransfer - Take the items off the source location
+func DispatchStockTransfer(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	transfer, err := services.NewStockTransferService().Dispatch(uint(id), c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// ReceiveStockTransfer - Put the items on the destination location
+func ReceiveStockTransfer(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	transfer, err := services.NewStockTransferService().Receive(uint(id), c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// CancelStockTransfer - Drop a draft or send a transfer in transit back to its source
+func CancelStockTransfer(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	transfer, err := services.NewStockTransferService().Cancel(uint(id), c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// ============================================
+// STOCK TAKE (stock opname)
+// ============================================
+
+// GetStockTakes - List stock-take sessions (?status=, ?location_id=, ?page=, ?limit=)
+func GetStockTakes(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 20
+	}
+	locationID, _ := strconv.Atoi(c.Query("location_id"))
+
+	takes, total, err := services.NewStockTakeService().List(services.StockTakeFilter{
+		Status:     c.Query("status"),
+		LocationID: uint(locationID),
+		Page:       page,
+		Limit:      limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock takes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  takes,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetStockTake - Count sheet with expected/counted quantities and the variance summary
+func GetStockTake(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	take, summary, err := services.NewStockTakeService().Get(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stock_take": take, "summary": summary})
+}
+
+// CreateStockTake - Open a session and freeze the expected quantities of a location
+func CreateStockTake(c *gin.Context) {
+	var input services.StockTakeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	take, err := services.NewStockTakeService().Create(input, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, take)
+}
+
+// ScanStockTake - Count a scanned QR code/SKU: {"code": "FZ-...", "quantity": 1, "set": false}
+func ScanStockTake(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input services.ScanInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := services.NewStockTakeService().Scan(uint(id), input, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// SetStockTakeCount - Overwrite the count of one line
+func SetStockTakeCount(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	itemID, _ := strconv.Atoi(c.Param("itemId"))
+
+	var input struct {
+		CountedQty *int `json:"counted_qty" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := services.NewStockTakeService().SetCount(uint(id), uint(itemID), *input.CountedQty, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// SubmitStockTake - Close counting and send the sheet for approval ({"zero_uncounted": true} counts missed lines as 0)
+func SubmitStockTake(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input struct {
+		ZeroUncounted bool `json:"zero_uncounted"`
+	}
+	c.ShouldBindJSON(&input)
+
+	take, err := services.NewStockTakeService().Submit(uint(id), input.ZeroUncounted, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, take)
+}
+
+// ApproveStockTake - Book the variances and post the shrinkage journal
+func ApproveStockTake(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	take, err := services.NewStockTakeService().Approve(uint(id), c.GetUint("userID"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, helpers.ErrPeriodClosed) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, take)
+}
+
+// RejectStockTake - Send a submitted sheet back to counting
+func RejectStockTake(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	take, err := services.NewStockTakeService().Reject(uint(id), input.Reason, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, take)
+}
+
+// CancelStockTake - Abandon a session without touching stock
+func CancelStockTake(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	take, err := services.NewStockTakeService().Cancel(uint(id), c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, take)
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,7 +15,6 @@ import (
 	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // GetOrderStats returns aggregated statistics for Orders
@@ -143,7 +143,7 @@ func GetOrder(c *gin.Context) {
 	id := c.Param("id")
 	var order models.Order
 
-	if err := config.DB.Preload("User").Preload("Items.Product").Preload("Logs").Preload("Invoices").First(&order, id).Error; err != nil {
+	if err := config.DB.Preload("User").Preload("Items.Product").Preload("Items.Variant").Preload("Logs").Preload("Invoices").Preload("Shipments.Items").First(&order, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
 		return
 	}
@@ -175,72 +175,10 @@ func GetOrder(c *gin.Context) {
 	}
 
 	// ---------------------------------------------------------
-	// AUTO-CHECK PRISMALINK STATUS (Active Inquiry for Polling)
+	// AUTO-CHECK GATEWAY STATUS (Active Inquiry for Polling)
 	// ---------------------------------------------------------
-	if order.PaymentMethod == "QRIS" && (order.PaymentStatus == "unpaid" || order.PaymentStatus == "pending") {
-		go func() {
-			// Run in background header or do it blocking?
-			// Blocking is better for the poll to return immediate success.
-		}()
-
-		var lastInvoice models.Invoice
-		if err := config.DB.Where("order_id = ?", order.ID).Order("created_at desc").First(&lastInvoice).Error; err == nil {
-			var payTx models.PaymentTransaction
-			if err := config.DB.Where("invoice_id = ?", lastInvoice.ID).First(&payTx).Error; err == nil && payTx.MerchantRefNo != "" {
-
-				// Call Prismalink Inquiry
-				// We send "QR" because we know this block is for QRIS
-				res, err := helpers.CheckPrismalinkStatus(payTx.MerchantRefNo, payTx.GatewayTxID, lastInvoice.Amount, "QR")
-				if err == nil {
-					// Extract Status - Prismalink uses "transaction_status" in inquiry response
-					upstreamStatus, _ := res["transaction_status"].(string)
-					if upstreamStatus == "" {
-						upstreamStatus, _ = res["payment_status"].(string)
-					}
-					if upstreamStatus == "" {
-						upstreamStatus, _ = res["status"].(string)
-					}
-					// Check nested data (Prismalink often wraps in "data")
-					if data, ok := res["data"].(map[string]interface{}); ok {
-						if s, ok := data["transaction_status"].(string); ok && s != "" {
-							upstreamStatus = s
-						} else if s, ok := data["payment_status"].(string); ok && s != "" {
-							upstreamStatus = s
-						}
-					}
-
-					log.Printf("Auto-Inquiry %s: %s", payTx.MerchantRefNo, upstreamStatus)
-
-					// If Paid, Update DB
-					if upstreamStatus == "SETLD" || upstreamStatus == "SUCCESS" || upstreamStatus == "00" || upstreamStatus == "PAID" {
-						tx := config.DB.Begin()
-
-						// Update Invoice
-						now := time.Now()
-						lastInvoice.Status = "paid"
-						lastInvoice.PaidAt = &now
-						tx.Save(&lastInvoice)
-
-						// Update Payment Tx
-						payTx.Status = "success"
-						tx.Save(&payTx)
-
-						// Update Order
-						order.PaymentStatus = "paid"
-						order.Status = "processing"
-						order.RemainingBalance = 0
-						tx.Save(&order)
-
-						// Record Journal (Simplified check)
-						helpers.RecordPaymentJournal(tx, &lastInvoice, payTx.MerchantRefNo)
-
-						tx.Commit()
-
-						log.Printf("Order Auto-Updated to PAID via Inquiry")
-					}
-				}
-			}
-		}
+	if order.PaymentMethod == "QRIS" {
+		services.NewPaymentService().CheckAndSyncStatus(&order)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -367,13 +305,47 @@ func RefundOrder(c *gin.Context) {
 	user := c.MustGet("currentUser").(models.User)
 
 	orderSvc := services.NewOrderService()
-	order, err := orderSvc.RefundOrder(input, user, c.ClientIP(), c.Request.UserAgent())
+	order, refunds, err := orderSvc.RefundOrder(input, user, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, order)
+	// A gateway refund on a provider without refund API falls back to a manual payout for finance
+	message := "Refund processed"
+	for _, r := range refunds {
+		if r.Method == services.RefundMethodManual && input.Method != services.RefundMethodManual {
+			message = "Gateway ini tidak mendukung refund otomatis, refund dicatat sebagai transfer manual oleh finance"
+			break
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": order, "refunds": refunds, "message": message})
+}
+
+// EditOrderItems - Add, remove or change lines of an unshipped order; re-prices it and adjusts its invoices
+func EditOrderItems(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input services.EditOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.OrderID = uint(id)
+	user := c.MustGet("currentUser").(models.User)
+
+	order, err := services.NewOrderService().EditOrderItems(input, user, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    order,
+		"message": "Order updated successfully",
+	})
 }
 
 // OpenBalanceDue - Explicitly opens/activates the balance payment invoice for a PO order.
@@ -393,14 +365,25 @@ func OpenBalanceDue(c *gin.Context) {
 		return
 	}
 
-	if order.PaymentStatus != "deposit_paid" {
+	// Mixed orders may already have another line's balance open
+	if order.PaymentStatus != "deposit_paid" && order.PaymentStatus != "balance_due" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Order must be in deposit_paid status to open balance"})
 		return
 	}
 
+	// Optional: open the balance of a single PO line
+	var input struct {
+		ItemID uint `json:"item_id"`
+	}
+	c.ShouldBindJSON(&input)
+
 	// Find the pending_arrival or unpaid balance invoice and activate it
 	var balanceInvoice models.Invoice
-	if err := config.DB.Where("order_id = ? AND type = ? AND status IN ?", order.ID, "balance", []string{"pending_arrival", "unpaid"}).First(&balanceInvoice).Error; err != nil {
+	query := config.DB.Where("order_id = ? AND type = ? AND status IN ?", order.ID, "balance", []string{"pending_arrival", "unpaid"})
+	if input.ItemID != 0 {
+		query = query.Where("order_item_id = ?", input.ItemID)
+	}
+	if err := query.Order("id asc").First(&balanceInvoice).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Balance invoice not found or already activated/paid"})
 		return
 	}
@@ -411,6 +394,7 @@ func OpenBalanceDue(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to activate balance invoice"})
 		return
 	}
+	config.DB.Model(&order).Update("payment_status", "balance_due")
 
 	user := c.MustGet("currentUser").(models.User)
 	config.DB.Create(&models.OrderLog{
@@ -467,7 +451,65 @@ func GetOrderInvoices(c *gin.Context) {
 	c.JSON(http.StatusOK, invoices)
 }
 
-// GetBiteshipOrderInfo - Admin: Get Biteship order detail via API
+// GetOrderShipments - Admin: list the parcels of an order
+func GetOrderShipments(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	shipments, err := services.NewShipmentService().ListShipments(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch shipments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, shipments)
+}
+
+// CreateOrderShipment - Admin: ship selected lines of an order as a separate parcel
+func CreateOrderShipment(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input services.CreateShipmentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input.OrderID = uint(id)
+	input.RequesterID = c.MustGet("currentUser").(models.User).ID
+
+	shipment, order, err := services.NewShipmentService().CreateShipment(input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"shipment":           shipment,
+		"status":             order.Status,
+		"fulfillment_status": order.FulfillmentStatus,
+	})
+}
+
+// CancelOrderShipment - Admin: void a parcel that was never delivered (its items can be shipped again)
+func CancelOrderShipment(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	shipmentID, _ := strconv.Atoi(c.Param("shipmentId"))
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	c.ShouldBindJSON(&input)
+
+	admin := c.MustGet("currentUser").(models.User)
+	shipment, err := services.NewShipmentService().CancelShipment(uint(id), uint(shipmentID), admin.ID, input.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, shipment)
+}
+
+// GetBiteshipOrderInfo - Admin: Get Biteship order detail via API (?shipment_id= for a specific parcel)
 func GetBiteshipOrderInfo(c *gin.Context) {
 	id := c.Param("id")
 	var order models.Order
@@ -476,6 +518,15 @@ func GetBiteshipOrderInfo(c *gin.Context) {
 		return
 	}
 
+	if shipmentID := c.Query("shipment_id"); shipmentID != "" {
+		var shipment models.Shipment
+		if err := config.DB.Where("id = ? AND order_id = ?", shipmentID, order.ID).First(&shipment).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Shipment not found"})
+			return
+		}
+		order.BiteshipOrderID = shipment.BiteshipOrderID
+	}
+
 	if order.BiteshipOrderID == "" {
 		c.JSON(http.StatusOK, gin.H{
 			"message":      "Order ini belum terhubung dengan Biteship",
@@ -515,13 +566,34 @@ func ConfirmDelivery(c *gin.Context) {
 	c.JSON(http.StatusOK, order)
 }
 
+// ConfirmShipmentDelivery - User confirms a single parcel arrived (multi-parcel orders)
+func ConfirmShipmentDelivery(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	shipmentID, _ := strconv.Atoi(c.Param("shipmentId"))
+	user := c.MustGet("currentUser").(models.User)
+
+	order, err := services.NewShipmentService().ConfirmDelivery(uint(id), uint(shipmentID), user.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
+
 // MarkOrderArrived - Admin triggers item arrival, enabling balance payment
 func MarkOrderArrived(c *gin.Context) {
 	idStr := c.Param("id")
 	id, _ := strconv.Atoi(idStr)
 
+	// Optional: mark a single PO line (mixed / multi-PO orders). Omitted = every outstanding PO line.
+	var input struct {
+		ItemID uint `json:"item_id"`
+	}
+	c.ShouldBindJSON(&input)
+
 	orderSvc := services.NewOrderService()
-	order, err := orderSvc.MarkArrived(uint(id), c.MustGet("currentUser").(models.User).ID)
+	order, err := orderSvc.MarkArrived(uint(id), input.ItemID, c.MustGet("currentUser").(models.User).ID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -630,6 +702,7 @@ func CreateAdminOrder(c *gin.Context) {
 		UserID uint `json:"user_id" binding:"required"` // Can use a generic "Walk-in" user ID if needed
 		Items  []struct {
 			ProductID uint    `json:"product_id" binding:"required"`
+			VariantID *uint   `json:"variant_id"` // Edition, required for products with variants
 			Quantity  int     `json:"quantity" binding:"required"`
 			Price     float64 `json:"price"` // Optional override. If 0, use product price.
 		} `json:"items" binding:"required"`
@@ -659,14 +732,14 @@ func CreateAdminOrder(c *gin.Context) {
 	var subtotal float64
 
 	for _, item := range input.Items {
-		var product models.Product
-		if err := tx.First(&product, item.ProductID).Error; err != nil {
+		product, variant, err := services.LoadSellable(tx, item.ProductID, item.VariantID)
+		if err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Product %d not found", item.ProductID)})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		price := product.Price
+		price := product.PriceFor(variant)
 		if item.Price > 0 {
 			price = item.Price
 		}
@@ -674,27 +747,30 @@ func CreateAdminOrder(c *gin.Context) {
 		total := price * float64(item.Quantity)
 		subtotal += total
 
-		// Reserve Stock
-		newReserved := product.ReservedQty + item.Quantity
-		if err := tx.Model(&product).Update("reserved_qty", newReserved).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve stock"})
-			return
-		}
-
 		orderItems = append(orderItems, models.OrderItem{
 			ProductID:    product.ID,
+			VariantID:    item.VariantID,
 			Quantity:     item.Quantity,
 			Price:        price,
 			Total:        total,
-			COGSSnapshot: product.SupplierCost,
+			COGSSnapshot: product.CostFor(variant),
 		})
 	}
 
-	totalAmount := subtotal + input.ShippingCost // No discount logic for now
+	// PPN (manual orders are handed over / shipped domestically)
+	taxBreakdown := (&services.TaxService{DB: tx}).CalculateForItems(orderItems, 0, input.ShippingCost, "ID", "")
+
+	totalAmount := subtotal + input.ShippingCost + taxBreakdown.Extra() // No discount logic for now
+
+	orderNumber, err := helpers.NextSequenceNumber(tx, helpers.SeqPOSOrder)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
 	order := models.Order{
-		OrderNumber: fmt.Sprintf("POS-%d", time.Now().Unix()),
+		OrderNumber: orderNumber,
 		UserID:      customer.ID,
 
 		// Use Customer Profile for Billing/Shipping (simplify Manual Order)
@@ -714,6 +790,7 @@ func CreateAdminOrder(c *gin.Context) {
 		Notes:         input.Notes,
 		Items:         orderItems,
 	}
+	services.ApplyTax(&order, orderItems, taxBreakdown)
 
 	if err := tx.Omit("Items").Create(&order).Error; err != nil {
 		tx.Rollback()
@@ -731,6 +808,20 @@ func CreateAdminOrder(c *gin.Context) {
 		return
 	}
 
+	// Reserve Stock (one ledger hold per line)
+	inventory := &services.InventoryService{DB: tx}
+	for _, item := range orderItems {
+		if err := inventory.HoldOrderLine(order, item, "manual_order", &admin.ID); err != nil {
+			tx.Rollback()
+			if errors.Is(err, services.ErrInsufficientStock) {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Insufficient stock for product %d", item.ProductID)})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve stock"})
+			return
+		}
+	}
+
 	// Create Log
 	tx.Create(&models.OrderLog{
 		OrderID:           order.ID,
@@ -743,7 +834,7 @@ func CreateAdminOrder(c *gin.Context) {
 	// Generate Invoice (Full Payment by default for POS)
 	invoiceType := "full"
 
-	if err := helpers.GenerateInvoice(tx, order, invoiceType, totalAmount, "unpaid"); err != nil {
+	if err := helpers.GenerateTaxedInvoice(tx, order, invoiceType, totalAmount, order.TaxAmount, "unpaid"); err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invoice"})
 		return
@@ -801,62 +892,17 @@ func CreateAdminOrder(c *gin.Context) {
 }
 
 // CheckExpiredPOs - Scan and cancel POs where balance payment is overdue (Ghost Protocol)
+// Same routine as the nightly cron: multi-line orders only lose the overdue PO line.
 func CheckExpiredPOs(c *gin.Context) {
-	var expiredInvoices []models.Invoice
-	now := time.Now()
-
-	// Find unpaid balance invoices that are past due
-	err := config.DB.Preload("Order.Items").
-		Where("type = ? AND status = ? AND due_date < ?", "balance", "unpaid", now).
-		Find(&expiredInvoices).Error
+	admin := c.MustGet("currentUser").(models.User)
 
+	orderSvc := services.NewOrderService()
+	cancelledCount, err := orderSvc.CheckExpiredPOs(admin.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan expired invoices"})
 		return
 	}
 
-	cancelledCount := 0
-	tx := config.DB.Begin()
-
-	for _, inv := range expiredInvoices {
-		if inv.Order.ID == 0 {
-			continue
-		}
-
-		// 1. Release Stock
-		for _, item := range inv.Order.Items {
-			helpers.RecordStockMovement(tx, item.ProductID, -item.Quantity, "reserved", "cancellation", "ORDER", inv.Order.OrderNumber, "Auto-cancel: Overdue balance", nil)
-			tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
-				Update("reserved_qty", gorm.Expr("GREATEST(reserved_qty - ?, 0)", item.Quantity))
-		}
-
-		// 2. Cancel Order
-		inv.Order.Status = "cancelled"
-		inv.Order.InternalNotes += "\n[AUTO-CANCEL] Ghost Protocol: Balance payment overdue. Deposit forfeited."
-		tx.Save(&inv.Order)
-
-		// 3. Update Invoice
-		inv.Status = "cancelled"
-		tx.Save(&inv)
-
-		// 4. Financial Journal: Forfeit Deposit
-		if inv.Order.DepositPaid > 0 {
-			coaLiabID, _ := helpers.GetCOAByMappingKey("CUSTOMER_DEPOSIT")
-			coaIncomeID, _ := helpers.GetCOAByMappingKey("OTHER_INCOME")
-
-			if coaLiabID != 0 && coaIncomeID != 0 {
-				helpers.PostJournalWithTX(tx, inv.Order.OrderNumber, "ADJUSTMENT", "Deposit Forfeited (Auto Ghost)", []models.JournalItem{
-					{COAID: coaLiabID, Debit: inv.Order.DepositPaid, Credit: 0},
-					{COAID: coaIncomeID, Debit: 0, Credit: inv.Order.DepositPaid},
-				})
-			}
-		}
-
-		cancelledCount++
-	}
-
-	tx.Commit()
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":         fmt.Sprintf("Ghost Protocol executed. %d orders cancelled.", cancelledCount),
 		"cancelled_count": cancelledCount,
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================
+// ACCOUNTS PAYABLE
+// ============================================
+
+// GetSupplierBills - List bills (?supplier_id=, ?status=unpaid|partial|paid|open, ?overdue=true, ?page=, ?limit=)
+func GetSupplierBills(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 20
+	}
+	supplierID, _ := strconv.Atoi(c.Query("supplier_id"))
+
+	bills, total, err := services.NewPayableService().ListBills(services.BillFilter{
+		SupplierID: uint(supplierID),
+		Status:     c.Query("status"),
+		Overdue:    c.Query("overdue") == "true",
+		Page:       page,
+		Limit:      limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch supplier bills"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  bills,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetSupplierBill - Bill with its payments
+func GetSupplierBill(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	bill, err := services.NewPayableService().GetBill(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, bill)
+}
+
+// CreateSupplierBill - Record a supplier bill (bill_date / due_date: YYYY-MM-DD)
+func CreateSupplierBill(c *gin.Context) {
+	var input struct {
+		services.BillInput
+		BillDate string `json:"bill_date"`
+		DueDate  string `json:"due_date"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bill := input.BillInput
+	if input.BillDate != "" {
+		date, err := time.ParseInLocation("2006-01-02", input.BillDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bill_date must be YYYY-MM-DD"})
+			return
+		}
+		bill.BillDate = date
+	}
+	if input.DueDate != "" {
+		date, err := time.ParseInLocation("2006-01-02", input.DueDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "due_date must be YYYY-MM-DD"})
+			return
+		}
+		bill.DueDate = &date
+	}
+
+	created, err := services.NewPayableService().CreateBill(bill, c.GetUint("userID"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, helpers.ErrPeriodClosed) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// PaySupplierBill - Record a (partial) payment: Debit Hutang Usaha, Credit bank (date: YYYY-MM-DD)
+func PaySupplierBill(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input struct {
+		services.PaymentInput
+		Date string `json:"date"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payment := input.PaymentInput
+	if input.Date != "" {
+		date, err := time.ParseInLocation("2006-01-02", input.Date, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+			return
+		}
+		payment.Date = date
+	}
+
+	created, err := services.NewPayableService().RecordPayment(uint(id), payment, c.GetUint("userID"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, helpers.ErrPeriodClosed) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// GetPayableAging - Outstanding per supplier in current / 1-30 / 31-60 / 61-90 / 90+ days past due (?as_of=)
+func GetPayableAging(c *gin.Context) {
+	now := time.Now()
+	asOf, err := reportDate(c, "as_of", now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.NewPayableService().Aging(asOf))
+}
+
+// GetSupplierStatement - Bills and payments with running balance (?from_date=, ?to_date=)
+func GetSupplierStatement(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	period, err := reportPeriod(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	statement, err := services.NewPayableService().Statement(uint(id), period)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, statement)
+}
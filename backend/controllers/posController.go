@@ -41,6 +41,7 @@ func CreatePOSOrder(c *gin.Context) {
 		PaymentMethod string             `json:"payment_method"`
 		POPaymentType string             `json:"po_payment_type"`
 		Notes         string             `json:"notes"`
+		LocationID    uint               `json:"location_id"`
 	}
 
 	if err := c.ShouldBindJSON(&requestBody); err != nil {
@@ -64,6 +65,7 @@ func CreatePOSOrder(c *gin.Context) {
 		PaymentMethod: requestBody.PaymentMethod,
 		POPaymentType: requestBody.POPaymentType,
 		Notes:         requestBody.Notes,
+		LocationID:    requestBody.LocationID,
 		ProcessorID:   staffID,
 		IPAddress:     c.ClientIP(),
 		UserAgent:     c.Request.UserAgent(),
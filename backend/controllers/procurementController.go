@@ -1,24 +1,33 @@
 package controllers
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"forzashop/backend/config"
 	"forzashop/backend/helpers"
 	"forzashop/backend/models"
+	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetPurchaseOrderStats returns aggregated statistics for POs
 func GetPurchaseOrderStats(c *gin.Context) {
 	var totalPO int64
 	var draftPO int64
+	var pendingPO int64
+	var approvedPO int64
 	var orderedPO int64
 	var receivedPO int64
+	var partialPO int64
 	var totalItems int64
 	var receivedItems int64
 
@@ -28,30 +37,40 @@ func GetPurchaseOrderStats(c *gin.Context) {
 	// Draft
 	config.DB.Model(&models.PurchaseOrder{}).Where("status = ?", "draft").Count(&draftPO)
 
+	// Waiting for approval / approved but not yet sent
+	config.DB.Model(&models.PurchaseOrder{}).Where("status = ?", models.POPendingApproval).Count(&pendingPO)
+	config.DB.Model(&models.PurchaseOrder{}).Where("status = ?", models.POApproved).Count(&approvedPO)
+
 	// Ordered
 	config.DB.Model(&models.PurchaseOrder{}).Where("status = ?", "ordered").Count(&orderedPO)
 
 	// Received
 	config.DB.Model(&models.PurchaseOrder{}).Where("status = ?", "received").Count(&receivedPO)
 
+	// Partially received
+	config.DB.Model(&models.PurchaseOrder{}).Where("status = ?", "partial").Count(&partialPO)
+
 	// Item Counts (Total and Received)
 	// We join with purchase_order_items to sum quantities
 	config.DB.Table("purchase_order_items").Select("COALESCE(SUM(quantity), 0)").Row().Scan(&totalItems)
 
-	// Received Items
+	// Received Items (partially received POs count what has arrived so far)
 	config.DB.Table("purchase_order_items").
 		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id").
-		Where("purchase_orders.status = ?", "received").
-		Select("COALESCE(SUM(quantity), 0)").
+		Where("purchase_orders.status IN ?", []string{"received", "partial"}).
+		Select("COALESCE(SUM(CASE WHEN purchase_orders.status = 'received' THEN quantity ELSE received_qty END), 0)").
 		Row().Scan(&receivedItems)
 
 	c.JSON(http.StatusOK, gin.H{
-		"total":          totalPO,
-		"draft":          draftPO,
-		"ordered":        orderedPO,
-		"received":       receivedPO,
-		"total_items":    totalItems,
-		"received_items": receivedItems,
+		"total":            totalPO,
+		"draft":            draftPO,
+		"pending_approval": pendingPO,
+		"approved":         approvedPO,
+		"ordered":          orderedPO,
+		"received":         receivedPO,
+		"partial":          partialPO,
+		"total_items":      totalItems,
+		"received_items":   receivedItems,
 	})
 }
 
@@ -143,22 +162,36 @@ func CreatePurchaseOrder(c *gin.Context) {
 		return
 	}
 
-	// Calculate total
+	// Calculate total (received quantities only move through receipts)
 	var totalAmount float64
-	for _, item := range input.Items {
-		totalAmount += item.TotalCost
+	for i := range input.Items {
+		input.Items[i].ReceivedQty = 0
+		totalAmount += input.Items[i].TotalCost
 	}
 
+	userID := c.GetUint("userID")
 	po := models.PurchaseOrder{
-		PONumber:    input.PONumber,
+		PONumber:    strings.TrimSpace(input.PONumber),
 		SupplierID:  input.SupplierID,
+		CreatedBy:   &userID,
 		Status:      "draft",
 		TotalAmount: totalAmount,
 		Notes:       input.Notes,
 		Items:       input.Items,
 	}
 
-	if err := config.DB.Create(&po).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Blank PO number: take the next one from the purchase_order sequence
+		if po.PONumber == "" {
+			poNumber, err := helpers.NextSequenceNumber(tx, helpers.SeqPurchaseOrder)
+			if err != nil {
+				return err
+			}
+			po.PONumber = poNumber
+		}
+		return tx.Create(&po).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create PO"})
 		return
 	}
@@ -166,7 +199,8 @@ func CreatePurchaseOrder(c *gin.Context) {
 	c.JSON(http.StatusCreated, po)
 }
 
-// UpdatePurchaseOrder updates a PO (only if draft)
+// UpdatePurchaseOrder replaces the lines of a PO that has no receipts and is not cancelled. Submitted or
+// approved POs go back to draft; sent POs may not grow past the approved amount.
 func UpdatePurchaseOrder(c *gin.Context) {
 	id := c.Param("id")
 	var po models.PurchaseOrder
@@ -181,6 +215,15 @@ func UpdatePurchaseOrder(c *gin.Context) {
 	// 	return
 	// }
 
+	if hasReceipts(po) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "PO sudah ada penerimaan barang, item tidak dapat diubah"})
+		return
+	}
+	if po.Status == models.POCancelled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "PO yang dibatalkan tidak dapat diubah"})
+		return
+	}
+
 	var input struct {
 		PONumber   string                     `json:"po_number"`
 		SupplierID uint                       `json:"supplier_id"`
@@ -206,14 +249,30 @@ func UpdatePurchaseOrder(c *gin.Context) {
 	var totalAmount float64
 	for i := range input.Items {
 		input.Items[i].PurchaseOrderID = po.ID
+		input.Items[i].ReceivedQty = 0
 		totalAmount += input.Items[i].TotalCost
 	}
 
-	po.PONumber = input.PONumber
+	if err := services.PrepareEdit(&po, totalAmount); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Blank PO number keeps the current one
+	if number := strings.TrimSpace(input.PONumber); number != "" {
+		po.PONumber = number
+	}
 	po.SupplierID = input.SupplierID
 	po.Notes = input.Notes
 	po.TotalAmount = totalAmount
 
+	// Products whose incoming stock may change: the old lines and the new ones
+	productIDs := make([]uint, 0, len(po.Items)+len(input.Items))
+	for _, item := range append(po.Items, input.Items...) {
+		productIDs = append(productIDs, item.ProductID)
+	}
+
 	// IMPORTANT: Clear the Items slice in the struct so GORM doesn't try to
 	// save/re-insert the old preloaded items during Save(&po)
 	po.Items = nil
@@ -233,24 +292,35 @@ func UpdatePurchaseOrder(c *gin.Context) {
 		}
 	}
 
+	if err := services.SyncIncoming(tx, productIDs); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update incoming stock"})
+		return
+	}
+
 	tx.Commit()
 	c.JSON(http.StatusOK, po)
 }
 
-// DeletePurchaseOrder deletes a PO (only if draft)
+// DeletePurchaseOrder deletes a PO (only draft or pending approval; others are cancelled)
 func DeletePurchaseOrder(c *gin.Context) {
 	id := c.Param("id")
 	var po models.PurchaseOrder
-	if err := config.DB.First(&po, id).Error; err != nil {
+	if err := config.DB.Preload("Items").First(&po, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "PO not found"})
 		return
 	}
 
-	// Restriction relaxed per user request
-	// if po.Status != "draft" && po.Status != "cancelled" {
-	// 	c.JSON(http.StatusBadRequest, gin.H{"error": "Only draft or cancelled POs can be deleted"})
-	// 	return
-	// }
+	// Approved POs may already be with the supplier: those are cancelled, not deleted
+	if po.Status != models.PODraft && po.Status != models.POPendingApproval {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Hanya PO draft atau menunggu persetujuan yang dapat dihapus, batalkan PO ini"})
+		return
+	}
+
+	if hasReceipts(po) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "PO sudah ada penerimaan barang, tidak dapat dihapus"})
+		return
+	}
 
 	tx := config.DB.Begin()
 
@@ -261,87 +331,343 @@ func DeletePurchaseOrder(c *gin.Context) {
 		return
 	}
 
+	productIDs := make([]uint, 0, len(po.Items))
+	for _, item := range po.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	po.Items = nil
+
 	if err := tx.Delete(&po).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete PO"})
 		return
 	}
 
+	if err := services.SyncIncoming(tx, productIDs); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update incoming stock"})
+		return
+	}
+
 	tx.Commit()
 	c.JSON(http.StatusOK, gin.H{"message": "PO deleted successfully"})
 }
 
-// ReceivePurchaseOrder marks PO as received, updates stock, and creates journal entry
+// ReceivePurchaseOrder books a delivery: all outstanding lines, or just the given items/quantities,
+// with optional freight / customs / import duty allocated as landed cost
 func ReceivePurchaseOrder(c *gin.Context) {
-	id := c.Param("id")
+	id, _ := strconv.Atoi(c.Param("id"))
 
-	tx := config.DB.Begin()
+	var input services.ReceiveInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	receipt, bill, err := services.NewPurchaseReceiptService().Receive(uint(id), input, c.GetUint("userID"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, helpers.ErrPeriodClosed) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
 
 	var po models.PurchaseOrder
-	if err := tx.Preload("Items").First(&po, id).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusNotFound, gin.H{"error": "PO not found"})
+	config.DB.Preload("Supplier").Preload("Items.Product").First(&po, id)
+	c.JSON(http.StatusOK, gin.H{"message": "PO received and stock updated", "po": po, "receipt": receipt, "bill": bill})
+}
+
+// GetPurchaseReceipts lists the deliveries booked against a PO
+func GetPurchaseReceipts(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	receipts, err := services.NewPurchaseReceiptService().ListReceipts(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receipts"})
 		return
 	}
 
-	if po.Status == "received" {
-		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "PO already received"})
+	c.JSON(http.StatusOK, gin.H{"data": receipts})
+}
+
+// hasReceipts: once goods came in, PO lines carry stock and journal history and can't be rewritten
+func hasReceipts(po models.PurchaseOrder) bool {
+	var count int64
+	config.DB.Model(&models.PurchaseReceipt{}).Where("purchase_order_id = ?", po.ID).Count(&count)
+	return count > 0 || po.Status == "received"
+}
+
+// ============================================
+// REORDER SUGGESTIONS
+// ============================================
+
+// GetReorderSuggestions - What to buy, from whom and how much (?supplier_id=, ?all=true for every item)
+func GetReorderSuggestions(c *gin.Context) {
+	supplierID, _ := strconv.Atoi(c.Query("supplier_id"))
+
+	suggestions, err := services.NewReplenishmentService().Suggestions(services.ReorderFilter{
+		SupplierID: uint(supplierID),
+		All:        c.Query("all") == "true",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute reorder suggestions"})
 		return
 	}
+	if suggestions == nil {
+		suggestions = []services.ReorderSuggestion{}
+	}
 
-	// 1. Update Stock for each item
-	for _, item := range po.Items {
-		var product models.Product
-		if err := tx.First(&product, item.ProductID).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Product not found in PO item"})
+	c.JSON(http.StatusOK, gin.H{"data": suggestions, "count": len(suggestions)})
+}
+
+// CreateReorderDrafts - Draft one PO per supplier from the suggestions (or from the given lines)
+func CreateReorderDrafts(c *gin.Context) {
+	var input services.DraftInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+	}
 
-		// Increase Request Stock (Assuming standard stock logic)
-		// Or if we have separate warehouse tracking, update there.
-		// Use standard "Stock" field for now.
-		product.Stock += item.Quantity
-		tx.Save(&product)
-	}
-
-	// 4. Create Journal Entry (Inventory Asset vs Accounts Payable/Cash)
-	// Simplified: Assuming bought on credit (AP)
-	coaInvID, _ := helpers.GetCOAByCode("1003") // Persediaan Barang
-	coaAPID, _ := helpers.GetCOAByCode("2001")  // Hutang Usaha
-
-	if coaInvID != 0 && coaAPID != 0 {
-		je := models.JournalEntry{
-			Date:          time.Now(),
-			Description:   "Purchase Order #" + po.PONumber,
-			ReferenceID:   po.PONumber,
-			ReferenceType: "PURCHASE_ORDER",
-			Items: []models.JournalItem{
-				{
-					COAID:  coaInvID,
-					Debit:  po.TotalAmount,
-					Credit: 0,
-				},
-				{
-					COAID:  coaAPID,
-					Debit:  0,
-					Credit: po.TotalAmount,
-				},
-			},
-		}
+	pos, err := services.NewReplenishmentService().CreateDrafts(input, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		if err := tx.Create(&je).Error; err != nil {
-			log.Println("⚠️ Failed to create auto-journal for PO:", err)
+	c.JSON(http.StatusCreated, gin.H{"data": pos, "count": len(pos)})
+}
+
+// ApprovePurchaseOrders - Place several draft POs at once: {"ids": [1, 2, 3]}
+func ApprovePurchaseOrders(c *gin.Context) {
+	var input struct {
+		IDs []uint `json:"ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pos, err := services.NewReplenishmentService().ApproveDrafts(input.IDs, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(poErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": pos, "message": "PO berhasil disetujui"})
+}
+
+// poErrorStatus maps PO lifecycle errors: over the approval limit or approving one's own PO is a 403,
+// the rest are bad requests
+func poErrorStatus(err error) int {
+	if errors.Is(err, services.ErrApprovalLimit) || errors.Is(err, services.ErrSelfApproval) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// SubmitPurchaseOrder - Send a draft PO for approval by another user
+func SubmitPurchaseOrder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	po, err := services.NewPurchaseOrderService().Submit(uint(id), c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": po, "message": "PO menunggu persetujuan"})
+}
+
+// ApprovePurchaseOrder - Approve a draft or submitted PO within the role's approval limit
+func ApprovePurchaseOrder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	po, err := services.NewPurchaseOrderService().Approve(uint(id), c.GetUint("userID"))
+	if err != nil {
+		c.JSON(poErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": po, "message": "PO disetujui"})
+}
+
+// RejectPurchaseOrder - Send a submitted PO back to draft with a reason
+func RejectPurchaseOrder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Alasan penolakan wajib diisi"})
+		return
+	}
+
+	po, err := services.NewPurchaseOrderService().Reject(uint(id), input.Reason, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": po, "message": "PO ditolak"})
+}
+
+// SendPurchaseOrder - Email the PO to the supplier (or the given address) and place the order
+func SendPurchaseOrder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input struct {
+		Email string `json:"email"`
+	}
+	_ = c.ShouldBindJSON(&input)
+
+	po, err := services.NewPurchaseOrderService().Send(uint(id), strings.TrimSpace(input.Email), c.GetUint("userID"))
+	if err != nil && po != nil {
+		// Ordered, but the email did not go out: the PO can be sent again
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "data": po})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": po, "message": "PO dikirim ke " + po.SentTo})
+}
+
+// CancelPurchaseOrder - Cancel a PO nothing was received on yet
+func CancelPurchaseOrder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	po, err := services.NewPurchaseOrderService().Cancel(uint(id), c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": po, "message": "PO dibatalkan"})
+}
+
+// SetPurchaseOrderExpectedDate - Move the expected delivery date ({"expected_date":"2006-01-02"})
+func SetPurchaseOrderExpectedDate(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input struct {
+		ExpectedDate string `json:"expected_date" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	date, err := time.ParseInLocation("2006-01-02", input.ExpectedDate, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expected_date must be YYYY-MM-DD"})
+		return
+	}
+
+	po, err := services.NewPurchaseOrderService().SetExpectedDate(uint(id), date, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": po})
+}
+
+// GetOverduePurchaseOrders - Ordered POs past their expected date
+func GetOverduePurchaseOrders(c *gin.Context) {
+	pos, err := services.NewPurchaseOrderService().Overdue()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch overdue POs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": pos, "total": len(pos)})
+}
+
+// DownloadPurchaseOrderPDF - The PO document as PDF (?format=html for the printable page)
+func DownloadPurchaseOrderPDF(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	doc, err := services.NewPurchaseOrderService().Document(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	writePODocument(c, doc, c.Query("format") == "html")
+}
+
+// writePODocument renders the PO into a buffer first so a render error can still be reported as JSON
+func writePODocument(c *gin.Context, doc *services.PODocument, html bool) {
+	var buf bytes.Buffer
+	if html {
+		if err := services.RenderPOHTML(doc, &buf); err != nil {
+			log.Printf("⚠️ PO %s render failed: %v", doc.PONumber, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render PO"})
+			return
 		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+		return
 	}
 
-	// 3. Update PO Status
-	now := time.Now()
-	po.Status = "received"
-	po.ReceivedAt = &now
-	tx.Save(&po)
+	if err := services.RenderPOPDF(doc, &buf); err != nil {
+		log.Printf("⚠️ PO %s PDF failed: %v", doc.PONumber, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render PO"})
+		return
+	}
+	filename := strings.NewReplacer("/", "-", " ", "_").Replace(doc.PONumber) + ".pdf"
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
 
-	tx.Commit()
-	c.JSON(http.StatusOK, gin.H{"message": "PO received and stock updated", "po": po})
+// GetProductSuppliers - Suppliers a product can be bought from (?product_id=)
+func GetProductSuppliers(c *gin.Context) {
+	productID, _ := strconv.Atoi(c.Query("product_id"))
+	if productID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_id is required"})
+		return
+	}
+
+	links, err := services.NewReplenishmentService().ListProductSuppliers(uint(productID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch product suppliers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": links})
+}
+
+// SaveProductSupplier - Link a product to a supplier with its cost, lead time and minimum order
+func SaveProductSupplier(c *gin.Context) {
+	var input services.ProductSupplierInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link, err := services.NewReplenishmentService().SaveProductSupplier(input, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, link)
+}
+
+// DeleteProductSupplier - Remove a product/supplier link
+func DeleteProductSupplier(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := services.NewReplenishmentService().DeleteProductSupplier(uint(id), c.GetUint("userID")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Product supplier removed"})
 }
@@ -12,6 +12,7 @@ import (
 	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetProductStats - Get product statistics
@@ -227,6 +228,7 @@ func GetProduct(c *gin.Context) {
 		Preload("Scale").
 		Preload("Material").
 		Preload("EditionType").
+		Preload("Variants", activeVariants).
 		First(&product, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
@@ -237,10 +239,27 @@ func GetProduct(c *gin.Context) {
 	if product.AvailableStock < 0 {
 		product.AvailableStock = 0
 	}
+	fillVariantStock(&product)
 
 	c.JSON(http.StatusOK, product)
 }
 
+// activeVariants preloads the editions shown on the product page, in display order
+func activeVariants(db *gorm.DB) *gorm.DB {
+	return db.Where("status = ?", "active").Order("display_order ASC, id ASC")
+}
+
+// fillVariantStock computes the virtual available stock of every preloaded edition
+func fillVariantStock(product *models.Product) {
+	for i := range product.Variants {
+		v := &product.Variants[i]
+		v.AvailableStock = v.Stock - v.ReservedQty
+		if v.AvailableStock < 0 {
+			v.AvailableStock = 0
+		}
+	}
+}
+
 // GetRelatedProducts - Get products from the same category (US-PRD-008)
 func GetRelatedProducts(c *gin.Context) {
 	id := c.Param("id")
@@ -321,6 +340,7 @@ func GetProductBySlug(c *gin.Context) {
 		Preload("Scale").
 		Preload("Material").
 		Preload("EditionType").
+		Preload("Variants", activeVariants).
 		Where("slug = ?", slug).First(&product).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
 		return
@@ -331,6 +351,7 @@ func GetProductBySlug(c *gin.Context) {
 	if product.AvailableStock < 0 {
 		product.AvailableStock = 0
 	}
+	fillVariantStock(&product)
 
 	c.JSON(http.StatusOK, product)
 }
@@ -403,3 +424,81 @@ func DeleteProduct(c *gin.Context) {
 	helpers.Cache.Flush()
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted"})
 }
+
+// GetProductVariants - List every edition of a product (admin)
+func GetProductVariants(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	svc := services.NewProductService()
+	variants, err := svc.ListVariants(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch variants"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": variants})
+}
+
+// CreateProductVariant - Add an edition (standard/deluxe/exclusive, regional) to a product
+func CreateProductVariant(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input services.VariantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("currentUser").(models.User).ID
+
+	svc := services.NewProductService()
+	variant, err := svc.CreateVariant(uint(id), input, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	helpers.Cache.Flush()
+	c.JSON(http.StatusCreated, variant)
+}
+
+// UpdateProductVariant - Edit an edition's price, stock, weight or status
+func UpdateProductVariant(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	variantID, _ := strconv.Atoi(c.Param("variantId"))
+
+	var input services.VariantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := c.MustGet("currentUser").(models.User).ID
+
+	svc := services.NewProductService()
+	variant, err := svc.UpdateVariant(uint(id), uint(variantID), input, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	helpers.Cache.Flush()
+	c.JSON(http.StatusOK, variant)
+}
+
+// DeleteProductVariant - Remove an edition that has no open reservations
+func DeleteProductVariant(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	variantID, _ := strconv.Atoi(c.Param("variantId"))
+
+	userID := c.MustGet("currentUser").(models.User).ID
+
+	svc := services.NewProductService()
+	if err := svc.DeleteVariant(uint(id), uint(variantID), userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	helpers.Cache.Flush()
+	c.JSON(http.StatusOK, gin.H{"message": "Variant deleted"})
+}
@@ -75,7 +75,15 @@ func MarkPOArrived(c *gin.Context) {
 	for _, order := range orders {
 		var balanceInvoice models.Invoice
 		// Find the balance invoice that is pending arrival
-		if err := config.DB.Where("order_id = ? AND type = ? AND status = ?", order.ID, "balance", "pending_arrival").First(&balanceInvoice).Error; err == nil {
+		// Only this product's line (mixed orders carry one balance per PO line; legacy ones a single order-level balance)
+		lineIDs := config.DB.Model(&models.OrderItem{}).Select("id").Where("order_id = ? AND product_id = ?", order.ID, product.ID)
+		if err := config.DB.Where("order_id = ? AND type = ? AND status = ?", order.ID, "balance", "pending_arrival").
+			Where("order_item_id IS NULL OR order_item_id IN (?)", lineIDs).
+			First(&balanceInvoice).Error; err == nil {
+			config.DB.Model(&models.OrderItem{}).
+				Where("order_id = ? AND product_id = ? AND arrived_at IS NULL", order.ID, product.ID).
+				Update("arrived_at", time.Now())
+
 			// Found one! Activate it.
 
 			// 3. Update Invoice
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forzashop/backend/models"
+	"forzashop/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StartBankMutationWorker imports bank mutation files dropped in bank_mutation_dir so transfers
+// carrying an invoice's unique code mark the invoice paid without an admin
+func StartBankMutationWorker() {
+	ticker := time.NewTicker(5 * time.Minute)
+
+	go func() {
+		log.Println("🏦 Bank Mutation Worker Started")
+		svc := services.NewBankTransferService()
+		for range ticker.C {
+			svc.ImportMutationFiles()
+		}
+	}()
+}
+
+// ============================================
+// ADMIN: SETTLEMENT RECONCILIATION
+// ============================================
+
+// ImportSettlement - Admin uploads a gateway settlement report or bank statement CSV
+// (multipart: file, source=gateway|bank, provider, bank_coa_id)
+func ImportSettlement(c *gin.Context) {
+	admin := c.MustGet("currentUser").(models.User)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required"})
+		return
+	}
+	if fileHeader.Size > 10*1024*1024 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 10MB)"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to open file"})
+		return
+	}
+	defer file.Close()
+
+	input := services.ImportSettlementInput{
+		Source:     c.DefaultPostForm("source", "gateway"),
+		Provider:   c.PostForm("provider"),
+		FileName:   fileHeader.Filename,
+		ImportedBy: admin.ID,
+	}
+	if id, _ := strconv.Atoi(c.PostForm("bank_coa_id")); id > 0 {
+		coaID := uint(id)
+		input.BankCOAID = &coaID
+	}
+
+	batch, err := services.NewReconciliationService().Import(input, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, batch)
+}
+
+// GetSettlementBatches - Admin: list imported reports (?source=)
+func GetSettlementBatches(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	batches, total, err := services.NewReconciliationService().ListBatches(c.Query("source"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settlement batches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  batches,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetSettlementBatch - Admin: batch detail with all lines
+func GetSettlementBatch(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	batch, err := services.NewReconciliationService().GetBatch(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// GetSettlementLines - Admin: lines by status (?batch_id=, ?status=unmatched,mismatched).
+// Without a status it lists every open unmatched and mismatched item.
+func GetSettlementLines(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	batchID, _ := strconv.Atoi(c.Query("batch_id"))
+
+	statuses := []string{models.SettlementLineUnmatched, models.SettlementLineMismatched}
+	if status := c.Query("status"); status == "all" {
+		statuses = nil
+	} else if status != "" {
+		statuses = strings.Split(status, ",")
+	}
+
+	lines, total, err := services.NewReconciliationService().ListLines(uint(batchID), statuses, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settlement lines"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  lines,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// ConfirmSettlement - Admin confirms the matched lines of a batch (all, or line_ids) and posts the journals
+func ConfirmSettlement(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	admin := c.MustGet("currentUser").(models.User)
+
+	var input struct {
+		LineIDs []uint `json:"line_ids"`
+	}
+	c.ShouldBindJSON(&input)
+
+	result, err := services.NewReconciliationService().Confirm(uint(id), input.LineIDs, admin.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// MatchSettlementLine - Admin matches a line by hand (payment_tx_id or invoice_id), or re-runs auto-matching with neither
+func MatchSettlementLine(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input struct {
+		PaymentTxID uint `json:"payment_tx_id"`
+		InvoiceID   uint `json:"invoice_id"`
+	}
+	c.ShouldBindJSON(&input)
+
+	line, err := services.NewReconciliationService().MatchLine(uint(id), input.PaymentTxID, input.InvoiceID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, line)
+}
+
+// IgnoreSettlementLine - Admin excludes a line (bank charge, internal transfer, ...)
+func IgnoreSettlementLine(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	var input struct {
+		Note string `json:"note"`
+	}
+	c.ShouldBindJSON(&input)
+
+	line, err := services.NewReconciliationService().IgnoreLine(uint(id), input.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, line)
+}
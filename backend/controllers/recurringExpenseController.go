@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"forzashop/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================
+// RECURRING EXPENSES
+// ============================================
+
+// bindRecurringExpense reads the schedule body; start_date / end_date are YYYY-MM-DD
+func bindRecurringExpense(c *gin.Context) (services.RecurringExpenseInput, bool) {
+	var input struct {
+		services.RecurringExpenseInput
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"` // Empty = no end
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return input.RecurringExpenseInput, false
+	}
+
+	out := input.RecurringExpenseInput
+	if input.StartDate != "" {
+		start, err := time.ParseInLocation("2006-01-02", input.StartDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must be YYYY-MM-DD"})
+			return out, false
+		}
+		out.StartDate = start
+	}
+	if input.EndDate != "" {
+		end, err := time.ParseInLocation("2006-01-02", input.EndDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must be YYYY-MM-DD"})
+			return out, false
+		}
+		out.EndDate = &end
+	}
+	return out, true
+}
+
+// GetRecurringExpenses - List schedules
+func GetRecurringExpenses(c *gin.Context) {
+	schedules, err := services.NewRecurringExpenseService().List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recurring expenses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": schedules})
+}
+
+// CreateRecurringExpense - Create a weekly / monthly / yearly schedule
+func CreateRecurringExpense(c *gin.Context) {
+	input, ok := bindRecurringExpense(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := services.NewRecurringExpenseService().Create(input, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// UpdateRecurringExpense - Change a schedule (generated expenses stay as posted)
+func UpdateRecurringExpense(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	input, ok := bindRecurringExpense(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := services.NewRecurringExpenseService().Update(uint(id), input, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteRecurringExpense - Remove a schedule
+func DeleteRecurringExpense(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	if err := services.NewRecurringExpenseService().Delete(uint(id), c.GetUint("userID")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Biaya rutin dihapus"})
+}
+
+// RunRecurringExpenses - Post every due occurrence now instead of waiting for the nightly run
+func RunRecurringExpenses(c *gin.Context) {
+	result, err := services.NewRecurringExpenseService().RunDue(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+	"forzashop/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================
+// ADMIN: REFUNDS
+// ============================================
+
+// GetRefunds - Admin: list refunds (?status=, ?order_id=)
+func GetRefunds(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	orderID, _ := strconv.Atoi(c.Query("order_id"))
+
+	refunds, total, err := services.NewRefundService().ListRefunds(c.Query("status"), uint(orderID), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  refunds,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetRefund - Admin: refund detail
+func GetRefund(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	refund, err := services.NewRefundService().GetRefund(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, refund)
+}
+
+// RetryRefund - Admin re-runs a failed refund
+func RetryRefund(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	admin := c.MustGet("currentUser").(models.User)
+
+	refund, err := services.NewRefundService().Retry(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	helpers.LogAuditSimple(admin.ID, "Refund", "Retry", refund.ID, fmt.Sprintf("Retried refund %s (%s)", refund.RefundNumber, refund.Status))
+	c.JSON(http.StatusOK, refund)
+}
+
+// ConfirmRefund - Admin confirms a processing refund was paid out (gateway settled it, or a manual transfer was made)
+func ConfirmRefund(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	admin := c.MustGet("currentUser").(models.User)
+
+	var input struct {
+		GatewayRef string `json:"gateway_ref"`
+	}
+	c.ShouldBindJSON(&input)
+
+	refund, err := services.NewRefundService().Confirm(uint(id), input.GatewayRef)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	helpers.LogAuditSimple(admin.ID, "Refund", "Confirm", refund.ID, fmt.Sprintf("Confirmed refund %s, ref %s", refund.RefundNumber, refund.GatewayRef))
+	c.JSON(http.StatusOK, refund)
+}
+
+// FailRefund - Admin marks a processing refund as failed
+func FailRefund(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	admin := c.MustGet("currentUser").(models.User)
+
+	var input struct {
+		Note string `json:"note" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	refund, err := services.NewRefundService().Reject(uint(id), input.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	helpers.LogAuditSimple(admin.ID, "Refund", "Fail", refund.ID, fmt.Sprintf("Marked refund %s failed: %s", refund.RefundNumber, input.Note))
+	c.JSON(http.StatusOK, refund)
+}
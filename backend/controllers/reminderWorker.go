@@ -8,8 +8,7 @@ import (
 	"forzashop/backend/config"
 	"forzashop/backend/helpers"
 	"forzashop/backend/models"
-
-	"gorm.io/gorm"
+	"forzashop/backend/services"
 )
 
 // StartPaymentReminderWorker is a background job
@@ -23,6 +22,7 @@ func StartPaymentReminderWorker() {
 			processPaymentReminders()
 			processExpiredOrders()
 			processPOBalanceReminders()
+			services.NewRefundService().ProcessPending()
 		}
 	}()
 }
@@ -112,10 +112,11 @@ func processExpiredOrders() {
 					order.Status = "cancelled"
 					order.InternalNotes += "\n[SYSTEM] Auto-cancelled due to payment expiration"
 
-					for _, item := range order.Items {
-						// ✅ Atomic update — safe against race conditions
-						tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
-							Update("reserved_qty", gorm.Expr("GREATEST(reserved_qty - ?, 0)", item.Quantity))
+					// Release the order's holds through the reservation ledger
+					if err := (&services.InventoryService{DB: tx}).ReleaseOrder(order, "cancellation", "Auto-cancel: Payment expired", nil); err != nil {
+						log.Printf("⚠️ Failed to release stock of order %s: %v", order.OrderNumber, err)
+						tx.Rollback()
+						continue
 					}
 
 					tx.Save(&order)
@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,10 +9,10 @@ import (
 	"forzashop/backend/config"
 	"forzashop/backend/helpers"
 	"forzashop/backend/models"
+	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 // ReservationTTL is the default time-to-live for stock reservations
@@ -25,8 +24,9 @@ const ReservationTTL = 30 * time.Minute
 
 // ReserveStockInput represents the request to reserve stock
 type ReserveStockInput struct {
-	ProductID uint `json:"product_id" binding:"required"`
-	Quantity  int  `json:"quantity" binding:"required,min=1"`
+	ProductID uint  `json:"product_id" binding:"required"`
+	VariantID *uint `json:"variant_id"`
+	Quantity  int   `json:"quantity" binding:"required,min=1"`
 }
 
 // ReserveStock creates a stock reservation for checkout
@@ -52,7 +52,7 @@ func ReserveStock(c *gin.Context) {
 		return
 	}
 
-	reservation, err := CreateReservation(input.ProductID, input.Quantity, userID, 0)
+	reservation, err := CreateReservation(input.ProductID, input.VariantID, input.Quantity, userID, 0)
 	if err != nil {
 		c.JSON(http.StatusConflict, gin.H{
 			"error":   err.Error(),
@@ -69,58 +69,36 @@ func ReserveStock(c *gin.Context) {
 	})
 }
 
-// CreateReservation handles the actual reservation logic with proper locking
-// This is the core function that prevents race conditions
-func CreateReservation(productID uint, quantity int, userID uint, orderID uint) (*models.StockReservation, error) {
-	var reservation models.StockReservation
+// CreateReservation places a cart hold through the inventory service, which reserves the
+// stock atomically and records the reservation row in the same transaction
+func CreateReservation(productID uint, variantID *uint, quantity int, userID uint, orderID uint) (*models.StockReservation, error) {
+	var reservation *models.StockReservation
 
 	err := config.DB.Transaction(func(tx *gorm.DB) error {
-		// 1. Lock the product row for update (prevents concurrent reads)
-		var product models.Product
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			First(&product, productID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("product not found")
-			}
+		if _, _, err := services.LoadSellable(tx, productID, variantID); err != nil {
 			return err
 		}
 
-		// 2. Calculate available stock (total - reserved)
-		availableStock := product.Stock - product.ReservedQty
-		if availableStock < quantity {
-			return errors.New("insufficient stock available")
-		}
-
-		// 3. Create the reservation
-		reservation = models.StockReservation{
-			OrderID:   orderID,
+		var err error
+		reservation, err = (&services.InventoryService{DB: tx}).Hold(services.HoldInput{
 			ProductID: productID,
+			VariantID: variantID,
 			Quantity:  quantity,
-			Status:    models.ReservationReserved,
-			ExpiresAt: time.Now().Add(ReservationTTL),
-		}
-
-		if err := tx.Create(&reservation).Error; err != nil {
-			return err
-		}
-
-		// 4. Update product's reserved quantity
-		if err := tx.Model(&product).
-			Update("reserved_qty", gorm.Expr("reserved_qty + ?", quantity)).Error; err != nil {
-			return err
-		}
-
-		log.Printf("📦 RESERVATION CREATED: Product %d, Qty %d, Expires %s",
-			productID, quantity, reservation.ExpiresAt.Format("15:04:05"))
-
-		return nil
+			OrderID:   orderID,
+			Source:    "cart",
+			TTL:       ReservationTTL,
+		})
+		return err
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return &reservation, nil
+	log.Printf("📦 RESERVATION CREATED: Product %d, Qty %d, Expires %s",
+		productID, quantity, reservation.ExpiresAt.Format("15:04:05"))
+
+	return reservation, nil
 }
 
 // ========================================
@@ -130,48 +108,13 @@ func CreateReservation(productID uint, quantity int, userID uint, orderID uint)
 // ConsumeReservation converts a reservation to actual stock deduction
 // Called when payment is confirmed
 func ConsumeReservation(reservationID uint) error {
-	return config.DB.Transaction(func(tx *gorm.DB) error {
-		// 1. Get and lock the reservation
-		var reservation models.StockReservation
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			First(&reservation, reservationID).Error; err != nil {
-			return err
-		}
-
-		// 2. Verify it's still in reserved status
-		if reservation.Status != models.ReservationReserved {
-			return errors.New("reservation is no longer active")
-		}
-
-		// 3. Lock the product
-		var product models.Product
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			First(&product, reservation.ProductID).Error; err != nil {
-			return err
-		}
-
-		// 4. Deduct actual stock and reserved qty
-		now := time.Now()
-		if err := tx.Model(&product).Updates(map[string]interface{}{
-			"stock":        gorm.Expr("stock - ?", reservation.Quantity),
-			"reserved_qty": gorm.Expr("GREATEST(reserved_qty - ?, 0)", reservation.Quantity),
-		}).Error; err != nil {
-			return err
-		}
-
-		// 5. Mark reservation as consumed
-		if err := tx.Model(&reservation).Updates(map[string]interface{}{
-			"status":      models.ReservationConsumed,
-			"consumed_at": now,
-		}).Error; err != nil {
-			return err
-		}
-
-		log.Printf("✅ RESERVATION CONSUMED: ID %d, Product %d, Qty %d",
-			reservationID, reservation.ProductID, reservation.Quantity)
-
-		return nil
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return (&services.InventoryService{DB: tx}).ConsumeReservation(reservationID)
 	})
+	if err == nil {
+		log.Printf("✅ RESERVATION CONSUMED: ID %d", reservationID)
+	}
+	return err
 }
 
 // ConsumeReservationByOrderID consumes all reservations for an order
@@ -198,51 +141,13 @@ func ConsumeReservationByOrderID(orderID uint) error {
 
 // ReleaseReservation releases a reservation and returns stock to available pool
 func ReleaseReservation(reservationID uint, releasedBy string) error {
-	return config.DB.Transaction(func(tx *gorm.DB) error {
-		// 1. Get and lock the reservation
-		var reservation models.StockReservation
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			First(&reservation, reservationID).Error; err != nil {
-			return err
-		}
-
-		// 2. Only release if currently reserved
-		if reservation.Status != models.ReservationReserved {
-			return nil // Already released/consumed, no action needed
-		}
-
-		// 3. Lock the product and release reserved qty
-		var product models.Product
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			First(&product, reservation.ProductID).Error; err != nil {
-			return err
-		}
-
-		now := time.Now()
-		if err := tx.Model(&product).
-			Update("reserved_qty", gorm.Expr("GREATEST(reserved_qty - ?, 0)", reservation.Quantity)).Error; err != nil {
-			return err
-		}
-
-		// 4. Mark reservation as released
-		status := models.ReservationReleased
-		if releasedBy == "system_expiry" {
-			status = models.ReservationExpired
-		}
-
-		if err := tx.Model(&reservation).Updates(map[string]interface{}{
-			"status":      status,
-			"released_at": now,
-			"released_by": releasedBy,
-		}).Error; err != nil {
-			return err
-		}
-
-		log.Printf("🔓 RESERVATION RELEASED: ID %d, Product %d, Qty %d, By %s",
-			reservationID, reservation.ProductID, reservation.Quantity, releasedBy)
-
-		return nil
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return (&services.InventoryService{DB: tx}).ReleaseReservation(reservationID, releasedBy)
 	})
+	if err == nil {
+		log.Printf("🔓 RESERVATION RELEASED: ID %d, By %s", reservationID, releasedBy)
+	}
+	return err
 }
 
 // ReleaseReservationByOrderID releases all reservations for an order
@@ -269,10 +174,8 @@ func ReleaseReservationByOrderID(orderID uint, releasedBy string) error {
 // CleanupExpiredReservations finds and releases all expired reservations
 // This should be called periodically by a cron job or ticker
 func CleanupExpiredReservations() {
-	var expired []models.StockReservation
-
-	if err := config.DB.Where("status = ? AND expires_at < ?",
-		models.ReservationReserved, time.Now()).Find(&expired).Error; err != nil {
+	expired, err := services.NewInventoryService().ReleaseExpired()
+	if err != nil {
 		log.Printf("❌ Error finding expired reservations: %v", err)
 		return
 	}
@@ -281,30 +184,30 @@ func CleanupExpiredReservations() {
 		return
 	}
 
-	log.Printf("🕐 Found %d expired reservations to cleanup", len(expired))
+	log.Printf("🕐 Released %d expired reservations", len(expired))
 
 	for _, r := range expired {
-		if err := ReleaseReservation(r.ID, "system_expiry"); err != nil {
-			log.Printf("❌ Failed to release expired reservation %d: %v", r.ID, err)
-			continue
-		}
-
 		// If this reservation was linked to an order, cancel the order too if it's still pending
 		if r.OrderID != 0 {
 			var order models.Order
-			if err := config.DB.Preload("Invoices").First(&order, r.OrderID).Error; err == nil {
+			if err := config.DB.Preload("Items").Preload("Invoices").First(&order, r.OrderID).Error; err == nil {
 				if order.Status == "pending" {
-					config.DB.Model(&order).Updates(map[string]interface{}{
-						"status":         "cancelled",
-						"internal_notes": order.InternalNotes + "\n[SYSTEM] Auto-cancelled: Payment reservation expired (30m).",
-					})
-
-					// Expire associated invoices
-					for _, inv := range order.Invoices {
-						if inv.Status == "unpaid" {
-							config.DB.Model(&inv).Update("status", "expired")
+					config.DB.Transaction(func(tx *gorm.DB) error {
+						tx.Model(&order).Updates(map[string]interface{}{
+							"status":         "cancelled",
+							"internal_notes": order.InternalNotes + "\n[SYSTEM] Auto-cancelled: Payment reservation expired (30m).",
+						})
+
+						// Expire associated invoices
+						for _, inv := range order.Invoices {
+							if inv.Status == "unpaid" {
+								tx.Model(&inv).Update("status", "expired")
+							}
 						}
-					}
+
+						// The order's other holds go back to the shelf as well
+						return (&services.InventoryService{DB: tx}).ReleaseOrder(order, "cancellation", "Auto-cancel: Reservation expired", nil)
+					})
 
 					log.Printf("🚫 AUTO-CANCEL: Order %d cancelled due to reservation expiry", r.OrderID)
 				}
@@ -367,10 +270,11 @@ func CleanupExpiredOrders() {
 		}
 
 		// 1. Release Stock
+		if err := (&services.InventoryService{DB: tx}).ReleaseOrder(inv.Order, "cancellation", "Auto-cancel: Payment expired", nil); err != nil {
+			log.Printf("⚠️ Failed to release stock of order %d: %v", inv.Order.ID, err)
+			continue
+		}
 		for _, item := range inv.Order.Items {
-			helpers.RecordStockMovement(tx, item.ProductID, -item.Quantity, "reserved", "cancellation", "ORDER", inv.Order.OrderNumber, "Auto-cancel: Payment expired", nil)
-			tx.Model(&models.Product{}).Where("id = ?", item.ProductID).
-				Update("reserved_qty", gorm.Expr("GREATEST(reserved_qty - ?, 0)", item.Quantity))
 
 			// Sektor 2: Waitlist / Restock Notifier
 			var waitlists []models.RestockNotification
@@ -483,6 +387,30 @@ func GetReservationStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"reservations": result})
 }
 
+// GetReservationDrift lists products/editions whose reserved_qty disagrees with the reservation ledger (dry run)
+func GetReservationDrift(c *gin.Context) {
+	report, err := services.NewInventoryService().Reconcile(false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile reservations"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ReconcileReservations backfills ledger rows for legacy holds and rewrites drifting reserved_qty
+func ReconcileReservations(c *gin.Context) {
+	report, err := services.NewInventoryService().Reconcile(true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile reservations"})
+		return
+	}
+
+	admin := c.MustGet("currentUser").(models.User)
+	helpers.LogAudit(admin.ID, "StockReservation", "Reconcile", "", fmt.Sprintf("Fixed %d drifts, backfilled %d holds", len(report.Drifts), report.Backfilled), nil, report, c.ClientIP(), c.Request.UserAgent())
+
+	c.JSON(http.StatusOK, report)
+}
+
 // CheckStockAvailability checks if stock is available for checkout
 func CheckStockAvailability(c *gin.Context) {
 	log.Println("🔍 CheckStockAvailability endpoint HIT")
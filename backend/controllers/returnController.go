@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"forzashop/backend/models"
+	"forzashop/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxReturnPhotos = 6
+
+// ============================================
+// CUSTOMER: RETURNS / RMA
+// ============================================
+
+// CreateReturnRequest - Customer asks to return items of an order (multipart: reason, note, items JSON, photos[])
+func CreateReturnRequest(c *gin.Context) {
+	user := c.MustGet("currentUser").(models.User)
+	orderID, _ := strconv.Atoi(c.Param("id"))
+
+	var items []services.ReturnItemInput
+	if err := json.Unmarshal([]byte(c.PostForm("items")), &items); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid items payload"})
+		return
+	}
+
+	// Photos go through the same image pipeline as admin uploads
+	var photos []string
+	if form, err := c.MultipartForm(); err == nil {
+		files := form.File["photos"]
+		if len(files) > maxReturnPhotos {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Maximum 6 photos per return"})
+			return
+		}
+		for _, fileHeader := range files {
+			url, err := saveImageUpload(c, fileHeader, "returns", 80)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			photos = append(photos, url)
+		}
+	}
+	if len(photos) == 0 && c.PostForm("reason") == "damaged" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Please attach photos of the damage"})
+		return
+	}
+
+	rma, err := services.NewReturnService().CreateReturn(services.CreateReturnInput{
+		OrderID: uint(orderID),
+		UserID:  user.ID,
+		Reason:  c.PostForm("reason"),
+		Note:    c.PostForm("note"),
+		Photos:  photos,
+		Items:   items,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rma)
+}
+
+// GetCustomerReturns - Customer: RMAs of an order
+func GetCustomerReturns(c *gin.Context) {
+	user := c.MustGet("currentUser").(models.User)
+	orderID, _ := strconv.Atoi(c.Param("id"))
+
+	returns, _, err := services.NewReturnService().ListReturns("", uint(orderID), user.ID, 1, 100)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch returns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, returns)
+}
+
+// ShipReturn - Customer enters the courier and waybill of the return parcel
+func ShipReturn(c *gin.Context) {
+	user := c.MustGet("currentUser").(models.User)
+	returnID, _ := strconv.Atoi(c.Param("returnId"))
+
+	var input struct {
+		Carrier        string `json:"carrier"`
+		TrackingNumber string `json:"tracking_number" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rma, err := services.NewReturnService().ShipBack(uint(returnID), user.ID, input.Carrier, input.TrackingNumber)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// CancelReturnRequest - Customer withdraws a return before shipping it
+func CancelReturnRequest(c *gin.Context) {
+	user := c.MustGet("currentUser").(models.User)
+	returnID, _ := strconv.Atoi(c.Param("returnId"))
+
+	rma, err := services.NewReturnService().Cancel(uint(returnID), user.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// ============================================
+// ADMIN: RETURNS / RMA
+// ============================================
+
+// GetReturns - Admin: list RMAs (?status=, ?order_id=)
+func GetReturns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	orderID, _ := strconv.Atoi(c.Query("order_id"))
+
+	returns, total, err := services.NewReturnService().ListReturns(c.Query("status"), uint(orderID), 0, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch returns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  returns,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// GetReturn - Admin: RMA detail
+func GetReturn(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	rma, err := services.NewReturnService().GetReturn(uint(id), 0)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// AuthorizeReturn - Admin approves the request so the customer can ship the items back
+func AuthorizeReturn(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	admin := c.MustGet("currentUser").(models.User)
+
+	var input struct {
+		Note string `json:"note"`
+	}
+	c.ShouldBindJSON(&input)
+
+	rma, err := services.NewReturnService().Authorize(uint(id), admin.ID, input.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// RejectReturn - Admin declines the request
+func RejectReturn(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	admin := c.MustGet("currentUser").(models.User)
+
+	var input struct {
+		Note string `json:"note" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+		return
+	}
+
+	rma, err := services.NewReturnService().Reject(uint(id), admin.ID, input.Note)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// ReceiveReturn - Warehouse confirms the return parcel arrived
+func ReceiveReturn(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	admin := c.MustGet("currentUser").(models.User)
+
+	rma, err := services.NewReturnService().Receive(uint(id), admin.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// InspectReturn - Warehouse records restock / write_off / return_to_supplier per item
+func InspectReturn(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	admin := c.MustGet("currentUser").(models.User)
+
+	var input struct {
+		Items []services.InspectItemInput `json:"items" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rma, err := services.NewReturnService().Inspect(uint(id), admin.ID, input.Items)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
+
+// ApproveReturn - Admin settles an inspected RMA: stock movements, refund and reversing journals
+func ApproveReturn(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	admin := c.MustGet("currentUser").(models.User)
+
+	var input services.ApproveReturnInput
+	c.ShouldBindJSON(&input)
+
+	rma, err := services.NewReturnService().Approve(uint(id), admin, input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rma)
+}
@@ -131,6 +131,39 @@ func UpdateRole(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Role updated", "data": role})
 }
 
+// UpdateRolePOApprovalLimit - Largest PO total this role may approve (0 = cannot approve POs)
+func UpdateRolePOApprovalLimit(c *gin.Context) {
+	roleID := c.Param("id")
+	var input struct {
+		POApprovalLimit *float64 `json:"po_approval_limit" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if *input.POApprovalLimit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "po_approval_limit cannot be negative"})
+		return
+	}
+
+	var role models.Role
+	if err := config.DB.First(&role, roleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return
+	}
+
+	// Super Admin approves any amount
+	if role.Slug == models.RoleSuperAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Super Admin has no approval limit"})
+		return
+	}
+
+	config.DB.Model(&role).Update("po_approval_limit", *input.POApprovalLimit)
+
+	c.JSON(http.StatusOK, gin.H{"message": "PO approval limit updated", "data": role})
+}
+
 // DeleteRole - Remove a role
 func DeleteRole(c *gin.Context) {
 	roleID := c.Param("id")
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sandboxGateway returns the sandbox provider, or answers 404 while it is disabled
+func sandboxGateway(c *gin.Context) *services.SandboxGateway {
+	gateway, err := services.GetPaymentGateway("sandbox")
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil
+	}
+	return gateway.(*services.SandboxGateway)
+}
+
+// SimulateSandboxPayment - Pays or rejects a sandbox charge and delivers the signed callback (local E2E tests)
+func SimulateSandboxPayment(c *gin.Context) {
+	gateway := sandboxGateway(c)
+	if gateway == nil {
+		return
+	}
+
+	var input struct {
+		Status string `json:"status" binding:"required,oneof=paid failed"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	charge, err := gateway.Simulate(c.Param("ref"), input.Status)
+	if charge == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "charge": charge})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sandbox charge " + charge.Status + ", callback delivered", "charge": charge})
+}
+
+// PaySandboxCharge - Hosted "card page" of the sandbox: settles the charge and returns the user like a real gateway would
+func PaySandboxCharge(c *gin.Context) {
+	gateway := sandboxGateway(c)
+	if gateway == nil {
+		return
+	}
+
+	charge, err := gateway.Simulate(c.Param("ref"), services.ChargeStatusPaid)
+	if charge == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The return handler runs an active inquiry, so a failed callback delivery still settles
+	c.Redirect(http.StatusFound, fmt.Sprintf("%s/payment/callback?merchant_ref_no=%s&plink_ref_no=%s&payment_status=%s",
+		helpers.GetAppURL(), url.QueryEscape(charge.MerchantRefNo), url.QueryEscape(charge.GatewayRef), charge.Status))
+}
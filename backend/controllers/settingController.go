@@ -132,18 +132,21 @@ func GetSettings(c *gin.Context) {
 
 	// Supply default/fallback values from .env if missing from DB
 	envFallbacks := map[string]string{
-		"smtp_host":              os.Getenv("SMTP_HOST"),
-		"smtp_port":              os.Getenv("SMTP_PORT"),
-		"smtp_username":          os.Getenv("SMTP_USER"),
-		"smtp_password":          os.Getenv("SMTP_PASS"),
-		"smtp_from":              os.Getenv("SMTP_FROM"),
-		"biteship_api_key":       os.Getenv("BITESHIP_API_KEY"),
-		"prismalink_merchant_id": os.Getenv("PRISMALINK_MERCHANT_ID"),
-		"prismalink_key_id":      os.Getenv("PRISMALINK_KEY_ID"),
-		"prismalink_secret_key":  os.Getenv("PRISMALINK_SECRET_KEY"),
-		"prismalink_url":         os.Getenv("PRISMALINK_URL"),
-		"store_postal_code":      os.Getenv("STORE_POSTAL_CODE"),
-		"store_address":          os.Getenv("STORE_ADDRESS"),
+		"smtp_host":               os.Getenv("SMTP_HOST"),
+		"smtp_port":               os.Getenv("SMTP_PORT"),
+		"smtp_username":           os.Getenv("SMTP_USER"),
+		"smtp_password":           os.Getenv("SMTP_PASS"),
+		"smtp_from":               os.Getenv("SMTP_FROM"),
+		"biteship_api_key":        os.Getenv("BITESHIP_API_KEY"),
+		"prismalink_merchant_id":  os.Getenv("PRISMALINK_MERCHANT_ID"),
+		"prismalink_key_id":       os.Getenv("PRISMALINK_KEY_ID"),
+		"prismalink_secret_key":   os.Getenv("PRISMALINK_SECRET_KEY"),
+		"prismalink_url":          os.Getenv("PRISMALINK_URL"),
+		"payment_gateway":         os.Getenv("PAYMENT_GATEWAY"),
+		"payment_sandbox_enabled": os.Getenv("PAYMENT_SANDBOX_ENABLED"),
+		"sandbox_gateway_secret":  os.Getenv("SANDBOX_GATEWAY_SECRET"),
+		"store_postal_code":       os.Getenv("STORE_POSTAL_CODE"),
+		"store_address":           os.Getenv("STORE_ADDRESS"),
 	}
 
 	for key, val := range envFallbacks {
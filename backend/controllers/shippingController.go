@@ -1,12 +1,10 @@
 package controllers
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
 
 	"forzashop/backend/config"
 	"forzashop/backend/helpers"
@@ -137,44 +135,8 @@ func GetIntlShippingOptions(c *gin.Context) {
 	var allZones []models.ShippingZone
 	config.DB.Preload("Methods", "is_active = ?", true).Where("is_active = ?", true).Find(&allZones)
 
-	var matchedZone *models.ShippingZone
-
-	// 1. Try to match Postal Code (Specific zip or Range)
-	for i, zone := range allZones {
-		if zone.PostalCodes != "" && isZipMatch(postalCode, zone.PostalCodes) {
-			matchedZone = &allZones[i]
-			break
-		}
-	}
-
-	// 2. Try to match Country
-	if matchedZone == nil {
-		for i, zone := range allZones {
-			if zone.Countries != "" {
-				var countries []string
-				json.Unmarshal([]byte(zone.Countries), &countries)
-				for _, c := range countries {
-					if strings.EqualFold(c, country) {
-						matchedZone = &allZones[i]
-						break
-					}
-				}
-			}
-			if matchedZone != nil {
-				break
-			}
-		}
-	}
-
-	// 3. Fallback to "Rest of World" or generic zone if exists
-	if matchedZone == nil {
-		for i, zone := range allZones {
-			if zone.Countries == "" && zone.PostalCodes == "" {
-				matchedZone = &allZones[i]
-				break
-			}
-		}
-	}
+	// Postal code first, then country, then "Rest of World" catch-all
+	matchedZone := services.MatchShippingZone(allZones, postalCode, country, "")
 
 	if matchedZone == nil {
 		c.JSON(http.StatusOK, []ShippingOptionResponse{})
@@ -254,27 +216,3 @@ func GetIntlShippingOptions(c *gin.Context) {
 
 	c.JSON(http.StatusOK, options)
 }
-
-func isZipMatch(userZip, zoneZips string) bool {
-	// Simple Logic: check if zip code is in comma separated list or range
-	parts := strings.Split(zoneZips, ",")
-	for _, p := range parts {
-		p = strings.TrimSpace(p)
-		if p == userZip {
-			return true
-		}
-		// Check Range e.g. 10001-20000
-		if strings.Contains(p, "-") {
-			rangeParts := strings.Split(p, "-")
-			if len(rangeParts) == 2 {
-				min, _ := strconv.Atoi(strings.TrimSpace(rangeParts[0]))
-				max, _ := strconv.Atoi(strings.TrimSpace(rangeParts[1]))
-				target, _ := strconv.Atoi(userZip)
-				if target >= min && target <= max {
-					return true
-				}
-			}
-		}
-	}
-	return false
-}
@@ -81,7 +81,9 @@ func GetSitemap(c *gin.Context) {
 
 	// 4. Products
 	var products []models.Product
-	config.DB.Where("status = ?", "active").Find(&products)
+	config.DB.Where("status = ?", "active").
+		Preload("Variants", "status = ?", "active").
+		Find(&products)
 	for _, prod := range products {
 		urlSet.URLs = append(urlSet.URLs, XMLURL{
 			Loc:        fmt.Sprintf("%s/product/%d", frontendURL, prod.ID),
@@ -89,6 +91,15 @@ func GetSitemap(c *gin.Context) {
 			ChangeFreq: "weekly",
 			Priority:   0.6,
 		})
+		// Each edition gets its own entry so search engines index deluxe/exclusive versions too
+		for _, variant := range prod.Variants {
+			urlSet.URLs = append(urlSet.URLs, XMLURL{
+				Loc:        fmt.Sprintf("%s/product/%d?variant=%d", frontendURL, prod.ID, variant.ID),
+				LastMod:    variant.UpdatedAt.Format("2006-01-02"),
+				ChangeFreq: "weekly",
+				Priority:   0.5,
+			})
+		}
 	}
 
 	// 5. Blog Posts
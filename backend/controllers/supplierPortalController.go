@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================
+// SUPPLIER PO LINK (public, signed token)
+// ============================================
+
+// supplierPODocument resolves the signed link to its PO document
+func supplierPODocument(c *gin.Context) (*services.PODocument, bool) {
+	token := c.Param("token")
+	claims, err := helpers.ParseSupplierPOToken(token)
+	if err != nil {
+		c.String(http.StatusForbidden, "Link tidak valid atau sudah kedaluwarsa")
+		return nil, false
+	}
+	doc, err := services.NewPurchaseOrderService().Document(claims.POID)
+	if err != nil || doc.Supplier.ID != claims.SupplierID {
+		c.String(http.StatusNotFound, "PO tidak ditemukan")
+		return nil, false
+	}
+	doc.Token = token
+	return doc, true
+}
+
+// GetSupplierPO - The PO page the supplier opens from the email, with the acknowledgement form
+func GetSupplierPO(c *gin.Context) {
+	doc, ok := supplierPODocument(c)
+	if !ok {
+		return
+	}
+	writePODocument(c, doc, true)
+}
+
+// GetSupplierPOPDF - The PO as PDF for the supplier
+func GetSupplierPOPDF(c *gin.Context) {
+	doc, ok := supplierPODocument(c)
+	if !ok {
+		return
+	}
+	writePODocument(c, doc, false)
+}
+
+// AcknowledgeSupplierPO - The supplier confirms the PO, optionally with a delivery date and a note.
+// Accepts the page's form post (redirects back to the page) or JSON.
+func AcknowledgeSupplierPO(c *gin.Context) {
+	token := c.Param("token")
+
+	var input struct {
+		ExpectedDate string `form:"expected_date" json:"expected_date"`
+		Note         string `form:"note" json:"note"`
+	}
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ack := services.AcknowledgeInput{Note: strings.TrimSpace(input.Note)}
+	if input.ExpectedDate != "" {
+		date, err := time.ParseInLocation("2006-01-02", input.ExpectedDate, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expected_date must be YYYY-MM-DD"})
+			return
+		}
+		ack.ExpectedDate = &date
+	}
+
+	po, err := services.NewPurchaseOrderService().Acknowledge(token, ack)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.ContentType() == gin.MIMEJSON {
+		c.JSON(http.StatusOK, gin.H{"message": "PO dikonfirmasi", "po_number": po.PONumber, "expected_date": po.ExpectedDate})
+		return
+	}
+	c.Redirect(http.StatusSeeOther, helpers.GetAppURL()+"/api/supplier/po/"+token)
+}
@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -27,36 +29,56 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
-	// 2. Validate Extension (Images only)
+	// 2. Validate, optimise and store
+	publicURL, err := saveImageUpload(c, fileHeader, "", 85)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if err == errInvalidImageType {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 3. Return Public URL
+	c.JSON(http.StatusOK, gin.H{
+		"message": "File uploaded successfully",
+		"url":     publicURL,
+	})
+}
+
+var errInvalidImageType = errors.New("Invalid file type. Only images allowed (jpg, jpeg, png, webp, gif)")
+
+// saveImageUpload stores an uploaded image under public/uploads/<subDir> and returns its public URL.
+// JPG/PNG are converted to WebP; GIF/WEBP are saved as-is to keep animations.
+func saveImageUpload(c *gin.Context, fileHeader *multipart.FileHeader, subDir string, quality float32) (string, error) {
+	// Validate Extension (Images only)
 	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
 	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".webp" && ext != ".gif" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only images allowed (jpg, jpeg, png, webp, gif)"})
-		return
+		return "", errInvalidImageType
 	}
 
-	// 3. Ensure Upload Directory
-	uploadDir := "./public/uploads"
+	// Ensure Upload Directory
+	uploadDir := filepath.Join("./public/uploads", subDir)
 	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
 		os.MkdirAll(uploadDir, os.ModePerm)
 	}
 
 	var finalFilename string
 
-	// 4. Handle Format Optimization
+	// Handle Format Optimization
 	// For GIF and WEBP, we save directly to preserve animations or avoid re-compressing
 	if ext == ".gif" || ext == ".webp" {
 		finalFilename = fmt.Sprintf("%d-%s", time.Now().UnixNano(), fileHeader.Filename)
 		targetPath := filepath.Join(uploadDir, finalFilename)
 		if err := c.SaveUploadedFile(fileHeader, targetPath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-			return
+			return "", errors.New("Failed to save file")
 		}
 	} else {
 		// Convert JPG/PNG to WebP
 		srcFile, err := fileHeader.Open()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
-			return
+			return "", errors.New("Failed to read uploaded file")
 		}
 		defer srcFile.Close()
 
@@ -74,13 +96,12 @@ func UploadFile(c *gin.Context) {
 
 			out, err := os.Create(targetPath)
 			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webp file"})
-				return
+				return "", errors.New("Failed to create webp file")
 			}
 			defer out.Close()
 
-			// Compress with Quality 85 using helper
-			if err := services.EncodeToWebP(out, img, 85); err != nil {
+			// Compress using helper
+			if err := services.EncodeToWebP(out, img, quality); err != nil {
 				out.Close()
 				os.Remove(targetPath)
 				// Fallback if encode fails
@@ -91,10 +112,8 @@ func UploadFile(c *gin.Context) {
 		}
 	}
 
-	// 5. Return Public URL
-	publicURL := fmt.Sprintf("/uploads/%s", finalFilename)
-	c.JSON(http.StatusOK, gin.H{
-		"message": "File uploaded successfully",
-		"url":     publicURL,
-	})
+	if subDir != "" {
+		return fmt.Sprintf("/uploads/%s/%s", subDir, finalFilename), nil
+	}
+	return fmt.Sprintf("/uploads/%s", finalFilename), nil
 }
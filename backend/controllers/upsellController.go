@@ -8,6 +8,7 @@ import (
 	"forzashop/backend/config"
 	"forzashop/backend/helpers"
 	"forzashop/backend/models"
+	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -91,7 +92,8 @@ func SetUpsells(c *gin.Context) {
 
 // ─── LOW STOCK ALERT ─────────────────────────────────────────────────────────
 
-// GetLowStockProducts returns products that are at or below their min_stock_level (admin only)
+// GetLowStockProducts returns products that are at or below their min_stock_level, plus every
+// location running below its own threshold (?location_id= narrows the per-location list) (admin only)
 func GetLowStockProducts(c *gin.Context) {
 	var products []models.Product
 	if err := config.DB.
@@ -113,9 +115,18 @@ func GetLowStockProducts(c *gin.Context) {
 		}
 	}
 
+	locationID, _ := strconv.Atoi(c.Query("location_id"))
+	locations, err := services.NewLocationService().LowStock(uint(locationID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch low stock locations"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"data":  products,
-		"count": len(products),
+		"data":           products,
+		"count":          len(products),
+		"locations":      locations,
+		"location_count": len(locations),
 	})
 }
 
@@ -125,8 +136,9 @@ func SendLowStockAlertEmail(c *gin.Context) {
 	config.DB.
 		Where("min_stock_level > 0 AND (stock - reserved_qty) <= min_stock_level AND status = 'active'").
 		Find(&products)
+	locations, _ := services.NewLocationService().LowStock(0)
 
-	if len(products) == 0 {
+	if len(products) == 0 && len(locations) == 0 {
 		c.JSON(http.StatusOK, gin.H{"message": "No low-stock products found"})
 		return
 	}
@@ -135,7 +147,7 @@ func SendLowStockAlertEmail(c *gin.Context) {
 	var admins []models.User
 	config.DB.Where("role_id IN (SELECT id FROM roles WHERE slug IN ('super_admin', 'admin'))").Find(&admins)
 
-	subject := fmt.Sprintf("⚠️ Low Stock Alert — %d Product(s) Need Restocking", len(products))
+	subject := fmt.Sprintf("⚠️ Low Stock Alert — %d Item(s) Need Restocking", len(products)+len(locations))
 
 	rows := ""
 	for _, p := range products {
@@ -156,6 +168,42 @@ func SendLowStockAlertEmail(c *gin.Context) {
 		)
 	}
 
+	locationRows := ""
+	for _, l := range locations {
+		name := l.ProductName
+		if l.VariantName != "" {
+			name += " - " + l.VariantName
+		}
+		available := max(l.AvailableStock, 0)
+		locationRows += fmt.Sprintf(`
+			<tr>
+				<td style="padding:8px 12px;border-bottom:1px solid #222;">%s</td>
+				<td style="padding:8px 12px;border-bottom:1px solid #222;color:#aaa;">%s</td>
+				<td style="padding:8px 12px;border-bottom:1px solid #222;text-align:center;color:%s;font-weight:bold;">%d</td>
+				<td style="padding:8px 12px;border-bottom:1px solid #222;text-align:center;color:#888;">%d</td>
+			</tr>`,
+			name, l.LocationName,
+			map[bool]string{true: "#ef4444", false: "#f59e0b"}[available <= 0],
+			available, l.MinStockLevel,
+		)
+	}
+	locationTable := ""
+	if locationRows != "" {
+		locationTable = fmt.Sprintf(`
+		<h3 style="color:#fff;margin:32px 0 12px;">Per Location</h3>
+		<table style="width:100%%;border-collapse:collapse;background:#111;">
+			<thead>
+				<tr style="background:#1a1a1a;">
+					<th style="padding:10px 12px;text-align:left;font-size:11px;text-transform:uppercase;color:#6b7280;">Product</th>
+					<th style="padding:10px 12px;text-align:left;font-size:11px;text-transform:uppercase;color:#6b7280;">Location</th>
+					<th style="padding:10px 12px;text-align:center;font-size:11px;text-transform:uppercase;color:#6b7280;">Available</th>
+					<th style="padding:10px 12px;text-align:center;font-size:11px;text-transform:uppercase;color:#6b7280;">Min Level</th>
+				</tr>
+			</thead>
+			<tbody>%s</tbody>
+		</table>`, locationRows)
+	}
+
 	body := fmt.Sprintf(`
 	<div style="font-family:sans-serif;background:#0a0a0a;padding:32px;color:#fff;max-width:700px;margin:0 auto;">
 		<h2 style="color:#e11d48;margin-bottom:4px;">⚠️ Low Stock Alert</h2>
@@ -170,15 +218,15 @@ func SendLowStockAlertEmail(c *gin.Context) {
 				</tr>
 			</thead>
 			<tbody>%s</tbody>
-		</table>
+		</table>%s
 		<p style="margin-top:24px;font-size:12px;color:#6b7280;">This is an automated alert from Warung Forza inventory system.</p>
-	</div>`, rows)
+	</div>`, rows, locationTable)
 
 	for _, admin := range admins {
 		go helpers.SendEmail(admin.Email, subject, body)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": fmt.Sprintf("Alert email sent to %d admin(s) for %d low-stock product(s)", len(admins), len(products)),
+		"message": fmt.Sprintf("Alert email sent to %d admin(s) for %d low-stock product(s) and %d location shortage(s)", len(admins), len(products), len(locations)),
 	})
 }
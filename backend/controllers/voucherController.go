@@ -63,6 +63,7 @@ func GetVoucher(c *gin.Context) {
 		Preload("Usages.User").
 		Preload("Usages.Order").
 		Preload("ProductRestricts.Product").
+		Preload("ProductRestricts.Variant").
 		Preload("CategoryRestricts.Category").
 		First(&voucher, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Voucher tidak ditemukan"})
@@ -91,8 +92,9 @@ func CreateVoucher(c *gin.Context) {
 		EndDate           *time.Time `json:"end_date"`
 		Status            string     `json:"status"`
 		ProductIDs        []struct {
-			ID   uint   `json:"id"`
-			Type string `json:"type"` // include/exclude
+			ID        uint   `json:"id"`
+			VariantID *uint  `json:"variant_id"` // Optional: restrict to one edition
+			Type      string `json:"type"`       // include/exclude
 		} `json:"product_ids"`
 		CategoryIDs []struct {
 			ID   uint   `json:"id"`
@@ -158,6 +160,7 @@ func CreateVoucher(c *gin.Context) {
 		config.DB.Create(&models.VoucherProduct{
 			VoucherID: voucher.ID,
 			ProductID: p.ID,
+			VariantID: p.VariantID,
 			Type:      p.Type,
 		})
 	}
@@ -217,8 +220,9 @@ func UpdateVoucher(c *gin.Context) {
 		EndDate           *time.Time `json:"end_date"`
 		Status            string     `json:"status"`
 		ProductIDs        []struct {
-			ID   uint   `json:"id"`
-			Type string `json:"type"`
+			ID        uint   `json:"id"`
+			VariantID *uint  `json:"variant_id"`
+			Type      string `json:"type"`
 		} `json:"product_ids"`
 		CategoryIDs []struct {
 			ID   uint   `json:"id"`
@@ -274,6 +278,7 @@ func UpdateVoucher(c *gin.Context) {
 			config.DB.Create(&models.VoucherProduct{
 				VoucherID: voucher.ID,
 				ProductID: p.ID,
+				VariantID: p.VariantID,
 				Type:      p.Type,
 			})
 		}
@@ -405,10 +410,11 @@ func ValidateVoucherForCart(c *gin.Context) {
 	user := c.MustGet("currentUser").(models.User)
 
 	var input struct {
-		Code        string  `json:"code" binding:"required"`
-		CartTotal   float64 `json:"cart_total" binding:"required"`
-		ProductIDs  []uint  `json:"product_ids"`
-		CategoryIDs []uint  `json:"category_ids"`
+		Code        string               `json:"code" binding:"required"`
+		CartTotal   float64              `json:"cart_total" binding:"required"`
+		ProductIDs  []uint               `json:"product_ids"`
+		Items       []models.VoucherLine `json:"items"` // Product + edition per cart line (preferred over product_ids)
+		CategoryIDs []uint               `json:"category_ids"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -417,7 +423,13 @@ func ValidateVoucherForCart(c *gin.Context) {
 	}
 
 	code := strings.ToUpper(strings.TrimSpace(input.Code))
-	result := ValidateVoucher(code, user.ID, input.CartTotal, input.ProductIDs, input.CategoryIDs)
+	lines := input.Items
+	if len(lines) == 0 {
+		for _, pid := range input.ProductIDs {
+			lines = append(lines, models.VoucherLine{ProductID: pid})
+		}
+	}
+	result := ValidateVoucher(code, user.ID, input.CartTotal, lines, input.CategoryIDs)
 
 	if !result.Valid {
 		c.JSON(http.StatusBadRequest, gin.H{"error": result.Message})
@@ -442,7 +454,7 @@ func ValidateVoucherForCart(c *gin.Context) {
 // ============================================
 
 // ValidateVoucher - Central voucher validation logic
-func ValidateVoucher(code string, userID uint, cartTotal float64, productIDs []uint, categoryIDs []uint) models.VoucherValidationResult {
+func ValidateVoucher(code string, userID uint, cartTotal float64, lines []models.VoucherLine, categoryIDs []uint) models.VoucherValidationResult {
 	var voucher models.Voucher
 	if err := config.DB.
 		Preload("ProductRestricts").
@@ -497,22 +509,9 @@ func ValidateVoucher(code string, userID uint, cartTotal float64, productIDs []u
 		return models.VoucherValidationResult{Valid: false, Message: fmt.Sprintf("Maksimum pembelian Rp %.0f untuk menggunakan voucher ini", voucher.MaxSpend)}
 	}
 
-	// 7. Product/Category restrictions
-	if len(voucher.ProductRestricts) > 0 {
-		eligible := false
-		for _, restrict := range voucher.ProductRestricts {
-			if restrict.Type == "include" {
-				for _, pid := range productIDs {
-					if pid == restrict.ProductID {
-						eligible = true
-						break
-					}
-				}
-			}
-		}
-		if !eligible {
-			return models.VoucherValidationResult{Valid: false, Message: "Voucher tidak berlaku untuk produk yang dipilih"}
-		}
+	// 7. Product/Category restrictions (a restriction may target a single edition)
+	if !models.ProductRestrictionsMet(voucher.ProductRestricts, lines) {
+		return models.VoucherValidationResult{Valid: false, Message: "Voucher tidak berlaku untuk produk yang dipilih"}
 	}
 
 	// 8. Calculate discount
@@ -8,6 +8,7 @@ import (
 	"forzashop/backend/config"
 	"forzashop/backend/helpers"
 	"forzashop/backend/models"
+	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -65,20 +66,25 @@ func TopUpWallet(c *gin.Context) {
 		return
 	}
 
-	// Create a "TopUp" Invoice
-	// Format: INV-TOPUP-UID-TIMESTAMP
-	invoiceNumber := fmt.Sprintf("INV-TOPUP-%d-%s", userID, time.Now().Format("20060102150405"))
-
-	invoice := models.Invoice{
-		InvoiceNumber: invoiceNumber,
-		UserID:        userID, // Link directly to user
-		Amount:        input.Amount,
-		Type:          "topup",
-		Status:        "unpaid",
-		DueDate:       time.Now().Add(24 * time.Hour), // 24h expiry
-	}
+	// Create a "TopUp" Invoice (numbered from the shared invoice sequence)
+	var invoice models.Invoice
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		invoiceNumber, err := helpers.NextSequenceNumber(tx, helpers.SeqInvoice)
+		if err != nil {
+			return err
+		}
 
-	if err := config.DB.Create(&invoice).Error; err != nil {
+		invoice = models.Invoice{
+			InvoiceNumber: invoiceNumber,
+			UserID:        userID, // Link directly to user
+			Amount:        input.Amount,
+			Type:          "topup",
+			Status:        "unpaid",
+			DueDate:       time.Now().Add(24 * time.Hour), // 24h expiry
+		}
+		return tx.Create(&invoice).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invoice"})
 		return
 	}
@@ -308,38 +314,9 @@ func PayInvoiceWithWallet(c *gin.Context) {
 
 	// 6. Update Order Logic
 	if invoice.OrderID != nil {
-		var order models.Order
-		if err := tx.Preload("Items.Product").First(&order, *invoice.OrderID).Error; err == nil {
-			if invoice.Type == "deposit" {
-				order.DepositPaid += invoice.Amount
-				order.RemainingBalance -= invoice.Amount
-				order.PaymentStatus = "deposit_paid"
-				order.Status = "pre_order" // Confirmed PO
-			} else if invoice.Type == "balance" {
-				order.PaymentStatus = "paid"
-				order.RemainingBalance -= invoice.Amount
-				order.Status = "processing" // Ready to ship (conceptually)
-			} else {
-				// Full payment
-				order.PaymentStatus = "paid"
-				order.RemainingBalance = 0
-				order.Status = "processing"
-			}
-
-			// Finalize Stock Deduction if moving to processing
-			if order.Status == "processing" {
-				for _, item := range order.Items {
-					tx.Model(&models.Product{}).
-						Where("id = ?", item.ProductID).
-						Updates(map[string]interface{}{
-							"stock":        gorm.Expr("stock - ?", item.Quantity),
-							"reserved_qty": gorm.Expr("GREATEST(reserved_qty - ?, 0)", item.Quantity),
-						})
-				}
-			}
-
-			tx.Save(&order)
-
+		// Per-line settlement: stock moves from reserved to sold only for fully paid lines
+		order, err := services.NewOrderService().SyncPaymentState(tx, *invoice.OrderID)
+		if err == nil {
 			// Order Log
 			tx.Create(&models.OrderLog{
 				OrderID: order.ID,
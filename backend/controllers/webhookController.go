@@ -1,22 +1,23 @@
 package controllers
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
-	"forzashop/backend/config"
+	"forzashop/backend/helpers"
 	"forzashop/backend/models"
+	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
 )
 
-// BiteshipWebhook - Menangani update status dari Biteship secara otomatis
-// Mendukung event: order.status, order.waybill_id, order.price
+// BiteshipWebhook - Menerima update status dari Biteship (order.status, order.waybill_id, order.price).
+// Payload disimpan dulu di webhook inbox, lalu diproses oleh worker.
 func BiteshipWebhook(c *gin.Context) {
-	// 1. Baca isi kiriman dari Biteship
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
@@ -29,128 +30,82 @@ func BiteshipWebhook(c *gin.Context) {
 		return
 	}
 
-	// 2. Parse data JSON secara fleksibel
-	var payload struct {
-		Event     string  `json:"event"`
-		Status    string  `json:"status"`
-		WaybillID string  `json:"waybill_id"`
-		OrderID   string  `json:"order_id"`
-		Price     float64 `json:"price"`
-		Courier   struct {
-			WaybillID string `json:"waybill_id"`
-			Company   string `json:"company"`
-		} `json:"courier"`
-	}
-
-	if err := json.Unmarshal(body, &payload); err != nil {
-		log.Printf("webhook parse error: %v", err)
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	event, duplicate, err := services.NewWebhookService().Receive("biteship", c.Request.Header, body)
+	if err != nil {
+		log.Printf("webhook: biteship event not stored: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store event"})
 		return
 	}
 
-	// Ambil waybill dari root atau dari nested courier
-	finalWaybill := payload.WaybillID
-	if finalWaybill == "" {
-		finalWaybill = payload.Courier.WaybillID
-	}
-
-	log.Printf("webhook received: event=%s waybill=%s status=%s orderID=%s", payload.Event, finalWaybill, payload.Status, payload.OrderID)
-
-	// 3. Cari order berdasarkan waybill ATAU biteship_order_id
-	var order models.Order
-	found := false
+	// Selalu jawab OK ke Biteship
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "event_id": event.ID, "duplicate": duplicate})
+}
 
-	if finalWaybill != "" {
-		if err := config.DB.Where("tracking_number = ?", finalWaybill).First(&order).Error; err == nil {
-			found = true
+// StartWebhookWorker processes the webhook inbox: right after a new event arrives, and every
+// 15 seconds for retries that became due
+func StartWebhookWorker() {
+	ticker := time.NewTicker(15 * time.Second)
+
+	go func() {
+		log.Println("📥 Webhook Inbox Worker Started")
+		svc := services.NewWebhookService()
+		for {
+			select {
+			case <-ticker.C:
+			case <-services.WebhookWakeups():
+			}
+			svc.ProcessDue()
 		}
-	}
-	if !found && payload.OrderID != "" {
-		if err := config.DB.Where("biteship_order_id = ?", payload.OrderID).First(&order).Error; err == nil {
-			found = true
-		}
-	}
-
-	if !found {
-		log.Printf("webhook: no matching order waybill=%s biteship_id=%s", finalWaybill, payload.OrderID)
-		c.JSON(http.StatusOK, gin.H{"status": "ok", "message": "No matching order"})
-		return
-	}
+	}()
+}
 
-	// 4. Handle by Event Type
-	switch payload.Event {
-	case "order.status":
-		handleStatusUpdate(order, payload.Status)
-
-	case "order.waybill_id":
-		// Biteship memberikan nomor resi yang baru/updated
-		if finalWaybill != "" && finalWaybill != order.TrackingNumber {
-			log.Printf("waybill updated: %s -> %s", order.TrackingNumber, finalWaybill)
-			config.DB.Model(&order).Updates(map[string]interface{}{
-				"tracking_number": finalWaybill,
-				"carrier":         payload.Courier.Company,
-			})
-			config.DB.Create(&models.OrderLog{
-				OrderID:           order.ID,
-				Action:            "waybill_updated",
-				Note:              fmt.Sprintf("Nomor resi diperbarui: %s (%s)", finalWaybill, payload.Courier.Company),
-				IsCustomerVisible: true,
-			})
-		}
+// ============================================
+// ADMIN: WEBHOOK INBOX
+// ============================================
 
-	case "order.price":
-		// Biteship memberikan update harga (berat aktual berbeda)
-		if payload.Price > 0 && payload.Price != order.ShippingCost {
-			log.Printf("shipping price updated: %.0f -> %.0f", order.ShippingCost, payload.Price)
-			config.DB.Model(&order).Update("shipping_cost", payload.Price)
-			config.DB.Create(&models.OrderLog{
-				OrderID:           order.ID,
-				Action:            "shipping_price_updated",
-				Note:              fmt.Sprintf("Ongkir diperbarui oleh kurir: Rp %.0f (sebelumnya Rp %.0f)", payload.Price, order.ShippingCost),
-				IsCustomerVisible: false,
-			})
-		}
+// GetWebhookEvents - Admin: list inbox events (?provider=, ?status=, ?search=)
+func GetWebhookEvents(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 
-	default:
-		// Event tidak dikenal, tapi tetap coba update status
-		if payload.Status != "" {
-			handleStatusUpdate(order, payload.Status)
-		}
+	events, total, err := services.NewWebhookService().ListEvents(c.Query("provider"), c.Query("status"), c.Query("search"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook events"})
+		return
 	}
 
-	// 5. Selalu jawab OK ke Biteship
 	c.JSON(http.StatusOK, gin.H{
-		"status":  "ok",
-		"message": "Update processed successfully",
+		"data":  events,
+		"total": total,
+		"page":  page,
+		"limit": limit,
 	})
 }
 
-// handleStatusUpdate - Update status order berdasarkan status dari Biteship
-func handleStatusUpdate(order models.Order, biteshipStatus string) {
-	newStatus := order.Status
-
-	switch biteshipStatus {
-	case "delivered":
-		newStatus = "completed"
-	case "picked", "picking_up", "in_transit", "out_for_delivery", "allocated", "confirmed":
-		newStatus = "shipped"
-	case "rejected", "returned", "lost", "disposed":
-		newStatus = "cancelled"
-	case "courier_not_found":
-		// Kurir tidak ditemukan, order kembali ke processing
-		newStatus = "processing"
+// GetWebhookEvent - Admin: one event with its raw payload and headers
+func GetWebhookEvent(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+
+	event, err := services.NewWebhookService().GetEvent(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
 	}
 
-	if newStatus != order.Status {
-		log.Printf("auto-updating order #%s: %s -> %s (biteship: %s)", order.OrderNumber, order.Status, newStatus, biteshipStatus)
+	c.JSON(http.StatusOK, event)
+}
 
-		config.DB.Model(&order).Update("status", newStatus)
+// ReplayWebhookEvent - Admin re-runs a failed, dead or rejected event
+func ReplayWebhookEvent(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	admin := c.MustGet("currentUser").(models.User)
 
-		config.DB.Create(&models.OrderLog{
-			OrderID:           order.ID,
-			Action:            "webhook_status_update",
-			Note:              fmt.Sprintf("Status otomatis diperbarui: %s → %s (dari kurir: %s)", order.Status, newStatus, biteshipStatus),
-			IsCustomerVisible: true,
-		})
+	event, err := services.NewWebhookService().Replay(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
+
+	helpers.LogAuditSimple(admin.ID, "Webhook", "Replay", event.ID, fmt.Sprintf("Replayed %s event %s: %s", event.Provider, event.EventKey, event.Status))
+	c.JSON(http.StatusOK, event)
 }
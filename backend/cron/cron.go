@@ -3,6 +3,7 @@ package cron
 import (
 	"fmt"
 	"forzashop/backend/services"
+	"time"
 
 	"github.com/robfig/cron/v3"
 )
@@ -32,8 +33,42 @@ func InitCron() {
 		return
 	}
 
+	// Recurring expenses shortly after midnight, once the new day has started
+	_, err = cronJob.AddFunc("15 0 * * *", runRecurringExpenses)
+	if err != nil {
+		fmt.Printf("🔴 [CRON] Failed to register cron tasks: %v\n", err)
+		return
+	}
+	// Catch up on anything missed while the server was down
+	go runRecurringExpenses()
+
+	// Purchase orders past their expected delivery date, at the start of the working day
+	_, err = cronJob.AddFunc("0 8 * * *", runOverduePurchaseOrders)
+	if err != nil {
+		fmt.Printf("🔴 [CRON] Failed to register cron tasks: %v\n", err)
+		return
+	}
+
 	cronJob.Start()
-	fmt.Println("🕰️  [CRON] Daily System Scheduler started successfully (00:00).")
+	fmt.Println("🕰️  [CRON] Daily System Scheduler started successfully (00:00, recurring expenses 00:15, overdue POs 08:00).")
+}
+
+func runRecurringExpenses() {
+	result, err := services.NewRecurringExpenseService().RunDue(time.Now())
+	if err != nil {
+		fmt.Printf("🔴 [CRON] Recurring expenses failed: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ [CRON] Recurring expenses: %d posted, %d already present, %d failed.\n", len(result.Posted), result.Skipped, len(result.Failed))
+}
+
+func runOverduePurchaseOrders() {
+	count, err := services.NewPurchaseOrderService().NotifyOverdue()
+	if err != nil {
+		fmt.Printf("🔴 [CRON] Overdue PO check failed: %v\n", err)
+		return
+	}
+	fmt.Printf("✅ [CRON] Overdue PO check: %d purchase orders past their expected date.\n", count)
 }
 
 func StopCron() {
@@ -13,6 +13,21 @@ import (
 
 // GenerateInvoice creates an invoice record for an order
 func GenerateInvoice(db *gorm.DB, order models.Order, invType string, amount float64, status string) error {
+	return createOrderInvoice(db, order, nil, invType, amount, 0, status)
+}
+
+// GenerateTaxedInvoice creates an order-level invoice whose amount includes taxAmount of PPN
+func GenerateTaxedInvoice(db *gorm.DB, order models.Order, invType string, amount float64, taxAmount float64, status string) error {
+	return createOrderInvoice(db, order, nil, invType, amount, taxAmount, status)
+}
+
+// GenerateLineInvoice creates a deposit/balance invoice scoped to a single order line,
+// so mixed carts can carry a separate payment schedule per PO item
+func GenerateLineInvoice(db *gorm.DB, order models.Order, item models.OrderItem, invType string, amount float64, taxAmount float64, status string) error {
+	return createOrderInvoice(db, order, &item, invType, amount, taxAmount, status)
+}
+
+func createOrderInvoice(db *gorm.DB, order models.Order, item *models.OrderItem, invType string, amount float64, taxAmount float64, status string) error {
 	var dueDate time.Time
 	if invType == "balance" {
 		dueDate = time.Now().Add(24 * time.Hour)
@@ -20,15 +35,24 @@ func GenerateInvoice(db *gorm.DB, order models.Order, invType string, amount flo
 		dueDate = time.Now().Add(30 * time.Minute)
 	}
 
+	invoiceNumber, err := NextSequenceNumber(db, SeqInvoice)
+	if err != nil {
+		return err
+	}
+
 	invoice := models.Invoice{
-		InvoiceNumber: fmt.Sprintf("INV-%d-%s-%d", order.ID, invType, time.Now().Unix()),
+		InvoiceNumber: invoiceNumber,
 		OrderID:       &order.ID,
 		UserID:        order.UserID,
 		Type:          invType, // 'deposit', 'balance', 'full'
 		Amount:        amount,
+		TaxAmount:     taxAmount,
 		Status:        status, // 'unpaid', 'pending_arrival', etc.
 		DueDate:       dueDate,
 	}
+	if item != nil {
+		invoice.OrderItemID = &item.ID
+	}
 
 	if err := db.Create(&invoice).Error; err != nil {
 		return err
@@ -36,6 +60,34 @@ func GenerateInvoice(db *gorm.DB, order models.Order, invType string, amount flo
 	return nil
 }
 
+// GetPayableCOA returns Hutang Usaha: the ACCOUNTS_PAYABLE mapping, falling back to the legacy code 2001
+func GetPayableCOA() (uint, error) {
+	if id, err := GetCOAByMappingKey("ACCOUNTS_PAYABLE"); err == nil {
+		return id, nil
+	}
+	return GetCOAByCode("2001")
+}
+
+// GetInventoryCOA returns Persediaan Barang: the INVENTORY_ASSET mapping, falling back to code 1003
+func GetInventoryCOA() (uint, error) {
+	if id, err := GetCOAByMappingKey("INVENTORY_ASSET"); err == nil {
+		return id, nil
+	}
+	return GetCOAByCode("1003")
+}
+
+// GetShrinkageCOA returns the account stock-take variances are booked on: the INVENTORY_SHRINKAGE
+// mapping, code 6009, or HPP when neither exists
+func GetShrinkageCOA() (uint, error) {
+	if id, err := GetCOAByMappingKey("INVENTORY_SHRINKAGE"); err == nil {
+		return id, nil
+	}
+	if id, err := GetCOAByCode("6009"); err == nil {
+		return id, nil
+	}
+	return GetCOAByMappingKey("COGS_EXPENSE")
+}
+
 // GetPrimaryBankCOA retrieves the primary bank account ID with smart fallbacks
 func GetPrimaryBankCOA(tx *gorm.DB) (uint, error) {
 	// 1. Try Mapping Key
@@ -70,9 +122,30 @@ func GetPrimaryBankCOA(tx *gorm.DB) (uint, error) {
 	return 0, fmt.Errorf("no primary bank or cash asset account found")
 }
 
+// GetTaxPayableCOA returns the PPN payable (output tax) account, 0 if none is configured
+func GetTaxPayableCOA(tx *gorm.DB) uint {
+	if id, err := GetCOAByMappingKey("TAX_PAYABLE"); err == nil {
+		return id
+	}
+	var coa models.COA
+	if err := tx.Where("type = ? AND can_post = ? AND (name ILIKE ? OR name ILIKE ?)", "LIABILITY", true, "%ppn%", "%pajak%").First(&coa).Error; err == nil {
+		return coa.ID
+	}
+	return 0
+}
+
 // CreateAutoJournal records financial transaction
 func CreateAutoJournal(referenceID string, refType string, description string, items []models.JournalItem) error {
+	if err := CheckPostingPeriod(config.DB, time.Now(), false); err != nil {
+		return err
+	}
+	entryNumber, err := NextJournalNumber()
+	if err != nil {
+		return err
+	}
+
 	entry := models.JournalEntry{
+		EntryNumber:   entryNumber,
 		Date:          time.Now(),
 		Description:   description,
 		ReferenceID:   referenceID,
@@ -122,8 +195,17 @@ func PostJournalEntry(tx *gorm.DB, orderID uint, desc string, category string, a
 	if debitID == 0 || creditID == 0 {
 		return fmt.Errorf("COA not found for %s or %s", debitName, creditName)
 	}
+	if err := CheckPostingPeriod(tx, time.Now(), false); err != nil {
+		return err
+	}
+
+	entryNumber, err := NextJournalNumber()
+	if err != nil {
+		return err
+	}
 
 	entry := models.JournalEntry{
+		EntryNumber:   entryNumber,
 		Date:          time.Now(),
 		Description:   desc,
 		ReferenceID:   fmt.Sprintf("ORDER-%d", orderID),
@@ -156,7 +238,30 @@ func RecordPaymentJournal(tx *gorm.DB, invoice *models.Invoice, gatewayTxID stri
 			return err
 		}
 	}
+	return recordPaymentJournal(tx, invoice, gatewayTxID, debitCOAID, 0)
+}
+
+// RecordTransferPaymentJournal records a bank transfer payment. The invoice's unique code arrives on top of
+// the invoice amount and is credited to OTHER_INCOME as a rounding line.
+func RecordTransferPaymentJournal(tx *gorm.DB, invoice *models.Invoice, ref string) error {
+	debitCOAID, err := GetPrimaryBankCOA(tx)
+	if err != nil {
+		return err
+	}
+	return recordPaymentJournal(tx, invoice, ref, debitCOAID, float64(invoice.UniqueCode))
+}
+
+// RecordGatewayPaymentJournal books a gateway payment on GATEWAY_CLEARING (money the gateway still owes us)
+// when that account is mapped. Settlement reconciliation later moves it to the bank, net of the gateway fee.
+func RecordGatewayPaymentJournal(tx *gorm.DB, invoice *models.Invoice, gatewayTxID string) error {
+	clearingID, _ := GetCOAByMappingKey("GATEWAY_CLEARING")
+	if clearingID == 0 {
+		return RecordPaymentJournal(tx, invoice, gatewayTxID)
+	}
+	return recordPaymentJournal(tx, invoice, gatewayTxID, clearingID, 0)
+}
 
+func recordPaymentJournal(tx *gorm.DB, invoice *models.Invoice, gatewayTxID string, debitCOAID uint, uniqueCode float64) error {
 	// 2. Determine Credit Account (Revenue or Liability)
 	var mappingKey string
 	switch invoice.Type {
@@ -187,6 +292,9 @@ func RecordPaymentJournal(tx *gorm.DB, invoice *models.Invoice, gatewayTxID stri
 	if creditCOAID == 0 || debitCOAID == 0 {
 		return fmt.Errorf("failed to map accounts for journal: debit=%d, credit=%d", debitCOAID, creditCOAID)
 	}
+	if err := CheckPostingPeriod(tx, time.Now(), false); err != nil {
+		return err
+	}
 
 	// 3. Create Journal Entry
 	desc := fmt.Sprintf("Payment for %s (%s)", invoice.InvoiceNumber, invoice.Type)
@@ -195,7 +303,13 @@ func RecordPaymentJournal(tx *gorm.DB, invoice *models.Invoice, gatewayTxID stri
 		refID = invoice.InvoiceNumber
 	}
 
+	entryNumber, err := NextJournalNumber()
+	if err != nil {
+		return err
+	}
+
 	entry := models.JournalEntry{
+		EntryNumber:   entryNumber,
 		Date:          time.Now(),
 		Description:   desc,
 		ReferenceID:   refID,
@@ -207,11 +321,35 @@ func RecordPaymentJournal(tx *gorm.DB, invoice *models.Invoice, gatewayTxID stri
 	}
 
 	// 4. Create Journal Items
+	// PPN collected with the payment is owed to the state, not earned: split it off to TAX_PAYABLE
+	netAmount := invoice.Amount
+	var taxCOAID uint
+	if invoice.TaxAmount > 0 && invoice.Type != "topup" {
+		taxCOAID = GetTaxPayableCOA(tx)
+		if taxCOAID != 0 {
+			netAmount = invoice.Amount - invoice.TaxAmount
+		} else {
+			fmt.Printf("⚠️ TAX_PAYABLE account not mapped, PPN for %s booked as %s\n", invoice.InvoiceNumber, mappingKey)
+		}
+	}
+
 	items := []models.JournalItem{
 		// Debit (Bank Increase)
-		{JournalEntryID: entry.ID, COAID: debitCOAID, Debit: invoice.Amount, Credit: 0},
+		{JournalEntryID: entry.ID, COAID: debitCOAID, Debit: invoice.Amount + uniqueCode, Credit: 0},
 		// Credit (Revenue Increase)
-		{JournalEntryID: entry.ID, COAID: creditCOAID, Debit: 0, Credit: invoice.Amount},
+		{JournalEntryID: entry.ID, COAID: creditCOAID, Debit: 0, Credit: netAmount},
+	}
+	if taxCOAID != 0 {
+		// Credit (Output Tax Liability)
+		items = append(items, models.JournalItem{JournalEntryID: entry.ID, COAID: taxCOAID, Debit: 0, Credit: invoice.TaxAmount})
+	}
+	if uniqueCode > 0 {
+		// Credit (Transfer unique code, kept as other income; revenue account when not mapped)
+		codeCOAID, _ := GetCOAByMappingKey("OTHER_INCOME")
+		if codeCOAID == 0 {
+			codeCOAID = creditCOAID
+		}
+		items = append(items, models.JournalItem{JournalEntryID: entry.ID, COAID: codeCOAID, Debit: 0, Credit: uniqueCode})
 	}
 
 	for _, item := range items {
@@ -242,41 +380,14 @@ func RecordPaymentJournal(tx *gorm.DB, invoice *models.Invoice, gatewayTxID stri
 	return nil
 }
 
-// PostJournalWithTX records a multi-item journal entry and updates COA balances within a TX
+// PostJournalWithTX records a multi-item journal entry dated now and updates COA balances within a TX
 func PostJournalWithTX(tx *gorm.DB, referenceID string, refType string, description string, items []models.JournalItem) error {
-	entry := models.JournalEntry{
+	_, err := PostJournal(tx, JournalPosting{
 		Date:          time.Now(),
-		Description:   description,
 		ReferenceID:   referenceID,
 		ReferenceType: refType,
-	}
-
-	if err := tx.Create(&entry).Error; err != nil {
-		return err
-	}
-
-	for i := range items {
-		items[i].JournalEntryID = entry.ID
-		if err := tx.Create(&items[i]).Error; err != nil {
-			return err
-		}
-
-		// Update COA Balance
-		var coa models.COA
-		if err := tx.First(&coa, items[i].COAID).Error; err != nil {
-			return err
-		}
-
-		if coa.Type == "ASSET" || coa.Type == "EXPENSE" || coa.Type == "COGS" {
-			coa.Balance += items[i].Debit - items[i].Credit
-		} else {
-			coa.Balance += items[i].Credit - items[i].Debit
-		}
-
-		if err := tx.Save(&coa).Error; err != nil {
-			return err
-		}
-	}
-
-	return nil
+		Description:   description,
+		Items:         items,
+	})
+	return err
 }
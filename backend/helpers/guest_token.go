@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Guest token purposes. Guest tokens never carry a user_id claim, so AuthMiddleware rejects them.
+const (
+	GuestTokenCheckout    = "guest_checkout" // Verified email, allowed to quote shipping and check out
+	GuestTokenOrderAccess = "order_access"   // Read access to a single guest order (tracking, invoices)
+	GuestTokenClaim       = "account_claim"  // Emailed only: turn the guest account into a regular one
+)
+
+// GuestClaims is the payload of a guest checkout / order access token
+type GuestClaims struct {
+	Purpose string `json:"purpose"`
+	Email   string `json:"email"`
+	OrderID uint   `json:"order_id,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// SignGuestToken issues a token for a verified guest email, scoped to one purpose (and order)
+func SignGuestToken(purpose, email string, orderID uint, ttl time.Duration) (string, error) {
+	claims := GuestClaims{
+		Purpose: purpose,
+		Email:   email,
+		OrderID: orderID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// ParseGuestToken validates a guest token and returns its claims when it matches one of the purposes
+func ParseGuestToken(tokenString string, purposes ...string) (*GuestClaims, error) {
+	claims := &GuestClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid || claims.Email == "" {
+		return nil, fmt.Errorf("invalid or expired guest token")
+	}
+	for _, p := range purposes {
+		if claims.Purpose == p {
+			return claims, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid or expired guest token")
+}
+
+// GuestOrderTokenTTL is how long a guest can track an order with its access link (setting in days)
+func GuestOrderTokenTTL() time.Duration {
+	days, err := strconv.Atoi(GetSetting("guest_order_token_days", "90"))
+	if err != nil || days <= 0 {
+		days = 90
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
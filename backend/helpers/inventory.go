@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"fmt"
 	"forzashop/backend/models"
 	"time"
 
@@ -9,6 +10,29 @@ import (
 
 // RecordStockMovement logs a change in stock levels for auditing and alerts
 func RecordStockMovement(tx *gorm.DB, productID uint, qty int, stockType string, movementType string, refType string, refID string, note string, userID *uint) error {
+	return RecordVariantStockMovement(tx, productID, nil, qty, stockType, movementType, refType, refID, note, userID)
+}
+
+// RecordVariantStockMovement logs a stock change against a product edition (variantID nil = the product itself)
+func RecordVariantStockMovement(tx *gorm.DB, productID uint, variantID *uint, qty int, stockType string, movementType string, refType string, refID string, note string, userID *uint) error {
+	return recordStockMovement(tx, models.StockMovement{
+		ProductID:     productID,
+		VariantID:     variantID,
+		Quantity:      qty,
+		StockType:     stockType,
+		MovementType:  movementType,
+		ReferenceType: refType,
+		ReferenceID:   refID,
+		Note:          note,
+		PerformedBy:   userID,
+	})
+}
+
+// recordStockMovement fills in the balances and writes the log row. Transfers move stock between
+// locations without changing the product total, so their balance stays put.
+func recordStockMovement(tx *gorm.DB, movement models.StockMovement) error {
+	productID, variantID, stockType := movement.ProductID, movement.VariantID, movement.StockType
+
 	// 1. Get current balance
 	var product models.Product
 	if err := tx.First(&product, productID).Error; err != nil {
@@ -20,34 +44,41 @@ func RecordStockMovement(tx *gorm.DB, productID uint, qty int, stockType string,
 		balanceBefore = product.ReservedQty
 	}
 
-	balanceAfter := balanceBefore + qty
+	productName := product.Name
+	if variantID != nil {
+		var variant models.ProductVariant
+		if err := tx.First(&variant, *variantID).Error; err != nil {
+			return err
+		}
+		balanceBefore = variant.Stock
+		if stockType == "reserved" {
+			balanceBefore = variant.ReservedQty
+		}
+		productName = fmt.Sprintf("%s (%s)", product.Name, variant.Name)
+	}
 
-	// 2. Create Movement Log
-	movement := models.StockMovement{
-		ProductID:     productID,
-		Quantity:      qty,
-		StockType:     stockType,
-		MovementType:  movementType,
-		ReferenceType: refType,
-		ReferenceID:   refID,
-		Note:          note,
-		PerformedBy:   userID,
-		BalanceBefore: balanceBefore,
-		BalanceAfter:  balanceAfter,
-		CreatedAt:     time.Now(),
+	balanceAfter := balanceBefore + movement.Quantity
+	if stockType == "transfer" {
+		balanceAfter = balanceBefore
 	}
 
+	// 2. Create Movement Log
+	movement.BalanceBefore = balanceBefore
+	movement.BalanceAfter = balanceAfter
+	movement.CreatedAt = time.Now()
+
 	if err := tx.Create(&movement).Error; err != nil {
 		return err
 	}
 
 	// 3. Check for Stock Alerts (Physical Only)
-	if stockType == "physical" && movementType != "procurement" {
+	if stockType == "physical" && movement.MovementType != "procurement" {
 		if balanceAfter <= product.MinStockLevel && product.MinStockLevel > 0 {
 			// Trigger Alert Notification
 			NotifyAdmin("STOCK_CRITICAL", "Critical Stock Level Detected", map[string]interface{}{
 				"product_id":      product.ID,
-				"product_name":    product.Name,
+				"variant_id":      variantID,
+				"product_name":    productName,
 				"current_stock":   balanceAfter,
 				"min_stock_level": product.MinStockLevel,
 			})
@@ -56,3 +87,62 @@ func RecordStockMovement(tx *gorm.DB, productID uint, qty int, stockType string,
 
 	return nil
 }
+
+// StockRow scopes a query to the row that holds the stock for a line:
+// the variant when an edition is selected, otherwise the product itself
+func StockRow(tx *gorm.DB, productID uint, variantID *uint) *gorm.DB {
+	if variantID != nil {
+		return tx.Model(&models.ProductVariant{}).Where("id = ? AND product_id = ?", *variantID, productID)
+	}
+	return tx.Model(&models.Product{}).Where("id = ?", productID)
+}
+
+// ReserveStock atomically holds qty against (stock - reserved_qty).
+// Returns false when there is not enough available stock (or PO slots) left.
+func ReserveStock(tx *gorm.DB, productID uint, variantID *uint, qty int) (bool, error) {
+	res := StockRow(tx, productID, variantID).
+		Where("(stock - reserved_qty) >= ?", qty).
+		Update("reserved_qty", gorm.Expr("reserved_qty + ?", qty))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, SyncVariantTotals(tx, productID, variantID)
+}
+
+// DeductStock atomically takes qty off physical stock that is not held by anyone else.
+// Returns false when there is not enough available stock.
+func DeductStock(tx *gorm.DB, productID uint, variantID *uint, qty int) (bool, error) {
+	res := StockRow(tx, productID, variantID).
+		Where("(stock - reserved_qty) >= ?", qty).
+		Update("stock", gorm.Expr("stock - ?", qty))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, SyncVariantTotals(tx, productID, variantID)
+}
+
+// AdjustStock applies stock/reserved_qty updates (usually gorm.Expr) to the line's stock row
+func AdjustStock(tx *gorm.DB, productID uint, variantID *uint, updates map[string]interface{}) error {
+	if err := StockRow(tx, productID, variantID).Updates(updates).Error; err != nil {
+		return err
+	}
+	return SyncVariantTotals(tx, productID, variantID)
+}
+
+// SyncVariantTotals rolls variant stock up into the parent product so listings, low-stock
+// filters and reports that only look at products keep working. No-op for plain products.
+func SyncVariantTotals(tx *gorm.DB, productID uint, variantID *uint) error {
+	if variantID == nil {
+		return nil
+	}
+	return tx.Exec(`UPDATE products SET
+		stock = COALESCE((SELECT SUM(stock) FROM product_variants WHERE product_id = ? AND deleted_at IS NULL), 0),
+		reserved_qty = COALESCE((SELECT SUM(reserved_qty) FROM product_variants WHERE product_id = ? AND deleted_at IS NULL), 0)
+		WHERE id = ?`, productID, productID, productID).Error
+}
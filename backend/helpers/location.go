@@ -0,0 +1,217 @@
+package helpers
+
+import (
+	"errors"
+	"time"
+
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLocationStock is returned when a location can't serve a quantity from its own shelf
+var ErrLocationStock = errors.New("insufficient stock at location")
+
+// DefaultLocationID returns the location that receives goods, returns and manual adjustments.
+// A "MAIN" location is created on first use so stock always has somewhere to live.
+func DefaultLocationID(tx *gorm.DB) (uint, error) {
+	var location models.Location
+	if err := tx.Where("is_active = ?", true).Order("is_default DESC, priority ASC, id ASC").Limit(1).Find(&location).Error; err != nil {
+		return 0, err
+	}
+	if location.ID != 0 {
+		return location.ID, nil
+	}
+
+	location = models.Location{Code: "MAIN", Name: "Gudang Utama", Type: models.LocationWarehouse, Priority: 1, SellOnline: true, IsDefault: true, IsActive: true}
+	if err := tx.Where(models.Location{Code: location.Code}).FirstOrCreate(&location).Error; err != nil {
+		return 0, err
+	}
+	return location.ID, nil
+}
+
+// ResolveLocationID returns locationID, or the default location when it is 0
+func ResolveLocationID(tx *gorm.DB, locationID uint) (uint, error) {
+	if locationID != 0 {
+		return locationID, nil
+	}
+	return DefaultLocationID(tx)
+}
+
+// LocationVariantKey maps a line's edition to the StockLevel key (0 = the product itself)
+func LocationVariantKey(variantID *uint) uint {
+	if variantID == nil {
+		return 0
+	}
+	return *variantID
+}
+
+// AdjustLocationStock moves a location's stock and reserved_qty by the given deltas, creating the
+// level row on first use. Callers keep the product/edition totals in step themselves.
+func AdjustLocationStock(tx *gorm.DB, locationID, productID uint, variantID *uint, stockDelta, reservedDelta int) error {
+	level := models.StockLevel{
+		LocationID:  locationID,
+		ProductID:   productID,
+		VariantID:   LocationVariantKey(variantID),
+		Stock:       stockDelta,
+		ReservedQty: max(reservedDelta, 0),
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "location_id"}, {Name: "product_id"}, {Name: "variant_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"stock":        gorm.Expr("stock_levels.stock + ?", stockDelta),
+			"reserved_qty": gorm.Expr("GREATEST(stock_levels.reserved_qty + ?, 0)", reservedDelta),
+			"updated_at":   time.Now(),
+		}),
+	}).Create(&level).Error
+}
+
+// LockLocationStock returns a location's level row, locked until the transaction ends.
+// The zero value (ID 0) means the location never held the item.
+func LockLocationStock(tx *gorm.DB, locationID, productID uint, variantID *uint) (models.StockLevel, error) {
+	var level models.StockLevel
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("location_id = ? AND product_id = ? AND variant_id = ?", locationID, productID, LocationVariantKey(variantID)).
+		Limit(1).Find(&level).Error
+	return level, err
+}
+
+// LocationAllocation is the part of a hold served by one location
+type LocationAllocation struct {
+	LocationID uint
+	Quantity   int
+}
+
+// AllocateStock picks the locations web checkout reserves qty from, walking the online locations in
+// priority order. The first location that can serve the whole quantity wins (one parcel); when none
+// can and split is set, each location gives what it has. Returns nil when the locations can't cover qty.
+func AllocateStock(tx *gorm.DB, productID uint, variantID *uint, qty int, split bool) ([]LocationAllocation, error) {
+	var levels []models.StockLevel
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Table: clause.Table{Name: "stock_levels"}}).
+		Select("stock_levels.*").
+		Joins("JOIN locations ON locations.id = stock_levels.location_id").
+		Where("stock_levels.product_id = ? AND stock_levels.variant_id = ?", productID, LocationVariantKey(variantID)).
+		Where("locations.is_active = ? AND locations.sell_online = ?", true, true).
+		Where("stock_levels.stock - stock_levels.reserved_qty > 0").
+		Order("locations.priority ASC, locations.id ASC").
+		Find(&levels).Error; err != nil {
+		return nil, err
+	}
+	return allocateFromLevels(levels, qty, split), nil
+}
+
+// allocateFromLevels applies AllocateStock's rules to level rows already in priority order
+func allocateFromLevels(levels []models.StockLevel, qty int, split bool) []LocationAllocation {
+	for _, level := range levels {
+		if level.Stock-level.ReservedQty >= qty {
+			return []LocationAllocation{{LocationID: level.LocationID, Quantity: qty}}
+		}
+	}
+	if !split {
+		return nil
+	}
+
+	var allocations []LocationAllocation
+	remaining := qty
+	for _, level := range levels {
+		take := min(level.Stock-level.ReservedQty, remaining)
+		allocations = append(allocations, LocationAllocation{LocationID: level.LocationID, Quantity: take})
+		if remaining -= take; remaining == 0 {
+			return allocations
+		}
+	}
+	return nil
+}
+
+// RecordLocationStockMovement applies a physical or reserved stock change to a location's level and
+// logs it like RecordVariantStockMovement, with the location as source (qty < 0) or destination.
+// Location 0 is the default location. Like the plain variant, call it before changing the totals.
+func RecordLocationStockMovement(tx *gorm.DB, locationID, productID uint, variantID *uint, qty int, stockType string, movementType string, refType string, refID string, note string, userID *uint) error {
+	locationID, err := ResolveLocationID(tx, locationID)
+	if err != nil {
+		return err
+	}
+
+	switch stockType {
+	case "physical":
+		err = AdjustLocationStock(tx, locationID, productID, variantID, qty, 0)
+	case "reserved":
+		err = AdjustLocationStock(tx, locationID, productID, variantID, 0, qty)
+	}
+	if err != nil {
+		return err
+	}
+
+	movement := models.StockMovement{
+		ProductID:     productID,
+		VariantID:     variantID,
+		Quantity:      qty,
+		StockType:     stockType,
+		MovementType:  movementType,
+		ReferenceType: refType,
+		ReferenceID:   refID,
+		Note:          note,
+		PerformedBy:   userID,
+	}
+	if qty < 0 {
+		movement.FromLocationID = &locationID
+	} else {
+		movement.ToLocationID = &locationID
+	}
+	if err := recordStockMovement(tx, movement); err != nil {
+		return err
+	}
+
+	if stockType == "physical" && qty < 0 {
+		checkLocationStock(tx, locationID, productID, variantID)
+	}
+	return nil
+}
+
+// RecordTransferMovement logs units leaving one location for another. The product total is unchanged.
+func RecordTransferMovement(tx *gorm.DB, fromID, toID *uint, productID uint, variantID *uint, qty int, refID string, note string, userID *uint) error {
+	return recordStockMovement(tx, models.StockMovement{
+		ProductID:      productID,
+		VariantID:      variantID,
+		Quantity:       qty,
+		FromLocationID: fromID,
+		ToLocationID:   toID,
+		StockType:      "transfer",
+		MovementType:   "transfer",
+		ReferenceType:  "TRANSFER",
+		ReferenceID:    refID,
+		Note:           note,
+		PerformedBy:    userID,
+	})
+}
+
+// checkLocationStock notifies admins when a location drops to its own min_stock_level
+func checkLocationStock(tx *gorm.DB, locationID, productID uint, variantID *uint) {
+	var row struct {
+		Stock         int
+		ReservedQty   int
+		MinStockLevel int
+		LocationName  string
+		ProductName   string
+	}
+	tx.Table("stock_levels").
+		Select("stock_levels.stock, stock_levels.reserved_qty, stock_levels.min_stock_level, locations.name AS location_name, products.name AS product_name").
+		Joins("JOIN locations ON locations.id = stock_levels.location_id").
+		Joins("JOIN products ON products.id = stock_levels.product_id").
+		Where("stock_levels.location_id = ? AND stock_levels.product_id = ? AND stock_levels.variant_id = ?", locationID, productID, LocationVariantKey(variantID)).
+		Limit(1).Scan(&row)
+
+	available := row.Stock - row.ReservedQty
+	if row.MinStockLevel > 0 && available <= row.MinStockLevel {
+		NotifyAdmin("STOCK_CRITICAL", "Critical Stock Level at "+row.LocationName, map[string]interface{}{
+			"product_id":      productID,
+			"variant_id":      variantID,
+			"product_name":    row.ProductName,
+			"location_id":     locationID,
+			"location_name":   row.LocationName,
+			"current_stock":   available,
+			"min_stock_level": row.MinStockLevel,
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+
+	"forzashop/backend/models"
+)
+
+func TestAllocateFromLevels(t *testing.T) {
+	levels := []models.StockLevel{
+		{LocationID: 1, Stock: 5, ReservedQty: 3},  // 2 free
+		{LocationID: 2, Stock: 4},                  // 4 free
+		{LocationID: 3, Stock: 10, ReservedQty: 9}, // 1 free
+	}
+
+	tests := []struct {
+		name  string
+		qty   int
+		split bool
+		want  []LocationAllocation
+	}{
+		{"first location covers it", 2, false, []LocationAllocation{{LocationID: 1, Quantity: 2}}},
+		{"one parcel beats priority", 3, false, []LocationAllocation{{LocationID: 2, Quantity: 3}}},
+		{"one parcel preferred even when splitting", 4, true, []LocationAllocation{{LocationID: 2, Quantity: 4}}},
+		{"no single location, no split", 6, false, nil},
+		{"split in priority order", 6, true, []LocationAllocation{{LocationID: 1, Quantity: 2}, {LocationID: 2, Quantity: 4}}},
+		{"split uses every location", 7, true, []LocationAllocation{{LocationID: 1, Quantity: 2}, {LocationID: 2, Quantity: 4}, {LocationID: 3, Quantity: 1}}},
+		{"not enough stock", 8, true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := allocateFromLevels(levels, tt.qty, tt.split)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("allocateFromLevels(%d, %v) = %+v, want %+v", tt.qty, tt.split, got, tt.want)
+			}
+		})
+	}
+}
@@ -458,10 +458,7 @@ func GeneratePaymentDirect(order models.Order, invoice models.Invoice, user mode
 	validity := time.Now().Add(24 * time.Hour).Format("2006-01-02 15:04:05.000 -0700")
 
 	// Generate Unique Merchant Ref No
-	uniqueRefNo := fmt.Sprintf("%s-%s", invoice.InvoiceNumber, time.Now().Format("150405"))
-	if len(uniqueRefNo) > 25 {
-		uniqueRefNo = invoice.InvoiceNumber[len(invoice.InvoiceNumber)-6:] + "-" + time.Now().Format("150405")
-	}
+	uniqueRefNo := NewMerchantRefNo(invoice.InvoiceNumber)
 
 	// Create Payment Transaction Record
 	if err := config.DB.Create(&models.PaymentTransaction{
@@ -743,15 +740,8 @@ func GeneratePaymentDirect(order models.Order, invoice models.Invoice, user mode
 	return paymentData, nil
 }
 
-// GeneratePaymentLink remains as a backward-compatible wrapper
-// Returns the payment URL string for legacy callers
-func GeneratePaymentLink(order models.Order, invoice models.Invoice, user models.User, clientIP string) (string, error) {
-	paymentData, err := GeneratePaymentDirect(order, invoice, user, clientIP)
-	if err != nil {
-		return "", err
-	}
-
-	// For backward compat: return a URL string
+// PaymentLinkFromData flattens structured payment data into the URL string legacy callers expect
+func PaymentLinkFromData(paymentData *PaymentData) (string, error) {
 	switch paymentData.Type {
 	case "qris":
 		if paymentData.QRISImageURL != "" {
@@ -773,6 +763,18 @@ func GeneratePaymentLink(order models.Order, invoice models.Invoice, user models
 }
 
 // formatPhoneNumber formats phone to international +62 format
+// NewMerchantRefNo builds the unique per-attempt reference sent to gateways (max 25 chars). It also
+// ends up in URLs (sandbox charge pages), so the "/" of configurable invoice numbers becomes "-".
+func NewMerchantRefNo(invoiceNumber string) string {
+	base := strings.ReplaceAll(invoiceNumber, "/", "-")
+	stamp := time.Now().Format("150405")
+	ref := base + "-" + stamp
+	if len(ref) > 25 {
+		ref = base[max(len(base)-6, 0):] + "-" + stamp
+	}
+	return ref
+}
+
 func formatPhoneNumber(phone string) string {
 	if strings.HasPrefix(phone, "+") {
 		return phone
@@ -789,7 +791,7 @@ func formatPhoneNumber(phone string) string {
 	return phone
 }
 
-// GenerateSimulationPaymentURL is now only for explicit internal testing if called manually
+// GenerateSimulationPaymentURL opens the frontend simulator, which pays the invoice's sandbox gateway charge
 func GenerateSimulationPaymentURL(order models.Order, invoice models.Invoice) string {
 	// Use the frontend simulator route
 	return fmt.Sprintf(
@@ -912,7 +914,7 @@ func GetPrismalinkVA(order models.Order, invoice models.Invoice, user models.Use
 		})
 	}
 
-	cleanRef := strings.ReplaceAll(invoice.InvoiceNumber, "-", "")
+	cleanRef := strings.NewReplacer("-", "", "/", "").Replace(invoice.InvoiceNumber)
 	if len(cleanRef) > 24 {
 		cleanRef = cleanRef[:24]
 	}
@@ -0,0 +1,34 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewMerchantRefNo(t *testing.T) {
+	tests := []struct {
+		name          string
+		invoiceNumber string
+		wantPrefix    string
+	}{
+		{"dash separated", "INV-2026-10-00042", "INV-2026-10-00042-"},
+		{"slash separated", "INV/2026/10/00042", "INV-2026-10-00042-"},
+		{"too long keeps the tail", "INVOICE/2026/10/0000000042", "000042-"},
+		{"short number", "42", "42-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := NewMerchantRefNo(tt.invoiceNumber)
+			if strings.Contains(ref, "/") {
+				t.Errorf("ref %q contains a slash", ref)
+			}
+			if len(ref) > 25 {
+				t.Errorf("ref %q is longer than 25 chars", ref)
+			}
+			if !strings.HasPrefix(ref, tt.wantPrefix) {
+				t.Errorf("ref %q, want prefix %q", ref, tt.wantPrefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrPeriodClosed is returned when a journal would land in a closed or locked accounting period
+var ErrPeriodClosed = errors.New("accounting period is closed")
+
+// JournalTypeClosing marks year-end closing entries; P&L reports leave them out
+const JournalTypeClosing = "CLOSING"
+
+// PeriodFor returns the accounting period containing date, nil when none is defined
+func PeriodFor(tx *gorm.DB, date time.Time) *models.AccountingPeriod {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	var period models.AccountingPeriod
+	if err := tx.Where("start_date <= ? AND end_date >= ?", day, day).First(&period).Error; err != nil {
+		return nil
+	}
+	return &period
+}
+
+// CheckPostingPeriod rejects journals dated in a locked period, and in a closed period unless adjusting
+func CheckPostingPeriod(tx *gorm.DB, date time.Time, adjusting bool) error {
+	return periodAccepts(PeriodFor(tx, date), adjusting)
+}
+
+// periodAccepts applies the posting rules to a period; no period row means open
+func periodAccepts(period *models.AccountingPeriod, adjusting bool) error {
+	if period == nil {
+		return nil
+	}
+	switch period.Status {
+	case models.PeriodLocked:
+		return fmt.Errorf("%w: %s is locked", ErrPeriodClosed, period.Name)
+	case models.PeriodClosed:
+		if !adjusting {
+			return fmt.Errorf("%w: %s only accepts adjusting entries", ErrPeriodClosed, period.Name)
+		}
+	}
+	return nil
+}
+
+// JournalPosting is a journal entry to be posted with PostJournal
+type JournalPosting struct {
+	Date          time.Time
+	ReferenceID   string
+	ReferenceType string
+	Description   string
+	IsAdjusting   bool
+	YearEndClose  bool // Closing entry of a fiscal year: passes closed and locked periods, the caller checked them
+	ReversalOfID  *uint
+	Items         []models.JournalItem
+}
+
+// PostJournal checks the period, then records the entry and updates COA balances within a TX
+func PostJournal(tx *gorm.DB, p JournalPosting) (*models.JournalEntry, error) {
+	if p.Date.IsZero() {
+		p.Date = time.Now()
+	}
+	if !p.YearEndClose {
+		if err := CheckPostingPeriod(tx, p.Date, p.IsAdjusting); err != nil {
+			return nil, err
+		}
+	}
+
+	entryNumber, err := NextJournalNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	entry := models.JournalEntry{
+		EntryNumber:   entryNumber,
+		Date:          p.Date,
+		Description:   p.Description,
+		ReferenceID:   p.ReferenceID,
+		ReferenceType: p.ReferenceType,
+		IsAdjusting:   p.IsAdjusting,
+		ReversalOfID:  p.ReversalOfID,
+	}
+	if entry.ReferenceID == "" {
+		entry.ReferenceID = entryNumber
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range p.Items {
+		p.Items[i].ID = 0
+		p.Items[i].JournalEntryID = entry.ID
+		if err := tx.Create(&p.Items[i]).Error; err != nil {
+			return nil, err
+		}
+		if err := applyCOABalance(tx, p.Items[i].COAID, p.Items[i].Debit, p.Items[i].Credit); err != nil {
+			return nil, err
+		}
+	}
+	entry.Items = p.Items
+	return &entry, nil
+}
+
+// applyCOABalance keeps the cached COA.Balance in step with a posted item
+func applyCOABalance(tx *gorm.DB, coaID uint, debit, credit float64) error {
+	var coa models.COA
+	if err := tx.First(&coa, coaID).Error; err != nil {
+		return err
+	}
+	if coa.Type == "ASSET" || coa.Type == "EXPENSE" || coa.Type == "COGS" {
+		coa.Balance += debit - credit
+	} else {
+		coa.Balance += credit - debit
+	}
+	return tx.Save(&coa).Error
+}
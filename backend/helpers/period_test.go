@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+
+	"forzashop/backend/models"
+)
+
+func TestPeriodAccepts(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string // "" = no period row
+		adjusting bool
+		wantErr   bool
+	}{
+		{"no period", "", false, false},
+		{"open", models.PeriodOpen, false, false},
+		{"open adjusting", models.PeriodOpen, true, false},
+		{"closed", models.PeriodClosed, false, true},
+		{"closed adjusting", models.PeriodClosed, true, false},
+		{"locked", models.PeriodLocked, false, true},
+		{"locked adjusting", models.PeriodLocked, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var period *models.AccountingPeriod
+			if tt.status != "" {
+				period = &models.AccountingPeriod{Name: "2026-10", Status: tt.status}
+			}
+			err := periodAccepts(period, tt.adjusting)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("periodAccepts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrPeriodClosed) {
+				t.Errorf("error %v does not wrap ErrPeriodClosed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package helpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"forzashop/backend/config"
+
+	"gorm.io/gorm"
+)
+
+// Document sequence types
+const (
+	SeqOrder         = "order"
+	SeqPOSOrder      = "pos_order"
+	SeqInvoice       = "invoice"
+	SeqPurchaseOrder = "purchase_order"
+	SeqJournal       = "journal"
+	SeqReturn        = "return"
+	SeqRefund        = "refund"
+	SeqSettlement    = "settlement"
+	SeqSupplierBill  = "supplier_bill"
+	SeqSupplierPay   = "supplier_payment"
+	SeqGoodsReceipt  = "goods_receipt"
+	SeqStockTransfer = "stock_transfer"
+	SeqStockTake     = "stock_take"
+)
+
+type sequenceFormat struct {
+	Prefix    string
+	Reset     string // monthly, yearly, never
+	Separator string
+	Padding   int
+}
+
+// Defaults, each overridable through settings: numbering_<type>_prefix, _reset, _separator, _padding
+var sequenceDefaults = map[string]sequenceFormat{
+	SeqOrder:         {Prefix: "FORZA", Reset: "monthly", Separator: "-", Padding: 5},
+	SeqPOSOrder:      {Prefix: "POS", Reset: "monthly", Separator: "-", Padding: 5},
+	SeqInvoice:       {Prefix: "INV", Reset: "monthly", Separator: "-", Padding: 5}, // "-": it ends up in gateway refs and URLs
+	SeqPurchaseOrder: {Prefix: "PO", Reset: "yearly", Separator: "/", Padding: 4},
+	SeqJournal:       {Prefix: "JRN", Reset: "monthly", Separator: "/", Padding: 5},
+	SeqReturn:        {Prefix: "RMA", Reset: "yearly", Separator: "/", Padding: 5},
+	SeqRefund:        {Prefix: "RF", Reset: "yearly", Separator: "/", Padding: 5},
+	SeqSettlement:    {Prefix: "REC", Reset: "monthly", Separator: "/", Padding: 4},
+	SeqSupplierBill:  {Prefix: "BILL", Reset: "yearly", Separator: "/", Padding: 4},
+	SeqSupplierPay:   {Prefix: "PAY", Reset: "monthly", Separator: "/", Padding: 4},
+	SeqGoodsReceipt:  {Prefix: "GRN", Reset: "monthly", Separator: "/", Padding: 4},
+	SeqStockTransfer: {Prefix: "TRF", Reset: "monthly", Separator: "/", Padding: 4},
+	SeqStockTake:     {Prefix: "SO", Reset: "monthly", Separator: "/", Padding: 4},
+}
+
+// NextSequenceNumber returns the next document number for seqType, e.g. INV-2026-10-00042.
+// Call it with the transaction that stores the document: the counter row stays locked until
+// commit, so concurrent checkouts queue instead of colliding, and a rollback returns the number.
+func NextSequenceNumber(tx *gorm.DB, seqType string) (string, error) {
+	format := loadSequenceFormat(seqType)
+	now := time.Now()
+
+	period := ""
+	datePart := ""
+	switch format.Reset {
+	case "monthly":
+		period = now.Format("200601")
+		datePart = now.Format("2006") + format.Separator + now.Format("01")
+	case "yearly":
+		period = now.Format("2006")
+		datePart = now.Format("2006")
+	}
+
+	var value int64
+	err := tx.Raw(`INSERT INTO number_sequences (type, period, last_value, updated_at)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT (type, period) DO UPDATE SET last_value = number_sequences.last_value + 1, updated_at = EXCLUDED.updated_at
+		RETURNING last_value`, seqType, period, now).Scan(&value).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate %s number: %v", seqType, err)
+	}
+	if value == 0 {
+		return "", fmt.Errorf("failed to allocate %s number", seqType)
+	}
+
+	parts := []string{}
+	if format.Prefix != "" {
+		parts = append(parts, format.Prefix)
+	}
+	if datePart != "" {
+		parts = append(parts, datePart)
+	}
+	parts = append(parts, fmt.Sprintf("%0*d", format.Padding, value))
+
+	return strings.Join(parts, format.Separator), nil
+}
+
+// NextJournalNumber allocates a journal entry number outside the caller's transaction. Nearly
+// every posting needs one, so holding the counter row until the caller commits would serialize
+// all posting; here it is locked only for its own statement. A rolled-back posting leaves a gap.
+func NextJournalNumber() (string, error) {
+	return NextSequenceNumber(config.DB, SeqJournal)
+}
+
+func loadSequenceFormat(seqType string) sequenceFormat {
+	format, ok := sequenceDefaults[seqType]
+	if !ok {
+		format = sequenceFormat{Prefix: strings.ToUpper(seqType), Reset: "yearly", Separator: "/", Padding: 5}
+	}
+
+	key := "numbering_" + seqType + "_"
+	format.Prefix = GetSetting(key+"prefix", format.Prefix)
+	format.Separator = GetSetting(key+"separator", format.Separator)
+
+	switch reset := GetSetting(key+"reset", format.Reset); reset {
+	case "monthly", "yearly", "never":
+		format.Reset = reset
+	}
+	if padding, err := strconv.Atoi(GetSetting(key+"padding", "")); err == nil && padding > 0 && padding <= 12 {
+		format.Padding = padding
+	}
+
+	return format
+}
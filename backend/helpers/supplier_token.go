@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SupplierTokenPOAcknowledge scopes a signed link to viewing and acknowledging one purchase order
+const SupplierTokenPOAcknowledge = "po_acknowledge"
+
+// SupplierPOClaims is the payload of the link emailed with a purchase order
+type SupplierPOClaims struct {
+	Purpose    string `json:"purpose"`
+	POID       uint   `json:"po_id"`
+	SupplierID uint   `json:"supplier_id"`
+	jwt.RegisteredClaims
+}
+
+// SignSupplierPOToken issues the supplier's link token for a purchase order
+func SignSupplierPOToken(poID, supplierID uint, ttl time.Duration) (string, error) {
+	claims := SupplierPOClaims{
+		Purpose:    SupplierTokenPOAcknowledge,
+		POID:       poID,
+		SupplierID: supplierID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// ParseSupplierPOToken validates a supplier link token
+func ParseSupplierPOToken(tokenString string) (*SupplierPOClaims, error) {
+	claims := &SupplierPOClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != SupplierTokenPOAcknowledge || claims.POID == 0 {
+		return nil, fmt.Errorf("invalid or expired link")
+	}
+	return claims, nil
+}
+
+// SupplierPOTokenTTL is how long a supplier's PO link stays valid (setting in days)
+func SupplierPOTokenTTL() time.Duration {
+	days, err := strconv.Atoi(GetSetting("po_supplier_link_days", "60"))
+	if err != nil || days <= 0 {
+		days = 60
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
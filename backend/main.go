@@ -10,6 +10,7 @@ import (
 	"forzashop/backend/models"
 	"forzashop/backend/routes"
 	"forzashop/backend/seed"
+	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -29,6 +30,8 @@ func main() {
 	args := os.Args[1:]
 	isSeed := false
 	isPresentation := false
+	isReconcileStock := false
+	isReconcileFix := false
 	for _, arg := range args {
 		if arg == "--seed" {
 			isSeed = true
@@ -36,6 +39,12 @@ func main() {
 		if arg == "--presentation" {
 			isPresentation = true
 		}
+		if arg == "--reconcile-stock" {
+			isReconcileStock = true
+		}
+		if arg == "--fix" {
+			isReconcileFix = true
+		}
 	}
 
 	// 1. Connect to Database
@@ -53,6 +62,7 @@ func main() {
 		&models.Category{},
 		&models.Brand{},
 		&models.Product{},
+		&models.ProductVariant{},
 		&models.CustomFieldTemplate{},
 
 		// Advanced Taxonomy (Warung Forza Inspired)
@@ -67,14 +77,21 @@ func main() {
 		&models.Order{},
 		&models.OrderItem{},
 		&models.OrderLog{},
+		&models.Shipment{},
+		&models.ShipmentItem{},
+		&models.ReturnRequest{},
+		&models.ReturnItem{},
 		&models.Invoice{},
 
 		// Finance
 		&models.AuditLog{},
 		&models.COA{},
 		&models.JournalEntry{},
+		&models.AccountingPeriod{},
 		&models.JournalItem{},
 		&models.Expense{},
+		&models.RecurringExpense{},
+		&models.ExportAccountMapping{},
 
 		// Marketing
 		&models.Wishlist{},
@@ -87,6 +104,7 @@ func main() {
 
 		// Payments
 		&models.PaymentTransaction{},
+		&models.Refund{},
 
 		// Suppliers
 		&models.Supplier{},
@@ -96,17 +114,25 @@ func main() {
 		&models.RestockEvent{},
 		&models.RestockNotification{},
 		&models.StockMovement{},
+		&models.Location{},
+		&models.StockLevel{},
+		&models.StockTransfer{},
+		&models.StockTransferItem{},
+		&models.StockTake{},
+		&models.StockTakeItem{},
 
 		// Customer
 		&models.CustomerProfile{},
 
 		// Settings
 		&models.Setting{},
+		&models.NumberSequence{},
 		&models.ShippingRate{},
 		&models.CarrierTemplate{},
 		&models.CarrierService{},
 		&models.ShippingZone{},
 		&models.ShippingMethod{},
+		&models.ShippingQuote{},
 
 		// Stock Reservation (Anti-Overselling)
 		&models.StockReservation{},
@@ -117,6 +143,10 @@ func main() {
 		// Procurement
 		&models.PurchaseOrder{},
 		&models.PurchaseOrderItem{},
+		&models.PurchaseReceipt{},
+		&models.PurchaseReceiptItem{},
+		&models.SupplierBill{},
+		&models.SupplierPayment{},
 
 		// Multi-Currency
 		&models.Currency{},
@@ -139,6 +169,10 @@ func main() {
 		&models.PrismalinkAPILog{},
 		&models.PrismalinkBank{},
 		&models.PrismalinkErrorCode{},
+		&models.SandboxCharge{},
+		&models.WebhookEvent{},
+		&models.SettlementBatch{},
+		&models.SettlementLine{},
 
 		// Vouchers
 		&models.Voucher{},
@@ -154,6 +188,11 @@ func main() {
 	}
 	log.Println("✅ MIGRATION SUCCESSFUL")
 
+	// POS used to mark fully paid pre-orders "paid_full"; everything now reads "paid"
+	if err := config.DB.Model(&models.Order{}).Where("payment_status = ?", "paid_full").Update("payment_status", "paid").Error; err != nil {
+		log.Println("⚠️ Failed to migrate paid_full orders:", err)
+	}
+
 	// 3. Handle Seeding Logic
 	if isPresentation {
 		log.Println("🎭 STARTING PRESENTATION MODE SEED...")
@@ -169,8 +208,33 @@ func main() {
 		return
 	}
 
+	if isReconcileStock {
+		log.Println("🔍 RECONCILING STOCK RESERVATIONS...")
+		report, err := services.NewInventoryService().Reconcile(isReconcileFix)
+		if err != nil {
+			log.Fatal("❌ RECONCILIATION FAILED: ", err)
+		}
+		for _, d := range report.Drifts {
+			log.Printf("⚠️  %s (product %d): reserved_qty %d, ledger %d", d.Name, d.ProductID, d.ReservedQty, d.Expected)
+		}
+		if isReconcileFix {
+			if _, err := services.NewLocationService().Sync(); err != nil {
+				log.Println("⚠️ Stock location sync failed: ", err)
+			}
+			log.Printf("✅ RECONCILIATION COMPLETE: %d drifts fixed, %d legacy holds backfilled. EXITING.", len(report.Drifts), report.Backfilled)
+		} else {
+			log.Printf("✅ DRY RUN COMPLETE: %d drifts, %d legacy holds without a ledger row (re-run with --fix to repair). EXITING.", len(report.Drifts), report.Unledgered)
+		}
+		return
+	}
+
 	// Normal Startup: Update permissions automatically to ensure sync
 	seed.SeedPermissions()
+	if report, err := services.NewLocationService().Sync(); err != nil {
+		log.Println("⚠️ Stock location sync failed: ", err)
+	} else if report.Adopted > 0 || report.Adjusted > 0 {
+		log.Printf("📍 Stock locations synced: %d holds adopted, %d items adjusted", report.Adopted, report.Adjusted)
+	}
 	// seed.SeedDatabase() // Disable auto-seed on start to prevent overwrites, use CLI args instead
 
 	// 4. Setup Router
@@ -189,6 +253,10 @@ func main() {
 	controllers.StartReservationCleanupWorker()
 	log.Println("🔄 Starting Payment Reminder Worker...")
 	controllers.StartPaymentReminderWorker()
+	log.Println("📥 Starting Webhook Inbox Worker...")
+	controllers.StartWebhookWorker()
+	log.Println("🏦 Starting Bank Mutation Worker...")
+	controllers.StartBankMutationWorker()
 
 	log.Println("🕰️  Initializing System Cron Scheduler...")
 	cron.InitCron()
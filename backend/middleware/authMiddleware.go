@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"forzashop/backend/config"
+	"forzashop/backend/helpers"
 	"forzashop/backend/models"
 
 	"github.com/gin-gonic/gin"
@@ -88,6 +89,11 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if user.Status == models.UserStatusGuest {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Guest accounts must be claimed before logging in"})
+			c.Abort()
+			return
+		}
 
 		c.Set("currentUser", user)
 		c.Set("userID", userID)
@@ -95,6 +101,88 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// guestTokenFromRequest reads a guest token from the Authorization header or the ?token= query
+// (order access links are opened straight from the confirmation email)
+func guestTokenFromRequest(c *gin.Context) string {
+	if tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "); tokenString != "" {
+		return tokenString
+	}
+	return c.Query("token")
+}
+
+// GuestCheckoutMiddleware authenticates a verified guest email (see GuestService.VerifyEmail) and
+// runs the request as its guest account, so the regular checkout handlers can be reused
+func GuestCheckoutMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := helpers.ParseGuestToken(guestTokenFromRequest(c), helpers.GuestTokenCheckout)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		var user models.User
+		if err := config.DB.Preload("Role.Permissions").
+			Where("LOWER(email) = ? AND status = ?", claims.Email, models.UserStatusGuest).
+			First(&user).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Guest session not found, please verify your email again"})
+			c.Abort()
+			return
+		}
+
+		c.Set("currentUser", user)
+		c.Set("userID", user.ID)
+		c.Set("guestEmail", claims.Email)
+		c.Next()
+	}
+}
+
+// GuestOrderAccessMiddleware authorizes a signed order access token for the single order in the
+// :id parameter (numeric ID or order number) and runs the request as that order's owner
+func GuestOrderAccessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := helpers.ParseGuestToken(guestTokenFromRequest(c), helpers.GuestTokenOrderAccess)
+		if err != nil || claims.OrderID == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired order link"})
+			c.Abort()
+			return
+		}
+
+		var order models.Order
+		if err := config.DB.Select("id", "order_number", "user_id").First(&order, claims.OrderID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+			c.Abort()
+			return
+		}
+		id := c.Param("id")
+		if id != fmt.Sprintf("%d", order.ID) && !strings.EqualFold(id, order.OrderNumber) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This link is not valid for this order"})
+			c.Abort()
+			return
+		}
+
+		var user models.User
+		if err := config.DB.Preload("Role.Permissions").First(&user, order.UserID).Error; err != nil ||
+			!strings.EqualFold(user.Email, claims.Email) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This link is no longer valid"})
+			c.Abort()
+			return
+		}
+
+		// Handlers look orders up by numeric ID
+		for i := range c.Params {
+			if c.Params[i].Key == "id" {
+				c.Params[i].Value = fmt.Sprintf("%d", order.ID)
+			}
+		}
+
+		c.Set("currentUser", user)
+		c.Set("userID", user.ID)
+		c.Set("guestEmail", claims.Email)
+		c.Next()
+	}
+}
+
 // AdminOnly middleware ensures user has admin role
 // Since we have single admin role, this replaces granular permission checks
 func AdminOnly() gin.HandlerFunc {
@@ -25,14 +25,41 @@ type COA struct {
 // JournalEntry - Header for a transaction
 type JournalEntry struct {
 	ID            uint          `gorm:"primaryKey" json:"id"`
+	EntryNumber   string        `gorm:"size:50;index" json:"entry_number"` // JRN/2026/10/00042, see helpers.NextSequenceNumber
 	Date          time.Time     `json:"date"`
 	Description   string        `json:"description"`
-	ReferenceID   string        `json:"reference_id"`   // e.g., ORDER-123, ADJUST-001
-	ReferenceType string        `json:"reference_type"` // ORDER, ADJUSTMENT, EXPENSE
+	ReferenceID   string        `json:"reference_id"`                      // e.g., ORDER-123, ADJUST-001
+	ReferenceType string        `json:"reference_type"`                    // ORDER, ADJUSTMENT, EXPENSE
+	IsAdjusting   bool          `gorm:"default:false" json:"is_adjusting"` // May be posted into a closed (not locked) period
+	ReversalOfID  *uint         `gorm:"index" json:"reversal_of_id"`       // This entry cancels ReversalOfID
+	ReversedByID  *uint         `json:"reversed_by_id"`                    // Set once, when the entry is reversed
 	Items         []JournalItem `json:"items"`
 	CreatedAt     time.Time     `json:"created_at"`
 }
 
+// Accounting period statuses. Flow: open -> closed (adjusting entries only) -> locked (no postings at all)
+const (
+	PeriodOpen   = "open"
+	PeriodClosed = "closed"
+	PeriodLocked = "locked"
+)
+
+// AccountingPeriod - a month of the books. Dates without a period are treated as open.
+type AccountingPeriod struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Name      string     `gorm:"size:20;unique;not null" json:"name"` // 2026-10
+	StartDate time.Time  `gorm:"index" json:"start_date"`
+	EndDate   time.Time  `gorm:"index" json:"end_date"` // Last day, inclusive
+	Status    string     `gorm:"size:20;default:'open'" json:"status"`
+	ClosedBy  *uint      `json:"closed_by"`
+	ClosedAt  *time.Time `json:"closed_at"`
+	LockedBy  *uint      `json:"locked_by"`
+	LockedAt  *time.Time `json:"locked_at"`
+	Note      string     `gorm:"type:text" json:"note"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
 // JournalItem - Line items (Debit/Credit)
 type JournalItem struct {
 	ID             uint    `gorm:"primaryKey" json:"id"`
@@ -49,23 +76,31 @@ type Invoice struct {
 	InvoiceNumber  string     `gorm:"size:50;unique;not null" json:"invoice_number"` // INV-202310-001
 	OrderID        *uint      `json:"order_id"`                                      // Pointer to allow null for TopUp
 	Order          Order      `gorm:"foreignKey:OrderID" json:"order,omitempty"`
-	UserID         uint       `json:"user_id"` // Optional: For wallet topups without order
+	OrderItemID    *uint      `gorm:"index" json:"order_item_id"` // PO line this deposit/balance belongs to (nil = whole order)
+	UserID         uint       `json:"user_id"`                    // Optional: For wallet topups without order
 	User           User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
 	Type           string     `gorm:"size:20;default:'full'" json:"type"` // full, deposit, balance
 	Amount         float64    `gorm:"type:decimal(20,2)" json:"amount"`
+	TaxAmount      float64    `gorm:"type:decimal(20,2);default:0" json:"tax_amount"` // PPN portion of Amount, credited to TAX_PAYABLE
 	CurrencyCode   string     `gorm:"size:3;default:'IDR'" json:"currency_code"`
 	ExchangeRate   float64    `gorm:"type:decimal(20,6);default:1" json:"exchange_rate"`
 	Status         string     `gorm:"default:'unpaid'" json:"status"` // unpaid, paid, cancelled
 	DueDate        time.Time  `json:"due_date"`
 	PaidAt         *time.Time `json:"paid_at"`
-	ReminderSentAt *time.Time `json:"reminder_sent_at"` // ✅ Tracks when payment reminder email was last sent
-	PaymentMethod  string     `json:"payment_method"`   // bank_transfer, etc.
-	PaymentProof   string     `json:"payment_proof"`    // URL to receipt image
-	PaymentNote    string     `json:"payment_note"`     // User note for payment
+	ReminderSentAt *time.Time `json:"reminder_sent_at"`             // ✅ Tracks when payment reminder email was last sent
+	PaymentMethod  string     `json:"payment_method"`               // bank_transfer, etc.
+	PaymentProof   string     `json:"payment_proof"`                // URL to receipt image
+	PaymentNote    string     `json:"payment_note"`                 // User note for payment
+	UniqueCode     int        `gorm:"default:0" json:"unique_code"` // 1-999 added to a bank transfer so the mutation identifies the invoice
 	CreatedAt      time.Time  `json:"created_at"`
 	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
+// TransferAmount is what the customer transfers: the invoice amount plus its unique code
+func (i *Invoice) TransferAmount() float64 {
+	return i.Amount + float64(i.UniqueCode)
+}
+
 // ============================================
 // PAYMENT MODULE
 // ============================================
@@ -100,7 +135,7 @@ type PaymentTransaction struct {
 
 type Expense struct {
 	ID           uint      `gorm:"primaryKey" json:"id"`
-	Date         time.Time `json:"date"`
+	Date         time.Time `gorm:"uniqueIndex:idx_recurring_occurrence" json:"date"`
 	Amount       float64   `gorm:"type:decimal(20,2);not null" json:"amount"`
 	COAID        uint      `json:"coa_id"` // Link to expense account
 	COA          COA       `json:"coa,omitempty"`
@@ -109,8 +144,50 @@ type Expense struct {
 	Attachment   string    `gorm:"size:255" json:"attachment"` // File URL
 	IsRecurring  bool      `gorm:"default:false" json:"is_recurring"`
 	RecurringDay int       `gorm:"default:0" json:"recurring_day"` // Day of month for recurring
-	CreatedBy    uint      `json:"created_by"`
-	Creator      User      `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+	// Schedule that generated (or was started from) this expense; one expense per schedule per day
+	RecurringExpenseID *uint     `gorm:"uniqueIndex:idx_recurring_occurrence" json:"recurring_expense_id"`
+	CreatedBy          uint      `json:"created_by"`
+	Creator            User      `gorm:"foreignKey:CreatedBy" json:"creator,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// Recurring expense frequencies
+const (
+	RecurWeekly  = "weekly"
+	RecurMonthly = "monthly"
+	RecurYearly  = "yearly"
+)
+
+// RecurringExpense - template the scheduler turns into an Expense (and journal) on every occurrence
+type RecurringExpense struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	COAID       uint       `json:"coa_id"`
+	COA         COA        `json:"coa,omitempty"`
+	Amount      float64    `gorm:"type:decimal(20,2);not null" json:"amount"`
+	Vendor      string     `gorm:"size:100" json:"vendor"`
+	Description string     `gorm:"type:text" json:"description"`
+	Frequency   string     `gorm:"size:20;not null" json:"frequency"` // weekly, monthly, yearly
+	DayOfMonth  int        `gorm:"default:0" json:"day_of_month"`     // monthly / yearly, clamped to the month's last day
+	Weekday     int        `gorm:"default:0" json:"weekday"`          // weekly, 0 = Sunday
+	Month       int        `gorm:"default:0" json:"month"`            // yearly, 1-12
+	StartDate   time.Time  `json:"start_date"`
+	EndDate     *time.Time `json:"end_date"`      // Last day an occurrence may fall on, nil = no end
+	LastRunDate *time.Time `json:"last_run_date"` // Latest occurrence generated; the next run continues after it
+	IsActive    bool       `gorm:"default:true" json:"is_active"`
+	LastError   string     `gorm:"type:text" json:"last_error"`
+	CreatedBy   uint       `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ExportAccountMapping - our COA code as known by an external accounting package (accurate, jurnal)
+type ExportAccountMapping struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Target       string    `gorm:"size:20;not null;uniqueIndex:idx_export_mapping" json:"target"`
+	COACode      string    `gorm:"size:20;not null;uniqueIndex:idx_export_mapping" json:"coa_code"`
+	ExternalCode string    `gorm:"size:50;not null" json:"external_code"`
+	ExternalName string    `gorm:"size:100" json:"external_name"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
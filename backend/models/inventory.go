@@ -31,18 +31,24 @@ type RestockNotification struct {
 // ============================================
 
 type StockMovement struct {
-	ID            uint      `gorm:"primaryKey" json:"id"`
-	ProductID     uint      `gorm:"index" json:"product_id"`
-	Product       Product   `json:"product,omitempty"`
-	Quantity      int       `json:"quantity"`       // + for addition, - for reduction
-	StockType     string    `json:"stock_type"`     // 'physical', 'reserved'
-	MovementType  string    `json:"movement_type"`  // 'sale', 'adjustment', 'procurement', 'return', 'cancellation'
-	ReferenceType string    `json:"reference_type"` // 'ORDER', 'PROCUREMENT', 'MANUAL'
-	ReferenceID   string    `json:"reference_id"`   // INV-XXX, ADJ-XXX
-	Note          string    `json:"note"`
-	PerformedBy   *uint     `json:"performed_by"` // User ID
-	User          *User     `gorm:"foreignKey:PerformedBy" json:"user,omitempty"`
-	BalanceBefore int       `json:"balance_before"`
-	BalanceAfter  int       `json:"balance_after"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	ProductID      uint            `gorm:"index" json:"product_id"`
+	Product        Product         `json:"product,omitempty"`
+	VariantID      *uint           `gorm:"index" json:"variant_id"` // Set when the movement hit a variant's stock
+	Variant        *ProductVariant `json:"variant,omitempty"`
+	Quantity       int             `json:"quantity"`                      // + for addition, - for reduction
+	FromLocationID *uint           `gorm:"index" json:"from_location_id"` // Location the units left (reductions, transfers)
+	FromLocation   *Location       `json:"from_location,omitempty"`
+	ToLocationID   *uint           `gorm:"index" json:"to_location_id"` // Location the units arrived at (additions, transfers)
+	ToLocation     *Location       `json:"to_location,omitempty"`
+	StockType      string          `json:"stock_type"`     // 'physical', 'reserved'
+	MovementType   string          `json:"movement_type"`  // 'sale', 'adjustment', 'procurement', 'return', 'cancellation', 'transfer'
+	ReferenceType  string          `json:"reference_type"` // 'ORDER', 'PROCUREMENT', 'MANUAL'
+	ReferenceID    string          `json:"reference_id"`   // INV-XXX, ADJ-XXX
+	Note           string          `json:"note"`
+	PerformedBy    *uint           `json:"performed_by"` // User ID
+	User           *User           `gorm:"foreignKey:PerformedBy" json:"user,omitempty"`
+	BalanceBefore  int             `json:"balance_before"`
+	BalanceAfter   int             `json:"balance_after"`
+	CreatedAt      time.Time       `json:"created_at"`
 }
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+)
+
+// ============================================
+// STOCK LOCATIONS
+// ============================================
+
+// Location types
+const (
+	LocationShop        = "shop"
+	LocationWarehouse   = "warehouse"
+	LocationConsignment = "consignment"
+)
+
+// Location - a place that physically holds stock: the shop, the back warehouse, an event booth.
+// Product.Stock / ProductVariant.Stock stay the company-wide totals; StockLevel splits them per location.
+type Location struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Code       string    `gorm:"size:30;unique;not null" json:"code"` // JKT-SHOP, WH-01, EVT-JFF
+	Name       string    `gorm:"size:100;not null" json:"name"`
+	Type       string    `gorm:"size:20;default:'warehouse'" json:"type"` // shop, warehouse, consignment
+	Address    string    `gorm:"type:text" json:"address"`
+	Priority   int       `gorm:"default:100;index" json:"priority"` // Web checkout allocates from the lowest number first
+	SellOnline bool      `gorm:"default:true" json:"sell_online"`   // Stock here can be reserved by web checkout
+	IsDefault  bool      `gorm:"default:false" json:"is_default"`   // Receives goods, returns and manual adjustments
+	IsActive   bool      `gorm:"default:true;index" json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// StockLevel - stock of one product/edition at one location. VariantID is 0 for products without
+// editions so the (location, product, variant) key stays unique.
+type StockLevel struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	LocationID    uint      `gorm:"uniqueIndex:idx_stock_level;not null" json:"location_id"`
+	Location      *Location `json:"location,omitempty"`
+	ProductID     uint      `gorm:"uniqueIndex:idx_stock_level;not null;index" json:"product_id"`
+	Product       *Product  `json:"product,omitempty"`
+	VariantID     uint      `gorm:"uniqueIndex:idx_stock_level;default:0" json:"variant_id"` // No foreign key: 0 is a valid value
+	Stock         int       `gorm:"default:0" json:"stock"`
+	ReservedQty   int       `gorm:"default:0" json:"reserved_qty"`
+	MinStockLevel int       `gorm:"default:0" json:"min_stock_level"` // Low-stock alert threshold for this location
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	AvailableStock int `gorm:"-" json:"available_stock"`
+}
+
+// ============================================
+// STOCK TRANSFERS
+// ============================================
+
+// Stock transfer statuses
+const (
+	TransferDraft     = "draft"
+	TransferInTransit = "in_transit"
+	TransferReceived  = "received"
+	TransferCancelled = "cancelled"
+)
+
+// StockTransfer - moves stock between locations. Dispatching takes it off the source, receiving
+// puts it on the destination. In transit the units still count in the product total, but at neither location.
+type StockTransfer struct {
+	ID             uint                `gorm:"primaryKey" json:"id"`
+	TransferNumber string              `gorm:"size:50;unique;not null" json:"transfer_number"` // TRF/2026/10/0001
+	FromLocationID uint                `gorm:"index;not null" json:"from_location_id"`
+	FromLocation   *Location           `json:"from_location,omitempty"`
+	ToLocationID   uint                `gorm:"index;not null" json:"to_location_id"`
+	ToLocation     *Location           `json:"to_location,omitempty"`
+	Status         string              `gorm:"size:20;default:'draft';index" json:"status"` // draft, in_transit, received, cancelled
+	Notes          string              `gorm:"type:text" json:"notes"`
+	Items          []StockTransferItem `json:"items"`
+	CreatedBy      uint                `json:"created_by"`
+	DispatchedAt   *time.Time          `json:"dispatched_at"`
+	ReceivedAt     *time.Time          `json:"received_at"`
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
+
+type StockTransferItem struct {
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	StockTransferID uint            `gorm:"index;not null" json:"stock_transfer_id"`
+	ProductID       uint            `gorm:"not null" json:"product_id"`
+	Product         *Product        `json:"product,omitempty"`
+	VariantID       *uint           `json:"variant_id"`
+	Variant         *ProductVariant `json:"variant,omitempty"`
+	Quantity        int             `gorm:"not null" json:"quantity"`
+}
@@ -46,7 +46,9 @@ type Order struct {
 	TotalAmount      float64 `gorm:"type:decimal(20,2);not null" json:"total_amount"`
 	CurrencyCode     string  `gorm:"size:3;default:'IDR'" json:"currency_code"`
 	ExchangeRate     float64 `gorm:"type:decimal(20,6);default:1" json:"exchange_rate"` // Rate at time of order
-	TaxAmount        float64 `gorm:"type:decimal(20,2);default:0" json:"tax_amount"`
+	TaxAmount        float64 `gorm:"type:decimal(20,2);default:0" json:"tax_amount"`    // PPN, see TaxService
+	TaxRate          float64 `gorm:"type:decimal(5,2);default:0" json:"tax_rate"`       // Effective PPN % (0 when disabled or zero-rated)
+	TaxInclusive     bool    `gorm:"default:false" json:"tax_inclusive"`                // true = TaxAmount is already inside the item prices
 	ShippingCost     float64 `gorm:"type:decimal(20,2);default:0" json:"shipping_cost"`
 	ShippingMethod   string  `gorm:"size:100" json:"shipping_method"` // Weight Based Shipping, Local Pickup
 	DiscountAmount   float64 `gorm:"type:decimal(20,2);default:0" json:"discount_amount"`
@@ -71,9 +73,10 @@ type Order struct {
 	Notes         string `gorm:"type:text" json:"notes"`          // Customer notes
 	InternalNotes string `gorm:"type:text" json:"internal_notes"` // Admin notes
 
-	Items    []OrderItem `json:"items"`
-	Logs     []OrderLog  `json:"logs"`
-	Invoices []Invoice   `json:"invoices"` // One order can have multiple invoices (Deposit, Balance)
+	Items     []OrderItem `json:"items"`
+	Logs      []OrderLog  `json:"logs"`
+	Invoices  []Invoice   `json:"invoices"`            // One order can have multiple invoices (Deposit, Balance)
+	Shipments []Shipment  `json:"shipments,omitempty"` // Parcels; TrackingNumber/Carrier above mirror the latest one
 
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
@@ -81,17 +84,35 @@ type Order struct {
 }
 
 type OrderItem struct {
-	ID        uint    `gorm:"primaryKey" json:"id"`
-	OrderID   uint    `json:"order_id"`
-	ProductID uint    `json:"product_id"`
-	Product   Product `json:"product"`
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	OrderID   uint            `json:"order_id"`
+	ProductID uint            `json:"product_id"`
+	Product   Product         `json:"product"`
+	VariantID *uint           `gorm:"index" json:"variant_id"` // Selected edition, nil for products without variants
+	Variant   *ProductVariant `json:"variant,omitempty"`
 
 	Quantity int     `json:"quantity"`
 	Price    float64 `gorm:"type:decimal(20,2)" json:"price"` // Price at time of purchase
 	Total    float64 `gorm:"type:decimal(20,2)" json:"total"`
 
-	SnapshotData datatypes.JSON `json:"snapshot_data"`                           // Name, SKU, Image at time of purchase to prevent historic changes
-	COGSSnapshot float64        `gorm:"type:decimal(20,2)" json:"cogs_snapshot"` // Cost of Goods Sold snapshot
+	SnapshotData datatypes.JSON `json:"snapshot_data"`                                  // Name, SKU, Image at time of purchase to prevent historic changes
+	COGSSnapshot float64        `gorm:"type:decimal(20,2)" json:"cogs_snapshot"`        // Cost of Goods Sold snapshot
+	TaxAmount    float64        `gorm:"type:decimal(20,2);default:0" json:"tax_amount"` // PPN on this line after discount
+
+	// Per-line payment schedule (mixed ready + PO carts)
+	ProductType string     `gorm:"size:20" json:"product_type"` // ready, po (snapshot at checkout; empty on legacy rows)
+	ArrivedAt   *time.Time `json:"arrived_at"`                  // PO lines: when the stock arrived and the balance was opened
+	SettledAt   *time.Time `json:"settled_at"`                  // When the line was fully paid and its stock moved from reserved to sold
+	ForfeitedAt *time.Time `json:"forfeited_at"`                // PO lines: balance never paid, deposit kept and reservation released
+}
+
+// IsPO reports whether the line follows the deposit/balance schedule.
+// Legacy rows have no ProductType snapshot, so fall back to the (preloaded) product.
+func (i *OrderItem) IsPO() bool {
+	if i.ProductType != "" {
+		return i.ProductType == "po"
+	}
+	return i.Product.ProductType == "po"
 }
 
 type OrderLog struct {
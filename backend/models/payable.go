@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// ============================================
+// ACCOUNTS PAYABLE
+// ============================================
+
+// Supplier bill statuses
+const (
+	BillUnpaid  = "unpaid"
+	BillPartial = "partial"
+	BillPaid    = "paid"
+)
+
+// SupplierBill - what we owe a supplier. Bills from a PO receipt carry no journal of their own:
+// receiving already credited Hutang Usaha. Other bills debit ExpenseCOAID.
+type SupplierBill struct {
+	ID                uint              `gorm:"primaryKey" json:"id"`
+	BillNumber        string            `gorm:"size:50;unique;not null" json:"bill_number"` // BILL/2026/0001
+	SupplierID        uint              `gorm:"index;not null" json:"supplier_id"`
+	Supplier          Supplier          `json:"supplier,omitempty"`
+	PurchaseOrderID   *uint             `gorm:"index" json:"purchase_order_id"`
+	PurchaseOrder     *PurchaseOrder    `json:"purchase_order,omitempty"`
+	SupplierInvoiceNo string            `gorm:"size:100" json:"supplier_invoice_no"` // The supplier's own invoice number
+	BillDate          time.Time         `gorm:"index" json:"bill_date"`
+	DueDate           time.Time         `gorm:"index" json:"due_date"`
+	Amount            float64           `gorm:"type:decimal(20,2);not null" json:"amount"`
+	PaidAmount        float64           `gorm:"type:decimal(20,2);default:0" json:"paid_amount"`
+	Status            string            `gorm:"size:20;default:'unpaid';index" json:"status"` // unpaid, partial, paid
+	ExpenseCOAID      *uint             `json:"expense_coa_id"`                               // Debited on bills without a PO
+	Notes             string            `gorm:"type:text" json:"notes"`
+	Payments          []SupplierPayment `json:"payments,omitempty"`
+	CreatedBy         uint              `json:"created_by"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+// Outstanding is what is still to be paid on the bill
+func (b *SupplierBill) Outstanding() float64 {
+	return b.Amount - b.PaidAmount
+}
+
+// SupplierPayment - a (partial) payment of a bill: Debit Hutang Usaha, Credit bank
+type SupplierPayment struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	PaymentNumber  string    `gorm:"size:50;unique;not null" json:"payment_number"` // PAY/2026/10/0001
+	SupplierBillID uint      `gorm:"index;not null" json:"supplier_bill_id"`
+	SupplierID     uint      `gorm:"index;not null" json:"supplier_id"`
+	Date           time.Time `gorm:"index" json:"date"`
+	Amount         float64   `gorm:"type:decimal(20,2);not null" json:"amount"`
+	BankCOAID      uint      `json:"bank_coa_id"`
+	Method         string    `gorm:"size:50" json:"method"`     // transfer, cash, giro
+	Reference      string    `gorm:"size:100" json:"reference"` // Bank transfer ref
+	Notes          string    `gorm:"type:text" json:"notes"`
+	JournalEntryID uint      `json:"journal_entry_id"`
+	CreatedBy      uint      `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -4,6 +4,18 @@ import (
 	"time"
 )
 
+// Purchase order statuses. A draft needs approval (pending_approval -> approved) before it is
+// sent to the supplier (ordered); receipts then move it to partial / received.
+const (
+	PODraft           = "draft"
+	POPendingApproval = "pending_approval"
+	POApproved        = "approved"
+	POOrdered         = "ordered"
+	POPartial         = "partial"
+	POReceived        = "received"
+	POCancelled       = "cancelled"
+)
+
 // PurchaseOrder represents a procurement order to a supplier
 type PurchaseOrder struct {
 	ID         uint     `gorm:"primaryKey" json:"id"`
@@ -11,14 +23,30 @@ type PurchaseOrder struct {
 	SupplierID uint     `json:"supplier_id"`
 	Supplier   Supplier `json:"supplier"`
 
-	Status      string  `gorm:"size:20;default:'draft'" json:"status"` // draft, ordered, received, cancelled
+	Status      string  `gorm:"size:20;default:'draft'" json:"status"` // draft, pending_approval, approved, ordered, partial, received, cancelled
 	TotalAmount float64 `gorm:"type:decimal(20,2)" json:"total_amount"`
 	Notes       string  `gorm:"type:text" json:"notes"`
+	Source      string  `gorm:"size:20;default:'manual'" json:"source"` // manual, reorder (drafted from reorder suggestions)
 
 	OrderedAt    *time.Time `json:"ordered_at"`
-	ExpectedDate *time.Time `json:"expected_date"`
+	ExpectedDate *time.Time `json:"expected_date"` // Feeds the ETA of pre-order products
 	ReceivedAt   *time.Time `json:"received_at"`
 
+	// Approval
+	CreatedBy      *uint      `json:"created_by"` // Creator and submitter may not approve their own PO
+	SubmittedBy    *uint      `json:"submitted_by"`
+	SubmittedAt    *time.Time `json:"submitted_at"`
+	ApprovedBy     *uint      `json:"approved_by"`
+	ApprovedAt     *time.Time `json:"approved_at"`
+	ApprovedAmount float64    `gorm:"type:decimal(20,2);default:0" json:"approved_amount"` // Total at approval; sent POs can't grow past it
+	RejectReason   string     `gorm:"type:text" json:"reject_reason"`
+
+	// Supplier
+	SentAt         *time.Time `json:"sent_at"`
+	SentTo         string     `gorm:"size:255" json:"sent_to"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"` // Supplier confirmed through the signed link
+	SupplierNote   string     `gorm:"type:text" json:"supplier_note"`
+
 	Items []PurchaseOrderItem `json:"items"`
 
 	CreatedAt time.Time `json:"created_at"`
@@ -27,16 +55,69 @@ type PurchaseOrder struct {
 
 // PurchaseOrderItem represents an item within a Purchase Order
 type PurchaseOrderItem struct {
-	ID              uint    `gorm:"primaryKey" json:"id"`
-	PurchaseOrderID uint    `json:"purchase_order_id"`
-	ProductID       uint    `json:"product_id"`
-	Product         Product `json:"product"`
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	PurchaseOrderID uint            `json:"purchase_order_id"`
+	ProductID       uint            `json:"product_id"`
+	Product         Product         `json:"product"`
+	VariantID       *uint           `json:"variant_id"` // Edition received into; nil = the product itself
+	Variant         *ProductVariant `json:"variant,omitempty"`
 
 	Quantity  int     `json:"quantity"`
 	UnitCost  float64 `gorm:"type:decimal(20,2)" json:"unit_cost"`
 	TotalCost float64 `gorm:"type:decimal(20,2)" json:"total_cost"`
 
-	ReceivedQty int `gorm:"default:0" json:"received_qty"` // For partial receiving support later
+	ReceivedQty int `gorm:"default:0" json:"received_qty"` // Sum of PurchaseReceiptItem.Quantity
+}
+
+// Remaining is what is still to be received on the line
+func (i *PurchaseOrderItem) Remaining() int {
+	return i.Quantity - i.ReceivedQty
+}
+
+// Landed cost allocation methods
+const (
+	AllocateByValue    = "value"
+	AllocateByQuantity = "quantity"
+)
+
+// PurchaseReceipt - one delivery against a PO (goods received note). Freight, customs and import duty
+// are spread over its lines as landed cost, which is capitalised into inventory.
+type PurchaseReceipt struct {
+	ID               uint                  `gorm:"primaryKey" json:"id"`
+	ReceiptNumber    string                `gorm:"size:50;unique;not null" json:"receipt_number"` // GRN/2026/10/0001
+	PurchaseOrderID  uint                  `gorm:"index;not null" json:"purchase_order_id"`
+	LocationID       uint                  `gorm:"index" json:"location_id"` // Location the goods were put away at
+	ReceivedAt       time.Time             `json:"received_at"`
+	GoodsValue       float64               `gorm:"type:decimal(20,2)" json:"goods_value"` // Quantity x PO unit cost, billed by the supplier
+	Freight          float64               `gorm:"type:decimal(20,2);default:0" json:"freight"`
+	Customs          float64               `gorm:"type:decimal(20,2);default:0" json:"customs"`
+	ImportDuty       float64               `gorm:"type:decimal(20,2);default:0" json:"import_duty"`
+	LandedCost       float64               `gorm:"type:decimal(20,2);default:0" json:"landed_cost"`  // Freight + Customs + ImportDuty
+	AllocationMethod string                `gorm:"size:20;default:'value'" json:"allocation_method"` // value, quantity
+	LandedCostCOAID  *uint                 `json:"landed_cost_coa_id"`                               // Credited for the landed cost
+	SupplierBillID   *uint                 `json:"supplier_bill_id"`
+	JournalEntryID   uint                  `json:"journal_entry_id"`
+	Notes            string                `gorm:"type:text" json:"notes"`
+	ReceivedBy       uint                  `json:"received_by"`
+	Items            []PurchaseReceiptItem `json:"items"`
+	CreatedAt        time.Time             `json:"created_at"`
+}
+
+// PurchaseReceiptItem - quantity received on one PO line and what each unit cost once landed
+type PurchaseReceiptItem struct {
+	ID                  uint    `gorm:"primaryKey" json:"id"`
+	PurchaseReceiptID   uint    `gorm:"index;not null" json:"purchase_receipt_id"`
+	PurchaseOrderItemID uint    `gorm:"index;not null" json:"purchase_order_item_id"`
+	ProductID           uint    `json:"product_id"`
+	Product             Product `json:"product,omitempty"`
+	VariantID           *uint   `json:"variant_id"`
+	Quantity            int     `json:"quantity"`
+	UnitCost            float64 `gorm:"type:decimal(20,2)" json:"unit_cost"`        // PO price
+	LandedCost          float64 `gorm:"type:decimal(20,2)" json:"landed_cost"`      // Share of the receipt's landed cost
+	UnitLandedCost      float64 `gorm:"type:decimal(20,4)" json:"unit_landed_cost"` // (Quantity x UnitCost + LandedCost) / Quantity
+	StockBefore         int     `json:"stock_before"`
+	CostBefore          float64 `gorm:"type:decimal(20,2)" json:"cost_before"` // Average cost before this receipt
+	CostAfter           float64 `gorm:"type:decimal(20,2)" json:"cost_after"`  // Moving weighted average after it
 }
 
 // Hook to update total cost before saving
@@ -20,6 +20,7 @@ type Category struct {
 	Products     []Product  `json:"products,omitempty"`
 	DisplayOrder int        `gorm:"default:0" json:"display_order"` // For custom sorting
 	IsActive     bool       `gorm:"default:true" json:"is_active"`
+	TaxExempt    bool       `gorm:"default:false" json:"tax_exempt"` // PPN exempt (also applies to sub-categories)
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 }
@@ -131,4 +132,55 @@ type Product struct {
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 	ReleaseDate  *time.Time     `json:"release_date"`  // Official release date
 	PreOrderDate *time.Time     `json:"preorder_date"` // When pre-orders open
+
+	// Editions (standard/deluxe/exclusive, regional). When present, stock lives on the variants
+	// and Stock/ReservedQty above are the rolled-up totals.
+	Variants []ProductVariant `gorm:"foreignKey:ProductID" json:"variants,omitempty"`
+}
+
+// ProductVariant is a sellable edition of a Product with its own price, stock and QR code
+type ProductVariant struct {
+	ID             uint    `gorm:"primaryKey" json:"id"`
+	ProductID      uint    `gorm:"index;not null" json:"product_id"`
+	Name           string  `gorm:"size:255;not null" json:"name"` // e.g. "Deluxe Version", "Japan Edition"
+	SKU            string  `gorm:"size:100;unique;not null;index" json:"sku"`
+	QRCode         string  `gorm:"size:100;unique;index" json:"qr_code"`
+	Price          float64 `gorm:"type:decimal(20,2);not null" json:"price"`
+	SupplierCost   float64 `gorm:"type:decimal(20,2);default:0" json:"supplier_cost"`
+	Stock          int     `gorm:"default:0" json:"stock"`
+	ReservedQty    int     `gorm:"default:0" json:"reserved_qty"`
+	AvailableStock int     `gorm:"-" json:"available_stock"`                   // Virtual field: Stock - ReservedQty
+	Weight         float64 `gorm:"type:decimal(20,2);default:0" json:"weight"` // 0 = use the parent product weight
+
+	Images       datatypes.JSON `json:"images"`                                       // Optional edition-specific images
+	Status       string         `gorm:"size:50;default:'active';index" json:"status"` // active, archived
+	DisplayOrder int            `gorm:"default:0" json:"display_order"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+}
+
+// PriceFor returns the unit price of the product, or of the given edition
+func (p *Product) PriceFor(v *ProductVariant) float64 {
+	if v != nil {
+		return v.Price
+	}
+	return p.Price
+}
+
+// WeightFor returns the unit weight of the product, or of the given edition
+func (p *Product) WeightFor(v *ProductVariant) float64 {
+	if v != nil && v.Weight > 0 {
+		return v.Weight
+	}
+	return p.Weight
+}
+
+// CostFor returns the supplier cost (COGS) of the product, or of the given edition
+func (p *Product) CostFor(v *ProductVariant) float64 {
+	if v != nil && v.SupplierCost > 0 {
+		return v.SupplierCost
+	}
+	return p.SupplierCost
 }
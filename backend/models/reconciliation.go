@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Settlement line statuses
+const (
+	SettlementLineMatched    = "matched"    // Found and agrees, waiting for confirmation
+	SettlementLineMismatched = "mismatched" // Found but amount / status / duplicate does not agree
+	SettlementLineUnmatched  = "unmatched"  // Nothing found
+	SettlementLineConfirmed  = "confirmed"  // Reconciled, journals posted
+	SettlementLineIgnored    = "ignored"    // Not ours to reconcile (bank charges, internal transfers, ...)
+)
+
+// SettlementBatch is one imported gateway settlement report or bank statement
+type SettlementBatch struct {
+	ID             uint             `gorm:"primaryKey" json:"id"`
+	BatchNumber    string           `gorm:"size:50;unique;not null" json:"batch_number"`
+	Source         string           `gorm:"size:20;not null" json:"source"` // gateway, bank
+	Provider       string           `gorm:"size:50" json:"provider"`        // prismalink, sandbox, bca, ...
+	FileName       string           `gorm:"size:255" json:"file_name"`
+	BankCOAID      *uint            `json:"bank_coa_id"` // Account the money lands on (default PRIMARY_BANK)
+	LineCount      int              `json:"line_count"`
+	MatchedCount   int              `json:"matched_count"`
+	MismatchCount  int              `json:"mismatch_count"`
+	UnmatchedCount int              `json:"unmatched_count"`
+	ConfirmedCount int              `json:"confirmed_count"`
+	TotalGross     float64          `gorm:"type:decimal(20,2)" json:"total_gross"`
+	TotalFee       float64          `gorm:"type:decimal(20,2)" json:"total_fee"`
+	TotalNet       float64          `gorm:"type:decimal(20,2)" json:"total_net"`
+	ImportedBy     uint             `json:"imported_by"`
+	Lines          []SettlementLine `gorm:"foreignKey:BatchID" json:"lines,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// SettlementLine is one row of an imported report and what it was matched to
+type SettlementLine struct {
+	ID             uint                `gorm:"primaryKey" json:"id"`
+	BatchID        uint                `gorm:"index;not null" json:"batch_id"`
+	LineNo         int                 `json:"line_no"`
+	TxDate         *time.Time          `json:"tx_date"`
+	Reference      string              `gorm:"size:100;index" json:"reference"`   // Merchant ref no (gateway reports)
+	GatewayRef     string              `gorm:"size:100;index" json:"gateway_ref"` // Gateway's own transaction id
+	Description    string              `gorm:"type:text" json:"description"`
+	GrossAmount    float64             `gorm:"type:decimal(20,2)" json:"gross_amount"`
+	FeeAmount      float64             `gorm:"type:decimal(20,2)" json:"fee_amount"`
+	NetAmount      float64             `gorm:"type:decimal(20,2)" json:"net_amount"`
+	Status         string              `gorm:"size:20;index" json:"status"`
+	MatchType      string              `gorm:"size:20" json:"match_type"` // payment_tx, invoice
+	PaymentTxID    *uint               `gorm:"index" json:"payment_tx_id"`
+	PaymentTx      *PaymentTransaction `gorm:"foreignKey:PaymentTxID" json:"payment_tx,omitempty"`
+	InvoiceID      *uint               `gorm:"index" json:"invoice_id"`
+	Invoice        *Invoice            `gorm:"foreignKey:InvoiceID" json:"invoice,omitempty"`
+	ExpectedAmount float64             `gorm:"type:decimal(20,2)" json:"expected_amount"`
+	Note           string              `gorm:"type:text" json:"note"`
+	JournalRef     string              `gorm:"size:50" json:"journal_ref"`
+	ConfirmedBy    *uint               `json:"confirmed_by"`
+	ConfirmedAt    *time.Time          `json:"confirmed_at"`
+	Raw            datatypes.JSON      `json:"raw"` // Original CSV row, keyed by header
+	CreatedAt      time.Time           `json:"created_at"`
+	UpdatedAt      time.Time           `json:"updated_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Refund statuses. Flow: requested -> processing -> succeeded | failed (failed -> requested on retry)
+const (
+	RefundStatusRequested  = "requested"
+	RefundStatusProcessing = "processing"
+	RefundStatusSucceeded  = "succeeded"
+	RefundStatusFailed     = "failed"
+)
+
+// Refund is money going back to a customer, executed against one original charge through its
+// gateway (method gateway), credited to the Forza Wallet (wallet) or paid out by hand (manual).
+// The reversing journal and the customer email are produced when it reaches succeeded.
+type Refund struct {
+	ID           uint    `gorm:"primaryKey" json:"id"`
+	RefundNumber string  `gorm:"size:50;unique;not null" json:"refund_number"` // RF/2026/00001, sent to the gateway as reference
+	OrderID      uint    `gorm:"index;not null" json:"order_id"`
+	Order        *Order  `json:"order,omitempty"`
+	Method       string  `gorm:"size:20;not null" json:"method"` // gateway, wallet, manual
+	Gateway      string  `gorm:"size:50" json:"gateway"`
+	Type         string  `gorm:"size:20" json:"type"` // full, partial
+	Amount       float64 `gorm:"type:decimal(20,2);not null" json:"amount"`
+	Status       string  `gorm:"size:20;default:'requested';index" json:"status"`
+	Reason       string  `gorm:"type:text" json:"reason"`
+
+	// Original charge being refunded (gateway method) and the refund PaymentTransaction mirroring this row
+	ChargeTxID  *uint               `gorm:"index" json:"charge_tx_id"`
+	ChargeTx    *PaymentTransaction `gorm:"foreignKey:ChargeTxID" json:"charge_tx,omitempty"`
+	RefundTxID  *uint               `json:"refund_tx_id"`
+	GatewayRef  string              `gorm:"size:100" json:"gateway_ref"`
+	FailureNote string              `gorm:"type:text" json:"failure_note"`
+	Attempts    int                 `gorm:"default:0" json:"attempts"`
+
+	// What triggered the refund: order (admin refund), return, cancellation, order_edit
+	Source    string `gorm:"size:30;index" json:"source"`
+	SourceRef string `gorm:"size:50" json:"source_ref"` // RMA number, order number, ...
+
+	RequestedBy *uint      `json:"requested_by"`
+	ProcessedAt *time.Time `json:"processed_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -14,18 +14,24 @@ const (
 	ReservationExpired  ReservationStatus = "expired"  // Timeout, auto-released
 )
 
-// StockReservation tracks stock holds during checkout process
+// StockReservation is one hold on stock: a checkout cart hold, an order line (checkout, POS PO,
+// manual order) or an order edit. The reserved_qty of a product/edition always equals the sum of its
+// active rows; the inventory service is the only writer of both.
 type StockReservation struct {
-	ID        uint              `gorm:"primaryKey" json:"id"`
-	OrderID   uint              `gorm:"index" json:"order_id"`
-	Order     *Order            `json:"order,omitempty"`
-	ProductID uint              `gorm:"index;not null" json:"product_id"`
-	Product   *Product          `json:"product,omitempty"`
-	Quantity  int               `gorm:"not null" json:"quantity"`
-	Status    ReservationStatus `gorm:"size:20;default:'reserved';index" json:"status"`
-	ExpiresAt time.Time         `gorm:"index" json:"expires_at"`
-	CreatedAt time.Time         `json:"created_at"`
-	UpdatedAt time.Time         `json:"updated_at"`
+	ID          uint              `gorm:"primaryKey" json:"id"`
+	OrderID     uint              `gorm:"index" json:"order_id"`
+	Order       *Order            `json:"order,omitempty"`
+	OrderItemID *uint             `gorm:"index" json:"order_item_id"` // Order line the hold belongs to (nil = cart hold)
+	ProductID   uint              `gorm:"index;not null" json:"product_id"`
+	Product     *Product          `json:"product,omitempty"`
+	VariantID   *uint             `gorm:"index" json:"variant_id"`  // Edition held, nil for products without variants
+	LocationID  *uint             `gorm:"index" json:"location_id"` // Location the units are held at (nil = default location)
+	Quantity    int               `gorm:"not null" json:"quantity"`
+	Status      ReservationStatus `gorm:"size:20;default:'reserved';index" json:"status"`
+	Source      string            `gorm:"size:30" json:"source"`   // cart, checkout, pos, manual_order, order_edit, backfill
+	ExpiresAt   *time.Time        `gorm:"index" json:"expires_at"` // nil = held until the order consumes or releases it
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
 
 	// Audit fields
 	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
@@ -35,7 +41,7 @@ type StockReservation struct {
 
 // IsExpired checks if the reservation has passed its expiration time
 func (r *StockReservation) IsExpired() bool {
-	return time.Now().After(r.ExpiresAt)
+	return r.ExpiresAt != nil && time.Now().After(*r.ExpiresAt)
 }
 
 // IsActive checks if reservation is still holding stock
@@ -43,12 +49,12 @@ func (r *StockReservation) IsActive() bool {
 	return r.Status == ReservationReserved && !r.IsExpired()
 }
 
-// TimeRemaining returns duration until expiration
+// TimeRemaining returns duration until expiration (0 for holds without a deadline)
 func (r *StockReservation) TimeRemaining() time.Duration {
-	if r.IsExpired() {
+	if r.ExpiresAt == nil || r.IsExpired() {
 		return 0
 	}
-	return time.Until(r.ExpiresAt)
+	return time.Until(*r.ExpiresAt)
 }
 
 // ReservationConfig holds settings for the reservation system
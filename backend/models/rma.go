@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// ============================================
+// RETURNS / RMA MODULE
+// ============================================
+
+// ReturnRequest is a customer's request to send items of an order back (damaged statue, wrong item, ...).
+// Flow: requested -> authorized -> in_transit -> received -> inspected -> completed (or rejected / cancelled)
+type ReturnRequest struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	RMANumber string `gorm:"size:50;unique;not null" json:"rma_number"` // RMA/2026/00001
+	OrderID   uint   `gorm:"index;not null" json:"order_id"`
+	Order     Order  `json:"order,omitempty"`
+	UserID    uint   `gorm:"index;not null" json:"user_id"`
+	User      User   `json:"user,omitempty"`
+	Status    string `gorm:"size:30;default:'requested';index" json:"status"`
+
+	Reason         string         `gorm:"size:50" json:"reason"` // damaged, defective, wrong_item, not_as_described, other
+	CustomerNote   string         `gorm:"type:text" json:"customer_note"`
+	Photos         datatypes.JSON `json:"photos"` // ["/uploads/returns/..."]
+	ResolutionNote string         `gorm:"type:text" json:"resolution_note"`
+
+	// Return shipment (customer -> warehouse)
+	ReturnCarrier        string     `gorm:"size:100" json:"return_carrier"`
+	ReturnTrackingNumber string     `gorm:"size:100" json:"return_tracking_number"`
+	ReturnShippedAt      *time.Time `json:"return_shipped_at"`
+	ReceivedAt           *time.Time `json:"received_at"`
+
+	// Settlement
+	RefundMethod string       `gorm:"size:20" json:"refund_method"` // wallet, gateway, manual
+	RefundAmount float64      `gorm:"type:decimal(20,2);default:0" json:"refund_amount"`
+	AuthorizedBy *uint        `json:"authorized_by"`
+	AuthorizedAt *time.Time   `json:"authorized_at"`
+	InspectedBy  *uint        `json:"inspected_by"`
+	InspectedAt  *time.Time   `json:"inspected_at"`
+	ApprovedBy   *uint        `json:"approved_by"`
+	ApprovedAt   *time.Time   `json:"approved_at"`
+	RejectedAt   *time.Time   `json:"rejected_at"`
+	PaymentTxID  *uint        `json:"payment_tx_id"` // Gateway refunds: the refund PaymentTransaction
+	Items        []ReturnItem `json:"items"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReturnItem is one order line (or part of it) being returned, with the warehouse's inspection result
+type ReturnItem struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	ReturnRequestID uint      `gorm:"index;not null" json:"return_request_id"`
+	OrderItemID     uint      `gorm:"index;not null" json:"order_item_id"`
+	OrderItem       OrderItem `json:"order_item,omitempty"`
+	Quantity        int       `json:"quantity"`
+	Condition       string    `gorm:"size:50" json:"condition"` // Customer's description: damaged, defective, ...
+
+	// Inspection
+	Resolution     string  `gorm:"size:30" json:"resolution"` // restock, write_off, return_to_supplier
+	InspectionNote string  `gorm:"type:text" json:"inspection_note"`
+	RefundAmount   float64 `gorm:"type:decimal(20,2);default:0" json:"refund_amount"`
+}
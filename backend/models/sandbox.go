@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SandboxCharge is the upstream ledger of the built-in sandbox payment gateway.
+// It plays the provider's side so charges, callbacks and refunds can be exercised locally.
+type SandboxCharge struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	GatewayRef     string     `gorm:"size:40;uniqueIndex;not null" json:"gateway_ref"`
+	MerchantRefNo  string     `gorm:"size:100;index;not null" json:"merchant_ref_no"`
+	InvoiceNumber  string     `gorm:"size:50" json:"invoice_number"`
+	Method         string     `gorm:"size:20" json:"method"` // VA, QRIS, CC
+	Amount         float64    `gorm:"type:decimal(20,2);not null" json:"amount"`
+	Status         string     `gorm:"size:20;default:'pending'" json:"status"` // pending, paid, failed
+	RefundedAmount float64    `gorm:"type:decimal(20,2);default:0" json:"refunded_amount"`
+	CallbackURL    string     `gorm:"size:255" json:"callback_url"`
+	PaidAt         *time.Time `json:"paid_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
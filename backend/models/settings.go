@@ -30,3 +30,13 @@ type ShippingRate struct {
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
+
+// NumberSequence is the running counter behind document numbers (orders, invoices, POs, journals).
+// One row per type and period; the period key is empty when the counter never resets.
+type NumberSequence struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Type      string    `gorm:"size:50;not null;uniqueIndex:idx_sequence_type_period" json:"type"`
+	Period    string    `gorm:"size:10;not null;default:'';uniqueIndex:idx_sequence_type_period" json:"period"` // 2026, 202610 or ''
+	LastValue int64     `gorm:"not null;default:0" json:"last_value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// ============================================
+// SHIPMENTS (partial / multi-parcel fulfilment)
+// ============================================
+
+// Shipment is one parcel of an order: a subset of its lines with its own courier booking and waybill.
+// Ready items can leave now and PO items follow in a later shipment once they arrive.
+type Shipment struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	OrderID         uint           `gorm:"index;not null" json:"order_id"`
+	ShipmentNumber  string         `gorm:"size:60;unique;not null" json:"shipment_number"` // <order number>-S1, -S2, ...
+	Status          string         `gorm:"size:30;default:'shipped';index" json:"status"`  // shipped, in_transit, delivered, pending_pickup, returned, cancelled
+	Carrier         string         `gorm:"size:100" json:"carrier"`
+	TrackingNumber  string         `gorm:"size:100;index" json:"tracking_number"`
+	BiteshipOrderID string         `gorm:"size:100;index" json:"biteship_order_id"`
+	CourierStatus   string         `gorm:"size:50" json:"courier_status"`                      // Last raw status from Biteship
+	ShippingCost    float64        `gorm:"type:decimal(20,2);default:0" json:"shipping_cost"`  // Actual courier price (Biteship order.price)
+	RevenueAmount   float64        `gorm:"type:decimal(20,2);default:0" json:"revenue_amount"` // Revenue recognised by this shipment's journal
+	COGSAmount      float64        `gorm:"type:decimal(20,2);default:0" json:"cogs_amount"`
+	Items           []ShipmentItem `json:"items"`
+	ShippedBy       uint           `json:"shipped_by"`
+	ShippedAt       time.Time      `json:"shipped_at"`
+	DeliveredAt     *time.Time     `json:"delivered_at"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// ShipmentItem is the quantity of an order line packed in a shipment
+type ShipmentItem struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ShipmentID  uint      `gorm:"index;not null" json:"shipment_id"`
+	OrderItemID uint      `gorm:"index;not null" json:"order_item_id"`
+	OrderItem   OrderItem `json:"order_item,omitempty"`
+	Quantity    int       `json:"quantity"`
+}
+
+// IsActive reports whether the shipment still accounts for its items (cancelled parcels free them again)
+func (s *Shipment) IsActive() bool {
+	return s.Status != "cancelled"
+}
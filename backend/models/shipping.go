@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/datatypes"
 )
 
 // ============================================
@@ -38,14 +40,15 @@ type CarrierService struct {
 
 // ShippingZone represents a geographic region for shipping rates.
 type ShippingZone struct {
-	ID          uint             `gorm:"primaryKey" json:"id"`
-	Name        string           `gorm:"size:100;not null" json:"name"` // "Asia", "North America", "Rest of World"
-	Countries   string           `gorm:"type:text" json:"countries"`    // JSON Array: '["MY", "SG", "US"]'
-	PostalCodes string           `gorm:"type:text" json:"postal_codes"` // '10001, 10002, 20000-29999'
-	IsActive    bool             `gorm:"default:true" json:"is_active"`
-	Methods     []ShippingMethod `gorm:"foreignKey:ZoneID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"methods"`
-	CreatedAt   time.Time        `json:"created_at"`
-	UpdatedAt   time.Time        `json:"updated_at"`
+	ID           uint             `gorm:"primaryKey" json:"id"`
+	Name         string           `gorm:"size:100;not null" json:"name"` // "Asia", "North America", "Rest of World"
+	Countries    string           `gorm:"type:text" json:"countries"`    // JSON Array: '["MY", "SG", "US"]'
+	PostalCodes  string           `gorm:"type:text" json:"postal_codes"` // '10001, 10002, 20000-29999'
+	IsActive     bool             `gorm:"default:true" json:"is_active"`
+	TaxZeroRated bool             `gorm:"default:false" json:"tax_zero_rated"` // Exports: PPN 0% for shipments to this zone
+	Methods      []ShippingMethod `gorm:"foreignKey:ZoneID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"methods"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
 }
 
 // ShippingMethod provides the rule to calculate the cost.
@@ -64,3 +67,42 @@ type ShippingMethod struct {
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
 }
+
+// ============================================
+// SERVER-SIDE SHIPPING QUOTES
+// ============================================
+
+// ShippingQuote locks a shipping price (and the item prices) for a specific cart and
+// destination. Checkout only accepts a QuoteID; the cost is never taken from the client.
+type ShippingQuote struct {
+	ID             uint           `gorm:"primaryKey" json:"-"`
+	QuoteID        string         `gorm:"size:64;uniqueIndex;not null" json:"quote_id"`
+	UserID         uint           `gorm:"index;not null" json:"-"`
+	Country        string         `gorm:"size:10" json:"country"` // ISO code
+	PostalCode     string         `gorm:"size:20" json:"postal_code"`
+	TotalWeight    float64        `gorm:"type:decimal(10,3)" json:"total_weight"` // KG, recomputed from products
+	Subtotal       float64        `gorm:"type:decimal(15,2)" json:"subtotal"`
+	OptionID       string         `gorm:"size:100" json:"option_id"` // ShippingOption.ID chosen by customer
+	ShippingMethod string         `gorm:"size:150" json:"shipping_method"`
+	ShippingCost   float64        `gorm:"type:decimal(15,2)" json:"shipping_cost"`
+	LockedItems    datatypes.JSON `json:"items"`            // []QuotedItem - prices locked at quote time
+	Signature      string         `gorm:"size:64" json:"-"` // HMAC-SHA256 over the quote fields
+	ExpiresAt      time.Time      `json:"expires_at"`
+	UsedAt         *time.Time     `json:"used_at"`
+	OrderID        *uint          `json:"order_id"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// QuotedItem is one cart line inside a ShippingQuote
+type QuotedItem struct {
+	ProductID uint    `json:"product_id"`
+	VariantID *uint   `json:"variant_id,omitempty"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+	Weight    float64 `json:"weight"` // KG per unit
+}
+
+// IsExpired checks if the quote can no longer be used
+func (q *ShippingQuote) IsExpired() bool {
+	return time.Now().After(q.ExpiresAt)
+}
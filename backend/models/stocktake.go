@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+)
+
+// ============================================
+// STOCK TAKE (cycle count / stock opname)
+// ============================================
+
+// Stock take statuses
+const (
+	StockTakeCounting  = "counting"
+	StockTakeSubmitted = "submitted"
+	StockTakeApproved  = "approved"
+	StockTakeCancelled = "cancelled"
+)
+
+// StockTake - a count of one location. Expected quantities and unit costs are frozen when the session
+// opens; approval books each line's variance as an adjustment on top of whatever moved since.
+type StockTake struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	Number         string          `gorm:"size:50;unique;not null" json:"number"` // SO/2026/10/0001
+	LocationID     uint            `gorm:"index;not null" json:"location_id"`
+	Location       *Location       `json:"location,omitempty"`
+	CategoryID     *uint           `json:"category_id"` // Partial count: only this category was frozen
+	BrandID        *uint           `json:"brand_id"`
+	Status         string          `gorm:"size:20;default:'counting';index" json:"status"` // counting, submitted, approved, cancelled
+	Notes          string          `gorm:"type:text" json:"notes"`
+	FrozenAt       time.Time       `json:"frozen_at"`
+	VarianceQty    int             `json:"variance_qty"`                                       // Net units over (+) / short (-)
+	VarianceValue  float64         `gorm:"type:decimal(20,2);default:0" json:"variance_value"` // Net at frozen unit cost
+	JournalEntryID *uint           `json:"journal_entry_id"`
+	RejectReason   string          `gorm:"type:text" json:"reject_reason"`
+	Items          []StockTakeItem `json:"items,omitempty"`
+	CreatedBy      uint            `json:"created_by"`
+	SubmittedBy    *uint           `json:"submitted_by"`
+	SubmittedAt    *time.Time      `json:"submitted_at"`
+	ApprovedBy     *uint           `json:"approved_by"`
+	ApprovedAt     *time.Time      `json:"approved_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// StockTakeItem - one product/edition on the count sheet. CountedQty stays nil until it is scanned
+// or entered; uncounted lines post no variance.
+type StockTakeItem struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	StockTakeID   uint            `gorm:"index;not null" json:"stock_take_id"`
+	ProductID     uint            `gorm:"not null" json:"product_id"`
+	Product       *Product        `json:"product,omitempty"`
+	VariantID     *uint           `json:"variant_id"`
+	Variant       *ProductVariant `json:"variant,omitempty"`
+	ExpectedQty   int             `json:"expected_qty"` // Frozen when the session opened
+	CountedQty    *int            `json:"counted_qty"`
+	Variance      int             `json:"variance"`                                      // Counted - expected
+	UnitCost      float64         `gorm:"type:decimal(20,2);default:0" json:"unit_cost"` // Frozen with the expected quantity
+	VarianceValue float64         `gorm:"type:decimal(20,2);default:0" json:"variance_value"`
+	CountedBy     *uint           `json:"counted_by"`
+	CountedAt     *time.Time      `json:"counted_at"`
+}
+
+// SetCount records a count and derives the variance
+func (i *StockTakeItem) SetCount(qty int, userID uint) {
+	now := time.Now()
+	i.CountedQty = &qty
+	i.Variance = qty - i.ExpectedQty
+	i.VarianceValue = float64(i.Variance) * i.UnitCost
+	i.CountedBy = &userID
+	i.CountedAt = &now
+}
@@ -9,25 +9,30 @@ import (
 // ============================================
 
 type Supplier struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"size:100;not null" json:"name"`
-	Contact   string    `gorm:"size:100" json:"contact"`
-	Email     string    `gorm:"size:255" json:"email"`
-	Phone     string    `gorm:"size:20" json:"phone"`
-	Address   string    `gorm:"type:text" json:"address"`
-	Active    bool      `gorm:"default:true" json:"active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"size:100;not null" json:"name"`
+	Contact      string    `gorm:"size:100" json:"contact"`
+	Email        string    `gorm:"size:255" json:"email"`
+	Phone        string    `gorm:"size:20" json:"phone"`
+	Address      string    `gorm:"type:text" json:"address"`
+	Active       bool      `gorm:"default:true" json:"active"`
+	TermDays     int       `gorm:"default:30" json:"term_days"`      // Payment terms: bill due date = bill date + TermDays
+	LeadTimeDays int       `gorm:"default:14" json:"lead_time_days"` // Days from ordering to goods on the shelf
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
-// ProductSupplier links products to suppliers with specific costs
+// ProductSupplier links products to suppliers with specific costs. Reorder suggestions buy from
+// the primary supplier (or the cheapest one when none is primary).
 type ProductSupplier struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	ProductID  uint      `json:"product_id"`
-	SupplierID uint      `json:"supplier_id"`
-	Supplier   Supplier  `json:"supplier,omitempty"`
-	Cost       float64   `gorm:"type:decimal(15,2)" json:"cost"`
-	IsPrimary  bool      `gorm:"default:false" json:"is_primary"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ProductID    uint      `gorm:"index" json:"product_id"`
+	SupplierID   uint      `json:"supplier_id"`
+	Supplier     Supplier  `json:"supplier,omitempty"`
+	Cost         float64   `gorm:"type:decimal(15,2)" json:"cost"`
+	IsPrimary    bool      `gorm:"default:false" json:"is_primary"`
+	LeadTimeDays int       `gorm:"default:0" json:"lead_time_days"` // 0 = the supplier's lead time
+	MinOrderQty  int       `gorm:"default:0" json:"min_order_qty"`  // Suggestions are raised to at least this
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -14,14 +14,20 @@ const (
 	RoleUser       = "user" // Regular customer
 )
 
+// UserStatusGuest marks the account behind web guest checkouts: one per verified email, it owns
+// the guest's orders and invoices but cannot log in until it is claimed.
+const UserStatusGuest = "guest"
+
 type Role struct {
 	ID          uint         `gorm:"primaryKey" json:"id"`
 	Name        string       `gorm:"size:50;unique;not null" json:"name"` // Super Admin, Product Admin, etc.
 	Slug        string       `gorm:"size:50;unique;not null" json:"slug"` // super_admin, product_admin
 	Description string       `gorm:"size:255" json:"description"`
 	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions"`
-	CreatedAt   time.Time    `json:"created_at"`
-	UpdatedAt   time.Time    `json:"updated_at"`
+	// Largest PO total the role may approve; 0 = none (super admin: unlimited)
+	POApprovalLimit float64   `gorm:"type:decimal(20,2);default:0" json:"po_approval_limit"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
 }
 
 type Permission struct {
@@ -40,7 +46,7 @@ type User struct {
 	Password    string    `gorm:"size:255;not null" json:"-"`
 	FullName    string    `gorm:"size:255" json:"full_name"`
 	Phone       string    `gorm:"size:20" json:"phone"`
-	Status      string    `gorm:"size:20;default:'active'" json:"status"` // active, inactive
+	Status      string    `gorm:"size:20;default:'active'" json:"status"` // active, inactive, pending, guest
 	GoogleID    *string   `gorm:"size:255;unique;index" json:"google_id"` // OAuth Provider ID
 	RoleID      uint      `json:"role_id"`
 	Role        Role      `gorm:"foreignKey:RoleID" json:"role"`
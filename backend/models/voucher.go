@@ -65,11 +65,48 @@ type VoucherUsage struct {
 
 // VoucherProduct - Product restrictions for a voucher
 type VoucherProduct struct {
-	ID        uint    `gorm:"primaryKey" json:"id"`
-	VoucherID uint    `gorm:"index;not null" json:"voucher_id"`
-	ProductID uint    `gorm:"index;not null" json:"product_id"`
-	Product   Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
-	Type      string  `gorm:"size:10;default:'include'" json:"type"` // include, exclude
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	VoucherID uint            `gorm:"index;not null" json:"voucher_id"`
+	ProductID uint            `gorm:"index;not null" json:"product_id"`
+	Product   Product         `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	VariantID *uint           `gorm:"index" json:"variant_id"` // Restrict to one edition; nil = every edition of the product
+	Variant   *ProductVariant `gorm:"foreignKey:VariantID" json:"variant,omitempty"`
+	Type      string          `gorm:"size:10;default:'include'" json:"type"` // include, exclude
+}
+
+// VoucherLine is a cart line (product + optional edition) checked against product restrictions
+type VoucherLine struct {
+	ProductID uint  `json:"product_id"`
+	VariantID *uint `json:"variant_id"`
+}
+
+// Matches reports whether a cart line (product + optional edition) falls under this restriction
+func (r VoucherProduct) Matches(productID uint, variantID *uint) bool {
+	if r.ProductID != productID {
+		return false
+	}
+	if r.VariantID == nil {
+		return true
+	}
+	return variantID != nil && *variantID == *r.VariantID
+}
+
+// ProductRestrictionsMet reports whether at least one cart line is covered by the voucher's
+// "include" product restrictions. Vouchers without product restrictions apply to any cart.
+func ProductRestrictionsMet(restricts []VoucherProduct, lines []VoucherLine) bool {
+	hasInclude := false
+	for _, restrict := range restricts {
+		if restrict.Type != "include" {
+			continue
+		}
+		hasInclude = true
+		for _, line := range lines {
+			if restrict.Matches(line.ProductID, line.VariantID) {
+				return true
+			}
+		}
+	}
+	return !hasInclude
 }
 
 // VoucherCategory - Category restrictions for a voucher
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Webhook inbox statuses. Flow: received -> processing -> processed | failed (retried with backoff) | dead | rejected
+const (
+	WebhookStatusReceived   = "received"
+	WebhookStatusProcessing = "processing"
+	WebhookStatusProcessed  = "processed"
+	WebhookStatusFailed     = "failed"   // Will be retried at NextAttemptAt
+	WebhookStatusDead       = "dead"     // Retries exhausted, needs an admin replay
+	WebhookStatusRejected   = "rejected" // Bad signature or unreadable payload, never retried automatically
+)
+
+// WebhookEvent is one inbound callback (payment gateway, courier) stored before it is processed.
+// Provider + EventKey is unique, so a callback the provider retries is only processed once.
+type WebhookEvent struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	Provider      string         `gorm:"size:50;not null;uniqueIndex:idx_webhook_provider_key" json:"provider"` // prismalink, sandbox, biteship
+	EventKey      string         `gorm:"size:191;not null;uniqueIndex:idx_webhook_provider_key" json:"event_key"`
+	EventType     string         `gorm:"size:50" json:"event_type"`
+	Headers       datatypes.JSON `json:"headers"`
+	Payload       string         `gorm:"type:text" json:"payload"` // Raw body exactly as received
+	Status        string         `gorm:"size:20;default:'received';index" json:"status"`
+	Attempts      int            `gorm:"default:0" json:"attempts"`
+	NextAttemptAt *time.Time     `gorm:"index" json:"next_attempt_at"`
+	LastError     string         `gorm:"type:text" json:"last_error"`
+	Result        string         `gorm:"size:255" json:"result"` // Outcome note of the last successful run
+	DuplicateHits int            `gorm:"default:0" json:"duplicate_hits"`
+	ReceivedAt    time.Time      `json:"received_at"`
+	ProcessedAt   *time.Time     `json:"processed_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
@@ -5,6 +5,7 @@ import (
 
 	"forzashop/backend/controllers"
 	"forzashop/backend/middleware"
+	"forzashop/backend/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -48,9 +49,61 @@ func SetupRouter() *gin.Engine {
 		api.POST("/auth/verify-registration", middleware.StrictRateLimitMiddleware(), controllers.VerifyRegistration)
 		api.POST("/auth/resend-verification", middleware.StrictRateLimitMiddleware(), controllers.ResendVerification)
 
+		// ============================================
+		// GUEST CHECKOUT (No account required)
+		// ============================================
+		guest := api.Group("/guest")
+		{
+			guest.POST("/verify-email", middleware.StrictRateLimitMiddleware(), controllers.RequestGuestVerification)
+			guest.POST("/verify-email/confirm", middleware.StrictRateLimitMiddleware(), controllers.VerifyGuestEmail)
+			guest.POST("/claim/request", middleware.StrictRateLimitMiddleware(), controllers.RequestGuestClaim)
+			guest.POST("/claim", middleware.StrictRateLimitMiddleware(), controllers.ClaimGuestAccount)
+
+			// Verified email (guest checkout token)
+			guestCheckout := guest.Group("/checkout")
+			guestCheckout.Use(middleware.GuestCheckoutMiddleware())
+			{
+				guestCheckout.POST("", middleware.StrictRateLimitMiddleware(), controllers.Checkout)
+				guestCheckout.POST("/shipping-options", controllers.GetShippingOptions)
+				guestCheckout.POST("/shipping-quote", controllers.CreateShippingQuote)
+				guestCheckout.POST("/check-availability", controllers.CheckStockAvailability)
+			}
+
+			// Signed order access link (tracking & invoice download)
+			guestOrders := guest.Group("/orders/:id")
+			guestOrders.Use(middleware.GuestOrderAccessMiddleware())
+			{
+				guestOrders.GET("", controllers.GetCustomerOrderDetail)
+				guestOrders.GET("/tracking", controllers.GetOrderTracking)
+				guestOrders.GET("/invoices", controllers.GetCustomerOrderInvoices)
+				guestOrders.GET("/invoices/:invoice_id/pdf", controllers.GetOrderInvoiceForDownload)
+			}
+		}
+
+		// Supplier PO link (signed token from the PO email)
+		supplierPO := api.Group("/supplier/po/:token")
+		{
+			supplierPO.GET("", controllers.GetSupplierPO)
+			supplierPO.GET("/pdf", controllers.GetSupplierPOPDF)
+			supplierPO.POST("/acknowledge", middleware.StrictRateLimitMiddleware(), controllers.AcknowledgeSupplierPO)
+		}
+
 		// Webhooks & Callbacks
-		// Use a single standardized webhook endpoint
-		api.POST("/webhooks/prismalink", controllers.HandlePrismalinkWebhook)
+		// One webhook endpoint per registered payment gateway (/webhooks/prismalink, /webhooks/sandbox, ...)
+		for _, name := range services.PaymentGatewayNames() {
+			api.POST("/webhooks/"+name, controllers.HandlePaymentWebhook(name))
+		}
+		api.GET("/payments/methods", controllers.GetPaymentMethods)
+
+		// Sandbox payment gateway (only answers while payment_sandbox_enabled = true). These routes settle
+		// charges without paying, so release builds never mount them; :ref is the charge's merchant_ref_no.
+		if gin.Mode() != gin.ReleaseMode {
+			sandbox := api.Group("/sandbox-gateway")
+			{
+				sandbox.POST("/charges/:ref/simulate", controllers.SimulateSandboxPayment)
+				sandbox.GET("/charges/:ref/pay", controllers.PaySandboxCharge)
+			}
+		}
 
 		// Public Products
 		api.GET("/products", controllers.GetProducts)
@@ -114,6 +167,7 @@ func SetupRouter() *gin.Engine {
 				roles.DELETE("/:id", middleware.CheckPermission("role.manage"), controllers.DeleteRole)
 				roles.GET("/permissions", middleware.CheckPermission("role.view"), controllers.GetPermissions)
 				roles.PUT("/:id/permissions", middleware.CheckPermission("role.manage"), controllers.UpdateRolePermissions)
+				roles.PUT("/:id/po-approval-limit", middleware.CheckPermission("role.manage"), controllers.UpdateRolePOApprovalLimit)
 			}
 
 			// ============================================
@@ -130,6 +184,11 @@ func SetupRouter() *gin.Engine {
 				products.PUT("/:id", middleware.CheckPermission("product.edit"), controllers.UpdateProduct)
 				products.POST("/:id/arrive", middleware.CheckPermission("product.edit"), controllers.MarkPOArrived)
 				products.DELETE("/:id", middleware.CheckPermission("product.delete"), controllers.DeleteProduct)
+				// Variants (Editions)
+				products.GET("/:id/variants", middleware.CheckPermission("product.view"), controllers.GetProductVariants)
+				products.POST("/:id/variants", middleware.CheckPermission("product.edit"), controllers.CreateProductVariant)
+				products.PUT("/:id/variants/:variantId", middleware.CheckPermission("product.edit"), controllers.UpdateProductVariant)
+				products.DELETE("/:id/variants/:variantId", middleware.CheckPermission("product.edit"), controllers.DeleteProductVariant)
 				// Upsell Management
 				products.GET("/:id/upsells", middleware.CheckPermission("product.view"), controllers.GetUpsells)
 				products.PUT("/:id/upsells", middleware.CheckPermission("product.edit"), controllers.SetUpsells)
@@ -230,7 +289,11 @@ func SetupRouter() *gin.Engine {
 				orders.POST("", middleware.CheckPermission("order.manage"), controllers.CreateAdminOrder) // New POS/Manual Order
 				orders.GET("/:id", middleware.CheckPermission("order.view"), controllers.GetOrder)
 				orders.PUT("/:id/status", middleware.CheckPermission("order.edit"), controllers.UpdateOrderStatus)
+				orders.PUT("/:id/items", middleware.CheckPermission("order.edit"), controllers.EditOrderItems)
 				orders.POST("/:id/ship", middleware.CheckPermission("order.fulfill"), controllers.ShipOrder)
+				orders.GET("/:id/shipments", middleware.CheckPermission("order.view"), controllers.GetOrderShipments)
+				orders.POST("/:id/shipments", middleware.CheckPermission("order.fulfill"), controllers.CreateOrderShipment)
+				orders.POST("/:id/shipments/:shipmentId/cancel", middleware.CheckPermission("order.fulfill"), controllers.CancelOrderShipment)
 				orders.POST("/:id/cancel", middleware.CheckPermission("order.cancel_refund"), controllers.CancelOrder)
 				orders.POST("/:id/mark-arrived", middleware.CheckPermission("order.edit"), controllers.MarkOrderArrived)       // Renamed from MarkPOArrived
 				orders.POST("/:id/force-cancel", middleware.CheckPermission("order.cancel_refund"), controllers.ForceCancelPO) // NEW: Force Cancel
@@ -241,6 +304,36 @@ func SetupRouter() *gin.Engine {
 				orders.GET("/:id/biteship", middleware.CheckPermission("order.view"), controllers.GetBiteshipOrderInfo)
 			}
 
+			// WEBHOOK INBOX
+			webhooks := admin.Group("/webhooks")
+			{
+				webhooks.GET("", middleware.CheckPermission("settings.view"), controllers.GetWebhookEvents)
+				webhooks.GET("/:id", middleware.CheckPermission("settings.view"), controllers.GetWebhookEvent)
+				webhooks.POST("/:id/replay", middleware.CheckPermission("settings.system.manage"), controllers.ReplayWebhookEvent)
+			}
+
+			// REFUNDS
+			refunds := admin.Group("/refunds")
+			{
+				refunds.GET("", middleware.CheckPermission("order.view"), controllers.GetRefunds)
+				refunds.GET("/:id", middleware.CheckPermission("order.view"), controllers.GetRefund)
+				refunds.POST("/:id/retry", middleware.CheckPermission("order.cancel_refund"), controllers.RetryRefund)
+				refunds.POST("/:id/confirm", middleware.CheckPermission("order.cancel_refund"), controllers.ConfirmRefund)
+				refunds.POST("/:id/fail", middleware.CheckPermission("order.cancel_refund"), controllers.FailRefund)
+			}
+
+			// RETURNS / RMA
+			returns := admin.Group("/returns")
+			{
+				returns.GET("", middleware.CheckPermission("order.view"), controllers.GetReturns)
+				returns.GET("/:id", middleware.CheckPermission("order.view"), controllers.GetReturn)
+				returns.POST("/:id/authorize", middleware.CheckPermission("order.cancel_refund"), controllers.AuthorizeReturn)
+				returns.POST("/:id/reject", middleware.CheckPermission("order.cancel_refund"), controllers.RejectReturn)
+				returns.POST("/:id/receive", middleware.CheckPermission("order.fulfill"), controllers.ReceiveReturn)
+				returns.POST("/:id/inspect", middleware.CheckPermission("order.fulfill"), controllers.InspectReturn)
+				returns.POST("/:id/approve", middleware.CheckPermission("order.cancel_refund"), controllers.ApproveReturn)
+			}
+
 			// ============================================
 			// POS MODULE (Point of Sale)
 			// ============================================
@@ -279,6 +372,14 @@ func SetupRouter() *gin.Engine {
 				finance.GET("/journals", middleware.CheckPermission("finance.view"), controllers.GetJournalEntries)
 				finance.GET("/journals/:id", middleware.CheckPermission("finance.view"), controllers.GetJournalEntry)
 				finance.POST("/journals", middleware.CheckPermission("finance.manage"), controllers.CreateJournalEntry)
+				finance.POST("/journals/:id/reverse", middleware.CheckPermission("finance.manage"), controllers.ReverseJournalEntry)
+
+				// Accounting Periods & Year-End Close
+				finance.GET("/periods", middleware.CheckPermission("finance.view"), controllers.GetAccountingPeriods)
+				finance.POST("/periods", middleware.CheckPermission("finance.period.manage"), controllers.OpenAccountingPeriod)
+				finance.POST("/periods/:id/close", middleware.CheckPermission("finance.period.manage"), controllers.CloseAccountingPeriod)
+				finance.POST("/periods/:id/lock", middleware.CheckPermission("finance.period.manage"), controllers.LockAccountingPeriod)
+				finance.POST("/periods/year/:year/close", middleware.CheckPermission("finance.period.manage"), controllers.CloseFiscalYear)
 
 				// Expenses (Full CRUD)
 				finance.GET("/expenses", middleware.CheckPermission("finance.view"), controllers.GetExpenses)
@@ -287,10 +388,43 @@ func SetupRouter() *gin.Engine {
 				finance.PUT("/expenses/:id", middleware.CheckPermission("finance.manage"), controllers.UpdateExpense)
 				finance.DELETE("/expenses/:id", middleware.CheckPermission("finance.manage"), controllers.DeleteExpense)
 
+				// Recurring Expenses
+				finance.GET("/recurring-expenses", middleware.CheckPermission("finance.view"), controllers.GetRecurringExpenses)
+				finance.POST("/recurring-expenses", middleware.CheckPermission("finance.manage"), controllers.CreateRecurringExpense)
+				finance.POST("/recurring-expenses/run", middleware.CheckPermission("finance.manage"), controllers.RunRecurringExpenses)
+				finance.PUT("/recurring-expenses/:id", middleware.CheckPermission("finance.manage"), controllers.UpdateRecurringExpense)
+				finance.DELETE("/recurring-expenses/:id", middleware.CheckPermission("finance.manage"), controllers.DeleteRecurringExpense)
+
 				// Stats & Reports
 				finance.GET("/stats", middleware.CheckPermission("finance.view"), controllers.GetFinanceStats)
 				finance.GET("/trend", middleware.CheckPermission("finance.view"), controllers.GetCashFlowTrend) // Added Trend Route
 				finance.GET("/reports/pnl", middleware.CheckPermission("finance.view"), controllers.GetProfitLossReport)
+				finance.GET("/reports/trial-balance", middleware.CheckPermission("finance.view"), controllers.GetTrialBalance)
+				finance.GET("/reports/balance-sheet", middleware.CheckPermission("finance.view"), controllers.GetBalanceSheet)
+				finance.GET("/reports/general-ledger", middleware.CheckPermission("finance.view"), controllers.GetGeneralLedger)
+				finance.GET("/reports/cash-flow", middleware.CheckPermission("finance.view"), controllers.GetCashFlowStatement)
+
+				// Accounts Payable
+				finance.GET("/payables/bills", middleware.CheckPermission("finance.view"), controllers.GetSupplierBills)
+				finance.GET("/payables/bills/:id", middleware.CheckPermission("finance.view"), controllers.GetSupplierBill)
+				finance.POST("/payables/bills", middleware.CheckPermission("finance.manage"), controllers.CreateSupplierBill)
+				finance.POST("/payables/bills/:id/payments", middleware.CheckPermission("finance.manage"), controllers.PaySupplierBill)
+				finance.GET("/payables/aging", middleware.CheckPermission("finance.view"), controllers.GetPayableAging)
+				finance.GET("/payables/suppliers/:id/statement", middleware.CheckPermission("finance.view"), controllers.GetSupplierStatement)
+
+				// Accounting Export
+				finance.GET("/exports/mappings", middleware.CheckPermission("finance.view"), controllers.GetExportMappings)
+				finance.PUT("/exports/mappings", middleware.CheckPermission("finance.manage"), controllers.SaveExportMappings)
+				finance.GET("/exports/:dataset", middleware.CheckPermission("finance.view"), controllers.ExportAccounting)
+
+				// Settlement / Bank Statement Reconciliation
+				finance.GET("/reconciliations", middleware.CheckPermission("finance.view"), controllers.GetSettlementBatches)
+				finance.GET("/reconciliations/lines", middleware.CheckPermission("finance.view"), controllers.GetSettlementLines)
+				finance.GET("/reconciliations/:id", middleware.CheckPermission("finance.view"), controllers.GetSettlementBatch)
+				finance.POST("/reconciliations/import", middleware.CheckPermission("finance.manage"), controllers.ImportSettlement)
+				finance.POST("/reconciliations/:id/confirm", middleware.CheckPermission("finance.manage"), controllers.ConfirmSettlement)
+				finance.POST("/reconciliations/lines/:id/match", middleware.CheckPermission("finance.manage"), controllers.MatchSettlementLine)
+				finance.POST("/reconciliations/lines/:id/ignore", middleware.CheckPermission("finance.manage"), controllers.IgnoreSettlementLine)
 			}
 
 			// ============================================
@@ -357,6 +491,8 @@ func SetupRouter() *gin.Engine {
 			{
 				// ✅ FIX: Added CheckPermission — previously any logged-in staff could view
 				reservations.GET("", middleware.CheckPermission("order.view"), controllers.GetReservationStatus)
+				reservations.GET("/reconcile", middleware.CheckPermission("product.view"), controllers.GetReservationDrift)
+				reservations.POST("/reconcile", middleware.CheckPermission("product.edit"), controllers.ReconcileReservations)
 			}
 
 			// ============================================
@@ -383,6 +519,51 @@ func SetupRouter() *gin.Engine {
 				procurement.PUT("/orders/:id", middleware.CheckPermission("procurement.manage"), controllers.UpdatePurchaseOrder)
 				procurement.DELETE("/orders/:id", middleware.CheckPermission("procurement.manage"), controllers.DeletePurchaseOrder)
 				procurement.POST("/orders/:id/receive", middleware.CheckPermission("procurement.manage"), controllers.ReceivePurchaseOrder)
+				procurement.GET("/orders/:id/receipts", middleware.CheckPermission("procurement.view"), controllers.GetPurchaseReceipts)
+				procurement.POST("/orders/approve", middleware.CheckPermission("procurement.approve"), controllers.ApprovePurchaseOrders)
+				procurement.GET("/orders/overdue", middleware.CheckPermission("procurement.view"), controllers.GetOverduePurchaseOrders)
+				procurement.POST("/orders/:id/submit", middleware.CheckPermission("procurement.manage"), controllers.SubmitPurchaseOrder)
+				procurement.POST("/orders/:id/approve", middleware.CheckPermission("procurement.approve"), controllers.ApprovePurchaseOrder)
+				procurement.POST("/orders/:id/reject", middleware.CheckPermission("procurement.approve"), controllers.RejectPurchaseOrder)
+				procurement.POST("/orders/:id/send", middleware.CheckPermission("procurement.manage"), controllers.SendPurchaseOrder)
+				procurement.POST("/orders/:id/cancel", middleware.CheckPermission("procurement.manage"), controllers.CancelPurchaseOrder)
+				procurement.PUT("/orders/:id/expected-date", middleware.CheckPermission("procurement.manage"), controllers.SetPurchaseOrderExpectedDate)
+				procurement.GET("/orders/:id/pdf", middleware.CheckPermission("procurement.view"), controllers.DownloadPurchaseOrderPDF)
+				procurement.GET("/reorder-suggestions", middleware.CheckPermission("procurement.view"), controllers.GetReorderSuggestions)
+				procurement.POST("/reorder-suggestions/drafts", middleware.CheckPermission("procurement.manage"), controllers.CreateReorderDrafts)
+				procurement.GET("/product-suppliers", middleware.CheckPermission("procurement.view"), controllers.GetProductSuppliers)
+				procurement.POST("/product-suppliers", middleware.CheckPermission("procurement.manage"), controllers.SaveProductSupplier)
+				procurement.DELETE("/product-suppliers/:id", middleware.CheckPermission("procurement.manage"), controllers.DeleteProductSupplier)
+			}
+
+			// ============================================
+			// INVENTORY MODULE (Locations & Transfers)
+			// ============================================
+			inventory := admin.Group("/inventory")
+			{
+				inventory.GET("/locations", middleware.CheckPermission("inventory.view"), controllers.GetLocations)
+				inventory.POST("/locations", middleware.CheckPermission("inventory.manage"), controllers.CreateLocation)
+				inventory.PUT("/locations/priority", middleware.CheckPermission("inventory.manage"), controllers.SetLocationPriority)
+				inventory.PUT("/locations/:id", middleware.CheckPermission("inventory.manage"), controllers.UpdateLocation)
+				inventory.DELETE("/locations/:id", middleware.CheckPermission("inventory.manage"), controllers.DeleteLocation)
+				inventory.PUT("/locations/:id/min-stock", middleware.CheckPermission("inventory.manage"), controllers.SetLocationMinStock)
+				inventory.GET("/levels", middleware.CheckPermission("inventory.view"), controllers.GetStockLevels)
+				inventory.POST("/levels/sync", middleware.CheckPermission("inventory.manage"), controllers.SyncStockLocations)
+				inventory.GET("/transfers", middleware.CheckPermission("inventory.view"), controllers.GetStockTransfers)
+				inventory.GET("/transfers/:id", middleware.CheckPermission("inventory.view"), controllers.GetStockTransfer)
+				inventory.POST("/transfers", middleware.CheckPermission("inventory.manage"), controllers.CreateStockTransfer)
+				inventory.POST("/transfers/:id/dispatch", middleware.CheckPermission("inventory.manage"), controllers.DispatchStockTransfer)
+				inventory.POST("/transfers/:id/receive", middleware.CheckPermission("inventory.manage"), controllers.ReceiveStockTransfer)
+				inventory.POST("/transfers/:id/cancel", middleware.CheckPermission("inventory.manage"), controllers.CancelStockTransfer)
+				inventory.GET("/stock-takes", middleware.CheckPermission("inventory.view"), controllers.GetStockTakes)
+				inventory.GET("/stock-takes/:id", middleware.CheckPermission("inventory.view"), controllers.GetStockTake)
+				inventory.POST("/stock-takes", middleware.CheckPermission("inventory.manage"), controllers.CreateStockTake)
+				inventory.POST("/stock-takes/:id/scan", middleware.CheckPermission("inventory.manage"), controllers.ScanStockTake)
+				inventory.PUT("/stock-takes/:id/items/:itemId", middleware.CheckPermission("inventory.manage"), controllers.SetStockTakeCount)
+				inventory.POST("/stock-takes/:id/submit", middleware.CheckPermission("inventory.manage"), controllers.SubmitStockTake)
+				inventory.POST("/stock-takes/:id/approve", middleware.CheckPermission("inventory.stocktake.approve"), controllers.ApproveStockTake)
+				inventory.POST("/stock-takes/:id/reject", middleware.CheckPermission("inventory.stocktake.approve"), controllers.RejectStockTake)
+				inventory.POST("/stock-takes/:id/cancel", middleware.CheckPermission("inventory.manage"), controllers.CancelStockTake)
 			}
 
 			// ============================================
@@ -519,8 +700,14 @@ func SetupRouter() *gin.Engine {
 			customer.GET("/orders/:id/tracking", controllers.GetOrderTracking)
 			customer.POST("/orders/:id/confirm", controllers.ConfirmOrderReceived)
 			customer.POST("/orders/:id/confirm-delivery", controllers.ConfirmDelivery)
+			customer.POST("/orders/:id/shipments/:shipmentId/confirm-delivery", controllers.ConfirmShipmentDelivery)
+			customer.GET("/orders/:id/returns", controllers.GetCustomerReturns)
+			customer.POST("/orders/:id/returns", middleware.StrictRateLimitMiddleware(), controllers.CreateReturnRequest)
+			customer.POST("/orders/:id/returns/:returnId/ship", controllers.ShipReturn)
+			customer.POST("/orders/:id/returns/:returnId/cancel", controllers.CancelReturnRequest)
 			customer.POST("/checkout", middleware.StrictRateLimitMiddleware(), controllers.Checkout)
 			customer.POST("/checkout/shipping-options", controllers.GetShippingOptions)
+			customer.POST("/checkout/shipping-quote", controllers.CreateShippingQuote)
 
 			// Invoices & Payments (Strict Limit)
 			customer.GET("/orders/:id/invoices", controllers.GetCustomerOrderInvoices)
@@ -532,6 +719,7 @@ func SetupRouter() *gin.Engine {
 			customer.GET("/payment/cards", controllers.GetSavedCards) // NEW: Saved Cards
 			customer.GET("/payment/:id/details", controllers.GetInvoiceDetails)
 			customer.POST("/payment/:id/generate", controllers.GeneratePaymentCode)
+			customer.POST("/payment/:id/bank-transfer", controllers.ChooseBankTransfer)
 			customer.POST("/payment/:id/submit-card", controllers.SubmitCreditCard) // CC Direct API Step 2
 			customer.GET("/payment/:id/status", controllers.CheckPaymentStatus)
 			customer.GET("/payment/:id/link", controllers.GetInvoicePaymentLink)
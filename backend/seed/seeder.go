@@ -144,6 +144,7 @@ func SeedPermissions() {
 		// FINANCE
 		{Name: "Lihat Keuangan", Slug: "finance.view"},
 		{Name: "Kelola Keuangan", Slug: "finance.manage"},
+		{Name: "Tutup Periode Akuntansi", Slug: "finance.period.manage"},
 		{Name: "Lihat Kartu Tersimpan", Slug: "finance.card.view"},
 		{Name: "Kelola Cicilan", Slug: "finance.installment.manage"},
 		{Name: "Proses Refund", Slug: "finance.refund.execute"},
@@ -184,6 +185,12 @@ func SeedPermissions() {
 		// PROCUREMENT
 		{Name: "Lihat Pengadaan (PO)", Slug: "procurement.view"},
 		{Name: "Kelola Pengadaan (PO)", Slug: "procurement.manage"},
+		{Name: "Setujui Purchase Order", Slug: "procurement.approve"},
+
+		// INVENTORY (Multi-lokasi)
+		{Name: "Lihat Stok per Lokasi", Slug: "inventory.view"},
+		{Name: "Kelola Lokasi & Transfer Stok", Slug: "inventory.manage"},
+		{Name: "Setujui Stock Opname", Slug: "inventory.stocktake.approve"},
 
 		// TAXONOMY
 		{Name: "Lihat Taksonomi", Slug: "taxonomy.view"},
@@ -557,15 +564,17 @@ func seedCOA() {
 		{Code: "1001", Name: "Kas Utama", Type: "ASSET", MappingKey: strPtr("CASH"), CanPost: true},
 		{Code: "1002", Name: "Bank BCA", Type: "ASSET", MappingKey: strPtr("PRIMARY_BANK"), CanPost: true},
 		{Code: "1003", Name: "Persediaan Barang", Type: "ASSET", MappingKey: strPtr("INVENTORY_ASSET"), CanPost: true},
+		{Code: "1004", Name: "Kliring Payment Gateway", Type: "ASSET", MappingKey: strPtr("GATEWAY_CLEARING"), CanPost: true},
 
 		// LIABILITIES (2xxx)
-		{Code: "2001", Name: "Hutang Usaha", Type: "LIABILITY", CanPost: true},
+		{Code: "2001", Name: "Hutang Usaha", Type: "LIABILITY", MappingKey: strPtr("ACCOUNTS_PAYABLE"), CanPost: true},
 		{Code: "2002", Name: "Titipan Pelanggan (PO)", Type: "LIABILITY", MappingKey: strPtr("CUSTOMER_DEPOSIT"), CanPost: true},
 		{Code: "2003", Name: "Saldo Dompet Pelanggan", Type: "LIABILITY", MappingKey: strPtr("WALLET_LIABILITY"), CanPost: true},
+		{Code: "2004", Name: "Hutang PPN Keluaran", Type: "LIABILITY", MappingKey: strPtr("TAX_PAYABLE"), CanPost: true},
 
 		// EQUITY (3xxx)
 		{Code: "3001", Name: "Modal Pemilik", Type: "EQUITY", CanPost: true},
-		{Code: "3002", Name: "Laba Ditahan", Type: "EQUITY", CanPost: true},
+		{Code: "3002", Name: "Laba Ditahan", Type: "EQUITY", MappingKey: strPtr("RETAINED_EARNINGS"), CanPost: true},
 
 		// REVENUE (4xxx)
 		{Code: "4001", Name: "Pendapatan Penjualan Retail", Type: "REVENUE", MappingKey: strPtr("RETAIL_REVENUE"), CanPost: true},
@@ -583,6 +592,8 @@ func seedCOA() {
 		{Code: "6005", Name: "Biaya Perlengkapan Packing", Type: "EXPENSE", CanPost: true},
 		{Code: "6006", Name: "Biaya Pengiriman (Ongkir Toko)", Type: "EXPENSE", CanPost: true},
 		{Code: "6007", Name: "Biaya Operasional Lainnya", Type: "EXPENSE", CanPost: true},
+		{Code: "6008", Name: "Biaya Payment Gateway & Bank", Type: "EXPENSE", MappingKey: strPtr("GATEWAY_FEE"), CanPost: true},
+		{Code: "6009", Name: "Selisih Persediaan (Stock Opname)", Type: "EXPENSE", MappingKey: strPtr("INVENTORY_SHRINKAGE"), CanPost: true},
 	}
 	for _, acc := range accounts {
 		config.DB.Create(&acc)
@@ -619,7 +630,74 @@ func seedCarriers() {
 		{Key: "bank_name", Value: "Bank Central Asia (BCA)", Group: "payment"},
 		{Key: "bank_account_number", Value: "123-456-7890", Group: "payment"},
 		{Key: "bank_account_name", Value: "PT Warung Forza Indonesia", Group: "payment"},
+		// Bank transfer: unique 3-digit code on the amount, mutation files auto-confirm matching transfers
+		{Key: "bank_transfer_unique_code", Value: "true", Group: "payment"},
+		{Key: "bank_transfer_auto_confirm", Value: "true", Group: "payment"},
+		{Key: "bank_mutation_dir", Value: "", Group: "payment"},
+		{Key: "bank_mutation_provider", Value: "bca", Group: "payment"},
 		{Key: "store_url", Value: "http://localhost:5173", Group: "system"},
+		{Key: "company_npwp", Value: "", Group: "tax"},
+		{Key: "tax_enabled", Value: "true", Group: "tax"},
+		{Key: "tax_ppn_rate", Value: "11", Group: "tax"},
+		{Key: "tax_price_inclusive", Value: "true", Group: "tax"},
+		{Key: "tax_shipping", Value: "false", Group: "tax"},
+		{Key: "tax_zero_rate_exports", Value: "true", Group: "tax"},
+		// Document numbering (helpers.NextSequenceNumber); reset: monthly, yearly, never
+		{Key: "numbering_order_prefix", Value: "FORZA", Group: "numbering"},
+		{Key: "numbering_order_reset", Value: "monthly", Group: "numbering"},
+		{Key: "numbering_order_separator", Value: "-", Group: "numbering"},
+		{Key: "numbering_order_padding", Value: "5", Group: "numbering"},
+		{Key: "numbering_pos_order_prefix", Value: "POS", Group: "numbering"},
+		{Key: "numbering_pos_order_reset", Value: "monthly", Group: "numbering"},
+		{Key: "numbering_pos_order_separator", Value: "-", Group: "numbering"},
+		{Key: "numbering_pos_order_padding", Value: "5", Group: "numbering"},
+		{Key: "numbering_invoice_prefix", Value: "INV", Group: "numbering"},
+		{Key: "numbering_invoice_reset", Value: "monthly", Group: "numbering"},
+		{Key: "numbering_invoice_separator", Value: "/", Group: "numbering"},
+		{Key: "numbering_invoice_padding", Value: "5", Group: "numbering"},
+		{Key: "numbering_purchase_order_prefix", Value: "PO", Group: "numbering"},
+		{Key: "numbering_purchase_order_reset", Value: "yearly", Group: "numbering"},
+		{Key: "numbering_purchase_order_separator", Value: "/", Group: "numbering"},
+		{Key: "numbering_purchase_order_padding", Value: "4", Group: "numbering"},
+		{Key: "numbering_journal_prefix", Value: "JRN", Group: "numbering"},
+		{Key: "numbering_journal_reset", Value: "monthly", Group: "numbering"},
+		{Key: "numbering_journal_separator", Value: "/", Group: "numbering"},
+		{Key: "numbering_journal_padding", Value: "5", Group: "numbering"},
+		{Key: "numbering_return_prefix", Value: "RMA", Group: "numbering"},
+		{Key: "numbering_return_reset", Value: "yearly", Group: "numbering"},
+		{Key: "numbering_return_separator", Value: "/", Group: "numbering"},
+		{Key: "numbering_return_padding", Value: "5", Group: "numbering"},
+		{Key: "numbering_supplier_bill_prefix", Value: "BILL", Group: "numbering"},
+		{Key: "numbering_supplier_bill_reset", Value: "yearly", Group: "numbering"},
+		{Key: "numbering_supplier_bill_separator", Value: "/", Group: "numbering"},
+		{Key: "numbering_supplier_bill_padding", Value: "4", Group: "numbering"},
+		{Key: "numbering_supplier_payment_prefix", Value: "PAY", Group: "numbering"},
+		{Key: "numbering_supplier_payment_reset", Value: "monthly", Group: "numbering"},
+		{Key: "numbering_supplier_payment_separator", Value: "/", Group: "numbering"},
+		{Key: "numbering_supplier_payment_padding", Value: "4", Group: "numbering"},
+		{Key: "numbering_goods_receipt_prefix", Value: "GRN", Group: "numbering"},
+		{Key: "numbering_goods_receipt_reset", Value: "monthly", Group: "numbering"},
+		{Key: "numbering_goods_receipt_separator", Value: "/", Group: "numbering"},
+		{Key: "numbering_goods_receipt_padding", Value: "4", Group: "numbering"},
+		{Key: "numbering_stock_transfer_prefix", Value: "TRF", Group: "numbering"},
+		{Key: "numbering_stock_transfer_reset", Value: "monthly", Group: "numbering"},
+		{Key: "numbering_stock_transfer_separator", Value: "/", Group: "numbering"},
+		{Key: "numbering_stock_transfer_padding", Value: "4", Group: "numbering"},
+		{Key: "numbering_stock_take_prefix", Value: "SO", Group: "numbering"},
+		{Key: "numbering_stock_take_reset", Value: "monthly", Group: "numbering"},
+		{Key: "numbering_stock_take_separator", Value: "/", Group: "numbering"},
+		{Key: "numbering_stock_take_padding", Value: "4", Group: "numbering"},
+		// Returns / RMA
+		{Key: "rma_window_days", Value: "14", Group: "returns"},
+		// Inventory: location code the POS tills sell from (empty = default location)
+		{Key: "pos_location_code", Value: "", Group: "inventory"},
+		// Inventory: reorder suggestions (sales window, safety stock, cover per order, lead time without a supplier)
+		{Key: "reorder_velocity_days", Value: "30", Group: "inventory"},
+		{Key: "reorder_safety_days", Value: "7", Group: "inventory"},
+		{Key: "reorder_review_days", Value: "30", Group: "inventory"},
+		{Key: "reorder_default_lead_days", Value: "14", Group: "inventory"},
+		// Procurement: how long the signed PO link emailed to suppliers stays valid
+		{Key: "po_supplier_link_days", Value: "60", Group: "procurement"},
 	}
 	for _, s := range bankCompanySettings {
 		config.DB.Where(models.Setting{Key: s.Key}).FirstOrCreate(&s)
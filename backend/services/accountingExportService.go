@@ -0,0 +1,569 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// ===============================================
+// ACCOUNTING EXPORT (CSV / XLSX / ACCURATE / JURNAL.ID)
+// Rows are read with a DB cursor and written as they arrive, so a full year never sits in memory.
+// ===============================================
+
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatXLSX = "xlsx"
+
+	ExportProfileStandard = "standard"
+	ExportProfileAccurate = "accurate"
+	ExportProfileJurnal   = "jurnal"
+)
+
+// ExportDatasets lists what can be exported
+var ExportDatasets = []string{"journals", "invoices", "expenses", "pnl"}
+
+// ExportFilter narrows an export; COAIDs is ignored for invoices
+type ExportFilter struct {
+	Period  ReportPeriod
+	COAIDs  []uint
+	Profile string // journals only: standard, accurate, jurnal
+}
+
+type AccountingExportService struct {
+	DB *gorm.DB
+}
+
+func NewAccountingExportService() *AccountingExportService {
+	return &AccountingExportService{
+		DB: config.DB,
+	}
+}
+
+// ---------------------------------------------------------
+// TABLE WRITERS
+// ---------------------------------------------------------
+
+// TableWriter receives a header and then rows; values are strings, float64, int or bool
+type TableWriter interface {
+	WriteHeader(cols []string) error
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// NewTableWriter returns a CSV or XLSX writer streaming into w
+func NewTableWriter(w io.Writer, format, sheet string) (TableWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return &csvTableWriter{w: csv.NewWriter(w)}, nil
+	case ExportFormatXLSX:
+		return newXLSXTableWriter(w, sheet)
+	}
+	return nil, fmt.Errorf("unsupported format %q (csv, xlsx)", format)
+}
+
+type csvTableWriter struct {
+	w    *csv.Writer
+	rows int
+}
+
+func (t *csvTableWriter) WriteHeader(cols []string) error {
+	return t.w.Write(cols)
+}
+
+func (t *csvTableWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch val := v.(type) {
+		case float64:
+			record[i] = strconv.FormatFloat(val, 'f', 2, 64)
+		default:
+			record[i] = fmt.Sprint(val)
+		}
+	}
+	if err := t.w.Write(record); err != nil {
+		return err
+	}
+	// Push data out to the client in chunks instead of buffering the whole file
+	t.rows++
+	if t.rows%500 == 0 {
+		t.w.Flush()
+	}
+	return t.w.Error()
+}
+
+func (t *csvTableWriter) Close() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// xlsxTableWriter uses excelize's stream writer, which spills to a temp file once the sheet grows large
+type xlsxTableWriter struct {
+	out  io.Writer
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	row  int
+	bold int
+}
+
+func newXLSXTableWriter(w io.Writer, sheet string) (*xlsxTableWriter, error) {
+	f := excelize.NewFile()
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		f.Close()
+		return nil, err
+	}
+	sw, err := f.NewStreamWriter(sheet)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	bold, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	return &xlsxTableWriter{out: w, file: f, sw: sw, bold: bold}, nil
+}
+
+func (t *xlsxTableWriter) WriteHeader(cols []string) error {
+	cells := make([]interface{}, len(cols))
+	for i, col := range cols {
+		cells[i] = excelize.Cell{StyleID: t.bold, Value: col}
+	}
+	return t.WriteRow(cells)
+}
+
+func (t *xlsxTableWriter) WriteRow(values []interface{}) error {
+	t.row++
+	cell, err := excelize.CoordinatesToCellName(1, t.row)
+	if err != nil {
+		return err
+	}
+	return t.sw.SetRow(cell, values)
+}
+
+func (t *xlsxTableWriter) Close() error {
+	defer t.file.Close()
+	if err := t.sw.Flush(); err != nil {
+		return err
+	}
+	return t.file.Write(t.out)
+}
+
+// ---------------------------------------------------------
+// EXPORT
+// ---------------------------------------------------------
+
+// Export writes one dataset into tw
+func (s *AccountingExportService) Export(dataset string, f ExportFilter, tw TableWriter) error {
+	switch dataset {
+	case "journals":
+		return s.exportJournals(f, tw)
+	case "invoices":
+		return s.exportInvoices(f, tw)
+	case "expenses":
+		return s.exportExpenses(f, tw)
+	case "pnl":
+		return s.exportProfitLoss(f, tw)
+	}
+	return fmt.Errorf("unknown dataset %q", dataset)
+}
+
+// ValidateExport checks the request before any bytes are sent, since errors can't be reported mid-stream
+func ValidateExport(dataset string, f ExportFilter) error {
+	known := false
+	for _, d := range ExportDatasets {
+		if d == dataset {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("unknown dataset %q (%s)", dataset, strings.Join(ExportDatasets, ", "))
+	}
+	if f.Period.From == nil {
+		return fmt.Errorf("from_date is required")
+	}
+	if dataset != "journals" && f.Profile != "" && f.Profile != ExportProfileStandard {
+		return fmt.Errorf("profile %q is only available for journals", f.Profile)
+	}
+	if _, ok := journalProfiles[f.Profile]; !ok && f.Profile != "" {
+		return fmt.Errorf("unknown profile %q (standard, accurate, jurnal)", f.Profile)
+	}
+	return nil
+}
+
+func (s *AccountingExportService) dateRange(query *gorm.DB, column string, p ReportPeriod) *gorm.DB {
+	return query.Where(column+" >= ? AND "+column+" < ?", *p.From, p.To.AddDate(0, 0, 1))
+}
+
+type journalExportLine struct {
+	EntryNumber   string
+	Date          time.Time
+	Description   string
+	ReferenceID   string
+	ReferenceType string
+	IsAdjusting   bool
+	ReversalOfID  *uint
+	COACode       string
+	COAName       string
+	COAType       string
+	Debit         float64
+	Credit        float64
+}
+
+// journalProfile is the column layout of one target; code is the account code after mapping
+type journalProfile struct {
+	header []string
+	row    func(l journalExportLine, code string) []interface{}
+}
+
+var journalProfiles = map[string]journalProfile{
+	ExportProfileStandard: {
+		header: []string{"Entry No", "Date", "Reference Type", "Reference ID", "Description", "Account Code", "Account Name", "Account Type", "Debit", "Credit", "Adjusting", "Reversal"},
+		row: func(l journalExportLine, code string) []interface{} {
+			return []interface{}{l.EntryNumber, l.Date.Format("2006-01-02"), l.ReferenceType, l.ReferenceID, l.Description, code, l.COAName, l.COAType, l.Debit, l.Credit, l.IsAdjusting, l.ReversalOfID != nil}
+		},
+	},
+	// Accurate Online: Jurnal Umum import template
+	ExportProfileAccurate: {
+		header: []string{"No. Bukti", "Tanggal", "Keterangan", "No. Akun", "Debit", "Kredit", "Catatan"},
+		row: func(l journalExportLine, code string) []interface{} {
+			return []interface{}{l.EntryNumber, l.Date.Format("02/01/2006"), l.Description, code, l.Debit, l.Credit, l.ReferenceType + " " + l.ReferenceID}
+		},
+	},
+	// Jurnal.id: Journal Entry import template
+	ExportProfileJurnal: {
+		header: []string{"*Transaction Date", "*Transaction No", "Memo", "*Account Code", "Description", "*Debit", "*Credit", "Tags"},
+		row: func(l journalExportLine, code string) []interface{} {
+			return []interface{}{l.Date.Format("02/01/2006"), l.EntryNumber, l.Description, code, l.ReferenceType + " " + l.ReferenceID, l.Debit, l.Credit, l.ReferenceType}
+		},
+	},
+}
+
+// exportJournals streams journal lines. The import profiles keep whole entries so each voucher stays balanced:
+// the COA filter then selects entries touching those accounts instead of single lines.
+func (s *AccountingExportService) exportJournals(f ExportFilter, tw TableWriter) error {
+	profileName := f.Profile
+	if profileName == "" {
+		profileName = ExportProfileStandard
+	}
+	profile := journalProfiles[profileName]
+
+	codes := map[string]string{}
+	if profileName != ExportProfileStandard {
+		codes = s.mappedCodes(profileName)
+	}
+
+	query := s.DB.Table("journal_items ji").
+		Select("je.entry_number, je.date, je.description, je.reference_id, je.reference_type, je.is_adjusting, je.reversal_of_id, c.code AS coa_code, c.name AS coa_name, c.type AS coa_type, ji.debit, ji.credit").
+		Joins("JOIN journal_entries je ON ji.journal_entry_id = je.id").
+		Joins("JOIN coas c ON ji.coa_id = c.id")
+	query = s.dateRange(query, "je.date", f.Period)
+	if len(f.COAIDs) > 0 {
+		if profileName == ExportProfileStandard {
+			query = query.Where("ji.coa_id IN ?", f.COAIDs)
+		} else {
+			query = query.Where("je.id IN (SELECT journal_entry_id FROM journal_items WHERE coa_id IN ?)", f.COAIDs)
+		}
+	}
+
+	rows, err := query.Order("je.date, je.id, ji.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if err := tw.WriteHeader(profile.header); err != nil {
+		return err
+	}
+	for rows.Next() {
+		var line journalExportLine
+		if err := s.DB.ScanRows(rows, &line); err != nil {
+			return err
+		}
+		code := line.COACode
+		if mapped, ok := codes[line.COACode]; ok {
+			code = mapped
+		}
+		if err := tw.WriteRow(profile.row(line, code)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *AccountingExportService) exportInvoices(f ExportFilter, tw TableWriter) error {
+	query := s.DB.Table("invoices i").
+		Select("i.invoice_number, i.created_at, i.type, i.status, i.order_id, u.full_name, u.email, i.currency_code, i.exchange_rate, i.amount, i.tax_amount, i.due_date, i.paid_at, i.payment_method").
+		Joins("LEFT JOIN users u ON i.user_id = u.id")
+	query = s.dateRange(query, "i.created_at", f.Period)
+
+	rows, err := query.Order("i.created_at, i.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	header := []string{"Invoice No", "Date", "Type", "Status", "Order ID", "Customer", "Email", "Currency", "Exchange Rate", "Amount", "Tax", "Due Date", "Paid At", "Payment Method"}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	for rows.Next() {
+		var r struct {
+			InvoiceNumber string
+			CreatedAt     time.Time
+			Type          string
+			Status        string
+			OrderID       *uint
+			FullName      *string
+			Email         *string
+			CurrencyCode  string
+			ExchangeRate  float64
+			Amount        float64
+			TaxAmount     float64
+			DueDate       time.Time
+			PaidAt        *time.Time
+			PaymentMethod string
+		}
+		if err := s.DB.ScanRows(rows, &r); err != nil {
+			return err
+		}
+		orderID, paidAt, name, email := "", "", "", ""
+		if r.OrderID != nil {
+			orderID = strconv.FormatUint(uint64(*r.OrderID), 10)
+		}
+		if r.PaidAt != nil {
+			paidAt = r.PaidAt.Format("2006-01-02 15:04")
+		}
+		if r.FullName != nil {
+			name = *r.FullName
+		}
+		if r.Email != nil {
+			email = *r.Email
+		}
+		err := tw.WriteRow([]interface{}{
+			r.InvoiceNumber, r.CreatedAt.Format("2006-01-02"), r.Type, r.Status, orderID, name, email,
+			r.CurrencyCode, r.ExchangeRate, r.Amount, r.TaxAmount, r.DueDate.Format("2006-01-02"), paidAt, r.PaymentMethod,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *AccountingExportService) exportExpenses(f ExportFilter, tw TableWriter) error {
+	query := s.DB.Table("expenses e").
+		Select("e.id, e.date, c.code AS coa_code, c.name AS coa_name, e.vendor, e.description, e.amount, e.is_recurring, u.username AS created_by").
+		Joins("JOIN coas c ON e.coa_id = c.id").
+		Joins("LEFT JOIN users u ON e.created_by = u.id")
+	query = s.dateRange(query, "e.date", f.Period)
+	if len(f.COAIDs) > 0 {
+		query = query.Where("e.coa_id IN ?", f.COAIDs)
+	}
+
+	rows, err := query.Order("e.date, e.id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	header := []string{"ID", "Date", "Account Code", "Account Name", "Vendor", "Description", "Amount", "Recurring", "Created By"}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	for rows.Next() {
+		var r struct {
+			ID          uint
+			Date        time.Time
+			COACode     string
+			COAName     string
+			Vendor      string
+			Description string
+			Amount      float64
+			IsRecurring bool
+			CreatedBy   *string
+		}
+		if err := s.DB.ScanRows(rows, &r); err != nil {
+			return err
+		}
+		createdBy := ""
+		if r.CreatedBy != nil {
+			createdBy = *r.CreatedBy
+		}
+		err := tw.WriteRow([]interface{}{r.ID, r.Date.Format("2006-01-02"), r.COACode, r.COAName, r.Vendor, r.Description, r.Amount, r.IsRecurring, createdBy})
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// exportProfitLoss writes one row per revenue / expense account followed by the totals
+func (s *AccountingExportService) exportProfitLoss(f ExportFilter, tw TableWriter) error {
+	var rows []struct {
+		Code   string
+		Name   string
+		Type   string
+		Debit  float64
+		Credit float64
+	}
+	query := s.DB.Table("journal_items ji").
+		Select("c.code, c.name, c.type, COALESCE(SUM(ji.debit), 0) AS debit, COALESCE(SUM(ji.credit), 0) AS credit").
+		Joins("JOIN coas c ON ji.coa_id = c.id").
+		Joins("JOIN journal_entries je ON ji.journal_entry_id = je.id").
+		Where("c.type IN ? AND je.reference_type <> ?", []string{"REVENUE", "EXPENSE", "COGS"}, helpers.JournalTypeClosing)
+	query = s.dateRange(query, "je.date", f.Period)
+	if len(f.COAIDs) > 0 {
+		query = query.Where("ji.coa_id IN ?", f.COAIDs)
+	}
+	if err := query.Group("c.id, c.code, c.name, c.type").Order("c.code").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader([]string{"Section", "Account Code", "Account Name", "Amount"}); err != nil {
+		return err
+	}
+	var revenue, expense float64
+	for _, section := range []string{"REVENUE", "COGS", "EXPENSE"} {
+		for _, r := range rows {
+			if r.Type != section {
+				continue
+			}
+			amount := naturalBalance(r.Type, r.Debit, r.Credit)
+			if section == "REVENUE" {
+				revenue += amount
+			} else {
+				expense += amount
+			}
+			if err := tw.WriteRow([]interface{}{section, r.Code, r.Name, amount}); err != nil {
+				return err
+			}
+		}
+	}
+	totals := [][]interface{}{
+		{"TOTAL", "", "Total Revenue", roundMoney(revenue)},
+		{"TOTAL", "", "Total Expense", roundMoney(expense)},
+		{"TOTAL", "", "Net Profit", roundMoney(revenue - expense)},
+	}
+	for _, t := range totals {
+		if err := tw.WriteRow(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------
+// ACCOUNT MAPPING
+// ---------------------------------------------------------
+
+// mappedCodes returns COA.Code -> external code for a target; unmapped accounts keep their own code
+func (s *AccountingExportService) mappedCodes(target string) map[string]string {
+	var mappings []models.ExportAccountMapping
+	s.DB.Where("target = ?", target).Find(&mappings)
+	out := make(map[string]string, len(mappings))
+	for _, m := range mappings {
+		out[m.COACode] = m.ExternalCode
+	}
+	return out
+}
+
+// AccountMappingRow is a postable account with its external code, if any
+type AccountMappingRow struct {
+	COAID        uint    `json:"coa_id"`
+	COACode      string  `json:"coa_code"`
+	COAName      string  `json:"coa_name"`
+	COAType      string  `json:"coa_type"`
+	ExternalCode *string `json:"external_code"` // nil = exported under our own code
+	ExternalName string  `json:"external_name"`
+}
+
+func validMappingTarget(target string) error {
+	if target != ExportProfileAccurate && target != ExportProfileJurnal {
+		return fmt.Errorf("target must be accurate or jurnal")
+	}
+	return nil
+}
+
+// ListMappings lists every postable account for a target so gaps are visible
+func (s *AccountingExportService) ListMappings(target string) ([]AccountMappingRow, error) {
+	if err := validMappingTarget(target); err != nil {
+		return nil, err
+	}
+	var coas []models.COA
+	if err := s.DB.Where("can_post = ?", true).Order("code asc").Find(&coas).Error; err != nil {
+		return nil, err
+	}
+	var mappings []models.ExportAccountMapping
+	s.DB.Where("target = ?", target).Find(&mappings)
+	byCode := make(map[string]models.ExportAccountMapping, len(mappings))
+	for _, m := range mappings {
+		byCode[m.COACode] = m
+	}
+
+	out := make([]AccountMappingRow, 0, len(coas))
+	for _, coa := range coas {
+		row := AccountMappingRow{COAID: coa.ID, COACode: coa.Code, COAName: coa.Name, COAType: coa.Type}
+		if m, ok := byCode[coa.Code]; ok {
+			code := m.ExternalCode
+			row.ExternalCode = &code
+			row.ExternalName = m.ExternalName
+		}
+		out = append(out, row)
+	}
+	return out, nil
+}
+
+// AccountMappingInput sets one account's external code; an empty ExternalCode removes the mapping
+type AccountMappingInput struct {
+	COACode      string `json:"coa_code" binding:"required"`
+	ExternalCode string `json:"external_code"`
+	ExternalName string `json:"external_name"`
+}
+
+// SaveMappings upserts the given mappings for a target in one TX
+func (s *AccountingExportService) SaveMappings(target string, inputs []AccountMappingInput) error {
+	if err := validMappingTarget(target); err != nil {
+		return err
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, in := range inputs {
+			in.COACode = strings.TrimSpace(in.COACode)
+			in.ExternalCode = strings.TrimSpace(in.ExternalCode)
+
+			var count int64
+			tx.Model(&models.COA{}).Where("code = ?", in.COACode).Count(&count)
+			if count == 0 {
+				return fmt.Errorf("account %s not found", in.COACode)
+			}
+
+			if in.ExternalCode == "" {
+				if err := tx.Where("target = ? AND coa_code = ?", target, in.COACode).Delete(&models.ExportAccountMapping{}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			var mapping models.ExportAccountMapping
+			err := tx.Where("target = ? AND coa_code = ?", target, in.COACode).First(&mapping).Error
+			if err != nil && err != gorm.ErrRecordNotFound {
+				return err
+			}
+			mapping.Target = target
+			mapping.COACode = in.COACode
+			mapping.ExternalCode = in.ExternalCode
+			mapping.ExternalName = in.ExternalName
+			if err := tx.Save(&mapping).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===============================================
+// ACCOUNTING PERIODS & YEAR-END CLOSE
+// ===============================================
+
+type AccountingPeriodService struct {
+	DB *gorm.DB
+}
+
+func NewAccountingPeriodService() *AccountingPeriodService {
+	return &AccountingPeriodService{
+		DB: config.DB,
+	}
+}
+
+// ListPeriods returns the periods of a year (0 = all), oldest first
+func (s *AccountingPeriodService) ListPeriods(year int) ([]models.AccountingPeriod, error) {
+	var periods []models.AccountingPeriod
+	query := s.DB.Order("start_date asc")
+	if year > 0 {
+		query = query.Where("name LIKE ?", fmt.Sprintf("%04d-%%", year))
+	}
+	err := query.Find(&periods).Error
+	return periods, err
+}
+
+// OpenPeriod creates the monthly period (open), or reopens a closed one. Locked periods stay locked.
+func (s *AccountingPeriodService) OpenPeriod(year, month int, userID uint) (*models.AccountingPeriod, error) {
+	if year < 2000 || month < 1 || month > 12 {
+		return nil, fmt.Errorf("invalid period")
+	}
+	name := fmt.Sprintf("%04d-%02d", year, month)
+
+	var period models.AccountingPeriod
+	if err := s.DB.Where("name = ?", name).First(&period).Error; err != nil {
+		start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
+		period = models.AccountingPeriod{
+			Name:      name,
+			StartDate: start,
+			EndDate:   start.AddDate(0, 1, -1),
+			Status:    models.PeriodOpen,
+		}
+		if err := s.DB.Create(&period).Error; err != nil {
+			return nil, err
+		}
+		helpers.LogAuditSimple(userID, "Finance", "Open Period", period.ID, "Opened accounting period "+name)
+		return &period, nil
+	}
+
+	switch period.Status {
+	case models.PeriodOpen:
+		return &period, nil
+	case models.PeriodLocked:
+		return nil, fmt.Errorf("period %s is locked and cannot be reopened", name)
+	}
+
+	// A later period that is already closed was closed on top of these figures
+	var laterClosed int64
+	s.DB.Model(&models.AccountingPeriod{}).Where("start_date > ? AND status <> ?", period.StartDate, models.PeriodOpen).Count(&laterClosed)
+	if laterClosed > 0 {
+		return nil, fmt.Errorf("reopen the later closed periods first")
+	}
+
+	res := s.DB.Model(&period).Where("status = ?", models.PeriodClosed).
+		Updates(map[string]interface{}{"status": models.PeriodOpen, "closed_by": nil, "closed_at": nil})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	helpers.LogAuditSimple(userID, "Finance", "Reopen Period", period.ID, "Reopened accounting period "+name)
+	return s.getPeriod(period.ID)
+}
+
+// ClosePeriod closes a period: from now on it only accepts adjusting entries. Periods close in order.
+func (s *AccountingPeriodService) ClosePeriod(id uint, userID uint, note string) (*models.AccountingPeriod, error) {
+	period, err := s.getPeriod(id)
+	if err != nil {
+		return nil, err
+	}
+	if period.Status != models.PeriodOpen {
+		return nil, fmt.Errorf("period %s is already %s", period.Name, period.Status)
+	}
+
+	var earlierOpen int64
+	s.DB.Model(&models.AccountingPeriod{}).Where("start_date < ? AND status = ?", period.StartDate, models.PeriodOpen).Count(&earlierOpen)
+	if earlierOpen > 0 {
+		return nil, fmt.Errorf("close the earlier open periods first")
+	}
+
+	now := time.Now()
+	res := s.DB.Model(period).Where("status = ?", models.PeriodOpen).
+		Updates(map[string]interface{}{"status": models.PeriodClosed, "closed_by": userID, "closed_at": now, "note": note})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	helpers.LogAuditSimple(userID, "Finance", "Close Period", period.ID, "Closed accounting period "+period.Name)
+	return s.getPeriod(id)
+}
+
+// LockPeriod makes a closed period final: not even adjusting entries are accepted
+func (s *AccountingPeriodService) LockPeriod(id uint, userID uint) (*models.AccountingPeriod, error) {
+	period, err := s.getPeriod(id)
+	if err != nil {
+		return nil, err
+	}
+	if period.Status != models.PeriodClosed {
+		return nil, fmt.Errorf("only a closed period can be locked (status: %s)", period.Status)
+	}
+
+	now := time.Now()
+	res := s.DB.Model(period).Where("status = ?", models.PeriodClosed).
+		Updates(map[string]interface{}{"status": models.PeriodLocked, "locked_by": userID, "locked_at": now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	helpers.LogAuditSimple(userID, "Finance", "Lock Period", period.ID, "Locked accounting period "+period.Name)
+	return s.getPeriod(id)
+}
+
+// CloseYear posts the closing entry that rolls the year's REVENUE, EXPENSE and COGS balances into
+// retained earnings (dated 31 December, adjusting), then locks the twelve periods of the year.
+// Every month of the year must be closed (or already locked) first.
+func (s *AccountingPeriodService) CloseYear(year int, userID uint) (*models.JournalEntry, error) {
+	retainedID := retainedEarningsCOA(s.DB)
+	if retainedID == 0 {
+		return nil, fmt.Errorf("map an equity account to RETAINED_EARNINGS before the year-end close")
+	}
+
+	ref := fmt.Sprintf("CLOSE-%d", year)
+	var entry *models.JournalEntry
+	var netIncome float64
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// The year's period rows serialize concurrent closes: the second one sees the first one's entry
+		var periods []models.AccountingPeriod
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("name LIKE ?", fmt.Sprintf("%04d-%%", year)).Order("start_date asc").Find(&periods).Error; err != nil {
+			return err
+		}
+		closedMonths := map[string]bool{}
+		for _, p := range periods {
+			if p.Status != models.PeriodOpen {
+				closedMonths[p.Name] = true
+			}
+		}
+		for m := 1; m <= 12; m++ {
+			if name := fmt.Sprintf("%04d-%02d", year, m); !closedMonths[name] {
+				return fmt.Errorf("period %s must be closed before the year-end close", name)
+			}
+		}
+
+		var existing int64
+		tx.Model(&models.JournalEntry{}).Where("reference_type = ? AND reference_id = ?", helpers.JournalTypeClosing, ref).Count(&existing)
+		if existing > 0 {
+			return fmt.Errorf("year %d is already closed", year)
+		}
+
+		start := time.Date(year, 1, 1, 0, 0, 0, 0, time.Local)
+		end := time.Date(year, 12, 31, 0, 0, 0, 0, time.Local)
+		movements := (&FinanceService{DB: tx}).accountMovements(ReportPeriod{From: &start, To: end})
+
+		var coas []models.COA
+		tx.Where("type IN ?", []string{"REVENUE", "EXPENSE", "COGS"}).Find(&coas)
+		sort.Slice(coas, func(i, j int) bool { return coas[i].Code < coas[j].Code })
+
+		var items []models.JournalItem
+		for _, coa := range coas {
+			m := movements[coa.ID]
+			net := roundMoney(m.Debit - m.Credit) // > 0: debit balance to be credited away
+			if net == 0 {
+				continue
+			}
+			netIncome -= net
+			if net > 0 {
+				items = append(items, models.JournalItem{COAID: coa.ID, Credit: net})
+			} else {
+				items = append(items, models.JournalItem{COAID: coa.ID, Debit: -net})
+			}
+		}
+		netIncome = roundMoney(netIncome)
+		if len(items) == 0 {
+			return fmt.Errorf("no revenue or expense balances to close in %d", year)
+		}
+		if netIncome > 0 {
+			items = append(items, models.JournalItem{COAID: retainedID, Credit: netIncome})
+		} else if netIncome < 0 {
+			items = append(items, models.JournalItem{COAID: retainedID, Debit: -netIncome})
+		}
+
+		// December may already be locked: the closing entry is let through, the months were checked above
+		var err error
+		entry, err = helpers.PostJournal(tx, helpers.JournalPosting{
+			Date:          end,
+			ReferenceID:   ref,
+			ReferenceType: helpers.JournalTypeClosing,
+			Description:   fmt.Sprintf("Tutup buku %d: laba/rugi Rp %.0f ke laba ditahan", year, netIncome),
+			IsAdjusting:   true,
+			YearEndClose:  true,
+			Items:         items,
+		})
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&models.AccountingPeriod{}).Where("name LIKE ? AND status = ?", fmt.Sprintf("%04d-%%", year), models.PeriodClosed).
+			Updates(map[string]interface{}{"status": models.PeriodLocked, "locked_by": userID, "locked_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "Finance", "Close Year", entry.ID, fmt.Sprintf("Year-end close %d posted as %s (net income Rp %.0f)", year, entry.EntryNumber, netIncome))
+	return entry, nil
+}
+
+func (s *AccountingPeriodService) getPeriod(id uint) (*models.AccountingPeriod, error) {
+	var period models.AccountingPeriod
+	if err := s.DB.First(&period, id).Error; err != nil {
+		return nil, fmt.Errorf("accounting period not found")
+	}
+	return &period, nil
+}
+
+// retainedEarningsCOA - RETAINED_EARNINGS mapping, or an equity account named Laba Ditahan / Retained
+func retainedEarningsCOA(tx *gorm.DB) uint {
+	if id, err := helpers.GetCOAByMappingKey("RETAINED_EARNINGS"); err == nil {
+		return id
+	}
+	var coa models.COA
+	if err := tx.Where("type = ? AND can_post = ? AND (name ILIKE ? OR name ILIKE ?)", "EQUITY", true, "%laba ditahan%", "%retained%").First(&coa).Error; err == nil {
+		return coa.ID
+	}
+	return 0
+}
+
+// ---------------------------------------------------------
+// JOURNAL REVERSAL
+// ---------------------------------------------------------
+
+type ReverseJournalInput struct {
+	Date        *time.Time `json:"date"` // Default today
+	Reason      string     `json:"reason"`
+	IsAdjusting bool       `json:"is_adjusting"` // Reversal dated into a closed period
+}
+
+// ReverseJournal corrects a posted entry with a mirrored one; posted entries are never edited or deleted
+func (s *FinanceService) ReverseJournal(id uint, input ReverseJournalInput, userID uint) (*models.JournalEntry, error) {
+	var original models.JournalEntry
+	if err := s.DB.Preload("Items").First(&original, id).Error; err != nil {
+		return nil, fmt.Errorf("journal entry not found")
+	}
+	switch {
+	case original.ReversedByID != nil:
+		return nil, fmt.Errorf("journal %s is already reversed", original.EntryNumber)
+	case original.ReversalOfID != nil:
+		return nil, fmt.Errorf("journal %s is itself a reversal; post a new entry instead", original.EntryNumber)
+	case original.ReferenceType == helpers.JournalTypeClosing:
+		return nil, fmt.Errorf("a year-end closing entry cannot be reversed")
+	}
+
+	date := time.Now()
+	if input.Date != nil && !input.Date.IsZero() {
+		date = *input.Date
+	}
+	if date.Before(original.Date) {
+		return nil, fmt.Errorf("a reversal cannot be dated before the original entry")
+	}
+
+	items := make([]models.JournalItem, 0, len(original.Items))
+	for _, it := range original.Items {
+		items = append(items, models.JournalItem{COAID: it.COAID, Debit: it.Credit, Credit: it.Debit})
+	}
+	desc := "Reversal of " + original.EntryNumber
+	if input.Reason != "" {
+		desc += ": " + input.Reason
+	}
+
+	var reversal *models.JournalEntry
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		reversal, err = helpers.PostJournal(tx, helpers.JournalPosting{
+			Date:          date,
+			ReferenceID:   original.EntryNumber,
+			ReferenceType: "REVERSAL",
+			Description:   desc,
+			IsAdjusting:   input.IsAdjusting,
+			ReversalOfID:  &original.ID,
+			Items:         items,
+		})
+		if err != nil {
+			return err
+		}
+		res := tx.Model(&models.JournalEntry{}).Where("id = ? AND reversed_by_id IS NULL", original.ID).Update("reversed_by_id", reversal.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("journal %s is already reversed", original.EntryNumber)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "finance", "REVERSE", original.ID, fmt.Sprintf("Reversed %s with %s", original.EntryNumber, reversal.EntryNumber))
+	return reversal, nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ===============================================
+// BANK TRANSFER (UNIQUE CODE)
+// ===============================================
+
+const uniqueCodeMax = 999
+
+// openTransferStatuses - invoices whose transfer may still arrive
+var openTransferStatuses = []string{"unpaid", "awaiting_approval"}
+
+type BankTransferService struct {
+	DB *gorm.DB
+}
+
+func NewBankTransferService() *BankTransferService {
+	return &BankTransferService{
+		DB: config.DB,
+	}
+}
+
+// ChooseTransfer is called when the customer picks manual bank transfer: the invoice gets its unique code
+func (s *BankTransferService) ChooseTransfer(invoiceID, userID uint) (*models.Invoice, error) {
+	if helpers.GetSetting("enable_bank_transfer", "false") != "true" {
+		return nil, fmt.Errorf("bank transfer is not available")
+	}
+
+	var invoice models.Invoice
+	if err := s.DB.Preload("Order").First(&invoice, invoiceID).Error; err != nil {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	if invoice.UserID != userID && (invoice.OrderID == nil || invoice.Order.UserID != userID) {
+		return nil, fmt.Errorf("unauthorized")
+	}
+	if invoice.Status != "unpaid" && invoice.Status != "awaiting_approval" {
+		return nil, fmt.Errorf("invoice is %s", invoice.Status)
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := AssignUniqueCode(tx, &invoice); err != nil {
+			return err
+		}
+		if invoice.PaymentMethod == "" {
+			invoice.PaymentMethod = "bank_transfer"
+			return tx.Model(&invoice).Update("payment_method", invoice.PaymentMethod).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// AssignUniqueCode gives an invoice a 1-999 code so that no other open invoice expects the same transfer amount.
+// An invoice keeps the code it already has; with bank_transfer_unique_code=false nothing is assigned.
+func AssignUniqueCode(tx *gorm.DB, invoice *models.Invoice) error {
+	if invoice.UniqueCode > 0 || helpers.GetSetting("bank_transfer_unique_code", "true") != "true" {
+		return nil
+	}
+
+	// Serialize assignments so two checkouts of the same amount cannot draw the same code
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('invoice_unique_code'))").Error; err != nil {
+		return err
+	}
+
+	var taken []int
+	tx.Model(&models.Invoice{}).
+		Select("CAST(ROUND(amount + unique_code - ?) AS INTEGER)", invoice.Amount).
+		Where("status IN ? AND id <> ? AND amount + unique_code BETWEEN ? AND ?", openTransferStatuses, invoice.ID, invoice.Amount, invoice.Amount+uniqueCodeMax).
+		Scan(&taken)
+	used := map[int]bool{}
+	for _, c := range taken {
+		used[c] = true
+	}
+
+	start := rand.Intn(uniqueCodeMax)
+	for i := 0; i < uniqueCodeMax; i++ {
+		code := (start+i)%uniqueCodeMax + 1
+		if used[code] {
+			continue
+		}
+		if err := tx.Model(invoice).Update("unique_code", code).Error; err != nil {
+			return err
+		}
+		invoice.UniqueCode = code
+		return nil
+	}
+	return fmt.Errorf("no unique code left for amount %.0f, try again later", invoice.Amount)
+}
+
+// ImportMutationFiles picks up bank mutation CSVs dropped in bank_mutation_dir (e.g. by an internet-banking
+// export job), imports them as bank statements and moves each file to processed/ or failed/.
+func (s *BankTransferService) ImportMutationFiles() {
+	dir := helpers.GetSetting("bank_mutation_dir", os.Getenv("BANK_MUTATION_DIR"))
+	if dir == "" {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if err != nil || len(files) == 0 {
+		return
+	}
+	sort.Strings(files)
+
+	provider := helpers.GetSetting("bank_mutation_provider", "bank")
+	for _, path := range files {
+		// Skip files still being written
+		if info, err := os.Stat(path); err != nil || time.Since(info.ModTime()) < time.Minute {
+			continue
+		}
+
+		target := "processed"
+		if err := s.importMutationFile(path, provider); err != nil {
+			log.Printf("❌ Bank mutation import %s failed: %v", filepath.Base(path), err)
+			target = "failed"
+		}
+		os.MkdirAll(filepath.Join(dir, target), 0755)
+		if err := os.Rename(path, filepath.Join(dir, target, filepath.Base(path))); err != nil {
+			log.Printf("⚠️ Could not move %s: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func (s *BankTransferService) importMutationFile(path, provider string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	batch, err := (&ReconciliationService{DB: s.DB}).Import(ImportSettlementInput{
+		Source:   "bank",
+		Provider: strings.ToLower(provider),
+		FileName: filepath.Base(path),
+	}, f)
+	if err != nil {
+		return err
+	}
+	log.Printf("🏦 Imported bank mutations %s as %s: %d lines, %d confirmed", filepath.Base(path), batch.BatchNumber, batch.LineCount, batch.ConfirmedCount)
+	return nil
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"forzashop/backend/config"
+	"forzashop/backend/models"
+)
+
+func init() {
+	RegisterWebhookHandler("biteship", biteshipWebhookHandler{})
+}
+
+// biteshipPayload - Biteship mengirim waybill di root atau di dalam courier
+type biteshipPayload struct {
+	Event     string  `json:"event"`
+	Status    string  `json:"status"`
+	WaybillID string  `json:"waybill_id"`
+	OrderID   string  `json:"order_id"`
+	Price     float64 `json:"price"`
+	Courier   struct {
+		WaybillID string `json:"waybill_id"`
+		Company   string `json:"company"`
+	} `json:"courier"`
+}
+
+func (p biteshipPayload) waybill() string {
+	if p.WaybillID != "" {
+		return p.WaybillID
+	}
+	return p.Courier.WaybillID
+}
+
+// legacyOrderCourierTransitions - order status moves a courier event may cause on orders shipped before
+// shipments existed. Completed and cancelled orders are final; late "picked" events cannot reopen them.
+var legacyOrderCourierTransitions = map[string][]string{
+	"processing": {"shipped", "completed", "cancelled"},
+	"shipped":    {"completed", "cancelled", "processing"}, // processing: courier_not_found
+}
+
+// biteshipWebhookHandler applies stored Biteship events to shipments (or legacy orders)
+type biteshipWebhookHandler struct{}
+
+// EventKey - Biteship has no event id; the same event/status/waybill/price for an order is the same event
+func (biteshipWebhookHandler) EventKey(header http.Header, body []byte) (string, string) {
+	var p biteshipPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return "", ""
+	}
+	return fmt.Sprintf("%s:%s:%s:%s:%.0f", p.OrderID, p.waybill(), p.Event, p.Status, p.Price), p.Event
+}
+
+func (biteshipWebhookHandler) Process(event models.WebhookEvent, header http.Header) (string, error) {
+	var payload biteshipPayload
+	if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrWebhookRejected, err)
+	}
+	finalWaybill := payload.waybill()
+
+	// Parcel (shipment) first: each one has its own Biteship order and waybill
+	shipmentSvc := NewShipmentService()
+	if shipment, ok := shipmentSvc.FindByCourierRef(finalWaybill, payload.OrderID); ok {
+		if err := shipmentSvc.ApplyCourierEvent(*shipment, CourierEvent{
+			Event:           payload.Event,
+			Status:          payload.Status,
+			WaybillID:       finalWaybill,
+			BiteshipOrderID: payload.OrderID,
+			Company:         payload.Courier.Company,
+			Price:           payload.Price,
+		}); err != nil {
+			return "", fmt.Errorf("shipment %s update failed: %v", shipment.ShipmentNumber, err)
+		}
+		return "shipment " + shipment.ShipmentNumber, nil
+	}
+
+	// Legacy orders shipped before shipments existed: cari order berdasarkan waybill ATAU biteship_order_id
+	var order models.Order
+	found := false
+	if finalWaybill != "" {
+		found = config.DB.Where("tracking_number = ?", finalWaybill).First(&order).Error == nil
+	}
+	if !found && payload.OrderID != "" {
+		found = config.DB.Where("biteship_order_id = ?", payload.OrderID).First(&order).Error == nil
+	}
+	if !found {
+		log.Printf("webhook: no matching order waybill=%s biteship_id=%s", finalWaybill, payload.OrderID)
+		return "no matching order", nil
+	}
+
+	switch payload.Event {
+	case "order.status":
+		return applyLegacyCourierStatus(order, payload.Status)
+
+	case "order.waybill_id":
+		// Biteship memberikan nomor resi yang baru/updated
+		if finalWaybill != "" && finalWaybill != order.TrackingNumber {
+			log.Printf("waybill updated: %s -> %s", order.TrackingNumber, finalWaybill)
+			if err := config.DB.Model(&order).Updates(map[string]interface{}{
+				"tracking_number": finalWaybill,
+				"carrier":         payload.Courier.Company,
+			}).Error; err != nil {
+				return "", err
+			}
+			config.DB.Create(&models.OrderLog{
+				OrderID:           order.ID,
+				Action:            "waybill_updated",
+				Note:              fmt.Sprintf("Nomor resi diperbarui: %s (%s)", finalWaybill, payload.Courier.Company),
+				IsCustomerVisible: true,
+			})
+		}
+
+	case "order.price":
+		// Biteship memberikan update harga (berat aktual berbeda)
+		if payload.Price > 0 && payload.Price != order.ShippingCost {
+			log.Printf("shipping price updated: %.0f -> %.0f", order.ShippingCost, payload.Price)
+			if err := config.DB.Model(&order).Update("shipping_cost", payload.Price).Error; err != nil {
+				return "", err
+			}
+			config.DB.Create(&models.OrderLog{
+				OrderID:           order.ID,
+				Action:            "shipping_price_updated",
+				Note:              fmt.Sprintf("Ongkir diperbarui oleh kurir: Rp %.0f (sebelumnya Rp %.0f)", payload.Price, order.ShippingCost),
+				IsCustomerVisible: false,
+			})
+		}
+
+	default:
+		// Event tidak dikenal, tapi tetap coba update status
+		if payload.Status != "" {
+			return applyLegacyCourierStatus(order, payload.Status)
+		}
+	}
+	return "order " + order.OrderNumber, nil
+}
+
+// applyLegacyCourierStatus - Update status order berdasarkan status dari Biteship
+func applyLegacyCourierStatus(order models.Order, biteshipStatus string) (string, error) {
+	newStatus := order.Status
+
+	switch biteshipStatus {
+	case "delivered":
+		newStatus = "completed"
+	case "picked", "picking_up", "in_transit", "out_for_delivery", "allocated", "confirmed":
+		newStatus = "shipped"
+	case "rejected", "returned", "lost", "disposed":
+		newStatus = "cancelled"
+	case "courier_not_found":
+		// Kurir tidak ditemukan, order kembali ke processing
+		newStatus = "processing"
+	}
+	if newStatus == order.Status {
+		return "order " + order.OrderNumber + " unchanged", nil
+	}
+
+	allowed := false
+	for _, next := range legacyOrderCourierTransitions[order.Status] {
+		if next == newStatus {
+			allowed = true
+		}
+	}
+	if !allowed {
+		log.Printf("webhook: order #%s %s -> %s not allowed (biteship: %s)", order.OrderNumber, order.Status, newStatus, biteshipStatus)
+		return fmt.Sprintf("order %s: %s -> %s ignored", order.OrderNumber, order.Status, newStatus), nil
+	}
+
+	log.Printf("auto-updating order #%s: %s -> %s (biteship: %s)", order.OrderNumber, order.Status, newStatus, biteshipStatus)
+	res := config.DB.Model(&models.Order{}).Where("id = ? AND status = ?", order.ID, order.Status).Update("status", newStatus)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Sprintf("order %s changed concurrently", order.OrderNumber), nil
+	}
+
+	config.DB.Create(&models.OrderLog{
+		OrderID:           order.ID,
+		Action:            "webhook_status_update",
+		Note:              fmt.Sprintf("Status otomatis diperbarui: %s → %s (dari kurir: %s)", order.Status, newStatus, biteshipStatus),
+		IsCustomerVisible: true,
+	})
+	return fmt.Sprintf("order %s: %s -> %s", order.OrderNumber, order.Status, newStatus), nil
+}
@@ -0,0 +1,589 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+)
+
+// ---------------------------------------------------------
+// FINANCIAL STATEMENTS
+// All figures come from journal_items, never from the cached COA.Balance.
+// ---------------------------------------------------------
+
+// ReportPeriod is a reporting window; From is nil for "since the beginning" (balances as of To)
+type ReportPeriod struct {
+	From *time.Time `json:"from"`
+	To   time.Time  `json:"to"`
+}
+
+// PreviousPeriod returns the window of the same length right before p; as-of periods step back one month
+func (p ReportPeriod) PreviousPeriod() ReportPeriod {
+	if p.From == nil {
+		return ReportPeriod{To: p.To.AddDate(0, -1, 0)}
+	}
+	days := int(p.To.Sub(*p.From).Hours()/24) + 1
+	to := p.From.AddDate(0, 0, -1)
+	from := to.AddDate(0, 0, -(days - 1))
+	return ReportPeriod{From: &from, To: to}
+}
+
+type accountSums struct {
+	COAID  uint
+	Debit  float64
+	Credit float64
+}
+
+// isDebitNormal - assets, expenses and COGS grow with debits
+func isDebitNormal(coaType string) bool {
+	return coaType == "ASSET" || coaType == "EXPENSE" || coaType == "COGS"
+}
+
+func naturalBalance(coaType string, debit, credit float64) float64 {
+	if isDebitNormal(coaType) {
+		return roundMoney(debit - credit)
+	}
+	return roundMoney(credit - debit)
+}
+
+// accountMovements sums debits and credits per account over a period (dates inclusive),
+// leaving out the given journal reference types
+func (s *FinanceService) accountMovements(p ReportPeriod, excludeTypes ...string) map[uint]accountSums {
+	var rows []accountSums
+	query := s.DB.Table("journal_items ji").
+		Select("ji.coa_id, COALESCE(SUM(ji.debit), 0) AS debit, COALESCE(SUM(ji.credit), 0) AS credit").
+		Joins("JOIN journal_entries je ON ji.journal_entry_id = je.id").
+		Where("je.date < ?", p.To.AddDate(0, 0, 1))
+	if p.From != nil {
+		query = query.Where("je.date >= ?", *p.From)
+	}
+	if len(excludeTypes) > 0 {
+		query = query.Where("je.reference_type NOT IN ?", excludeTypes)
+	}
+	query.Group("ji.coa_id").Scan(&rows)
+
+	out := make(map[uint]accountSums, len(rows))
+	for _, r := range rows {
+		out[r.COAID] = r
+	}
+	return out
+}
+
+func (s *FinanceService) loadCOAs() []models.COA {
+	var coas []models.COA
+	s.DB.Order("code asc").Find(&coas)
+	return coas
+}
+
+// ---------------------------------------------------------
+// TRIAL BALANCE
+// ---------------------------------------------------------
+
+type TrialBalanceRow struct {
+	COAID           uint    `json:"coa_id"`
+	Code            string  `json:"code"`
+	Name            string  `json:"name"`
+	Type            string  `json:"type"`
+	Debit           float64 `json:"debit"`  // Closing balance on the debit side
+	Credit          float64 `json:"credit"` // Closing balance on the credit side
+	Balance         float64 `json:"balance"`
+	PreviousBalance float64 `json:"previous_balance"`
+	Change          float64 `json:"change"`
+}
+
+type TrialBalanceReport struct {
+	AsOf           time.Time         `json:"as_of"`
+	PreviousAsOf   time.Time         `json:"previous_as_of"`
+	Rows           []TrialBalanceRow `json:"rows"`
+	TotalDebit     float64           `json:"total_debit"`
+	TotalCredit    float64           `json:"total_credit"`
+	PreviousDebit  float64           `json:"previous_debit"`
+	PreviousCredit float64           `json:"previous_credit"`
+	Balanced       bool              `json:"balanced"`
+}
+
+// TrialBalance lists every account's balance as of a date, compared with compareTo (default one month earlier)
+func (s *FinanceService) TrialBalance(asOf time.Time, compareTo *time.Time) *TrialBalanceReport {
+	prev := ReportPeriod{To: asOf}.PreviousPeriod()
+	if compareTo != nil {
+		prev.To = *compareTo
+	}
+	current := s.accountMovements(ReportPeriod{To: asOf})
+	previous := s.accountMovements(prev)
+
+	report := &TrialBalanceReport{AsOf: asOf, PreviousAsOf: prev.To, Rows: []TrialBalanceRow{}}
+	for _, coa := range s.loadCOAs() {
+		cur, hasCur := current[coa.ID]
+		old, hasOld := previous[coa.ID]
+		if !hasCur && !hasOld {
+			continue
+		}
+		row := TrialBalanceRow{
+			COAID:           coa.ID,
+			Code:            coa.Code,
+			Name:            coa.Name,
+			Type:            coa.Type,
+			Balance:         naturalBalance(coa.Type, cur.Debit, cur.Credit),
+			PreviousBalance: naturalBalance(coa.Type, old.Debit, old.Credit),
+		}
+		row.Change = roundMoney(row.Balance - row.PreviousBalance)
+
+		if net := roundMoney(cur.Debit - cur.Credit); net >= 0 {
+			row.Debit = net
+		} else {
+			row.Credit = -net
+		}
+		if net := roundMoney(old.Debit - old.Credit); net >= 0 {
+			report.PreviousDebit += net
+		} else {
+			report.PreviousCredit -= net
+		}
+		if row.Balance == 0 && row.PreviousBalance == 0 {
+			continue
+		}
+		report.TotalDebit += row.Debit
+		report.TotalCredit += row.Credit
+		report.Rows = append(report.Rows, row)
+	}
+	report.TotalDebit = roundMoney(report.TotalDebit)
+	report.TotalCredit = roundMoney(report.TotalCredit)
+	report.PreviousDebit = roundMoney(report.PreviousDebit)
+	report.PreviousCredit = roundMoney(report.PreviousCredit)
+	report.Balanced = report.TotalDebit == report.TotalCredit
+	return report
+}
+
+// ---------------------------------------------------------
+// BALANCE SHEET
+// ---------------------------------------------------------
+
+// BalanceSheetNode is an account with its sub-accounts; Balance includes the children
+type BalanceSheetNode struct {
+	COAID           uint                `json:"coa_id,omitempty"`
+	Code            string              `json:"code"`
+	Name            string              `json:"name"`
+	Balance         float64             `json:"balance"`
+	PreviousBalance float64             `json:"previous_balance"`
+	Children        []*BalanceSheetNode `json:"children,omitempty"`
+}
+
+type BalanceSheetSection struct {
+	Type          string              `json:"type"`
+	Accounts      []*BalanceSheetNode `json:"accounts"`
+	Total         float64             `json:"total"`
+	PreviousTotal float64             `json:"previous_total"`
+}
+
+type BalanceSheetReport struct {
+	AsOf                  time.Time           `json:"as_of"`
+	PreviousAsOf          time.Time           `json:"previous_as_of"`
+	Assets                BalanceSheetSection `json:"assets"`
+	Liabilities           BalanceSheetSection `json:"liabilities"`
+	Equity                BalanceSheetSection `json:"equity"`
+	LiabilitiesAndEquity  float64             `json:"liabilities_and_equity"`
+	PreviousLiabAndEquity float64             `json:"previous_liabilities_and_equity"`
+	Balanced              bool                `json:"balanced"`
+}
+
+// BalanceSheet groups asset, liability and equity balances along the COA ParentID hierarchy.
+// Revenue and expenses not yet closed show up in equity as current earnings.
+func (s *FinanceService) BalanceSheet(asOf time.Time, compareTo *time.Time) *BalanceSheetReport {
+	prev := ReportPeriod{To: asOf}.PreviousPeriod()
+	if compareTo != nil {
+		prev.To = *compareTo
+	}
+	current := s.accountMovements(ReportPeriod{To: asOf})
+	previous := s.accountMovements(prev)
+	coas := s.loadCOAs()
+
+	report := &BalanceSheetReport{AsOf: asOf, PreviousAsOf: prev.To}
+	report.Assets = buildBalanceSection("ASSET", coas, current, previous)
+	report.Liabilities = buildBalanceSection("LIABILITY", coas, current, previous)
+	report.Equity = buildBalanceSection("EQUITY", coas, current, previous)
+
+	// Unclosed profit: revenue - COGS - expenses up to the date
+	var earnings, prevEarnings float64
+	for _, coa := range coas {
+		switch coa.Type {
+		case "REVENUE":
+			earnings += naturalBalance(coa.Type, current[coa.ID].Debit, current[coa.ID].Credit)
+			prevEarnings += naturalBalance(coa.Type, previous[coa.ID].Debit, previous[coa.ID].Credit)
+		case "EXPENSE", "COGS":
+			earnings -= naturalBalance(coa.Type, current[coa.ID].Debit, current[coa.ID].Credit)
+			prevEarnings -= naturalBalance(coa.Type, previous[coa.ID].Debit, previous[coa.ID].Credit)
+		}
+	}
+	if earnings != 0 || prevEarnings != 0 {
+		report.Equity.Accounts = append(report.Equity.Accounts, &BalanceSheetNode{
+			Code:            "-",
+			Name:            "Laba/Rugi Berjalan",
+			Balance:         roundMoney(earnings),
+			PreviousBalance: roundMoney(prevEarnings),
+		})
+		report.Equity.Total = roundMoney(report.Equity.Total + earnings)
+		report.Equity.PreviousTotal = roundMoney(report.Equity.PreviousTotal + prevEarnings)
+	}
+
+	report.LiabilitiesAndEquity = roundMoney(report.Liabilities.Total + report.Equity.Total)
+	report.PreviousLiabAndEquity = roundMoney(report.Liabilities.PreviousTotal + report.Equity.PreviousTotal)
+	report.Balanced = report.Assets.Total == report.LiabilitiesAndEquity
+	return report
+}
+
+// buildBalanceSection builds the account tree of one type; accounts whose parent has another type become roots
+func buildBalanceSection(coaType string, coas []models.COA, current, previous map[uint]accountSums) BalanceSheetSection {
+	nodes := map[uint]*BalanceSheetNode{}
+	var ordered []models.COA
+	for _, coa := range coas {
+		if coa.Type != coaType {
+			continue
+		}
+		nodes[coa.ID] = &BalanceSheetNode{
+			COAID:           coa.ID,
+			Code:            coa.Code,
+			Name:            coa.Name,
+			Balance:         naturalBalance(coa.Type, current[coa.ID].Debit, current[coa.ID].Credit),
+			PreviousBalance: naturalBalance(coa.Type, previous[coa.ID].Debit, previous[coa.ID].Credit),
+		}
+		ordered = append(ordered, coa)
+	}
+
+	section := BalanceSheetSection{Type: coaType, Accounts: []*BalanceSheetNode{}}
+	for _, coa := range ordered {
+		node := nodes[coa.ID]
+		if coa.ParentID != nil {
+			if parent, ok := nodes[*coa.ParentID]; ok && *coa.ParentID != coa.ID {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		section.Accounts = append(section.Accounts, node)
+	}
+	for _, root := range section.Accounts {
+		rollUpBalance(root, 0)
+		section.Total += root.Balance
+		section.PreviousTotal += root.PreviousBalance
+	}
+	section.Accounts = pruneEmptyNodes(section.Accounts)
+	section.Total = roundMoney(section.Total)
+	section.PreviousTotal = roundMoney(section.PreviousTotal)
+	return section
+}
+
+// rollUpBalance adds the children's balances into their parent (depth guards against a ParentID cycle)
+func rollUpBalance(node *BalanceSheetNode, depth int) {
+	if depth > 20 {
+		return
+	}
+	for _, child := range node.Children {
+		rollUpBalance(child, depth+1)
+		node.Balance += child.Balance
+		node.PreviousBalance += child.PreviousBalance
+	}
+	node.Balance = roundMoney(node.Balance)
+	node.PreviousBalance = roundMoney(node.PreviousBalance)
+}
+
+func pruneEmptyNodes(nodes []*BalanceSheetNode) []*BalanceSheetNode {
+	out := nodes[:0]
+	for _, n := range nodes {
+		n.Children = pruneEmptyNodes(n.Children)
+		if n.Balance != 0 || n.PreviousBalance != 0 || len(n.Children) > 0 {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------
+// GENERAL LEDGER
+// ---------------------------------------------------------
+
+type LedgerLine struct {
+	JournalEntryID uint      `json:"journal_entry_id"`
+	EntryNumber    string    `json:"entry_number"`
+	Date           time.Time `json:"date"`
+	Description    string    `json:"description"`
+	ReferenceID    string    `json:"reference_id"`
+	ReferenceType  string    `json:"reference_type"`
+	Debit          float64   `json:"debit"`
+	Credit         float64   `json:"credit"`
+	Balance        float64   `json:"balance"` // Running balance after this line
+}
+
+type LedgerAccount struct {
+	COAID          uint         `json:"coa_id"`
+	Code           string       `json:"code"`
+	Name           string       `json:"name"`
+	Type           string       `json:"type"`
+	OpeningBalance float64      `json:"opening_balance"`
+	TotalDebit     float64      `json:"total_debit"`
+	TotalCredit    float64      `json:"total_credit"`
+	ClosingBalance float64      `json:"closing_balance"`
+	PreviousDebit  float64      `json:"previous_debit"`  // Same-length period before From
+	PreviousCredit float64      `json:"previous_credit"` // Same-length period before From
+	PreviousChange float64      `json:"previous_change"`
+	Lines          []LedgerLine `json:"lines"`
+}
+
+type GeneralLedgerReport struct {
+	Period   ReportPeriod    `json:"period"`
+	Previous ReportPeriod    `json:"previous"`
+	Accounts []LedgerAccount `json:"accounts"`
+}
+
+// GeneralLedger lists every posting per account in the period with running balances.
+// coaID 0 reports all accounts that moved in the period.
+func (s *FinanceService) GeneralLedger(p ReportPeriod, coaID uint) (*GeneralLedgerReport, error) {
+	if p.From == nil {
+		return nil, fmt.Errorf("from_date is required")
+	}
+	prev := p.PreviousPeriod()
+	openingTo := p.From.AddDate(0, 0, -1)
+	opening := s.accountMovements(ReportPeriod{To: openingTo})
+	previous := s.accountMovements(prev)
+
+	var coas []models.COA
+	query := s.DB.Order("code asc")
+	if coaID != 0 {
+		query = query.Where("id = ?", coaID)
+	}
+	query.Find(&coas)
+	if coaID != 0 && len(coas) == 0 {
+		return nil, fmt.Errorf("account not found")
+	}
+
+	type row struct {
+		COAID          uint
+		JournalEntryID uint
+		EntryNumber    string
+		Date           time.Time
+		Description    string
+		ReferenceID    string
+		ReferenceType  string
+		Debit          float64
+		Credit         float64
+	}
+	var rows []row
+	lines := s.DB.Table("journal_items ji").
+		Select("ji.coa_id, je.id AS journal_entry_id, je.entry_number, je.date, je.description, je.reference_id, je.reference_type, ji.debit, ji.credit").
+		Joins("JOIN journal_entries je ON ji.journal_entry_id = je.id").
+		Where("je.date >= ? AND je.date < ?", *p.From, p.To.AddDate(0, 0, 1))
+	if coaID != 0 {
+		lines = lines.Where("ji.coa_id = ?", coaID)
+	}
+	lines.Order("je.date, je.id, ji.id").Scan(&rows)
+
+	byAccount := map[uint][]row{}
+	for _, r := range rows {
+		byAccount[r.COAID] = append(byAccount[r.COAID], r)
+	}
+
+	report := &GeneralLedgerReport{Period: p, Previous: prev, Accounts: []LedgerAccount{}}
+	for _, coa := range coas {
+		postings := byAccount[coa.ID]
+		if coaID == 0 && len(postings) == 0 {
+			continue
+		}
+		acc := LedgerAccount{
+			COAID:          coa.ID,
+			Code:           coa.Code,
+			Name:           coa.Name,
+			Type:           coa.Type,
+			OpeningBalance: naturalBalance(coa.Type, opening[coa.ID].Debit, opening[coa.ID].Credit),
+			PreviousDebit:  roundMoney(previous[coa.ID].Debit),
+			PreviousCredit: roundMoney(previous[coa.ID].Credit),
+			PreviousChange: naturalBalance(coa.Type, previous[coa.ID].Debit, previous[coa.ID].Credit),
+			Lines:          []LedgerLine{},
+		}
+		balance := acc.OpeningBalance
+		for _, r := range postings {
+			balance = roundMoney(balance + naturalBalance(coa.Type, r.Debit, r.Credit))
+			acc.TotalDebit += r.Debit
+			acc.TotalCredit += r.Credit
+			acc.Lines = append(acc.Lines, LedgerLine{
+				JournalEntryID: r.JournalEntryID,
+				EntryNumber:    r.EntryNumber,
+				Date:           r.Date,
+				Description:    r.Description,
+				ReferenceID:    r.ReferenceID,
+				ReferenceType:  r.ReferenceType,
+				Debit:          r.Debit,
+				Credit:         r.Credit,
+				Balance:        balance,
+			})
+		}
+		acc.TotalDebit = roundMoney(acc.TotalDebit)
+		acc.TotalCredit = roundMoney(acc.TotalCredit)
+		acc.ClosingBalance = balance
+		report.Accounts = append(report.Accounts, acc)
+	}
+	return report, nil
+}
+
+// ---------------------------------------------------------
+// CASH FLOW (INDIRECT METHOD)
+// ---------------------------------------------------------
+
+type CashFlowLine struct {
+	Label          string  `json:"label"`
+	COAID          uint    `json:"coa_id,omitempty"`
+	Amount         float64 `json:"amount"`
+	PreviousAmount float64 `json:"previous_amount"`
+}
+
+type CashFlowSection struct {
+	Lines         []CashFlowLine `json:"lines"`
+	Total         float64        `json:"total"`
+	PreviousTotal float64        `json:"previous_total"`
+}
+
+type CashFlowReport struct {
+	Period             ReportPeriod    `json:"period"`
+	Previous           ReportPeriod    `json:"previous"`
+	NetIncome          float64         `json:"net_income"`
+	PreviousNetIncome  float64         `json:"previous_net_income"`
+	Operating          CashFlowSection `json:"operating"`
+	Investing          CashFlowSection `json:"investing"`
+	Financing          CashFlowSection `json:"financing"`
+	NetChange          float64         `json:"net_change"`
+	PreviousNetChange  float64         `json:"previous_net_change"`
+	OpeningCash        float64         `json:"opening_cash"`
+	ClosingCash        float64         `json:"closing_cash"`
+	CashAccountsChange float64         `json:"cash_accounts_change"` // Actual movement of the cash accounts
+	Difference         float64         `json:"difference"`           // Non-zero means an account is misclassified
+	PreviousCashChange float64         `json:"previous_cash_accounts_change"`
+	CashAccountCodes   []string        `json:"cash_account_codes"`
+}
+
+var (
+	investingAccountPattern = regexp.MustCompile(`(?i)aset tetap|peralatan|kendaraan|bangunan|tanah|inventaris kantor|fixed asset|equipment|akumulasi penyusutan`)
+	financingAccountPattern = regexp.MustCompile(`(?i)pinjaman|hutang bank|utang bank|loan|modal|prive|dividen`)
+)
+
+// CashFlow derives cash movements from net income plus the change of every non-cash balance sheet account.
+// Cash accounts are CASH / PRIMARY_BANK, assets named Kas* or Bank*, or the codes in setting cash_account_codes.
+func (s *FinanceService) CashFlow(p ReportPeriod) (*CashFlowReport, error) {
+	if p.From == nil {
+		return nil, fmt.Errorf("from_date is required")
+	}
+	// Year-end closing moves profit into retained earnings without any cash: leave it out
+	prev := p.PreviousPeriod()
+	current := s.accountMovements(p, helpers.JournalTypeClosing)
+	previous := s.accountMovements(prev, helpers.JournalTypeClosing)
+	openingTo := p.From.AddDate(0, 0, -1)
+	opening := s.accountMovements(ReportPeriod{To: openingTo})
+
+	coas := s.loadCOAs()
+	isCash := cashAccountSet(coas)
+
+	report := &CashFlowReport{Period: p, Previous: prev, CashAccountCodes: []string{}}
+	report.Operating.Lines = []CashFlowLine{}
+	report.Investing.Lines = []CashFlowLine{}
+	report.Financing.Lines = []CashFlowLine{}
+
+	var adjustments []CashFlowLine
+	for _, coa := range coas {
+		cur, old := current[coa.ID], previous[coa.ID]
+		switch coa.Type {
+		case "REVENUE":
+			report.NetIncome += naturalBalance(coa.Type, cur.Debit, cur.Credit)
+			report.PreviousNetIncome += naturalBalance(coa.Type, old.Debit, old.Credit)
+			continue
+		case "EXPENSE", "COGS":
+			report.NetIncome -= naturalBalance(coa.Type, cur.Debit, cur.Credit)
+			report.PreviousNetIncome -= naturalBalance(coa.Type, old.Debit, old.Credit)
+			continue
+		}
+
+		if isCash[coa.ID] {
+			report.CashAccountCodes = append(report.CashAccountCodes, coa.Code)
+			report.OpeningCash += naturalBalance(coa.Type, opening[coa.ID].Debit, opening[coa.ID].Credit)
+			report.CashAccountsChange += naturalBalance(coa.Type, cur.Debit, cur.Credit)
+			report.PreviousCashChange += naturalBalance(coa.Type, old.Debit, old.Credit)
+			continue
+		}
+
+		// Cash effect of a balance sheet account: credits bring cash in, debits take it out
+		amount := roundMoney(cur.Credit - cur.Debit)
+		prevAmount := roundMoney(old.Credit - old.Debit)
+		if amount == 0 && prevAmount == 0 {
+			continue
+		}
+		line := CashFlowLine{COAID: coa.ID, Amount: amount, PreviousAmount: prevAmount}
+		switch {
+		case coa.Type == "ASSET" && investingAccountPattern.MatchString(coa.Name):
+			line.Label = "Perolehan / pelepasan " + coa.Name
+			report.Investing.Lines = append(report.Investing.Lines, line)
+		case coa.Type == "EQUITY" || (coa.Type == "LIABILITY" && financingAccountPattern.MatchString(coa.Name)):
+			line.Label = "Perubahan " + coa.Name
+			report.Financing.Lines = append(report.Financing.Lines, line)
+		case coa.Type == "ASSET":
+			line.Label = fmt.Sprintf("(Kenaikan) / penurunan %s", coa.Name)
+			adjustments = append(adjustments, line)
+		default:
+			line.Label = fmt.Sprintf("Kenaikan / (penurunan) %s", coa.Name)
+			adjustments = append(adjustments, line)
+		}
+	}
+
+	report.NetIncome = roundMoney(report.NetIncome)
+	report.PreviousNetIncome = roundMoney(report.PreviousNetIncome)
+	report.Operating.Lines = append(report.Operating.Lines, CashFlowLine{Label: "Laba bersih", Amount: report.NetIncome, PreviousAmount: report.PreviousNetIncome})
+	report.Operating.Lines = append(report.Operating.Lines, adjustments...)
+
+	for _, section := range []*CashFlowSection{&report.Operating, &report.Investing, &report.Financing} {
+		for _, l := range section.Lines {
+			section.Total += l.Amount
+			section.PreviousTotal += l.PreviousAmount
+		}
+		section.Total = roundMoney(section.Total)
+		section.PreviousTotal = roundMoney(section.PreviousTotal)
+	}
+
+	report.NetChange = roundMoney(report.Operating.Total + report.Investing.Total + report.Financing.Total)
+	report.PreviousNetChange = roundMoney(report.Operating.PreviousTotal + report.Investing.PreviousTotal + report.Financing.PreviousTotal)
+	report.OpeningCash = roundMoney(report.OpeningCash)
+	report.CashAccountsChange = roundMoney(report.CashAccountsChange)
+	report.PreviousCashChange = roundMoney(report.PreviousCashChange)
+	report.ClosingCash = roundMoney(report.OpeningCash + report.CashAccountsChange)
+	report.Difference = roundMoney(report.NetChange - report.CashAccountsChange)
+	sort.Strings(report.CashAccountCodes)
+	return report, nil
+}
+
+// cashAccountSet marks the accounts that count as cash and cash equivalents
+func cashAccountSet(coas []models.COA) map[uint]bool {
+	set := map[uint]bool{}
+	if codes := strings.TrimSpace(helpers.GetSetting("cash_account_codes", "")); codes != "" {
+		wanted := map[string]bool{}
+		for _, c := range strings.Split(codes, ",") {
+			wanted[strings.TrimSpace(c)] = true
+		}
+		for _, coa := range coas {
+			if wanted[coa.Code] {
+				set[coa.ID] = true
+			}
+		}
+		return set
+	}
+	for _, coa := range coas {
+		if coa.Type != "ASSET" {
+			continue
+		}
+		key := ""
+		if coa.MappingKey != nil {
+			key = *coa.MappingKey
+		}
+		name := strings.ToLower(coa.Name)
+		if key == "CASH" || key == "PRIMARY_BANK" || strings.HasPrefix(name, "kas") || strings.HasPrefix(name, "bank") {
+			set[coa.ID] = true
+		}
+	}
+	return set
+}
@@ -120,6 +120,7 @@ func (s *FinanceService) GetNextCOACode(coaType string, parentIDStr string) (str
 type JournalInput struct {
 	Description string    `json:"description"`
 	Date        time.Time `json:"date"`
+	IsAdjusting bool      `json:"is_adjusting"` // Adjusting entry into a closed period
 	Items       []struct {
 		COAID  uint    `json:"coa_id"`
 		Debit  float64 `json:"debit"`
@@ -141,36 +142,22 @@ func (s *FinanceService) CreateManualJournal(input JournalInput) (*models.Journa
 		return nil, fmt.Errorf("journal is not balanced (Diff: %.6f)", math.Abs(totalDebit-totalCredit))
 	}
 
-	var entry models.JournalEntry
+	items := make([]models.JournalItem, 0, len(input.Items))
+	for _, item := range input.Items {
+		items = append(items, models.JournalItem{COAID: item.COAID, Debit: item.Debit, Credit: item.Credit})
+	}
+
+	var entry *models.JournalEntry
 	err := s.DB.Transaction(func(tx *gorm.DB) error {
-		entry = models.JournalEntry{
-			Description:   input.Description,
+		var err error
+		entry, err = helpers.PostJournal(tx, helpers.JournalPosting{
 			Date:          input.Date,
-			ReferenceID:   fmt.Sprintf("MAN-%d", time.Now().Unix()),
 			ReferenceType: "MANUAL",
-		}
-
-		if err := tx.Create(&entry).Error; err != nil {
-			return err
-		}
-
-		for _, item := range input.Items {
-			journalItem := models.JournalItem{
-				JournalEntryID: entry.ID,
-				COAID:          item.COAID,
-				Debit:          item.Debit,
-				Credit:         item.Credit,
-			}
-			if err := tx.Create(&journalItem).Error; err != nil {
-				return err
-			}
-
-			// Update COA Balance
-			if err := s.updateCOABalance(tx, item.COAID, item.Debit, item.Credit); err != nil {
-				return err
-			}
-		}
-		return nil
+			Description:   input.Description,
+			IsAdjusting:   input.IsAdjusting,
+			Items:         items,
+		})
+		return err
 	})
 
 	if err != nil {
@@ -178,7 +165,7 @@ func (s *FinanceService) CreateManualJournal(input JournalInput) (*models.Journa
 	}
 
 	helpers.LogAuditSimple(0, "finance", "CREATE", entry.ID, "Manual Journal: "+input.Description)
-	return &entry, nil
+	return entry, nil
 }
 
 // ---------------------------------------------------------
@@ -193,6 +180,9 @@ type ExpenseInput struct {
 	Date        time.Time
 	Attachment  string
 	UserID      uint
+	// RecurringDay > 0 with IsRecurring also starts a monthly schedule on that day, continuing after Date
+	IsRecurring  bool
+	RecurringDay int
 }
 
 // CreateExpense handles expense creation and auto-journal
@@ -200,7 +190,6 @@ func (s *FinanceService) CreateExpense(input ExpenseInput) (*models.Expense, err
 	var expense models.Expense
 
 	err := s.DB.Transaction(func(tx *gorm.DB) error {
-		// 1. Create Expense
 		expense = models.Expense{
 			COAID:       input.COAID,
 			Amount:      input.Amount,
@@ -210,44 +199,28 @@ func (s *FinanceService) CreateExpense(input ExpenseInput) (*models.Expense, err
 			CreatedBy:   input.UserID,
 			Attachment:  input.Attachment,
 		}
-
-		if err := tx.Create(&expense).Error; err != nil {
-			return err
-		}
-
-		// 2. Auto Journal (Credit Bank, Debit Expense)
-		coaBankID, err := helpers.GetPrimaryBankCOA(tx)
-		if err != nil {
-			return fmt.Errorf("no Bank/Cash mapping found")
-		}
-
-		journalEntry := models.JournalEntry{
-			Description:   "Expense: " + input.Description,
-			Date:          input.Date,
-			ReferenceID:   fmt.Sprintf("EXP-%d", expense.ID),
-			ReferenceType: "EXPENSE",
-		}
-
-		if err := tx.Create(&journalEntry).Error; err != nil {
-			return err
-		}
-
-		// Items
-		items := []models.JournalItem{
-			{JournalEntryID: journalEntry.ID, COAID: input.COAID, Debit: input.Amount, Credit: 0}, // Debit Expense
-			{JournalEntryID: journalEntry.ID, COAID: coaBankID, Debit: 0, Credit: input.Amount},   // Credit Bank
-		}
-
-		for _, item := range items {
-			if err := tx.Create(&item).Error; err != nil {
-				return err
+		if input.IsRecurring && input.RecurringDay > 0 {
+			last := startOfDay(input.Date)
+			schedule := models.RecurringExpense{
+				COAID:       input.COAID,
+				Amount:      input.Amount,
+				Vendor:      input.Vendor,
+				Description: input.Description,
+				Frequency:   models.RecurMonthly,
+				DayOfMonth:  input.RecurringDay,
+				StartDate:   last,
+				LastRunDate: &last,
+				IsActive:    true,
+				CreatedBy:   input.UserID,
 			}
-			if err := s.updateCOABalance(tx, item.COAID, item.Debit, item.Credit); err != nil {
+			if err := tx.Create(&schedule).Error; err != nil {
 				return err
 			}
+			expense.IsRecurring = true
+			expense.RecurringDay = input.RecurringDay
+			expense.RecurringExpenseID = &schedule.ID
 		}
-
-		return nil
+		return postExpense(tx, &expense)
 	})
 
 	if err != nil {
@@ -258,6 +231,30 @@ func (s *FinanceService) CreateExpense(input ExpenseInput) (*models.Expense, err
 	return &expense, nil
 }
 
+// postExpense creates the expense and its journal (Debit expense, Credit bank) within a TX
+func postExpense(tx *gorm.DB, expense *models.Expense) error {
+	if err := tx.Create(expense).Error; err != nil {
+		return err
+	}
+
+	coaBankID, err := helpers.GetPrimaryBankCOA(tx)
+	if err != nil {
+		return fmt.Errorf("no Bank/Cash mapping found")
+	}
+
+	_, err = helpers.PostJournal(tx, helpers.JournalPosting{
+		Date:          expense.Date,
+		ReferenceID:   fmt.Sprintf("EXP-%d", expense.ID),
+		ReferenceType: "EXPENSE",
+		Description:   "Expense: " + expense.Description,
+		Items: []models.JournalItem{
+			{COAID: expense.COAID, Debit: expense.Amount, Credit: 0}, // Debit Expense
+			{COAID: coaBankID, Debit: 0, Credit: expense.Amount},     // Credit Bank
+		},
+	})
+	return err
+}
+
 // DeleteExpense handles expense deletion and journal reversal
 func (s *FinanceService) DeleteExpense(id uint, userID uint) error {
 	var expense models.Expense
@@ -266,37 +263,40 @@ func (s *FinanceService) DeleteExpense(id uint, userID uint) error {
 	}
 
 	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// The books of a closed period stay as they are: reverse its journal instead
+		if err := helpers.CheckPostingPeriod(tx, expense.Date, false); err != nil {
+			return fmt.Errorf("%w; reverse the expense journal instead of deleting it", err)
+		}
+
 		// 1. Reversal Journal
 		coaBankID, err := helpers.GetPrimaryBankCOA(tx)
 		if err != nil {
 			return fmt.Errorf("failed to determine bank account for reversal")
 		}
 
-		if coaBankID != 0 {
-			journalEntry := models.JournalEntry{
-				Description:   "Reversal: " + expense.Description,
-				Date:          time.Now(),
-				ReferenceID:   fmt.Sprintf("REV-EXP-%d", expense.ID),
-				ReferenceType: "REVERSAL",
-			}
-			if err := tx.Create(&journalEntry).Error; err != nil {
-				return err
-			}
-
-			// Items (Swapped Debit/Credit)
-			items := []models.JournalItem{
-				{JournalEntryID: journalEntry.ID, COAID: coaBankID, Debit: expense.Amount, Credit: 0},
-				{JournalEntryID: journalEntry.ID, COAID: expense.COAID, Debit: 0, Credit: expense.Amount},
-			}
+		var original models.JournalEntry
+		tx.Where("reference_type = ? AND reference_id = ?", "EXPENSE", fmt.Sprintf("EXP-%d", expense.ID)).First(&original)
+		var reversalOf *uint
+		if original.ID != 0 {
+			reversalOf = &original.ID
+		}
 
-			for _, item := range items {
-				if err := tx.Create(&item).Error; err != nil {
-					return err
-				}
-				if err := s.updateCOABalance(tx, item.COAID, item.Debit, item.Credit); err != nil {
-					return err
-				}
-			}
+		reversal, err := helpers.PostJournal(tx, helpers.JournalPosting{
+			Date:          time.Now(),
+			ReferenceID:   fmt.Sprintf("REV-EXP-%d", expense.ID),
+			ReferenceType: "REVERSAL",
+			Description:   "Reversal: " + expense.Description,
+			ReversalOfID:  reversalOf,
+			Items: []models.JournalItem{
+				{COAID: coaBankID, Debit: expense.Amount, Credit: 0},
+				{COAID: expense.COAID, Debit: 0, Credit: expense.Amount},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if original.ID != 0 {
+			tx.Model(&original).Where("reversed_by_id IS NULL").Update("reversed_by_id", reversal.ID)
 		}
 
 		// 2. Delete Expense
@@ -315,21 +315,6 @@ func (s *FinanceService) DeleteExpense(id uint, userID uint) error {
 	return nil
 }
 
-// Helper: Update COA Balance
-func (s *FinanceService) updateCOABalance(tx *gorm.DB, coaID uint, debit, credit float64) error {
-	var coa models.COA
-	if err := tx.First(&coa, coaID).Error; err != nil {
-		return err
-	}
-
-	if coa.Type == "ASSET" || coa.Type == "EXPENSE" || coa.Type == "COGS" {
-		coa.Balance += debit - credit
-	} else {
-		coa.Balance += credit - debit
-	}
-	return tx.Save(&coa).Error
-}
-
 // GetFinanceStats calculates dashboard stats
 func (s *FinanceService) GetFinanceStats(period string) (map[string]interface{}, error) {
 	var sinceTime time.Time
@@ -382,7 +367,7 @@ func (s *FinanceService) GetFinanceStats(period string) (map[string]interface{},
 		Select("c.name, COALESCE(SUM(ji.debit - ji.credit), 0) as value").
 		Joins("JOIN coas c ON ji.coa_id = c.id").
 		Joins("JOIN journal_entries je ON ji.journal_entry_id = je.id").
-		Where("(c.type = ? OR c.type = ?)", "EXPENSE", "COGS")
+		Where("(c.type = ? OR c.type = ?) AND je.reference_type <> ?", "EXPENSE", "COGS", helpers.JournalTypeClosing)
 
 	if isFiltered {
 		breakdownQuery = breakdownQuery.Where("je.date >= ?", sinceTime)
@@ -405,7 +390,7 @@ func (s *FinanceService) sumJournalType(coaType string, since time.Time, isFilte
 	query := s.DB.Table("journal_items ji").
 		Joins("JOIN coas c ON ji.coa_id = c.id").
 		Joins("JOIN journal_entries je ON ji.journal_entry_id = je.id").
-		Where("c.type = ?", coaType)
+		Where("c.type = ? AND je.reference_type <> ?", coaType, helpers.JournalTypeClosing)
 	if isFiltered {
 		query = query.Where("je.date >= ?", since)
 	}
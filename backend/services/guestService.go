@@ -0,0 +1,275 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// GuestCheckoutTokenTTL is how long a verified email may keep quoting and checking out
+const GuestCheckoutTokenTTL = 2 * time.Hour
+
+// GuestClaimTokenTTL is how long the emailed account claim link stays valid
+const GuestClaimTokenTTL = 30 * time.Minute
+
+type GuestService struct {
+	DB *gorm.DB
+}
+
+func NewGuestService() *GuestService {
+	return &GuestService{
+		DB: config.DB,
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// SendVerificationCode emails a one-time code (VerificationCode) to a guest checkout email.
+// Emails that already belong to a registered account have to log in instead.
+func (s *GuestService) SendVerificationCode(email string) error {
+	email = normalizeEmail(email)
+
+	var existing models.User
+	if err := s.DB.Where("LOWER(email) = ?", email).First(&existing).Error; err == nil && existing.Status != models.UserStatusGuest {
+		return fmt.Errorf("Email sudah terdaftar, silakan login untuk melanjutkan checkout")
+	}
+
+	otp := helpers.GenerateOTP(6)
+	if err := s.DB.Create(&models.VerificationCode{
+		Email:     email,
+		Code:      otp,
+		ExpiresAt: time.Now().Add(15 * time.Minute),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to create verification code")
+	}
+
+	body := fmt.Sprintf(`
+		<h3>Guest Checkout Verification</h3>
+		<p>Your verification code is: <b>%s</b></p>
+		<p>This code expires in 15 minutes.</p>
+		<p>If you did not request this, please ignore.</p>
+	`, otp)
+	go helpers.SendEmail(email, "Guest Checkout Code - Warung Forza", body)
+
+	return nil
+}
+
+// VerifyEmail checks the code, makes sure the guest account exists and returns a guest checkout token
+func (s *GuestService) VerifyEmail(email, code string) (string, *models.User, error) {
+	email = normalizeEmail(email)
+
+	var user *models.User
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var codeEntry models.VerificationCode
+		if err := tx.Where("email = ? AND code = ? AND used = ? AND expires_at > ?", email, strings.TrimSpace(code), false, time.Now()).First(&codeEntry).Error; err != nil {
+			return fmt.Errorf("Invalid or expired verification code")
+		}
+		if err := tx.Model(&codeEntry).Update("used", true).Error; err != nil {
+			return err
+		}
+
+		var err error
+		user, err = s.getOrCreateGuestUser(tx, email)
+		return err
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, err := helpers.SignGuestToken(helpers.GuestTokenCheckout, email, 0, GuestCheckoutTokenTTL)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign guest token")
+	}
+	return token, user, nil
+}
+
+func (s *GuestService) getOrCreateGuestUser(tx *gorm.DB, email string) (*models.User, error) {
+	var user models.User
+	err := tx.Where("LOWER(email) = ?", email).First(&user).Error
+	if err == nil {
+		if user.Status != models.UserStatusGuest {
+			return nil, fmt.Errorf("Email sudah terdaftar, silakan login untuk melanjutkan checkout")
+		}
+		return &user, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	// Unusable random password: the account only becomes loginable once it is claimed
+	password, _ := bcrypt.GenerateFromPassword([]byte(helpers.GenerateRandomString(32)), bcrypt.DefaultCost)
+	var role models.Role
+	tx.Where("slug = ?", models.RoleUser).First(&role)
+
+	user = models.User{
+		Username: "guest_" + strings.ToLower(helpers.GenerateRandomString(10)),
+		Email:    email,
+		Password: string(password),
+		FullName: strings.Split(email, "@")[0],
+		RoleID:   role.ID,
+		Status:   models.UserStatusGuest,
+	}
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GuestUser loads the guest account behind a verified guest email
+func (s *GuestService) GuestUser(email string) (*models.User, error) {
+	var user models.User
+	if err := s.DB.Preload("Role.Permissions").Where("LOWER(email) = ? AND status = ?", normalizeEmail(email), models.UserStatusGuest).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("guest session not found, please verify your email again")
+	}
+	return &user, nil
+}
+
+// OrderAccessToken signs the tracking / invoice link sent to a guest for one order
+func (s *GuestService) OrderAccessToken(order models.Order, email string) (string, error) {
+	return helpers.SignGuestToken(helpers.GuestTokenOrderAccess, normalizeEmail(email), order.ID, helpers.GuestOrderTokenTTL())
+}
+
+// SendOrderAccessLink emails the guest a signed link to track the order and download its invoices
+func (s *GuestService) SendOrderAccessLink(order models.Order, email, token string) {
+	link := fmt.Sprintf("%s/guest/orders/%s?token=%s", helpers.GetFrontendURL(), order.OrderNumber, token)
+	body := fmt.Sprintf(`
+		<h3>Thank you for your order %s</h3>
+		<p>You checked out as a guest. Use the link below to track your order and download invoices:</p>
+		<p><a href="%s">%s</a></p>
+		<p>Want to see all your orders in one place? Request an account claim link from the order page, we will email it to you.</p>
+	`, order.OrderNumber, link, link)
+	go helpers.SendEmail(email, "Your Order "+order.OrderNumber+" - Warung Forza", body)
+}
+
+// SendClaimLink emails a short-lived account claim link to a guest email. Order links travel in URLs,
+// so claiming needs this separate token that only the mailbox owner receives. Unknown or already
+// claimed emails are ignored silently.
+func (s *GuestService) SendClaimLink(email string) error {
+	email = normalizeEmail(email)
+
+	var user models.User
+	if err := s.DB.Where("LOWER(email) = ? AND status = ?", email, models.UserStatusGuest).First(&user).Error; err != nil {
+		return nil
+	}
+
+	token, err := helpers.SignGuestToken(helpers.GuestTokenClaim, email, 0, GuestClaimTokenTTL)
+	if err != nil {
+		return fmt.Errorf("failed to sign claim token")
+	}
+	link := fmt.Sprintf("%s/guest/claim?token=%s", helpers.GetFrontendURL(), token)
+	body := fmt.Sprintf(`
+		<h3>Claim your Warung Forza account</h3>
+		<p>Choose a username and password to see all your orders in one place:</p>
+		<p><a href="%s">%s</a></p>
+		<p>This link expires in 30 minutes. If you did not request this, please ignore.</p>
+	`, link, link)
+	go helpers.SendEmail(email, "Claim Your Account - Warung Forza", body)
+	return nil
+}
+
+// ClaimGuestInput turns a guest account into a regular, loginable account
+type ClaimGuestInput struct {
+	Token    string `json:"token" binding:"required"` // Account claim token from SendClaimLink
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+	FullName string `json:"full_name"`
+	Phone    string `json:"phone"`
+}
+
+// ClaimAccount activates the guest account of the claim token's email with the chosen credentials
+// and merges the guest's carts and newsletter subscription into it
+func (s *GuestService) ClaimAccount(input ClaimGuestInput) (*models.User, error) {
+	claims, err := helpers.ParseGuestToken(input.Token, helpers.GuestTokenClaim)
+	if err != nil {
+		return nil, err
+	}
+
+	username := strings.TrimSpace(input.Username)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(strings.TrimSpace(input.Password)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encrypt password")
+	}
+
+	var user models.User
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("LOWER(email) = ?", claims.Email).First(&user).Error; err != nil {
+			return fmt.Errorf("Guest account not found")
+		}
+		if user.Status != models.UserStatusGuest {
+			return fmt.Errorf("Akun ini sudah diklaim, silakan login")
+		}
+
+		var taken int64
+		tx.Model(&models.User{}).Where("username = ? AND id <> ?", username, user.ID).Count(&taken)
+		if taken > 0 {
+			return fmt.Errorf("Username already taken")
+		}
+
+		updates := map[string]interface{}{
+			"username": username,
+			"password": string(hashedPassword),
+			"status":   "active",
+		}
+		if strings.TrimSpace(input.FullName) != "" {
+			updates["full_name"] = strings.TrimSpace(input.FullName)
+		}
+		if strings.TrimSpace(input.Phone) != "" {
+			updates["phone"] = strings.TrimSpace(input.Phone)
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		return s.MergeGuestRecords(tx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.DB.Preload("Role.Permissions").First(&user, user.ID)
+	helpers.LogAuditSimple(user.ID, "User", "ClaimGuest", user.ID, "Guest account claimed: "+user.Email)
+	return &user, nil
+}
+
+// MergeGuestRecords attaches what a guest left behind under their email to the account: anonymous
+// abandoned carts and the newsletter subscription. Guest orders and invoices already belong to the
+// account, it is the same user row.
+func (s *GuestService) MergeGuestRecords(tx *gorm.DB, user models.User) error {
+	email := normalizeEmail(user.Email)
+
+	if err := tx.Model(&models.AbandonedCart{}).
+		Where("user_id IS NULL AND LOWER(email) = ?", email).
+		Update("user_id", user.ID).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.NewsletterSubscriber{}).
+		Where("LOWER(email) = ? AND (user_id IS NULL OR user_id <> ?)", email, user.ID).
+		Update("user_id", user.ID).Error; err != nil {
+		return err
+	}
+
+	var profile models.CustomerProfile
+	if err := tx.Where("user_id = ?", user.ID).First(&profile).Error; err == gorm.ErrRecordNotFound {
+		var subscribed int64
+		tx.Model(&models.NewsletterSubscriber{}).Where("user_id = ? AND status = ?", user.ID, "subscribed").Count(&subscribed)
+		if err := tx.Create(&models.CustomerProfile{
+			UserID:        user.ID,
+			Phone:         user.Phone,
+			NewsletterSub: subscribed > 0,
+			RestockNotify: true,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
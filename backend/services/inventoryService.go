@@ -0,0 +1,567 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// InventoryService owns every stock hold: reserve, consume (reserved -> sold) and release.
+// Each hold is a StockReservation row, and reserved_qty on the product/edition is only ever moved
+// together with those rows, so it can always be derived from the active ones (see Reconcile).
+// Construct it on the caller's transaction: (&InventoryService{DB: tx}).
+type InventoryService struct {
+	DB *gorm.DB
+}
+
+func NewInventoryService() *InventoryService {
+	return &InventoryService{
+		DB: config.DB,
+	}
+}
+
+// ErrInsufficientStock is returned when a hold doesn't fit in (stock - reserved_qty)
+var ErrInsufficientStock = errors.New("insufficient stock available")
+
+// HoldInput describes a new hold. Order holds carry the order line they belong to; cart holds
+// have no order and a TTL after which the cleanup worker releases them.
+type HoldInput struct {
+	ProductID    uint
+	VariantID    *uint
+	Quantity     int
+	OrderID      uint
+	OrderItemID  *uint
+	Source       string        // cart, checkout, pos, manual_order, order_edit
+	TTL          time.Duration // 0 = held until the order consumes or releases it
+	MovementType string        // Stock movement logged with the hold (default "reservation")
+	RefType      string        // Stock movement reference, empty = no movement (cart holds)
+	RefID        string
+	Note         string
+	UserID       *uint
+}
+
+// Hold atomically reserves stock and records the reservation row, one per location the units are
+// held at (see allocate). Only order lines are split over several locations; the first row is returned.
+func (s *InventoryService) Hold(input HoldInput) (*models.StockReservation, error) {
+	if input.Quantity <= 0 {
+		return nil, fmt.Errorf("invalid quantity for product %d", input.ProductID)
+	}
+
+	reserved, err := helpers.ReserveStock(s.DB, input.ProductID, input.VariantID, input.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		return nil, ErrInsufficientStock
+	}
+
+	allocations, err := s.allocate(input)
+	if err != nil {
+		return nil, err
+	}
+	if allocations == nil {
+		return nil, ErrInsufficientStock
+	}
+
+	var first *models.StockReservation
+	for _, allocation := range allocations {
+		locationID := allocation.LocationID
+		reservation := models.StockReservation{
+			OrderID:     input.OrderID,
+			OrderItemID: input.OrderItemID,
+			ProductID:   input.ProductID,
+			VariantID:   input.VariantID,
+			LocationID:  &locationID,
+			Quantity:    allocation.Quantity,
+			Status:      models.ReservationReserved,
+			Source:      input.Source,
+		}
+		if input.TTL > 0 {
+			expiresAt := time.Now().Add(input.TTL)
+			reservation.ExpiresAt = &expiresAt
+		}
+		if err := s.DB.Create(&reservation).Error; err != nil {
+			return nil, err
+		}
+
+		if input.RefType != "" {
+			movementType := input.MovementType
+			if movementType == "" {
+				movementType = "reservation"
+			}
+			err = helpers.RecordLocationStockMovement(s.DB, locationID, input.ProductID, input.VariantID, allocation.Quantity, "reserved", movementType, input.RefType, input.RefID, input.Note, input.UserID)
+		} else {
+			err = helpers.AdjustLocationStock(s.DB, locationID, input.ProductID, input.VariantID, 0, allocation.Quantity)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			first = &reservation
+		}
+	}
+
+	return first, nil
+}
+
+// allocate decides where a hold's units come from. PO slots are not on a shelf yet and are all
+// counted at the default location; ready stock follows the online locations' priority order.
+func (s *InventoryService) allocate(input HoldInput) ([]helpers.LocationAllocation, error) {
+	var product models.Product
+	if err := s.DB.Select("id", "product_type").First(&product, input.ProductID).Error; err != nil {
+		return nil, err
+	}
+	if product.ProductType == "po" {
+		locationID, err := helpers.DefaultLocationID(s.DB)
+		if err != nil {
+			return nil, err
+		}
+		return []helpers.LocationAllocation{{LocationID: locationID, Quantity: input.Quantity}}, nil
+	}
+	return helpers.AllocateStock(s.DB, input.ProductID, input.VariantID, input.Quantity, input.OrderItemID != nil)
+}
+
+// heldAt sums quantities per reservation location (0 = the default location, for rows that predate locations)
+type heldAt []helpers.LocationAllocation
+
+func (h *heldAt) add(locationID *uint, qty int) {
+	var id uint
+	if locationID != nil {
+		id = *locationID
+	}
+	for i := range *h {
+		if (*h)[i].LocationID == id {
+			(*h)[i].Quantity += qty
+			return
+		}
+	}
+	*h = append(*h, helpers.LocationAllocation{LocationID: id, Quantity: qty})
+}
+
+// HoldOrderLine reserves a saved order line's full quantity
+func (s *InventoryService) HoldOrderLine(order models.Order, item models.OrderItem, source string, userID *uint) error {
+	_, err := s.Hold(HoldInput{
+		ProductID:   item.ProductID,
+		VariantID:   item.VariantID,
+		Quantity:    item.Quantity,
+		OrderID:     order.ID,
+		OrderItemID: &item.ID,
+		Source:      source,
+		RefType:     "ORDER",
+		RefID:       order.OrderNumber,
+		Note:        fmt.Sprintf("Order reservation (%s)", source),
+		UserID:      userID,
+	})
+	return err
+}
+
+// lineReservations returns every reservation row of an order line, newest first
+func (s *InventoryService) lineReservations(orderID, itemID uint) ([]models.StockReservation, error) {
+	var rows []models.StockReservation
+	err := s.DB.Where("order_id = ? AND order_item_id = ?", orderID, itemID).Order("id DESC").Find(&rows).Error
+	return rows, err
+}
+
+// ReleaseLine gives qty reserved units of an order line back to the shelf. Partial releases split
+// the active row so the ledger keeps what is still held. Lines reserved before the ledger existed
+// have no rows; their reserved_qty is decremented directly, as it used to be.
+func (s *InventoryService) ReleaseLine(order models.Order, item models.OrderItem, qty int, movementType, note string, userID *uint) error {
+	if qty <= 0 {
+		return nil
+	}
+
+	rows, err := s.lineReservations(order.ID, item.ID)
+	if err != nil {
+		return err
+	}
+
+	releasedBy := "system"
+	if userID != nil && *userID != 0 {
+		releasedBy = "admin"
+	}
+
+	released := qty
+	var locations heldAt
+	if len(rows) == 0 {
+		locations.add(nil, qty)
+	} else {
+		released = 0
+		now := time.Now()
+		for _, row := range rows {
+			if released == qty {
+				break
+			}
+			if row.Status != models.ReservationReserved {
+				continue
+			}
+
+			take := row.Quantity
+			if take > qty-released {
+				take = qty - released
+			}
+			if take == row.Quantity {
+				if err := s.DB.Model(&row).Updates(map[string]interface{}{
+					"status":      models.ReservationReleased,
+					"released_at": now,
+					"released_by": releasedBy,
+				}).Error; err != nil {
+					return err
+				}
+			} else {
+				if err := s.DB.Model(&row).Update("quantity", row.Quantity-take).Error; err != nil {
+					return err
+				}
+				split := row
+				split.ID = 0
+				split.Quantity = take
+				split.Status = models.ReservationReleased
+				split.ReleasedAt = &now
+				split.ReleasedBy = releasedBy
+				if err := s.DB.Create(&split).Error; err != nil {
+					return err
+				}
+			}
+			released += take
+			locations.add(row.LocationID, take)
+		}
+		if released == 0 {
+			return nil // Already consumed or released
+		}
+	}
+
+	if err := helpers.AdjustStock(s.DB, item.ProductID, item.VariantID, map[string]interface{}{"reserved_qty": gorm.Expr("GREATEST(reserved_qty - ?, 0)", released)}); err != nil {
+		return err
+	}
+	for _, at := range locations {
+		if err := helpers.RecordLocationStockMovement(s.DB, at.LocationID, item.ProductID, item.VariantID, -at.Quantity, "reserved", movementType, "ORDER", order.OrderNumber, note, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReleaseOrder releases every hold of an order: its open lines (settled and forfeited lines hold
+// nothing any more) plus any order-level cart holds that were linked to it
+func (s *InventoryService) ReleaseOrder(order models.Order, movementType, note string, userID *uint) error {
+	for _, item := range order.Items {
+		if item.SettledAt != nil || item.ForfeitedAt != nil {
+			continue
+		}
+		if err := s.ReleaseLine(order, item, item.Quantity, movementType, note, userID); err != nil {
+			return err
+		}
+	}
+
+	var orphans []models.StockReservation
+	s.DB.Where("order_id = ? AND order_item_id IS NULL AND status = ?", order.ID, models.ReservationReserved).Find(&orphans)
+	for _, r := range orphans {
+		if err := s.releaseRow(r, models.ReservationReleased, "system"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConsumeLine moves a fully paid order line from reserved to sold: physical stock goes down by what
+// the line still holds, at the locations it was held at, and those holds are marked consumed.
+// A line whose holds are all consumed or released already has nothing left to sell, so a second
+// call (retried callback, edit-then-pay) changes nothing.
+func (s *InventoryService) ConsumeLine(order models.Order, item models.OrderItem, userID *uint) error {
+	rows, err := s.lineReservations(order.ID, item.ID)
+	if err != nil {
+		return err
+	}
+
+	held, locations := consumableHolds(rows, item.Quantity)
+	if held == 0 {
+		return nil
+	}
+
+	if err := helpers.AdjustStock(s.DB, item.ProductID, item.VariantID, map[string]interface{}{
+		"stock":        gorm.Expr("stock - ?", held),
+		"reserved_qty": gorm.Expr("GREATEST(reserved_qty - ?, 0)", held),
+	}); err != nil {
+		return err
+	}
+
+	if len(rows) > 0 {
+		if err := s.DB.Model(&models.StockReservation{}).
+			Where("order_id = ? AND order_item_id = ? AND status = ?", order.ID, item.ID, models.ReservationReserved).
+			Updates(map[string]interface{}{"status": models.ReservationConsumed, "consumed_at": time.Now()}).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, at := range locations {
+		if err := helpers.RecordLocationStockMovement(s.DB, at.LocationID, item.ProductID, item.VariantID, -at.Quantity, "reserved", "sale", "ORDER", order.OrderNumber, "Reservation consumed (paid)", userID); err != nil {
+			return err
+		}
+	}
+	for _, at := range locations {
+		if err := helpers.RecordLocationStockMovement(s.DB, at.LocationID, item.ProductID, item.VariantID, -at.Quantity, "physical", "sale", "ORDER", order.OrderNumber, "Order line paid", userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumableHolds is what paying a line sells: its active holds per location. Lines reserved before
+// the ledger existed have no rows and sell their full quantity at the default location.
+func consumableHolds(rows []models.StockReservation, quantity int) (int, heldAt) {
+	var locations heldAt
+	if len(rows) == 0 {
+		if quantity > 0 {
+			locations.add(nil, quantity)
+		}
+		return max(quantity, 0), locations
+	}
+	held := 0
+	for _, row := range rows {
+		if row.Status == models.ReservationReserved {
+			held += row.Quantity
+			locations.add(row.LocationID, row.Quantity)
+		}
+	}
+	return held, locations
+}
+
+// ConsumeReservation turns a single active hold into a sale (cart holds paid outside an order line)
+func (s *InventoryService) ConsumeReservation(reservationID uint) error {
+	var reservation models.StockReservation
+	if err := s.DB.First(&reservation, reservationID).Error; err != nil {
+		return err
+	}
+	if reservation.Status != models.ReservationReserved {
+		return errors.New("reservation is no longer active")
+	}
+
+	if err := helpers.AdjustStock(s.DB, reservation.ProductID, reservation.VariantID, map[string]interface{}{
+		"stock":        gorm.Expr("stock - ?", reservation.Quantity),
+		"reserved_qty": gorm.Expr("GREATEST(reserved_qty - ?, 0)", reservation.Quantity),
+	}); err != nil {
+		return err
+	}
+	if err := s.adjustHeldLocation(reservation, -reservation.Quantity, -reservation.Quantity); err != nil {
+		return err
+	}
+	return s.DB.Model(&reservation).Updates(map[string]interface{}{
+		"status":      models.ReservationConsumed,
+		"consumed_at": time.Now(),
+	}).Error
+}
+
+// ReleaseReservation releases a single hold; already consumed/released rows are left alone
+func (s *InventoryService) ReleaseReservation(reservationID uint, releasedBy string) error {
+	var reservation models.StockReservation
+	if err := s.DB.First(&reservation, reservationID).Error; err != nil {
+		return err
+	}
+	if reservation.Status != models.ReservationReserved {
+		return nil
+	}
+
+	status := models.ReservationReleased
+	if releasedBy == "system_expiry" {
+		status = models.ReservationExpired
+	}
+	return s.releaseRow(reservation, status, releasedBy)
+}
+
+func (s *InventoryService) releaseRow(reservation models.StockReservation, status models.ReservationStatus, releasedBy string) error {
+	if err := helpers.AdjustStock(s.DB, reservation.ProductID, reservation.VariantID, map[string]interface{}{"reserved_qty": gorm.Expr("GREATEST(reserved_qty - ?, 0)", reservation.Quantity)}); err != nil {
+		return err
+	}
+	if err := s.adjustHeldLocation(reservation, 0, -reservation.Quantity); err != nil {
+		return err
+	}
+	return s.DB.Model(&reservation).Updates(map[string]interface{}{
+		"status":      status,
+		"released_at": time.Now(),
+		"released_by": releasedBy,
+	}).Error
+}
+
+// adjustHeldLocation moves the stock level of the location a single hold sits at
+func (s *InventoryService) adjustHeldLocation(reservation models.StockReservation, stockDelta, reservedDelta int) error {
+	var locationID uint
+	if reservation.LocationID != nil {
+		locationID = *reservation.LocationID
+	}
+	locationID, err := helpers.ResolveLocationID(s.DB, locationID)
+	if err != nil {
+		return err
+	}
+	return helpers.AdjustLocationStock(s.DB, locationID, reservation.ProductID, reservation.VariantID, stockDelta, reservedDelta)
+}
+
+// ReleaseExpired releases every hold past its deadline and returns them
+func (s *InventoryService) ReleaseExpired() ([]models.StockReservation, error) {
+	var expired []models.StockReservation
+	if err := s.DB.Where("status = ? AND expires_at IS NOT NULL AND expires_at < ?", models.ReservationReserved, time.Now()).Find(&expired).Error; err != nil {
+		return nil, err
+	}
+
+	var released []models.StockReservation
+	for _, r := range expired {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			return (&InventoryService{DB: tx}).ReleaseReservation(r.ID, "system_expiry")
+		})
+		if err != nil {
+			log.Printf("❌ Failed to release expired reservation %d: %v", r.ID, err)
+			continue
+		}
+		released = append(released, r)
+	}
+	return released, nil
+}
+
+// ============================================
+// RECONCILIATION
+// ============================================
+
+// StockDrift is a product/edition whose reserved_qty disagrees with its active reservations
+type StockDrift struct {
+	ProductID   uint   `json:"product_id"`
+	VariantID   *uint  `json:"variant_id,omitempty"`
+	Name        string `json:"name"`
+	ReservedQty int    `json:"reserved_qty"` // What the stock row says
+	Expected    int    `json:"expected"`     // Sum of active reservations
+}
+
+// ReconcileReport is the outcome of a reconciliation run
+type ReconcileReport struct {
+	Drifts     []StockDrift `json:"drifts"`
+	Unledgered int          `json:"unledgered"` // Open order lines reserved before the ledger existed
+	Backfilled int          `json:"backfilled"`
+	Fixed      bool         `json:"fixed"`
+}
+
+// Reconcile compares reserved_qty on every product and edition with the sum of its active
+// reservations. Open order lines that were reserved before the ledger existed count as held.
+// With fix, those lines get a "backfill" reservation row and every drifting reserved_qty is
+// overwritten with the ledger value.
+func (s *InventoryService) Reconcile(fix bool) (*ReconcileReport, error) {
+	report := &ReconcileReport{Fixed: fix}
+
+	run := func(tx *gorm.DB) error {
+		// 1. Lines still holding stock the old way: unpaid, unforfeited, no reservation row at all
+		var unledgered []struct {
+			OrderItemID uint
+			OrderID     uint
+			ProductID   uint
+			VariantID   *uint
+			Quantity    int
+		}
+		if err := tx.Table("order_items").
+			Select("order_items.id AS order_item_id, order_items.order_id, order_items.product_id, order_items.variant_id, order_items.quantity").
+			Joins("JOIN orders ON orders.id = order_items.order_id").
+			Where("orders.status NOT IN ?", []string{"cancelled", "completed"}).
+			Where("orders.payment_status NOT IN ?", []string{"paid", "refunded", "refunded_partial"}).
+			Where("order_items.settled_at IS NULL AND order_items.forfeited_at IS NULL").
+			Where("NOT EXISTS (SELECT 1 FROM stock_reservations r WHERE r.order_item_id = order_items.id)").
+			Scan(&unledgered).Error; err != nil {
+			return err
+		}
+		report.Unledgered = len(unledgered)
+
+		// 2. The ledger
+		var active []models.StockReservation
+		if err := tx.Where("status = ?", models.ReservationReserved).Find(&active).Error; err != nil {
+			return err
+		}
+
+		expected := map[cartLineKey]int{}
+		for _, r := range active {
+			expected[lineKey(r.ProductID, r.VariantID)] += r.Quantity
+		}
+		for _, line := range unledgered {
+			expected[lineKey(line.ProductID, line.VariantID)] += line.Quantity
+			if fix {
+				itemID := line.OrderItemID
+				if err := tx.Create(&models.StockReservation{
+					OrderID:     line.OrderID,
+					OrderItemID: &itemID,
+					ProductID:   line.ProductID,
+					VariantID:   line.VariantID,
+					Quantity:    line.Quantity,
+					Status:      models.ReservationReserved,
+					Source:      "backfill",
+				}).Error; err != nil {
+					return err
+				}
+				report.Backfilled++
+			}
+		}
+
+		// 3. Editions first, then products (whose totals roll up from their editions)
+		var products []models.Product
+		if err := tx.Select("id", "name", "reserved_qty").Find(&products).Error; err != nil {
+			return err
+		}
+		names := map[uint]string{}
+		for _, p := range products {
+			names[p.ID] = p.Name
+		}
+
+		var variants []models.ProductVariant
+		if err := tx.Find(&variants).Error; err != nil {
+			return err
+		}
+		variantTotals := map[uint]int{}
+		for _, v := range variants {
+			variantID := v.ID
+			want := expected[lineKey(v.ProductID, &variantID)]
+			variantTotals[v.ProductID] += want
+			if v.ReservedQty != want {
+				report.Drifts = append(report.Drifts, StockDrift{
+					ProductID: v.ProductID, VariantID: &variantID, Name: names[v.ProductID] + " - " + v.Name,
+					ReservedQty: v.ReservedQty, Expected: want,
+				})
+			}
+		}
+
+		for _, p := range products {
+			want, hasVariants := variantTotals[p.ID]
+			if !hasVariants {
+				want = expected[lineKey(p.ID, nil)]
+			}
+			if p.ReservedQty != want {
+				report.Drifts = append(report.Drifts, StockDrift{
+					ProductID: p.ID, Name: p.Name, ReservedQty: p.ReservedQty, Expected: want,
+				})
+			}
+		}
+
+		if !fix {
+			return nil
+		}
+		for _, d := range report.Drifts {
+			if err := helpers.StockRow(tx, d.ProductID, d.VariantID).Update("reserved_qty", d.Expected).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if fix {
+		err = s.DB.Transaction(run)
+	} else {
+		err = run(s.DB)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if fix && len(report.Drifts) > 0 {
+		helpers.Cache.Flush()
+	}
+	return report, nil
+}
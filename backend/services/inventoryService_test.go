@@ -0,0 +1,114 @@
+package services
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	"forzashop/backend/models"
+)
+
+func TestHeldAtAdd(t *testing.T) {
+	type hold struct {
+		location *uint
+		qty      int
+	}
+	loc := func(id uint) *uint { return &id }
+
+	tests := []struct {
+		name  string
+		holds []hold
+		want  heldAt
+	}{
+		{
+			name:  "rows without a location count at the default",
+			holds: []hold{{nil, 2}, {nil, 3}},
+			want:  heldAt{{LocationID: 0, Quantity: 5}},
+		},
+		{
+			name:  "summed per location in first-seen order",
+			holds: []hold{{loc(2), 1}, {nil, 4}, {loc(2), 2}, {loc(1), 1}},
+			want:  heldAt{{LocationID: 2, Quantity: 3}, {LocationID: 0, Quantity: 4}, {LocationID: 1, Quantity: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got heldAt
+			for _, h := range tt.holds {
+				got.add(h.location, h.qty)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsumableHolds(t *testing.T) {
+	loc := func(id uint) *uint { return &id }
+	row := func(status models.ReservationStatus, qty int, location *uint) models.StockReservation {
+		return models.StockReservation{Status: status, Quantity: qty, LocationID: location}
+	}
+
+	tests := []struct {
+		name      string
+		rows      []models.StockReservation
+		quantity  int
+		wantHeld  int
+		wantAtLoc heldAt
+	}{
+		{"legacy line without rows", nil, 3, 3, heldAt{{LocationID: 0, Quantity: 3}}},
+		{"all reserved", []models.StockReservation{row(models.ReservationReserved, 2, loc(1)), row(models.ReservationReserved, 1, loc(2))}, 3, 3,
+			heldAt{{LocationID: 1, Quantity: 2}, {LocationID: 2, Quantity: 1}}},
+		{"already consumed", []models.StockReservation{row(models.ReservationConsumed, 3, loc(1))}, 3, 0, nil},
+		{"released", []models.StockReservation{row(models.ReservationReleased, 3, nil)}, 3, 0, nil},
+		{"only the remaining hold", []models.StockReservation{row(models.ReservationReserved, 1, nil), row(models.ReservationConsumed, 2, nil)}, 3, 1,
+			heldAt{{LocationID: 0, Quantity: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			held, locations := consumableHolds(tt.rows, tt.quantity)
+			if held != tt.wantHeld {
+				t.Errorf("held = %d, want %d", held, tt.wantHeld)
+			}
+			if !reflect.DeepEqual(locations, tt.wantAtLoc) {
+				t.Errorf("locations = %+v, want %+v", locations, tt.wantAtLoc)
+			}
+		})
+	}
+}
+
+func TestConsumeLineTwiceDeductsOnce(t *testing.T) {
+	order := models.Order{ID: 1, OrderNumber: "FORZA-2026-10-00001"}
+	itemID := uint(10)
+	item := models.OrderItem{ID: itemID, OrderID: 1, ProductID: 5, Quantity: 2}
+	cols := []string{"id", "order_id", "order_item_id", "product_id", "quantity", "status"}
+
+	tests := []struct {
+		name       string
+		status     models.ReservationStatus
+		wantAdjust bool
+	}{
+		{"first payment sells the hold", models.ReservationReserved, true},
+		{"retried payment finds it consumed", models.ReservationConsumed, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, stub := newStubDB(t)
+			stub.on(`FROM "stock_reservations"`, cols, []driver.Value{int64(1), int64(1), int64(itemID), int64(5), int64(2), string(tt.status)})
+			stub.on(`FROM "locations"`, []string{"id", "code", "is_default", "is_active"}, []driver.Value{int64(1), "MAIN", true, true})
+			stub.on(`FROM "products"`, []string{"id", "name", "stock", "reserved_qty"}, []driver.Value{int64(5), "Figure", int64(3), int64(2)})
+
+			if err := (&InventoryService{DB: db}).ConsumeLine(order, item, nil); err != nil {
+				t.Fatalf("%v\n%s", err, stub.dump())
+			}
+			adjusted := stub.count(`UPDATE "products"`, "stock") > 0
+			if adjusted != tt.wantAdjust {
+				t.Errorf("stock adjusted = %v, want %v\n%s", adjusted, tt.wantAdjust, stub.dump())
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ===============================================
+// STOCK LOCATIONS
+// Product/edition stock is the company-wide total; stock_levels split it per location. Every
+// stock change goes through a location helper, Sync repairs levels that drifted from the totals.
+// ===============================================
+
+type LocationService struct {
+	DB *gorm.DB
+}
+
+func NewLocationService() *LocationService {
+	return &LocationService{
+		DB: config.DB,
+	}
+}
+
+// LocationInput is the admin payload for a location
+type LocationInput struct {
+	Code       string `json:"code" binding:"required"`
+	Name       string `json:"name" binding:"required"`
+	Type       string `json:"type"` // shop, warehouse, consignment
+	Address    string `json:"address"`
+	Priority   *int   `json:"priority"`
+	SellOnline *bool  `json:"sell_online"`
+	IsDefault  bool   `json:"is_default"`
+	IsActive   *bool  `json:"is_active"`
+}
+
+// ListLocations returns every location in web checkout allocation order
+func (s *LocationService) ListLocations() ([]models.Location, error) {
+	var locations []models.Location
+	err := s.DB.Order("priority ASC, id ASC").Find(&locations).Error
+	return locations, err
+}
+
+func (s *LocationService) apply(location *models.Location, in LocationInput) error {
+	switch in.Type {
+	case "":
+		if location.Type == "" {
+			location.Type = models.LocationWarehouse
+		}
+	case models.LocationShop, models.LocationWarehouse, models.LocationConsignment:
+		location.Type = in.Type
+	default:
+		return fmt.Errorf("type must be shop, warehouse or consignment")
+	}
+
+	location.Code = strings.ToUpper(strings.TrimSpace(in.Code))
+	location.Name = strings.TrimSpace(in.Name)
+	location.Address = in.Address
+	location.IsDefault = in.IsDefault
+	if in.Priority != nil {
+		location.Priority = *in.Priority
+	}
+	if in.SellOnline != nil {
+		location.SellOnline = *in.SellOnline
+	}
+	if in.IsActive != nil {
+		location.IsActive = *in.IsActive
+	}
+	if location.IsDefault && !location.IsActive {
+		return fmt.Errorf("lokasi default tidak boleh nonaktif")
+	}
+
+	var clash int64
+	s.DB.Model(&models.Location{}).Where("code = ? AND id <> ?", location.Code, location.ID).Count(&clash)
+	if clash > 0 {
+		return fmt.Errorf("kode lokasi %s sudah dipakai", location.Code)
+	}
+	return nil
+}
+
+// save stores the location; marking it default takes the flag off every other location
+func (s *LocationService) save(location *models.Location) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(location).Error; err != nil {
+			return err
+		}
+		if !location.IsDefault {
+			return nil
+		}
+		return tx.Model(&models.Location{}).Where("id <> ?", location.ID).Update("is_default", false).Error
+	})
+}
+
+// CreateLocation adds a location
+func (s *LocationService) CreateLocation(in LocationInput, userID uint) (*models.Location, error) {
+	location := models.Location{Priority: 100, SellOnline: true, IsActive: true}
+	if err := s.apply(&location, in); err != nil {
+		return nil, err
+	}
+	if err := s.save(&location); err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "CREATE", location.ID, "Created location "+location.Code)
+	return &location, nil
+}
+
+// UpdateLocation edits a location. The default location can't be unflagged directly: flag another one.
+func (s *LocationService) UpdateLocation(id uint, in LocationInput, userID uint) (*models.Location, error) {
+	var location models.Location
+	if err := s.DB.First(&location, id).Error; err != nil {
+		return nil, fmt.Errorf("location not found")
+	}
+	wasDefault := location.IsDefault
+	if err := s.apply(&location, in); err != nil {
+		return nil, err
+	}
+	if wasDefault && !location.IsDefault {
+		return nil, fmt.Errorf("pilih lokasi default lain terlebih dahulu")
+	}
+	if err := s.save(&location); err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "UPDATE", location.ID, "Updated location "+location.Code)
+	return &location, nil
+}
+
+// DeleteLocation removes an empty location that no transfer is on its way to or from
+func (s *LocationService) DeleteLocation(id uint, userID uint) error {
+	var location models.Location
+	if err := s.DB.First(&location, id).Error; err != nil {
+		return fmt.Errorf("location not found")
+	}
+	if location.IsDefault {
+		return fmt.Errorf("lokasi default tidak bisa dihapus")
+	}
+
+	var stocked int64
+	s.DB.Model(&models.StockLevel{}).Where("location_id = ? AND (stock <> 0 OR reserved_qty <> 0)", id).Count(&stocked)
+	if stocked > 0 {
+		return fmt.Errorf("lokasi masih menyimpan stok, pindahkan stok terlebih dahulu")
+	}
+	var open int64
+	s.DB.Model(&models.StockTransfer{}).
+		Where("(from_location_id = ? OR to_location_id = ?) AND status IN ?", id, id, []string{models.TransferDraft, models.TransferInTransit}).
+		Count(&open)
+	if open > 0 {
+		return fmt.Errorf("lokasi masih punya transfer yang belum selesai")
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("location_id = ?", id).Delete(&models.StockLevel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&location).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "DELETE", id, "Deleted location "+location.Code)
+	return nil
+}
+
+// SetPriority stores the web checkout allocation order: the first id is tried first
+func (s *LocationService) SetPriority(ids []uint, userID uint) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			res := tx.Model(&models.Location{}).Where("id = ?", id).Update("priority", (i+1)*10)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return fmt.Errorf("location %d not found", id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "UPDATE", 0, fmt.Sprintf("Reordered %d locations", len(ids)))
+	return nil
+}
+
+// ============================================
+// STOCK LEVELS
+// ============================================
+
+// LocationStockRow is one product/edition at one location
+type LocationStockRow struct {
+	LocationID     uint   `json:"location_id"`
+	LocationCode   string `json:"location_code"`
+	LocationName   string `json:"location_name"`
+	ProductID      uint   `json:"product_id"`
+	VariantID      *uint  `json:"variant_id"`
+	ProductName    string `json:"product_name"`
+	VariantName    string `json:"variant_name"`
+	SKU            string `json:"sku"`
+	Stock          int    `json:"stock"`
+	ReservedQty    int    `json:"reserved_qty"`
+	AvailableStock int    `json:"available_stock"`
+	MinStockLevel  int    `json:"min_stock_level"`
+}
+
+// levelRows selects stock_levels as LocationStockRow
+func (s *LocationService) levelRows() *gorm.DB {
+	return s.DB.Table("stock_levels").
+		Select(`stock_levels.location_id, locations.code AS location_code, locations.name AS location_name,
+			stock_levels.product_id, NULLIF(stock_levels.variant_id, 0) AS variant_id,
+			products.name AS product_name, COALESCE(product_variants.name, '') AS variant_name,
+			COALESCE(NULLIF(product_variants.sku, ''), products.sku) AS sku,
+			stock_levels.stock, stock_levels.reserved_qty, stock_levels.stock - stock_levels.reserved_qty AS available_stock,
+			stock_levels.min_stock_level`).
+		Joins("JOIN locations ON locations.id = stock_levels.location_id").
+		Joins("JOIN products ON products.id = stock_levels.product_id AND products.deleted_at IS NULL").
+		Joins("LEFT JOIN product_variants ON product_variants.id = stock_levels.variant_id")
+}
+
+// LevelFilter narrows ListLevels
+type LevelFilter struct {
+	LocationID uint
+	ProductID  uint
+	Search     string
+	InStock    bool // Only rows holding stock
+	Page       int
+	Limit      int
+}
+
+// ListLevels returns a page of per-location stock
+func (s *LocationService) ListLevels(f LevelFilter) ([]LocationStockRow, int64, error) {
+	filter := func(query *gorm.DB) *gorm.DB {
+		if f.LocationID != 0 {
+			query = query.Where("stock_levels.location_id = ?", f.LocationID)
+		}
+		if f.ProductID != 0 {
+			query = query.Where("stock_levels.product_id = ?", f.ProductID)
+		}
+		if f.Search != "" {
+			like := "%" + f.Search + "%"
+			query = query.Where("products.name ILIKE ? OR products.sku ILIKE ? OR product_variants.sku ILIKE ?", like, like, like)
+		}
+		if f.InStock {
+			query = query.Where("stock_levels.stock <> 0 OR stock_levels.reserved_qty <> 0")
+		}
+		return query
+	}
+
+	// Count on its own chain: Count rewrites the select list
+	var total int64
+	s.levelRows().Scopes(filter).Count(&total)
+
+	var rows []LocationStockRow
+	err := s.levelRows().Scopes(filter).Order("locations.priority ASC, products.name ASC, stock_levels.variant_id ASC").
+		Limit(f.Limit).Offset((f.Page - 1) * f.Limit).Scan(&rows).Error
+	return rows, total, err
+}
+
+// SetMinStock sets the low-stock threshold of a product/edition at a location (0 = no alert there)
+func (s *LocationService) SetMinStock(locationID, productID uint, variantID *uint, minStock int, userID uint) error {
+	if minStock < 0 {
+		return fmt.Errorf("min_stock_level cannot be negative")
+	}
+	if _, _, err := loadStockItem(s.DB, productID, variantID); err != nil {
+		return err
+	}
+	if err := s.DB.First(&models.Location{}, locationID).Error; err != nil {
+		return fmt.Errorf("location not found")
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := helpers.AdjustLocationStock(tx, locationID, productID, variantID, 0, 0); err != nil {
+			return err
+		}
+		return tx.Model(&models.StockLevel{}).
+			Where("location_id = ? AND product_id = ? AND variant_id = ?", locationID, productID, helpers.LocationVariantKey(variantID)).
+			Update("min_stock_level", minStock).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "UPDATE", productID, fmt.Sprintf("Min stock at location %d set to %d", locationID, minStock))
+	return nil
+}
+
+// LowStock returns every active product/edition at or below its location's own min_stock_level
+// (locationID 0 = all locations)
+func (s *LocationService) LowStock(locationID uint) ([]LocationStockRow, error) {
+	query := s.levelRows().
+		Where("stock_levels.min_stock_level > 0 AND stock_levels.stock - stock_levels.reserved_qty <= stock_levels.min_stock_level").
+		Where("locations.is_active = ? AND products.status = 'active'", true)
+	if locationID != 0 {
+		query = query.Where("stock_levels.location_id = ?", locationID)
+	}
+
+	var rows []LocationStockRow
+	err := query.Order("locations.priority ASC, available_stock ASC").Scan(&rows).Error
+	return rows, err
+}
+
+// loadStockItem loads a product and, when given, one of its editions
+func loadStockItem(tx *gorm.DB, productID uint, variantID *uint) (*models.Product, *models.ProductVariant, error) {
+	var product models.Product
+	if err := tx.First(&product, productID).Error; err != nil {
+		return nil, nil, fmt.Errorf("product %d not found", productID)
+	}
+	if variantID == nil {
+		return &product, nil, nil
+	}
+	var variant models.ProductVariant
+	if err := tx.Where("product_id = ?", productID).First(&variant, *variantID).Error; err != nil {
+		return nil, nil, fmt.Errorf("edition %d of %s not found", *variantID, product.Name)
+	}
+	return &product, &variant, nil
+}
+
+// ============================================
+// SYNC
+// ============================================
+
+// LocationSyncReport is the outcome of a Sync run
+type LocationSyncReport struct {
+	DefaultLocationID uint `json:"default_location_id"`
+	Adopted           int  `json:"adopted"`  // Active holds without a location, moved to the default location
+	Adjusted          int  `json:"adjusted"` // Items whose levels didn't add up to their total
+}
+
+type stockKey struct {
+	ProductID uint
+	VariantID uint
+}
+
+// Sync makes the levels add up again. Holds without a location are put on the default location,
+// reserved_qty per location is rebuilt from the active holds, and whatever physical stock the levels
+// (plus transfers in transit) don't account for is booked on the default location. On a shop that
+// never had locations this moves all existing stock to the default location.
+func (s *LocationService) Sync() (*LocationSyncReport, error) {
+	report := &LocationSyncReport{}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		defaultID, err := helpers.DefaultLocationID(tx)
+		if err != nil {
+			return err
+		}
+		report.DefaultLocationID = defaultID
+
+		// 1. Holds
+		res := tx.Model(&models.StockReservation{}).
+			Where("location_id IS NULL AND status = ?", models.ReservationReserved).
+			Update("location_id", defaultID)
+		if res.Error != nil {
+			return res.Error
+		}
+		report.Adopted = int(res.RowsAffected)
+
+		if err := tx.Exec(`INSERT INTO stock_levels (location_id, product_id, variant_id, stock, reserved_qty, min_stock_level, updated_at)
+			SELECT DISTINCT location_id, product_id, COALESCE(variant_id, 0), 0, 0, 0, NOW() FROM stock_reservations WHERE status = ?
+			ON CONFLICT (location_id, product_id, variant_id) DO NOTHING`, models.ReservationReserved).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`UPDATE stock_levels SET reserved_qty = COALESCE((SELECT SUM(r.quantity) FROM stock_reservations r
+			WHERE r.status = ? AND r.location_id = stock_levels.location_id AND r.product_id = stock_levels.product_id
+			AND COALESCE(r.variant_id, 0) = stock_levels.variant_id), 0)`, models.ReservationReserved).Error; err != nil {
+			return err
+		}
+
+		// 2. Rows that no longer hold stock of their own: products that gained editions, deleted editions
+		if err := tx.Exec(`UPDATE stock_levels SET stock = 0 WHERE stock <> 0 AND (
+			(variant_id = 0 AND product_id IN (SELECT product_id FROM product_variants WHERE deleted_at IS NULL))
+			OR (variant_id <> 0 AND variant_id NOT IN (SELECT id FROM product_variants WHERE deleted_at IS NULL)))`).Error; err != nil {
+			return err
+		}
+
+		// 3. Physical stock
+		var totals []struct {
+			ProductID uint
+			VariantID uint
+			Stock     int
+		}
+		if err := tx.Raw(`SELECT id AS product_id, 0 AS variant_id, stock FROM products
+			WHERE deleted_at IS NULL AND NOT EXISTS (SELECT 1 FROM product_variants v WHERE v.product_id = products.id AND v.deleted_at IS NULL)
+			UNION ALL
+			SELECT product_id, id AS variant_id, stock FROM product_variants WHERE deleted_at IS NULL`).Scan(&totals).Error; err != nil {
+			return err
+		}
+
+		counted := map[stockKey]int{}
+		var sums []struct {
+			ProductID uint
+			VariantID uint
+			Quantity  int
+		}
+		if err := tx.Raw(`SELECT product_id, variant_id, SUM(stock) AS quantity FROM stock_levels GROUP BY product_id, variant_id
+			UNION ALL
+			SELECT i.product_id, COALESCE(i.variant_id, 0), SUM(i.quantity) FROM stock_transfer_items i
+			JOIN stock_transfers t ON t.id = i.stock_transfer_id WHERE t.status = ? GROUP BY i.product_id, i.variant_id`,
+			models.TransferInTransit).Scan(&sums).Error; err != nil {
+			return err
+		}
+		for _, row := range sums {
+			counted[stockKey{row.ProductID, row.VariantID}] += row.Quantity
+		}
+
+		for _, total := range totals {
+			diff := total.Stock - counted[stockKey{total.ProductID, total.VariantID}]
+			if diff == 0 {
+				continue
+			}
+			var variantID *uint
+			if total.VariantID != 0 {
+				id := total.VariantID
+				variantID = &id
+			}
+			if err := helpers.AdjustLocationStock(tx, defaultID, total.ProductID, variantID, diff, 0); err != nil {
+				return err
+			}
+			report.Adjusted++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,673 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ============================================
+// ADMIN ORDER EDITING (lines, re-pricing, invoice adjustment)
+// ============================================
+
+// EditOrderLine is one change to an order's lines. ItemID 0 adds a new line,
+// Quantity 0 removes the line, a different VariantID swaps the edition.
+type EditOrderLine struct {
+	ItemID    uint  `json:"item_id"`
+	ProductID uint  `json:"product_id"`
+	VariantID *uint `json:"variant_id"`
+	Quantity  int   `json:"quantity"`
+}
+
+// EditOrderInput is the payload of an admin order edit. Lines not listed stay as they are.
+type EditOrderInput struct {
+	OrderID      uint            `json:"-"`
+	Lines        []EditOrderLine `json:"lines" binding:"required"`
+	ShippingCost *float64        `json:"shipping_cost"` // Manual override; nil re-prices the courier option picked at checkout
+	Reason       string          `json:"reason"`
+}
+
+// orderEditSnapshot is the part of an order an edit can change, stored as the audit diff
+type orderEditSnapshot struct {
+	Items    []orderEditSnapshotLine    `json:"items"`
+	Subtotal float64                    `json:"subtotal"`
+	Discount float64                    `json:"discount"`
+	Shipping float64                    `json:"shipping"`
+	Tax      float64                    `json:"tax"`
+	Total    float64                    `json:"total"`
+	Invoices []orderEditSnapshotInvoice `json:"invoices"`
+}
+
+type orderEditSnapshotLine struct {
+	ItemID    uint    `json:"item_id"`
+	ProductID uint    `json:"product_id"`
+	VariantID *uint   `json:"variant_id"`
+	Quantity  int     `json:"quantity"`
+	Price     float64 `json:"price"`
+	Total     float64 `json:"total"`
+}
+
+type orderEditSnapshotInvoice struct {
+	InvoiceNumber string  `json:"invoice_number"`
+	Type          string  `json:"type"`
+	Amount        float64 `json:"amount"`
+	Status        string  `json:"status"`
+}
+
+func snapshotOrderEdit(order models.Order) orderEditSnapshot {
+	snap := orderEditSnapshot{
+		Subtotal: order.SubtotalAmount,
+		Discount: order.DiscountAmount,
+		Shipping: order.ShippingCost,
+		Tax:      order.TaxAmount,
+		Total:    order.TotalAmount,
+	}
+	for _, item := range order.Items {
+		snap.Items = append(snap.Items, orderEditSnapshotLine{
+			ItemID: item.ID, ProductID: item.ProductID, VariantID: item.VariantID,
+			Quantity: item.Quantity, Price: item.Price, Total: item.Total,
+		})
+	}
+	for _, inv := range order.Invoices {
+		snap.Invoices = append(snap.Invoices, orderEditSnapshotInvoice{
+			InvoiceNumber: inv.InvoiceNumber, Type: inv.Type, Amount: inv.Amount, Status: inv.Status,
+		})
+	}
+	return snap
+}
+
+// EditOrderItems adds, removes or changes lines of an unshipped order. Stock reservations follow
+// the new quantities, discount/shipping/PPN are recomputed, and the payment schedule is brought in
+// line: open invoices are re-amounted, extra charges on paid invoices get an adjustment invoice and
+// overpayment is credited to the customer's wallet.
+func (s *OrderService) EditOrderItems(input EditOrderInput, requester models.User, ip, userAgent string) (*models.Order, error) {
+	if len(input.Lines) == 0 {
+		return nil, fmt.Errorf("no changes submitted")
+	}
+
+	var order models.Order
+	var oldSnapshot orderEditSnapshot
+	var notes []string
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked for the whole edit: a payment settling an invoice meanwhile waits, and the
+		// re-pricing below works from the invoices as they are now
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items.Product").Preload("Items.Variant").Preload("Invoices").Preload("Shipments.Items").First(&order, input.OrderID).Error; err != nil {
+			return fmt.Errorf("order not found")
+		}
+
+		if order.Status == "cancelled" || order.Status == "completed" || order.PaymentStatus == "refunded" {
+			return fmt.Errorf("order cannot be edited in its current state")
+		}
+		if order.FulfillmentStatus == "shipped" || order.FulfillmentStatus == "delivered" {
+			return fmt.Errorf("cannot edit an order that has already shipped")
+		}
+		for _, inv := range order.Invoices {
+			if inv.Status == "awaiting_approval" {
+				return fmt.Errorf("Invoice %s sedang menunggu verifikasi pembayaran", inv.InvoiceNumber)
+			}
+			if inv.OrderItemID == nil && (inv.Type == "deposit" || inv.Type == "balance") {
+				return fmt.Errorf("order uses the legacy order-level PO schedule and cannot be edited")
+			}
+		}
+		oldSnapshot = snapshotOrderEdit(order)
+
+		items := append([]models.OrderItem(nil), order.Items...)
+		var removed []models.OrderItem
+		shipped := shippedQuantities(order)
+
+		for _, line := range input.Lines {
+			if line.Quantity < 0 {
+				return fmt.Errorf("invalid quantity for item %d", line.ItemID)
+			}
+
+			// New line, priced at today's price
+			if line.ItemID == 0 {
+				if line.Quantity == 0 {
+					return fmt.Errorf("invalid quantity for product %d", line.ProductID)
+				}
+				product, variant, err := LoadSellable(tx, line.ProductID, line.VariantID)
+				if err != nil {
+					return err
+				}
+				productType := ProductTypeReady
+				if product.ProductType == ProductTypePO {
+					productType = ProductTypePO
+				}
+				item := models.OrderItem{
+					OrderID:      order.ID,
+					ProductID:    product.ID,
+					VariantID:    line.VariantID,
+					Quantity:     line.Quantity,
+					Price:        product.PriceFor(variant),
+					Total:        product.PriceFor(variant) * float64(line.Quantity),
+					COGSSnapshot: product.CostFor(variant),
+					ProductType:  productType,
+				}
+				if err := tx.Omit(clause.Associations).Create(&item).Error; err != nil {
+					return err
+				}
+				item.Product, item.Variant = product, variant
+				if err := s.reserveEditedLine(tx, order, item, item.Quantity, requester.ID); err != nil {
+					return err
+				}
+				items = append(items, item)
+				notes = append(notes, fmt.Sprintf("+ %s x%d", orderItemName(item), item.Quantity))
+				continue
+			}
+
+			idx := -1
+			for i := range items {
+				if items[i].ID == line.ItemID {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				return fmt.Errorf("item %d not found in this order", line.ItemID)
+			}
+			item := &items[idx]
+			if item.SettledAt != nil || item.ForfeitedAt != nil || shipped[item.ID] > 0 {
+				return fmt.Errorf("%s sudah lunas, hangus atau dikirim dan tidak bisa diubah", orderItemName(*item))
+			}
+
+			// Remove
+			if line.Quantity == 0 {
+				if err := s.releaseEditedLine(tx, order, *item, item.Quantity, requester.ID); err != nil {
+					return err
+				}
+				if err := tx.Delete(&models.OrderItem{}, item.ID).Error; err != nil {
+					return err
+				}
+				notes = append(notes, fmt.Sprintf("- %s x%d", orderItemName(*item), item.Quantity))
+				removed = append(removed, *item)
+				items = append(items[:idx], items[idx+1:]...)
+				continue
+			}
+
+			// Omitted product/variant keep the line's current ones
+			productID, variantID := item.ProductID, item.VariantID
+			if line.ProductID != 0 && line.ProductID != item.ProductID {
+				productID, variantID = line.ProductID, line.VariantID
+			} else if line.VariantID != nil {
+				variantID = line.VariantID
+			}
+			swapped := productID != item.ProductID || !sameVariant(variantID, item.VariantID)
+
+			if swapped {
+				// Edition swap: free the old reservation, hold the new one and re-price
+				product, variant, err := LoadSellable(tx, productID, variantID)
+				if err != nil {
+					return err
+				}
+				if err := s.releaseEditedLine(tx, order, *item, item.Quantity, requester.ID); err != nil {
+					return err
+				}
+				oldName, oldQty := orderItemName(*item), item.Quantity
+				item.ProductID, item.VariantID = product.ID, variantID
+				item.Product, item.Variant = product, variant
+				item.Price = product.PriceFor(variant)
+				item.COGSSnapshot = product.CostFor(variant)
+				item.ProductType = ProductTypeReady
+				if product.ProductType == ProductTypePO {
+					item.ProductType = ProductTypePO
+				}
+				item.Quantity = line.Quantity
+				if err := s.reserveEditedLine(tx, order, *item, item.Quantity, requester.ID); err != nil {
+					return err
+				}
+				notes = append(notes, fmt.Sprintf("%s x%d -> %s x%d", oldName, oldQty, orderItemName(*item), item.Quantity))
+			} else if line.Quantity != item.Quantity {
+				delta := line.Quantity - item.Quantity
+				if delta > 0 {
+					if err := s.reserveEditedLine(tx, order, *item, delta, requester.ID); err != nil {
+						return err
+					}
+				} else if err := s.releaseEditedLine(tx, order, *item, -delta, requester.ID); err != nil {
+					return err
+				}
+				notes = append(notes, fmt.Sprintf("%s qty %d -> %d", orderItemName(*item), item.Quantity, line.Quantity))
+				item.Quantity = line.Quantity
+			} else {
+				continue
+			}
+
+			item.Total = item.Price * float64(item.Quantity)
+			if err := tx.Omit(clause.Associations).Save(item).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(items) == 0 {
+			return fmt.Errorf("an order needs at least one item, cancel it instead")
+		}
+
+		// Re-price: subtotal, voucher, shipping and PPN exactly as checkout would
+		subtotal := 0.0
+		products := make(map[uint]models.Product)
+		for _, item := range items {
+			subtotal += item.Total
+			products[item.ProductID] = item.Product
+		}
+
+		discount, freeShipping := s.repriceVoucher(tx, order.CouponCode, items, subtotal, order.DiscountAmount)
+
+		shippingCost := order.ShippingCost
+		if input.ShippingCost != nil {
+			shippingCost = *input.ShippingCost
+		} else if cost, ok := s.requoteShipping(tx, order, items, subtotal); ok {
+			shippingCost = cost
+		}
+		if freeShipping {
+			shippingCost = 0
+		}
+
+		destCountry, destPostcode := order.BillingCountry, order.BillingPostcode
+		if order.ShipToDifferent {
+			destCountry, destPostcode = order.ShippingCountry, order.ShippingPostcode
+		}
+		shares := allocateLineAmounts(items, discount)
+		taxLines := make([]TaxableLine, len(items))
+		for i, item := range items {
+			taxLines[i] = TaxableLine{CategoryID: products[item.ProductID].CategoryID, Amount: shares[i]}
+		}
+		taxBreakdown := (&TaxService{DB: tx}).Calculate(taxLines, shippingCost, destCountry, destPostcode)
+		ApplyTax(&order, items, taxBreakdown)
+		for _, item := range items {
+			if err := tx.Model(&models.OrderItem{}).Where("id = ?", item.ID).Update("tax_amount", item.TaxAmount).Error; err != nil {
+				return err
+			}
+		}
+
+		totalAmount := subtotal + shippingCost - discount + taxBreakdown.Extra()
+		if totalAmount < 0 {
+			totalAmount = 0
+		}
+		if shippingCost != order.ShippingCost {
+			notes = append(notes, fmt.Sprintf("Ongkir Rp %s -> Rp %s", helpers.FormatPrice(order.ShippingCost), helpers.FormatPrice(shippingCost)))
+		}
+		if discount != order.DiscountAmount {
+			notes = append(notes, fmt.Sprintf("Diskon Rp %s -> Rp %s", helpers.FormatPrice(order.DiscountAmount), helpers.FormatPrice(discount)))
+		}
+		notes = append(notes, fmt.Sprintf("Total Rp %s -> Rp %s", helpers.FormatPrice(order.TotalAmount), helpers.FormatPrice(totalAmount)))
+
+		order.SubtotalAmount = subtotal
+		order.DiscountAmount = discount
+		order.ShippingCost = shippingCost
+		order.TotalAmount = totalAmount
+		order.Items = items
+		if err := tx.Omit(clause.Associations).Save(&order).Error; err != nil {
+			return err
+		}
+
+		// Payment schedule
+		credit, invoiceNotes, err := s.reconcileInvoices(tx, order, removed, products)
+		if err != nil {
+			return err
+		}
+		notes = append(notes, invoiceNotes...)
+		if credit > 0 {
+			method, err := s.processAutoRefund(tx, order, credit, "order_edit", requester.ID)
+			if err != nil {
+				return err
+			}
+			notes = append(notes, fmt.Sprintf("Kelebihan bayar Rp %s dikembalikan via %s", helpers.FormatPrice(credit), method))
+		}
+
+		if order.CouponCode != "" {
+			tx.Model(&models.VoucherUsage{}).Where("order_id = ?", order.ID).Update("discount_amount", discount)
+		}
+
+		if _, err := s.SyncPaymentState(tx, order.ID); err != nil {
+			return err
+		}
+
+		note := strings.Join(notes, "; ")
+		if input.Reason != "" {
+			note = input.Reason + ": " + note
+		}
+		tx.Create(&models.OrderLog{
+			OrderID:           order.ID,
+			UserID:            requester.ID,
+			Action:            "items_edited",
+			Note:              note,
+			IsCustomerVisible: true,
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.Cache.Flush()
+	(&RefundService{DB: s.DB}).ProcessOrder(order.ID)
+
+	var updated models.Order
+	if err := s.DB.Preload("Items.Product").Preload("Items.Variant").Preload("Invoices").First(&updated, order.ID).Error; err != nil {
+		return nil, err
+	}
+
+	helpers.LogAudit(requester.ID, "Order", "Edit Items", updated.OrderNumber, strings.Join(notes, "; "),
+		oldSnapshot, snapshotOrderEdit(updated), ip, userAgent)
+
+	helpers.NotifyUser(updated.UserID, "ORDER_UPDATED",
+		fmt.Sprintf("Order %s has been updated. New total: Rp %s", updated.OrderNumber, helpers.FormatPrice(updated.TotalAmount)),
+		map[string]interface{}{
+			"order_id": updated.ID,
+			"total":    updated.TotalAmount,
+		})
+
+	return &updated, nil
+}
+
+// reserveEditedLine holds qty more units for the line, failing when the stock / PO slots ran out
+func (s *OrderService) reserveEditedLine(tx *gorm.DB, order models.Order, item models.OrderItem, qty int, userID uint) error {
+	_, err := (&InventoryService{DB: tx}).Hold(HoldInput{
+		ProductID:    item.ProductID,
+		VariantID:    item.VariantID,
+		Quantity:     qty,
+		OrderID:      order.ID,
+		OrderItemID:  &item.ID,
+		Source:       "order_edit",
+		MovementType: "order_edit",
+		RefType:      "ORDER",
+		RefID:        order.OrderNumber,
+		Note:         "Order edited (reserve)",
+		UserID:       &userID,
+	})
+	if errors.Is(err, ErrInsufficientStock) {
+		return fmt.Errorf("Stok %s tidak mencukupi untuk perubahan ini", orderItemName(item))
+	}
+	return err
+}
+
+// releaseEditedLine gives qty reserved units of a line back to the shelf
+func (s *OrderService) releaseEditedLine(tx *gorm.DB, order models.Order, item models.OrderItem, qty int, userID uint) error {
+	return (&InventoryService{DB: tx}).ReleaseLine(order, item, qty, "order_edit", "Order edited (release)", &userID)
+}
+
+// repriceVoucher recomputes the order's voucher discount for the edited lines with the checkout
+// rules. Usage limits are not checked again, the voucher was already redeemed by this order.
+// A voucher that no longer exists keeps its old discount, capped at the new subtotal.
+func (s *OrderService) repriceVoucher(tx *gorm.DB, couponCode string, items []models.OrderItem, subtotal, currentDiscount float64) (float64, bool) {
+	if couponCode == "" {
+		return 0, false
+	}
+
+	var voucher models.Voucher
+	if err := tx.Where("code = ?", strings.ToUpper(strings.TrimSpace(couponCode))).First(&voucher).Error; err != nil {
+		if currentDiscount > subtotal {
+			return subtotal, false
+		}
+		return currentDiscount, false
+	}
+
+	var voucherLines []models.VoucherLine
+	for _, item := range items {
+		voucherLines = append(voucherLines, models.VoucherLine{ProductID: item.ProductID, VariantID: item.VariantID})
+	}
+	var restricts []models.VoucherProduct
+	tx.Where("voucher_id = ?", voucher.ID).Find(&restricts)
+	if !models.ProductRestrictionsMet(restricts, voucherLines) {
+		return 0, false
+	}
+
+	discount := currentDiscount
+	switch voucher.Type {
+	case "percentage":
+		discount = subtotal * (voucher.Value / 100)
+		if voucher.MaxDiscount > 0 && discount > voucher.MaxDiscount {
+			discount = voucher.MaxDiscount
+		}
+	case "fixed":
+		discount = voucher.Value
+	case "shipping":
+		discount = 0
+	}
+	if discount > subtotal {
+		discount = subtotal
+	}
+	return roundMoney(discount), voucher.FreeShipping || voucher.Type == "shipping"
+}
+
+// requoteShipping prices the edited parcel with the courier option picked at checkout.
+// Reports false when the option is no longer offered, the caller then keeps the current cost.
+func (s *OrderService) requoteShipping(tx *gorm.DB, order models.Order, items []models.OrderItem, subtotal float64) (float64, bool) {
+	var quote models.ShippingQuote
+	optionID := ""
+	if err := tx.Where("order_id = ?", order.ID).First(&quote).Error; err == nil {
+		optionID = quote.OptionID
+	}
+
+	totalWeight := 0.0
+	for _, item := range items {
+		totalWeight += item.Product.WeightFor(item.Variant) * float64(item.Quantity)
+	}
+
+	input := ShippingOptionsInput{
+		TotalWeight: roundWeight(totalWeight),
+		Country:     order.BillingCountry,
+		State:       order.BillingState,
+		City:        order.BillingCity,
+		PostalCode:  order.BillingPostcode,
+		Subtotal:    subtotal,
+	}
+	if order.ShipToDifferent {
+		input.Country, input.State, input.City, input.PostalCode = order.ShippingCountry, order.ShippingState, order.ShippingCity, order.ShippingPostcode
+	}
+
+	for _, option := range (&ShippingService{DB: tx}).GetShippingOptions(input) {
+		if (optionID != "" && option.ID == optionID) || (optionID == "" && option.Name == order.ShippingMethod) {
+			return option.Cost, true
+		}
+	}
+	return 0, false
+}
+
+// reconcileInvoices brings the open invoices of an edited order in line with its re-planned schedule.
+// Invoices are grouped per bucket: the order-level "full" invoice(s) and each PO line's deposit/balance.
+// Paid invoices are never touched; while nothing in a bucket is paid its open invoices simply take the
+// planned amounts, otherwise what is still owed goes on one open invoice (an adjustment invoice when
+// there is none) and any overpayment is returned as credit.
+func (s *OrderService) reconcileInvoices(tx *gorm.DB, order models.Order, removed []models.OrderItem, products map[uint]models.Product) (float64, []string, error) {
+	plan := s.planInvoiceSchedule(order, products)
+
+	planned := map[uint][]plannedInvoice{}
+	lines := map[uint]*models.OrderItem{}
+	var keys []uint
+	addKey := func(key uint) {
+		if _, seen := planned[key]; !seen {
+			planned[key] = nil
+			keys = append(keys, key)
+		}
+	}
+	for _, p := range plan {
+		key := uint(0)
+		if p.Item != nil {
+			key = p.Item.ID
+			lines[key] = p.Item
+		}
+		addKey(key)
+		planned[key] = append(planned[key], p)
+	}
+
+	existing := map[uint][]models.Invoice{}
+	for _, inv := range order.Invoices {
+		key := uint(0)
+		if inv.OrderItemID != nil {
+			key = *inv.OrderItemID
+		}
+		addKey(key)
+		existing[key] = append(existing[key], inv)
+	}
+	for _, item := range removed {
+		addKey(item.ID)
+	}
+
+	credit := 0.0
+	var notes []string
+	// Only open invoices are changed; one paid in the meantime fails the edit instead of being rewritten.
+	// Charges opened for the old amount are expired so they cannot settle the new one.
+	updateOpen := func(inv models.Invoice, updates map[string]interface{}) error {
+		res := tx.Model(&models.Invoice{}).Where("id = ? AND status IN ?", inv.ID, openInvoiceStatuses).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("Invoice %s sudah dibayar atau berubah, muat ulang order lalu ulangi perubahan", inv.InvoiceNumber)
+		}
+		return expireOpenCharges(tx, inv.ID)
+	}
+	setAmount := func(inv models.Invoice, amount, tax float64) error {
+		if roundMoney(inv.Amount) == roundMoney(amount) && roundMoney(inv.TaxAmount) == roundMoney(tax) {
+			return nil
+		}
+		notes = append(notes, fmt.Sprintf("%s Rp %s -> Rp %s", inv.InvoiceNumber, helpers.FormatPrice(inv.Amount), helpers.FormatPrice(amount)))
+		return updateOpen(inv, map[string]interface{}{"amount": roundMoney(amount), "tax_amount": roundMoney(tax)})
+	}
+	cancel := func(inv models.Invoice) error {
+		notes = append(notes, inv.InvoiceNumber+" dibatalkan")
+		return updateOpen(inv, map[string]interface{}{"status": "cancelled"})
+	}
+	create := func(key uint, invType string, amount, tax float64, status string) error {
+		notes = append(notes, fmt.Sprintf("Invoice %s baru Rp %s", invType, helpers.FormatPrice(amount)))
+		if key == 0 {
+			return helpers.GenerateTaxedInvoice(tx, order, invType, roundMoney(amount), roundMoney(tax), status)
+		}
+		return helpers.GenerateLineInvoice(tx, order, *lines[key], invType, roundMoney(amount), roundMoney(tax), status)
+	}
+
+	for _, key := range keys {
+		paid, paidTax := 0.0, 0.0
+		var open []models.Invoice
+		for _, inv := range existing[key] {
+			switch inv.Status {
+			case "paid", "paid_late":
+				paid += inv.Amount
+				paidTax += inv.TaxAmount
+			case "unpaid", "pending_arrival":
+				open = append(open, inv)
+			}
+		}
+
+		// Nothing paid yet: lay the planned invoices over the open ones of the same type
+		if paid == 0 {
+			used := map[uint]bool{}
+			for _, p := range planned[key] {
+				var match *models.Invoice
+				for i := range open {
+					if !used[open[i].ID] && open[i].Type == p.Type {
+						match = &open[i]
+						break
+					}
+				}
+				if match == nil {
+					if err := create(key, p.Type, p.Amount, p.TaxAmount, p.Status); err != nil {
+						return 0, nil, err
+					}
+					continue
+				}
+				used[match.ID] = true
+				if err := setAmount(*match, p.Amount, p.TaxAmount); err != nil {
+					return 0, nil, err
+				}
+			}
+			for _, inv := range open {
+				if !used[inv.ID] {
+					if err := cancel(inv); err != nil {
+						return 0, nil, err
+					}
+				}
+			}
+			continue
+		}
+
+		target, targetTax := 0.0, 0.0
+		for _, p := range planned[key] {
+			target += p.Amount
+			targetTax += p.TaxAmount
+		}
+		remaining := roundMoney(target - paid)
+		remainingTax := roundMoney(targetTax - paidTax)
+		if remainingTax < 0 {
+			remainingTax = 0
+		}
+
+		if remaining <= 0 {
+			for _, inv := range open {
+				if err := cancel(inv); err != nil {
+					return 0, nil, err
+				}
+			}
+			credit += -remaining
+			continue
+		}
+
+		// Still owed: order-level charges go on a "full" invoice, PO lines on their balance
+		openType, status := "full", "unpaid"
+		if key != 0 {
+			openType = "balance"
+			if line := lines[key]; line == nil || line.ArrivedAt == nil {
+				status = "pending_arrival"
+			}
+		}
+		var owed *models.Invoice
+		for i := range open {
+			if open[i].Type == openType {
+				owed = &open[i]
+				break
+			}
+		}
+		for _, inv := range open {
+			if owed != nil && inv.ID == owed.ID {
+				continue
+			}
+			if err := cancel(inv); err != nil {
+				return 0, nil, err
+			}
+		}
+		if owed != nil {
+			if err := setAmount(*owed, remaining, remainingTax); err != nil {
+				return 0, nil, err
+			}
+		} else if err := create(key, openType, remaining, remainingTax, status); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return roundMoney(credit), notes, nil
+}
+
+// openInvoiceStatuses are the invoice statuses an order edit may still re-amount or cancel
+var openInvoiceStatuses = []string{"unpaid", "pending", "pending_arrival"}
+
+// expireOpenCharges expires the pending gateway charges (VA, QR) of an invoice whose amount or status changed
+func expireOpenCharges(tx *gorm.DB, invoiceID uint) error {
+	return tx.Model(&models.PaymentTransaction{}).
+		Where("invoice_id = ? AND status = ?", invoiceID, "pending").
+		Updates(map[string]interface{}{"status": "expired", "expired_at": time.Now()}).Error
+}
+
+// orderItemName is the display name of a line (product + edition)
+func orderItemName(item models.OrderItem) string {
+	if item.Variant != nil {
+		return item.Product.Name + " - " + item.Variant.Name
+	}
+	return item.Product.Name
+}
+
+func sameVariant(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
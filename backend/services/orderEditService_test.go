@@ -0,0 +1,77 @@
+package services
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+)
+
+func TestEditOrderItemsLocksOrderInsideTransaction(t *testing.T) {
+	_, stub := newStubDB(t)
+	stub.on(`FROM "orders"`, []string{"id", "status", "order_number"}, []driver.Value{int64(1), "cancelled", "FORZA-2026-10-00001"})
+
+	_, err := NewOrderService().EditOrderItems(EditOrderInput{OrderID: 1, Lines: []EditOrderLine{{ItemID: 10, Quantity: 1}}}, models.User{ID: 1}, "", "")
+	if err == nil || !strings.Contains(err.Error(), "cannot be edited") {
+		t.Fatalf("expected the cancelled order to be refused, got %v", err)
+	}
+
+	lock := stub.index(`FROM "orders"`, "FOR UPDATE")
+	if lock < 0 || !stub.inTransaction(lock) {
+		t.Fatalf("order not loaded with a row lock inside the transaction:\n%s", stub.dump())
+	}
+	if invoices := stub.index(`FROM "invoices"`); invoices < lock || !stub.inTransaction(invoices) {
+		t.Errorf("invoices not reloaded under the lock:\n%s", stub.dump())
+	}
+}
+
+func TestReconcileInvoicesOnlyTouchesOpenInvoices(t *testing.T) {
+	order := models.Order{
+		ID:           1,
+		TaxInclusive: true,
+		TotalAmount:  150000,
+		Items:        []models.OrderItem{{ID: 10, ProductID: 5, ProductType: "ready", Quantity: 3, Total: 150000}},
+		Invoices: []models.Invoice{
+			{ID: 20, InvoiceNumber: "INV-2026-10-00020", Type: "full", Amount: 100000, Status: "unpaid"},
+		},
+	}
+	products := map[uint]models.Product{5: {ID: 5}}
+
+	tests := []struct {
+		name     string
+		affected int64 // rows the guarded invoice update reports
+		wantErr  bool
+	}{
+		{"open invoice is re-amounted", 1, false},
+		{"invoice paid meanwhile fails the edit", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, stub := newStubDB(t)
+			stub.affect(`UPDATE "invoices"`, tt.affected)
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				_, _, err := NewOrderService().reconcileInvoices(tx, order, nil, products)
+				return err
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconcileInvoices() error = %v, wantErr %v\n%s", err, tt.wantErr, stub.dump())
+			}
+
+			if stub.index(`UPDATE "invoices"`, "amount", "status IN (unpaid,pending,pending_arrival)") < 0 {
+				t.Errorf("invoice update is not guarded on open statuses:\n%s", stub.dump())
+			}
+			expired := stub.index(`UPDATE "payment_transactions"`, "expired", "invoice_id = 20", "status = pending")
+			if tt.wantErr && expired >= 0 {
+				t.Errorf("charges expired although the invoice was not changed:\n%s", stub.dump())
+			}
+			if !tt.wantErr && expired < 0 {
+				t.Errorf("pending charges for the old amount not expired:\n%s", stub.dump())
+			}
+		})
+	}
+}
@@ -3,7 +3,9 @@ package services
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"os"
 	"strconv"
@@ -15,6 +17,7 @@ import (
 	"forzashop/backend/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderService struct {
@@ -29,10 +32,7 @@ func NewOrderService() *OrderService {
 
 // CheckoutInput defines the payload for creating a new order
 type CheckoutInput struct {
-	Items []struct {
-		ProductID uint `json:"product_id"`
-		Quantity  int  `json:"quantity"`
-	} `json:"items"`
+	Items []CartItemInput `json:"items"`
 
 	// Billing Details
 	BillingFirstName string `json:"billing_first_name"`
@@ -60,8 +60,8 @@ type CheckoutInput struct {
 	ShippingPostcode  string `json:"shipping_postcode"`
 
 	// Payment & Shipping Method
-	ShippingMethod     string  `json:"shipping_method"`
-	ShippingCost       float64 `json:"shipping_cost"`
+	// Shipping cost/method come only from the server-issued quote (see ShippingService.CreateQuote)
+	ShippingQuoteID    string  `json:"shipping_quote_id"`
 	PaymentMethod      string  `json:"payment_method"`
 	PaymentMethodTitle string  `json:"payment_method_title"`
 	CouponCode         string  `json:"coupon_code"`
@@ -96,45 +96,72 @@ func (s *OrderService) CreateOrder(input CheckoutInput, user models.User) (*Crea
 	err := s.DB.Transaction(func(tx *gorm.DB) error {
 		var subtotalAmount float64
 		var orderItems []models.OrderItem
+		var priceNotes []string
+		var displayNames []string
+		products := make(map[uint]models.Product)
+
+		// 0. Verify Shipping Quote (cost, weight, destination & locked prices)
+		destCountry, destPostcode := input.BillingCountry, input.BillingPostcode
+		if input.ShipToDifferent {
+			destCountry, destPostcode = input.ShippingCountry, input.ShippingPostcode
+		}
+		shippingSvc := &ShippingService{DB: tx}
+		quote, lockedPrices, err := shippingSvc.VerifyQuote(tx, input.ShippingQuoteID, user.ID, input.Items, destCountry, destPostcode)
+		if err != nil {
+			return err
+		}
 
 		// 1. Process Items & Reserve Stock
 		for _, item := range input.Items {
-			var product models.Product
-			if err := tx.First(&product, item.ProductID).Error; err != nil {
-				return fmt.Errorf("product %d not found", item.ProductID)
+			if item.Quantity <= 0 {
+				return fmt.Errorf("invalid quantity for product %d", item.ProductID)
+			}
+			product, variant, err := LoadSellable(tx, item.ProductID, item.VariantID)
+			if err != nil {
+				return err
 			}
 
-			itemTotal := product.Price * float64(item.Quantity)
-			subtotalAmount += itemTotal
-
-			// Atomic Stock Update (Applies to BOTH Ready and PO to prevent overselling slots)
-			res := tx.Model(&models.Product{}).
-				Where("id = ? AND (stock - reserved_qty) >= ?", product.ID, item.Quantity).
-				Update("reserved_qty", gorm.Expr("reserved_qty + ?", item.Quantity))
+			products[product.ID] = product
+			displayName := product.Name
+			if variant != nil {
+				displayName = product.Name + " - " + variant.Name
+			}
 
-			if res.Error != nil {
-				return fmt.Errorf("database error reserving stock")
+			// Honour the price shown when the quote was issued
+			unitPrice := lockedPrices[lineKey(product.ID, item.VariantID)]
+			if currentPrice := product.PriceFor(variant); unitPrice != currentPrice {
+				priceNotes = append(priceNotes, fmt.Sprintf("%s: Rp %.0f (harga saat ini Rp %.0f)", displayName, unitPrice, currentPrice))
 			}
-			if res.RowsAffected == 0 {
-				return fmt.Errorf("High demand! %s just sold out or ran out of PO slots.", product.Name)
+
+			itemTotal := unitPrice * float64(item.Quantity)
+			subtotalAmount += itemTotal
+			displayNames = append(displayNames, displayName)
+
+			productType := ProductTypeReady
+			if product.ProductType == ProductTypePO {
+				productType = ProductTypePO
 			}
 
 			orderItems = append(orderItems, models.OrderItem{
 				ProductID:    product.ID,
+				VariantID:    item.VariantID,
 				Quantity:     item.Quantity,
-				Price:        product.Price,
+				Price:        unitPrice,
 				Total:        itemTotal,
-				COGSSnapshot: product.SupplierCost,
+				COGSSnapshot: product.CostFor(variant),
+				ProductType:  productType,
 			})
 		}
 
+		shippingCost := quote.ShippingCost
+
 		// 2. Calculate Totals
 		// Voucher Validation Integration
 		validatedDiscount := 0.0
 		if input.CouponCode != "" {
-			var productIDs []uint
+			var voucherLines []models.VoucherLine
 			for _, item := range orderItems {
-				productIDs = append(productIDs, item.ProductID)
+				voucherLines = append(voucherLines, models.VoucherLine{ProductID: item.ProductID, VariantID: item.VariantID})
 			}
 			// Temporary call ValidateVoucher without category checking for now (complex preload inside order service)
 			// Wait, the controllers package needs to be imported, but we can't import controllers in services! Circular dependency!
@@ -162,6 +189,17 @@ func (s *OrderService) CreateOrder(input CheckoutInput, user models.User) (*Crea
 				}
 			}
 
+			var restricts []models.VoucherProduct
+			tx.Where("voucher_id = ?", voucher.ID).Find(&restricts)
+			if !models.ProductRestrictionsMet(restricts, voucherLines) {
+				return fmt.Errorf("Voucher tidak berlaku untuk produk yang dipilih")
+			}
+
+			// Free shipping is applied here, never by the client zeroing the cost
+			if voucher.FreeShipping || voucher.Type == "shipping" {
+				shippingCost = 0
+			}
+
 			// Issue 5 Fix: Securely calculate discount on backend (don't trust frontend)
 			if voucher.Type == "percentage" {
 				validatedDiscount = subtotalAmount * (voucher.Value / 100)
@@ -170,7 +208,7 @@ func (s *OrderService) CreateOrder(input CheckoutInput, user models.User) (*Crea
 				}
 			} else if voucher.Type == "fixed" {
 				validatedDiscount = voucher.Value
-			} else {
+			} else if voucher.Type != "shipping" {
 				// Prevent untrusted calculations for other types right now
 				validatedDiscount = input.DiscountAmount
 			}
@@ -180,14 +218,27 @@ func (s *OrderService) CreateOrder(input CheckoutInput, user models.User) (*Crea
 			}
 		}
 
-		totalAmount := subtotalAmount + input.ShippingCost - validatedDiscount
+		// PPN per line (after discount) and on shipping, zero-rated for exports
+		shares := allocateLineAmounts(orderItems, validatedDiscount)
+		taxLines := make([]TaxableLine, len(orderItems))
+		for i, item := range orderItems {
+			taxLines[i] = TaxableLine{CategoryID: products[item.ProductID].CategoryID, Amount: shares[i]}
+		}
+		taxBreakdown := (&TaxService{DB: tx}).Calculate(taxLines, shippingCost, destCountry, destPostcode)
+
+		totalAmount := subtotalAmount + shippingCost - validatedDiscount + taxBreakdown.Extra()
 		if totalAmount < 0 {
 			totalAmount = 0
 		}
 
 		// 3. Create Order
+		orderNumber, err := helpers.NextSequenceNumber(tx, helpers.SeqOrder)
+		if err != nil {
+			return err
+		}
+
 		order = models.Order{
-			OrderNumber: fmt.Sprintf("FORZA-%d", time.Now().Unix()),
+			OrderNumber: orderNumber,
 			UserID:      user.ID,
 			// Billing
 			BillingFirstName: input.BillingFirstName,
@@ -215,8 +266,8 @@ func (s *OrderService) CreateOrder(input CheckoutInput, user models.User) (*Crea
 			// Financials
 			SubtotalAmount:   subtotalAmount,
 			TotalAmount:      totalAmount,
-			ShippingCost:     input.ShippingCost,
-			ShippingMethod:   input.ShippingMethod,
+			ShippingCost:     shippingCost,
+			ShippingMethod:   quote.ShippingMethod,
 			DiscountAmount:   validatedDiscount,
 			CouponCode:       input.CouponCode,
 			RemainingBalance: totalAmount,
@@ -229,6 +280,8 @@ func (s *OrderService) CreateOrder(input CheckoutInput, user models.User) (*Crea
 			Items:              orderItems,
 		}
 
+		ApplyTax(&order, orderItems, taxBreakdown)
+
 		// Handle Shipping Address JSON (Legacy)
 		// ... logic can be simplified or just set JSON here if needed
 		// For brevity, skipping the redundant JSON blob if not critical, or adding simple map:
@@ -247,6 +300,17 @@ func (s *OrderService) CreateOrder(input CheckoutInput, user models.User) (*Crea
 		}
 		order.Items = orderItems
 
+		// Atomic stock hold per line (applies to BOTH Ready and PO to prevent overselling slots), per edition
+		inventory := &InventoryService{DB: tx}
+		for i, item := range orderItems {
+			if err := inventory.HoldOrderLine(order, item, "checkout", &user.ID); err != nil {
+				if errors.Is(err, ErrInsufficientStock) {
+					return fmt.Errorf("High demand! %s just sold out or ran out of PO slots.", displayNames[i])
+				}
+				return fmt.Errorf("database error reserving stock")
+			}
+		}
+
 		// 4. Initial Log
 		tx.Create(&models.OrderLog{
 			OrderID: order.ID,
@@ -254,31 +318,22 @@ func (s *OrderService) CreateOrder(input CheckoutInput, user models.User) (*Crea
 			Action:  "created",
 			Note:    "Order created via checkout.",
 		})
+		if len(priceNotes) > 0 {
+			tx.Create(&models.OrderLog{
+				OrderID: order.ID,
+				UserID:  user.ID,
+				Action:  "price_locked",
+				Note:    "Harga dikunci sesuai penawaran ongkir " + quote.QuoteID + ": " + strings.Join(priceNotes, "; "),
+			})
+		}
 
-		// 5. Generate Invoices
-		if len(input.Items) > 0 {
-			// Check first item type for PO logic (Simplified business rule: cart usually mixed or single type dominance)
-			var firstProduct models.Product
-			tx.First(&firstProduct, input.Items[0].ProductID)
-
-			if firstProduct.ProductType == "po" {
-				// PO Deposit Logic
-				depositAmount := s.calculateDeposit(firstProduct, totalAmount, order.Items)
-
-				if err := helpers.GenerateInvoice(tx, order, "deposit", depositAmount, "unpaid"); err != nil {
-					return err
-				}
+		if err := shippingSvc.ConsumeQuote(tx, quote, order.ID); err != nil {
+			return err
+		}
 
-				balanceAmount := totalAmount - depositAmount
-				if err := helpers.GenerateInvoice(tx, order, "balance", balanceAmount, "pending_arrival"); err != nil {
-					return err
-				}
-			} else {
-				// Full Payment
-				if err := helpers.GenerateInvoice(tx, order, "full", totalAmount, "unpaid"); err != nil {
-					return err
-				}
-			}
+		// 5. Generate Invoices (per-line schedule: ready lines billed in full, PO lines deposit + balance)
+		if err := s.buildInvoiceSchedule(tx, order, products); err != nil {
+			return err
 		}
 
 		// 6. Sync Profile Address (Side Effect)
@@ -304,7 +359,7 @@ func (s *OrderService) CreateOrder(input CheckoutInput, user models.User) (*Crea
 	if len(invoices) > 0 {
 		primaryInvoice = invoices[0]
 	}
-	link, _ := helpers.GeneratePaymentLink(order, primaryInvoice, user, "127.0.0.1")
+	link, _ := CreatePaymentLink(order, primaryInvoice, user, "127.0.0.1")
 	paymentLink = link
 
 	// 7. Record Voucher Usage (if coupon was applied)
@@ -329,8 +384,8 @@ func (s *OrderService) CreateOrder(input CheckoutInput, user models.User) (*Crea
 	}, nil
 }
 
-// Helper: Calculate Deposit
-func (s *OrderService) calculateDeposit(product models.Product, totalAmount float64, items []models.OrderItem) float64 {
+// Helper: Calculate Deposit for a single PO line
+func (s *OrderService) calculateDeposit(product models.Product, lineAmount float64, item models.OrderItem) float64 {
 	// Try to read po_config from the product
 	if product.POConfig != nil {
 		var poConfig struct {
@@ -339,21 +394,16 @@ func (s *OrderService) calculateDeposit(product models.Product, totalAmount floa
 		}
 		if err := json.Unmarshal(product.POConfig, &poConfig); err == nil && poConfig.DepositValue > 0 {
 			if poConfig.DepositType == "fixed" {
-				// Fixed amount per product — multiply by order quantity
-				totalFixedDeposit := float64(0)
-				for _, item := range items {
-					if item.ProductID == product.ID {
-						totalFixedDeposit += poConfig.DepositValue * float64(item.Quantity)
-					}
+				// Fixed amount per unit — multiply by line quantity, never more than the line itself
+				fixedDeposit := poConfig.DepositValue * float64(item.Quantity)
+				if fixedDeposit > lineAmount {
+					return lineAmount
 				}
-				if totalFixedDeposit > 0 && totalFixedDeposit < totalAmount {
-					return totalFixedDeposit
-				}
-				return poConfig.DepositValue // fallback single
+				return fixedDeposit
 			}
 			// Percent
 			if poConfig.DepositValue > 0 && poConfig.DepositValue <= 100 {
-				return totalAmount * (poConfig.DepositValue / 100)
+				return roundMoney(lineAmount * (poConfig.DepositValue / 100))
 			}
 		}
 	}
@@ -364,7 +414,137 @@ func (s *OrderService) calculateDeposit(product models.Product, totalAmount floa
 	if parsed, err := strconv.ParseFloat(globalPct, 64); err == nil && parsed > 0 && parsed <= 100 {
 		pct = parsed
 	}
-	return totalAmount * (pct / 100)
+	return roundMoney(lineAmount * (pct / 100))
+}
+
+// buildInvoiceSchedule creates the invoices for a new order from planInvoiceSchedule.
+func (s *OrderService) buildInvoiceSchedule(tx *gorm.DB, order models.Order, products map[uint]models.Product) error {
+	for _, planned := range s.planInvoiceSchedule(order, products) {
+		var err error
+		if planned.Item == nil {
+			err = helpers.GenerateTaxedInvoice(tx, order, planned.Type, planned.Amount, planned.TaxAmount, planned.Status)
+		} else {
+			err = helpers.GenerateLineInvoice(tx, order, *planned.Item, planned.Type, planned.Amount, planned.TaxAmount, planned.Status)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plannedInvoice is one invoice of an order's payment schedule (Item nil = order-level)
+type plannedInvoice struct {
+	Item      *models.OrderItem
+	Type      string
+	Amount    float64
+	TaxAmount float64
+	Status    string
+}
+
+// planInvoiceSchedule works out the invoices an order should carry. Ready lines (plus shipping) share
+// one "full" invoice; every PO line gets its own deposit now and a balance that opens when it arrives.
+// A PO-only cart collects shipping with the first PO line's balance, as before.
+// Each invoice carries its share of the PPN so the payment journal can post it to TAX_PAYABLE.
+func (s *OrderService) planInvoiceSchedule(order models.Order, products map[uint]models.Product) []plannedInvoice {
+	var plan []plannedInvoice
+	shares := allocateLineAmounts(order.Items, order.DiscountAmount)
+
+	// Amounts due per line / for shipping, including PPN when it is charged on top of the price
+	lineTaxTotal := 0.0
+	for _, item := range order.Items {
+		lineTaxTotal += item.TaxAmount
+	}
+	shippingTax := roundMoney(order.TaxAmount - lineTaxTotal)
+	if shippingTax < 0 {
+		shippingTax = 0
+	}
+	shippingDue := order.ShippingCost
+	if !order.TaxInclusive {
+		shippingDue += shippingTax
+	}
+	lineDue := func(i int) float64 {
+		if order.TaxInclusive {
+			return shares[i]
+		}
+		return roundMoney(shares[i] + order.Items[i].TaxAmount)
+	}
+
+	hasReady := false
+	readyDue, readyTax := 0.0, 0.0
+	for i, item := range order.Items {
+		if !item.IsPO() {
+			hasReady = true
+			readyDue += lineDue(i)
+			readyTax += item.TaxAmount
+		}
+	}
+
+	if hasReady {
+		plan = append(plan, plannedInvoice{Type: "full", Amount: roundMoney(readyDue + shippingDue), TaxAmount: roundMoney(readyTax + shippingTax), Status: "unpaid"})
+	}
+
+	shippingPending := !hasReady
+	for i := range order.Items {
+		item := &order.Items[i]
+		if !item.IsPO() {
+			continue
+		}
+
+		lineAmount := lineDue(i)
+		deposit := s.calculateDeposit(products[item.ProductID], lineAmount, *item)
+		depositTax := 0.0
+		if lineAmount > 0 {
+			depositTax = roundMoney(item.TaxAmount * deposit / lineAmount)
+		}
+		balance := lineAmount - deposit
+		balanceTax := item.TaxAmount - depositTax
+		if shippingPending {
+			balance += shippingDue
+			balanceTax += shippingTax
+			shippingPending = false
+		}
+
+		if deposit > 0 {
+			plan = append(plan, plannedInvoice{Item: item, Type: "deposit", Amount: deposit, TaxAmount: depositTax, Status: "unpaid"})
+		}
+		if balance > 0 {
+			plan = append(plan, plannedInvoice{Item: item, Type: "balance", Amount: roundMoney(balance), TaxAmount: roundMoney(balanceTax), Status: "pending_arrival"})
+		}
+	}
+
+	return plan
+}
+
+// allocateLineAmounts spreads the order discount over the lines pro-rata to their totals.
+// Any rounding remainder lands on the last line so the shares always add up.
+func allocateLineAmounts(items []models.OrderItem, discount float64) []float64 {
+	shares := make([]float64, len(items))
+	subtotal := 0.0
+	for _, item := range items {
+		subtotal += item.Total
+	}
+
+	allocated := 0.0
+	for i, item := range items {
+		lineDiscount := 0.0
+		if subtotal > 0 {
+			lineDiscount = roundMoney(discount * item.Total / subtotal)
+		}
+		if i == len(items)-1 {
+			lineDiscount = roundMoney(discount - allocated)
+		}
+		allocated += lineDiscount
+		shares[i] = roundMoney(item.Total - lineDiscount)
+		if shares[i] < 0 {
+			shares[i] = 0
+		}
+	}
+	return shares
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
 }
 
 // Helper: Sync User Profile
@@ -405,214 +585,32 @@ type OrderActionInput struct {
 	Carrier        string  `json:"carrier"`
 	Amount         float64 `json:"amount"` // For refunds
 	Type           string  `json:"type"`   // For refunds: partial/full
+	Method         string  `json:"method"` // For refunds: gateway/wallet/manual (empty = original gateway when possible)
+
+	Items []ShipmentItemInput `json:"items"` // For shipping: lines in this parcel (empty = all remaining)
 }
 
-// ShipOrder handles all logic for shipping an order
+// ShipOrder ships an order, or the selected lines of it, as a new shipment (see ShipmentService)
 func (s *OrderService) ShipOrder(input OrderActionInput) (*models.Order, error) {
-	var order models.Order
-	if err := s.DB.Preload("Items.Product").Preload("User").First(&order, input.OrderID).Error; err != nil {
-		return nil, fmt.Errorf("order not found")
-	}
-
-	if order.Status == "cancelled" {
-		return nil, fmt.Errorf("cannot ship cancelled order")
-	}
-
-	// Transaction Wrapper
-	err := s.DB.Transaction(func(tx *gorm.DB) error {
-		// 1. Update Order Status
-		order.Carrier = input.Carrier
-		order.TrackingNumber = input.TrackingNumber
-		order.FulfillmentStatus = "shipped"
-		order.Status = "shipped"
-
-		if err := tx.Save(&order).Error; err != nil {
-			return err
-		}
-
-		// 2. Inventory Management
-		if err := s.processShippingSideEffects(tx, order, input.RequesterID); err != nil {
-			return err
-		}
-
-		// 3. Audit Log
-		tx.Create(&models.OrderLog{
-			OrderID:           order.ID,
-			UserID:            input.RequesterID,
-			Action:            "shipped",
-			Note:              fmt.Sprintf("Shipped via %s, Tracking: %s", input.Carrier, input.TrackingNumber),
-			IsCustomerVisible: true,
-		})
-
-		return nil
+	_, order, err := (&ShipmentService{DB: s.DB}).CreateShipment(CreateShipmentInput{
+		OrderID:        input.OrderID,
+		RequesterID:    input.RequesterID,
+		Carrier:        input.Carrier,
+		TrackingNumber: input.TrackingNumber,
+		Items:          input.Items,
 	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	// 4. BITESHIP INTEGRATION: Auto-create shipment order (LOCAL ONLY)
-	go func() {
-		// Only run biteship logic if country is ID
-		if strings.ToUpper(order.ShippingCountry) != "ID" && strings.ToUpper(order.BillingCountry) != "ID" {
-			fmt.Println("ℹ️ International Order: Skipping Biteship Auto-Pickup")
-			return
-		}
-
-		biteshipSvc := NewBiteshipService()
-		if biteshipSvc.APIKey == "" {
-			fmt.Println("⚠️ Biteship: API Key kosong, skip create order")
-			return
-		}
-
-		// Build items from order
-		var biteshipItems []BiteshipItem
-		for _, item := range order.Items {
-			// Konversi berat: DB menyimpan dalam KG, Biteship butuh GRAM
-			weight := int(item.Product.Weight * 1000)
-			if weight < 100 {
-				// Produk tanpa berat → gunakan estimasi default 2kg untuk collectible
-				weight = 2000
-			}
-
-			// Gunakan dimensi produk jika tersedia, fallback ke default
-			height := 20 // cm default
-			length := 30 // cm default (depth)
-			width := 20  // cm default
-			if item.Product.Height != nil && *item.Product.Height > 0 {
-				height = int(*item.Product.Height)
-			}
-			if item.Product.Depth != nil && *item.Product.Depth > 0 {
-				length = int(*item.Product.Depth)
-			}
-			if item.Product.Width != nil && *item.Product.Width > 0 {
-				width = int(*item.Product.Width)
-			}
-
-			biteshipItems = append(biteshipItems, BiteshipItem{
-				Name:        item.Product.Name,
-				Description: fmt.Sprintf("Order %s", order.OrderNumber),
-				Category:    "others",
-				Value:       item.Price,
-				Quantity:    item.Quantity,
-				Weight:      weight,
-				Height:      height,
-				Length:      length,
-				Width:       width,
-			})
-		}
-
-		// Fetch carrier info from DB for automatic Biteship Code mapping
-		var carrier models.CarrierTemplate
-
-		// DEFAULT courier mapping assumptions
-		biteshipCourier := ""
-		courierType := "reg"
-
-		// 1. First Pass: Get from Database Mapping
-		if err := s.DB.Where("name = ?", input.Carrier).First(&carrier).Error; err == nil && carrier.BiteshipCode != "" {
-			biteshipCourier = carrier.BiteshipCode
-		} else {
-			// 2. Second Pass (Fallback): Analyze String
-			rawCarrierLower := strings.ToLower(input.Carrier)
-			biteshipCourier = rawCarrierLower
-
-			if strings.Contains(rawCarrierLower, "sicepat") {
-				biteshipCourier = "sicepat"
-			} else if strings.Contains(rawCarrierLower, "jne") {
-				biteshipCourier = "jne"
-			} else if strings.Contains(rawCarrierLower, "j&t") || strings.Contains(rawCarrierLower, "jnt") {
-				biteshipCourier = "jnt"
-			}
-		}
-
-		// 3. Last Pass: Adjust the specific courierType
-		// (Biteship strict validation expects very specific keys per courier)
-		if biteshipCourier == "jnt" {
-			courierType = "ez" // J&T uses "ez" instead of "reg"
-		} else if biteshipCourier == "sicepat" {
-			courierType = "reg" // SiCepat uses "reg", "halu" sometimes fails in test env
-		}
-
-		storePhone := helpers.GetSetting("company_phone", os.Getenv("STORE_PHONE"))
-		if storePhone == "" {
-			storePhone = "081234567890"
-		}
-		storeEmail := helpers.GetSetting("company_email", os.Getenv("STORE_EMAIL"))
-		if storeEmail == "" {
-			storeEmail = "admin@warungforza.com"
-		}
-
-		createReq := BiteshipCreateRequest{
-			ShipperName:  "Warung Forza",
-			ShipperPhone: storePhone,
-			ShipperEmail: storeEmail,
-			ShipperOrg:   "Warung Forza Collectibles",
-
-			OriginName:       "Warung Forza HQ",
-			OriginPhone:      storePhone,
-			OriginAddress:    helpers.GetSetting("company_address", os.Getenv("STORE_ADDRESS")),
-			OriginPostalCode: helpers.GetSetting("store_postal_code", os.Getenv("STORE_POSTAL_CODE")),
-			OriginNote:       "Warung Forza Collectibles",
-
-			DestName:       order.BillingFirstName + " " + order.BillingLastName,
-			DestPhone:      order.BillingPhone,
-			DestEmail:      order.BillingEmail,
-			DestAddress:    order.BillingAddress1,
-			DestPostalCode: order.BillingPostcode,
-			DestNote:       order.Notes,
-
-			CourierCompany: biteshipCourier,
-			CourierType:    courierType,
-			Insurance:      order.TotalAmount,
-
-			Items:       biteshipItems,
-			OrderNote:   fmt.Sprintf("Warung Forza Order %s", order.OrderNumber),
-			ReferenceID: order.OrderNumber,
-		}
-
-		result, err := biteshipSvc.CreateOrder(createReq)
-		if err != nil {
-			fmt.Printf("⚠️ Biteship Create Order failed: %v (Order will still be shipped manually)\n", err)
-			return
-		}
-
-		// Update order with Biteship data
-		updates := map[string]interface{}{
-			"biteship_order_id": result.ID,
-		}
-		// Jika Biteship langsung kasih waybill, update tracking number
-		if result.Courier.WaybillID != "" && order.TrackingNumber == "" {
-			updates["tracking_number"] = result.Courier.WaybillID
-		}
-
-		s.DB.Model(&models.Order{}).Where("id = ?", order.ID).Updates(updates)
-		fmt.Printf("✅ Biteship Order Created: ID=%s, Waybill=%s\n", result.ID, result.Courier.WaybillID)
-
-		// Log
-		s.DB.Create(&models.OrderLog{
-			OrderID:           order.ID,
-			UserID:            input.RequesterID,
-			Action:            "biteship_order_created",
-			Note:              fmt.Sprintf("Biteship Order ID: %s, Kurir pickup dijadwalkan", result.ID),
-			IsCustomerVisible: true,
-		})
-	}()
-
-	// 5. Notifications (Post-Commit)
-	go s.sendShippingNotification(order)
-
-	return &order, nil
+	return order, err
 }
 
 // UpdateOrder handles complex status updates (Admin/Staff tool)
 func (s *OrderService) UpdateOrder(input UpdateOrderInput) (*models.Order, error) {
-	var order models.Order
-	if err := s.DB.Preload("Items.Product").Preload("User").First(&order, input.OrderID).Error; err != nil {
-		return nil, fmt.Errorf("order not found")
+	shipmentSvc := &ShipmentService{DB: s.DB}
+	order, err := shipmentSvc.loadOrder(s.DB, input.OrderID)
+	if err != nil {
+		return nil, err
 	}
 
-	err := s.DB.Transaction(func(tx *gorm.DB) error {
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
 		updates := map[string]interface{}{}
 
 		// 1. Fulfillment Logic
@@ -626,16 +624,23 @@ func (s *OrderService) UpdateOrder(input UpdateOrderInput) (*models.Order, error
 				if tracking == "" {
 					tracking = order.TrackingNumber
 				}
-				updates["tracking_number"] = tracking
-				updates["fulfillment_status"] = "shipped"
-				updates["status"] = "shipped" // Sync main status
+				carrier := input.Carrier
+				if carrier == "" {
+					carrier = order.Carrier
+				}
 
-				// Only process side effects if it wasn't already shipped
-				if order.FulfillmentStatus != "shipped" {
-					if err := s.processShippingSideEffects(tx, order, input.RequesterID); err != nil {
+				// Ship whatever is left as one more shipment; it also syncs status/fulfillment_status
+				if order.FulfillmentStatus != "shipped" && order.FulfillmentStatus != "delivered" {
+					if _, err := shipmentSvc.CreateShipmentTx(tx, &order, CreateShipmentInput{
+						OrderID:        order.ID,
+						RequesterID:    input.RequesterID,
+						Carrier:        carrier,
+						TrackingNumber: tracking,
+					}); err != nil {
 						return err
 					}
 				}
+				updates["tracking_number"] = tracking
 			} else {
 				updates["fulfillment_status"] = input.FulfillmentStatus
 			}
@@ -646,9 +651,17 @@ func (s *OrderService) UpdateOrder(input UpdateOrderInput) (*models.Order, error
 			updates["payment_status"] = input.PaymentStatus
 
 			if input.PaymentStatus == "deposit_paid" {
-				// PO Logic: Generate Balance Invoice if needed
+				// PO Logic: Generate Balance Invoice if needed (skip when the line schedule already has one)
 				remaining := order.TotalAmount - order.DepositPaid
-				if len(order.Items) > 0 && order.Items[0].Product.ProductType == "po" && remaining > 0 {
+				hasPO := false
+				for _, item := range order.Items {
+					if item.IsPO() {
+						hasPO = true
+					}
+				}
+				var balanceCount int64
+				tx.Model(&models.Invoice{}).Where("order_id = ? AND type = ?", order.ID, "balance").Count(&balanceCount)
+				if hasPO && balanceCount == 0 && remaining > 0 {
 					helpers.GenerateInvoice(tx, order, "balance", remaining, "unpaid")
 				}
 			}
@@ -702,37 +715,41 @@ func (s *OrderService) UpdateOrder(input UpdateOrderInput) (*models.Order, error
 // CancelOrder handles cancellation logic including refunds and stock return
 func (s *OrderService) CancelOrder(input OrderActionInput) (*models.Order, error) {
 	var order models.Order
-	if err := s.DB.Preload("Items.Product").First(&order, input.OrderID).Error; err != nil {
+	if err := s.DB.Preload("Items.Product").Preload("Invoices").First(&order, input.OrderID).Error; err != nil {
 		return nil, fmt.Errorf("order not found")
 	}
 
-	if order.FulfillmentStatus == "shipped" || order.FulfillmentStatus == "delivered" {
+	if order.FulfillmentStatus == "shipped" || order.FulfillmentStatus == "delivered" || order.FulfillmentStatus == "partial" {
 		return nil, fmt.Errorf("cannot cancel shipped or delivered orders")
 	}
 
 	err := s.DB.Transaction(func(tx *gorm.DB) error {
-		// 1. Return Stock (Release Reservation)
+		// 1. Return Stock per line (to the default location): settled lines already left stock, the rest only hold a reservation
 		for _, item := range order.Items {
-			helpers.RecordStockMovement(tx, item.ProductID, -item.Quantity, "reserved", "cancellation", "ORDER", order.OrderNumber, "Order cancelled", &input.RequesterID)
-			if err := tx.Model(&item.Product).Update("reserved_qty", gorm.Expr("GREATEST(reserved_qty - ?, 0)", item.Quantity)).Error; err != nil {
+			if item.SettledAt == nil || item.ForfeitedAt != nil {
+				continue
+			}
+			if err := helpers.RecordLocationStockMovement(tx, 0, item.ProductID, item.VariantID, item.Quantity, "physical", "cancellation", "ORDER", order.OrderNumber, "Order cancelled (restock)", &input.RequesterID); err != nil {
+				return err
+			}
+			if err := helpers.AdjustStock(tx, item.ProductID, item.VariantID, map[string]interface{}{"stock": gorm.Expr("stock + ?", item.Quantity)}); err != nil {
 				return err
 			}
 		}
-
-		// 2. Process Refund (if paid)
-		refundAmount := 0.0
-		if order.PaymentStatus == "paid" {
-			refundAmount = order.TotalAmount
-		} else if order.PaymentStatus == "deposit_paid" {
-			refundAmount = order.DepositPaid
+		if err := (&InventoryService{DB: tx}).ReleaseOrder(order, "cancellation", "Order cancelled", &input.RequesterID); err != nil {
+			return err
 		}
 
+		// 2. Process Refund (everything paid so far across all line schedules, minus earlier refunds)
+		refundAmount := refundableAmount(tx, order)
+
 		if refundAmount > 0 {
-			if err := s.processAutoRefund(tx, order, refundAmount); err != nil {
+			method, err := s.processAutoRefund(tx, order, refundAmount, "cancellation", input.RequesterID)
+			if err != nil {
 				return err
 			}
 			order.PaymentStatus = "refunded"
-			order.InternalNotes += fmt.Sprintf("\n[SYSTEM] Auto-refunded Rp %.0f to Wallet", refundAmount)
+			order.InternalNotes += fmt.Sprintf("\n[SYSTEM] Auto-refund Rp %.0f via %s", refundAmount, method)
 		}
 
 		// 3. Update Order Status
@@ -740,7 +757,7 @@ func (s *OrderService) CancelOrder(input OrderActionInput) (*models.Order, error
 		order.FulfillmentStatus = "cancelled"
 		order.InternalNotes += "\n[CANCELLED] " + input.Reason
 
-		if err := tx.Save(&order).Error; err != nil {
+		if err := tx.Omit(clause.Associations).Save(&order).Error; err != nil {
 			return err
 		}
 
@@ -763,6 +780,8 @@ func (s *OrderService) CancelOrder(input OrderActionInput) (*models.Order, error
 		return nil, err
 	}
 
+	(&RefundService{DB: s.DB}).ProcessOrder(order.ID)
+
 	// 5. BITESHIP INTEGRATION: Auto-cancel shipment jika ada
 	if order.BiteshipOrderID != "" {
 		go func() {
@@ -791,192 +810,195 @@ func (s *OrderService) CancelOrder(input OrderActionInput) (*models.Order, error
 	return &order, nil
 }
 
-// RefundOrder handles the refund process for an order
-func (s *OrderService) RefundOrder(input OrderActionInput, requester models.User, ip, userAgent string) (*models.Order, error) {
-	var order models.Order
-	if err := s.DB.First(&order, input.OrderID).Error; err != nil {
-		return nil, fmt.Errorf("order not found")
-	}
-
-	// Validate refund amount
-	totalPaid := order.DepositPaid
-	if order.PaymentStatus == "paid" {
-		totalPaid = order.TotalAmount
-	}
-
-	if input.Amount > totalPaid {
-		return nil, fmt.Errorf("refund amount cannot exceed total paid")
-	}
-
-	oldOrder := order
+// RefundOrder refunds (part of) an order. The refund runs through the RefundService status machine:
+// back through the original gateway by default (manual payout when the gateway has no refund API),
+// or to the wallet / manually when requested. Returns the refunds with the method each one got.
+func (s *OrderService) RefundOrder(input OrderActionInput, requester models.User, ip, userAgent string) (*models.Order, []models.Refund, error) {
+	var order, oldOrder models.Order
+	var refunds []models.Refund
 	err := s.DB.Transaction(func(tx *gorm.DB) error {
-		// Determine refund type
-		if input.Type == "" {
-			if input.Amount >= totalPaid {
-				input.Type = "full"
-			} else {
-				input.Type = "partial"
-			}
+		// Lock the order so the balance checked here is still the balance when the refund is recorded
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items.Product").Preload("Invoices").First(&order, input.OrderID).Error; err != nil {
+			return fmt.Errorf("order not found")
 		}
+		oldOrder = order
 
-		if input.Type == "full" {
-			order.PaymentStatus = "refunded"
-		} else {
-			order.PaymentStatus = "refunded_partial"
+		// Validate refund amount against what is paid and not refunded yet
+		refundable := refundableAmount(tx, order)
+		if input.Amount == 0 && input.Type != "partial" {
+			input.Amount = refundable // Full refund of the remaining balance
 		}
-
-		order.InternalNotes = order.InternalNotes + fmt.Sprintf("\n[REFUND] Amount: %.2f, Reason: %s", input.Amount, input.Reason)
-
-		if err := tx.Save(&order).Error; err != nil {
-			return err
+		if input.Amount <= 0 {
+			return fmt.Errorf("nothing left to refund on this order")
 		}
-
-		// Create payment transaction record for refund
-		tx.Create(&models.PaymentTransaction{
-			OrderID: order.ID,
-			Type:    "refund",
-			Amount:  input.Amount,
-			Status:  "success",
-		})
-
-		// Financial: Reverse the revenue using Centralized Helper
-		coaBankID, err := helpers.GetPrimaryBankCOA(tx)
-		if err == nil {
-			var coaSourceID uint
-			if order.PaymentStatus == "paid" {
-				coaRevKey := "RETAIL_REVENUE"
-				if len(order.Items) > 0 && order.Items[0].Product.ProductType == "po" {
-					coaRevKey = "PO_REVENUE"
-				}
-				coaSourceID, _ = helpers.GetCOAByMappingKey(coaRevKey)
-			} else {
-				coaSourceID, _ = helpers.GetCOAByMappingKey("CUSTOMER_DEPOSIT")
-			}
-
-			if coaSourceID != 0 {
-				helpers.PostJournalWithTX(tx, order.OrderNumber, "REFUND", "Refund Processed", []models.JournalItem{
-					{COAID: coaSourceID, Debit: input.Amount, Credit: 0},
-					{COAID: coaBankID, Debit: 0, Credit: input.Amount},
-				})
-			}
+		if input.Amount > refundable+0.01 {
+			return fmt.Errorf("refund amount cannot exceed total paid (refundable Rp %.0f)", refundable)
 		}
 
-		// Create order log
-		tx.Create(&models.OrderLog{
-			OrderID:           order.ID,
-			UserID:            requester.ID,
-			Action:            "refund",
-			Note:              fmt.Sprintf("Refunded %.2f - %s", input.Amount, input.Reason),
-			IsCustomerVisible: true,
+		var err error
+		refunds, err = (&RefundService{DB: tx}).Request(RefundInput{
+			OrderID:     order.ID,
+			Amount:      input.Amount,
+			Method:      input.Method,
+			Type:        input.Type,
+			Reason:      input.Reason,
+			Source:      "order",
+			SourceRef:   order.OrderNumber,
+			RequestedBy: &requester.ID,
 		})
-
-		return nil
+		return err
 	})
-
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Notifications
-	helpers.NotifyUser(order.UserID, "REFUND_PROCESSED",
-		fmt.Sprintf("Refund of Rp %s has been processed for Order %s", helpers.FormatPrice(input.Amount), order.OrderNumber),
-		map[string]interface{}{
-			"order_id": order.ID,
-			"amount":   input.Amount,
-		})
-
-	// Send Email
-	go func() {
-		var u models.User
-		if err := s.DB.First(&u, order.UserID).Error; err == nil {
-			helpers.SendRefundEmail(u.Email, order.OrderNumber, input.Amount, input.Type, input.Reason)
+	// Execute after commit: gateway calls, journal and customer email happen in the status machine
+	processed := (&RefundService{DB: s.DB}).ProcessOrder(order.ID)
+	var failures []string
+	for i, r := range processed {
+		for j := range refunds {
+			if refunds[j].ID == r.ID {
+				refunds[j] = processed[i]
+			}
 		}
-	}()
+		if r.Status == models.RefundStatusFailed {
+			failures = append(failures, fmt.Sprintf("%s: %s", r.RefundNumber, r.FailureNote))
+		}
+	}
 
+	s.DB.Preload("Items.Product").Preload("Invoices").First(&order, order.ID)
+	numbers := make([]string, 0, len(refunds))
+	for _, r := range refunds {
+		numbers = append(numbers, r.RefundNumber)
+	}
 	helpers.LogAudit(requester.ID, "Order", "Refund", order.OrderNumber,
-		fmt.Sprintf("Refunded %.2f: %s", input.Amount, input.Reason),
+		fmt.Sprintf("Refund %.2f requested (%s): %s", input.Amount, strings.Join(numbers, ", "), input.Reason),
 		oldOrder, order, ip, userAgent)
 
-	return &order, nil
+	if len(failures) > 0 {
+		return &order, refunds, fmt.Errorf("refund failed - %s", strings.Join(failures, "; "))
+	}
+	return &order, refunds, nil
 }
 
-// MarkArrived handles PO arrival logic
-func (s *OrderService) MarkArrived(orderID uint, requesterID uint) (*models.Order, error) {
+// MarkArrived handles PO arrival logic per line. itemID 0 marks every outstanding PO line of the
+// order as arrived; otherwise only that line. Each arrived line opens its own balance invoice.
+func (s *OrderService) MarkArrived(orderID uint, itemID uint, requesterID uint) (*models.Order, error) {
 	var order models.Order
 	if err := s.DB.Preload("Invoices").Preload("User").Preload("Items.Product").First(&order, orderID).Error; err != nil {
 		return nil, fmt.Errorf("order not found")
 	}
 
-	isPO := false
-	var balanceInvoice *models.Invoice
-
-	for i := range order.Invoices {
-		if order.Invoices[i].Type == "balance" {
-			balanceInvoice = &order.Invoices[i]
+	hasReady := false
+	firstPOIndex := -1
+	var lines []int
+	for i := range order.Items {
+		item := order.Items[i]
+		if !item.IsPO() {
+			hasReady = true
+			continue
+		}
+		if firstPOIndex == -1 {
+			firstPOIndex = i
 		}
+		if item.ArrivedAt != nil || item.ForfeitedAt != nil || (itemID != 0 && item.ID != itemID) {
+			continue
+		}
+		lines = append(lines, i)
 	}
 
-	if len(order.Items) > 0 && order.Items[0].Product.ProductType == "po" {
-		isPO = true
+	if firstPOIndex == -1 {
+		return nil, fmt.Errorf("not a valid PO order")
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no outstanding PO item to mark as arrived")
 	}
 
-	if !isPO {
-		return nil, fmt.Errorf("not a valid PO order")
+	now := time.Now()
+	dueDate := now.AddDate(0, 0, 7)
+
+	type arrival struct {
+		productName string
+		invoice     *models.Invoice // nil when the line was already paid in full
 	}
+	var arrivals []arrival
 
-	// SCENARIO 1: FULLY PAID PO
-	if order.RemainingBalance <= 0 || (balanceInvoice == nil && order.PaymentStatus == "paid") {
-		order.Status = "processing"
-		order.FulfillmentStatus = "ready_to_ship"
-		order.InternalNotes += "\n[SYSTEM] PO Arrived. Order is fully paid. Marked as Ready to Ship."
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, idx := range lines {
+			item := &order.Items[idx]
+			item.ArrivedAt = &now
+			if err := tx.Model(item).Update("arrived_at", now).Error; err != nil {
+				return err
+			}
 
-		if err := s.DB.Save(&order).Error; err != nil {
-			return nil, err
-		}
+			balanceInvoice := lineBalanceInvoice(&order, *item)
 
-		go func() {
-			productName := order.Items[0].Product.Name
-			helpers.SendPOArrivalInFullEmail(order.User.Email, productName)
-		}()
+			// SCENARIO 1: LINE FULLY PAID
+			if balanceInvoice == nil || balanceInvoice.Status == "paid" || balanceInvoice.Status == "cancelled" || balanceInvoice.Status == "expired" {
+				arrivals = append(arrivals, arrival{productName: item.Product.Name})
+				continue
+			}
 
-		return &order, nil
-	}
+			// SCENARIO 2: BALANCE DUE
+			// Dynamic Shipping Recalculation (only the line that carries shipping on a PO-only order)
+			if !hasReady && idx == firstPOIndex {
+				newShipping, errRecalc := s.recalculatePOShipping(&order)
+				if errRecalc == nil && newShipping > 0 && newShipping != order.ShippingCost {
+					diff := newShipping - order.ShippingCost
+					order.InternalNotes += fmt.Sprintf("\n[SYSTEM] Shipping recalculated on arrival: Rp %.0f -> Rp %.0f based on final product weight.", order.ShippingCost, newShipping)
+					order.ShippingCost = newShipping
+					order.TotalAmount += diff
+					order.RemainingBalance += diff
+					balanceInvoice.Amount += diff
+				}
+			}
 
-	// SCENARIO 2: BALANCE DUE
-	if balanceInvoice == nil {
-		return nil, fmt.Errorf("balance invoice missing")
-	}
+			balanceInvoice.Status = "unpaid"
+			balanceInvoice.DueDate = dueDate
+			if err := tx.Save(balanceInvoice).Error; err != nil {
+				return err
+			}
+			arrivals = append(arrivals, arrival{productName: item.Product.Name, invoice: balanceInvoice})
+		}
 
-	if balanceInvoice.Status == "paid" {
-		return nil, fmt.Errorf("balance already paid")
-	}
+		balanceOpened := false
+		for _, a := range arrivals {
+			if a.invoice != nil {
+				balanceOpened = true
+			}
+		}
 
-	// Dynamic Shipping Recalculation
-	newShipping, errRecalc := s.recalculatePOShipping(&order)
-	if errRecalc == nil && newShipping > 0 && newShipping != order.ShippingCost {
-		diff := newShipping - order.ShippingCost
-		order.ShippingCost = newShipping
-		order.TotalAmount += diff
-		order.RemainingBalance += diff
-		balanceInvoice.Amount += diff
-		order.InternalNotes += fmt.Sprintf("\n[SYSTEM] Shipping recalculated on arrival: Rp %.0f -> Rp %.0f based on final product weight.", order.ShippingCost-diff, newShipping)
-	}
+		allArrived := true
+		for _, item := range order.Items {
+			if item.IsPO() && item.ArrivedAt == nil {
+				allArrived = false
+			}
+		}
 
-	now := time.Now()
-	dueDate := now.AddDate(0, 0, 7)
+		if balanceOpened {
+			order.Status = "waiting_payment"
+			order.PaymentStatus = "balance_due"
+		} else if allArrived && (order.RemainingBalance <= 0 || order.PaymentStatus == "paid") {
+			order.Status = "processing"
+			order.FulfillmentStatus = "ready_to_ship"
+			order.InternalNotes += "\n[SYSTEM] PO Arrived. Order is fully paid. Marked as Ready to Ship."
+		}
 
-	err := s.DB.Transaction(func(tx *gorm.DB) error {
-		balanceInvoice.Status = "unpaid"
-		balanceInvoice.DueDate = dueDate
-		if err := tx.Save(balanceInvoice).Error; err != nil {
+		if err := tx.Omit(clause.Associations).Save(&order).Error; err != nil {
 			return err
 		}
 
-		order.Status = "waiting_payment"
-		order.PaymentStatus = "balance_due"
-		if err := tx.Save(&order).Error; err != nil {
-			return err
+		var names []string
+		for _, a := range arrivals {
+			names = append(names, a.productName)
 		}
+		tx.Create(&models.OrderLog{
+			OrderID:           order.ID,
+			UserID:            requesterID,
+			Action:            "po_arrived",
+			Note:              "PO item arrived: " + strings.Join(names, ", "),
+			IsCustomerVisible: true,
+		})
 
 		return nil
 	})
@@ -986,11 +1008,13 @@ func (s *OrderService) MarkArrived(orderID uint, requesterID uint) (*models.Orde
 	}
 
 	go func() {
-		productName := "Unknown Product"
-		if len(order.Items) > 0 {
-			productName = order.Items[0].Product.Name
+		for _, a := range arrivals {
+			if a.invoice == nil {
+				helpers.SendPOArrivalInFullEmail(order.User.Email, a.productName)
+				continue
+			}
+			helpers.SendPOArrivalEmail(order.User.Email, order.User.FullName, a.productName, a.invoice.Amount, dueDate.Format("02 Jan 2006"))
 		}
-		helpers.SendPOArrivalEmail(order.User.Email, order.User.FullName, productName, balanceInvoice.Amount, dueDate.Format("02 Jan 2006"))
 	}()
 
 	return &order, nil
@@ -998,44 +1022,7 @@ func (s *OrderService) MarkArrived(orderID uint, requesterID uint) (*models.Orde
 
 // ConfirmDelivery handles final fulfillment step
 func (s *OrderService) ConfirmDelivery(orderID uint, userID uint) (*models.Order, error) {
-	var order models.Order
-	if err := s.DB.Preload("Items.Product").First(&order, orderID).Error; err != nil {
-		return nil, fmt.Errorf("order not found")
-	}
-
-	if order.UserID != userID && userID != 0 { // 0 for admin override
-		return nil, fmt.Errorf("unauthorized")
-	}
-
-	if order.Status != "shipped" && order.Status != "delivered" {
-		return nil, fmt.Errorf("order must be shipped to be confirmed")
-	}
-
-	err := s.DB.Transaction(func(tx *gorm.DB) error {
-		order.Status = "completed"
-		order.FulfillmentStatus = "delivered"
-		order.CompletedAt = func(t time.Time) *time.Time { return &t }(time.Now())
-
-		if err := tx.Save(&order).Error; err != nil {
-			return err
-		}
-
-		// Create Order Log
-		tx.Create(&models.OrderLog{
-			OrderID: order.ID,
-			UserID:  userID,
-			Action:  "completed",
-			Note:    "Order marked as completed/delivered by customer",
-		})
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &order, nil
+	return (&ShipmentService{DB: s.DB}).ConfirmDelivery(orderID, 0, userID)
 }
 
 // CheckExpiredPOs scans for ghosting customers in PO
@@ -1052,20 +1039,28 @@ func (s *OrderService) CheckExpiredPOs(adminID uint) (int, error) {
 
 	count := 0
 	for _, inv := range invoices {
+		// Mixed/multi-PO order: only the ghosted line is forfeited, the rest of the order stands
+		if inv.OrderItemID != nil && len(inv.Order.Items) > 1 {
+			if err := s.forfeitPOLine(inv, adminID); err == nil {
+				count++
+				helpers.NotifyUser(inv.Order.UserID, "PO_FORFEITED", "Pre-order item cancelled and its deposit forfeited due to late balance payment.", nil)
+			}
+			continue
+		}
+
 		// Ghosting Scenarios: Cancel order and Forfeit deposit
 		err := s.DB.Transaction(func(tx *gorm.DB) error {
 			order := inv.Order
 			order.Status = "cancelled"
 			order.InternalNotes += "\n[SYSTEM] PO forfeited due to non-payment of balance after arrival."
 
-			if err := tx.Save(&order).Error; err != nil {
+			if err := tx.Omit(clause.Associations).Save(&order).Error; err != nil {
 				return err
 			}
 
-			// Clear reservation but physical stock STAYS (already reduced if shipped?? no, PO arrival doesn't reduce physical usually until shipping)
-			// Actually PO items might have been reserved.
-			for _, item := range order.Items {
-				tx.Model(&item.Product).Update("reserved_qty", gorm.Expr("GREATEST(reserved_qty - ?, 0)", item.Quantity))
+			// Clear reservation but physical stock STAYS (PO arrival doesn't reduce physical stock until the line is paid)
+			if err := (&InventoryService{DB: tx}).ReleaseOrder(order, "forfeit", "PO forfeited (ghosted balance)", nil); err != nil {
+				return err
 			}
 
 			// Financial: Recognition of Forfeited Deposit as Miscellaneous Revenue
@@ -1079,7 +1074,7 @@ func (s *OrderService) CheckExpiredPOs(adminID uint) (int, error) {
 			}
 
 			inv.Status = "expired"
-			tx.Save(&inv)
+			tx.Omit(clause.Associations).Save(&inv)
 
 			return nil
 		})
@@ -1093,29 +1088,138 @@ func (s *OrderService) CheckExpiredPOs(adminID uint) (int, error) {
 	return count, nil
 }
 
+// forfeitPOLine forfeits a single PO line whose balance went unpaid: its reservation is released,
+// its paid deposit becomes other income and its balance invoice expires
+func (s *OrderService) forfeitPOLine(balance models.Invoice, adminID uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var item models.OrderItem
+		if err := tx.Preload("Product").First(&item, *balance.OrderItemID).Error; err != nil {
+			return err
+		}
+
+		if err := (&InventoryService{DB: tx}).ReleaseLine(balance.Order, item, item.Quantity, "forfeit", "PO line forfeited (ghosted balance)", nil); err != nil {
+			return err
+		}
+		if err := tx.Model(&item).Update("forfeited_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		var deposit models.Invoice
+		if err := tx.Where("order_item_id = ? AND type = ? AND status IN ?", item.ID, "deposit", []string{"paid", "paid_late"}).First(&deposit).Error; err == nil {
+			coaMiscRevID, _ := helpers.GetCOAByMappingKey("OTHER_INCOME")
+			coaLiabID, _ := helpers.GetCOAByMappingKey("CUSTOMER_DEPOSIT")
+			if coaMiscRevID != 0 && coaLiabID != 0 && deposit.Amount > 0 {
+				helpers.PostJournalWithTX(tx, balance.Order.OrderNumber, "ADJUSTMENT", fmt.Sprintf("PO Deposit Forfeited (%s)", item.Product.Name), []models.JournalItem{
+					{COAID: coaLiabID, Debit: deposit.Amount, Credit: 0},
+					{COAID: coaMiscRevID, Debit: 0, Credit: deposit.Amount},
+				})
+			}
+		}
+
+		if err := tx.Model(&models.Invoice{}).Where("id = ?", balance.ID).Update("status", "expired").Error; err != nil {
+			return err
+		}
+
+		tx.Create(&models.OrderLog{
+			OrderID:           balance.Order.ID,
+			UserID:            adminID,
+			Action:            "po_line_forfeited",
+			Note:              fmt.Sprintf("PO item %s forfeited: balance %s not paid before due date.", item.Product.Name, balance.InvoiceNumber),
+			IsCustomerVisible: true,
+		})
+
+		// Remaining lines carry on; recompute order state without the forfeited balance
+		_, err := s.SyncPaymentState(tx, balance.Order.ID)
+		return err
+	})
+}
+
 // UpdatePaymentTotals recalculates paid/unpaid amounts and updates order state
 func (s *OrderService) UpdatePaymentTotals(orderID uint) error {
-	var order models.Order
-	if err := s.DB.Preload("Invoices").Preload("Items.Product").First(&order, orderID).Error; err != nil {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := s.SyncPaymentState(tx, orderID)
 		return err
+	})
+}
+
+// SyncPaymentState is the single place an invoice payment moves an order forward. Callers mark
+// the invoice paid inside tx first; this settles every line whose schedule is now fully paid
+// (reserved -> sold) and derives the order's payment/status from its invoices.
+func (s *OrderService) SyncPaymentState(tx *gorm.DB, orderID uint) (*models.Order, error) {
+	var order models.Order
+	if err := tx.Preload("Invoices").Preload("Items.Product").First(&order, orderID).Error; err != nil {
+		return nil, err
+	}
+
+	if order.Status == "cancelled" || order.PaymentStatus == "refunded" {
+		return &order, nil
 	}
 
 	totalPaid := 0.0
 	depositPaid := 0.0
-	allPaid := true
-
+	outstanding := 0.0
+	balanceOpen := false
 	for _, inv := range order.Invoices {
-		if inv.Status == "paid" {
+		switch inv.Status {
+		case "paid", "paid_late":
 			totalPaid += inv.Amount
 			if inv.Type == "deposit" {
 				depositPaid += inv.Amount
 			}
-		} else if inv.Status == "unpaid" {
-			allPaid = false
+		case "unpaid", "pending_arrival":
+			outstanding += inv.Amount
+			if inv.Type == "balance" && inv.Status == "unpaid" {
+				balanceOpen = true
+			}
 		}
 	}
 
-	remaining := order.TotalAmount - totalPaid
+	// Orders fully paid before per-line settlement existed already had their stock cut. Their lines
+	// hold no reservation rows; lines added since (e.g. by an order edit) do and are consumed as usual.
+	wasPaid := order.PaymentStatus == "paid"
+	inventory := &InventoryService{DB: tx}
+
+	now := time.Now()
+	allSettled := true
+	hasPO := false
+	for i := range order.Items {
+		item := &order.Items[i]
+		if item.IsPO() {
+			hasPO = true
+		}
+		if item.SettledAt != nil || item.ForfeitedAt != nil {
+			continue
+		}
+		if !isLineSettled(order, *item) {
+			allSettled = false
+			continue
+		}
+
+		legacy := false
+		if wasPaid {
+			rows, err := inventory.lineReservations(order.ID, item.ID)
+			if err != nil {
+				return nil, err
+			}
+			legacy = len(rows) == 0
+		}
+		if !legacy {
+			// Reserved -> Sold for this line only
+			if err := inventory.ConsumeLine(order, *item, nil); err != nil {
+				return nil, err
+			}
+		}
+		if err := tx.Model(item).Update("settled_at", now).Error; err != nil {
+			return nil, err
+		}
+		item.SettledAt = &now
+	}
+
+	// What the customer still owes on the schedule (forfeited balances no longer count)
+	remaining := outstanding
+	if len(order.Invoices) == 0 {
+		remaining = order.TotalAmount - totalPaid
+	}
 	if remaining < 0 {
 		remaining = 0
 	}
@@ -1125,41 +1229,102 @@ func (s *OrderService) UpdatePaymentTotals(orderID uint) error {
 		"remaining_balance": remaining,
 	}
 
-	shouldCutStock := false
-
 	// Status State Machine
-	if allPaid || remaining <= 0 {
-		updates["payment_status"] = "paid_full"
-		if order.Status == "pending" {
+	awaitingPayment := order.Status == "pending" || order.Status == "pre_order" || order.Status == "waiting_payment" || order.Status == "payment_due"
+	if allSettled || (remaining <= 0 && totalPaid > 0) {
+		updates["payment_status"] = "paid"
+		if awaitingPayment {
 			updates["status"] = "processing"
-			shouldCutStock = true
 		}
 	} else if totalPaid > 0 {
-		updates["payment_status"] = "deposit_paid"
-		if order.Status == "pending" {
-			// PO Orders go to pre_order
-			updates["status"] = "pre_order"
+		if balanceOpen {
+			updates["payment_status"] = "balance_due"
+			if awaitingPayment {
+				updates["status"] = "waiting_payment"
+			}
+		} else {
+			updates["payment_status"] = "deposit_paid"
+			if awaitingPayment && hasPO {
+				// PO Orders go to pre_order
+				updates["status"] = "pre_order"
+			}
 		}
 	}
 
-	err := s.DB.Model(&order).Updates(updates).Error
-	if err != nil {
-		return err
+	if err := tx.Model(&order).Updates(updates).Error; err != nil {
+		return nil, err
 	}
 
-	if shouldCutStock {
-		// Cut stock if we transition to processing (like in paymentController webhook)
-		for _, item := range order.Items {
-			s.DB.Model(&models.Product{}).
-				Where("id = ?", item.ProductID).
-				Updates(map[string]interface{}{
-					"stock":        gorm.Expr("stock - ?", item.Quantity),
-					"reserved_qty": gorm.Expr("GREATEST(reserved_qty - ?, 0)", item.Quantity),
-				})
+	return &order, nil
+}
+
+// isLineSettled reports whether every invoice covering the line has been paid.
+// PO lines are covered by their own deposit/balance (or the order-level pair on legacy orders);
+// ready lines by the order-level "full" invoice.
+func isLineSettled(order models.Order, item models.OrderItem) bool {
+	var covering []models.Invoice
+	if item.IsPO() {
+		covering = lineInvoices(order, item)
+	}
+	if len(covering) == 0 {
+		// Ready lines (or PO lines billed in full, e.g. manual orders)
+		for _, inv := range order.Invoices {
+			if inv.OrderItemID == nil && inv.Type != "deposit" && inv.Type != "balance" {
+				covering = append(covering, inv)
+			}
+		}
+		if len(covering) == 0 {
+			covering = order.Invoices
 		}
 	}
 
-	return nil
+	paid := 0
+	for _, inv := range covering {
+		switch inv.Status {
+		case "paid", "paid_late":
+			paid++
+		case "cancelled", "expired":
+			// Voided invoices don't block settlement
+		default:
+			return false
+		}
+	}
+	return paid > 0
+}
+
+// lineInvoices returns the deposit/balance invoices of a PO line, falling back to the
+// order-level pair for orders created before invoices were scoped per line
+func lineInvoices(order models.Order, item models.OrderItem) []models.Invoice {
+	var scoped, legacy []models.Invoice
+	for _, inv := range order.Invoices {
+		if inv.OrderItemID != nil && *inv.OrderItemID == item.ID {
+			scoped = append(scoped, inv)
+		} else if inv.OrderItemID == nil && (inv.Type == "deposit" || inv.Type == "balance") {
+			legacy = append(legacy, inv)
+		}
+	}
+	if len(scoped) > 0 {
+		return scoped
+	}
+	return legacy
+}
+
+// lineBalanceInvoice returns a pointer into order.Invoices for the line's balance invoice
+func lineBalanceInvoice(order *models.Order, item models.OrderItem) *models.Invoice {
+	var legacy *models.Invoice
+	for i := range order.Invoices {
+		inv := &order.Invoices[i]
+		if inv.Type != "balance" {
+			continue
+		}
+		if inv.OrderItemID != nil && *inv.OrderItemID == item.ID {
+			return inv
+		}
+		if inv.OrderItemID == nil && legacy == nil {
+			legacy = inv
+		}
+	}
+	return legacy
 }
 
 // ForfeitPO handles manual forfeiture of a PO deposit
@@ -1182,8 +1347,8 @@ func (s *OrderService) ForfeitPO(orderID uint, adminID uint) (*models.Order, err
 		}
 
 		// Clear reservation
-		for _, item := range order.Items {
-			tx.Model(&item.Product).Update("reserved_qty", gorm.Expr("GREATEST(reserved_qty - ?, 0)", item.Quantity))
+		if err := (&InventoryService{DB: tx}).ReleaseOrder(order, "forfeit", "PO forfeited by admin", &adminID); err != nil {
+			return err
 		}
 
 		// Financial: Recognition of Forfeited Deposit as Miscellaneous Revenue
@@ -1298,105 +1463,98 @@ func (s *OrderService) recalculatePOShipping(order *models.Order) (float64, erro
 
 // Internal Helpers
 
-// processShippingSideEffects handles inventory and finance for shipping
-func (s *OrderService) processShippingSideEffects(tx *gorm.DB, order models.Order, requesterID uint) error {
-	// Inventory was ALREADY deducted when order moved to 'Processing'.
-	// Only run financials here.
-
-	// Financials
-	if err := s.recordShippingJournal(tx, order); err != nil {
-		return err
-	}
-	return nil
+// processAutoRefund requests the refund of money the customer no longer owes (cancellation, order edit).
+// The method comes from setting auto_refund_method (wallet by default); gateway refunds fall back to the
+// wallet when the original charges cannot cover the amount. Callers run RefundService.ProcessOrder after commit.
+func (s *OrderService) processAutoRefund(tx *gorm.DB, order models.Order, amount float64, source string, requesterID uint) (string, error) {
+	input := RefundInput{
+		OrderID:     order.ID,
+		Amount:      amount,
+		Method:      helpers.GetSetting("auto_refund_method", RefundMethodWallet),
+		Reason:      fmt.Sprintf("Auto refund (%s)", source),
+		Source:      source,
+		SourceRef:   order.OrderNumber,
+		RequestedBy: &requesterID,
+	}
+	svc := &RefundService{DB: tx}
+	_, err := svc.Request(input)
+	if err != nil && input.Method == RefundMethodGateway {
+		input.Method = RefundMethodWallet
+		_, err = svc.Request(input)
+	}
+	return input.Method, err
 }
 
-func (s *OrderService) recordShippingJournal(tx *gorm.DB, order models.Order) error {
-	// Revenue Recognition
-	coaLiabID, _ := helpers.GetCOAByMappingKey("CUSTOMER_DEPOSIT")
-	coaRevID, _ := helpers.GetCOAByMappingKey("PO_REVENUE")
-	if coaLiabID != 0 && coaRevID != 0 {
-		helpers.PostJournalWithTX(tx, order.OrderNumber, "ORDER", "Revenue Recognition - Order Shipped", []models.JournalItem{
-			{COAID: coaLiabID, Debit: order.TotalAmount, Credit: 0},
-			{COAID: coaRevID, Debit: 0, Credit: order.TotalAmount},
-		})
-	}
-
-	// COGS
-	coaCOGSID, _ := helpers.GetCOAByMappingKey("COGS_EXPENSE")
-	coaInvID, _ := helpers.GetCOAByMappingKey("INVENTORY_ASSET")
-	totalCOGS := 0.0
-	for _, item := range order.Items {
-		totalCOGS += item.COGSSnapshot * float64(item.Quantity)
-	}
-
-	if coaCOGSID != 0 && coaInvID != 0 && totalCOGS > 0 {
-		helpers.PostJournalWithTX(tx, order.OrderNumber, "ORDER", "COGS Recognition - Order Shipped", []models.JournalItem{
-			{COAID: coaCOGSID, Debit: totalCOGS, Credit: 0},
-			{COAID: coaInvID, Debit: 0, Credit: totalCOGS},
-		})
+// paidInvoiceTotal sums every paid invoice of the order (ready, deposit and balance lines alike)
+func paidInvoiceTotal(order models.Order) float64 {
+	total := 0.0
+	for _, inv := range order.Invoices {
+		if inv.Status == "paid" || inv.Status == "paid_late" {
+			total += inv.Amount
+		}
 	}
-	return nil
+	return total
 }
 
-func (s *OrderService) processAutoRefund(tx *gorm.DB, order models.Order, amount float64) error {
-	var user models.User
-	if err := tx.First(&user, order.UserID).Error; err != nil {
-		return fmt.Errorf("user not found for refund")
-	}
-
-	balanceBefore := user.Balance
-	user.Balance += amount
-	if err := tx.Save(&user).Error; err != nil {
-		return err
-	}
-
-	// Wallet Transaction
-	tx.Create(&models.WalletTransaction{
-		UserID:        user.ID,
-		Type:          "refund",
-		Amount:        amount,
-		Description:   fmt.Sprintf("Refund for Order %s", order.OrderNumber),
-		ReferenceType: "order",
-		ReferenceID:   order.OrderNumber,
-		BalanceBefore: balanceBefore,
-		BalanceAfter:  user.Balance,
-	})
-
-	// Journaling
-	coaWalletID, _ := helpers.GetCOAByMappingKey("WALLET_LIABILITY")
-	var coaSourceID uint
-	if order.PaymentStatus == "paid" {
-		coaRevKey := "RETAIL_REVENUE"
-		if len(order.Items) > 0 && order.Items[0].Product.ProductType == "po" {
-			coaRevKey = "PO_REVENUE"
+// refundSourceItems builds the debit side of a refund journal. The amount is spread over the
+// paid invoices pro-rata, each reversing the account its payment was credited to
+// (deposit -> CUSTOMER_DEPOSIT, balance -> PO_REVENUE, full -> RETAIL_REVENUE, PPN -> TAX_PAYABLE).
+func refundSourceItems(order models.Order, amount float64) []models.JournalItem {
+	paidByKey := map[string]float64{}
+	var keys []string
+	totalPaid := 0.0
+	taxCOAID := helpers.GetTaxPayableCOA(config.DB)
+	for _, inv := range order.Invoices {
+		if inv.Status != "paid" && inv.Status != "paid_late" {
+			continue
 		}
-		coaSourceID, _ = helpers.GetCOAByMappingKey(coaRevKey)
-	} else {
-		coaSourceID, _ = helpers.GetCOAByMappingKey("CUSTOMER_DEPOSIT")
+		key := "RETAIL_REVENUE"
+		switch inv.Type {
+		case "deposit":
+			key = "CUSTOMER_DEPOSIT"
+		case "balance":
+			key = "PO_REVENUE"
+		}
+		net := inv.Amount
+		if inv.TaxAmount > 0 && taxCOAID != 0 {
+			net -= inv.TaxAmount
+			if _, seen := paidByKey["TAX_PAYABLE"]; !seen {
+				keys = append(keys, "TAX_PAYABLE")
+			}
+			paidByKey["TAX_PAYABLE"] += inv.TaxAmount
+		}
+		if _, seen := paidByKey[key]; !seen {
+			keys = append(keys, key)
+		}
+		paidByKey[key] += net
+		totalPaid += inv.Amount
 	}
 
-	if coaWalletID != 0 && coaSourceID != 0 {
-		helpers.PostJournalWithTX(tx, order.OrderNumber, "ORDER", fmt.Sprintf("Refund to Wallet for Order %s", order.OrderNumber), []models.JournalItem{
-			{COAID: coaSourceID, Debit: amount, Credit: 0},
-			{COAID: coaWalletID, Debit: 0, Credit: amount},
-		})
+	// Nothing recorded per invoice (e.g. manual status edits): fall back to the deposit liability
+	if totalPaid <= 0 {
+		keys = []string{"CUSTOMER_DEPOSIT"}
+		paidByKey["CUSTOMER_DEPOSIT"] = amount
+		totalPaid = amount
 	}
-	return nil
-}
 
-func (s *OrderService) sendShippingNotification(order models.Order) {
-	var carrier models.CarrierTemplate
-	trackingURL := ""
-	if err := s.DB.Where("name = ? AND active = ?", order.Carrier, true).First(&carrier).Error; err == nil {
-		trackingURL = strings.ReplaceAll(carrier.TrackingURLTemplate, "{tracking}", order.TrackingNumber)
+	var items []models.JournalItem
+	allocated := 0.0
+	for i, key := range keys {
+		coaID, _ := helpers.GetCOAByMappingKey(key)
+		if key == "TAX_PAYABLE" {
+			coaID = taxCOAID
+		}
+		if coaID == 0 {
+			return nil
+		}
+		share := roundMoney(amount * paidByKey[key] / totalPaid)
+		if i == len(keys)-1 {
+			share = roundMoney(amount - allocated)
+		}
+		allocated += share
+		items = append(items, models.JournalItem{COAID: coaID, Debit: share, Credit: 0})
 	}
-
-	helpers.NotifyUser(order.UserID, "ORDER_SHIPPED", fmt.Sprintf("Your artifacts (Order %s) have been dispatched!", order.OrderNumber), map[string]interface{}{
-		"order_id":        order.ID,
-		"tracking_number": order.TrackingNumber,
-		"carrier":         order.Carrier,
-		"tracking_url":    trackingURL,
-	})
+	return items
 }
 
 // applyVoucherUsage records voucher usage after a successful checkout
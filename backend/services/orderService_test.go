@@ -0,0 +1,82 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"forzashop/backend/models"
+)
+
+func TestAllocateLineAmounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		totals   []float64
+		discount float64
+		want     []float64
+	}{
+		{"no discount", []float64{100000, 50000}, 0, []float64{100000, 50000}},
+		{"pro-rata", []float64{100000, 50000}, 30000, []float64{80000, 40000}},
+		{"remainder on last line", []float64{100, 100, 100}, 100, []float64{66.67, 66.67, 66.66}},
+		{"discount above subtotal", []float64{10000}, 15000, []float64{0}},
+		{"zero subtotal", []float64{0, 0}, 5000, []float64{0, 0}},
+		{"no lines", nil, 5000, []float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([]models.OrderItem, len(tt.totals))
+			for i, total := range tt.totals {
+				items[i].Total = total
+			}
+			got := allocateLineAmounts(items, tt.discount)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d shares, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("share[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPlanInvoiceScheduleSkipsZeroDeposit(t *testing.T) {
+	order := models.Order{
+		DiscountAmount: 100000,
+		ShippingCost:   20000,
+		TaxInclusive:   true,
+		Items: []models.OrderItem{
+			{ProductID: 1, ProductType: "po", Quantity: 1, Total: 100000},
+		},
+	}
+	products := map[uint]models.Product{
+		1: {POConfig: []byte(`{"deposit_type":"fixed","deposit_value":10000}`)},
+	}
+
+	plan := (&OrderService{}).planInvoiceSchedule(order, products)
+	if len(plan) != 1 {
+		t.Fatalf("got %d invoices, want only the balance: %+v", len(plan), plan)
+	}
+	if plan[0].Type != "balance" || plan[0].Amount != 20000 {
+		t.Errorf("got %s %v, want balance 20000", plan[0].Type, plan[0].Amount)
+	}
+}
+
+func TestRefundOrderChecksBalanceUnderOrderLock(t *testing.T) {
+	db, stub := newStubDB(t)
+	stubRefundableOrder(stub, 180000)
+
+	_, _, err := (&OrderService{DB: db}).RefundOrder(OrderActionInput{OrderID: 1, Amount: 50000, Method: RefundMethodManual}, models.User{ID: 9}, "", "")
+	if err == nil || !strings.Contains(err.Error(), "cannot exceed total paid") {
+		t.Fatalf("expected the over-refund to be refused, got %v\n%s", err, stub.dump())
+	}
+
+	lock := stub.index(`FROM "orders"`, "FOR UPDATE")
+	if lock < 0 || !stub.inTransaction(lock) {
+		t.Fatalf("order row not locked inside the transaction:\n%s", stub.dump())
+	}
+	if sum := stub.index("SUM(amount)"); sum < lock {
+		t.Errorf("refunded total read before the order lock:\n%s", stub.dump())
+	}
+}
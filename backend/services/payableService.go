@@ -0,0 +1,504 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===============================================
+// ACCOUNTS PAYABLE (supplier bills & payments)
+// Hutang Usaha in the ledger is credited by PO receipts and non-PO bills, and debited by payments.
+// ===============================================
+
+type PayableService struct {
+	DB *gorm.DB
+}
+
+func NewPayableService() *PayableService {
+	return &PayableService{
+		DB: config.DB,
+	}
+}
+
+// billStatus derives the status from what has been paid
+func billStatus(amount, paid float64) string {
+	switch {
+	case paid <= 0:
+		return models.BillUnpaid
+	case roundMoney(amount-paid) <= 0:
+		return models.BillPaid
+	}
+	return models.BillPartial
+}
+
+// dueDateFor applies the supplier's payment terms to a bill date
+func dueDateFor(supplier models.Supplier, billDate time.Time) time.Time {
+	return startOfDay(billDate).AddDate(0, 0, supplier.TermDays)
+}
+
+// CreateBillForReceipt opens a bill for goods received on a PO. The receipt journal already credited AP.
+func (s *PayableService) CreateBillForReceipt(po models.PurchaseOrder, amount float64, reference string, userID uint) (*models.SupplierBill, error) {
+	if amount <= 0 {
+		return nil, nil
+	}
+	var supplier models.Supplier
+	if err := s.DB.First(&supplier, po.SupplierID).Error; err != nil {
+		return nil, fmt.Errorf("supplier not found")
+	}
+	number, err := helpers.NextSequenceNumber(s.DB, helpers.SeqSupplierBill)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	bill := models.SupplierBill{
+		BillNumber:      number,
+		SupplierID:      po.SupplierID,
+		PurchaseOrderID: &po.ID,
+		BillDate:        startOfDay(now),
+		DueDate:         dueDateFor(supplier, now),
+		Amount:          roundMoney(amount),
+		Status:          models.BillUnpaid,
+		Notes:           fmt.Sprintf("Penerimaan %s - PO #%s", reference, po.PONumber),
+		CreatedBy:       userID,
+	}
+	if err := s.DB.Create(&bill).Error; err != nil {
+		return nil, err
+	}
+	return &bill, nil
+}
+
+// BillInput is a manually entered bill
+type BillInput struct {
+	SupplierID        uint       `json:"supplier_id" binding:"required"`
+	PurchaseOrderID   *uint      `json:"purchase_order_id"`
+	SupplierInvoiceNo string     `json:"supplier_invoice_no"`
+	Amount            float64    `json:"amount" binding:"required,gt=0"`
+	ExpenseCOAID      *uint      `json:"expense_coa_id"` // Required without a PO
+	Notes             string     `json:"notes"`
+	BillDate          time.Time  `json:"-"`
+	DueDate           *time.Time `json:"-"` // Default: bill date + supplier terms
+}
+
+// CreateBill records a supplier bill. With a PO it tracks goods already booked to AP at receipt
+// (up to the received value); without one it books Debit expense / Credit AP.
+func (s *PayableService) CreateBill(in BillInput, userID uint) (*models.SupplierBill, error) {
+	var bill models.SupplierBill
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var supplier models.Supplier
+		if err := tx.First(&supplier, in.SupplierID).Error; err != nil {
+			return fmt.Errorf("supplier not found")
+		}
+		if in.BillDate.IsZero() {
+			in.BillDate = time.Now()
+		}
+		in.Amount = roundMoney(in.Amount)
+
+		if in.PurchaseOrderID != nil {
+			var po models.PurchaseOrder
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&po, *in.PurchaseOrderID).Error; err != nil {
+				return fmt.Errorf("purchase order not found")
+			}
+			if po.SupplierID != in.SupplierID {
+				return fmt.Errorf("purchase order %s belongs to another supplier", po.PONumber)
+			}
+			received := s.receivedValue(tx, po)
+			var billed float64
+			tx.Model(&models.SupplierBill{}).Where("purchase_order_id = ?", po.ID).Select("COALESCE(SUM(amount), 0)").Scan(&billed)
+			if roundMoney(billed+in.Amount) > roundMoney(received) {
+				return fmt.Errorf("bills for %s would exceed the received value (Rp %.0f, already billed Rp %.0f)", po.PONumber, received, billed)
+			}
+			in.ExpenseCOAID = nil
+		} else if in.ExpenseCOAID == nil {
+			return fmt.Errorf("expense_coa_id is required for a bill without a purchase order")
+		}
+
+		number, err := helpers.NextSequenceNumber(tx, helpers.SeqSupplierBill)
+		if err != nil {
+			return err
+		}
+		bill = models.SupplierBill{
+			BillNumber:        number,
+			SupplierID:        in.SupplierID,
+			PurchaseOrderID:   in.PurchaseOrderID,
+			SupplierInvoiceNo: in.SupplierInvoiceNo,
+			BillDate:          startOfDay(in.BillDate),
+			DueDate:           dueDateFor(supplier, in.BillDate),
+			Amount:            in.Amount,
+			Status:            models.BillUnpaid,
+			ExpenseCOAID:      in.ExpenseCOAID,
+			Notes:             in.Notes,
+			CreatedBy:         userID,
+		}
+		if in.DueDate != nil {
+			bill.DueDate = startOfDay(*in.DueDate)
+		}
+		if bill.DueDate.Before(bill.BillDate) {
+			return fmt.Errorf("due_date is before the bill date")
+		}
+		if err := tx.Create(&bill).Error; err != nil {
+			return err
+		}
+
+		if bill.ExpenseCOAID == nil {
+			return nil
+		}
+		apID, err := helpers.GetPayableCOA()
+		if err != nil {
+			return fmt.Errorf("accounts payable COA (2001) not found")
+		}
+		_, err = helpers.PostJournal(tx, helpers.JournalPosting{
+			Date:          bill.BillDate,
+			ReferenceID:   bill.BillNumber,
+			ReferenceType: "SUPPLIER_BILL",
+			Description:   fmt.Sprintf("Tagihan %s - %s", supplier.Name, bill.BillNumber),
+			Items: []models.JournalItem{
+				{COAID: *bill.ExpenseCOAID, Debit: bill.Amount, Credit: 0},
+				{COAID: apID, Debit: 0, Credit: bill.Amount},
+			},
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "finance", "CREATE", bill.ID, "Created supplier bill "+bill.BillNumber)
+	return &bill, nil
+}
+
+// receivedValue is the value of the goods received on a PO so far (POs received in one go before receipts existed count in full)
+func (s *PayableService) receivedValue(tx *gorm.DB, po models.PurchaseOrder) float64 {
+	var value float64
+	tx.Model(&models.PurchaseReceipt{}).Where("purchase_order_id = ?", po.ID).
+		Select("COALESCE(SUM(goods_value), 0)").Scan(&value)
+	if value == 0 && po.Status == "received" {
+		return po.TotalAmount
+	}
+	return value
+}
+
+// PaymentInput records a payment against a bill
+type PaymentInput struct {
+	Amount    float64   `json:"amount" binding:"required,gt=0"`
+	BankCOAID uint      `json:"bank_coa_id"` // Default: primary bank
+	Method    string    `json:"method"`
+	Reference string    `json:"reference"`
+	Notes     string    `json:"notes"`
+	Date      time.Time `json:"-"`
+}
+
+// RecordPayment pays (part of) a bill: Debit Hutang Usaha, Credit bank
+func (s *PayableService) RecordPayment(billID uint, in PaymentInput, userID uint) (*models.SupplierPayment, error) {
+	var payment models.SupplierPayment
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var bill models.SupplierBill
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Supplier").First(&bill, billID).Error; err != nil {
+			return fmt.Errorf("bill not found")
+		}
+		amount := roundMoney(in.Amount)
+		if amount > roundMoney(bill.Outstanding()) {
+			return fmt.Errorf("payment Rp %.0f exceeds the outstanding Rp %.0f", amount, bill.Outstanding())
+		}
+		if in.Date.IsZero() {
+			in.Date = time.Now()
+		}
+		if startOfDay(in.Date).Before(bill.BillDate) {
+			return fmt.Errorf("payment date is before the bill date")
+		}
+
+		bankID := in.BankCOAID
+		if bankID == 0 {
+			id, err := helpers.GetPrimaryBankCOA(tx)
+			if err != nil {
+				return fmt.Errorf("no Bank/Cash mapping found")
+			}
+			bankID = id
+		} else {
+			var coa models.COA
+			if err := tx.First(&coa, bankID).Error; err != nil || coa.Type != "ASSET" || !coa.CanPost {
+				return fmt.Errorf("bank_coa_id must be a postable asset account")
+			}
+		}
+		apID, err := helpers.GetPayableCOA()
+		if err != nil {
+			return fmt.Errorf("accounts payable COA (2001) not found")
+		}
+
+		number, err := helpers.NextSequenceNumber(tx, helpers.SeqSupplierPay)
+		if err != nil {
+			return err
+		}
+		entry, err := helpers.PostJournal(tx, helpers.JournalPosting{
+			Date:          in.Date,
+			ReferenceID:   number,
+			ReferenceType: "SUPPLIER_PAYMENT",
+			Description:   fmt.Sprintf("Pembayaran %s - %s", bill.Supplier.Name, bill.BillNumber),
+			Items: []models.JournalItem{
+				{COAID: apID, Debit: amount, Credit: 0},
+				{COAID: bankID, Debit: 0, Credit: amount},
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		payment = models.SupplierPayment{
+			PaymentNumber:  number,
+			SupplierBillID: bill.ID,
+			SupplierID:     bill.SupplierID,
+			Date:           in.Date,
+			Amount:         amount,
+			BankCOAID:      bankID,
+			Method:         in.Method,
+			Reference:      in.Reference,
+			Notes:          in.Notes,
+			JournalEntryID: entry.ID,
+			CreatedBy:      userID,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+
+		bill.PaidAmount = roundMoney(bill.PaidAmount + amount)
+		return tx.Model(&bill).Updates(map[string]interface{}{
+			"paid_amount": bill.PaidAmount,
+			"status":      billStatus(bill.Amount, bill.PaidAmount),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "finance", "CREATE", payment.ID, fmt.Sprintf("Paid supplier bill %d: %s Rp %.0f", billID, payment.PaymentNumber, payment.Amount))
+	return &payment, nil
+}
+
+// BillFilter narrows ListBills
+type BillFilter struct {
+	SupplierID uint
+	Status     string // unpaid, partial, paid, open (= unpaid + partial)
+	Overdue    bool
+	Page       int
+	Limit      int
+}
+
+// ListBills returns a page of bills, oldest due first
+func (s *PayableService) ListBills(f BillFilter) ([]models.SupplierBill, int64, error) {
+	query := s.DB.Model(&models.SupplierBill{})
+	if f.SupplierID != 0 {
+		query = query.Where("supplier_id = ?", f.SupplierID)
+	}
+	switch f.Status {
+	case "":
+	case "open":
+		query = query.Where("status IN ?", []string{models.BillUnpaid, models.BillPartial})
+	default:
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.Overdue {
+		query = query.Where("status <> ? AND due_date < ?", models.BillPaid, startOfDay(time.Now()))
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var bills []models.SupplierBill
+	err := query.Preload("Supplier").Preload("PurchaseOrder").
+		Order("due_date asc, id asc").Limit(f.Limit).Offset((f.Page - 1) * f.Limit).Find(&bills).Error
+	return bills, total, err
+}
+
+// GetBill returns a bill with its payments
+func (s *PayableService) GetBill(id uint) (*models.SupplierBill, error) {
+	var bill models.SupplierBill
+	err := s.DB.Preload("Supplier").Preload("PurchaseOrder").
+		Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("date asc, id asc") }).
+		First(&bill, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("bill not found")
+	}
+	return &bill, nil
+}
+
+// ---------------------------------------------------------
+// AGING
+// ---------------------------------------------------------
+
+// AgingBuckets splits outstanding amounts by days past due
+type AgingBuckets struct {
+	Current float64 `json:"current"` // Not yet due
+	Days30  float64 `json:"days_1_30"`
+	Days60  float64 `json:"days_31_60"`
+	Days90  float64 `json:"days_61_90"`
+	Over90  float64 `json:"over_90"`
+	Total   float64 `json:"total"`
+}
+
+func (b *AgingBuckets) add(daysPastDue int, amount float64) {
+	switch {
+	case daysPastDue <= 0:
+		b.Current += amount
+	case daysPastDue <= 30:
+		b.Days30 += amount
+	case daysPastDue <= 60:
+		b.Days60 += amount
+	case daysPastDue <= 90:
+		b.Days90 += amount
+	default:
+		b.Over90 += amount
+	}
+	b.Total += amount
+}
+
+func (b *AgingBuckets) round() {
+	b.Current, b.Days30, b.Days60 = roundMoney(b.Current), roundMoney(b.Days30), roundMoney(b.Days60)
+	b.Days90, b.Over90, b.Total = roundMoney(b.Days90), roundMoney(b.Over90), roundMoney(b.Total)
+}
+
+type SupplierAging struct {
+	SupplierID   uint   `json:"supplier_id"`
+	SupplierName string `json:"supplier_name"`
+	Bills        int    `json:"bills"`
+	AgingBuckets
+}
+
+type AgingReport struct {
+	AsOf      time.Time       `json:"as_of"`
+	Suppliers []SupplierAging `json:"suppliers"`
+	Totals    AgingBuckets    `json:"totals"`
+}
+
+// Aging reports what was owed on asOf, counting only bills and payments dated up to then
+func (s *PayableService) Aging(asOf time.Time) *AgingReport {
+	asOf = startOfDay(asOf)
+	var rows []struct {
+		SupplierID   uint
+		SupplierName string
+		DueDate      time.Time
+		Amount       float64
+		Paid         float64
+	}
+	s.DB.Table("supplier_bills b").
+		Select(`b.supplier_id, s.name AS supplier_name, b.due_date, b.amount,
+			COALESCE((SELECT SUM(p.amount) FROM supplier_payments p WHERE p.supplier_bill_id = b.id AND p.date < ?), 0) AS paid`, asOf.AddDate(0, 0, 1)).
+		Joins("JOIN suppliers s ON s.id = b.supplier_id").
+		Where("b.bill_date < ?", asOf.AddDate(0, 0, 1)).
+		Scan(&rows)
+
+	bySupplier := map[uint]*SupplierAging{}
+	report := &AgingReport{AsOf: asOf, Suppliers: []SupplierAging{}}
+	for _, r := range rows {
+		outstanding := roundMoney(r.Amount - r.Paid)
+		if outstanding <= 0 {
+			continue
+		}
+		agg, ok := bySupplier[r.SupplierID]
+		if !ok {
+			agg = &SupplierAging{SupplierID: r.SupplierID, SupplierName: r.SupplierName}
+			bySupplier[r.SupplierID] = agg
+		}
+		daysPastDue := int(asOf.Sub(startOfDay(r.DueDate)).Hours() / 24)
+		agg.Bills++
+		agg.add(daysPastDue, outstanding)
+		report.Totals.add(daysPastDue, outstanding)
+	}
+
+	for _, agg := range bySupplier {
+		agg.round()
+		report.Suppliers = append(report.Suppliers, *agg)
+	}
+	sort.Slice(report.Suppliers, func(i, j int) bool {
+		return report.Suppliers[i].Total > report.Suppliers[j].Total
+	})
+	report.Totals.round()
+	return report
+}
+
+// ---------------------------------------------------------
+// SUPPLIER STATEMENT
+// ---------------------------------------------------------
+
+type StatementLine struct {
+	Date        time.Time `json:"date"`
+	Type        string    `json:"type"` // bill, payment
+	Number      string    `json:"number"`
+	Reference   string    `json:"reference"` // Supplier invoice no / transfer ref
+	Description string    `json:"description"`
+	Debit       float64   `json:"debit"`  // Payments: lower what we owe
+	Credit      float64   `json:"credit"` // Bills: raise what we owe
+	Balance     float64   `json:"balance"`
+}
+
+type SupplierStatement struct {
+	Supplier       models.Supplier `json:"supplier"`
+	Period         ReportPeriod    `json:"period"`
+	OpeningBalance float64         `json:"opening_balance"`
+	Lines          []StatementLine `json:"lines"`
+	TotalBilled    float64         `json:"total_billed"`
+	TotalPaid      float64         `json:"total_paid"`
+	ClosingBalance float64         `json:"closing_balance"`
+}
+
+// Statement lists a supplier's bills and payments in the period with a running balance
+func (s *PayableService) Statement(supplierID uint, p ReportPeriod) (*SupplierStatement, error) {
+	if p.From == nil {
+		return nil, fmt.Errorf("from_date is required")
+	}
+	var supplier models.Supplier
+	if err := s.DB.First(&supplier, supplierID).Error; err != nil {
+		return nil, fmt.Errorf("supplier not found")
+	}
+	from, until := *p.From, p.To.AddDate(0, 0, 1)
+
+	var billedBefore, paidBefore float64
+	s.DB.Model(&models.SupplierBill{}).Where("supplier_id = ? AND bill_date < ?", supplierID, from).
+		Select("COALESCE(SUM(amount), 0)").Scan(&billedBefore)
+	s.DB.Model(&models.SupplierPayment{}).Where("supplier_id = ? AND date < ?", supplierID, from).
+		Select("COALESCE(SUM(amount), 0)").Scan(&paidBefore)
+
+	var bills []models.SupplierBill
+	s.DB.Preload("PurchaseOrder").Where("supplier_id = ? AND bill_date >= ? AND bill_date < ?", supplierID, from, until).Find(&bills)
+	var payments []models.SupplierPayment
+	s.DB.Where("supplier_id = ? AND date >= ? AND date < ?", supplierID, from, until).Find(&payments)
+
+	lines := make([]StatementLine, 0, len(bills)+len(payments))
+	for _, b := range bills {
+		desc := b.Notes
+		if b.PurchaseOrder != nil {
+			desc = "PO " + b.PurchaseOrder.PONumber
+		}
+		lines = append(lines, StatementLine{Date: b.BillDate, Type: "bill", Number: b.BillNumber, Reference: b.SupplierInvoiceNo, Description: desc, Credit: b.Amount})
+	}
+	for _, pay := range payments {
+		lines = append(lines, StatementLine{Date: pay.Date, Type: "payment", Number: pay.PaymentNumber, Reference: pay.Reference, Description: pay.Notes, Debit: pay.Amount})
+	}
+	// Same day: bills before the payments settling them
+	sort.SliceStable(lines, func(i, j int) bool {
+		di, dj := startOfDay(lines[i].Date), startOfDay(lines[j].Date)
+		if !di.Equal(dj) {
+			return di.Before(dj)
+		}
+		return lines[i].Type == "bill" && lines[j].Type == "payment"
+	})
+
+	st := &SupplierStatement{Supplier: supplier, Period: p, OpeningBalance: roundMoney(billedBefore - paidBefore), Lines: lines}
+	balance := st.OpeningBalance
+	for i := range st.Lines {
+		balance = roundMoney(balance + st.Lines[i].Credit - st.Lines[i].Debit)
+		st.Lines[i].Balance = balance
+		st.TotalBilled += st.Lines[i].Credit
+		st.TotalPaid += st.Lines[i].Debit
+	}
+	st.TotalBilled, st.TotalPaid = roundMoney(st.TotalBilled), roundMoney(st.TotalPaid)
+	st.ClosingBalance = balance
+	return st, nil
+}
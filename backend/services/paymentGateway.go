@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+)
+
+// ===============================================
+// PAYMENT GATEWAY ABSTRACTION
+// ===============================================
+
+// Normalized charge statuses every gateway maps its own codes to
+const (
+	ChargeStatusPending = "pending"
+	ChargeStatusPaid    = "paid"
+	ChargeStatusFailed  = "failed"
+)
+
+// ErrRefundNotSupported is returned by gateways that cannot refund through their API
+var ErrRefundNotSupported = errors.New("refund is not supported by this payment gateway")
+
+// ChargeRequest asks a gateway to open a charge for one invoice
+type ChargeRequest struct {
+	Order    models.Order
+	Invoice  models.Invoice
+	User     models.User
+	Method   string // VA, QRIS, CC (order payment method codes); empty uses Order.PaymentMethod
+	SaveCard bool
+	ClientIP string
+}
+
+// ChargeResult is the gateway's view of a charge, from a status inquiry or a verified callback
+type ChargeResult struct {
+	MerchantRefNo string
+	GatewayRef    string
+	InvoiceNumber string // Fallback lookup when the charge was not opened through a PaymentTransaction
+	Status        string // pending, paid, failed
+	RawStatus     string // Status code as sent by the gateway
+	Amount        float64
+	CardToken     string
+	MaskedCard    string
+	CardType      string
+	Raw           map[string]interface{}
+}
+
+// RefundRequest asks a gateway to refund (part of) a settled charge
+type RefundRequest struct {
+	Transaction models.PaymentTransaction
+	Amount      float64
+	Reason      string
+	Reference   string // Our own refund reference, sent as idempotency key where supported
+}
+
+// RefundResult is the gateway's answer to a refund request
+type RefundResult struct {
+	GatewayRef string
+	Status     string // models.RefundStatusProcessing, RefundStatusSucceeded or RefundStatusFailed
+	Message    string
+	Raw        map[string]interface{}
+}
+
+// PaymentMethodInfo describes a payment method a gateway offers at checkout
+type PaymentMethodInfo struct {
+	Code string `json:"code"` // VA, QRIS, CC
+	Name string `json:"name"`
+	Type string `json:"type"` // va, qris, card
+}
+
+// PaymentGateway is implemented by every payment provider. Controllers only talk to this
+// interface, so adding a provider means implementing it and registering it in init().
+type PaymentGateway interface {
+	Name() string
+	CreateCharge(req ChargeRequest) (*helpers.PaymentData, error)
+	QueryStatus(payTx models.PaymentTransaction) (*ChargeResult, error)
+	Refund(req RefundRequest) (*RefundResult, error)
+	VerifyCallback(header http.Header, body []byte) (*ChargeResult, error)
+	ListMethods() []PaymentMethodInfo
+}
+
+// RefundCapability is implemented by gateways that cannot always refund through their API.
+// Gateways without it are assumed to refund every settled charge.
+type RefundCapability interface {
+	SupportsRefund() bool
+}
+
+// gatewaySupportsRefund reports whether charges of the named gateway can be refunded by API
+func gatewaySupportsRefund(name string) bool {
+	g, err := GetPaymentGateway(name)
+	if err != nil {
+		return false
+	}
+	if rc, ok := g.(RefundCapability); ok {
+		return rc.SupportsRefund()
+	}
+	return true
+}
+
+// CardSubmitter is implemented by gateways with a two-step card flow (session token, then card data)
+type CardSubmitter interface {
+	SubmitCard(merchantRefNo, sessionToken string, invoice models.Invoice, user models.User, card map[string]string, clientIP string) (*helpers.PaymentData, error)
+}
+
+var paymentGateways = map[string]PaymentGateway{}
+
+// RegisterPaymentGateway makes a gateway available by its name, callbacks included
+func RegisterPaymentGateway(g PaymentGateway) {
+	paymentGateways[g.Name()] = g
+	RegisterWebhookHandler(g.Name(), paymentWebhookHandler{gateway: g.Name()})
+}
+
+// PaymentGatewayNames lists the registered gateways, e.g. to mount their webhook routes
+func PaymentGatewayNames() []string {
+	names := make([]string, 0, len(paymentGateways))
+	for name := range paymentGateways {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetPaymentGateway returns a registered gateway by name
+func GetPaymentGateway(name string) (PaymentGateway, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	// Transactions created by the old direct submit flow
+	if name == "prismalink_direct" || name == "" {
+		name = "prismalink"
+	}
+	g, ok := paymentGateways[name]
+	if !ok {
+		return nil, fmt.Errorf("payment gateway %q is not available", name)
+	}
+	if name == "sandbox" && !SandboxGatewayEnabled() {
+		return nil, fmt.Errorf("sandbox payment gateway is disabled")
+	}
+	return g, nil
+}
+
+// ActivePaymentGateway is the gateway new charges are opened on (setting payment_gateway)
+func ActivePaymentGateway() (PaymentGateway, error) {
+	fallback := os.Getenv("PAYMENT_GATEWAY")
+	if fallback == "" {
+		fallback = "prismalink"
+	}
+	return GetPaymentGateway(helpers.GetSetting("payment_gateway", fallback))
+}
+
+// GatewayForTransaction returns the gateway a payment transaction was opened on
+func GatewayForTransaction(payTx models.PaymentTransaction) (PaymentGateway, error) {
+	return GetPaymentGateway(payTx.Gateway)
+}
+
+// CreatePaymentCharge opens a charge for the invoice on the active gateway
+func CreatePaymentCharge(order models.Order, invoice models.Invoice, user models.User, clientIP string) (*helpers.PaymentData, error) {
+	gateway, err := ActivePaymentGateway()
+	if err != nil {
+		return nil, err
+	}
+	return gateway.CreateCharge(ChargeRequest{
+		Order:    order,
+		Invoice:  invoice,
+		User:     user,
+		ClientIP: clientIP,
+	})
+}
+
+// CreatePaymentLink opens a charge and returns it as a single link for emails and legacy callers
+func CreatePaymentLink(order models.Order, invoice models.Invoice, user models.User, clientIP string) (string, error) {
+	paymentData, err := CreatePaymentCharge(order, invoice, user, clientIP)
+	if err != nil {
+		return "", err
+	}
+	return helpers.PaymentLinkFromData(paymentData)
+}
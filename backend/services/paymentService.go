@@ -1,7 +1,6 @@
 package services
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"strings"
@@ -24,11 +23,22 @@ func NewPaymentService() *PaymentService {
 	}
 }
 
+// Settlement outcomes of SettleCharge, echoed back to gateways in the callback acknowledgement
+const (
+	SettleInvoiceNotFound = "invoice_not_found"
+	SettleAlreadyPaid     = "already_paid"
+	SettlePaid            = "paid"
+	SettlePaidLate        = "paid_late"
+	SettleFailed          = "failed"
+	SettlePending         = "pending"
+	SettleUnderpaid       = "underpaid" // Charge brought in less than the invoice is due, invoice left open
+)
+
 // PaymentCodeInput for SubmitTransaction
 type PaymentCodeInput struct {
 	InvoiceID       uint
 	UserID          uint
-	Method          string
+	Method          string // va, qris, cc
 	Bank            string
 	InstallmentTerm int
 	CardToken       string
@@ -36,8 +46,8 @@ type PaymentCodeInput struct {
 	IPAddress       string
 }
 
-// SubmitTransaction handles direct API calls to Prismalink
-func (s *PaymentService) SubmitTransaction(input PaymentCodeInput) (map[string]interface{}, error) {
+// SubmitTransaction opens a charge for an invoice on the active payment gateway
+func (s *PaymentService) SubmitTransaction(input PaymentCodeInput) (*helpers.PaymentData, error) {
 	var invoice models.Invoice
 	if err := s.DB.Preload("Order.Items.Product").Where("id = ?", input.InvoiceID).First(&invoice).Error; err != nil {
 		return nil, fmt.Errorf("invoice not found")
@@ -48,288 +58,415 @@ func (s *PaymentService) SubmitTransaction(input PaymentCodeInput) (map[string]i
 		return nil, fmt.Errorf("user not found")
 	}
 
-	// 1. Authorization & Validation
-	if invoice.UserID != 0 && invoice.UserID != user.ID {
-		return nil, fmt.Errorf("unauthorized")
-	} else if invoice.OrderID != nil && invoice.Order.UserID != user.ID {
+	// Ownership: invoice user OR order user
+	if !(invoice.UserID != 0 && invoice.UserID == user.ID) && !(invoice.OrderID != nil && invoice.Order.UserID == user.ID) {
 		return nil, fmt.Errorf("unauthorized")
 	}
-
-	if invoice.Status != "unpaid" && invoice.Status != "pending_arrival" {
-		return nil, fmt.Errorf("invoice already paid or not payable")
-	}
-
-	// 2. Configuration
-	cfg := helpers.GetPrismalinkConfig()
-	now := time.Now()
-	timestamp := now.Format("2006-01-02 15:04:05.000 -0700")
-	refNo := s.generateRefNo(invoice.InvoiceNumber, now)
-	_ = refNo // Keep for potential future use or delete if sure
-
-	formattedPhone := helpers.FormatPrismalinkPhone(user.Phone)
-
-	// 3. Build Body
-	products := s.buildProductDetails(invoice)
-	productDetailsJSON, _ := json.Marshal(products)
-
-	paymentMethod, integrationType, bankCode := s.mapPaymentMethod(input.Method, input.Bank, input.CardToken != "")
-
-	// Use the same unique ref logic as helpers/payment.go
-	uniqueRefNo := fmt.Sprintf("%s-%s", invoice.InvoiceNumber, now.Format("150405"))
-	if len(uniqueRefNo) > 24 {
-		uniqueRefNo = uniqueRefNo[:24]
-	}
-
-	requestBody := map[string]interface{}{
-		"merchant_key_id":        cfg.KeyID,
-		"merchant_id":            cfg.MerchantID,
-		"merchant_ref_no":        uniqueRefNo,
-		"backend_callback_url":   cfg.CallbackURL,
-		"frontend_callback_url":  cfg.ReturnURL,
-		"transaction_date_time":  timestamp,
-		"transmission_date_time": timestamp,
-		"transaction_currency":   "IDR",
-		"transaction_amount":     int64(invoice.Amount),
-		"payment_method":         paymentMethod,
-		"integration_type":       integrationType,
-		"bank_id":                bankCode,
-		"user_id":                fmt.Sprintf("%d", user.ID),
-		"user_name":              s.getValidName(&user),
-		"user_email":             s.getValidEmail(&user),
-		"user_phone_number":      formattedPhone,
-		"user_device_id":         "WEB",
-		"user_ip_address":        s.getValidIP(input.IPAddress),
-		"invoice_number":         invoice.InvoiceNumber,
-		"external_id":            fmt.Sprintf("%d", invoice.ID),
-		"product_details":        string(productDetailsJSON),
-	}
-
-	// 4. Create Pending Transaction
-	var orderID uint
-	if invoice.OrderID != nil {
-		orderID = *invoice.OrderID
+	if invoice.Status == "paid" || invoice.Status == "paid_late" {
+		return nil, fmt.Errorf("invoice already paid")
 	}
 
-	paymentTx := models.PaymentTransaction{
-		OrderID:       orderID,
-		InvoiceID:     invoice.ID,
-		MerchantRefNo: uniqueRefNo,
-		Gateway:       "prismalink_direct",
-		Type:          invoice.Type,
-		Amount:        invoice.Amount,
-		Status:        "pending",
-		PaymentMethod: fmt.Sprintf("%s_%s", input.Method, input.Bank),
-		SaveCard:      input.SaveCard,
+	gateway, err := ActivePaymentGateway()
+	if err != nil {
+		return nil, err
 	}
-	s.DB.Create(&paymentTx)
 
-	// DEBUG LOG
-	log.Printf("🔥 SUBMITTING DIRECT TRX: Method=%s, Ref=%s, Amt=%.0f", input.Method, uniqueRefNo, invoice.Amount)
+	method := "VA"
+	switch strings.ToLower(input.Method) {
+	case "qris":
+		method = "QRIS"
+	case "cc":
+		method = "CC"
+	}
 
-	// 5. Submit to Gateway
-	result, err := helpers.SubmitPrismalinkDirect(requestBody)
-	if err != nil {
-		return nil, err
+	// Persist payment method so inquiry/webhook can reference it later
+	if invoice.OrderID != nil {
+		s.DB.Model(&models.Order{}).Where("id = ?", *invoice.OrderID).Update("payment_method", method)
+		invoice.Order.PaymentMethod = method
 	}
 
-	// 6. Update Reference
-	plinkRefNo, _ := result["plink_ref_no"].(string)
-	s.DB.Model(&paymentTx).Update("external_id", plinkRefNo)
+	log.Printf("💳 Opening %s charge: Method=%s, Invoice=%s, Amt=%.0f", gateway.Name(), method, invoice.InvoiceNumber, invoice.Amount)
 
-	return result, nil
+	return gateway.CreateCharge(ChargeRequest{
+		Order:    invoice.Order,
+		Invoice:  invoice,
+		User:     user,
+		Method:   method,
+		SaveCard: input.SaveCard,
+		ClientIP: input.IPAddress,
+	})
 }
 
-// CheckAndSyncStatus polls Prismalink for specific order and updates status if changed (SYNC)
+// CheckAndSyncStatus asks the gateway about the latest invoice of an unpaid order and settles it if paid (SYNC)
 func (s *PaymentService) CheckAndSyncStatus(order *models.Order) error {
-	if (order.PaymentMethod == "QRIS" || strings.Contains(order.PaymentMethod, "va")) && (order.PaymentStatus == "unpaid" || order.PaymentStatus == "pending") {
-		var lastInvoice models.Invoice
-		if err := s.DB.Where("order_id = ?", order.ID).Order("created_at desc").First(&lastInvoice).Error; err != nil {
-			return nil
-		}
+	if order.PaymentStatus != "unpaid" && order.PaymentStatus != "pending" {
+		return nil
+	}
 
-		var payTx models.PaymentTransaction
-		if err := s.DB.Where("invoice_id = ?", lastInvoice.ID).First(&payTx).Error; err != nil || payTx.MerchantRefNo == "" {
-			return nil
-		}
+	var lastInvoice models.Invoice
+	if err := s.DB.Where("order_id = ?", order.ID).Order("created_at desc").First(&lastInvoice).Error; err != nil {
+		return nil
+	}
 
-		channelType := "QR"
-		if strings.Contains(order.PaymentMethod, "va") {
-			channelType = "VA"
+	settled, err := s.SyncInvoice(lastInvoice.ID, "Auto-Sync")
+	if err != nil || !settled {
+		return err
+	}
+	return s.DB.First(order, order.ID).Error
+}
+
+// SyncInvoice queries the pending charges of an invoice upstream (newest first) and settles the first paid one.
+// This is the reliability layer for missed or delayed callbacks.
+func (s *PaymentService) SyncInvoice(invoiceID uint, via string) (bool, error) {
+	var pendingTxs []models.PaymentTransaction
+	s.DB.Where("invoice_id = ? AND status = ?", invoiceID, "pending").Order("created_at desc").Find(&pendingTxs)
+
+	for _, payTx := range pendingTxs {
+		gateway, err := GatewayForTransaction(payTx)
+		if err != nil {
+			continue
+		}
+		result, err := gateway.QueryStatus(payTx)
+		if err != nil {
+			log.Printf("⚠️ Inquiry skipped for %s: %v", payTx.MerchantRefNo, err)
+			continue
 		}
+		log.Printf("🔍 Inquiry Result for %s: %s (%s)", payTx.MerchantRefNo, result.Status, result.RawStatus)
 
-		res, err := helpers.CheckPrismalinkStatus(payTx.MerchantRefNo, payTx.GatewayTxID, lastInvoice.Amount, channelType)
-		if err == nil {
-			upstreamStatus := s.extractStatus(res)
-			if s.isPaid(upstreamStatus) {
-				return s.finalizePayment(&lastInvoice, &payTx, order)
-			}
+		if result.Status != ChargeStatusPaid {
+			continue
+		}
+		outcome, err := s.SettleCharge(*result, via)
+		if err != nil {
+			return false, err
 		}
+		return outcome == SettlePaid || outcome == SettlePaidLate || outcome == SettleAlreadyPaid, nil
 	}
-	return nil
+	return false, nil
 }
 
-// Internal Helpers
+// paymentTxTransitions: callbacks and inquiries arrive late or out of order, a charge never moves backwards
+var paymentTxTransitions = map[string][]string{
+	"pending":              {"success", "failed", "expired", "underpaid"},
+	"pending_verification": {"success", "failed", "underpaid"}, // Manual transfer with uploaded proof
+	"failed":               {"success", "underpaid"},           // Paid after all (late settlement)
+	"expired":              {"success", "underpaid"},
+	"success":              {"refunded"},
+}
 
-func (s *PaymentService) generateRefNo(invoiceNum string, t time.Time) string {
-	refNo := strings.ReplaceAll(invoiceNum, "-", "")
-	refNo = fmt.Sprintf("%s%d", refNo, t.Unix()%10000)
-	if len(refNo) > 24 {
-		return refNo[:24]
+// transitionPaymentTx moves a payment transaction to a new status if the status machine allows it,
+// guarded on its current status so a concurrent callback cannot undo the move
+func transitionPaymentTx(tx *gorm.DB, payTx models.PaymentTransaction, to string, extra map[string]interface{}) bool {
+	allowed := false
+	for _, next := range paymentTxTransitions[payTx.Status] {
+		if next == to {
+			allowed = true
+		}
+	}
+	if !allowed {
+		if payTx.Status != to {
+			log.Printf("⚠️ Ignored payment %s transition %s -> %s", payTx.MerchantRefNo, payTx.Status, to)
+		}
+		return false
 	}
-	return refNo
+	updates := map[string]interface{}{"status": to}
+	for k, v := range extra {
+		updates[k] = v
+	}
+	res := tx.Model(&models.PaymentTransaction{}).Where("id = ? AND status = ?", payTx.ID, payTx.Status).Updates(updates)
+	return res.Error == nil && res.RowsAffected > 0
 }
 
-func (s *PaymentService) buildProductDetails(invoice models.Invoice) []map[string]interface{} {
-	var products []map[string]interface{}
-
-	// Create a single consolidated item for the entire invoice amount
-	// This prevents PL001 errors caused by mismatch between sum(items) and transaction_amount
-	// (e.g. due to shipping costs, discounts, or wallet deductions).
-	itemTitle := "Pembayaran " + invoice.InvoiceNumber
-	if invoice.Type == "topup" {
-		itemTitle = "Wallet Top Up " + invoice.InvoiceNumber
+// SettleCharge applies a gateway result (verified callback or inquiry) to the payment transaction,
+// invoice, order and wallet. It is idempotent: a charge that is already settled is reported, not re-applied.
+// via names the channel for order logs (e.g. "prismalink callback", "Active Inquiry").
+func (s *PaymentService) SettleCharge(result ChargeResult, via string) (string, error) {
+	if result.Status == ChargeStatusPending {
+		return SettlePending, nil
 	}
 
-	products = append(products, map[string]interface{}{
-		"item_code":  1,
-		"item_title": itemTitle,
-		"quantity":   1,
-		"total":      int64(invoice.Amount),
-		"currency":   "IDR",
-	})
-
-	return products
-}
-
-func (s *PaymentService) getValidName(u *models.User) string {
-	if u.FullName != "" {
-		return u.FullName
+	// Find the charge we opened, then its invoice
+	var payTx models.PaymentTransaction
+	query := s.DB.Where("1 = 0")
+	if result.MerchantRefNo != "" {
+		query = query.Or("merchant_ref_no = ?", result.MerchantRefNo)
 	}
-	if u.Username != "" {
-		return u.Username
+	if result.GatewayRef != "" {
+		query = query.Or("gateway_tx_id = ? OR external_id = ?", result.GatewayRef, result.GatewayRef)
 	}
-	return "Customer Forza"
-}
+	hasPayTx := query.First(&payTx).Error == nil
 
-func (s *PaymentService) getValidEmail(u *models.User) string {
-	if u.Email != "" {
-		return u.Email
+	var invoice models.Invoice
+	invoiceFound := false
+	if hasPayTx {
+		invoiceFound = s.DB.Preload("Order").First(&invoice, payTx.InvoiceID).Error == nil
 	}
-	return "customer@warungforza.com"
-}
-
-func (s *PaymentService) getValidIP(ip string) string {
-	if ip == "::1" || ip == "" {
-		return "127.0.0.1"
+	if !invoiceFound && result.InvoiceNumber != "" {
+		invoiceFound = s.DB.Preload("Order").Where("invoice_number = ?", result.InvoiceNumber).First(&invoice).Error == nil
+	}
+	if !invoiceFound {
+		log.Printf("❌ Core Invoice not found for merchant_ref_no=%s, gateway_ref=%s", result.MerchantRefNo, result.GatewayRef)
+		return SettleInvoiceNotFound, nil
 	}
-	return ip
-}
 
-func (s *PaymentService) mapPaymentMethod(method, bank string, hasToken bool) (pm, it, bc string) {
-	switch method {
-	case "va":
-		pm = "VA"
-		it = "01" // Hosted payment page for VA to let user select correctly configured banks
-		bc = ""
-	case "qris":
-		pm = "QR"
-		it = "01" // Hosted payment page for guaranteed success rate
-		bc = ""
-	case "cc":
-		pm = "CC"
-		bc = ""
-		if hasToken {
-			it = "02"
-		} else {
-			it = "01"
+	// Idempotency: skip if already paid
+	if invoice.Status == "paid" || invoice.Status == "paid_late" {
+		if hasPayTx && result.Status == ChargeStatusPaid {
+			transitionPaymentTx(s.DB, payTx, "success", nil)
 		}
+		return SettleAlreadyPaid, nil
 	}
-	return
-}
 
-func (s *PaymentService) extractStatus(res map[string]interface{}) string {
-	// Prismalink inquiry returns "transaction_status" (NOT "payment_status")
-	status, _ := res["transaction_status"].(string)
-	if status == "" {
-		status, _ = res["payment_status"].(string)
+	ref := result.GatewayRef
+	if ref == "" {
+		ref = result.MerchantRefNo
 	}
-	if status == "" {
-		status, _ = res["status"].(string)
-	}
-	if data, ok := res["data"].(map[string]interface{}); ok {
-		if ds, ok := data["transaction_status"].(string); ok && ds != "" {
-			status = ds
-		} else if ds, ok := data["payment_status"].(string); ok && ds != "" {
-			status = ds
-		}
+
+	if result.Status == ChargeStatusFailed {
+		return SettleFailed, s.DB.Transaction(func(tx *gorm.DB) error {
+			// A stale failure for a charge that already moved on (paid, refunded) changes nothing
+			if hasPayTx && !transitionPaymentTx(tx, payTx, "failed", nil) {
+				return nil
+			}
+			// Another attempt (e.g. a different method) may still be open for this invoice
+			var openAttempts int64
+			tx.Model(&models.PaymentTransaction{}).Where("invoice_id = ? AND status = ?", invoice.ID, "pending").Count(&openAttempts)
+			if openAttempts > 0 || invoice.Status != "unpaid" {
+				return nil
+			}
+			if err := tx.Model(&invoice).Update("status", "failed").Error; err != nil {
+				return err
+			}
+			if invoice.OrderID != nil {
+				return tx.Model(&models.Order{}).Where("id = ?", *invoice.OrderID).Update("payment_status", "failed").Error
+			}
+			return nil
+		})
 	}
-	return status
-}
 
-func (s *PaymentService) isPaid(status string) bool {
-	return status == "SETLD" || status == "SUCCESS" || status == "00" || status == "PAID"
-}
+	// The charge may have been opened before the invoice was re-priced (order edit): it only settles
+	// the invoice when it covers what is due now
+	if paid, due, ok := chargeCoversInvoice(invoice, payTx, hasPayTx, result); !ok {
+		return SettleUnderpaid, s.flagUnderpayment(invoice, payTx, hasPayTx, paid, due, via, ref)
+	}
 
-func (s *PaymentService) finalizePayment(invoice *models.Invoice, payTx *models.PaymentTransaction, order *models.Order) error {
-	return s.DB.Transaction(func(tx *gorm.DB) error {
+	outcome := SettlePaid
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
 		now := time.Now()
-		invoice.Status = "paid"
+		isLatePayment := invoice.Status == "expired" || invoice.Status == "cancelled" || invoice.Status == "failed"
+		if isLatePayment {
+			outcome = SettlePaidLate
+		}
+
+		// ATOMIC STATUS UPDATE (Prevent Race Condition)
+		updates := map[string]interface{}{
+			"status":  outcome,
+			"paid_at": now,
+		}
+		if payTx.PaymentMethod != "" {
+			updates["payment_method"] = payTx.PaymentMethod
+		}
+		res := tx.Model(&invoice).Where("id = ? AND status IN ?", invoice.ID, []string{"unpaid", "pending", "pending_arrival", "awaiting_approval", "expired", "failed", "cancelled"}).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			// Already paid by concurrent request
+			outcome = SettleAlreadyPaid
+			return nil
+		}
+		invoice.Status = outcome
 		invoice.PaidAt = &now
-		tx.Save(invoice)
-
-		payTx.Status = "success"
-		tx.Save(payTx)
-
-		// Handle different invoice types
-		if invoice.Type == "deposit" {
-			// Deposit payment for Pre-Order
-			order.DepositPaid += invoice.Amount
-			order.RemainingBalance -= invoice.Amount
-			order.PaymentStatus = "deposit_paid"
-			order.Status = "pre_order"
+		if payTx.PaymentMethod != "" {
+			invoice.PaymentMethod = payTx.PaymentMethod
+		}
+
+		if hasPayTx {
+			payUpdates := map[string]interface{}{}
+			if payTx.GatewayTxID == "" && result.GatewayRef != "" {
+				payUpdates["gateway_tx_id"] = result.GatewayRef
+			}
+			transitionPaymentTx(tx, payTx, "success", payUpdates)
+		}
+
+		// Auto-Save Card Token (If present in callback AND user opted to save)
+		if result.CardToken != "" && payTx.SaveCard {
+			s.saveCardToken(tx, invoice.UserID, result)
+		}
+
+		// Record Finance Journal: gateway money sits on clearing until its settlement is reconciled
+		// Bank transfers carry the invoice's unique code on top of the amount
+		journal := helpers.RecordGatewayPaymentJournal
+		switch {
+		case payTx.Gateway == "manual" || result.RawStatus == "BANK":
+			journal = helpers.RecordTransferPaymentJournal
+		case !hasPayTx || payTx.Gateway == "wallet":
+			journal = helpers.RecordPaymentJournal
+		}
+		if err := journal(tx, &invoice, ref); err != nil {
+			log.Printf("❌ Failed to record payment journal: %v", err)
+		}
+
+		if invoice.OrderID != nil {
+			if isLatePayment {
+				// 🛡️ RACE CONDITION HANDLER: Intercept and convert to Wallet Balance 🛡️
+				var user models.User
+				if err := tx.First(&user, invoice.UserID).Error; err == nil {
+					balanceBefore := user.Balance
+					if err := tx.Model(&user).Update("balance", gorm.Expr("balance + ?", invoice.Amount)).Error; err == nil {
+						tx.Create(&models.WalletTransaction{
+							UserID:        user.ID,
+							Type:          "credit",
+							Amount:        invoice.Amount,
+							Description:   fmt.Sprintf("Refund for late payment on %s", invoice.InvoiceNumber),
+							ReferenceType: "invoice",
+							ReferenceID:   invoice.InvoiceNumber,
+							BalanceBefore: balanceBefore,
+							BalanceAfter:  balanceBefore + invoice.Amount,
+						})
+						log.Printf("💰 RACE CONDITION INTERCEPTED: Late payment %s converted to Wallet Top Up for User %d", invoice.InvoiceNumber, user.ID)
+
+						tx.Create(&models.OrderLog{
+							OrderID: *invoice.OrderID,
+							UserID:  user.ID,
+							Action:  "late_payment_refunded",
+							Note:    fmt.Sprintf("Payment arrived after cancellation. Funds (Rp %.0f) automatically routed to Forza Wallet.", invoice.Amount),
+						})
+					}
+				}
+			} else {
+				// Per-line settlement: stock moves from reserved to sold only for fully paid lines
+				order, err := NewOrderService().SyncPaymentState(tx, *invoice.OrderID)
+				if err != nil {
+					return err
+				}
+				tx.Create(&models.OrderLog{
+					OrderID: order.ID,
+					UserID:  order.UserID,
+					Action:  "status_change",
+					Note:    fmt.Sprintf("Payment confirmed via %s (%s). Status: %s. Stock Finalized.", via, ref, order.Status),
+				})
+			}
 		} else if invoice.Type == "topup" {
-			// Wallet top-up — update user balance
 			var user models.User
 			if err := tx.First(&user, invoice.UserID).Error; err == nil {
 				balanceBefore := user.Balance
-				tx.Model(&user).Update("balance", gorm.Expr("balance + ?", invoice.Amount))
-				user.Balance += invoice.Amount
+				if err := tx.Model(&user).Update("balance", gorm.Expr("balance + ?", invoice.Amount)).Error; err != nil {
+					return err
+				}
 				tx.Create(&models.WalletTransaction{
 					UserID:        user.ID,
 					Type:          "credit",
 					Amount:        invoice.Amount,
-					Description:   fmt.Sprintf("Top Up via Auto-Sync - %s", payTx.MerchantRefNo),
+					Description:   fmt.Sprintf("Top Up via Warung Forza - %s", payTx.PaymentMethod),
 					ReferenceType: "invoice",
 					ReferenceID:   invoice.InvoiceNumber,
 					BalanceBefore: balanceBefore,
-					BalanceAfter:  user.Balance,
+					BalanceAfter:  balanceBefore + invoice.Amount,
 				})
-			}
-		} else {
-			// Full/balance payment — finalize order
-			order.PaymentStatus = "paid"
-			order.Status = "processing"
-			order.RemainingBalance -= invoice.Amount
-
-			// Stock Deduction: Reserved → Sold
-			var orderWithItems models.Order
-			if err := tx.Preload("Items.Product").First(&orderWithItems, order.ID).Error; err == nil {
-				for _, item := range orderWithItems.Items {
-					tx.Model(&models.Product{}).
-						Where("id = ?", item.ProductID).
-						Updates(map[string]interface{}{
-							"stock":        gorm.Expr("stock - ?", item.Quantity),
-							"reserved_qty": gorm.Expr("GREATEST(reserved_qty - ?, 0)", item.Quantity),
-						})
-				}
+				log.Printf("💰 Wallet Top Up Success (%s): User %d Amount %.2f", via, user.ID, invoice.Amount)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
 
-		if order.ID != 0 {
-			tx.Save(order)
-		}
+	if outcome == SettlePaid || outcome == SettlePaidLate {
+		s.sendPaymentSuccessEmail(invoice)
+	}
+	return outcome, nil
+}
 
-		helpers.RecordPaymentJournal(tx, invoice, payTx.MerchantRefNo)
+// chargeCoversInvoice compares what a paid charge brought in with what the invoice is due: its amount,
+// plus the unique code for bank transfers. The gateway's reported amount wins; a result without one
+// falls back to the amount the charge was opened for.
+func chargeCoversInvoice(invoice models.Invoice, payTx models.PaymentTransaction, hasPayTx bool, result ChargeResult) (paid, due float64, ok bool) {
+	due = invoice.Amount
+	if (hasPayTx && payTx.Gateway == "manual") || result.RawStatus == "BANK" {
+		due = invoice.TransferAmount()
+	}
+	paid = result.Amount
+	if paid <= 0 && hasPayTx {
+		paid = payTx.Amount
+	}
+	return paid, due, paid >= due-0.5
+}
+
+// flagUnderpayment records a paid charge that does not cover its invoice: the charge is marked
+// underpaid, the invoice stays open and finance is told to settle the difference by hand
+func (s *PaymentService) flagUnderpayment(invoice models.Invoice, payTx models.PaymentTransaction, hasPayTx bool, paid, due float64, via, ref string) error {
+	flagged := true
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if hasPayTx && !transitionPaymentTx(tx, payTx, "underpaid", nil) {
+			flagged = false // Already flagged by an earlier delivery of the same result
+			return nil
+		}
+		if invoice.OrderID != nil {
+			tx.Create(&models.OrderLog{
+				OrderID: *invoice.OrderID,
+				UserID:  invoice.Order.UserID,
+				Action:  "payment_underpaid",
+				Note:    fmt.Sprintf("Payment via %s (%s) of Rp %.0f does not cover invoice %s (Rp %.0f). Invoice left open.", via, ref, paid, invoice.InvoiceNumber, due),
+			})
+		}
 		return nil
 	})
+	if err != nil || !flagged {
+		return err
+	}
+
+	log.Printf("⚠️ Underpayment on %s: paid %.0f, due %.0f (%s)", invoice.InvoiceNumber, paid, due, ref)
+	helpers.NotifyAdmin("PAYMENT_UNDERPAID", fmt.Sprintf("Pembayaran %s kurang: Rp %.0f dari Rp %.0f", invoice.InvoiceNumber, paid, due), map[string]interface{}{
+		"invoice_id":     invoice.ID,
+		"invoice_number": invoice.InvoiceNumber,
+		"paid":           paid,
+		"due":            due,
+		"reference":      ref,
+	})
+	return nil
+}
+
+func (s *PaymentService) saveCardToken(tx *gorm.DB, userID uint, result ChargeResult) {
+	var existingCard models.PrismalinkCard
+	if err := tx.Where("card_token = ?", result.CardToken).First(&existingCard).Error; err == nil {
+		return
+	}
+
+	last4 := "0000"
+	if len(result.MaskedCard) >= 4 {
+		last4 = result.MaskedCard[len(result.MaskedCard)-4:]
+	}
+	cardType := result.CardType
+	if cardType == "" {
+		cardType = "CC"
+	}
+
+	newCard := models.PrismalinkCard{
+		UserID:        fmt.Sprintf("%d", userID),
+		MerchantID:    helpers.GetPrismalinkConfig().MerchantID,
+		CardToken:     result.CardToken,
+		CardDigit:     last4,
+		PaymentMethod: "CC",
+		BankID:        cardType,
+		BindStatus:    "ACTIVE",
+		IsActive:      true,
+	}
+	if err := tx.Create(&newCard).Error; err == nil {
+		log.Printf("💳 Auto-Saved New Card Token (Last4: %s)", last4)
+	} else {
+		log.Printf("⚠️ Failed to save card token: %v", err)
+	}
+}
+
+func (s *PaymentService) sendPaymentSuccessEmail(invoice models.Invoice) {
+	userID := invoice.UserID
+	if invoice.OrderID != nil && invoice.Order.UserID != 0 {
+		userID = invoice.Order.UserID
+	}
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err == nil && user.Email != "" {
+		helpers.SendPaymentSuccessEmail(user.Email, user.FullName, invoice.InvoiceNumber, invoice.Amount, invoice.Type)
+	}
 }
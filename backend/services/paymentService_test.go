@@ -0,0 +1,72 @@
+package services
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"forzashop/backend/models"
+)
+
+func TestChargeCoversInvoice(t *testing.T) {
+	invoice := models.Invoice{Amount: 150000, UniqueCode: 123}
+	card := models.PaymentTransaction{Gateway: "prismalink", Amount: 150000}
+	manual := models.PaymentTransaction{Gateway: "manual", Amount: 150123}
+
+	tests := []struct {
+		name     string
+		payTx    models.PaymentTransaction
+		hasPayTx bool
+		result   ChargeResult
+		wantPaid float64
+		wantDue  float64
+		wantOK   bool
+	}{
+		{"card pays the amount", card, true, ChargeResult{Amount: 150000}, 150000, 150000, true},
+		{"card underpays", card, true, ChargeResult{Amount: 100000}, 100000, 150000, false},
+		{"rounding is tolerated", card, true, ChargeResult{Amount: 149999.6}, 149999.6, 150000, true},
+		{"no reported amount falls back to the charge", card, true, ChargeResult{}, 150000, 150000, true},
+		{"bank mutation must include the unique code", models.PaymentTransaction{}, false, ChargeResult{Amount: 150000, RawStatus: "BANK"}, 150000, 150123, false},
+		{"bank mutation with the unique code", models.PaymentTransaction{}, false, ChargeResult{Amount: 150123, RawStatus: "BANK"}, 150123, 150123, true},
+		{"manual transfer is due with its code", manual, true, ChargeResult{}, 150123, 150123, true},
+		{"no amount and no charge", models.PaymentTransaction{}, false, ChargeResult{}, 0, 150000, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paid, due, ok := chargeCoversInvoice(invoice, tt.payTx, tt.hasPayTx, tt.result)
+			if paid != tt.wantPaid || due != tt.wantDue || ok != tt.wantOK {
+				t.Errorf("got (%v, %v, %v), want (%v, %v, %v)", paid, due, ok, tt.wantPaid, tt.wantDue, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSettleChargeLeavesUnderpaidInvoiceOpen(t *testing.T) {
+	db, stub := newStubDB(t)
+	stub.on(`FROM "payment_transactions"`, []string{"id", "invoice_id", "merchant_ref_no", "gateway", "amount", "status"},
+		[]driver.Value{int64(7), int64(20), "INV-2026-10-00001-101500", "sandbox", 150000.0, "pending"})
+	stub.on(`FROM "invoices"`, []string{"id", "order_id", "invoice_number", "type", "status", "amount"},
+		[]driver.Value{int64(20), int64(1), "INV-2026-10-00001", "full", "unpaid", 150000.0})
+	stub.on(`FROM "orders"`, []string{"id", "user_id", "order_number"}, []driver.Value{int64(1), int64(3), "FORZA-2026-10-00001"})
+
+	outcome, err := (&PaymentService{DB: db}).SettleCharge(ChargeResult{
+		MerchantRefNo: "INV-2026-10-00001-101500",
+		Status:        ChargeStatusPaid,
+		Amount:        100000,
+	}, "sandbox callback")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, stub.dump())
+	}
+	if outcome != SettleUnderpaid {
+		t.Errorf("outcome = %s, want %s", outcome, SettleUnderpaid)
+	}
+	if stub.count(`UPDATE "invoices"`) > 0 || stub.count(`UPDATE "orders"`) > 0 {
+		t.Errorf("underpaid charge changed the invoice or order:\n%s", stub.dump())
+	}
+	if stub.count(`UPDATE "payment_transactions"`, "underpaid", "status = pending") != 1 {
+		t.Errorf("charge not flagged underpaid:\n%s", stub.dump())
+	}
+	if stub.count(`INSERT INTO "order_logs"`, "payment_underpaid") != 1 {
+		t.Errorf("underpayment not logged on the order:\n%s", stub.dump())
+	}
+}
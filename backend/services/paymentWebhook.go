@@ -0,0 +1,73 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"forzashop/backend/models"
+)
+
+// CallbackKeyer is implemented by gateways whose callbacks carry an identity usable for de-duplication
+type CallbackKeyer interface {
+	CallbackKey(body []byte) (key string, eventType string)
+}
+
+// CallbackAuthenticator is implemented by gateways that can check a callback's signature (and
+// freshness) on receipt, without side effects
+type CallbackAuthenticator interface {
+	AuthenticateCallback(header http.Header, body []byte) error
+}
+
+// CallbackLogger is implemented by gateways that keep their own log of verified notifications
+type CallbackLogger interface {
+	LogCallback(header http.Header, body []byte)
+}
+
+// paymentWebhookHandler verifies a stored gateway callback and settles it
+type paymentWebhookHandler struct {
+	gateway string
+}
+
+func (h paymentWebhookHandler) EventKey(header http.Header, body []byte) (string, string) {
+	if g, ok := paymentGateways[h.gateway].(CallbackKeyer); ok {
+		if key, eventType := g.CallbackKey(body); key != "" {
+			return key, eventType
+		}
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:]), "payment"
+}
+
+func (h paymentWebhookHandler) Authenticate(header http.Header, body []byte) error {
+	if g, ok := paymentGateways[h.gateway].(CallbackAuthenticator); ok {
+		return g.AuthenticateCallback(header, body)
+	}
+	return nil
+}
+
+func (h paymentWebhookHandler) Process(event models.WebhookEvent, header http.Header) (string, error) {
+	gateway, err := GetPaymentGateway(h.gateway)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrWebhookRejected, err)
+	}
+	result, err := gateway.VerifyCallback(header, []byte(event.Payload))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrWebhookRejected, err)
+	}
+	// Logged on the first attempt only, retries of the same event are not new notifications
+	if logger, ok := gateway.(CallbackLogger); ok && event.Attempts <= 1 {
+		logger.LogCallback(header, []byte(event.Payload))
+	}
+
+	outcome, err := NewPaymentService().SettleCharge(*result, h.gateway+" callback")
+	if err != nil {
+		return "", err
+	}
+	// The callback may overtake the commit of the charge it reports on: retry before giving up
+	if outcome == SettleInvoiceNotFound {
+		return "", fmt.Errorf("no invoice for merchant_ref_no=%s gateway_ref=%s", result.MerchantRefNo, result.GatewayRef)
+	}
+	return fmt.Sprintf("%s (%s)", outcome, result.RawStatus), nil
+}
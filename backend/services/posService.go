@@ -3,8 +3,10 @@ package services
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"forzashop/backend/config"
 	"forzashop/backend/helpers"
@@ -53,7 +55,10 @@ func (s *POSService) SearchProducts(params SearchParams) ([]models.Product, erro
 	var products []models.Product
 	dbQuery := s.DB.Model(&models.Product{}).
 		Preload("Category").
-		Preload("Brand")
+		Preload("Brand").
+		Preload("Variants", func(db *gorm.DB) *gorm.DB {
+			return db.Where("status = ?", "active").Order("display_order ASC, id ASC")
+		})
 		// POS now shows both Ready and PO stock.
 
 	if params.Query != "" {
@@ -71,6 +76,9 @@ func (s *POSService) SearchProducts(params SearchParams) ([]models.Product, erro
 	// Calculate Virtual Stock
 	for i := range products {
 		products[i].AvailableStock = products[i].Stock - products[i].ReservedQty
+		for j := range products[i].Variants {
+			products[i].Variants[j].AvailableStock = products[i].Variants[j].Stock - products[i].Variants[j].ReservedQty
+		}
 	}
 
 	return products, nil
@@ -83,7 +91,10 @@ func (s *POSService) buildSearchQuery(db *gorm.DB, query string) *gorm.DB {
 		s.DB.Where("sku ILIKE ?", likeQuery).
 			Or("qr_code ILIKE ?", likeQuery).
 			Or("REPLACE(name, ' ', '') ILIKE ?", cleanQuery).
-			Or("name ILIKE ?", likeQuery),
+			Or("name ILIKE ?", likeQuery).
+			// Scanning an edition's QR/SKU finds its parent product
+			Or("id IN (?)", s.DB.Model(&models.ProductVariant{}).Select("product_id").
+				Where("sku ILIKE ? OR qr_code ILIKE ?", likeQuery, likeQuery)),
 	)
 }
 
@@ -96,6 +107,7 @@ type CreateOrderInput struct {
 	PaymentMethod string             `json:"payment_method"`
 	POPaymentType string             `json:"po_payment_type"`
 	Notes         string             `json:"notes"`
+	LocationID    uint               `json:"location_id"` // Till's location (0 = pos_location_code setting, then the default location)
 	ProcessorID   uint
 	IPAddress     string
 	UserAgent     string
@@ -115,10 +127,18 @@ func (s *POSService) CreateOrder(input CreateOrderInput) (*CreateOrderResult, er
 
 	return s.withTransaction(func(tx *gorm.DB) (*CreateOrderResult, error) {
 		// 1. Process Items & Update Stock
-		orderItems, totalAmount, isPO, err := s.processOrderItems(tx, input.Items, input.ProcessorID)
+		locationID, err := s.tillLocation(tx, input.LocationID)
 		if err != nil {
 			return nil, err
 		}
+		orderItems, totalAmount, isPO, err := s.processOrderItems(tx, input.Items, locationID, input.ProcessorID)
+		if err != nil {
+			return nil, err
+		}
+
+		// PPN (POS sales are always domestic)
+		taxBreakdown := (&TaxService{DB: tx}).CalculateForItems(orderItems, 0, 0, "ID", "")
+		totalAmount += taxBreakdown.Extra()
 
 		// 2. Handle User
 		userID := input.UserID
@@ -134,10 +154,13 @@ func (s *POSService) CreateOrder(input CreateOrderInput) (*CreateOrderResult, er
 		status, paymentStatus, invoiceStatus := s.determineOrderStatus(pm, isPO, input.POPaymentType)
 
 		// 4. Create Order Record
-		order, err := s.createOrderRecord(tx, userID, input, totalAmount, pm, status, paymentStatus, orderItems)
+		order, err := s.createOrderRecord(tx, userID, input, totalAmount, pm, status, paymentStatus, orderItems, taxBreakdown)
 		if err != nil {
 			return nil, err
 		}
+		if err := s.holdPOLines(tx, *order, input.ProcessorID); err != nil {
+			return nil, err
+		}
 
 		// Calculate invoice amount (Simplified for POS: assumes full unless explicitly logic handled later, but POS PO usually takes full payment for now unless DP system is linked. For simplicity, we bill totalAmount as Deposit if PO Type is deposit, or Full if full).
 		invoiceType := InvoiceTypeFull
@@ -146,7 +169,7 @@ func (s *POSService) CreateOrder(input CreateOrderInput) (*CreateOrderResult, er
 		}
 
 		// 5. Create Invoice Record
-		invoice, err := s.createInvoiceRecord(tx, order.ID, userID, totalAmount, pm, invoiceStatus, invoiceType)
+		invoice, err := s.createInvoiceRecord(tx, order.ID, userID, totalAmount, order.TaxAmount, pm, invoiceStatus, invoiceType)
 		if err != nil {
 			return nil, err
 		}
@@ -211,51 +234,110 @@ func (s *POSService) withTransaction(fn func(tx *gorm.DB) (*CreateOrderResult, e
 
 // Internal Logic Methods
 
-func (s *POSService) processOrderItems(tx *gorm.DB, items []models.OrderItem, staffID uint) ([]models.OrderItem, float64, bool, error) {
+// tillLocation resolves the location a till sells from: the one it sends, else the pos_location_code
+// setting, else the default location
+func (s *POSService) tillLocation(tx *gorm.DB, locationID uint) (uint, error) {
+	var location models.Location
+	if locationID != 0 {
+		if err := tx.Where("id = ? AND is_active = ?", locationID, true).First(&location).Error; err != nil {
+			return 0, fmt.Errorf("lokasi kasir tidak ditemukan")
+		}
+		return location.ID, nil
+	}
+	if code := helpers.GetSetting("pos_location_code", ""); code != "" {
+		if tx.Where("code = ? AND is_active = ?", code, true).Limit(1).Find(&location); location.ID != 0 {
+			return location.ID, nil
+		}
+	}
+	return helpers.DefaultLocationID(tx)
+}
+
+func (s *POSService) processOrderItems(tx *gorm.DB, items []models.OrderItem, locationID, staffID uint) ([]models.OrderItem, float64, bool, error) {
 	var totalAmount float64
 	var orderItems []models.OrderItem
 	var isPO bool
 
 	for _, itemInput := range items {
-		var product models.Product
-		if err := tx.First(&product, itemInput.ProductID).Error; err != nil {
-			return nil, 0, false, fmt.Errorf("produk ID %d tidak ditemukan", itemInput.ProductID)
+		if itemInput.Quantity <= 0 {
+			return nil, 0, false, fmt.Errorf("jumlah produk ID %d tidak valid", itemInput.ProductID)
 		}
-
-		available := product.Stock - product.ReservedQty
-		if available < itemInput.Quantity && product.ProductType == ProductTypeReady {
-			return nil, 0, false, fmt.Errorf("stok %s tidak mencukupi (%d tersedia)", product.Name, available)
+		product, variant, err := LoadSellable(tx, itemInput.ProductID, itemInput.VariantID)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		displayName := product.Name
+		if variant != nil {
+			displayName = product.Name + " - " + variant.Name
 		}
 
-		// Stock Movement
+		// Stock Movement (atomic against stock - reserved_qty, per edition), off the till's own shelf
 		if product.ProductType == ProductTypeReady {
-			helpers.RecordStockMovement(tx, product.ID, -itemInput.Quantity, "physical", "sale", "POS", "DIRECT", "POS Direct Sales", &staffID)
-			if err := tx.Model(&product).Update("stock", gorm.Expr("stock - ?", itemInput.Quantity)).Error; err != nil {
+			level, err := helpers.LockLocationStock(tx, locationID, product.ID, itemInput.VariantID)
+			if err != nil {
 				return nil, 0, false, err
 			}
-		} else {
-			helpers.RecordStockMovement(tx, product.ID, itemInput.Quantity, "reserved", "sale", "POS", "DIRECT", "POS Pre-Order Reservation", &staffID)
-			if err := tx.Model(&product).Update("reserved_qty", gorm.Expr("reserved_qty + ?", itemInput.Quantity)).Error; err != nil {
+			if level.Stock-level.ReservedQty < itemInput.Quantity {
+				return nil, 0, false, fmt.Errorf("stok %s di lokasi kasir tidak mencukupi", displayName)
+			}
+			if err := helpers.RecordLocationStockMovement(tx, locationID, product.ID, itemInput.VariantID, -itemInput.Quantity, "physical", "sale", "POS", "DIRECT", "POS Direct Sales", &staffID); err != nil {
 				return nil, 0, false, err
 			}
+			ok, err := helpers.DeductStock(tx, product.ID, itemInput.VariantID, itemInput.Quantity)
+			if err != nil {
+				return nil, 0, false, err
+			}
+			if !ok {
+				return nil, 0, false, fmt.Errorf("stok %s tidak mencukupi", displayName)
+			}
+		} else {
+			// PO slots are held per line once the order exists (see holdPOLines)
 			isPO = true
 		}
 
-		itemTotal := product.Price * float64(itemInput.Quantity)
+		unitPrice := product.PriceFor(variant)
+		itemTotal := unitPrice * float64(itemInput.Quantity)
 		totalAmount += itemTotal
 
-		orderItems = append(orderItems, models.OrderItem{
+		line := models.OrderItem{
 			ProductID:    product.ID,
+			VariantID:    itemInput.VariantID,
 			Quantity:     itemInput.Quantity,
-			Price:        product.Price,
+			Price:        unitPrice,
 			Total:        itemTotal,
-			COGSSnapshot: product.SupplierCost,
-		})
+			COGSSnapshot: product.CostFor(variant),
+			ProductType:  ProductTypeReady,
+		}
+		if product.ProductType == ProductTypePO {
+			line.ProductType = ProductTypePO
+		} else {
+			// Ready stock leaves the shelf at the till, so the line is settled immediately
+			now := time.Now()
+			line.SettledAt = &now
+		}
+		orderItems = append(orderItems, line)
 	}
 
 	return orderItems, totalAmount, isPO, nil
 }
 
+// holdPOLines reserves the PO slots of the order's pre-order lines in the reservation ledger
+func (s *POSService) holdPOLines(tx *gorm.DB, order models.Order, staffID uint) error {
+	inventory := &InventoryService{DB: tx}
+	for _, item := range order.Items {
+		if item.ProductType != ProductTypePO {
+			continue
+		}
+		if err := inventory.HoldOrderLine(order, item, "pos", &staffID); err != nil {
+			if errors.Is(err, ErrInsufficientStock) {
+				item.Product, item.Variant, _ = LoadSellable(tx, item.ProductID, item.VariantID)
+				return fmt.Errorf("slot PO %s tidak mencukupi", orderItemName(item))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *POSService) determineOrderStatus(paymentMethod string, isPO bool, poPaymentType string) (string, string, string) {
 	status := OrderStatusCompleted
 	paymentStatus := OrderStatusPaid
@@ -269,21 +351,22 @@ func (s *POSService) determineOrderStatus(paymentMethod string, isPO bool, poPay
 
 	if isPO {
 		status = OrderStatusPreOrder
-		if paymentStatus == OrderStatusPaid {
-			if poPaymentType == "full" {
-				paymentStatus = "paid_full"
-			} else {
-				paymentStatus = "deposit_paid"
-			}
+		if paymentStatus == OrderStatusPaid && poPaymentType != "full" {
+			paymentStatus = "deposit_paid"
 		}
 	}
 
 	return status, paymentStatus, invoiceStatus
 }
 
-func (s *POSService) createOrderRecord(tx *gorm.DB, userID uint, input CreateOrderInput, total float64, pm, status, payStatus string, items []models.OrderItem) (*models.Order, error) {
+func (s *POSService) createOrderRecord(tx *gorm.DB, userID uint, input CreateOrderInput, total float64, pm, status, payStatus string, items []models.OrderItem, tax TaxBreakdown) (*models.Order, error) {
+	orderNumber, err := helpers.NextSequenceNumber(tx, helpers.SeqPOSOrder)
+	if err != nil {
+		return nil, err
+	}
+
 	order := models.Order{
-		OrderNumber:      fmt.Sprintf("POS-%s", helpers.GenerateRandomString(8)),
+		OrderNumber:      orderNumber,
 		UserID:           userID,
 		BillingFirstName: input.CustomerName,
 		BillingEmail:     input.CustomerEmail,
@@ -299,6 +382,7 @@ func (s *POSService) createOrderRecord(tx *gorm.DB, userID uint, input CreateOrd
 	if order.BillingFirstName == "" {
 		order.BillingFirstName = "Walk-in Guest"
 	}
+	ApplyTax(&order, order.Items, tax)
 
 	if err := tx.Create(&order).Error; err != nil {
 		return nil, fmt.Errorf("gagal membuat order: %v", err)
@@ -306,12 +390,18 @@ func (s *POSService) createOrderRecord(tx *gorm.DB, userID uint, input CreateOrd
 	return &order, nil
 }
 
-func (s *POSService) createInvoiceRecord(tx *gorm.DB, orderID, userID uint, amount float64, pm, status string, invType string) (*models.Invoice, error) {
+func (s *POSService) createInvoiceRecord(tx *gorm.DB, orderID, userID uint, amount, taxAmount float64, pm, status string, invType string) (*models.Invoice, error) {
+	invoiceNumber, err := helpers.NextSequenceNumber(tx, helpers.SeqInvoice)
+	if err != nil {
+		return nil, err
+	}
+
 	invoice := models.Invoice{
-		InvoiceNumber: "INV-POS-" + helpers.GenerateRandomString(6),
+		InvoiceNumber: invoiceNumber,
 		OrderID:       &orderID,
 		UserID:        userID,
 		Amount:        amount,
+		TaxAmount:     taxAmount,
 		Status:        status,
 		PaymentMethod: pm,
 		Type:          invType,
@@ -372,10 +462,10 @@ func (s *POSService) generatePaymentLinkTx(tx *gorm.DB, order models.Order, invo
 	}
 	// Warning: helpers might use global DB, but here we pass objects.
 	// Ideally helpers should accept DB interface, but for now we rely on the object data.
-	return helpers.GeneratePaymentLink(order, invoice, payUser, "127.0.0.1")
+	return CreatePaymentLink(order, invoice, payUser, "127.0.0.1")
 }
 
-// GenerateQRCodes generates QR codes for products missing them
+// GenerateQRCodes generates QR codes for products and editions missing them
 func (s *POSService) GenerateQRCodes() (int, error) {
 	var products []models.Product
 	if err := s.DB.Where("qr_code IS NULL OR qr_code = ''").Find(&products).Error; err != nil {
@@ -391,5 +481,18 @@ func (s *POSService) GenerateQRCodes() (int, error) {
 			updated++
 		}
 	}
+
+	var variants []models.ProductVariant
+	if err := s.DB.Where("qr_code IS NULL OR qr_code = ''").Find(&variants).Error; err != nil {
+		return updated, err
+	}
+	for i := range variants {
+		qrBytes := make([]byte, 4)
+		rand.Read(qrBytes)
+		variants[i].QRCode = fmt.Sprintf("FZ-%s-%s", strings.ToUpper(variants[i].SKU), hex.EncodeToString(qrBytes))
+		if err := s.DB.Model(&variants[i]).Update("qr_code", variants[i].QRCode).Error; err == nil {
+			updated++
+		}
+	}
 	return updated, nil
 }
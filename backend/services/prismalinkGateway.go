@@ -0,0 +1,257 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/datatypes"
+)
+
+// PrismalinkGateway wraps the PLINK V2 Full API helpers behind the PaymentGateway interface
+type PrismalinkGateway struct{}
+
+func init() {
+	RegisterPaymentGateway(&PrismalinkGateway{})
+}
+
+func (g *PrismalinkGateway) Name() string {
+	return "prismalink"
+}
+
+func (g *PrismalinkGateway) ListMethods() []PaymentMethodInfo {
+	return []PaymentMethodInfo{
+		{Code: "VA", Name: "Virtual Account", Type: "va"},
+		{Code: "QRIS", Name: "QRIS", Type: "qris"},
+		{Code: "CC", Name: "Credit Card", Type: "card"},
+	}
+}
+
+func (g *PrismalinkGateway) CreateCharge(req ChargeRequest) (*helpers.PaymentData, error) {
+	order := req.Order
+	if req.Method != "" {
+		order.PaymentMethod = req.Method
+	}
+
+	paymentData, err := helpers.GeneratePaymentDirect(order, req.Invoice, req.User, req.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+	if req.SaveCard && paymentData.MerchantRefNo != "" {
+		config.DB.Model(&models.PaymentTransaction{}).Where("merchant_ref_no = ?", paymentData.MerchantRefNo).Update("save_card", true)
+	}
+	return paymentData, nil
+}
+
+func (g *PrismalinkGateway) SubmitCard(merchantRefNo, sessionToken string, invoice models.Invoice, user models.User, card map[string]string, clientIP string) (*helpers.PaymentData, error) {
+	return helpers.SubmitCreditCard(helpers.GetPrismalinkConfig(), merchantRefNo, sessionToken, invoice, user, card, clientIP)
+}
+
+func (g *PrismalinkGateway) QueryStatus(payTx models.PaymentTransaction) (*ChargeResult, error) {
+	// Inquiry always fails without the plink_ref_no returned by submit-trx
+	if payTx.GatewayTxID == "" {
+		return nil, fmt.Errorf("transaction %s has no plink_ref_no yet", payTx.MerchantRefNo)
+	}
+
+	// Prefer the transaction's own method, fallback to the order's
+	pm := payTx.PaymentMethod
+	if pm == "" && payTx.OrderID != 0 {
+		var order models.Order
+		if err := config.DB.Select("payment_method").First(&order, payTx.OrderID).Error; err == nil {
+			pm = order.PaymentMethod
+		}
+	}
+	switch strings.ToUpper(pm) {
+	case "QR", "QRIS":
+		pm = "QR" // Inquiry API expects "QR" not "QRIS"
+	case "CC":
+		pm = "CC"
+	default:
+		pm = "VA"
+	}
+
+	res, err := helpers.CheckPrismalinkStatus(payTx.MerchantRefNo, payTx.GatewayTxID, payTx.Amount, pm)
+	if err != nil {
+		return nil, err
+	}
+	if rc, ok := res["response_code"].(string); ok && rc != "PL000" && rc != "00" {
+		return nil, fmt.Errorf("prismalink inquiry returned %s", rc)
+	}
+
+	raw := prismalinkStatus(res)
+	return &ChargeResult{
+		MerchantRefNo: payTx.MerchantRefNo,
+		GatewayRef:    payTx.GatewayTxID,
+		Status:        prismalinkChargeStatus(raw),
+		RawStatus:     raw,
+		Amount:        payTx.Amount,
+		Raw:           res,
+	}, nil
+}
+
+// CallbackKey - one event per charge and status, Prismalink re-sends the same notification until acknowledged
+func (g *PrismalinkGateway) CallbackKey(body []byte) (string, string) {
+	var payload struct {
+		MerchantRefNo string `json:"merchant_ref_no"`
+		PlinkRefNo    string `json:"plink_ref_no"`
+		PaymentStatus string `json:"payment_status"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.MerchantRefNo == "" {
+		return "", ""
+	}
+	return fmt.Sprintf("%s:%s:%s", payload.MerchantRefNo, payload.PlinkRefNo, payload.PaymentStatus), "payment." + strings.ToLower(payload.PaymentStatus)
+}
+
+// SupportsRefund - PLINK V2 has no refund API; refunds are paid out from the merchant portal,
+// so Prismalink charges are refunded manually (or to the wallet)
+func (g *PrismalinkGateway) SupportsRefund() bool {
+	return false
+}
+
+func (g *PrismalinkGateway) Refund(req RefundRequest) (*RefundResult, error) {
+	return nil, ErrRefundNotSupported
+}
+
+// AuthenticateCallback checks the `mac` header: HMAC-SHA256 of the raw body with the merchant secret.
+// Callbacks are rejected while no secret is configured.
+func (g *PrismalinkGateway) AuthenticateCallback(header http.Header, body []byte) error {
+	secret := helpers.GetPrismalinkConfig().SecretKey
+	if secret == "" {
+		return fmt.Errorf("prismalink secret key is not configured")
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(header.Get("mac")))) {
+		log.Printf("❌ PRISMALINK SIGNATURE MISMATCH for callback")
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// prismalinkCallback is the part of the notification body we act on
+type prismalinkCallback struct {
+	MerchantRefNo string `json:"merchant_ref_no"`
+	PlinkRefNo    string `json:"plink_ref_no"`
+	PaymentStatus string `json:"payment_status"`
+	BankID        string `json:"bank_id"`
+}
+
+// VerifyCallback authenticates the callback, records the status on the PrismalinkTransaction
+// and returns the normalized result
+func (g *PrismalinkGateway) VerifyCallback(header http.Header, body []byte) (*ChargeResult, error) {
+	if err := g.AuthenticateCallback(header, body); err != nil {
+		return nil, err
+	}
+
+	var payload map[string]interface{}
+	var cb prismalinkCallback
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid JSON payload")
+	}
+	json.Unmarshal(body, &cb)
+
+	// transaction_amount can be float64 or string
+	var amount float64
+	if amtFloat, ok := payload["transaction_amount"].(float64); ok {
+		amount = amtFloat
+	} else if amtStr, ok := payload["transaction_amount"].(string); ok {
+		amount, _ = strconv.ParseFloat(amtStr, 64)
+	}
+
+	var pt models.PrismalinkTransaction
+	if err := config.DB.Where("merchant_ref_no = ?", cb.MerchantRefNo).First(&pt).Error; err != nil {
+		log.Printf("⚠️ PrismalinkTransaction not found for %s", cb.MerchantRefNo)
+	}
+	if pt.ID != 0 {
+		pt.PlinkRefNo = cb.PlinkRefNo
+		pt.TransactionStatus = cb.PaymentStatus
+		config.DB.Save(&pt)
+	}
+
+	result := &ChargeResult{
+		MerchantRefNo: cb.MerchantRefNo,
+		GatewayRef:    cb.PlinkRefNo,
+		InvoiceNumber: pt.InvoiceNumber,
+		Status:        prismalinkChargeStatus(cb.PaymentStatus),
+		RawStatus:     cb.PaymentStatus,
+		Amount:        amount,
+		Raw:           payload,
+	}
+	result.CardToken, _ = payload["token_id"].(string)
+	result.MaskedCard, _ = payload["masked_card"].(string)
+	result.CardType, _ = payload["card_type"].(string)
+	return result, nil
+}
+
+// LogCallback keeps the verified notification in prismalink_notifications, once per charge and status
+func (g *PrismalinkGateway) LogCallback(header http.Header, body []byte) {
+	var cb prismalinkCallback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		return
+	}
+
+	var logged int64
+	config.DB.Model(&models.PrismalinkNotification{}).
+		Where("merchant_ref_no = ? AND plink_ref_no = ? AND payment_status = ?", cb.MerchantRefNo, cb.PlinkRefNo, cb.PaymentStatus).Count(&logged)
+	if logged > 0 {
+		return
+	}
+
+	var pt models.PrismalinkTransaction
+	config.DB.Select("id").Where("merchant_ref_no = ?", cb.MerchantRefNo).First(&pt)
+	config.DB.Create(&models.PrismalinkNotification{
+		PrismalinkTransactionID: pt.ID,
+		MerchantRefNo:           cb.MerchantRefNo,
+		PlinkRefNo:              cb.PlinkRefNo,
+		PaymentStatus:           cb.PaymentStatus,
+		BankID:                  cb.BankID,
+		RawNotification:         datatypes.JSON(body),
+		MacHeader:               header.Get("mac"),
+		IsVerified:              true,
+		ReceivedAt:              time.Now(),
+	})
+}
+
+// prismalinkStatus extracts the status code of an inquiry response.
+// Inquiry returns "transaction_status" (NOT "payment_status"), sometimes nested in "data".
+func prismalinkStatus(res map[string]interface{}) string {
+	status, _ := res["transaction_status"].(string)
+	if status == "" {
+		status, _ = res["payment_status"].(string)
+	}
+	if status == "" {
+		status, _ = res["status"].(string)
+	}
+	if data, ok := res["data"].(map[string]interface{}); ok {
+		if ds, ok := data["transaction_status"].(string); ok && ds != "" {
+			status = ds
+		} else if ds, ok := data["payment_status"].(string); ok && ds != "" {
+			status = ds
+		}
+	}
+	return status
+}
+
+// prismalinkChargeStatus maps SETLD / PENDG / REJEC (and legacy codes) to a normalized status
+func prismalinkChargeStatus(status string) string {
+	switch strings.ToUpper(status) {
+	case "SETLD", "SUCCESS", "00", "PAID":
+		return ChargeStatusPaid
+	case "REJEC", "FAILED", "99":
+		return ChargeStatusFailed
+	}
+	return ChargeStatusPending
+}
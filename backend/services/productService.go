@@ -175,7 +175,7 @@ func (s *ProductService) CreateProduct(input ProductInput, currentUserID uint) (
 
 	// Log Stock
 	if product.Stock > 0 {
-		helpers.RecordStockMovement(s.DB, product.ID, product.Stock, "physical", "adjustment", "MANUAL", "INITIAL", "Initial stock setup", &currentUserID)
+		helpers.RecordLocationStockMovement(s.DB, 0, product.ID, nil, product.Stock, "physical", "adjustment", "MANUAL", "INITIAL", "Initial stock setup", &currentUserID)
 	}
 
 	helpers.LogAuditSimple(currentUserID, "Product", "CREATE", product.ID, "Created product: "+product.Name)
@@ -192,6 +192,13 @@ func (s *ProductService) UpdateProduct(id uint, input ProductInput, currentUserI
 
 	oldStock := product.Stock
 
+	// Editions carry their own stock; the product only holds the rolled-up totals
+	var variantCount int64
+	s.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount)
+	if variantCount > 0 {
+		input.Stock = nil
+	}
+
 	// Validations
 	// Weight WAJIB > 0 jika status = active (Biteship requirement)
 	if input.Status == "active" && input.Weight <= 0 {
@@ -267,10 +274,10 @@ func (s *ProductService) UpdateProduct(id uint, input ProductInput, currentUserI
 		return nil, err
 	}
 
-	// Log Stock Adjustment
+	// Log Stock Adjustment (manual counts land at the default location)
 	if oldStock != product.Stock {
 		diff := product.Stock - oldStock
-		helpers.RecordStockMovement(s.DB, product.ID, diff, "physical", "adjustment", "MANUAL", "UPDATE", "Manual adjustment via dashboard", &currentUserID)
+		helpers.RecordLocationStockMovement(s.DB, 0, product.ID, nil, diff, "physical", "adjustment", "MANUAL", "UPDATE", "Manual adjustment via dashboard", &currentUserID)
 	}
 
 	helpers.LogAuditSimple(currentUserID, "Product", "UPDATE", product.ID, "Updated product: "+product.Name)
@@ -278,8 +285,225 @@ func (s *ProductService) UpdateProduct(id uint, input ProductInput, currentUserI
 	return &product, nil
 }
 
+// VariantInput is the admin payload for a product edition
+type VariantInput struct {
+	Name         string   `json:"name"`
+	SKU          string   `json:"sku"`
+	Price        float64  `json:"price"`
+	SupplierCost float64  `json:"supplier_cost"`
+	Stock        *int     `json:"stock"` // Ptr to distinguish 0
+	Weight       float64  `json:"weight"`
+	Images       []string `json:"images"`
+	Status       string   `json:"status"`
+	DisplayOrder int      `json:"display_order"`
+}
+
+// LoadSellable resolves a cart line to its product and selected edition.
+// Products that have active editions can only be bought through one of them.
+func LoadSellable(tx *gorm.DB, productID uint, variantID *uint) (models.Product, *models.ProductVariant, error) {
+	var product models.Product
+	if err := tx.First(&product, productID).Error; err != nil {
+		return product, nil, fmt.Errorf("product %d not found", productID)
+	}
+
+	if variantID == nil {
+		var count int64
+		tx.Model(&models.ProductVariant{}).Where("product_id = ? AND status = ?", product.ID, "active").Count(&count)
+		if count > 0 {
+			return product, nil, fmt.Errorf("Silakan pilih varian untuk %s", product.Name)
+		}
+		return product, nil, nil
+	}
+
+	var variant models.ProductVariant
+	if err := tx.Where("id = ? AND product_id = ?", *variantID, product.ID).First(&variant).Error; err != nil {
+		return product, nil, fmt.Errorf("varian produk %d tidak ditemukan", *variantID)
+	}
+	if variant.Status != "active" {
+		return product, nil, fmt.Errorf("Varian %s - %s tidak tersedia", product.Name, variant.Name)
+	}
+	return product, &variant, nil
+}
+
+// ListVariants returns every edition of a product with its available stock
+func (s *ProductService) ListVariants(productID uint) ([]models.ProductVariant, error) {
+	var variants []models.ProductVariant
+	if err := s.DB.Where("product_id = ?", productID).Order("display_order ASC, id ASC").Find(&variants).Error; err != nil {
+		return nil, err
+	}
+	for i := range variants {
+		variants[i].AvailableStock = variants[i].Stock - variants[i].ReservedQty
+	}
+	return variants, nil
+}
+
+// CreateVariant adds an edition to a product. From then on the product's stock is the sum of its editions.
+func (s *ProductService) CreateVariant(productID uint, input VariantInput, currentUserID uint) (*models.ProductVariant, error) {
+	var product models.Product
+	if err := s.DB.First(&product, productID).Error; err != nil {
+		return nil, fmt.Errorf("product not found")
+	}
+	if input.Name == "" || input.SKU == "" || input.Price <= 0 {
+		return nil, fmt.Errorf("name, sku, and price are required")
+	}
+	if err := s.checkSKUAvailable(input.SKU, 0); err != nil {
+		return nil, err
+	}
+
+	var variantCount int64
+	s.DB.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&variantCount)
+	if variantCount == 0 && product.ReservedQty > 0 {
+		// Existing holds sit on the product row and would be lost in the roll-up
+		return nil, fmt.Errorf("produk masih memiliki %d stok yang dipesan, selesaikan pesanan tersebut sebelum menambahkan varian", product.ReservedQty)
+	}
+
+	stockVal := 0
+	if input.Stock != nil {
+		stockVal = *input.Stock
+	}
+	status := input.Status
+	if status == "" {
+		status = "active"
+	}
+	images, _ := json.Marshal(input.Images)
+
+	qrBytes := make([]byte, 4)
+	rand.Read(qrBytes)
+
+	variant := models.ProductVariant{
+		ProductID:    product.ID,
+		Name:         input.Name,
+		SKU:          input.SKU,
+		QRCode:       fmt.Sprintf("FZ-%s-%s", strings.ToUpper(input.SKU), hex.EncodeToString(qrBytes)),
+		Price:        input.Price,
+		SupplierCost: input.SupplierCost,
+		Stock:        stockVal,
+		Weight:       input.Weight,
+		Images:       datatypes.JSON(images),
+		Status:       status,
+		DisplayOrder: input.DisplayOrder,
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&variant).Error; err != nil {
+			return err
+		}
+		if variant.Stock > 0 {
+			helpers.RecordLocationStockMovement(tx, 0, product.ID, &variant.ID, variant.Stock, "physical", "adjustment", "MANUAL", "INITIAL", "Initial variant stock setup", &currentUserID)
+		}
+		return helpers.SyncVariantTotals(tx, product.ID, &variant.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(currentUserID, "ProductVariant", "CREATE", variant.ID, "Created variant: "+product.Name+" - "+variant.Name)
+
+	variant.AvailableStock = variant.Stock - variant.ReservedQty
+	return &variant, nil
+}
+
+// UpdateVariant updates an edition; stock edits are logged as manual adjustments
+func (s *ProductService) UpdateVariant(productID, variantID uint, input VariantInput, currentUserID uint) (*models.ProductVariant, error) {
+	var variant models.ProductVariant
+	if err := s.DB.Where("id = ? AND product_id = ?", variantID, productID).First(&variant).Error; err != nil {
+		return nil, fmt.Errorf("variant not found")
+	}
+	if input.Price < 0 {
+		return nil, fmt.Errorf("price cannot be negative")
+	}
+
+	oldStock := variant.Stock
+
+	if input.Name != "" {
+		variant.Name = input.Name
+	}
+	if input.SKU != "" && input.SKU != variant.SKU {
+		if err := s.checkSKUAvailable(input.SKU, variant.ID); err != nil {
+			return nil, err
+		}
+		variant.SKU = input.SKU
+	}
+	if input.Price > 0 {
+		variant.Price = input.Price
+	}
+	variant.SupplierCost = input.SupplierCost
+	if input.Stock != nil {
+		if *input.Stock < variant.ReservedQty {
+			return nil, fmt.Errorf("stok tidak boleh kurang dari jumlah yang sudah dipesan (%d)", variant.ReservedQty)
+		}
+		variant.Stock = *input.Stock
+	}
+	variant.Weight = input.Weight
+	if input.Images != nil {
+		images, _ := json.Marshal(input.Images)
+		variant.Images = datatypes.JSON(images)
+	}
+	if input.Status != "" {
+		variant.Status = input.Status
+	}
+	variant.DisplayOrder = input.DisplayOrder
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if oldStock != variant.Stock {
+			diff := variant.Stock - oldStock
+			helpers.RecordLocationStockMovement(tx, 0, productID, &variant.ID, diff, "physical", "adjustment", "MANUAL", "UPDATE", "Manual adjustment via dashboard", &currentUserID)
+		}
+		if err := tx.Save(&variant).Error; err != nil {
+			return err
+		}
+		return helpers.SyncVariantTotals(tx, productID, &variant.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(currentUserID, "ProductVariant", "UPDATE", variant.ID, "Updated variant: "+variant.Name)
+
+	variant.AvailableStock = variant.Stock - variant.ReservedQty
+	return &variant, nil
+}
+
+// DeleteVariant removes an edition that has no open reservations
+func (s *ProductService) DeleteVariant(productID, variantID uint, currentUserID uint) error {
+	var variant models.ProductVariant
+	if err := s.DB.Where("id = ? AND product_id = ?", variantID, productID).First(&variant).Error; err != nil {
+		return fmt.Errorf("variant not found")
+	}
+	if variant.ReservedQty > 0 {
+		return fmt.Errorf("varian masih memiliki %d stok yang dipesan", variant.ReservedQty)
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&variant).Error; err != nil {
+			return err
+		}
+		return helpers.SyncVariantTotals(tx, productID, &variant.ID)
+	})
+	if err != nil {
+		return err
+	}
+
+	helpers.LogAuditSimple(currentUserID, "ProductVariant", "DELETE", variant.ID, "Deleted variant: "+variant.Name)
+	return nil
+}
+
 // Internal Helpers
 
+// checkSKUAvailable ensures a SKU isn't used by any product or other edition
+func (s *ProductService) checkSKUAvailable(sku string, exceptVariantID uint) error {
+	var count int64
+	s.DB.Model(&models.Product{}).Where("sku = ?", sku).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("sku already exists")
+	}
+	s.DB.Model(&models.ProductVariant{}).Where("sku = ? AND id != ?", sku, exceptVariantID).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("sku already exists")
+	}
+	return nil
+}
+
 func (s *ProductService) validatePOConfig(config map[string]interface{}, price float64) error {
 	if config == nil {
 		return fmt.Errorf("PO Config is required for Pre-Order")
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// ===============================================
+// PURCHASE ORDER DOCUMENT
+// The PO as the supplier sees it: HTML (email and signed link page) and PDF.
+// ===============================================
+
+// PODocument is everything printed on a purchase order
+type PODocument struct {
+	CompanyName    string
+	CompanyAddress string
+	CompanyEmail   string
+	CompanyPhone   string
+	CompanyNPWP    string
+
+	PONumber       string
+	Status         string
+	Date           time.Time
+	ExpectedDate   *time.Time
+	Supplier       models.Supplier
+	Lines          []PODocumentLine
+	Total          float64
+	Notes          string
+	ApprovedBy     string
+	AcknowledgedAt *time.Time
+	SupplierNote   string
+
+	Link  string // Signed supplier link (view, PDF, acknowledge); empty on admin downloads
+	Token string // Set on the supplier page, for the acknowledge form
+}
+
+type PODocumentLine struct {
+	Name     string
+	SKU      string
+	Quantity int
+	UnitCost float64
+	Total    float64
+}
+
+// Document assembles the printable PO
+func (s *PurchaseOrderService) Document(id uint) (*PODocument, error) {
+	var po models.PurchaseOrder
+	if err := s.DB.Preload("Supplier").Preload("Items.Product").Preload("Items.Variant").First(&po, id).Error; err != nil {
+		return nil, fmt.Errorf("PO not found")
+	}
+
+	doc := &PODocument{
+		CompanyName:    helpers.GetSetting("company_name", "Warung Forza"),
+		CompanyAddress: helpers.GetSetting("company_address", ""),
+		CompanyEmail:   helpers.GetSetting("company_email", ""),
+		CompanyPhone:   helpers.GetSetting("company_phone", ""),
+		CompanyNPWP:    helpers.GetSetting("company_npwp", ""),
+		PONumber:       po.PONumber,
+		Status:         po.Status,
+		Date:           po.CreatedAt,
+		ExpectedDate:   po.ExpectedDate,
+		Supplier:       po.Supplier,
+		Total:          po.TotalAmount,
+		Notes:          po.Notes,
+		AcknowledgedAt: po.AcknowledgedAt,
+		SupplierNote:   po.SupplierNote,
+	}
+	if po.OrderedAt != nil {
+		doc.Date = *po.OrderedAt
+	}
+	if po.ApprovedBy != nil {
+		var approver models.User
+		if s.DB.First(&approver, *po.ApprovedBy).Error == nil {
+			doc.ApprovedBy = approver.FullName
+			if doc.ApprovedBy == "" {
+				doc.ApprovedBy = approver.Username
+			}
+		}
+	}
+	for _, item := range po.Items {
+		line := PODocumentLine{
+			Name:     item.Product.Name,
+			SKU:      item.Product.SKU,
+			Quantity: item.Quantity,
+			UnitCost: item.UnitCost,
+			Total:    item.TotalCost,
+		}
+		if item.Variant != nil {
+			line.Name += " - " + item.Variant.Name
+			line.SKU = item.Variant.SKU
+		}
+		doc.Lines = append(doc.Lines, line)
+	}
+	return doc, nil
+}
+
+func rupiah(amount float64) string {
+	return "Rp " + helpers.FormatPrice(amount)
+}
+
+func docDate(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format("02 Jan 2006")
+}
+
+var poTemplateFuncs = template.FuncMap{
+	"rupiah": rupiah,
+	"date":   func(t time.Time) string { return t.Format("02 Jan 2006") },
+	"datep":  docDate,
+	"add":    func(a, b int) int { return a + b },
+}
+
+// poTableTpl is the PO body shared by the email and the supplier page
+const poTableTpl = `{{define "po"}}
+<table width="100%" cellpadding="0" cellspacing="0" style="font-family:Arial,sans-serif;font-size:13px;color:#111;">
+  <tr>
+    <td valign="top">
+      <div style="font-size:18px;font-weight:bold;">{{.CompanyName}}</div>
+      <div>{{.CompanyAddress}}</div>
+      <div>{{.CompanyEmail}} {{.CompanyPhone}}</div>
+      {{if .CompanyNPWP}}<div>NPWP: {{.CompanyNPWP}}</div>{{end}}
+    </td>
+    <td valign="top" align="right">
+      <div style="font-size:18px;font-weight:bold;">PURCHASE ORDER</div>
+      <div>No: <b>{{.PONumber}}</b></div>
+      <div>Tanggal: {{date .Date}}</div>
+      <div>Estimasi Tiba: {{datep .ExpectedDate}}</div>
+    </td>
+  </tr>
+</table>
+<div style="margin:16px 0;font-family:Arial,sans-serif;font-size:13px;">
+  <b>Kepada:</b> {{.Supplier.Name}}<br>
+  {{if .Supplier.Contact}}{{.Supplier.Contact}}<br>{{end}}
+  {{if .Supplier.Address}}{{.Supplier.Address}}<br>{{end}}
+  {{.Supplier.Email}} {{.Supplier.Phone}}
+</div>
+<table width="100%" cellpadding="6" cellspacing="0" style="border-collapse:collapse;font-family:Arial,sans-serif;font-size:13px;">
+  <tr style="background:#f3f4f6;">
+    <th align="left" style="border:1px solid #ddd;">#</th>
+    <th align="left" style="border:1px solid #ddd;">Barang</th>
+    <th align="left" style="border:1px solid #ddd;">SKU</th>
+    <th align="right" style="border:1px solid #ddd;">Qty</th>
+    <th align="right" style="border:1px solid #ddd;">Harga</th>
+    <th align="right" style="border:1px solid #ddd;">Jumlah</th>
+  </tr>
+  {{range $i, $l := .Lines}}
+  <tr>
+    <td style="border:1px solid #ddd;">{{add $i 1}}</td>
+    <td style="border:1px solid #ddd;">{{$l.Name}}</td>
+    <td style="border:1px solid #ddd;">{{$l.SKU}}</td>
+    <td align="right" style="border:1px solid #ddd;">{{$l.Quantity}}</td>
+    <td align="right" style="border:1px solid #ddd;">{{rupiah $l.UnitCost}}</td>
+    <td align="right" style="border:1px solid #ddd;">{{rupiah $l.Total}}</td>
+  </tr>
+  {{end}}
+  <tr>
+    <td colspan="5" align="right" style="border:1px solid #ddd;"><b>Total</b></td>
+    <td align="right" style="border:1px solid #ddd;"><b>{{rupiah .Total}}</b></td>
+  </tr>
+</table>
+{{if .Notes}}<p style="font-family:Arial,sans-serif;font-size:13px;"><b>Catatan:</b> {{.Notes}}</p>{{end}}
+{{if .ApprovedBy}}<p style="font-family:Arial,sans-serif;font-size:13px;">Disetujui oleh: {{.ApprovedBy}}</p>{{end}}
+{{end}}`
+
+var poEmailTemplate = template.Must(template.New("email").Funcs(poTemplateFuncs).Parse(poTableTpl + `
+<p>Dengan hormat,</p>
+<p>Berikut Purchase Order <b>{{.PONumber}}</b> dari {{.CompanyName}}.</p>
+{{template "po" .}}
+{{if .Link}}
+<p style="margin-top:24px;">
+  <a href="{{.Link}}" style="background:#111;color:#fff;padding:10px 18px;text-decoration:none;border-radius:4px;">Lihat &amp; Konfirmasi PO</a>
+  &nbsp; <a href="{{.Link}}/pdf">Unduh PDF</a>
+</p>
+{{end}}`))
+
+var poPageTemplate = template.Must(template.New("page").Funcs(poTemplateFuncs).Parse(poTableTpl + `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Purchase Order {{.PONumber}}</title>
+<meta name="viewport" content="width=device-width, initial-scale=1"></head>
+<body style="max-width:860px;margin:24px auto;padding:0 16px;">
+{{template "po" .}}
+{{if .Token}}
+<p style="font-family:Arial,sans-serif;font-size:13px;"><a href="{{.Token}}/pdf">Unduh PDF</a></p>
+{{if .AcknowledgedAt}}
+<p style="font-family:Arial,sans-serif;font-size:13px;color:#15803d;">Dikonfirmasi pada {{datep .AcknowledgedAt}}{{if .SupplierNote}}: {{.SupplierNote}}{{end}}</p>
+{{end}}
+{{if or (eq .Status "ordered") (eq .Status "partial")}}
+<form method="post" action="{{.Token}}/acknowledge" style="font-family:Arial,sans-serif;font-size:13px;border-top:1px solid #ddd;padding-top:16px;">
+  <p><b>Konfirmasi pesanan</b></p>
+  <p>Estimasi tanggal kirim/tiba: <input type="date" name="expected_date"></p>
+  <p>Catatan:<br><textarea name="note" rows="3" style="width:100%;"></textarea></p>
+  <button type="submit">Konfirmasi PO</button>
+</form>
+{{end}}
+{{end}}
+</body></html>`))
+
+// RenderPOEmail renders the email sent to the supplier, inside the shop email layout
+func RenderPOEmail(doc *PODocument) (string, error) {
+	var buf bytes.Buffer
+	if err := poEmailTemplate.Execute(&buf, doc); err != nil {
+		return "", err
+	}
+	shopName := helpers.GetSetting("shop_name", doc.CompanyName)
+	accent := helpers.GetSetting("theme_accent_color", "#e11d48")
+	return helpers.DefaultEmailLayout("Purchase Order "+doc.PONumber, shopName, accent, buf.String()), nil
+}
+
+// RenderPOHTML renders the PO as a standalone page (with the acknowledge form when Token is set)
+func RenderPOHTML(doc *PODocument, w io.Writer) error {
+	return poPageTemplate.Execute(w, doc)
+}
+
+// RenderPOPDF writes the PO as an A4 PDF
+func RenderPOPDF(doc *PODocument, w io.Writer) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf("%s - %d/{nb}", doc.PONumber, pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// Header: company left, PO block right
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(100, 7, tr(doc.CompanyName), "", 0, "L", false, 0, "")
+	pdf.CellFormat(80, 7, "PURCHASE ORDER", "", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	right := []string{
+		"No: " + doc.PONumber,
+		"Tanggal: " + doc.Date.Format("02 Jan 2006"),
+		"Estimasi Tiba: " + docDate(doc.ExpectedDate),
+	}
+	left := []string{doc.CompanyAddress, strings.TrimSpace(doc.CompanyEmail + " " + doc.CompanyPhone)}
+	if doc.CompanyNPWP != "" {
+		left = append(left, "NPWP: "+doc.CompanyNPWP)
+	}
+	for i := 0; i < max(len(left), len(right)); i++ {
+		l, r := "", ""
+		if i < len(left) {
+			l = left[i]
+		}
+		if i < len(right) {
+			r = right[i]
+		}
+		pdf.CellFormat(100, 5, tr(l), "", 0, "L", false, 0, "")
+		pdf.CellFormat(80, 5, tr(r), "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(5)
+
+	// Supplier
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 6, "Kepada:", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	for _, l := range []string{doc.Supplier.Name, doc.Supplier.Contact, doc.Supplier.Address, strings.TrimSpace(doc.Supplier.Email + " " + doc.Supplier.Phone)} {
+		if l != "" {
+			pdf.MultiCell(0, 5, tr(l), "", "L", false)
+		}
+	}
+	pdf.Ln(4)
+
+	// Lines
+	widths := []float64{8, 72, 30, 14, 28, 28}
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(243, 244, 246)
+	for i, h := range []string{"#", "Barang", "SKU", "Qty", "Harga", "Jumlah"} {
+		align := "L"
+		if i >= 3 {
+			align = "R"
+		}
+		pdf.CellFormat(widths[i], 7, h, "1", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 9)
+	for i, l := range doc.Lines {
+		name := tr(l.Name)
+		if pdf.GetStringWidth(name) > widths[1]-2 {
+			for len(name) > 0 && pdf.GetStringWidth(name+"...") > widths[1]-2 {
+				name = name[:len(name)-1]
+			}
+			name += "..."
+		}
+		pdf.CellFormat(widths[0], 6, fmt.Sprint(i+1), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 6, name, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 6, tr(l.SKU), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[3], 6, fmt.Sprint(l.Quantity), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 6, rupiah(l.UnitCost), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[5], 6, rupiah(l.Total), "1", 1, "R", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.CellFormat(widths[0]+widths[1]+widths[2]+widths[3]+widths[4], 7, "Total", "1", 0, "R", false, 0, "")
+	pdf.CellFormat(widths[5], 7, rupiah(doc.Total), "1", 1, "R", false, 0, "")
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "", 9)
+	if doc.Notes != "" {
+		pdf.MultiCell(0, 5, tr("Catatan: "+doc.Notes), "", "L", false)
+	}
+	if doc.ApprovedBy != "" {
+		pdf.MultiCell(0, 5, tr("Disetujui oleh: "+doc.ApprovedBy), "", "L", false)
+	}
+
+	return pdf.Output(w)
+}
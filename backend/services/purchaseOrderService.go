@@ -0,0 +1,445 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===============================================
+// PURCHASE ORDER LIFECYCLE
+// draft -> pending_approval -> approved -> ordered (emailed to the supplier, who acknowledges through
+// a signed link) -> partial / received. Each role may approve POs up to its POApprovalLimit, and
+// never the POs its user created or submitted.
+// Ordered quantities and expected dates feed Product.IncomingStock and the pre-order ETA.
+// ===============================================
+
+// ErrApprovalLimit is returned when a PO total is above what the user's role may approve
+var ErrApprovalLimit = errors.New("PO total is above your approval limit")
+
+// ErrSelfApproval is returned when the creator or submitter of a PO tries to approve it
+var ErrSelfApproval = errors.New("PO harus disetujui oleh pengguna lain selain pembuat / pengaju")
+
+type PurchaseOrderService struct {
+	DB *gorm.DB
+}
+
+func NewPurchaseOrderService() *PurchaseOrderService {
+	return &PurchaseOrderService{
+		DB: config.DB,
+	}
+}
+
+// lockPO loads a PO with its items and checks it is in one of the expected statuses
+func (s *PurchaseOrderService) lockPO(tx *gorm.DB, id uint, statuses ...string) (*models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&po, id).Error; err != nil {
+		return nil, fmt.Errorf("PO not found")
+	}
+	for _, status := range statuses {
+		if po.Status == status {
+			return &po, nil
+		}
+	}
+	return nil, fmt.Errorf("PO %s berstatus %s", po.PONumber, po.Status)
+}
+
+// approvalLimit returns the largest PO total the user may approve; unlimited for super admins
+func approvalLimit(tx *gorm.DB, userID uint) (limit float64, unlimited bool) {
+	var user models.User
+	if err := tx.Preload("Role").First(&user, userID).Error; err != nil {
+		return 0, false
+	}
+	if user.Role.Slug == models.RoleSuperAdmin {
+		return 0, true
+	}
+	return user.Role.POApprovalLimit, false
+}
+
+// canApprove reports whether the user may approve the PO total
+func canApprove(tx *gorm.DB, userID uint, total float64) bool {
+	limit, unlimited := approvalLimit(tx, userID)
+	return withinApprovalLimit(limit, unlimited, total)
+}
+
+// withinApprovalLimit: a zero limit means the role cannot approve POs at all
+func withinApprovalLimit(limit float64, unlimited bool, total float64) bool {
+	return unlimited || (limit > 0 && total <= limit)
+}
+
+// isOwnPO reports whether the user created or submitted the PO
+func isOwnPO(po *models.PurchaseOrder, userID uint) bool {
+	return (po.CreatedBy != nil && *po.CreatedBy == userID) || (po.SubmittedBy != nil && *po.SubmittedBy == userID)
+}
+
+// approve marks a locked PO approved at its current total
+func (s *PurchaseOrderService) approve(tx *gorm.DB, po *models.PurchaseOrder, userID uint) error {
+	if len(po.Items) == 0 || po.TotalAmount <= 0 {
+		return fmt.Errorf("PO %s belum memiliki item", po.PONumber)
+	}
+	if isOwnPO(po, userID) {
+		return fmt.Errorf("%w (PO %s)", ErrSelfApproval, po.PONumber)
+	}
+	if !canApprove(tx, userID, po.TotalAmount) {
+		return fmt.Errorf("%w (PO %s, Rp %s)", ErrApprovalLimit, po.PONumber, helpers.FormatPrice(po.TotalAmount))
+	}
+
+	now := time.Now()
+	po.Status = models.POApproved
+	po.ApprovedBy = &userID
+	po.ApprovedAt = &now
+	po.ApprovedAmount = po.TotalAmount
+	po.RejectReason = ""
+	return tx.Model(po).Updates(map[string]interface{}{
+		"status":          po.Status,
+		"approved_by":     userID,
+		"approved_at":     now,
+		"approved_amount": po.ApprovedAmount,
+		"reject_reason":   "",
+	}).Error
+}
+
+// Submit asks for approval; another user whose role covers the total has to approve it
+func (s *PurchaseOrderService) Submit(id uint, userID uint) (*models.PurchaseOrder, error) {
+	var po *models.PurchaseOrder
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = s.lockPO(tx, id, models.PODraft); err != nil {
+			return err
+		}
+		if len(po.Items) == 0 || po.TotalAmount <= 0 {
+			return fmt.Errorf("PO %s belum memiliki item", po.PONumber)
+		}
+
+		now := time.Now()
+		po.Status = models.POPendingApproval
+		po.SubmittedBy = &userID
+		po.SubmittedAt = &now
+		return tx.Model(po).Updates(map[string]interface{}{"status": po.Status, "submitted_by": userID, "submitted_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "procurement", "UPDATE", po.ID, "Submitted PO "+po.PONumber)
+	helpers.NotifyAdmin("PO_APPROVAL", "PO Menunggu Persetujuan: "+po.PONumber, map[string]interface{}{
+		"purchase_order_id": po.ID,
+		"po_number":         po.PONumber,
+		"total_amount":      po.TotalAmount,
+	})
+	return po, nil
+}
+
+// Approve approves a draft or submitted PO within the user's limit
+func (s *PurchaseOrderService) Approve(id uint, userID uint) (*models.PurchaseOrder, error) {
+	var po *models.PurchaseOrder
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = s.lockPO(tx, id, models.PODraft, models.POPendingApproval); err != nil {
+			return err
+		}
+		return s.approve(tx, po, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "procurement", "APPROVE", po.ID, fmt.Sprintf("Approved PO %s (Rp %s)", po.PONumber, helpers.FormatPrice(po.ApprovedAmount)))
+	return po, nil
+}
+
+// Reject sends a submitted PO back to draft
+func (s *PurchaseOrderService) Reject(id uint, reason string, userID uint) (*models.PurchaseOrder, error) {
+	var po *models.PurchaseOrder
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = s.lockPO(tx, id, models.POPendingApproval); err != nil {
+			return err
+		}
+		po.Status = models.PODraft
+		po.RejectReason = reason
+		return tx.Model(po).Updates(map[string]interface{}{"status": po.Status, "reject_reason": reason}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "procurement", "UPDATE", po.ID, "Rejected PO "+po.PONumber+": "+reason)
+	return po, nil
+}
+
+// Send emails the PO document to the supplier with the acknowledgement link. The first send places
+// the order: status ordered, expected date from the supplier's lead time unless one was set. The order
+// is committed before the email goes out; a failed email leaves it ordered but unsent, to be resent.
+func (s *PurchaseOrderService) Send(id uint, to string, userID uint) (*models.PurchaseOrder, error) {
+	var po *models.PurchaseOrder
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = s.lockPO(tx, id, models.POApproved, models.POOrdered, models.POPartial); err != nil {
+			return err
+		}
+		var supplier models.Supplier
+		if err := tx.First(&supplier, po.SupplierID).Error; err != nil {
+			return fmt.Errorf("supplier not found")
+		}
+		if to == "" {
+			to = supplier.Email
+		}
+		if to == "" {
+			return fmt.Errorf("supplier %s belum memiliki email", supplier.Name)
+		}
+		if po.Status != models.POApproved {
+			return nil
+		}
+
+		now := time.Now()
+		po.Status = models.POOrdered
+		po.OrderedAt = &now
+		updates := map[string]interface{}{"status": po.Status, "ordered_at": now}
+		if po.ExpectedDate == nil {
+			lead := supplier.LeadTimeDays
+			if lead <= 0 {
+				lead = loadReorderSettings().DefaultLeadDays
+			}
+			expected := startOfDay(now).AddDate(0, 0, lead)
+			po.ExpectedDate = &expected
+			updates["expected_date"] = expected
+		}
+		if err := tx.Model(po).Updates(updates).Error; err != nil {
+			return err
+		}
+		return syncIncoming(tx, poProductIDs(po))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := s.Document(po.ID)
+	if err != nil {
+		return po, err
+	}
+	token, err := helpers.SignSupplierPOToken(po.ID, po.SupplierID, helpers.SupplierPOTokenTTL())
+	if err != nil {
+		return po, err
+	}
+	doc.Link = helpers.GetAppURL() + "/api/supplier/po/" + token
+	body, err := RenderPOEmail(doc)
+	if err != nil {
+		return po, err
+	}
+	if err := helpers.SendEmail(to, fmt.Sprintf("Purchase Order %s - %s", po.PONumber, doc.CompanyName), body); err != nil {
+		helpers.LogAuditSimple(userID, "procurement", "UPDATE", po.ID, "PO "+po.PONumber+" ordered, email to "+to+" failed: "+err.Error())
+		return po, fmt.Errorf("PO %s berstatus %s, tetapi email ke %s gagal dikirim: %v", po.PONumber, po.Status, to, err)
+	}
+
+	now := time.Now()
+	po.SentAt = &now
+	po.SentTo = to
+	s.DB.Model(po).Updates(map[string]interface{}{"sent_at": now, "sent_to": to})
+
+	helpers.LogAuditSimple(userID, "procurement", "UPDATE", po.ID, "Sent PO "+po.PONumber+" to "+po.SentTo)
+	return po, nil
+}
+
+// AcknowledgeInput is the supplier's confirmation
+type AcknowledgeInput struct {
+	ExpectedDate *time.Time // Delivery date promised by the supplier (optional)
+	Note         string
+}
+
+// Acknowledge records the supplier's confirmation from the signed link
+func (s *PurchaseOrderService) Acknowledge(token string, in AcknowledgeInput) (*models.PurchaseOrder, error) {
+	claims, err := helpers.ParseSupplierPOToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var po *models.PurchaseOrder
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = s.lockPO(tx, claims.POID, models.POOrdered, models.POPartial); err != nil {
+			return err
+		}
+		if po.SupplierID != claims.SupplierID {
+			return fmt.Errorf("invalid or expired link")
+		}
+
+		now := time.Now()
+		po.AcknowledgedAt = &now
+		po.SupplierNote = in.Note
+		updates := map[string]interface{}{"acknowledged_at": now, "supplier_note": in.Note}
+		if in.ExpectedDate != nil {
+			expected := startOfDay(*in.ExpectedDate)
+			po.ExpectedDate = &expected
+			updates["expected_date"] = expected
+		}
+		if err := tx.Model(po).Updates(updates).Error; err != nil {
+			return err
+		}
+		return syncIncoming(tx, poProductIDs(po))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(0, "procurement", "UPDATE", po.ID, "Supplier acknowledged PO "+po.PONumber)
+	helpers.NotifyAdmin("PO_ACKNOWLEDGED", "Supplier Mengonfirmasi PO "+po.PONumber, map[string]interface{}{
+		"purchase_order_id": po.ID,
+		"po_number":         po.PONumber,
+		"expected_date":     po.ExpectedDate,
+		"note":              po.SupplierNote,
+	})
+	return po, nil
+}
+
+// SetExpectedDate moves the expected delivery of an open PO
+func (s *PurchaseOrderService) SetExpectedDate(id uint, date time.Time, userID uint) (*models.PurchaseOrder, error) {
+	var po *models.PurchaseOrder
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = s.lockPO(tx, id, models.PODraft, models.POPendingApproval, models.POApproved, models.POOrdered, models.POPartial); err != nil {
+			return err
+		}
+		expected := startOfDay(date)
+		po.ExpectedDate = &expected
+		if err := tx.Model(po).Update("expected_date", expected).Error; err != nil {
+			return err
+		}
+		return syncIncoming(tx, poProductIDs(po))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "procurement", "UPDATE", po.ID, fmt.Sprintf("PO %s expected on %s", po.PONumber, po.ExpectedDate.Format("2006-01-02")))
+	return po, nil
+}
+
+// Cancel drops a PO nothing was received on yet
+func (s *PurchaseOrderService) Cancel(id uint, userID uint) (*models.PurchaseOrder, error) {
+	var po *models.PurchaseOrder
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if po, err = s.lockPO(tx, id, models.PODraft, models.POPendingApproval, models.POApproved, models.POOrdered); err != nil {
+			return err
+		}
+		po.Status = models.POCancelled
+		if err := tx.Model(po).Update("status", po.Status).Error; err != nil {
+			return err
+		}
+		return syncIncoming(tx, poProductIDs(po))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "procurement", "UPDATE", po.ID, "Cancelled PO "+po.PONumber)
+	return po, nil
+}
+
+// PrepareEdit applies the approval rules to a PO whose lines are being replaced: a submitted or
+// approved PO goes back to draft for a fresh approval, an ordered one may not exceed what was approved
+func PrepareEdit(po *models.PurchaseOrder, total float64) error {
+	switch po.Status {
+	case models.POPendingApproval, models.POApproved:
+		po.Status = models.PODraft
+		po.SubmittedBy, po.SubmittedAt = nil, nil
+		po.ApprovedBy, po.ApprovedAt = nil, nil
+		po.ApprovedAmount = 0
+	case models.POOrdered, models.POPartial:
+		if total > po.ApprovedAmount && po.ApprovedAmount > 0 {
+			return fmt.Errorf("total PO melebihi jumlah yang disetujui (Rp %s)", helpers.FormatPrice(po.ApprovedAmount))
+		}
+	}
+	return nil
+}
+
+// SyncIncoming recomputes incoming stock and pre-order ETAs after POs were edited outside the service
+func SyncIncoming(tx *gorm.DB, productIDs []uint) error {
+	return syncIncoming(tx, productIDs)
+}
+
+// Overdue returns the open POs past their expected date, oldest first
+func (s *PurchaseOrderService) Overdue() ([]models.PurchaseOrder, error) {
+	var pos []models.PurchaseOrder
+	err := s.DB.Preload("Supplier").
+		Where("status IN ? AND expected_date < ?", []string{models.POOrdered, models.POPartial}, startOfDay(time.Now())).
+		Order("expected_date ASC").Find(&pos).Error
+	return pos, err
+}
+
+// NotifyOverdue tells admins which POs are late; returns how many there are
+func (s *PurchaseOrderService) NotifyOverdue() (int, error) {
+	pos, err := s.Overdue()
+	if err != nil || len(pos) == 0 {
+		return 0, err
+	}
+	list := make([]map[string]interface{}, 0, len(pos))
+	for _, po := range pos {
+		list = append(list, map[string]interface{}{
+			"purchase_order_id": po.ID,
+			"po_number":         po.PONumber,
+			"supplier":          po.Supplier.Name,
+			"expected_date":     po.ExpectedDate.Format("2006-01-02"),
+		})
+	}
+	helpers.NotifyAdmin("PO_OVERDUE", fmt.Sprintf("%d PO Melewati Tanggal Kedatangan", len(pos)), map[string]interface{}{"orders": list})
+	return len(pos), nil
+}
+
+// poProductIDs lists the products on a PO
+func poProductIDs(po *models.PurchaseOrder) []uint {
+	ids := make([]uint, 0, len(po.Items))
+	for _, item := range po.Items {
+		ids = append(ids, item.ProductID)
+	}
+	return ids
+}
+
+// syncIncoming recomputes Product.IncomingStock from what is still to arrive on ordered POs, and
+// moves the ETA of pre-order products to their earliest expected delivery
+func syncIncoming(tx *gorm.DB, productIDs []uint) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+	open := []string{models.POOrdered, models.POPartial}
+
+	remaining := tx.Table("purchase_order_items").
+		Select("COALESCE(SUM(GREATEST(purchase_order_items.quantity - purchase_order_items.received_qty, 0)), 0)").
+		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id").
+		Where("purchase_order_items.product_id = products.id AND purchase_orders.status IN ?", open)
+	if err := tx.Model(&models.Product{}).Where("id IN ?", productIDs).
+		UpdateColumn("incoming_stock", gorm.Expr("(?)", remaining)).Error; err != nil {
+		return err
+	}
+
+	var etas []struct {
+		ProductID uint
+		ETA       time.Time
+	}
+	if err := tx.Table("purchase_order_items").
+		Select("purchase_order_items.product_id, MIN(purchase_orders.expected_date) AS eta").
+		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id").
+		Joins("JOIN products ON products.id = purchase_order_items.product_id").
+		Where("purchase_order_items.product_id IN ? AND products.product_type = ?", productIDs, "po").
+		Where("purchase_orders.status IN ? AND purchase_orders.expected_date IS NOT NULL", open).
+		Where("purchase_order_items.quantity > purchase_order_items.received_qty").
+		Group("purchase_order_items.product_id").
+		Scan(&etas).Error; err != nil {
+		return err
+	}
+	for _, row := range etas {
+		if err := tx.Exec(`UPDATE products SET po_config = jsonb_set(CASE WHEN jsonb_typeof(po_config) = 'object' THEN po_config ELSE '{}'::jsonb END, '{eta}', to_jsonb(?::text)) WHERE id = ?`,
+			row.ETA.Format("2006-01-02"), row.ProductID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
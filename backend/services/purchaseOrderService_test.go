@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+
+	"forzashop/backend/models"
+)
+
+func TestWithinApprovalLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     float64
+		unlimited bool
+		total     float64
+		want      bool
+	}{
+		{"unlimited", 0, true, 1e9, true},
+		{"under the limit", 5000000, false, 4999999, true},
+		{"at the limit", 5000000, false, 5000000, true},
+		{"over the limit", 5000000, false, 5000001, false},
+		{"no limit set", 0, false, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withinApprovalLimit(tt.limit, tt.unlimited, tt.total); got != tt.want {
+				t.Errorf("withinApprovalLimit(%v, %v, %v) = %v, want %v", tt.limit, tt.unlimited, tt.total, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOwnPO(t *testing.T) {
+	id := func(v uint) *uint { return &v }
+
+	tests := []struct {
+		name string
+		po   models.PurchaseOrder
+		user uint
+		want bool
+	}{
+		{"creator", models.PurchaseOrder{CreatedBy: id(1), SubmittedBy: id(2)}, 1, true},
+		{"submitter", models.PurchaseOrder{CreatedBy: id(1), SubmittedBy: id(2)}, 2, true},
+		{"someone else", models.PurchaseOrder{CreatedBy: id(1), SubmittedBy: id(2)}, 3, false},
+		{"no author recorded", models.PurchaseOrder{}, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isOwnPO(&tt.po, tt.user); got != tt.want {
+				t.Errorf("isOwnPO() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===============================================
+// PURCHASE RECEIVING (partial receipts, landed cost, moving average cost)
+// ===============================================
+
+type PurchaseReceiptService struct {
+	DB *gorm.DB
+}
+
+func NewPurchaseReceiptService() *PurchaseReceiptService {
+	return &PurchaseReceiptService{
+		DB: config.DB,
+	}
+}
+
+// ReceiveLine is the quantity received on one PO line
+type ReceiveLine struct {
+	ItemID   uint `json:"item_id" binding:"required"` // PurchaseOrderItem.ID
+	Quantity int  `json:"quantity" binding:"required,gt=0"`
+}
+
+// ReceiveInput describes a delivery; no Items receives everything still outstanding
+type ReceiveInput struct {
+	Items            []ReceiveLine `json:"items"`
+	Freight          float64       `json:"freight" binding:"min=0"`
+	Customs          float64       `json:"customs" binding:"min=0"`
+	ImportDuty       float64       `json:"import_duty" binding:"min=0"`
+	AllocationMethod string        `json:"allocation_method"`  // value (default), quantity
+	LandedCostCOAID  *uint         `json:"landed_cost_coa_id"` // Default: primary bank (paid directly)
+	LocationID       uint          `json:"location_id"`        // Where the goods arrive, 0 = default location
+	Notes            string        `json:"notes"`
+}
+
+// openOrderStatuses - unshipped orders whose lines may still be missing a COGS snapshot
+var openOrderStatuses = []string{"pre_order", "pending", "processing", "payment_due"}
+
+// Receive books a delivery against a PO: stock in (with StockMovement), landed cost spread over the lines,
+// moving average cost updated, Debit inventory / Credit AP (+ landed cost account), and a supplier bill.
+func (s *PurchaseReceiptService) Receive(poID uint, in ReceiveInput, userID uint) (*models.PurchaseReceipt, *models.SupplierBill, error) {
+	if in.AllocationMethod == "" {
+		in.AllocationMethod = models.AllocateByValue
+	}
+	if in.AllocationMethod != models.AllocateByValue && in.AllocationMethod != models.AllocateByQuantity {
+		return nil, nil, fmt.Errorf("allocation_method must be value or quantity")
+	}
+
+	var receipt models.PurchaseReceipt
+	var bill *models.SupplierBill
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var po models.PurchaseOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&po, poID).Error; err != nil {
+			return fmt.Errorf("PO not found")
+		}
+		switch po.Status {
+		case "received":
+			return fmt.Errorf("PO already received")
+		case "cancelled":
+			return fmt.Errorf("PO is cancelled")
+		case models.PODraft, models.POPendingApproval:
+			return fmt.Errorf("PO belum disetujui")
+		}
+
+		lines, err := receiveLines(po, in.Items)
+		if err != nil {
+			return err
+		}
+
+		locationID, err := helpers.ResolveLocationID(tx, in.LocationID)
+		if err != nil {
+			return err
+		}
+		number, err := helpers.NextSequenceNumber(tx, helpers.SeqGoodsReceipt)
+		if err != nil {
+			return err
+		}
+		receipt = models.PurchaseReceipt{
+			ReceiptNumber:    number,
+			PurchaseOrderID:  po.ID,
+			LocationID:       locationID,
+			ReceivedAt:       time.Now(),
+			Freight:          roundMoney(in.Freight),
+			Customs:          roundMoney(in.Customs),
+			ImportDuty:       roundMoney(in.ImportDuty),
+			AllocationMethod: in.AllocationMethod,
+			Notes:            in.Notes,
+			ReceivedBy:       userID,
+		}
+		receipt.LandedCost = roundMoney(receipt.Freight + receipt.Customs + receipt.ImportDuty)
+
+		for _, l := range lines {
+			receipt.Items = append(receipt.Items, models.PurchaseReceiptItem{
+				PurchaseOrderItemID: l.item.ID,
+				ProductID:           l.item.ProductID,
+				VariantID:           l.item.VariantID,
+				Quantity:            l.qty,
+				UnitCost:            l.item.UnitCost,
+			})
+			receipt.GoodsValue += float64(l.qty) * l.item.UnitCost
+		}
+		receipt.GoodsValue = roundMoney(receipt.GoodsValue)
+		allocateLandedCost(receipt.Items, receipt.LandedCost, in.AllocationMethod)
+
+		// Stock and average cost per line; stock is read under lock so the average uses what was really on hand
+		for i := range receipt.Items {
+			if err := s.receiveItem(tx, &receipt.Items[i], locationID, number, userID); err != nil {
+				return err
+			}
+		}
+
+		if err := s.postJournal(tx, &receipt, po, in.LandedCostCOAID); err != nil {
+			return err
+		}
+		// receipt.Items are created together with the header
+		if err := tx.Create(&receipt).Error; err != nil {
+			return err
+		}
+
+		// PO lines and status
+		complete := true
+		for _, item := range po.Items {
+			received := item.ReceivedQty
+			for _, l := range lines {
+				if l.item.ID == item.ID {
+					received += l.qty
+				}
+			}
+			if received != item.ReceivedQty {
+				if err := tx.Model(&models.PurchaseOrderItem{}).Where("id = ?", item.ID).Update("received_qty", received).Error; err != nil {
+					return err
+				}
+			}
+			if received < item.Quantity {
+				complete = false
+			}
+		}
+		updates := map[string]interface{}{"status": "partial"}
+		if complete {
+			updates["status"] = "received"
+			updates["received_at"] = receipt.ReceivedAt
+		}
+		if err := tx.Model(&po).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := syncIncoming(tx, poProductIDs(&po)); err != nil {
+			return err
+		}
+
+		// The supplier bills the goods only; landed costs go to whoever carried / cleared them
+		bill, err = (&PayableService{DB: tx}).CreateBillForReceipt(po, receipt.GoodsValue, receipt.ReceiptNumber, userID)
+		if err != nil {
+			return err
+		}
+		if bill != nil {
+			return tx.Model(&receipt).Update("supplier_bill_id", bill.ID).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "procurement", "RECEIVE", receipt.ID, fmt.Sprintf("Received %s on PO %d (goods Rp %.0f, landed cost Rp %.0f)", receipt.ReceiptNumber, poID, receipt.GoodsValue, receipt.LandedCost))
+	return &receipt, bill, nil
+}
+
+type receiveLine struct {
+	item models.PurchaseOrderItem
+	qty  int
+}
+
+// receiveLines validates the requested quantities; none requested = all that is outstanding
+func receiveLines(po models.PurchaseOrder, requested []ReceiveLine) ([]receiveLine, error) {
+	var lines []receiveLine
+	if len(requested) == 0 {
+		for _, item := range po.Items {
+			if item.Remaining() > 0 {
+				lines = append(lines, receiveLine{item: item, qty: item.Remaining()})
+			}
+		}
+	} else {
+		byID := map[uint]models.PurchaseOrderItem{}
+		for _, item := range po.Items {
+			byID[item.ID] = item
+		}
+		seen := map[uint]bool{}
+		for _, r := range requested {
+			item, ok := byID[r.ItemID]
+			if !ok {
+				return nil, fmt.Errorf("item %d is not on this PO", r.ItemID)
+			}
+			if seen[r.ItemID] {
+				return nil, fmt.Errorf("item %d is listed twice", r.ItemID)
+			}
+			seen[r.ItemID] = true
+			if r.Quantity > item.Remaining() {
+				return nil, fmt.Errorf("item %d: receiving %d but only %d outstanding", r.ItemID, r.Quantity, item.Remaining())
+			}
+			lines = append(lines, receiveLine{item: item, qty: r.Quantity})
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("nothing left to receive on this PO")
+	}
+	return lines, nil
+}
+
+// allocateLandedCost spreads total over the items by goods value or quantity; the last line takes the rounding
+func allocateLandedCost(items []models.PurchaseReceiptItem, total float64, method string) {
+	var base float64
+	weight := func(it models.PurchaseReceiptItem) float64 {
+		if method == models.AllocateByQuantity {
+			return float64(it.Quantity)
+		}
+		return float64(it.Quantity) * it.UnitCost
+	}
+	for _, it := range items {
+		base += weight(it)
+	}
+	// All lines free of charge: fall back to quantity so the cost still lands somewhere
+	if base == 0 && method == models.AllocateByValue {
+		allocateLandedCost(items, total, models.AllocateByQuantity)
+		return
+	}
+
+	remaining := total
+	for i := range items {
+		share := remaining
+		if i < len(items)-1 {
+			share = roundMoney(total * weight(items[i]) / base)
+			remaining = roundMoney(remaining - share)
+		}
+		items[i].LandedCost = share
+		items[i].UnitLandedCost = math.Round((float64(items[i].Quantity)*items[i].UnitCost+share)/float64(items[i].Quantity)*10000) / 10000
+	}
+}
+
+// receiveItem adds the stock, logs the movement and moves the average cost:
+// (on hand x old cost + received x landed unit cost) / (on hand + received)
+func (s *PurchaseReceiptService) receiveItem(tx *gorm.DB, item *models.PurchaseReceiptItem, locationID uint, ref string, userID uint) error {
+	var product models.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, item.ProductID).Error; err != nil {
+		return fmt.Errorf("product %d not found", item.ProductID)
+	}
+	var variant *models.ProductVariant
+	onHand := product.Stock
+	if item.VariantID != nil {
+		variant = &models.ProductVariant{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("product_id = ?", product.ID).First(variant, *item.VariantID).Error; err != nil {
+			return fmt.Errorf("edition %d of %s not found", *item.VariantID, product.Name)
+		}
+		onHand = variant.Stock
+	}
+
+	item.StockBefore = onHand
+	item.CostBefore = product.CostFor(variant)
+	item.CostAfter = roundMoney(item.UnitLandedCost)
+	if onHand > 0 && item.CostBefore > 0 {
+		item.CostAfter = roundMoney((float64(onHand)*item.CostBefore + float64(item.Quantity)*item.UnitLandedCost) / float64(onHand+item.Quantity))
+	}
+
+	note := fmt.Sprintf("Receipt %s: %d @ Rp %.2f landed", ref, item.Quantity, item.UnitLandedCost)
+	if err := helpers.RecordLocationStockMovement(tx, locationID, product.ID, item.VariantID, item.Quantity, "physical", "procurement", "PROCUREMENT", ref, note, &userID); err != nil {
+		return err
+	}
+	if err := helpers.AdjustStock(tx, product.ID, item.VariantID, map[string]interface{}{
+		"stock":         gorm.Expr("stock + ?", item.Quantity),
+		"supplier_cost": item.CostAfter,
+	}); err != nil {
+		return err
+	}
+
+	// Unshipped lines sold before the product had a cost take the new average. Snapshots already taken
+	// are historical and stay as they are.
+	itemScope := tx.Model(&models.OrderItem{}).
+		Where("product_id = ? AND order_id IN (SELECT id FROM orders WHERE status IN ?)", product.ID, openOrderStatuses).
+		Where("cogs_snapshot IS NULL OR cogs_snapshot <= 0")
+	if variant != nil {
+		itemScope = itemScope.Where("variant_id = ?", variant.ID)
+	} else {
+		itemScope = itemScope.Where("variant_id IS NULL OR variant_id IN (SELECT id FROM product_variants WHERE product_id = ? AND supplier_cost <= 0)", product.ID)
+	}
+	return itemScope.Update("cogs_snapshot", item.CostAfter).Error
+}
+
+// postJournal: Debit inventory (goods + landed cost), Credit Hutang Usaha (goods), Credit the landed cost account
+func (s *PurchaseReceiptService) postJournal(tx *gorm.DB, receipt *models.PurchaseReceipt, po models.PurchaseOrder, landedCOAID *uint) error {
+	invID, err := helpers.GetInventoryCOA()
+	if err != nil {
+		return fmt.Errorf("inventory COA (1003) not found")
+	}
+	apID, err := helpers.GetPayableCOA()
+	if err != nil {
+		return fmt.Errorf("accounts payable COA (2001) not found")
+	}
+
+	items := []models.JournalItem{
+		{COAID: invID, Debit: roundMoney(receipt.GoodsValue + receipt.LandedCost), Credit: 0},
+		{COAID: apID, Debit: 0, Credit: receipt.GoodsValue},
+	}
+	if receipt.LandedCost > 0 {
+		creditID := uint(0)
+		if landedCOAID != nil {
+			var coa models.COA
+			if err := tx.First(&coa, *landedCOAID).Error; err != nil || !coa.CanPost {
+				return fmt.Errorf("landed_cost_coa_id must be a postable account")
+			}
+			creditID = coa.ID
+		} else if creditID, err = helpers.GetPrimaryBankCOA(tx); err != nil {
+			return fmt.Errorf("no Bank/Cash mapping found for the landed cost")
+		}
+		receipt.LandedCostCOAID = &creditID
+		items = append(items, models.JournalItem{COAID: creditID, Debit: 0, Credit: receipt.LandedCost})
+	}
+
+	entry, err := helpers.PostJournal(tx, helpers.JournalPosting{
+		Date:          receipt.ReceivedAt,
+		ReferenceID:   receipt.ReceiptNumber,
+		ReferenceType: "PURCHASE_ORDER",
+		Description:   fmt.Sprintf("Penerimaan %s - PO #%s", receipt.ReceiptNumber, po.PONumber),
+		Items:         items,
+	})
+	if err != nil {
+		return err
+	}
+	receipt.JournalEntryID = entry.ID
+	return nil
+}
+
+// ListReceipts returns a PO's receipts, oldest first
+func (s *PurchaseReceiptService) ListReceipts(poID uint) ([]models.PurchaseReceipt, error) {
+	var receipts []models.PurchaseReceipt
+	err := s.DB.Preload("Items.Product").Where("purchase_order_id = ?", poID).Order("id asc").Find(&receipts).Error
+	return receipts, err
+}
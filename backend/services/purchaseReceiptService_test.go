@@ -0,0 +1,88 @@
+package services
+
+import (
+	"testing"
+
+	"forzashop/backend/models"
+)
+
+func TestAllocateLandedCost(t *testing.T) {
+	type line struct {
+		qty  int
+		cost float64
+	}
+	tests := []struct {
+		name      string
+		lines     []line
+		total     float64
+		method    string
+		wantCost  []float64
+		wantLUnit []float64
+	}{
+		{
+			name:      "by value",
+			lines:     []line{{10, 1000}, {5, 4000}},
+			total:     3000,
+			method:    models.AllocateByValue,
+			wantCost:  []float64{1000, 2000},
+			wantLUnit: []float64{1100, 4400},
+		},
+		{
+			name:      "by quantity",
+			lines:     []line{{10, 1000}, {5, 4000}},
+			total:     3000,
+			method:    models.AllocateByQuantity,
+			wantCost:  []float64{2000, 1000},
+			wantLUnit: []float64{1200, 4200},
+		},
+		{
+			name:      "rounding lands on the last line",
+			lines:     []line{{1, 100}, {1, 100}, {1, 100}},
+			total:     100,
+			method:    models.AllocateByValue,
+			wantCost:  []float64{33.33, 33.33, 33.34},
+			wantLUnit: []float64{133.33, 133.33, 133.34},
+		},
+		{
+			name:      "free lines fall back to quantity",
+			lines:     []line{{3, 0}, {1, 0}},
+			total:     400,
+			method:    models.AllocateByValue,
+			wantCost:  []float64{300, 100},
+			wantLUnit: []float64{100, 100},
+		},
+		{
+			name:      "no landed cost",
+			lines:     []line{{2, 500}},
+			total:     0,
+			method:    models.AllocateByValue,
+			wantCost:  []float64{0},
+			wantLUnit: []float64{500},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := make([]models.PurchaseReceiptItem, len(tt.lines))
+			for i, l := range tt.lines {
+				items[i].Quantity = l.qty
+				items[i].UnitCost = l.cost
+			}
+			allocateLandedCost(items, tt.total, tt.method)
+
+			sum := 0.0
+			for i, it := range items {
+				sum += it.LandedCost
+				if it.LandedCost != tt.wantCost[i] {
+					t.Errorf("line %d landed cost = %v, want %v", i, it.LandedCost, tt.wantCost[i])
+				}
+				if it.UnitLandedCost != tt.wantLUnit[i] {
+					t.Errorf("line %d unit landed cost = %v, want %v", i, it.UnitLandedCost, tt.wantLUnit[i])
+				}
+			}
+			if roundMoney(sum) != tt.total {
+				t.Errorf("shares add up to %v, want %v", sum, tt.total)
+			}
+		})
+	}
+}
@@ -0,0 +1,745 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ===============================================
+// SETTLEMENT / BANK STATEMENT RECONCILIATION
+// ===============================================
+
+type ReconciliationService struct {
+	DB *gorm.DB
+}
+
+func NewReconciliationService() *ReconciliationService {
+	return &ReconciliationService{
+		DB: config.DB,
+	}
+}
+
+// ImportSettlementInput describes an uploaded report
+type ImportSettlementInput struct {
+	Source     string // gateway (settlement report) or bank (statement)
+	Provider   string // prismalink, sandbox, bca, ...
+	FileName   string
+	BankCOAID  *uint
+	ImportedBy uint
+}
+
+// ConfirmSettlementResult summarizes a confirmation run
+type ConfirmSettlementResult struct {
+	Confirmed  int      `json:"confirmed"`
+	Settled    []string `json:"settled"` // Invoices marked paid by a bank line
+	JournalRef string   `json:"journal_ref"`
+	GrossTotal float64  `json:"gross_total"`
+	FeeTotal   float64  `json:"fee_total"`
+	NetTotal   float64  `json:"net_total"`
+}
+
+// settlementColumns maps our fields to the header names seen in gateway reports and bank exports
+var settlementColumns = map[string][]string{
+	"date":        {"date", "tanggal", "transaction_date", "trx_date", "settlement_date", "tgl", "tanggal_transaksi"},
+	"reference":   {"merchant_ref_no", "merchant_ref", "reference", "ref", "ref_no", "order_id", "invoice_no"},
+	"gateway_ref": {"plink_ref_no", "gateway_ref", "transaction_id", "trx_id", "payment_ref", "plink_ref"},
+	"description": {"description", "keterangan", "remark", "remarks", "berita", "narrative"},
+	"gross":       {"gross", "gross_amount", "transaction_amount", "amount", "jumlah", "nominal", "mutasi"},
+	"fee":         {"fee", "fee_amount", "mdr", "mdr_amount", "biaya", "charge"},
+	"net":         {"net", "net_amount", "settlement_amount", "net_settlement"},
+	"credit":      {"credit", "kredit", "cr"},
+	"debit":       {"debit", "db", "dr"},
+	"type":        {"type", "cr_db", "db_cr", "jenis"},
+}
+
+var settlementDateLayouts = []string{
+	"2006-01-02", "2006-01-02 15:04:05", "2006-01-02T15:04:05Z07:00", "02/01/2006", "02/01/2006 15:04",
+	"02-01-2006", "2/1/2006", "02/01/06", "02 Jan 2006", "2006/01/02",
+}
+
+var headerCleaner = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Import parses a settlement/bank CSV, stores every line and auto-matches it
+func (s *ReconciliationService) Import(input ImportSettlementInput, r io.Reader) (*models.SettlementBatch, error) {
+	if input.Source != "gateway" && input.Source != "bank" {
+		return nil, fmt.Errorf("source must be gateway or bank")
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file")
+	}
+	raw = bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf")) // Excel BOM
+
+	reader := csv.NewReader(bytes.NewReader(raw))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	if firstLine, _, _ := strings.Cut(string(raw), "\n"); strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+
+	// Header row: the first row that names an amount column (bank exports start with account info)
+	headerRow := -1
+	var columns map[string]int
+	for i, rec := range records {
+		if cols := mapSettlementColumns(rec); cols["gross"] >= 0 || cols["credit"] >= 0 || cols["net"] >= 0 {
+			headerRow, columns = i, cols
+			break
+		}
+	}
+	if headerRow < 0 {
+		return nil, fmt.Errorf("no header row with an amount column found")
+	}
+	header := records[headerRow]
+
+	batch := models.SettlementBatch{
+		Source:     input.Source,
+		Provider:   strings.ToLower(input.Provider),
+		FileName:   input.FileName,
+		BankCOAID:  input.BankCOAID,
+		ImportedBy: input.ImportedBy,
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		number, err := helpers.NextSequenceNumber(tx, helpers.SeqSettlement)
+		if err != nil {
+			return err
+		}
+		batch.BatchNumber = number
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+
+		svc := &ReconciliationService{DB: tx}
+		for i, rec := range records[headerRow+1:] {
+			if isBlankRecord(rec) {
+				continue
+			}
+			line := parseSettlementRecord(header, rec, columns)
+			line.BatchID = batch.ID
+			line.LineNo = i + 1
+			if line.Status == "" {
+				svc.autoMatch(&batch, &line)
+			}
+			if err := tx.Create(&line).Error; err != nil {
+				return err
+			}
+		}
+		return svc.refreshBatch(&batch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(input.ImportedBy, "Finance", "Import Settlement", batch.ID,
+		fmt.Sprintf("Imported %s (%s %s): %d lines, %d matched, %d mismatched, %d unmatched",
+			batch.BatchNumber, batch.Source, batch.Provider, batch.LineCount, batch.MatchedCount, batch.MismatchCount, batch.UnmatchedCount))
+
+	if batch.Source == "bank" && helpers.GetSetting("bank_transfer_auto_confirm", "true") == "true" {
+		if _, err := s.AutoConfirmTransfers(batch.ID, input.ImportedBy); err != nil {
+			log.Printf("⚠️ Auto-confirm of %s failed: %v", batch.BatchNumber, err)
+		}
+	}
+	return s.GetBatch(batch.ID)
+}
+
+// AutoConfirmTransfers confirms the bank lines that paid an open invoice to the rupiah of its unique code.
+// Such a credit cannot belong to anything else, so no one needs to verify it.
+func (s *ReconciliationService) AutoConfirmTransfers(batchID uint, adminID uint) (*ConfirmSettlementResult, error) {
+	var lineIDs []uint
+	s.DB.Model(&models.SettlementLine{}).
+		Joins("JOIN invoices ON invoices.id = settlement_lines.invoice_id").
+		Where("settlement_lines.batch_id = ? AND settlement_lines.status = ? AND settlement_lines.match_type = ?", batchID, models.SettlementLineMatched, "invoice").
+		Where("invoices.unique_code > 0 AND invoices.status IN ?", []string{"unpaid", "awaiting_approval"}).
+		Pluck("settlement_lines.id", &lineIDs)
+	if len(lineIDs) == 0 {
+		return &ConfirmSettlementResult{}, nil
+	}
+	return s.Confirm(batchID, lineIDs, adminID)
+}
+
+// mapSettlementColumns returns the index of every known field in a header row (-1 = absent)
+func mapSettlementColumns(header []string) map[string]int {
+	cols := map[string]int{}
+	for field := range settlementColumns {
+		cols[field] = -1
+	}
+	for i, h := range header {
+		name := strings.Trim(headerCleaner.ReplaceAllString(strings.ToLower(strings.TrimSpace(h)), "_"), "_")
+		for field, aliases := range settlementColumns {
+			if cols[field] >= 0 {
+				continue
+			}
+			for _, alias := range aliases {
+				if name == alias {
+					cols[field] = i
+				}
+			}
+		}
+	}
+	return cols
+}
+
+func isBlankRecord(rec []string) bool {
+	for _, v := range rec {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSettlementRecord reads one CSV row. Bank debit mutations are stored as ignored.
+func parseSettlementRecord(header, rec []string, cols map[string]int) models.SettlementLine {
+	get := func(field string) string {
+		if i := cols[field]; i >= 0 && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	rawRow := map[string]string{}
+	for i, h := range header {
+		if i < len(rec) && strings.TrimSpace(h) != "" {
+			rawRow[strings.TrimSpace(h)] = rec[i]
+		}
+	}
+	rawJSON, _ := json.Marshal(rawRow)
+
+	line := models.SettlementLine{
+		Reference:   get("reference"),
+		GatewayRef:  get("gateway_ref"),
+		Description: get("description"),
+		Raw:         datatypes.JSON(rawJSON),
+	}
+	if d := get("date"); d != "" {
+		for _, layout := range settlementDateLayouts {
+			if t, err := time.ParseInLocation(layout, d, time.Local); err == nil {
+				line.TxDate = &t
+				break
+			}
+		}
+	}
+
+	// Amount: gross column (BCA style "1,500,000.00 CR"), or separate credit/debit columns
+	gross, sign := parseStatementAmount(get("gross"))
+	if credit, _ := parseStatementAmount(get("credit")); credit != 0 {
+		gross, sign = credit, 1
+	} else if debit, _ := parseStatementAmount(get("debit")); debit != 0 && gross == 0 {
+		gross, sign = debit, -1
+	}
+	switch strings.ToUpper(get("type")) {
+	case "DB", "D", "DEBIT", "DR":
+		sign = -1
+	}
+	fee, _ := parseStatementAmount(get("fee"))
+	net, _ := parseStatementAmount(get("net"))
+	fee = math.Abs(fee)
+
+	if gross == 0 && net != 0 {
+		gross = net + fee
+	}
+	if net == 0 {
+		net = gross - fee
+	}
+	if fee == 0 && net != 0 && gross > net {
+		fee = roundMoney(gross - net)
+	}
+	line.GrossAmount = roundMoney(math.Abs(gross))
+	line.FeeAmount = roundMoney(fee)
+	line.NetAmount = roundMoney(math.Abs(net))
+
+	if sign < 0 || gross < 0 {
+		line.Status = models.SettlementLineIgnored
+		line.Note = "Debit mutation"
+	} else if line.GrossAmount == 0 {
+		line.Status = models.SettlementLineIgnored
+		line.Note = "No amount"
+	}
+	return line
+}
+
+// parseStatementAmount reads "1.500.000,00", "1,500,000.00", "Rp 150000", "(2,500.00)" and "750,000.00 CR/DB".
+// The sign is -1 for DB suffixes and bracketed or negative amounts.
+func parseStatementAmount(v string) (float64, int) {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	if v == "" {
+		return 0, 1
+	}
+	sign := 1
+	if strings.HasSuffix(v, "DB") || strings.HasSuffix(v, "DR") {
+		sign = -1
+	}
+	if strings.HasPrefix(v, "(") || strings.HasPrefix(v, "-") {
+		sign = -1
+	}
+	v = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(v, "CR"), "DB"), "DR"), "D")
+	v = strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' || r == ',' {
+			return r
+		}
+		return -1
+	}, v)
+
+	lastDot, lastComma := strings.LastIndex(v, "."), strings.LastIndex(v, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// Whichever comes last is the decimal separator
+		if lastComma > lastDot {
+			v = strings.ReplaceAll(v, ".", "")
+			v = strings.Replace(v, ",", ".", 1)
+		} else {
+			v = strings.ReplaceAll(v, ",", "")
+		}
+	case lastComma >= 0:
+		// "1,500,000" thousands vs "150000,50" decimals
+		if len(v)-lastComma == 3 && strings.Count(v, ",") == 1 {
+			v = strings.Replace(v, ",", ".", 1)
+		} else {
+			v = strings.ReplaceAll(v, ",", "")
+		}
+	case lastDot >= 0:
+		if !(len(v)-lastDot == 3 && strings.Count(v, ".") == 1) {
+			v = strings.ReplaceAll(v, ".", "")
+		}
+	}
+	amount, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, sign
+	}
+	return amount * float64(sign), sign
+}
+
+// autoMatch finds what a line pays for: the charge (gateway reports) or the bank-transfer invoice (statements)
+func (s *ReconciliationService) autoMatch(batch *models.SettlementBatch, line *models.SettlementLine) {
+	line.Status, line.Note, line.MatchType = models.SettlementLineUnmatched, "", ""
+	line.PaymentTxID, line.InvoiceID, line.ExpectedAmount = nil, nil, 0
+
+	// Any line carrying one of our charge references, in either report type
+	if line.Reference != "" || line.GatewayRef != "" {
+		query := s.DB.Where("1 = 0")
+		if line.Reference != "" {
+			query = query.Or("merchant_ref_no = ?", line.Reference)
+		}
+		if line.GatewayRef != "" {
+			query = query.Or("gateway_tx_id = ?", line.GatewayRef)
+		}
+		var payTx models.PaymentTransaction
+		if err := s.DB.Where("type <> ?", "refund").Where(query).Order("id desc").First(&payTx).Error; err == nil {
+			s.evaluatePaymentTx(line, payTx)
+			return
+		}
+	}
+	if batch.Source == "gateway" {
+		line.Note = "No payment transaction with this reference"
+		return
+	}
+
+	// Bank: invoice number written in the transfer description
+	if line.Description != "" {
+		var inv models.Invoice
+		if err := s.DB.Where("? ILIKE '%' || invoice_number || '%'", line.Description).Order("id desc").First(&inv).Error; err == nil {
+			s.evaluateInvoice(line, inv)
+			return
+		}
+	}
+
+	// Bank: exact amount (incl. unique code) of an open or manually paid transfer invoice around the date
+	date := time.Now()
+	if line.TxDate != nil {
+		date = *line.TxDate
+	}
+	var candidates []models.Invoice
+	s.DB.Where("amount + unique_code = ? AND status IN ? AND created_at BETWEEN ? AND ?", line.GrossAmount,
+		[]string{"unpaid", "awaiting_approval", "paid", "paid_late"}, date.AddDate(0, 0, -14), date.AddDate(0, 0, 1)).
+		Where("COALESCE(payment_method, '') = '' OR payment_method ILIKE ? OR payment_method ILIKE ?", "%transfer%", "manual%").
+		Where("id NOT IN (?)", s.DB.Model(&models.SettlementLine{}).Select("invoice_id").Where("status = ? AND invoice_id IS NOT NULL", models.SettlementLineConfirmed)).
+		Order("created_at desc").Limit(5).Find(&candidates)
+
+	switch len(candidates) {
+	case 0:
+		line.Note = "No invoice with this amount around the transfer date"
+	case 1:
+		s.evaluateInvoice(line, candidates[0])
+	default:
+		numbers := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			numbers = append(numbers, c.InvoiceNumber)
+		}
+		line.Status = models.SettlementLineMismatched
+		line.Note = "Several invoices with this amount: " + strings.Join(numbers, ", ")
+	}
+}
+
+func (s *ReconciliationService) evaluatePaymentTx(line *models.SettlementLine, payTx models.PaymentTransaction) {
+	line.MatchType = "payment_tx"
+	line.PaymentTxID = &payTx.ID
+	if payTx.InvoiceID != 0 {
+		line.InvoiceID = &payTx.InvoiceID
+	}
+	line.ExpectedAmount = payTx.Amount
+
+	switch {
+	case s.alreadyReconciled(line, "payment_tx_id", payTx.ID):
+		line.Status = models.SettlementLineMismatched
+		line.Note = "Payment already reconciled in another line"
+	case payTx.Status != "success" && payTx.Status != "refunded":
+		line.Status = models.SettlementLineMismatched
+		line.Note = fmt.Sprintf("Payment is %s in the system: sync the invoice, then re-match", payTx.Status)
+	case math.Abs(line.GrossAmount-payTx.Amount) > 0.5:
+		line.Status = models.SettlementLineMismatched
+		line.Note = fmt.Sprintf("Amount differs: report Rp %.0f, system Rp %.0f", line.GrossAmount, payTx.Amount)
+	default:
+		line.Status = models.SettlementLineMatched
+	}
+}
+
+func (s *ReconciliationService) evaluateInvoice(line *models.SettlementLine, inv models.Invoice) {
+	line.MatchType = "invoice"
+	line.InvoiceID = &inv.ID
+	line.ExpectedAmount = inv.TransferAmount()
+
+	switch {
+	case s.alreadyReconciled(line, "invoice_id", inv.ID):
+		line.Status = models.SettlementLineMismatched
+		line.Note = fmt.Sprintf("Invoice %s already reconciled in another line", inv.InvoiceNumber)
+	case inv.Status != "unpaid" && inv.Status != "awaiting_approval" && inv.Status != "paid" && inv.Status != "paid_late":
+		line.Status = models.SettlementLineMismatched
+		line.Note = fmt.Sprintf("Invoice %s is %s", inv.InvoiceNumber, inv.Status)
+	case math.Abs(line.GrossAmount-inv.TransferAmount()) > 0.5:
+		line.Status = models.SettlementLineMismatched
+		line.Note = fmt.Sprintf("Amount differs: statement Rp %.0f, invoice %s Rp %.0f", line.GrossAmount, inv.InvoiceNumber, inv.TransferAmount())
+	default:
+		line.Status = models.SettlementLineMatched
+		if inv.Status == "unpaid" || inv.Status == "awaiting_approval" {
+			line.Note = fmt.Sprintf("Confirming marks invoice %s as paid", inv.InvoiceNumber)
+		}
+	}
+}
+
+func (s *ReconciliationService) alreadyReconciled(line *models.SettlementLine, column string, id uint) bool {
+	var n int64
+	s.DB.Model(&models.SettlementLine{}).Where(column+" = ? AND status = ? AND id <> ?", id, models.SettlementLineConfirmed, line.ID).Count(&n)
+	return n > 0
+}
+
+// refreshBatch recounts the batch totals from its lines
+func (s *ReconciliationService) refreshBatch(batch *models.SettlementBatch) error {
+	type row struct {
+		Status string
+		Count  int
+		Gross  float64
+		Fee    float64
+		Net    float64
+	}
+	var rows []row
+	s.DB.Model(&models.SettlementLine{}).Where("batch_id = ?", batch.ID).
+		Select("status, COUNT(*) AS count, COALESCE(SUM(gross_amount), 0) AS gross, COALESCE(SUM(fee_amount), 0) AS fee, COALESCE(SUM(net_amount), 0) AS net").
+		Group("status").Scan(&rows)
+
+	batch.LineCount, batch.MatchedCount, batch.MismatchCount, batch.UnmatchedCount, batch.ConfirmedCount = 0, 0, 0, 0, 0
+	batch.TotalGross, batch.TotalFee, batch.TotalNet = 0, 0, 0
+	for _, r := range rows {
+		batch.LineCount += r.Count
+		switch r.Status {
+		case models.SettlementLineMatched:
+			batch.MatchedCount = r.Count
+		case models.SettlementLineMismatched:
+			batch.MismatchCount = r.Count
+		case models.SettlementLineUnmatched:
+			batch.UnmatchedCount = r.Count
+		case models.SettlementLineConfirmed:
+			batch.ConfirmedCount = r.Count
+		}
+		if r.Status != models.SettlementLineIgnored {
+			batch.TotalGross += r.Gross
+			batch.TotalFee += r.Fee
+			batch.TotalNet += r.Net
+		}
+	}
+	return s.DB.Model(batch).Select("line_count", "matched_count", "mismatch_count", "unmatched_count", "confirmed_count", "total_gross", "total_fee", "total_net").Updates(batch).Error
+}
+
+// GetBatch loads a batch with its lines
+func (s *ReconciliationService) GetBatch(id uint) (*models.SettlementBatch, error) {
+	var batch models.SettlementBatch
+	err := s.DB.Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("line_no") }).
+		Preload("Lines.PaymentTx").Preload("Lines.Invoice").First(&batch, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("settlement batch not found")
+	}
+	return &batch, nil
+}
+
+// ListBatches - paginated imports, newest first
+func (s *ReconciliationService) ListBatches(source string, page, limit int) ([]models.SettlementBatch, int64, error) {
+	query := s.DB.Model(&models.SettlementBatch{})
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+	var total int64
+	query.Count(&total)
+
+	var batches []models.SettlementBatch
+	err := query.Order("id desc").Limit(limit).Offset((page - 1) * limit).Find(&batches).Error
+	return batches, total, err
+}
+
+// ListLines lists lines by status across batches (batchID 0), e.g. every open unmatched / mismatched item
+func (s *ReconciliationService) ListLines(batchID uint, statuses []string, page, limit int) ([]models.SettlementLine, int64, error) {
+	query := s.DB.Model(&models.SettlementLine{})
+	if batchID != 0 {
+		query = query.Where("batch_id = ?", batchID)
+	}
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+	var total int64
+	query.Count(&total)
+
+	var lines []models.SettlementLine
+	err := query.Preload("PaymentTx").Preload("Invoice").Order("batch_id desc, line_no").Limit(limit).Offset((page - 1) * limit).Find(&lines).Error
+	return lines, total, err
+}
+
+// MatchLine re-runs the auto-match for a line, or matches it by hand to a payment transaction or invoice
+func (s *ReconciliationService) MatchLine(lineID, paymentTxID, invoiceID uint) (*models.SettlementLine, error) {
+	var line models.SettlementLine
+	if err := s.DB.First(&line, lineID).Error; err != nil {
+		return nil, fmt.Errorf("settlement line not found")
+	}
+	if line.Status == models.SettlementLineConfirmed {
+		return nil, fmt.Errorf("line %d is already confirmed", line.LineNo)
+	}
+	var batch models.SettlementBatch
+	if err := s.DB.First(&batch, line.BatchID).Error; err != nil {
+		return nil, fmt.Errorf("settlement batch not found")
+	}
+
+	switch {
+	case paymentTxID != 0:
+		var payTx models.PaymentTransaction
+		if err := s.DB.First(&payTx, paymentTxID).Error; err != nil {
+			return nil, fmt.Errorf("payment transaction not found")
+		}
+		s.evaluatePaymentTx(&line, payTx)
+	case invoiceID != 0:
+		var inv models.Invoice
+		if err := s.DB.First(&inv, invoiceID).Error; err != nil {
+			return nil, fmt.Errorf("invoice not found")
+		}
+		line.PaymentTxID = nil
+		s.evaluateInvoice(&line, inv)
+	default:
+		s.autoMatch(&batch, &line)
+	}
+
+	if err := s.DB.Select("status", "note", "match_type", "payment_tx_id", "invoice_id", "expected_amount").Save(&line).Error; err != nil {
+		return nil, err
+	}
+	s.refreshBatch(&batch)
+	return &line, nil
+}
+
+// IgnoreLine takes a line out of the reconciliation (bank charges, transfers between own accounts, ...)
+func (s *ReconciliationService) IgnoreLine(lineID uint, note string) (*models.SettlementLine, error) {
+	var line models.SettlementLine
+	if err := s.DB.First(&line, lineID).Error; err != nil {
+		return nil, fmt.Errorf("settlement line not found")
+	}
+	if line.Status == models.SettlementLineConfirmed {
+		return nil, fmt.Errorf("line %d is already confirmed", line.LineNo)
+	}
+	line.Status = models.SettlementLineIgnored
+	line.Note = note
+	if err := s.DB.Select("status", "note").Save(&line).Error; err != nil {
+		return nil, err
+	}
+	s.refreshBatch(&models.SettlementBatch{ID: line.BatchID})
+	return &line, nil
+}
+
+// Confirm reconciles matched lines (all of the batch, or lineIDs). Gateway lines move the payment from
+// GATEWAY_CLEARING to the bank and book the fee in one SETTLEMENT journal; bank lines settle open
+// transfer invoices through the regular payment flow.
+func (s *ReconciliationService) Confirm(batchID uint, lineIDs []uint, adminID uint) (*ConfirmSettlementResult, error) {
+	var batch models.SettlementBatch
+	if err := s.DB.First(&batch, batchID).Error; err != nil {
+		return nil, fmt.Errorf("settlement batch not found")
+	}
+
+	result := &ConfirmSettlementResult{}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("batch_id = ? AND status = ?", batch.ID, models.SettlementLineMatched)
+		if len(lineIDs) > 0 {
+			query = query.Where("id IN ?", lineIDs)
+		}
+		var lines []models.SettlementLine
+		if err := query.Order("line_no").Find(&lines).Error; err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			return fmt.Errorf("no matched lines to confirm")
+		}
+
+		bankCOAID := uint(0)
+		if batch.BankCOAID != nil {
+			bankCOAID = *batch.BankCOAID
+		} else if id, err := helpers.GetPrimaryBankCOA(tx); err == nil {
+			bankCOAID = id
+		}
+		clearingID, _ := helpers.GetCOAByMappingKey("GATEWAY_CLEARING")
+		feeCOAID := gatewayFeeCOA(tx)
+
+		debits, credits := map[uint]float64{}, map[uint]float64{}
+		now := time.Now()
+		for _, line := range lines {
+			switch line.MatchType {
+			case "payment_tx":
+				var payTx models.PaymentTransaction
+				if line.PaymentTxID == nil || tx.First(&payTx, *line.PaymentTxID).Error != nil {
+					return fmt.Errorf("line %d: payment transaction not found", line.LineNo)
+				}
+				if line.FeeAmount > 0 && feeCOAID == 0 {
+					return fmt.Errorf("map an expense account to GATEWAY_FEE before confirming gateway fees")
+				}
+				if bankCOAID == 0 {
+					return fmt.Errorf("no bank account to settle into")
+				}
+				if clearingID != 0 && bookedOnClearing(tx, payTx, clearingID) {
+					// Clearing -> bank (net) + fee expense
+					debits[bankCOAID] += line.NetAmount
+					debits[feeCOAID] += line.FeeAmount
+					credits[clearingID] += line.GrossAmount
+				} else if line.FeeAmount > 0 {
+					// Payment went straight to the bank at gross: only the fee comes off
+					debits[feeCOAID] += line.FeeAmount
+					credits[bankCOAID] += line.FeeAmount
+				}
+
+			case "invoice":
+				var inv models.Invoice
+				if line.InvoiceID == nil || tx.First(&inv, *line.InvoiceID).Error != nil {
+					return fmt.Errorf("line %d: invoice not found", line.LineNo)
+				}
+				if inv.Status == "unpaid" || inv.Status == "awaiting_approval" {
+					ref := "MANUAL-" + inv.InvoiceNumber
+					if _, err := (&PaymentService{DB: tx}).SettleCharge(ChargeResult{
+						MerchantRefNo: ref,
+						InvoiceNumber: inv.InvoiceNumber,
+						Status:        ChargeStatusPaid,
+						RawStatus:     "BANK",
+						Amount:        line.GrossAmount,
+					}, "bank reconciliation "+batch.BatchNumber); err != nil {
+						return fmt.Errorf("line %d: %v", line.LineNo, err)
+					}
+					result.Settled = append(result.Settled, inv.InvoiceNumber)
+				}
+			}
+
+			if err := tx.Model(&models.SettlementLine{}).Where("id = ? AND status = ?", line.ID, models.SettlementLineMatched).Updates(map[string]interface{}{
+				"status":       models.SettlementLineConfirmed,
+				"confirmed_by": adminID,
+				"confirmed_at": now,
+				"journal_ref":  batch.BatchNumber,
+			}).Error; err != nil {
+				return err
+			}
+			result.Confirmed++
+			result.GrossTotal += line.GrossAmount
+			result.FeeTotal += line.FeeAmount
+			result.NetTotal += line.NetAmount
+		}
+
+		if items := settlementJournalItems(debits, credits); len(items) > 0 {
+			if err := helpers.PostJournalWithTX(tx, batch.BatchNumber, "SETTLEMENT",
+				fmt.Sprintf("Settlement %s %s (%d lines, fee Rp %.0f)", batch.Provider, batch.BatchNumber, result.Confirmed, result.FeeTotal), items); err != nil {
+				return err
+			}
+			result.JournalRef = batch.BatchNumber
+		}
+		return (&ReconciliationService{DB: tx}).refreshBatch(&batch)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(adminID, "Finance", "Confirm Settlement", batch.ID,
+		fmt.Sprintf("Confirmed %d lines of %s (gross Rp %.0f, fee Rp %.0f)", result.Confirmed, batch.BatchNumber, result.GrossTotal, result.FeeTotal))
+	return result, nil
+}
+
+// gatewayFeeCOA - expense account for MDR / gateway and bank charges
+func gatewayFeeCOA(tx *gorm.DB) uint {
+	if id, err := helpers.GetCOAByMappingKey("GATEWAY_FEE"); err == nil {
+		return id
+	}
+	var coa models.COA
+	if err := tx.Where("type = ? AND can_post = ? AND (name ILIKE ? OR name ILIKE ? OR name ILIKE ?)", "EXPENSE", true, "%gateway%", "%mdr%", "%biaya bank%").First(&coa).Error; err == nil {
+		return coa.ID
+	}
+	return 0
+}
+
+// bookedOnClearing - whether the payment journal of this charge debited the clearing account
+func bookedOnClearing(tx *gorm.DB, payTx models.PaymentTransaction, clearingID uint) bool {
+	refs := []string{payTx.MerchantRefNo}
+	if payTx.GatewayTxID != "" {
+		refs = append(refs, payTx.GatewayTxID)
+	}
+	var n int64
+	tx.Model(&models.JournalItem{}).
+		Joins("JOIN journal_entries ON journal_entries.id = journal_items.journal_entry_id").
+		Where("journal_entries.reference_type = ? AND journal_entries.reference_id IN ? AND journal_items.coa_id = ? AND journal_items.debit > 0", "PAYMENT", refs, clearingID).
+		Count(&n)
+	return n > 0
+}
+
+// settlementJournalItems collapses per-account debits and credits into journal lines
+func settlementJournalItems(debits, credits map[uint]float64) []models.JournalItem {
+	var items []models.JournalItem
+	ids := make([]uint, 0, len(debits)+len(credits))
+	for id := range debits {
+		ids = append(ids, id)
+	}
+	for id := range credits {
+		if _, seen := debits[id]; !seen {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		net := roundMoney(debits[id] - credits[id])
+		switch {
+		case id == 0 || net == 0:
+			continue
+		case net > 0:
+			items = append(items, models.JournalItem{COAID: id, Debit: net})
+		default:
+			items = append(items, models.JournalItem{COAID: id, Credit: -net})
+		}
+	}
+	return items
+}
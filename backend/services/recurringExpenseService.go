@@ -0,0 +1,386 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ===============================================
+// RECURRING EXPENSES
+// Each schedule remembers its last generated occurrence, so a run after downtime
+// catches up every missed day and a repeated run posts nothing twice.
+// ===============================================
+
+// maxCatchUp bounds how many occurrences one schedule may post in a single run
+const maxCatchUp = 400
+
+type RecurringExpenseService struct {
+	DB *gorm.DB
+}
+
+func NewRecurringExpenseService() *RecurringExpenseService {
+	return &RecurringExpenseService{
+		DB: config.DB,
+	}
+}
+
+// RecurringExpenseInput creates or updates a schedule
+type RecurringExpenseInput struct {
+	COAID       uint       `json:"coa_id" binding:"required"`
+	Amount      float64    `json:"amount" binding:"required,gt=0"`
+	Vendor      string     `json:"vendor"`
+	Description string     `json:"description" binding:"required"`
+	Frequency   string     `json:"frequency" binding:"required"`
+	DayOfMonth  int        `json:"day_of_month"`
+	Weekday     int        `json:"weekday"`
+	Month       int        `json:"month"`
+	StartDate   time.Time  `json:"-"`
+	EndDate     *time.Time `json:"-"`
+	IsActive    *bool      `json:"is_active"`
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// clampDay returns day in the given month, moved back to the month's last day when it doesn't exist (31 -> 30 Apr)
+func clampDay(year int, month time.Month, day int) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.Local).Day()
+	if day > last {
+		day = last
+	}
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+// nextOccurrence returns the first occurrence strictly after `after`
+func nextOccurrence(r *models.RecurringExpense, after time.Time) time.Time {
+	after = startOfDay(after)
+	switch r.Frequency {
+	case models.RecurWeekly:
+		days := (r.Weekday - int(after.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return after.AddDate(0, 0, days)
+	case models.RecurYearly:
+		candidate := clampDay(after.Year(), time.Month(r.Month), r.DayOfMonth)
+		if !candidate.After(after) {
+			candidate = clampDay(after.Year()+1, time.Month(r.Month), r.DayOfMonth)
+		}
+		return candidate
+	default: // monthly
+		candidate := clampDay(after.Year(), after.Month(), r.DayOfMonth)
+		if !candidate.After(after) {
+			candidate = clampDay(after.Year(), after.Month()+1, r.DayOfMonth)
+		}
+		return candidate
+	}
+}
+
+// DueOccurrences lists the occurrences not generated yet, up to and including today
+func DueOccurrences(r *models.RecurringExpense, today time.Time) []time.Time {
+	today = startOfDay(today)
+	if r.EndDate != nil && startOfDay(*r.EndDate).Before(today) {
+		today = startOfDay(*r.EndDate)
+	}
+
+	cursor := startOfDay(r.StartDate).AddDate(0, 0, -1)
+	if r.LastRunDate != nil && !startOfDay(*r.LastRunDate).Before(cursor) {
+		cursor = startOfDay(*r.LastRunDate)
+	}
+
+	var due []time.Time
+	for len(due) < maxCatchUp {
+		next := nextOccurrence(r, cursor)
+		if next.After(today) {
+			break
+		}
+		due = append(due, next)
+		cursor = next
+	}
+	return due
+}
+
+func (s *RecurringExpenseService) validate(in *RecurringExpenseInput) error {
+	switch in.Frequency {
+	case models.RecurWeekly:
+		if in.Weekday < 0 || in.Weekday > 6 {
+			return fmt.Errorf("weekday must be 0 (Sunday) - 6 (Saturday)")
+		}
+	case models.RecurMonthly:
+		if in.DayOfMonth < 1 || in.DayOfMonth > 31 {
+			return fmt.Errorf("day_of_month must be 1 - 31")
+		}
+	case models.RecurYearly:
+		if in.Month < 1 || in.Month > 12 || in.DayOfMonth < 1 || in.DayOfMonth > 31 {
+			return fmt.Errorf("yearly schedules need month 1 - 12 and day_of_month 1 - 31")
+		}
+	default:
+		return fmt.Errorf("frequency must be weekly, monthly or yearly")
+	}
+	if in.StartDate.IsZero() {
+		in.StartDate = time.Now()
+	}
+	in.StartDate = startOfDay(in.StartDate)
+	if in.EndDate != nil {
+		end := startOfDay(*in.EndDate)
+		if end.Before(in.StartDate) {
+			return fmt.Errorf("end_date is before start_date")
+		}
+		in.EndDate = &end
+	}
+
+	var coa models.COA
+	if err := s.DB.First(&coa, in.COAID).Error; err != nil {
+		return fmt.Errorf("account not found")
+	}
+	if !coa.CanPost {
+		return fmt.Errorf("account %s is a header account", coa.Code)
+	}
+	return nil
+}
+
+// List returns all schedules, active first
+func (s *RecurringExpenseService) List() ([]models.RecurringExpense, error) {
+	var schedules []models.RecurringExpense
+	err := s.DB.Preload("COA").Order("is_active desc, id desc").Find(&schedules).Error
+	return schedules, err
+}
+
+// Create adds a schedule; occurrences between StartDate and today are posted by the next run
+func (s *RecurringExpenseService) Create(in RecurringExpenseInput, userID uint) (*models.RecurringExpense, error) {
+	if err := s.validate(&in); err != nil {
+		return nil, err
+	}
+	schedule := models.RecurringExpense{
+		COAID:       in.COAID,
+		Amount:      in.Amount,
+		Vendor:      in.Vendor,
+		Description: in.Description,
+		Frequency:   in.Frequency,
+		DayOfMonth:  in.DayOfMonth,
+		Weekday:     in.Weekday,
+		Month:       in.Month,
+		StartDate:   in.StartDate,
+		EndDate:     in.EndDate,
+		IsActive:    in.IsActive == nil || *in.IsActive,
+		CreatedBy:   userID,
+	}
+	if err := s.DB.Create(&schedule).Error; err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "finance", "CREATE", schedule.ID, "Created recurring expense: "+schedule.Description)
+	return &schedule, nil
+}
+
+// Update changes a schedule; already generated expenses are left as they are
+func (s *RecurringExpenseService) Update(id uint, in RecurringExpenseInput, userID uint) (*models.RecurringExpense, error) {
+	var schedule models.RecurringExpense
+	if err := s.DB.First(&schedule, id).Error; err != nil {
+		return nil, fmt.Errorf("recurring expense not found")
+	}
+	if in.StartDate.IsZero() {
+		in.StartDate = schedule.StartDate
+	}
+	if err := s.validate(&in); err != nil {
+		return nil, err
+	}
+
+	schedule.COAID = in.COAID
+	schedule.Amount = in.Amount
+	schedule.Vendor = in.Vendor
+	schedule.Description = in.Description
+	schedule.Frequency = in.Frequency
+	schedule.DayOfMonth = in.DayOfMonth
+	schedule.Weekday = in.Weekday
+	schedule.Month = in.Month
+	schedule.StartDate = in.StartDate
+	schedule.EndDate = in.EndDate
+	if in.IsActive != nil {
+		schedule.IsActive = *in.IsActive
+	}
+	schedule.LastError = ""
+	if err := s.DB.Save(&schedule).Error; err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "finance", "UPDATE", schedule.ID, "Updated recurring expense: "+schedule.Description)
+	return &schedule, nil
+}
+
+// Delete removes a schedule; generated expenses keep their journals
+func (s *RecurringExpenseService) Delete(id uint, userID uint) error {
+	var schedule models.RecurringExpense
+	if err := s.DB.First(&schedule, id).Error; err != nil {
+		return fmt.Errorf("recurring expense not found")
+	}
+	if err := s.DB.Delete(&schedule).Error; err != nil {
+		return err
+	}
+
+	helpers.LogAuditSimple(userID, "finance", "DELETE", schedule.ID, "Deleted recurring expense: "+schedule.Description)
+	return nil
+}
+
+// adoptLegacyRecurring turns expenses flagged IsRecurring (RecurringDay = day of month) into monthly schedules.
+// They start after the flagged expense, but never earlier than today: nobody expected back postings for them.
+func (s *RecurringExpenseService) adoptLegacyRecurring(today time.Time) {
+	var legacy []models.Expense
+	s.DB.Where("is_recurring = ? AND recurring_day > 0 AND recurring_expense_id IS NULL", true).Find(&legacy)
+
+	yesterday := startOfDay(today).AddDate(0, 0, -1)
+	for _, expense := range legacy {
+		s.DB.Transaction(func(tx *gorm.DB) error {
+			last := startOfDay(expense.Date)
+			if last.Before(yesterday) {
+				last = yesterday
+			}
+			schedule := models.RecurringExpense{
+				COAID:       expense.COAID,
+				Amount:      expense.Amount,
+				Vendor:      expense.Vendor,
+				Description: expense.Description,
+				Frequency:   models.RecurMonthly,
+				DayOfMonth:  expense.RecurringDay,
+				StartDate:   last,
+				LastRunDate: &last,
+				IsActive:    true,
+				CreatedBy:   expense.CreatedBy,
+			}
+			if err := tx.Create(&schedule).Error; err != nil {
+				return err
+			}
+			return tx.Model(&expense).Update("recurring_expense_id", schedule.ID).Error
+		})
+	}
+}
+
+// PostedExpense is one expense generated by a run
+type PostedExpense struct {
+	ExpenseID          uint      `json:"expense_id"`
+	RecurringExpenseID uint      `json:"recurring_expense_id"`
+	Date               time.Time `json:"date"`
+	Description        string    `json:"description"`
+	Amount             float64   `json:"amount"`
+}
+
+// RecurringRunResult summarizes a run
+type RecurringRunResult struct {
+	Posted  []PostedExpense `json:"posted"`
+	Skipped int             `json:"skipped"` // Occurrences that already had an expense
+	Failed  []string        `json:"failed"`
+}
+
+// RunDue posts every due occurrence of every active schedule up to today and notifies admins of the result
+func (s *RecurringExpenseService) RunDue(today time.Time) (*RecurringRunResult, error) {
+	s.adoptLegacyRecurring(today)
+
+	var schedules []models.RecurringExpense
+	if err := s.DB.Where("is_active = ?", true).Order("id").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+
+	result := &RecurringRunResult{Posted: []PostedExpense{}, Failed: []string{}}
+	for i := range schedules {
+		schedule := &schedules[i]
+		for _, date := range DueOccurrences(schedule, today) {
+			posted, err := s.postOccurrence(schedule, date)
+			if err != nil {
+				// Stop this schedule at the failing day; the next run retries from there
+				msg := fmt.Sprintf("%s (%s): %v", schedule.Description, date.Format("2006-01-02"), err)
+				result.Failed = append(result.Failed, msg)
+				s.DB.Model(schedule).Update("last_error", msg)
+				break
+			}
+			if posted == nil {
+				result.Skipped++
+			} else {
+				result.Posted = append(result.Posted, *posted)
+			}
+			schedule.LastRunDate = &date
+			s.DB.Model(schedule).Updates(map[string]interface{}{"last_run_date": date, "last_error": ""})
+		}
+	}
+
+	if len(result.Posted) > 0 || len(result.Failed) > 0 {
+		s.notify(result)
+	}
+	return result, nil
+}
+
+// postOccurrence creates the expense for one day; nil without error when that day already has one
+func (s *RecurringExpenseService) postOccurrence(schedule *models.RecurringExpense, date time.Time) (*PostedExpense, error) {
+	var posted *PostedExpense
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		tx.Model(&models.Expense{}).Where("recurring_expense_id = ? AND date = ?", schedule.ID, date).Count(&count)
+		if count > 0 {
+			return nil
+		}
+
+		expense := models.Expense{
+			COAID:              schedule.COAID,
+			Amount:             schedule.Amount,
+			Vendor:             schedule.Vendor,
+			Description:        schedule.Description,
+			Date:               date,
+			IsRecurring:        true,
+			RecurringExpenseID: &schedule.ID,
+			CreatedBy:          schedule.CreatedBy,
+		}
+		if schedule.Frequency == models.RecurMonthly {
+			expense.RecurringDay = schedule.DayOfMonth
+		}
+		if err := postExpense(tx, &expense); err != nil {
+			return err
+		}
+		posted = &PostedExpense{
+			ExpenseID:          expense.ID,
+			RecurringExpenseID: schedule.ID,
+			Date:               date,
+			Description:        expense.Description,
+			Amount:             expense.Amount,
+		}
+		return nil
+	})
+	if err != nil && strings.Contains(err.Error(), "idx_recurring_occurrence") {
+		// A concurrent run (startup catch-up vs. cron) posted this day between our check and insert
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if posted != nil {
+		helpers.LogAuditSimple(0, "finance", "CREATE", posted.ExpenseID, "Posted recurring expense: "+posted.Description)
+	}
+	return posted, nil
+}
+
+func (s *RecurringExpenseService) notify(result *RecurringRunResult) {
+	var total float64
+	lines := make([]string, 0, len(result.Posted))
+	for _, p := range result.Posted {
+		total += p.Amount
+		lines = append(lines, fmt.Sprintf("%s %s: Rp %.0f", p.Date.Format("2006-01-02"), p.Description, p.Amount))
+	}
+
+	subject := fmt.Sprintf("%d biaya rutin diposting (Rp %.0f)", len(result.Posted), roundMoney(total))
+	if len(result.Failed) > 0 {
+		subject += fmt.Sprintf(", %d gagal", len(result.Failed))
+	}
+	helpers.NotifyAdmin("RECURRING_EXPENSE_POSTED", subject, map[string]interface{}{
+		"posted":  result.Posted,
+		"summary": strings.Join(lines, "\n"),
+		"total":   roundMoney(total),
+		"skipped": result.Skipped,
+		"failed":  result.Failed,
+	})
+}
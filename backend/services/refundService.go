@@ -0,0 +1,597 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RefundService struct {
+	DB *gorm.DB
+}
+
+func NewRefundService() *RefundService {
+	return &RefundService{
+		DB: config.DB,
+	}
+}
+
+// Refund methods
+const (
+	RefundMethodGateway = "gateway" // Back through the gateway the customer paid with
+	RefundMethodWallet  = "wallet"  // Credited to the Forza Wallet
+	RefundMethodManual  = "manual"  // Paid out by finance outside the system (bank transfer)
+)
+
+// refundTransitions is the refund status machine
+var refundTransitions = map[string][]string{
+	models.RefundStatusRequested:  {models.RefundStatusProcessing, models.RefundStatusFailed},
+	models.RefundStatusProcessing: {models.RefundStatusSucceeded, models.RefundStatusFailed},
+	models.RefundStatusFailed:     {models.RefundStatusRequested}, // Retry
+}
+
+// RefundInput requests money back for an order
+type RefundInput struct {
+	OrderID     uint
+	Amount      float64
+	Method      string // gateway, wallet, manual; empty = gateway when charges refundable by API cover it, else manual
+	Type        string // full, partial; empty = derived from the refundable balance
+	Reason      string
+	Source      string // order, return, cancellation, order_edit
+	SourceRef   string
+	RequestedBy *uint
+}
+
+// chargeBalance is a settled gateway charge and what can still be refunded on it
+type chargeBalance struct {
+	Charge    models.PaymentTransaction
+	Available float64
+}
+
+// refundableAmount is what the customer paid minus every refund already issued or in flight on the order
+func refundableAmount(tx *gorm.DB, order models.Order) float64 {
+	paid := paidInvoiceTotal(order)
+	if paid == 0 {
+		paid = order.DepositPaid
+		if order.PaymentStatus == "paid" {
+			paid = order.TotalAmount
+		}
+	}
+	var refunded float64
+	tx.Model(&models.PaymentTransaction{}).Where("order_id = ? AND type = ? AND status IN ?", order.ID, "refund", []string{"success", "pending"}).
+		Select("COALESCE(SUM(amount), 0)").Scan(&refunded)
+
+	remaining := roundMoney(paid - refunded)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// refundableCharges lists the order's settled charges on gateways with a refund API (newest first)
+// with their unrefunded balance
+func (s *RefundService) refundableCharges(orderID uint) []chargeBalance {
+	gateways := append(PaymentGatewayNames(), "prismalink_direct")
+	var charges []models.PaymentTransaction
+	s.DB.Where("order_id = ? AND type <> ? AND status IN ? AND gateway IN ?", orderID, "refund", []string{"success", "refunded"}, gateways).
+		Order("id desc").Find(&charges)
+
+	var balances []chargeBalance
+	for _, charge := range charges {
+		if !gatewaySupportsRefund(charge.Gateway) {
+			continue
+		}
+		var committed float64
+		s.DB.Model(&models.Refund{}).Where("charge_tx_id = ? AND status IN ?", charge.ID,
+			[]string{models.RefundStatusRequested, models.RefundStatusProcessing, models.RefundStatusSucceeded}).
+			Select("COALESCE(SUM(amount), 0)").Scan(&committed)
+		if available := roundMoney(charge.Amount - committed); available > 0 {
+			balances = append(balances, chargeBalance{Charge: charge, Available: available})
+		}
+	}
+	return balances
+}
+
+// refundPortion is the share of a refund paid out in one go: back onto one charge for gateway refunds
+type refundPortion struct {
+	charge *models.PaymentTransaction
+	amount float64
+}
+
+// splitRefund spreads an amount over the charges in order (newest first), so each portion stays within
+// what its charge can still give back. The caller checks the charges cover the amount.
+func splitRefund(amount float64, charges []chargeBalance) []refundPortion {
+	var portions []refundPortion
+	remaining := amount
+	for i := range charges {
+		if remaining <= 0 {
+			break
+		}
+		amt := charges[i].Available
+		if amt > remaining {
+			amt = remaining
+		}
+		portions = append(portions, refundPortion{charge: &charges[i].Charge, amount: roundMoney(amt)})
+		remaining = roundMoney(remaining - amt)
+	}
+	return portions
+}
+
+// Request records a refund in status requested. Gateway refunds are split over the original charges
+// (newest first). Nothing is paid out yet: callers run ProcessOrder once their transaction has committed,
+// so no gateway call or customer email happens for a rolled back request.
+func (s *RefundService) Request(input RefundInput) ([]models.Refund, error) {
+	input.Amount = roundMoney(input.Amount)
+	if input.Amount <= 0 {
+		return nil, fmt.Errorf("refund amount must be greater than zero")
+	}
+
+	var refunds []models.Refund
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the order before reading its balance: a concurrent request waits here and then sees
+		// this one's refund rows, so two refunds can never both fit the same balance
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Invoices").First(&order, input.OrderID).Error; err != nil {
+			return fmt.Errorf("order not found")
+		}
+		refundable := refundableAmount(tx, order)
+		if input.Amount > refundable+0.01 {
+			return fmt.Errorf("refund amount exceeds refundable balance (Rp %.0f)", refundable)
+		}
+		if input.Type == "" {
+			input.Type = "partial"
+			if input.Amount >= refundable {
+				input.Type = "full"
+			}
+		}
+
+		charges := (&RefundService{DB: tx}).refundableCharges(order.ID)
+		chargeTotal := 0.0
+		for _, c := range charges {
+			chargeTotal += c.Available
+		}
+		if input.Method == "" {
+			input.Method = RefundMethodManual
+			if chargeTotal >= input.Amount-0.01 {
+				input.Method = RefundMethodGateway
+			}
+		}
+
+		// Portions: one per charge for gateway refunds, a single one otherwise
+		var portions []refundPortion
+		switch input.Method {
+		case RefundMethodGateway:
+			if chargeTotal < input.Amount-0.01 {
+				return fmt.Errorf("gateway refundable balance is Rp %.0f (charges on gateways without a refund API are not included), refund the rest to wallet or manually", chargeTotal)
+			}
+			portions = splitRefund(input.Amount, charges)
+		case RefundMethodWallet, RefundMethodManual:
+			portions = append(portions, refundPortion{amount: input.Amount})
+		default:
+			return fmt.Errorf("refund method must be gateway, wallet or manual")
+		}
+
+		// All portions commit together: a failing portion leaves no pending refund transaction behind
+		for _, p := range portions {
+			number, err := helpers.NextSequenceNumber(tx, helpers.SeqRefund)
+			if err != nil {
+				return err
+			}
+			refund := models.Refund{
+				RefundNumber: number,
+				OrderID:      order.ID,
+				Method:       input.Method,
+				Gateway:      input.Method,
+				Type:         input.Type,
+				Amount:       p.amount,
+				Status:       models.RefundStatusRequested,
+				Reason:       input.Reason,
+				Source:       input.Source,
+				SourceRef:    input.SourceRef,
+				RequestedBy:  input.RequestedBy,
+			}
+			refundTx := models.PaymentTransaction{
+				OrderID:       order.ID,
+				Gateway:       input.Method,
+				PaymentMethod: input.Method,
+				MerchantRefNo: number,
+				Type:          "refund",
+				Amount:        p.amount,
+				Status:        "pending",
+			}
+			if p.charge != nil {
+				refund.ChargeTxID = &p.charge.ID
+				refund.Gateway = p.charge.Gateway
+				refundTx.InvoiceID = p.charge.InvoiceID
+				refundTx.Gateway = p.charge.Gateway
+				refundTx.PaymentMethod = p.charge.PaymentMethod
+			}
+			if err := tx.Create(&refundTx).Error; err != nil {
+				return err
+			}
+			refund.RefundTxID = &refundTx.ID
+			if err := tx.Create(&refund).Error; err != nil {
+				return err
+			}
+			refunds = append(refunds, refund)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// Process executes a requested refund. Gateway outcomes are recorded on the refund (succeeded, failed or
+// still processing), so an error here means the refund could not be moved at all.
+func (s *RefundService) Process(id uint) (*models.Refund, error) {
+	var refund models.Refund
+	if err := s.DB.First(&refund, id).Error; err != nil {
+		return nil, fmt.Errorf("refund not found")
+	}
+	if err := s.transition(s.DB, &refund, models.RefundStatusProcessing); err != nil {
+		return nil, err
+	}
+
+	if refund.Method != RefundMethodGateway {
+		return &refund, s.complete(&refund, "")
+	}
+
+	var charge models.PaymentTransaction
+	if refund.ChargeTxID == nil || s.DB.First(&charge, *refund.ChargeTxID).Error != nil {
+		return &refund, s.fail(&refund, "original charge not found")
+	}
+	gateway, err := GatewayForTransaction(charge)
+	if err != nil {
+		return &refund, s.fail(&refund, err.Error())
+	}
+
+	result, err := gateway.Refund(RefundRequest{
+		Transaction: charge,
+		Amount:      refund.Amount,
+		Reason:      refund.Reason,
+		Reference:   refund.RefundNumber,
+	})
+	if err != nil {
+		if errors.Is(err, ErrRefundNotSupported) {
+			return &refund, s.fail(&refund, fmt.Sprintf("%s does not support API refunds, refund to wallet or manually", gateway.Name()))
+		}
+		return &refund, s.fail(&refund, err.Error())
+	}
+
+	switch result.Status {
+	case models.RefundStatusSucceeded:
+		return &refund, s.complete(&refund, result.GatewayRef)
+	case models.RefundStatusFailed:
+		return &refund, s.fail(&refund, result.Message)
+	}
+
+	// Accepted but not final yet: wait for the gateway (or finance) to confirm
+	refund.GatewayRef = result.GatewayRef
+	s.DB.Model(&refund).Update("gateway_ref", result.GatewayRef)
+	return &refund, nil
+}
+
+// ProcessOrder executes every requested refund of an order (after the request has been committed)
+func (s *RefundService) ProcessOrder(orderID uint) []models.Refund {
+	var requested []models.Refund
+	s.DB.Where("order_id = ? AND status = ?", orderID, models.RefundStatusRequested).Order("id").Find(&requested)
+
+	var refunds []models.Refund
+	for _, r := range requested {
+		refund, err := s.Process(r.ID)
+		if err != nil {
+			log.Printf("⚠️ Refund %s not processed: %v", r.RefundNumber, err)
+			continue
+		}
+		refunds = append(refunds, *refund)
+	}
+	return refunds
+}
+
+// ProcessPending picks up requested refunds left behind (e.g. the server stopped before processing them)
+func (s *RefundService) ProcessPending() {
+	var requested []models.Refund
+	s.DB.Where("status = ? AND created_at < ?", models.RefundStatusRequested, time.Now().Add(-5*time.Minute)).Find(&requested)
+	for _, r := range requested {
+		if _, err := s.Process(r.ID); err != nil {
+			log.Printf("⚠️ Refund %s not processed: %v", r.RefundNumber, err)
+		}
+	}
+}
+
+// Confirm completes a processing refund once the gateway or finance confirms the payout
+func (s *RefundService) Confirm(id uint, gatewayRef string) (*models.Refund, error) {
+	var refund models.Refund
+	if err := s.DB.First(&refund, id).Error; err != nil {
+		return nil, fmt.Errorf("refund not found")
+	}
+	if gatewayRef == "" {
+		gatewayRef = refund.GatewayRef
+	}
+	return &refund, s.complete(&refund, gatewayRef)
+}
+
+// Reject marks a processing refund as failed (payout bounced, gateway declined later, ...)
+func (s *RefundService) Reject(id uint, note string) (*models.Refund, error) {
+	var refund models.Refund
+	if err := s.DB.First(&refund, id).Error; err != nil {
+		return nil, fmt.Errorf("refund not found")
+	}
+	return &refund, s.fail(&refund, note)
+}
+
+// Retry re-requests a failed refund and processes it again, if its charge can still cover it
+func (s *RefundService) Retry(id uint) (*models.Refund, error) {
+	var refund models.Refund
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&refund, id).Error; err != nil {
+			return fmt.Errorf("refund not found")
+		}
+		if refund.ChargeTxID != nil {
+			available := 0.0
+			for _, c := range (&RefundService{DB: tx}).refundableCharges(refund.OrderID) {
+				if c.Charge.ID == *refund.ChargeTxID {
+					available = c.Available
+				}
+			}
+			if available < refund.Amount-0.01 {
+				return fmt.Errorf("charge only has Rp %.0f left to refund", available)
+			}
+		}
+		if err := s.transition(tx, &refund, models.RefundStatusRequested); err != nil {
+			return err
+		}
+		if refund.RefundTxID != nil {
+			tx.Model(&models.PaymentTransaction{}).Where("id = ?", *refund.RefundTxID).Update("status", "pending")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Process(refund.ID)
+}
+
+// GetRefund loads a refund with its order and original charge
+func (s *RefundService) GetRefund(id uint) (*models.Refund, error) {
+	var refund models.Refund
+	if err := s.DB.Preload("Order").Preload("ChargeTx").First(&refund, id).Error; err != nil {
+		return nil, fmt.Errorf("refund not found")
+	}
+	return &refund, nil
+}
+
+// ListRefunds - paginated refunds, newest first
+func (s *RefundService) ListRefunds(status string, orderID uint, page, limit int) ([]models.Refund, int64, error) {
+	query := s.DB.Model(&models.Refund{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if orderID != 0 {
+		query = query.Where("order_id = ?", orderID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var refunds []models.Refund
+	err := query.Preload("Order").Preload("ChargeTx").Order("created_at desc").Limit(limit).Offset((page - 1) * limit).Find(&refunds).Error
+	return refunds, total, err
+}
+
+// canMoveRefund reports whether the status machine allows from -> to
+func canMoveRefund(from, to string) bool {
+	for _, next := range refundTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition moves a refund along the status machine, guarding against concurrent moves
+func (s *RefundService) transition(tx *gorm.DB, refund *models.Refund, to string) error {
+	if !canMoveRefund(refund.Status, to) {
+		return fmt.Errorf("refund %s cannot move from %s to %s", refund.RefundNumber, refund.Status, to)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": to}
+	switch to {
+	case models.RefundStatusProcessing:
+		updates["attempts"] = gorm.Expr("attempts + 1")
+		updates["processed_at"] = now
+		refund.Attempts++
+		refund.ProcessedAt = &now
+	case models.RefundStatusSucceeded:
+		updates["completed_at"] = now
+		refund.CompletedAt = &now
+	case models.RefundStatusRequested:
+		updates["failure_note"] = ""
+		refund.FailureNote = ""
+	}
+
+	res := tx.Model(&models.Refund{}).Where("id = ? AND status = ?", refund.ID, refund.Status).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("refund %s was changed concurrently", refund.RefundNumber)
+	}
+	refund.Status = to
+	return nil
+}
+
+// complete is the succeeded transition: it settles the refund transaction and original charge,
+// pays the wallet, posts the reversing journal, updates the order and notifies the customer
+func (s *RefundService) complete(refund *models.Refund, gatewayRef string) error {
+	var order models.Order
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.transition(tx, refund, models.RefundStatusSucceeded); err != nil {
+			return err
+		}
+		if gatewayRef != "" {
+			refund.GatewayRef = gatewayRef
+			tx.Model(&models.Refund{}).Where("id = ?", refund.ID).Update("gateway_ref", gatewayRef)
+		}
+		if err := tx.Preload("Invoices").First(&order, refund.OrderID).Error; err != nil {
+			return fmt.Errorf("order not found")
+		}
+		now := time.Now()
+
+		if refund.RefundTxID != nil {
+			tx.Model(&models.PaymentTransaction{}).Where("id = ?", *refund.RefundTxID).Updates(map[string]interface{}{
+				"status":        "success",
+				"gateway_tx_id": refund.GatewayRef,
+			})
+		}
+		if refund.ChargeTxID != nil {
+			var charge models.PaymentTransaction
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&charge, *refund.ChargeTxID).Error; err == nil {
+				charge.RefundAmount = roundMoney(charge.RefundAmount + refund.Amount)
+				charge.RefundedAt = &now
+				if charge.RefundAmount >= charge.Amount-0.01 {
+					charge.Status = "refunded"
+				}
+				tx.Model(&charge).Updates(map[string]interface{}{
+					"refund_amount": charge.RefundAmount,
+					"refunded_at":   now,
+					"status":        charge.Status,
+				})
+			}
+		}
+
+		// Reference documents the customer sees in their wallet / the journal
+		refType, refID := "order", order.OrderNumber
+		journalType := "REFUND"
+		if refund.Source == "return" && refund.SourceRef != "" {
+			refType, refID = "return", refund.SourceRef
+			journalType = "RETURN"
+		}
+
+		creditCOA := uint(0)
+		if refund.Method == RefundMethodWallet {
+			var user models.User
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, order.UserID).Error; err != nil {
+				return fmt.Errorf("user not found for refund")
+			}
+			balanceBefore := user.Balance
+			if err := tx.Model(&user).Update("balance", gorm.Expr("balance + ?", refund.Amount)).Error; err != nil {
+				return err
+			}
+			tx.Create(&models.WalletTransaction{
+				UserID:        user.ID,
+				Type:          "refund",
+				Amount:        refund.Amount,
+				Description:   fmt.Sprintf("Refund %s for Order %s", refund.RefundNumber, order.OrderNumber),
+				ReferenceType: refType,
+				ReferenceID:   refID,
+				BalanceBefore: balanceBefore,
+				BalanceAfter:  balanceBefore + refund.Amount,
+			})
+			creditCOA, _ = helpers.GetCOAByMappingKey("WALLET_LIABILITY")
+		} else {
+			creditCOA, _ = helpers.GetPrimaryBankCOA(tx)
+		}
+
+		// Reversing journal: the paid invoices' accounts back out, wallet liability or bank out
+		if debits := refundSourceItems(order, refund.Amount); creditCOA != 0 && len(debits) > 0 {
+			if err := helpers.PostJournalWithTX(tx, refID, journalType, fmt.Sprintf("Refund %s via %s - Order %s", refund.RefundNumber, refund.Gateway, order.OrderNumber), append(debits,
+				models.JournalItem{COAID: creditCOA, Debit: 0, Credit: refund.Amount},
+			)); err != nil {
+				return err
+			}
+		}
+
+		// Explicit refunds drive the order's payment status; cancellations and edits manage it themselves
+		if refund.Source == "order" || refund.Source == "return" {
+			var refunded float64
+			tx.Model(&models.PaymentTransaction{}).Where("order_id = ? AND type = ? AND status = ?", order.ID, "refund", "success").
+				Select("COALESCE(SUM(amount), 0)").Scan(&refunded)
+			paid := paidInvoiceTotal(order)
+			if paid == 0 {
+				paid = order.TotalAmount
+			}
+			status := "refunded_partial"
+			if refunded >= paid-0.01 {
+				status = "refunded"
+			}
+			tx.Model(&models.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+				"payment_status": status,
+				"internal_notes": order.InternalNotes + fmt.Sprintf("\n[REFUND] %s Rp %.0f via %s: %s", refund.RefundNumber, refund.Amount, refund.Gateway, refund.Reason),
+			})
+		}
+
+		actor := order.UserID
+		if refund.RequestedBy != nil {
+			actor = *refund.RequestedBy
+		}
+		tx.Create(&models.OrderLog{
+			OrderID:           order.ID,
+			UserID:            actor,
+			Action:            "refund",
+			Note:              fmt.Sprintf("Refund %s of Rp %.0f succeeded via %s - %s", refund.RefundNumber, refund.Amount, refund.Gateway, refund.Reason),
+			IsCustomerVisible: true,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	helpers.NotifyUser(order.UserID, "REFUND_PROCESSED",
+		fmt.Sprintf("Refund of Rp %s has been processed for Order %s", helpers.FormatPrice(refund.Amount), order.OrderNumber),
+		map[string]interface{}{
+			"order_id":  order.ID,
+			"refund_id": refund.ID,
+			"amount":    refund.Amount,
+			"method":    refund.Method,
+		})
+	go func() {
+		var u models.User
+		if err := config.DB.First(&u, order.UserID).Error; err == nil {
+			helpers.SendRefundEmail(u.Email, order.OrderNumber, refund.Amount, refund.Type, refund.Reason)
+		}
+	}()
+	return nil
+}
+
+// fail is the failed transition: the refund transaction stops counting against the refundable balance
+func (s *RefundService) fail(refund *models.Refund, note string) error {
+	if strings.TrimSpace(note) == "" {
+		note = "refund failed"
+	}
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.transition(tx, refund, models.RefundStatusFailed); err != nil {
+			return err
+		}
+		refund.FailureNote = note
+		tx.Model(&models.Refund{}).Where("id = ?", refund.ID).Update("failure_note", note)
+		if refund.RefundTxID != nil {
+			tx.Model(&models.PaymentTransaction{}).Where("id = ?", *refund.RefundTxID).Update("status", "failed")
+		}
+
+		actor := uint(0)
+		if refund.RequestedBy != nil {
+			actor = *refund.RequestedBy
+		}
+		log.Printf("❌ Refund %s failed: %s", refund.RefundNumber, note)
+		return tx.Create(&models.OrderLog{
+			OrderID: refund.OrderID,
+			UserID:  actor,
+			Action:  "refund_failed",
+			Note:    fmt.Sprintf("Refund %s of Rp %.0f via %s failed: %s", refund.RefundNumber, refund.Amount, refund.Gateway, note),
+		}).Error
+	})
+}
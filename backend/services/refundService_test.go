@@ -0,0 +1,139 @@
+package services
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"forzashop/backend/models"
+)
+
+func TestCanMoveRefund(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{models.RefundStatusRequested, models.RefundStatusProcessing, true},
+		{models.RefundStatusRequested, models.RefundStatusFailed, true},
+		{models.RefundStatusRequested, models.RefundStatusSucceeded, false},
+		{models.RefundStatusProcessing, models.RefundStatusSucceeded, true},
+		{models.RefundStatusProcessing, models.RefundStatusFailed, true},
+		{models.RefundStatusProcessing, models.RefundStatusRequested, false},
+		{models.RefundStatusFailed, models.RefundStatusRequested, true},
+		{models.RefundStatusFailed, models.RefundStatusSucceeded, false},
+		{models.RefundStatusSucceeded, models.RefundStatusFailed, false},
+		{models.RefundStatusSucceeded, models.RefundStatusRequested, false},
+		{"unknown", models.RefundStatusProcessing, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if got := canMoveRefund(tt.from, tt.to); got != tt.want {
+				t.Errorf("canMoveRefund(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitRefund(t *testing.T) {
+	charges := func(available ...float64) []chargeBalance {
+		list := make([]chargeBalance, len(available))
+		for i, a := range available {
+			list[i] = chargeBalance{Charge: models.PaymentTransaction{ID: uint(i + 1)}, Available: a}
+		}
+		return list
+	}
+
+	tests := []struct {
+		name    string
+		amount  float64
+		charges []chargeBalance
+		want    []float64
+	}{
+		{"fits on the newest charge", 50000, charges(80000, 100000), []float64{50000}},
+		{"spills onto the older charge", 150000, charges(80000, 100000), []float64{80000, 70000}},
+		{"uses every charge exactly", 180000, charges(80000, 100000), []float64{80000, 100000}},
+		{"rounds each portion", 100.005, charges(50.002, 100), []float64{50, 50}},
+		{"no charges", 50000, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			portions := splitRefund(tt.amount, tt.charges)
+			if len(portions) != len(tt.want) {
+				t.Fatalf("got %d portions, want %d: %+v", len(portions), len(tt.want), portions)
+			}
+			for i, p := range portions {
+				if p.amount != tt.want[i] || p.charge.ID != uint(i+1) {
+					t.Errorf("portion %d = %v on charge %d, want %v on charge %d", i, p.amount, p.charge.ID, tt.want[i], i+1)
+				}
+			}
+		})
+	}
+}
+
+// stubRefundableOrder answers the queries Request makes for a paid order with refunds already issued
+func stubRefundableOrder(stub *stubDB, refunded float64, charges ...[]driver.Value) {
+	stub.on(`FROM "orders"`, []string{"id", "order_number", "payment_status", "total_amount"},
+		[]driver.Value{int64(1), "FORZA-2026-10-00001", "paid", 200000.0})
+	stub.on(`SUM(amount), 0) FROM "payment_transactions"`, []string{"coalesce"}, []driver.Value{refunded})
+	stub.on(`FROM "refunds"`, []string{"coalesce"}, []driver.Value{0.0})
+	stub.on(`FROM "payment_transactions"`, []string{"id", "order_id", "invoice_id", "gateway", "payment_method", "type", "amount", "status"}, charges...)
+	stub.on(`number_sequences`, []string{"last_value"}, []driver.Value{int64(1)})
+}
+
+func TestRequestChecksBalanceUnderOrderLock(t *testing.T) {
+	db, stub := newStubDB(t)
+	stubRefundableOrder(stub, 180000)
+
+	_, err := (&RefundService{DB: db}).Request(RefundInput{OrderID: 1, Amount: 50000, Method: RefundMethodManual})
+	if err == nil || !strings.Contains(err.Error(), "exceeds refundable balance") {
+		t.Fatalf("expected the over-refund to be refused, got %v\n%s", err, stub.dump())
+	}
+
+	lock := stub.index(`FROM "orders"`, "FOR UPDATE")
+	if lock < 0 || !stub.inTransaction(lock) {
+		t.Fatalf("order row not locked inside the transaction:\n%s", stub.dump())
+	}
+	if sum := stub.index("SUM(amount)"); sum < lock {
+		t.Errorf("refunded total read before the order lock:\n%s", stub.dump())
+	}
+	if stub.count("INSERT") > 0 {
+		t.Errorf("rows written for a refused refund:\n%s", stub.dump())
+	}
+}
+
+func TestRequestRecordsAllPortionsInOneTransaction(t *testing.T) {
+	t.Setenv("PAYMENT_SANDBOX_ENABLED", "true") // the sandbox is the gateway with a refund API
+	db, stub := newStubDB(t)
+	stubRefundableOrder(stub, 0,
+		[]driver.Value{int64(8), int64(1), int64(21), "sandbox", "VA", "balance", 120000.0, "success"},
+		[]driver.Value{int64(7), int64(1), int64(20), "sandbox", "VA", "deposit", 80000.0, "success"},
+	)
+
+	refunds, err := (&RefundService{DB: db}).Request(RefundInput{OrderID: 1, Amount: 150000, Method: RefundMethodGateway})
+	if err != nil {
+		t.Fatalf("%v\n%s", err, stub.dump())
+	}
+	if len(refunds) != 2 || refunds[0].Amount != 120000 || refunds[1].Amount != 30000 {
+		t.Fatalf("got refunds %+v, want 120000 then 30000", refunds)
+	}
+
+	stmts := stub.statements()
+	if stub.count("BEGIN") != 1 || stub.count("COMMIT") != 1 {
+		t.Fatalf("want one transaction:\n%s", stub.dump())
+	}
+	commit := stub.index("COMMIT")
+	inserts := 0
+	for i, stmt := range stmts {
+		if strings.HasPrefix(stmt, `INSERT INTO "payment_transactions"`) || strings.HasPrefix(stmt, `INSERT INTO "refunds"`) {
+			inserts++
+			if !stub.inTransaction(i) || i > commit {
+				t.Errorf("statement %d written outside the transaction:\n%s", i, stub.dump())
+			}
+		}
+	}
+	if inserts != 4 {
+		t.Errorf("got %d refund rows, want a transaction and a refund per portion:\n%s", inserts, stub.dump())
+	}
+}
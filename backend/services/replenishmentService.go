@@ -0,0 +1,542 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===============================================
+// REPLENISHMENT
+// Reorder point = daily velocity x (lead time + safety days), never below MinStockLevel. When the
+// stock position (available + incoming + already drafted) is at or under it, suggest enough to cover
+// lead time + safety + one review period. Suggestions become draft POs grouped per supplier.
+// ===============================================
+
+type ReplenishmentService struct {
+	DB *gorm.DB
+}
+
+func NewReplenishmentService() *ReplenishmentService {
+	return &ReplenishmentService{
+		DB: config.DB,
+	}
+}
+
+// ReorderSuggestion is the replenishment picture of one product/edition
+type ReorderSuggestion struct {
+	ProductID     uint    `json:"product_id"`
+	VariantID     *uint   `json:"variant_id"`
+	Name          string  `json:"name"`
+	SKU           string  `json:"sku"`
+	SupplierID    *uint   `json:"supplier_id"` // nil = no supplier known, can't be drafted
+	SupplierName  string  `json:"supplier_name"`
+	UnitCost      float64 `json:"unit_cost"`
+	Stock         int     `json:"stock"`
+	Available     int     `json:"available"` // Stock - reserved
+	Incoming      int     `json:"incoming"`  // Still to arrive on ordered POs (or Product.IncomingStock, whichever is higher)
+	OnDraft       int     `json:"on_draft"`  // On draft POs not approved yet
+	MinStockLevel int     `json:"min_stock_level"`
+	SoldQty       int     `json:"sold_qty"` // Paid sales in the velocity window
+	DailyVelocity float64 `json:"daily_velocity"`
+	DaysOfCover   float64 `json:"days_of_cover"` // (available + incoming) / velocity; -1 = no sales
+	LeadTimeDays  int     `json:"lead_time_days"`
+	MinOrderQty   int     `json:"min_order_qty"`
+	ReorderPoint  int     `json:"reorder_point"`
+	SuggestedQty  int     `json:"suggested_qty"`
+}
+
+// ReorderFilter narrows Suggestions
+type ReorderFilter struct {
+	SupplierID uint
+	All        bool // Include items that don't need reordering
+}
+
+// reorderSettings are the replenishment knobs (settings group "inventory")
+type reorderSettings struct {
+	VelocityDays    int
+	SafetyDays      int
+	ReviewDays      int
+	DefaultLeadDays int
+}
+
+func loadReorderSettings() reorderSettings {
+	get := func(key string, fallback int) int {
+		if n, err := strconv.Atoi(helpers.GetSetting(key, strconv.Itoa(fallback))); err == nil && n >= 0 {
+			return n
+		}
+		return fallback
+	}
+	cfg := reorderSettings{
+		VelocityDays:    get("reorder_velocity_days", 30),
+		SafetyDays:      get("reorder_safety_days", 7),
+		ReviewDays:      get("reorder_review_days", 30),
+		DefaultLeadDays: get("reorder_default_lead_days", 14),
+	}
+	cfg.VelocityDays = max(cfg.VelocityDays, 1)
+	return cfg
+}
+
+// itemKey identifies a product/edition in the aggregates (variant 0 = the product itself)
+type itemKey struct {
+	ProductID uint
+	VariantID uint
+}
+
+type itemQty struct {
+	ProductID uint
+	VariantID uint
+	Qty       int
+}
+
+func (s *ReplenishmentService) sumByItem(query *gorm.DB) (map[itemKey]int, error) {
+	var rows []itemQty
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[itemKey]int, len(rows))
+	for _, r := range rows {
+		out[itemKey{r.ProductID, r.VariantID}] = r.Qty
+	}
+	return out, nil
+}
+
+// supplierPick is where an item is bought from
+type supplierPick struct {
+	Supplier    models.Supplier
+	Cost        float64
+	LeadTime    int
+	MinOrderQty int
+}
+
+// primarySuppliers picks each product's supplier: the primary ProductSupplier, else the cheapest,
+// else whoever the product was last ordered from
+func (s *ReplenishmentService) primarySuppliers() (map[uint]supplierPick, error) {
+	var links []models.ProductSupplier
+	if err := s.DB.Preload("Supplier").
+		Joins("JOIN suppliers ON suppliers.id = product_suppliers.supplier_id AND suppliers.active = ?", true).
+		Order("product_suppliers.is_primary DESC, product_suppliers.cost ASC, product_suppliers.id ASC").
+		Find(&links).Error; err != nil {
+		return nil, err
+	}
+	picks := make(map[uint]supplierPick)
+	for _, link := range links {
+		if _, ok := picks[link.ProductID]; ok {
+			continue
+		}
+		picks[link.ProductID] = supplierPick{Supplier: link.Supplier, Cost: link.Cost, LeadTime: link.LeadTimeDays, MinOrderQty: link.MinOrderQty}
+	}
+
+	var last []struct {
+		ProductID  uint
+		SupplierID uint
+	}
+	if err := s.DB.Table("purchase_order_items").
+		Select("DISTINCT ON (purchase_order_items.product_id) purchase_order_items.product_id, purchase_orders.supplier_id").
+		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id").
+		Where("purchase_orders.status <> ?", "cancelled").
+		Order("purchase_order_items.product_id, purchase_orders.created_at DESC").
+		Scan(&last).Error; err != nil {
+		return nil, err
+	}
+	var suppliers []models.Supplier
+	s.DB.Where("active = ?", true).Find(&suppliers)
+	byID := make(map[uint]models.Supplier, len(suppliers))
+	for _, sup := range suppliers {
+		byID[sup.ID] = sup
+	}
+	for _, row := range last {
+		if _, ok := picks[row.ProductID]; ok {
+			continue
+		}
+		if sup, ok := byID[row.SupplierID]; ok {
+			picks[row.ProductID] = supplierPick{Supplier: sup}
+		}
+	}
+	return picks, nil
+}
+
+// Suggestions computes the reorder picture of every active ready product/edition, most urgent first
+func (s *ReplenishmentService) Suggestions(f ReorderFilter) ([]ReorderSuggestion, error) {
+	cfg := loadReorderSettings()
+	since := time.Now().AddDate(0, 0, -cfg.VelocityDays)
+
+	sold, err := s.sumByItem(s.DB.Table("order_items").
+		Select("order_items.product_id, COALESCE(order_items.variant_id, 0) AS variant_id, SUM(order_items.quantity) AS qty").
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.created_at >= ? AND orders.status <> ? AND orders.payment_status = ?", since, "cancelled", "paid").
+		Where("order_items.product_type IS NULL OR order_items.product_type <> ?", "po").
+		Group("order_items.product_id, COALESCE(order_items.variant_id, 0)"))
+	if err != nil {
+		return nil, err
+	}
+	incoming, err := s.sumByItem(s.openPOLines([]string{"ordered", "partial"}).
+		Select("purchase_order_items.product_id, COALESCE(purchase_order_items.variant_id, 0) AS variant_id, " +
+			"SUM(GREATEST(purchase_order_items.quantity - purchase_order_items.received_qty, 0)) AS qty"))
+	if err != nil {
+		return nil, err
+	}
+	drafted, err := s.sumByItem(s.openPOLines([]string{models.PODraft, models.POPendingApproval, models.POApproved}).
+		Select("purchase_order_items.product_id, COALESCE(purchase_order_items.variant_id, 0) AS variant_id, " +
+			"SUM(purchase_order_items.quantity) AS qty"))
+	if err != nil {
+		return nil, err
+	}
+	picks, err := s.primarySuppliers()
+	if err != nil {
+		return nil, err
+	}
+
+	var products []models.Product
+	if err := s.DB.Preload("Variants", "status = ?", "active").
+		Where("status = ? AND product_type = ?", "active", "ready").
+		Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	var out []ReorderSuggestion
+	for i := range products {
+		p := &products[i]
+		pick, hasSupplier := picks[p.ID]
+		if f.SupplierID != 0 && (!hasSupplier || pick.Supplier.ID != f.SupplierID) {
+			continue
+		}
+
+		lines := []*models.ProductVariant{nil}
+		if len(p.Variants) > 0 {
+			lines = lines[:0]
+			for j := range p.Variants {
+				lines = append(lines, &p.Variants[j])
+			}
+		}
+		for _, v := range lines {
+			sg := ReorderSuggestion{
+				ProductID: p.ID,
+				Name:      p.Name,
+				SKU:       p.SKU,
+				UnitCost:  p.CostFor(v),
+				Stock:     p.Stock,
+				Available: p.Stock - p.ReservedQty,
+			}
+			key := itemKey{ProductID: p.ID}
+			if v != nil {
+				sg.VariantID = &v.ID
+				sg.Name += " - " + v.Name
+				sg.SKU = v.SKU
+				sg.Stock, sg.Available = v.Stock, v.Stock-v.ReservedQty
+				key.VariantID = v.ID
+			} else {
+				// Editions have no threshold of their own; the product's applies to products without editions
+				sg.MinStockLevel = p.MinStockLevel
+			}
+			sg.Available = max(sg.Available, 0)
+			sg.Incoming = incoming[key]
+			if v == nil {
+				sg.Incoming = max(sg.Incoming, p.IncomingStock)
+			}
+			sg.OnDraft = drafted[key]
+			sg.SoldQty = sold[key]
+
+			sg.LeadTimeDays = cfg.DefaultLeadDays
+			if hasSupplier {
+				sid := pick.Supplier.ID
+				sg.SupplierID = &sid
+				sg.SupplierName = pick.Supplier.Name
+				sg.MinOrderQty = pick.MinOrderQty
+				if pick.Cost > 0 {
+					sg.UnitCost = pick.Cost
+				}
+				if pick.LeadTime > 0 {
+					sg.LeadTimeDays = pick.LeadTime
+				} else if pick.Supplier.LeadTimeDays > 0 {
+					sg.LeadTimeDays = pick.Supplier.LeadTimeDays
+				}
+			}
+
+			planReorder(&sg, cfg)
+			if sg.SuggestedQty > 0 || f.All {
+				out = append(out, sg)
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if (out[i].SuggestedQty > 0) != (out[j].SuggestedQty > 0) {
+			return out[i].SuggestedQty > 0
+		}
+		ci, cj := out[i].DaysOfCover, out[j].DaysOfCover
+		if ci < 0 {
+			ci = math.MaxFloat64
+		}
+		if cj < 0 {
+			cj = math.MaxFloat64
+		}
+		return ci < cj
+	})
+	return out, nil
+}
+
+// openPOLines are the PO lines of POs in the given statuses, grouped per product/edition
+func (s *ReplenishmentService) openPOLines(statuses []string) *gorm.DB {
+	return s.DB.Table("purchase_order_items").
+		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id").
+		Where("purchase_orders.status IN ?", statuses).
+		Group("purchase_order_items.product_id, COALESCE(purchase_order_items.variant_id, 0)")
+}
+
+// planReorder fills in velocity, cover, reorder point and suggested quantity
+func planReorder(sg *ReorderSuggestion, cfg reorderSettings) {
+	sg.DailyVelocity = math.Round(float64(sg.SoldQty)/float64(cfg.VelocityDays)*100) / 100
+	daily := float64(sg.SoldQty) / float64(cfg.VelocityDays)
+
+	sg.DaysOfCover = -1
+	if daily > 0 {
+		sg.DaysOfCover = math.Round(float64(sg.Available+sg.Incoming)/daily*10) / 10
+	}
+
+	sg.ReorderPoint = max(int(math.Ceil(daily*float64(sg.LeadTimeDays+cfg.SafetyDays))), sg.MinStockLevel)
+	if daily == 0 && sg.MinStockLevel == 0 {
+		return
+	}
+
+	position := sg.Available + sg.Incoming + sg.OnDraft
+	if position > sg.ReorderPoint {
+		return
+	}
+	target := max(int(math.Ceil(daily*float64(sg.LeadTimeDays+cfg.SafetyDays+cfg.ReviewDays))), sg.ReorderPoint+1)
+	sg.SuggestedQty = max(target-position, sg.MinOrderQty)
+}
+
+// DraftInput picks what to draft: explicit lines, or every current suggestion (of the given suppliers)
+type DraftInput struct {
+	SupplierIDs []uint      `json:"supplier_ids"`
+	Lines       []DraftLine `json:"lines" binding:"dive"`
+}
+
+type DraftLine struct {
+	ProductID  uint     `json:"product_id" binding:"required"`
+	VariantID  *uint    `json:"variant_id"`
+	SupplierID uint     `json:"supplier_id" binding:"required"`
+	Quantity   int      `json:"quantity" binding:"required,min=1"`
+	UnitCost   *float64 `json:"unit_cost"` // Defaults to the supplier cost
+}
+
+// CreateDrafts turns suggestions into draft POs, one per supplier
+func (s *ReplenishmentService) CreateDrafts(in DraftInput, userID uint) ([]models.PurchaseOrder, error) {
+	suggestions, err := s.Suggestions(ReorderFilter{All: len(in.Lines) > 0})
+	if err != nil {
+		return nil, err
+	}
+	costs := make(map[itemKey]ReorderSuggestion, len(suggestions))
+	for _, sg := range suggestions {
+		costs[itemKey{sg.ProductID, helpers.LocationVariantKey(sg.VariantID)}] = sg
+	}
+
+	lines := in.Lines
+	if len(lines) == 0 {
+		wanted := make(map[uint]bool, len(in.SupplierIDs))
+		for _, id := range in.SupplierIDs {
+			wanted[id] = true
+		}
+		for _, sg := range suggestions {
+			if sg.SuggestedQty <= 0 || sg.SupplierID == nil || (len(wanted) > 0 && !wanted[*sg.SupplierID]) {
+				continue
+			}
+			cost := sg.UnitCost
+			lines = append(lines, DraftLine{ProductID: sg.ProductID, VariantID: sg.VariantID, SupplierID: *sg.SupplierID, Quantity: sg.SuggestedQty, UnitCost: &cost})
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("tidak ada barang yang perlu dipesan ulang")
+	}
+
+	bySupplier := make(map[uint][]DraftLine)
+	var order []uint
+	for _, line := range lines {
+		if _, ok := bySupplier[line.SupplierID]; !ok {
+			order = append(order, line.SupplierID)
+		}
+		bySupplier[line.SupplierID] = append(bySupplier[line.SupplierID], line)
+	}
+
+	var pos []models.PurchaseOrder
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, supplierID := range order {
+			var supplier models.Supplier
+			if err := tx.Where("active = ?", true).First(&supplier, supplierID).Error; err != nil {
+				return fmt.Errorf("supplier %d not found", supplierID)
+			}
+			number, err := helpers.NextSequenceNumber(tx, helpers.SeqPurchaseOrder)
+			if err != nil {
+				return err
+			}
+			po := models.PurchaseOrder{
+				PONumber:   number,
+				SupplierID: supplier.ID,
+				CreatedBy:  &userID,
+				Status:     "draft",
+				Source:     "reorder",
+				Notes:      "Dibuat dari saran pemesanan ulang",
+			}
+			for _, line := range bySupplier[supplierID] {
+				if _, _, err := loadStockItem(tx, line.ProductID, line.VariantID); err != nil {
+					return err
+				}
+				sg := costs[itemKey{line.ProductID, helpers.LocationVariantKey(line.VariantID)}]
+				cost := sg.UnitCost
+				if line.UnitCost != nil {
+					cost = *line.UnitCost
+				}
+				item := models.PurchaseOrderItem{
+					ProductID: line.ProductID,
+					VariantID: line.VariantID,
+					Quantity:  line.Quantity,
+					UnitCost:  roundMoney(cost),
+					TotalCost: roundMoney(cost * float64(line.Quantity)),
+				}
+				po.Items = append(po.Items, item)
+				po.TotalAmount += item.TotalCost
+			}
+			po.TotalAmount = roundMoney(po.TotalAmount)
+			if err := tx.Create(&po).Error; err != nil {
+				return err
+			}
+			pos = append(pos, po)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, po := range pos {
+		helpers.LogAuditSimple(userID, "procurement", "CREATE", po.ID, fmt.Sprintf("Drafted PO %s from reorder suggestions (%d lines)", po.PONumber, len(po.Items)))
+	}
+	return pos, nil
+}
+
+// ApproveDrafts approves draft or submitted POs in one go, each within the user's approval limit and
+// none created or submitted by the user. Approved POs are placed with the supplier by sending them.
+// Returns the approved POs.
+func (s *ReplenishmentService) ApproveDrafts(ids []uint, userID uint) ([]models.PurchaseOrder, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("pilih setidaknya satu PO")
+	}
+
+	var approved []models.PurchaseOrder
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var pos []models.PurchaseOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").
+			Where("id IN ?", ids).Order("id").Find(&pos).Error; err != nil {
+			return err
+		}
+		if len(pos) != len(ids) {
+			return fmt.Errorf("PO tidak ditemukan")
+		}
+
+		poService := &PurchaseOrderService{DB: tx}
+		for i := range pos {
+			if pos[i].Status != models.PODraft && pos[i].Status != models.POPendingApproval {
+				return fmt.Errorf("PO %s sudah berstatus %s", pos[i].PONumber, pos[i].Status)
+			}
+			if err := poService.approve(tx, &pos[i], userID); err != nil {
+				return err
+			}
+			approved = append(approved, pos[i])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, po := range approved {
+		helpers.LogAuditSimple(userID, "procurement", "APPROVE", po.ID, fmt.Sprintf("Approved PO %s (Rp %s)", po.PONumber, helpers.FormatPrice(po.ApprovedAmount)))
+	}
+	return approved, nil
+}
+
+// ============================================
+// PRODUCT SUPPLIERS
+// ============================================
+
+// ProductSupplierInput links a product to a supplier
+type ProductSupplierInput struct {
+	ProductID    uint    `json:"product_id" binding:"required"`
+	SupplierID   uint    `json:"supplier_id" binding:"required"`
+	Cost         float64 `json:"cost" binding:"min=0"`
+	IsPrimary    bool    `json:"is_primary"`
+	LeadTimeDays int     `json:"lead_time_days" binding:"min=0"`
+	MinOrderQty  int     `json:"min_order_qty" binding:"min=0"`
+}
+
+// ListProductSuppliers returns the suppliers of a product, primary first
+func (s *ReplenishmentService) ListProductSuppliers(productID uint) ([]models.ProductSupplier, error) {
+	var links []models.ProductSupplier
+	err := s.DB.Preload("Supplier").Where("product_id = ?", productID).
+		Order("is_primary DESC, cost ASC, id ASC").Find(&links).Error
+	return links, err
+}
+
+// SaveProductSupplier creates or updates the product/supplier link; a primary link demotes the others
+func (s *ReplenishmentService) SaveProductSupplier(in ProductSupplierInput, userID uint) (*models.ProductSupplier, error) {
+	var link models.ProductSupplier
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, _, err := loadStockItem(tx, in.ProductID, nil); err != nil {
+			return err
+		}
+		var supplier models.Supplier
+		if err := tx.First(&supplier, in.SupplierID).Error; err != nil {
+			return fmt.Errorf("supplier not found")
+		}
+
+		tx.Where("product_id = ? AND supplier_id = ?", in.ProductID, in.SupplierID).Limit(1).Find(&link)
+		link.ProductID = in.ProductID
+		link.SupplierID = in.SupplierID
+		link.Cost = roundMoney(in.Cost)
+		link.IsPrimary = in.IsPrimary
+		link.LeadTimeDays = in.LeadTimeDays
+		link.MinOrderQty = in.MinOrderQty
+		if link.IsPrimary {
+			if err := tx.Model(&models.ProductSupplier{}).
+				Where("product_id = ? AND supplier_id <> ?", in.ProductID, in.SupplierID).
+				Update("is_primary", false).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit("Supplier").Save(&link).Error; err != nil {
+			return err
+		}
+		link.Supplier = supplier
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "procurement", "UPDATE", link.ID, fmt.Sprintf("Linked product %d to supplier %s", link.ProductID, link.Supplier.Name))
+	return &link, nil
+}
+
+// DeleteProductSupplier removes a product/supplier link
+func (s *ReplenishmentService) DeleteProductSupplier(id uint, userID uint) error {
+	result := s.DB.Delete(&models.ProductSupplier{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("product supplier not found")
+	}
+
+	helpers.LogAuditSimple(userID, "procurement", "DELETE", id, "Removed product supplier link")
+	return nil
+}
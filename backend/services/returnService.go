@@ -0,0 +1,592 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReturnService struct {
+	DB *gorm.DB
+}
+
+func NewReturnService() *ReturnService {
+	return &ReturnService{
+		DB: config.DB,
+	}
+}
+
+// Return request statuses
+const (
+	ReturnStatusRequested  = "requested"
+	ReturnStatusAuthorized = "authorized"
+	ReturnStatusInTransit  = "in_transit"
+	ReturnStatusReceived   = "received"
+	ReturnStatusInspected  = "inspected"
+	ReturnStatusCompleted  = "completed"
+	ReturnStatusRejected   = "rejected"
+	ReturnStatusCancelled  = "cancelled"
+)
+
+// Inspection outcomes per returned item
+const (
+	ReturnResolutionRestock          = "restock"
+	ReturnResolutionWriteOff         = "write_off"
+	ReturnResolutionReturnToSupplier = "return_to_supplier"
+)
+
+// ReturnItemInput is one line the customer wants to send back
+type ReturnItemInput struct {
+	OrderItemID uint   `json:"order_item_id"`
+	Quantity    int    `json:"quantity"`
+	Condition   string `json:"condition"`
+}
+
+// CreateReturnInput is the customer's RMA request
+type CreateReturnInput struct {
+	OrderID uint
+	UserID  uint
+	Reason  string
+	Note    string
+	Photos  []string // Public URLs from the upload pipeline
+	Items   []ReturnItemInput
+}
+
+// InspectItemInput is the warehouse verdict for one returned item
+type InspectItemInput struct {
+	ID           uint     `json:"id"`
+	Resolution   string   `json:"resolution"` // restock, write_off, return_to_supplier
+	Note         string   `json:"note"`
+	RefundAmount *float64 `json:"refund_amount"` // nil = the line's paid value
+}
+
+// ApproveReturnInput settles an inspected RMA
+type ApproveReturnInput struct {
+	RefundMethod string   `json:"refund_method"` // wallet (default), gateway, manual
+	RefundAmount *float64 `json:"refund_amount"` // nil = sum of the items' refund amounts
+	Note         string   `json:"note"`
+}
+
+func (s *ReturnService) load(db *gorm.DB, id uint) (models.ReturnRequest, error) {
+	var rma models.ReturnRequest
+	err := db.Preload("Items.OrderItem.Product").Preload("Items.OrderItem.Variant").Preload("Order").Preload("User").
+		First(&rma, id).Error
+	if err != nil {
+		return rma, fmt.Errorf("return request not found")
+	}
+	return rma, nil
+}
+
+// GetReturn returns an RMA with its items; userID other than 0 restricts it to that customer
+func (s *ReturnService) GetReturn(id, userID uint) (*models.ReturnRequest, error) {
+	rma, err := s.load(s.DB, id)
+	if err != nil {
+		return nil, err
+	}
+	if userID != 0 && rma.UserID != userID {
+		return nil, fmt.Errorf("return request not found")
+	}
+	return &rma, nil
+}
+
+// ListReturns lists RMAs, newest first. Empty filters are ignored.
+func (s *ReturnService) ListReturns(status string, orderID, userID uint, page, limit int) ([]models.ReturnRequest, int64, error) {
+	query := s.DB.Model(&models.ReturnRequest{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if orderID != 0 {
+		query = query.Where("order_id = ?", orderID)
+	}
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var returns []models.ReturnRequest
+	err := query.Preload("Items.OrderItem.Product").Preload("Order").Preload("User").
+		Order("created_at desc").Limit(limit).Offset((page - 1) * limit).Find(&returns).Error
+	return returns, total, err
+}
+
+// CreateReturn opens an RMA for items the customer has received
+func (s *ReturnService) CreateReturn(input CreateReturnInput) (*models.ReturnRequest, error) {
+	if len(input.Items) == 0 {
+		return nil, fmt.Errorf("pilih minimal satu item untuk diretur")
+	}
+
+	var order models.Order
+	if err := s.DB.Preload("Items.Product").Preload("Shipments.Items").
+		Where("id = ? AND user_id = ?", input.OrderID, input.UserID).First(&order).Error; err != nil {
+		return nil, fmt.Errorf("order not found")
+	}
+	if order.Status == "cancelled" {
+		return nil, fmt.Errorf("cannot return items of a cancelled order")
+	}
+
+	// Return window counts from completion (delivery of everything)
+	windowDays, _ := strconv.Atoi(helpers.GetSetting("rma_window_days", "14"))
+	if windowDays > 0 && order.CompletedAt != nil && time.Since(*order.CompletedAt) > time.Duration(windowDays)*24*time.Hour {
+		return nil, fmt.Errorf("return window of %d days has passed", windowDays)
+	}
+
+	returnable, err := s.returnableQuantities(order)
+	if err != nil {
+		return nil, err
+	}
+
+	items := map[uint]models.OrderItem{}
+	for _, item := range order.Items {
+		items[item.ID] = item
+	}
+
+	var rma models.ReturnRequest
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var lines []models.ReturnItem
+		seen := map[uint]bool{}
+		for _, in := range input.Items {
+			item, ok := items[in.OrderItemID]
+			if !ok || seen[in.OrderItemID] {
+				return fmt.Errorf("invalid order item %d", in.OrderItemID)
+			}
+			seen[in.OrderItemID] = true
+			if in.Quantity <= 0 || in.Quantity > returnable[item.ID] {
+				return fmt.Errorf("%s: only %d unit(s) can be returned", item.Product.Name, returnable[item.ID])
+			}
+			lines = append(lines, models.ReturnItem{
+				OrderItemID:  item.ID,
+				Quantity:     in.Quantity,
+				Condition:    in.Condition,
+				RefundAmount: lineRefundValue(order, item, in.Quantity),
+			})
+		}
+
+		rmaNumber, err := helpers.NextSequenceNumber(tx, helpers.SeqReturn)
+		if err != nil {
+			return err
+		}
+		photos, _ := json.Marshal(input.Photos)
+
+		rma = models.ReturnRequest{
+			RMANumber:    rmaNumber,
+			OrderID:      order.ID,
+			UserID:       input.UserID,
+			Status:       ReturnStatusRequested,
+			Reason:       input.Reason,
+			CustomerNote: input.Note,
+			Photos:       photos,
+			Items:        lines,
+		}
+		if err := tx.Create(&rma).Error; err != nil {
+			return fmt.Errorf("gagal membuat retur: %v", err)
+		}
+
+		tx.Create(&models.OrderLog{
+			OrderID:           order.ID,
+			UserID:            input.UserID,
+			Action:            "return_requested",
+			Note:              fmt.Sprintf("Return %s requested (%s)", rma.RMANumber, input.Reason),
+			IsCustomerVisible: true,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go helpers.NotifyAdmin("RETURN_REQUESTED", fmt.Sprintf("Return %s requested for Order %s", rma.RMANumber, order.OrderNumber), map[string]interface{}{
+		"order_id":  order.ID,
+		"return_id": rma.ID,
+	})
+
+	return &rma, nil
+}
+
+// Authorize accepts the request; the customer can now ship the items back
+func (s *ReturnService) Authorize(id, adminID uint, note string) (*models.ReturnRequest, error) {
+	return s.transition(id, adminID, []string{ReturnStatusRequested}, func(tx *gorm.DB, rma *models.ReturnRequest) error {
+		now := time.Now()
+		rma.Status = ReturnStatusAuthorized
+		rma.AuthorizedBy = &adminID
+		rma.AuthorizedAt = &now
+		if note != "" {
+			rma.ResolutionNote = note
+		}
+		return nil
+	}, "return_authorized", "Return %s approved, please ship the items back")
+}
+
+// Reject closes the request without refund (before settlement)
+func (s *ReturnService) Reject(id, adminID uint, note string) (*models.ReturnRequest, error) {
+	allowed := []string{ReturnStatusRequested, ReturnStatusAuthorized, ReturnStatusInTransit, ReturnStatusReceived, ReturnStatusInspected}
+	return s.transition(id, adminID, allowed, func(tx *gorm.DB, rma *models.ReturnRequest) error {
+		now := time.Now()
+		rma.Status = ReturnStatusRejected
+		rma.RejectedAt = &now
+		rma.ResolutionNote = note
+		return nil
+	}, "return_rejected", "Return %s rejected")
+}
+
+// Cancel lets the customer withdraw the request before anything was shipped back
+func (s *ReturnService) Cancel(id, userID uint) (*models.ReturnRequest, error) {
+	rma, err := s.GetReturn(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(rma.ID, userID, []string{ReturnStatusRequested, ReturnStatusAuthorized}, func(tx *gorm.DB, rma *models.ReturnRequest) error {
+		rma.Status = ReturnStatusCancelled
+		return nil
+	}, "return_cancelled", "Return %s cancelled by customer")
+}
+
+// ShipBack records the customer's return shipment
+func (s *ReturnService) ShipBack(id, userID uint, carrier, trackingNumber string) (*models.ReturnRequest, error) {
+	if trackingNumber == "" {
+		return nil, fmt.Errorf("tracking number is required")
+	}
+	rma, err := s.GetReturn(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.transition(rma.ID, userID, []string{ReturnStatusAuthorized}, func(tx *gorm.DB, rma *models.ReturnRequest) error {
+		now := time.Now()
+		rma.Status = ReturnStatusInTransit
+		rma.ReturnCarrier = carrier
+		rma.ReturnTrackingNumber = trackingNumber
+		rma.ReturnShippedAt = &now
+		return nil
+	}, "return_shipped", "Return %s shipped back to warehouse")
+}
+
+// Receive marks the parcel as arrived at the warehouse (dropped off in person counts too)
+func (s *ReturnService) Receive(id, adminID uint) (*models.ReturnRequest, error) {
+	return s.transition(id, adminID, []string{ReturnStatusAuthorized, ReturnStatusInTransit}, func(tx *gorm.DB, rma *models.ReturnRequest) error {
+		now := time.Now()
+		rma.Status = ReturnStatusReceived
+		rma.ReceivedAt = &now
+		return nil
+	}, "return_received", "Return %s received at warehouse")
+}
+
+// Inspect records the verdict for every returned item
+func (s *ReturnService) Inspect(id, adminID uint, items []InspectItemInput) (*models.ReturnRequest, error) {
+	return s.transition(id, adminID, []string{ReturnStatusReceived, ReturnStatusInspected}, func(tx *gorm.DB, rma *models.ReturnRequest) error {
+		verdicts := map[uint]InspectItemInput{}
+		for _, in := range items {
+			switch in.Resolution {
+			case ReturnResolutionRestock, ReturnResolutionWriteOff, ReturnResolutionReturnToSupplier:
+			default:
+				return fmt.Errorf("invalid resolution '%s' (restock, write_off, return_to_supplier)", in.Resolution)
+			}
+			verdicts[in.ID] = in
+		}
+
+		for i := range rma.Items {
+			item := &rma.Items[i]
+			verdict, ok := verdicts[item.ID]
+			if !ok {
+				return fmt.Errorf("missing inspection result for %s", item.OrderItem.Product.Name)
+			}
+			item.Resolution = verdict.Resolution
+			item.InspectionNote = verdict.Note
+			if verdict.RefundAmount != nil {
+				if *verdict.RefundAmount < 0 {
+					return fmt.Errorf("refund amount cannot be negative")
+				}
+				item.RefundAmount = roundMoney(*verdict.RefundAmount)
+			}
+			if err := tx.Save(item).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		rma.Status = ReturnStatusInspected
+		rma.InspectedBy = &adminID
+		rma.InspectedAt = &now
+		return nil
+	}, "return_inspected", "Return %s inspected")
+}
+
+// Approve settles an inspected RMA: stock movements per verdict and their journals in one transaction,
+// then executes the refund (wallet, gateway or manual) through the refund status machine.
+func (s *ReturnService) Approve(id uint, admin models.User, input ApproveReturnInput) (*models.ReturnRequest, error) {
+	if input.RefundMethod == "" {
+		input.RefundMethod = "wallet"
+	}
+	if input.RefundMethod != RefundMethodWallet && input.RefundMethod != RefundMethodGateway && input.RefundMethod != RefundMethodManual {
+		return nil, fmt.Errorf("refund_method must be wallet, gateway or manual")
+	}
+
+	var rma models.ReturnRequest
+	var order models.Order
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.ReturnRequest{}, id).Error; err != nil {
+			return fmt.Errorf("return request not found")
+		}
+		if rma, err = s.load(tx, id); err != nil {
+			return err
+		}
+		if rma.Status != ReturnStatusInspected {
+			return fmt.Errorf("return must be inspected before approval (status: %s)", rma.Status)
+		}
+		// Locked: the refundable cap below must not race admin refunds or other RMAs of the order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Invoices").Preload("Items").First(&order, rma.OrderID).Error; err != nil {
+			return fmt.Errorf("order not found")
+		}
+
+		// 1. Refund amount, capped at what is still refundable on the order
+		amount := 0.0
+		for _, item := range rma.Items {
+			amount += item.RefundAmount
+		}
+		if input.RefundAmount != nil {
+			amount = *input.RefundAmount
+		}
+		amount = roundMoney(amount)
+		if amount < 0 {
+			return fmt.Errorf("refund amount cannot be negative")
+		}
+		refundable := refundableAmount(tx, order)
+		if amount > refundable {
+			return fmt.Errorf("refund amount exceeds refundable balance (Rp %.0f)", refundable)
+		}
+
+		// 2. Goods: movement_type 'return' per item, plus the inventory side of the journal
+		for _, item := range rma.Items {
+			if err := s.settleItem(tx, rma, item, admin.ID); err != nil {
+				return err
+			}
+		}
+
+		// 3. Money back (payment status follows once the refund succeeds)
+		rma.RefundMethod = input.RefundMethod
+		if amount > 0 {
+			if err := s.refund(tx, &rma, order, amount, input.RefundMethod, admin.ID); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Order{}).Where("id = ?", order.ID).
+				Update("internal_notes", order.InternalNotes+fmt.Sprintf("\n[RMA] %s refund Rp %.0f via %s", rma.RMANumber, amount, rma.RefundMethod)).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		rma.Status = ReturnStatusCompleted
+		rma.RefundAmount = amount
+		rma.ApprovedBy = &admin.ID
+		rma.ApprovedAt = &now
+		if input.Note != "" {
+			rma.ResolutionNote = input.Note
+		}
+		if err := tx.Omit(clause.Associations).Save(&rma).Error; err != nil {
+			return err
+		}
+
+		tx.Create(&models.OrderLog{
+			OrderID:           order.ID,
+			UserID:            admin.ID,
+			Action:            "return_completed",
+			Note:              fmt.Sprintf("Return %s approved. Refund Rp %.0f via %s", rma.RMANumber, amount, rma.RefundMethod),
+			IsCustomerVisible: true,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.Cache.Flush()
+	(&RefundService{DB: s.DB}).ProcessOrder(order.ID)
+	helpers.NotifyUser(rma.UserID, "RETURN_COMPLETED",
+		fmt.Sprintf("Return %s for Order %s has been approved. Refund: Rp %s", rma.RMANumber, order.OrderNumber, helpers.FormatPrice(rma.RefundAmount)),
+		map[string]interface{}{
+			"order_id":  order.ID,
+			"return_id": rma.ID,
+			"amount":    rma.RefundAmount,
+			"method":    rma.RefundMethod,
+		})
+
+	return &rma, nil
+}
+
+// settleItem moves one returned line according to its inspection verdict
+func (s *ReturnService) settleItem(tx *gorm.DB, rma models.ReturnRequest, item models.ReturnItem, adminID uint) error {
+	line := item.OrderItem
+	cost := roundMoney(line.COGSSnapshot * float64(item.Quantity))
+	note := fmt.Sprintf("RMA %s: %s", rma.RMANumber, item.Resolution)
+	if item.InspectionNote != "" {
+		note += " - " + item.InspectionNote
+	}
+
+	coaCOGSID, _ := helpers.GetCOAByMappingKey("COGS_EXPENSE")
+	switch item.Resolution {
+	case ReturnResolutionRestock:
+		// Back on the shelf at the default location: physical stock up, cost moves from COGS back to inventory
+		if err := helpers.RecordLocationStockMovement(tx, 0, line.ProductID, line.VariantID, item.Quantity, "physical", "return", "RMA", rma.RMANumber, note, &adminID); err != nil {
+			return err
+		}
+		if err := helpers.AdjustStock(tx, line.ProductID, line.VariantID, map[string]interface{}{"stock": gorm.Expr("stock + ?", item.Quantity)}); err != nil {
+			return err
+		}
+		coaInvID, _ := helpers.GetCOAByMappingKey("INVENTORY_ASSET")
+		if coaInvID != 0 && coaCOGSID != 0 && cost > 0 {
+			return helpers.PostJournalWithTX(tx, rma.RMANumber, "RETURN", fmt.Sprintf("Return Restock - %s", line.Product.Name), []models.JournalItem{
+				{COAID: coaInvID, Debit: cost, Credit: 0},
+				{COAID: coaCOGSID, Debit: 0, Credit: cost},
+			})
+		}
+
+	case ReturnResolutionWriteOff:
+		// Received but unsellable: logged, stock untouched, the cost stays expensed
+		helpers.RecordVariantStockMovement(tx, line.ProductID, line.VariantID, item.Quantity, "write_off", "return", "RMA", rma.RMANumber, note, &adminID)
+
+	case ReturnResolutionReturnToSupplier:
+		// Sent on to the supplier: the cost becomes a claim against what we owe them
+		helpers.RecordVariantStockMovement(tx, line.ProductID, line.VariantID, item.Quantity, "supplier_return", "return", "RMA", rma.RMANumber, note, &adminID)
+		coaAPID, _ := helpers.GetPayableCOA()
+		if coaAPID != 0 && coaCOGSID != 0 && cost > 0 {
+			return helpers.PostJournalWithTX(tx, rma.RMANumber, "RETURN", fmt.Sprintf("Return to Supplier - %s", line.Product.Name), []models.JournalItem{
+				{COAID: coaAPID, Debit: cost, Credit: 0},
+				{COAID: coaCOGSID, Debit: 0, Credit: cost},
+			})
+		}
+
+	default:
+		return fmt.Errorf("item %s has no inspection result", line.Product.Name)
+	}
+	return nil
+}
+
+// refund requests the money back through the refund status machine (see RefundService), which pays out
+// and reverses revenue (and PPN) once it succeeds. "gateway" refunds through the original charges when
+// they cover the amount and otherwise records a manual payout for finance.
+func (s *ReturnService) refund(tx *gorm.DB, rma *models.ReturnRequest, order models.Order, amount float64, method string, adminID uint) error {
+	if method == RefundMethodGateway {
+		method = ""
+	}
+	refunds, err := (&RefundService{DB: tx}).Request(RefundInput{
+		OrderID:     order.ID,
+		Amount:      amount,
+		Method:      method,
+		Reason:      fmt.Sprintf("Return %s", rma.RMANumber),
+		Source:      "return",
+		SourceRef:   rma.RMANumber,
+		RequestedBy: &adminID,
+	})
+	if err != nil {
+		return err
+	}
+	if len(refunds) > 0 {
+		rma.PaymentTxID = refunds[0].RefundTxID
+		rma.RefundMethod = refunds[0].Method // gateway falls back to manual when the charges cannot be refunded by API
+	}
+	return nil
+}
+
+// returnableQuantities: units the customer has been sent, minus units already in an open or settled RMA
+func (s *ReturnService) returnableQuantities(order models.Order) (map[uint]int, error) {
+	returnable := map[uint]int{}
+	if len(order.Shipments) > 0 {
+		returnable = shippedQuantities(order)
+	} else if order.Status == "shipped" || order.Status == "completed" || order.FulfillmentStatus == "shipped" || order.FulfillmentStatus == "delivered" {
+		// Shipped before shipments existed: the whole order went out
+		for _, item := range order.Items {
+			returnable[item.ID] = item.Quantity
+		}
+	} else {
+		return nil, fmt.Errorf("only shipped items can be returned")
+	}
+
+	var taken []struct {
+		OrderItemID uint
+		Qty         int
+	}
+	s.DB.Table("return_items").
+		Select("return_items.order_item_id, SUM(return_items.quantity) AS qty").
+		Joins("JOIN return_requests ON return_requests.id = return_items.return_request_id").
+		Where("return_requests.order_id = ? AND return_requests.status NOT IN ?", order.ID, []string{ReturnStatusRejected, ReturnStatusCancelled}).
+		Group("return_items.order_item_id").Scan(&taken)
+	for _, t := range taken {
+		returnable[t.OrderItemID] -= t.Qty
+	}
+	return returnable, nil
+}
+
+// transition loads an RMA, checks its status, applies fn and saves it with an order log
+func (s *ReturnService) transition(id, actorID uint, allowed []string, fn func(tx *gorm.DB, rma *models.ReturnRequest) error, action, noteFormat string) (*models.ReturnRequest, error) {
+	var rma models.ReturnRequest
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if rma, err = s.load(tx, id); err != nil {
+			return err
+		}
+		ok := false
+		for _, status := range allowed {
+			if rma.Status == status {
+				ok = true
+			}
+		}
+		if !ok {
+			return fmt.Errorf("return %s is %s, action not allowed", rma.RMANumber, rma.Status)
+		}
+
+		if err := fn(tx, &rma); err != nil {
+			return err
+		}
+		if err := tx.Omit(clause.Associations).Save(&rma).Error; err != nil {
+			return err
+		}
+
+		tx.Create(&models.OrderLog{
+			OrderID:           rma.OrderID,
+			UserID:            actorID,
+			Action:            action,
+			Note:              fmt.Sprintf(noteFormat, rma.RMANumber),
+			IsCustomerVisible: true,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rma.UserID != actorID {
+		go helpers.NotifyUser(rma.UserID, "RETURN_UPDATE", fmt.Sprintf("Return %s status: %s", rma.RMANumber, rma.Status), map[string]interface{}{
+			"order_id":  rma.OrderID,
+			"return_id": rma.ID,
+			"status":    rma.Status,
+		})
+	}
+	return &rma, nil
+}
+
+// lineRefundValue is what the customer paid for qty units of a line (after discount, plus PPN when added on top)
+func lineRefundValue(order models.Order, item models.OrderItem, qty int) float64 {
+	if item.Quantity <= 0 {
+		return 0
+	}
+	shares := allocateLineAmounts(order.Items, order.DiscountAmount)
+	value := 0.0
+	for i, it := range order.Items {
+		if it.ID == item.ID {
+			value = shares[i]
+			if !order.TaxInclusive {
+				value += it.TaxAmount
+			}
+		}
+	}
+	return roundMoney(value / float64(item.Quantity) * float64(qty))
+}
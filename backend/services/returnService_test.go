@@ -0,0 +1,32 @@
+package services
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"forzashop/backend/models"
+)
+
+func TestApproveLocksOrderBeforeRefundCap(t *testing.T) {
+	_, stub := newStubDB(t)
+	stub.on(`FROM "return_requests"`, []string{"id", "order_id", "status", "rma_number"}, []driver.Value{int64(7), int64(1), ReturnStatusInspected, "RMA-2026-00007"})
+	stub.on(`FROM "return_items"`, []string{"id", "return_request_id", "order_item_id", "quantity", "refund_amount"}, []driver.Value{int64(70), int64(7), int64(10), int64(1), 50000.0})
+	stub.on(`FROM "orders"`, []string{"id", "order_number", "payment_status", "total_amount"}, []driver.Value{int64(1), "FORZA-2026-10-00001", "paid", 50000.0})
+	// Another refund of the whole order is already in flight
+	stub.on(`SUM(amount)`, []string{"sum"}, []driver.Value{50000.0})
+
+	_, err := NewReturnService().Approve(7, models.User{ID: 1}, ApproveReturnInput{RefundMethod: RefundMethodWallet})
+	if err == nil || !strings.Contains(err.Error(), "exceeds refundable") {
+		t.Fatalf("expected the refund cap to reject the RMA, got %v\n%s", err, stub.dump())
+	}
+
+	lock := stub.index(`FROM "orders"`, "FOR UPDATE")
+	sum := stub.index(`SUM(amount)`)
+	if lock < 0 || !stub.inTransaction(lock) {
+		t.Fatalf("order row not locked inside the transaction:\n%s", stub.dump())
+	}
+	if sum < lock {
+		t.Errorf("refundable balance read before the order lock:\n%s", stub.dump())
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+)
+
+func TestIsPaidFor(t *testing.T) {
+	lineID := uint(2)
+	now := time.Now()
+	ready := models.OrderItem{ID: 1, ProductType: "ready"}
+	po := models.OrderItem{ID: lineID, ProductType: "po"}
+
+	tests := []struct {
+		name     string
+		item     models.OrderItem
+		invoices []models.Invoice
+		want     bool
+	}{
+		{"no invoices", ready, nil, true},
+		{"settled line", models.OrderItem{ID: 1, SettledAt: &now}, []models.Invoice{{Type: "full", Status: "unpaid"}}, true},
+		{"ready line unpaid", ready, []models.Invoice{{Type: "full", Status: "unpaid"}}, false},
+		{"ready line paid", ready, []models.Invoice{{Type: "full", Status: "paid"}}, true},
+		{"po deposit paid, balance open", po, []models.Invoice{
+			{OrderItemID: &lineID, Type: "deposit", Status: "paid"},
+			{OrderItemID: &lineID, Type: "balance", Status: "unpaid"},
+		}, false},
+		{"po deposit and balance paid", po, []models.Invoice{
+			{OrderItemID: &lineID, Type: "deposit", Status: "paid"},
+			{OrderItemID: &lineID, Type: "balance", Status: "paid_late"},
+		}, true},
+		{"ready line ignores a po balance", ready, []models.Invoice{
+			{Type: "full", Status: "paid"},
+			{OrderItemID: &lineID, Type: "balance", Status: "unpaid"},
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := models.Order{Invoices: tt.invoices}
+			if got := isPaidFor(order, tt.item); got != tt.want {
+				t.Errorf("isPaidFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateShipmentTxLocksOrderAndRefusesUnpaidLines(t *testing.T) {
+	db, stub := newStubDB(t)
+	stub.on(`FROM "orders"`, []string{"id", "status", "order_number"}, []driver.Value{int64(1), "processing", "FORZA-2026-10-00001"})
+	stub.on(`FROM "order_items"`, []string{"id", "order_id", "product_id", "quantity", "product_type"}, []driver.Value{int64(10), int64(1), int64(5), int64(2), "ready"})
+	stub.on(`FROM "products"`, []string{"id", "name"}, []driver.Value{int64(5), "Figure"})
+	stub.on(`FROM "invoices"`, []string{"id", "order_id", "type", "status", "amount"}, []driver.Value{int64(20), int64(1), "full", "unpaid", 100000.0})
+
+	// The caller's copy is stale: it still thinks nothing blocks shipping
+	order := models.Order{ID: 1, Status: "processing"}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		_, err := (&ShipmentService{DB: tx}).CreateShipmentTx(tx, &order, CreateShipmentInput{
+			OrderID: 1,
+			Items:   []ShipmentItemInput{{OrderItemID: 10}},
+		})
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "belum lunas") {
+		t.Fatalf("expected the unpaid line to be refused, got %v\n%s", err, stub.dump())
+	}
+
+	lock := stub.index(`FROM "orders"`, "FOR UPDATE")
+	if lock < 0 || !stub.inTransaction(lock) {
+		t.Fatalf("order row not locked inside the transaction:\n%s", stub.dump())
+	}
+	if shipments := stub.index(`FROM "shipments"`); shipments < lock {
+		t.Errorf("shipments read before the order lock:\n%s", stub.dump())
+	}
+	if stub.count(`INSERT INTO "shipments"`) > 0 {
+		t.Errorf("shipment created for an unpaid line:\n%s", stub.dump())
+	}
+}
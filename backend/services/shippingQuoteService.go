@@ -0,0 +1,241 @@
+package services
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CartItemInput is a single cart line submitted by the customer
+type CartItemInput struct {
+	ProductID uint  `json:"product_id"`
+	VariantID *uint `json:"variant_id"` // Required for products sold in several editions
+	Quantity  int   `json:"quantity"`
+}
+
+// cartLineKey identifies a cart line by product and (optional) edition
+type cartLineKey struct {
+	ProductID uint
+	VariantID uint
+}
+
+func lineKey(productID uint, variantID *uint) cartLineKey {
+	key := cartLineKey{ProductID: productID}
+	if variantID != nil {
+		key.VariantID = *variantID
+	}
+	return key
+}
+
+// ShippingQuoteInput is the payload for requesting a locked shipping quote
+type ShippingQuoteInput struct {
+	Items      []CartItemInput `json:"items" binding:"required"`
+	Country    string          `json:"country"`
+	State      string          `json:"state"`
+	City       string          `json:"city"`
+	PostalCode string          `json:"postal_code"`
+	OptionID   string          `json:"option_id" binding:"required"` // ShippingOption.ID picked by the customer
+}
+
+// CreateQuote prices the selected shipping option server-side and locks it, together
+// with the current item prices, in a signed quote that expires after a short TTL.
+func (s *ShippingService) CreateQuote(input ShippingQuoteInput, userID uint) (*models.ShippingQuote, error) {
+	if len(input.Items) == 0 {
+		return nil, fmt.Errorf("cart is empty")
+	}
+
+	var lockedItems []models.QuotedItem
+	var totalWeight, subtotal float64
+	for _, item := range input.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("invalid quantity for product %d", item.ProductID)
+		}
+		product, variant, err := LoadSellable(s.DB, item.ProductID, item.VariantID)
+		if err != nil {
+			return nil, err
+		}
+		unitPrice, unitWeight := product.PriceFor(variant), product.WeightFor(variant)
+		totalWeight += unitWeight * float64(item.Quantity)
+		subtotal += unitPrice * float64(item.Quantity)
+		lockedItems = append(lockedItems, models.QuotedItem{
+			ProductID: product.ID,
+			VariantID: item.VariantID,
+			Quantity:  item.Quantity,
+			Price:     unitPrice,
+			Weight:    unitWeight,
+		})
+	}
+	totalWeight = roundWeight(totalWeight)
+
+	// Same calculation as the checkout options list, but with the weight computed from DB
+	options := s.GetShippingOptions(ShippingOptionsInput{
+		TotalWeight: totalWeight,
+		Country:     input.Country,
+		State:       input.State,
+		City:        input.City,
+		PostalCode:  input.PostalCode,
+		Subtotal:    subtotal,
+	})
+
+	var selected *ShippingOption
+	for i := range options {
+		if options[i].ID == input.OptionID {
+			selected = &options[i]
+			break
+		}
+	}
+	if selected == nil {
+		return nil, fmt.Errorf("Opsi pengiriman tidak tersedia untuk keranjang ini, silakan muat ulang ongkir")
+	}
+
+	ttl, err := strconv.Atoi(helpers.GetSetting("shipping_quote_ttl_minutes", "15"))
+	if err != nil || ttl <= 0 {
+		ttl = 15
+	}
+
+	itemsJSON, _ := json.Marshal(lockedItems)
+	quote := models.ShippingQuote{
+		QuoteID:        "SQ-" + helpers.GenerateRandomString(12),
+		UserID:         userID,
+		Country:        NormalizeCountryISO(input.Country),
+		PostalCode:     strings.TrimSpace(input.PostalCode),
+		TotalWeight:    totalWeight,
+		Subtotal:       subtotal,
+		OptionID:       selected.ID,
+		ShippingMethod: selected.Name,
+		ShippingCost:   selected.Cost,
+		LockedItems:    itemsJSON,
+		ExpiresAt:      time.Now().Add(time.Duration(ttl) * time.Minute),
+	}
+	quote.Signature = signShippingQuote(quote, lockedItems)
+
+	if err := s.DB.Create(&quote).Error; err != nil {
+		return nil, fmt.Errorf("failed to save shipping quote")
+	}
+
+	return &quote, nil
+}
+
+// VerifyQuote loads (and row-locks) a quote inside the checkout transaction and checks that it
+// still matches the cart being ordered. Returns the locked prices per cart line (product + edition).
+func (s *ShippingService) VerifyQuote(tx *gorm.DB, quoteID string, userID uint, items []CartItemInput, country, postalCode string) (*models.ShippingQuote, map[cartLineKey]float64, error) {
+	if strings.TrimSpace(quoteID) == "" {
+		return nil, nil, fmt.Errorf("Ongkir belum dihitung, silakan pilih metode pengiriman")
+	}
+
+	var quote models.ShippingQuote
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("quote_id = ? AND user_id = ?", quoteID, userID).
+		First(&quote).Error; err != nil {
+		return nil, nil, fmt.Errorf("Penawaran ongkir tidak ditemukan")
+	}
+
+	if quote.UsedAt != nil {
+		return nil, nil, fmt.Errorf("Penawaran ongkir sudah digunakan untuk pesanan lain")
+	}
+	if quote.IsExpired() {
+		return nil, nil, fmt.Errorf("Penawaran ongkir sudah kedaluwarsa, silakan hitung ulang ongkir")
+	}
+
+	var lockedItems []models.QuotedItem
+	if err := json.Unmarshal(quote.LockedItems, &lockedItems); err != nil {
+		return nil, nil, fmt.Errorf("Penawaran ongkir tidak valid")
+	}
+	if !hmac.Equal([]byte(quote.Signature), []byte(signShippingQuote(quote, lockedItems))) {
+		return nil, nil, fmt.Errorf("Penawaran ongkir tidak valid")
+	}
+
+	// Destination must be the one the quote was priced for
+	if NormalizeCountryISO(country) != quote.Country || strings.TrimSpace(postalCode) != quote.PostalCode {
+		return nil, nil, fmt.Errorf("Alamat pengiriman berubah sejak ongkir dihitung, silakan hitung ulang ongkir")
+	}
+
+	// Cart lines must match exactly
+	quoted := make(map[cartLineKey]int)
+	prices := make(map[cartLineKey]float64)
+	for _, li := range lockedItems {
+		quoted[lineKey(li.ProductID, li.VariantID)] += li.Quantity
+		prices[lineKey(li.ProductID, li.VariantID)] = li.Price
+	}
+	requested := make(map[cartLineKey]int)
+	for _, item := range items {
+		requested[lineKey(item.ProductID, item.VariantID)] += item.Quantity
+	}
+	if len(quoted) != len(requested) {
+		return nil, nil, fmt.Errorf("Isi keranjang berubah sejak ongkir dihitung, silakan hitung ulang ongkir")
+	}
+	for key, qty := range requested {
+		if quoted[key] != qty {
+			return nil, nil, fmt.Errorf("Isi keranjang berubah sejak ongkir dihitung, silakan hitung ulang ongkir")
+		}
+	}
+
+	// Re-derive the weight from current product data (admin may have edited it)
+	var currentWeight float64
+	for key, qty := range requested {
+		var product models.Product
+		if err := tx.Select("id", "weight").First(&product, key.ProductID).Error; err != nil {
+			return nil, nil, fmt.Errorf("product %d not found", key.ProductID)
+		}
+		var variant *models.ProductVariant
+		if key.VariantID != 0 {
+			variant = &models.ProductVariant{}
+			if err := tx.Select("id", "weight").First(variant, key.VariantID).Error; err != nil {
+				return nil, nil, fmt.Errorf("varian produk %d tidak ditemukan", key.VariantID)
+			}
+		}
+		currentWeight += product.WeightFor(variant) * float64(qty)
+	}
+	if math.Abs(roundWeight(currentWeight)-quote.TotalWeight) > 0.001 {
+		return nil, nil, fmt.Errorf("Berat keranjang berubah sejak ongkir dihitung, silakan hitung ulang ongkir")
+	}
+
+	return &quote, prices, nil
+}
+
+// ConsumeQuote marks a quote as used so it can't be replayed for another order
+func (s *ShippingService) ConsumeQuote(tx *gorm.DB, quote *models.ShippingQuote, orderID uint) error {
+	now := time.Now()
+	res := tx.Model(&models.ShippingQuote{}).
+		Where("id = ? AND used_at IS NULL", quote.ID).
+		Updates(map[string]interface{}{"used_at": now, "order_id": orderID})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("Penawaran ongkir sudah digunakan untuk pesanan lain")
+	}
+	quote.UsedAt = &now
+	quote.OrderID = &orderID
+	return nil
+}
+
+// signShippingQuote builds the HMAC over the fields that determine the price.
+// Items are serialized canonically (not the stored JSON) so DB round-trips don't break it.
+func signShippingQuote(q models.ShippingQuote, items []models.QuotedItem) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s|%d|%s|%s|%.3f|%.2f|%s|%.2f|%d",
+		q.QuoteID, q.UserID, q.Country, q.PostalCode, q.TotalWeight, q.Subtotal, q.OptionID, q.ShippingCost, q.ExpiresAt.Unix())
+	for _, it := range items {
+		fmt.Fprintf(&sb, "|%d:%d:%.2f", it.ProductID, it.Quantity, it.Price)
+		if it.VariantID != nil {
+			fmt.Fprintf(&sb, ":v%d", *it.VariantID)
+		}
+	}
+	return helpers.GenerateHMACSignature([]byte(sb.String()), os.Getenv("JWT_SECRET"))
+}
+
+func roundWeight(kg float64) float64 {
+	return math.Round(kg*1000) / 1000
+}
@@ -0,0 +1,529 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===============================================
+// STOCK TAKE (stock opname)
+// counting (scan QR codes into the sheet) -> submitted -> approved (variances booked) or back to counting.
+// Expected quantities are frozen when the session opens; approval adds each line's variance to the
+// location's current stock and posts the net value against Persediaan Barang (1003).
+// ===============================================
+
+type StockTakeService struct {
+	DB *gorm.DB
+}
+
+func NewStockTakeService() *StockTakeService {
+	return &StockTakeService{
+		DB: config.DB,
+	}
+}
+
+// StockTakeInput opens a session. Category/brand narrow it to a partial (cycle) count.
+type StockTakeInput struct {
+	LocationID uint   `json:"location_id"` // 0 = default location
+	CategoryID *uint  `json:"category_id"`
+	BrandID    *uint  `json:"brand_id"`
+	Notes      string `json:"notes"`
+}
+
+// frozenLine is a stock_levels row read when the session opens
+type frozenLine struct {
+	ProductID uint
+	VariantID uint
+	Stock     int
+	UnitCost  float64
+}
+
+// Create opens a session and freezes the expected quantity of every ready product at the location
+func (s *StockTakeService) Create(in StockTakeInput, userID uint) (*models.StockTake, error) {
+	var take models.StockTake
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		locationID, err := helpers.ResolveLocationID(tx, in.LocationID)
+		if err != nil {
+			return err
+		}
+		var location models.Location
+		if err := tx.Where("is_active = ?", true).First(&location, locationID).Error; err != nil {
+			return fmt.Errorf("lokasi tidak ditemukan")
+		}
+
+		number, err := helpers.NextSequenceNumber(tx, helpers.SeqStockTake)
+		if err != nil {
+			return err
+		}
+
+		query := tx.Table("stock_levels").
+			Select("stock_levels.product_id, stock_levels.variant_id, stock_levels.stock, "+
+				"COALESCE(NULLIF(product_variants.supplier_cost, 0), products.supplier_cost) AS unit_cost").
+			Joins("JOIN products ON products.id = stock_levels.product_id AND products.deleted_at IS NULL").
+			Joins("LEFT JOIN product_variants ON product_variants.id = stock_levels.variant_id").
+			Where("stock_levels.location_id = ? AND stock_levels.stock <> 0", locationID).
+			Where("products.product_type = ?", "ready").
+			Where("stock_levels.variant_id = 0 OR product_variants.deleted_at IS NULL")
+		if in.CategoryID != nil {
+			query = query.Where("products.category_id = ?", *in.CategoryID)
+		}
+		if in.BrandID != nil {
+			query = query.Where("products.brand_id = ?", *in.BrandID)
+		}
+		var lines []frozenLine
+		if err := query.Order("stock_levels.product_id, stock_levels.variant_id").Scan(&lines).Error; err != nil {
+			return err
+		}
+
+		take = models.StockTake{
+			Number:     number,
+			LocationID: locationID,
+			CategoryID: in.CategoryID,
+			BrandID:    in.BrandID,
+			Status:     models.StockTakeCounting,
+			Notes:      in.Notes,
+			FrozenAt:   time.Now(),
+			CreatedBy:  userID,
+		}
+		for _, line := range lines {
+			item := models.StockTakeItem{ProductID: line.ProductID, ExpectedQty: line.Stock, UnitCost: line.UnitCost}
+			if line.VariantID != 0 {
+				vid := line.VariantID
+				item.VariantID = &vid
+			}
+			take.Items = append(take.Items, item)
+		}
+		return tx.Create(&take).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "CREATE", take.ID, fmt.Sprintf("Opened stock take %s (%d lines)", take.Number, len(take.Items)))
+	return &take, nil
+}
+
+// lockStockTake loads a session and checks it is in the expected status
+func (s *StockTakeService) lockStockTake(tx *gorm.DB, id uint, statuses ...string) (*models.StockTake, error) {
+	var take models.StockTake
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&take, id).Error; err != nil {
+		return nil, fmt.Errorf("stock take not found")
+	}
+	for _, status := range statuses {
+		if take.Status == status {
+			return &take, nil
+		}
+	}
+	return nil, fmt.Errorf("stock opname %s sudah berstatus %s", take.Number, take.Status)
+}
+
+// resolveCode finds the product/edition behind a scanned QR code or SKU. Products with editions
+// are counted per edition, so their own code is refused.
+func resolveCode(tx *gorm.DB, code string) (*models.Product, *models.ProductVariant, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return nil, nil, fmt.Errorf("kode kosong")
+	}
+
+	var variant models.ProductVariant
+	tx.Where("qr_code = ? OR UPPER(sku) = UPPER(?)", code, code).Limit(1).Find(&variant)
+	if variant.ID != 0 {
+		product, _, err := loadStockItem(tx, variant.ProductID, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return product, &variant, nil
+	}
+
+	var product models.Product
+	tx.Where("qr_code = ? OR UPPER(sku) = UPPER(?)", code, code).Limit(1).Find(&product)
+	if product.ID == 0 {
+		return nil, nil, fmt.Errorf("kode %s tidak dikenali", code)
+	}
+	var editions int64
+	tx.Model(&models.ProductVariant{}).Where("product_id = ?", product.ID).Count(&editions)
+	if editions > 0 {
+		return nil, nil, fmt.Errorf("%s memiliki edisi, scan kode edisinya", product.Name)
+	}
+	return &product, nil, nil
+}
+
+// ScanInput is one scan (or typed entry) on the count sheet
+type ScanInput struct {
+	Code     string `json:"code" binding:"required"` // QR code or SKU
+	Quantity int    `json:"quantity"`                // Defaults to 1
+	Set      bool   `json:"set"`                     // Replace the count instead of adding to it
+}
+
+// Scan adds a scanned item to its line. Items that weren't frozen (found where the system had none)
+// get a new line expecting the location's current stock.
+func (s *StockTakeService) Scan(id uint, in ScanInput, userID uint) (*models.StockTakeItem, error) {
+	if in.Quantity == 0 && !in.Set {
+		in.Quantity = 1
+	}
+	if in.Quantity < 0 {
+		return nil, fmt.Errorf("jumlah tidak boleh negatif")
+	}
+
+	var item models.StockTakeItem
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		take, err := s.lockStockTake(tx, id, models.StockTakeCounting)
+		if err != nil {
+			return err
+		}
+		product, variant, err := resolveCode(tx, in.Code)
+		if err != nil {
+			return err
+		}
+		var variantID *uint
+		if variant != nil {
+			variantID = &variant.ID
+		}
+
+		query := tx.Where("stock_take_id = ? AND product_id = ?", take.ID, product.ID)
+		if variantID != nil {
+			query = query.Where("variant_id = ?", *variantID)
+		} else {
+			query = query.Where("variant_id IS NULL")
+		}
+		query.Limit(1).Find(&item)
+
+		if item.ID == 0 {
+			level, err := helpers.LockLocationStock(tx, take.LocationID, product.ID, variantID)
+			if err != nil {
+				return err
+			}
+			item = models.StockTakeItem{
+				StockTakeID: take.ID,
+				ProductID:   product.ID,
+				VariantID:   variantID,
+				ExpectedQty: level.Stock,
+				UnitCost:    product.CostFor(variant),
+			}
+		}
+
+		qty := in.Quantity
+		if !in.Set && item.CountedQty != nil {
+			qty += *item.CountedQty
+		}
+		item.SetCount(qty, userID)
+		return tx.Save(&item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// SetCount overwrites the count of one line, e.g. after a recount
+func (s *StockTakeService) SetCount(id, itemID uint, qty int, userID uint) (*models.StockTakeItem, error) {
+	if qty < 0 {
+		return nil, fmt.Errorf("jumlah tidak boleh negatif")
+	}
+
+	var item models.StockTakeItem
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := s.lockStockTake(tx, id, models.StockTakeCounting); err != nil {
+			return err
+		}
+		if err := tx.Where("stock_take_id = ?", id).First(&item, itemID).Error; err != nil {
+			return fmt.Errorf("baris stock opname tidak ditemukan")
+		}
+		item.SetCount(qty, userID)
+		return tx.Save(&item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Submit closes counting and hands the sheet to an approver. With zeroUncounted, lines nobody
+// scanned are counted as 0 (missing); otherwise they are left out of the adjustment.
+func (s *StockTakeService) Submit(id uint, zeroUncounted bool, userID uint) (*models.StockTake, error) {
+	var take *models.StockTake
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if take, err = s.lockStockTake(tx, id, models.StockTakeCounting); err != nil {
+			return err
+		}
+
+		var items []models.StockTakeItem
+		if err := tx.Where("stock_take_id = ?", take.ID).Find(&items).Error; err != nil {
+			return err
+		}
+		counted := 0
+		take.VarianceQty, take.VarianceValue = 0, 0
+		for i := range items {
+			if items[i].CountedQty == nil {
+				if !zeroUncounted {
+					continue
+				}
+				items[i].SetCount(0, userID)
+				if err := tx.Save(&items[i]).Error; err != nil {
+					return err
+				}
+			}
+			counted++
+			take.VarianceQty += items[i].Variance
+			take.VarianceValue += items[i].VarianceValue
+		}
+		if counted == 0 {
+			return fmt.Errorf("belum ada barang yang dihitung")
+		}
+
+		now := time.Now()
+		take.Status = models.StockTakeSubmitted
+		take.VarianceValue = roundMoney(take.VarianceValue)
+		take.SubmittedBy = &userID
+		take.SubmittedAt = &now
+		take.RejectReason = ""
+		return tx.Model(take).Updates(map[string]interface{}{
+			"status":         take.Status,
+			"variance_qty":   take.VarianceQty,
+			"variance_value": take.VarianceValue,
+			"submitted_by":   userID,
+			"submitted_at":   now,
+			"reject_reason":  "",
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "UPDATE", take.ID, "Submitted stock take "+take.Number)
+	helpers.NotifyAdmin("STOCKTAKE_SUBMITTED", "Stock Opname Menunggu Persetujuan", map[string]interface{}{
+		"stock_take_id":  take.ID,
+		"number":         take.Number,
+		"variance_qty":   take.VarianceQty,
+		"variance_value": take.VarianceValue,
+	})
+	return take, nil
+}
+
+// Approve books every counted line's variance as an adjustment at the location and posts the
+// net shrinkage (or surplus) journal
+func (s *StockTakeService) Approve(id uint, userID uint) (*models.StockTake, error) {
+	var take *models.StockTake
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if take, err = s.lockStockTake(tx, id, models.StockTakeSubmitted); err != nil {
+			return err
+		}
+
+		var items []models.StockTakeItem
+		if err := tx.Where("stock_take_id = ? AND counted_qty IS NOT NULL AND variance <> 0", take.ID).
+			Preload("Product").Preload("Variant").Find(&items).Error; err != nil {
+			return err
+		}
+
+		take.VarianceQty, take.VarianceValue = 0, 0
+		for _, item := range items {
+			level, err := helpers.LockLocationStock(tx, take.LocationID, item.ProductID, item.VariantID)
+			if err != nil {
+				return err
+			}
+			if level.Stock+item.Variance < 0 {
+				name := fmt.Sprintf("produk %d", item.ProductID)
+				if item.Product != nil {
+					name = item.Product.Name
+				}
+				if item.Variant != nil {
+					name += " - " + item.Variant.Name
+				}
+				return fmt.Errorf("stok %s sudah berubah sejak dihitung (sisa %d, selisih %d), hitung ulang", name, level.Stock, item.Variance)
+			}
+
+			note := fmt.Sprintf("Stock opname: expected %d, counted %d", item.ExpectedQty, *item.CountedQty)
+			if err := helpers.RecordLocationStockMovement(tx, take.LocationID, item.ProductID, item.VariantID, item.Variance, "physical", "adjustment", "STOCKTAKE", take.Number, note, &userID); err != nil {
+				return err
+			}
+			if err := helpers.AdjustStock(tx, item.ProductID, item.VariantID, map[string]interface{}{"stock": gorm.Expr("stock + ?", item.Variance)}); err != nil {
+				return err
+			}
+			take.VarianceQty += item.Variance
+			take.VarianceValue += item.VarianceValue
+		}
+		take.VarianceValue = roundMoney(take.VarianceValue)
+
+		if take.VarianceValue != 0 {
+			entry, err := s.postJournal(tx, take)
+			if err != nil {
+				return err
+			}
+			take.JournalEntryID = &entry.ID
+		}
+
+		now := time.Now()
+		take.Status = models.StockTakeApproved
+		take.ApprovedBy = &userID
+		take.ApprovedAt = &now
+		return tx.Model(take).Updates(map[string]interface{}{
+			"status":           take.Status,
+			"variance_qty":     take.VarianceQty,
+			"variance_value":   take.VarianceValue,
+			"journal_entry_id": take.JournalEntryID,
+			"approved_by":      userID,
+			"approved_at":      now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "APPROVE", take.ID, fmt.Sprintf("Approved stock take %s (variance %d units, %.2f)", take.Number, take.VarianceQty, take.VarianceValue))
+	return take, nil
+}
+
+// postJournal books the net variance: a shortage is Dr Selisih Persediaan / Cr Persediaan Barang,
+// a surplus the other way round
+func (s *StockTakeService) postJournal(tx *gorm.DB, take *models.StockTake) (*models.JournalEntry, error) {
+	invID, err := helpers.GetInventoryCOA()
+	if err != nil {
+		return nil, fmt.Errorf("inventory COA (1003) not found")
+	}
+	shrinkID, err := helpers.GetShrinkageCOA()
+	if err != nil {
+		return nil, fmt.Errorf("inventory shrinkage COA (6009) not found")
+	}
+
+	amount := roundMoney(take.VarianceValue)
+	items := []models.JournalItem{
+		{COAID: shrinkID, Debit: -amount, Credit: 0},
+		{COAID: invID, Debit: 0, Credit: -amount},
+	}
+	if amount > 0 {
+		items = []models.JournalItem{
+			{COAID: invID, Debit: amount, Credit: 0},
+			{COAID: shrinkID, Debit: 0, Credit: amount},
+		}
+	}
+
+	return helpers.PostJournal(tx, helpers.JournalPosting{
+		Date:          time.Now(),
+		ReferenceID:   take.Number,
+		ReferenceType: "STOCK_TAKE",
+		Description:   "Selisih stock opname " + take.Number,
+		Items:         items,
+	})
+}
+
+// Reject sends a submitted sheet back to counting
+func (s *StockTakeService) Reject(id uint, reason string, userID uint) (*models.StockTake, error) {
+	var take *models.StockTake
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if take, err = s.lockStockTake(tx, id, models.StockTakeSubmitted); err != nil {
+			return err
+		}
+		take.Status = models.StockTakeCounting
+		take.RejectReason = reason
+		return tx.Model(take).Updates(map[string]interface{}{"status": take.Status, "reject_reason": reason}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "UPDATE", take.ID, "Rejected stock take "+take.Number+": "+reason)
+	return take, nil
+}
+
+// Cancel abandons a session that hasn't been approved; stock is untouched
+func (s *StockTakeService) Cancel(id uint, userID uint) (*models.StockTake, error) {
+	var take *models.StockTake
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if take, err = s.lockStockTake(tx, id, models.StockTakeCounting, models.StockTakeSubmitted); err != nil {
+			return err
+		}
+		take.Status = models.StockTakeCancelled
+		return tx.Model(take).Update("status", take.Status).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "UPDATE", take.ID, "Cancelled stock take "+take.Number)
+	return take, nil
+}
+
+// StockTakeFilter narrows List
+type StockTakeFilter struct {
+	Status     string
+	LocationID uint
+	Page       int
+	Limit      int
+}
+
+// List returns a page of sessions, newest first
+func (s *StockTakeService) List(f StockTakeFilter) ([]models.StockTake, int64, error) {
+	query := s.DB.Model(&models.StockTake{})
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.LocationID != 0 {
+		query = query.Where("location_id = ?", f.LocationID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var takes []models.StockTake
+	err := query.Preload("Location").Order("id desc").Limit(f.Limit).Offset((f.Page - 1) * f.Limit).Find(&takes).Error
+	return takes, total, err
+}
+
+// StockTakeSummary is the progress and variance of a count sheet
+type StockTakeSummary struct {
+	Lines         int     `json:"lines"`
+	Counted       int     `json:"counted"`
+	Uncounted     int     `json:"uncounted"`
+	WithVariance  int     `json:"with_variance"`
+	ShortageQty   int     `json:"shortage_qty"`
+	ShortageValue float64 `json:"shortage_value"`
+	SurplusQty    int     `json:"surplus_qty"`
+	SurplusValue  float64 `json:"surplus_value"`
+}
+
+// Get returns a session with its count sheet and a variance summary
+func (s *StockTakeService) Get(id uint) (*models.StockTake, StockTakeSummary, error) {
+	var take models.StockTake
+	var summary StockTakeSummary
+	err := s.DB.Preload("Location").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Items.Product").Preload("Items.Variant").
+		First(&take, id).Error
+	if err != nil {
+		return nil, summary, fmt.Errorf("stock take not found")
+	}
+
+	for _, item := range take.Items {
+		summary.Lines++
+		if item.CountedQty == nil {
+			summary.Uncounted++
+			continue
+		}
+		summary.Counted++
+		switch {
+		case item.Variance < 0:
+			summary.WithVariance++
+			summary.ShortageQty -= item.Variance
+			summary.ShortageValue -= item.VarianceValue
+		case item.Variance > 0:
+			summary.WithVariance++
+			summary.SurplusQty += item.Variance
+			summary.SurplusValue += item.VarianceValue
+		}
+	}
+	summary.ShortageValue = roundMoney(summary.ShortageValue)
+	summary.SurplusValue = roundMoney(summary.SurplusValue)
+	return &take, summary, nil
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===============================================
+// STOCK TRANSFERS
+// draft -> in_transit (off the source shelf) -> received (on the destination shelf).
+// Product totals never change: the stock only moves between locations.
+// ===============================================
+
+type StockTransferService struct {
+	DB *gorm.DB
+}
+
+func NewStockTransferService() *StockTransferService {
+	return &StockTransferService{
+		DB: config.DB,
+	}
+}
+
+// TransferInput describes a new transfer order
+type TransferInput struct {
+	FromLocationID uint           `json:"from_location_id" binding:"required"`
+	ToLocationID   uint           `json:"to_location_id" binding:"required"`
+	Notes          string         `json:"notes"`
+	Items          []TransferLine `json:"items" binding:"required,min=1,dive"`
+}
+
+type TransferLine struct {
+	ProductID uint  `json:"product_id" binding:"required"`
+	VariantID *uint `json:"variant_id"`
+	Quantity  int   `json:"quantity" binding:"required,min=1"`
+}
+
+// CreateTransfer saves a draft transfer; nothing moves until it is dispatched
+func (s *StockTransferService) CreateTransfer(in TransferInput, userID uint) (*models.StockTransfer, error) {
+	if in.FromLocationID == in.ToLocationID {
+		return nil, fmt.Errorf("lokasi asal dan tujuan tidak boleh sama")
+	}
+	var count int64
+	s.DB.Model(&models.Location{}).Where("id IN ? AND is_active = ?", []uint{in.FromLocationID, in.ToLocationID}, true).Count(&count)
+	if count != 2 {
+		return nil, fmt.Errorf("lokasi asal atau tujuan tidak ditemukan")
+	}
+
+	var transfer models.StockTransfer
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		number, err := helpers.NextSequenceNumber(tx, helpers.SeqStockTransfer)
+		if err != nil {
+			return err
+		}
+		transfer = models.StockTransfer{
+			TransferNumber: number,
+			FromLocationID: in.FromLocationID,
+			ToLocationID:   in.ToLocationID,
+			Status:         models.TransferDraft,
+			Notes:          in.Notes,
+			CreatedBy:      userID,
+		}
+		for _, line := range in.Items {
+			if _, _, err := loadStockItem(tx, line.ProductID, line.VariantID); err != nil {
+				return err
+			}
+			transfer.Items = append(transfer.Items, models.StockTransferItem{
+				ProductID: line.ProductID,
+				VariantID: line.VariantID,
+				Quantity:  line.Quantity,
+			})
+		}
+		return tx.Create(&transfer).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "CREATE", transfer.ID, "Created stock transfer "+transfer.TransferNumber)
+	return &transfer, nil
+}
+
+// lockTransfer loads a transfer with its items and checks it is in the expected status
+func (s *StockTransferService) lockTransfer(tx *gorm.DB, id uint, statuses ...string) (*models.StockTransfer, error) {
+	var transfer models.StockTransfer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&transfer, id).Error; err != nil {
+		return nil, fmt.Errorf("transfer not found")
+	}
+	for _, status := range statuses {
+		if transfer.Status == status {
+			return &transfer, nil
+		}
+	}
+	return nil, fmt.Errorf("transfer %s sudah berstatus %s", transfer.TransferNumber, transfer.Status)
+}
+
+// Dispatch takes the items off the source location. Units held there for orders can't leave.
+func (s *StockTransferService) Dispatch(id uint, userID uint) (*models.StockTransfer, error) {
+	var transfer *models.StockTransfer
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if transfer, err = s.lockTransfer(tx, id, models.TransferDraft); err != nil {
+			return err
+		}
+
+		for _, item := range transfer.Items {
+			level, err := helpers.LockLocationStock(tx, transfer.FromLocationID, item.ProductID, item.VariantID)
+			if err != nil {
+				return err
+			}
+			if level.Stock-level.ReservedQty < item.Quantity {
+				product, variant, _ := loadStockItem(tx, item.ProductID, item.VariantID)
+				name := fmt.Sprintf("produk %d", item.ProductID)
+				if product != nil {
+					name = product.Name
+					if variant != nil {
+						name += " - " + variant.Name
+					}
+				}
+				return fmt.Errorf("stok %s di lokasi asal tidak mencukupi (tersedia %d)", name, max(level.Stock-level.ReservedQty, 0))
+			}
+			if err := helpers.AdjustLocationStock(tx, transfer.FromLocationID, item.ProductID, item.VariantID, -item.Quantity, 0); err != nil {
+				return err
+			}
+			if err := helpers.RecordTransferMovement(tx, &transfer.FromLocationID, &transfer.ToLocationID, item.ProductID, item.VariantID, item.Quantity, transfer.TransferNumber, "Transfer dispatched", &userID); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		transfer.Status = models.TransferInTransit
+		transfer.DispatchedAt = &now
+		return tx.Model(transfer).Updates(map[string]interface{}{"status": transfer.Status, "dispatched_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "UPDATE", transfer.ID, "Dispatched stock transfer "+transfer.TransferNumber)
+	return transfer, nil
+}
+
+// Receive puts the items on the destination location
+func (s *StockTransferService) Receive(id uint, userID uint) (*models.StockTransfer, error) {
+	var transfer *models.StockTransfer
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if transfer, err = s.lockTransfer(tx, id, models.TransferInTransit); err != nil {
+			return err
+		}
+
+		for _, item := range transfer.Items {
+			if err := helpers.AdjustLocationStock(tx, transfer.ToLocationID, item.ProductID, item.VariantID, item.Quantity, 0); err != nil {
+				return err
+			}
+			if err := helpers.RecordTransferMovement(tx, &transfer.FromLocationID, &transfer.ToLocationID, item.ProductID, item.VariantID, item.Quantity, transfer.TransferNumber, "Transfer received", &userID); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		transfer.Status = models.TransferReceived
+		transfer.ReceivedAt = &now
+		return tx.Model(transfer).Updates(map[string]interface{}{"status": transfer.Status, "received_at": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "UPDATE", transfer.ID, "Received stock transfer "+transfer.TransferNumber)
+	return transfer, nil
+}
+
+// Cancel drops a draft, or sends a transfer in transit back to its source
+func (s *StockTransferService) Cancel(id uint, userID uint) (*models.StockTransfer, error) {
+	var transfer *models.StockTransfer
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if transfer, err = s.lockTransfer(tx, id, models.TransferDraft, models.TransferInTransit); err != nil {
+			return err
+		}
+
+		if transfer.Status == models.TransferInTransit {
+			for _, item := range transfer.Items {
+				if err := helpers.AdjustLocationStock(tx, transfer.FromLocationID, item.ProductID, item.VariantID, item.Quantity, 0); err != nil {
+					return err
+				}
+				if err := helpers.RecordTransferMovement(tx, &transfer.ToLocationID, &transfer.FromLocationID, item.ProductID, item.VariantID, item.Quantity, transfer.TransferNumber, "Transfer cancelled, back at source", &userID); err != nil {
+					return err
+				}
+			}
+		}
+
+		transfer.Status = models.TransferCancelled
+		return tx.Model(transfer).Update("status", transfer.Status).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.LogAuditSimple(userID, "inventory", "UPDATE", transfer.ID, "Cancelled stock transfer "+transfer.TransferNumber)
+	return transfer, nil
+}
+
+// TransferFilter narrows ListTransfers
+type TransferFilter struct {
+	Status     string
+	LocationID uint // From or to
+	Page       int
+	Limit      int
+}
+
+// ListTransfers returns a page of transfers, newest first
+func (s *StockTransferService) ListTransfers(f TransferFilter) ([]models.StockTransfer, int64, error) {
+	query := s.DB.Model(&models.StockTransfer{})
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.LocationID != 0 {
+		query = query.Where("from_location_id = ? OR to_location_id = ?", f.LocationID, f.LocationID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var transfers []models.StockTransfer
+	err := query.Preload("FromLocation").Preload("ToLocation").Preload("Items").
+		Order("id desc").Limit(f.Limit).Offset((f.Page - 1) * f.Limit).Find(&transfers).Error
+	return transfers, total, err
+}
+
+// GetTransfer returns a transfer with its locations and items
+func (s *StockTransferService) GetTransfer(id uint) (*models.StockTransfer, error) {
+	var transfer models.StockTransfer
+	err := s.DB.Preload("FromLocation").Preload("ToLocation").
+		Preload("Items.Product").Preload("Items.Variant").
+		First(&transfer, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("transfer not found")
+	}
+	return &transfer, nil
+}
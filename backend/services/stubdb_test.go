@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"forzashop/backend/config"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// stubDB is a database/sql driver that records every statement and answers from canned rules,
+// so service code can run its real queries and transactions without a Postgres server.
+type stubDB struct {
+	mu     sync.Mutex
+	rules  []stubRule
+	log    []string
+	nextID int64
+}
+
+// stubRule answers statements containing match (first matching rule wins). Rows are returned for
+// queries; affected is what an UPDATE/DELETE reports (default 1).
+type stubRule struct {
+	match    string
+	cols     []string
+	rows     [][]driver.Value
+	affected *int64
+}
+
+// newStubDB opens gorm on a fresh stub and makes it config.DB for the duration of the test
+func newStubDB(t *testing.T) (*gorm.DB, *stubDB) {
+	t.Helper()
+	stub := &stubDB{nextID: 100}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(stub)}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := config.DB
+	config.DB = db
+	t.Cleanup(func() { config.DB = prev })
+	return db, stub
+}
+
+// on answers statements containing match with the given columns and rows
+func (s *stubDB) on(match string, cols []string, rows ...[]driver.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, stubRule{match: match, cols: cols, rows: rows})
+}
+
+// affect makes statements containing match report n affected rows
+func (s *stubDB) affect(match string, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, stubRule{match: match, affected: &n})
+}
+
+// statements returns the recorded statements (BEGIN/COMMIT/ROLLBACK included)
+func (s *stubDB) statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.log...)
+}
+
+// index returns the position of the first recorded statement containing every part, -1 if none
+func (s *stubDB) index(parts ...string) int {
+	for i, stmt := range s.statements() {
+		all := true
+		for _, p := range parts {
+			if !strings.Contains(stmt, p) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return i
+		}
+	}
+	return -1
+}
+
+// count returns how many recorded statements contain every part
+func (s *stubDB) count(parts ...string) int {
+	n := 0
+	for _, stmt := range s.statements() {
+		all := true
+		for _, p := range parts {
+			if !strings.Contains(stmt, p) {
+				all = false
+				break
+			}
+		}
+		if all {
+			n++
+		}
+	}
+	return n
+}
+
+// inTransaction reports whether statement i ran inside a transaction (after a BEGIN not yet ended)
+func (s *stubDB) inTransaction(i int) bool {
+	depth := 0
+	for j, stmt := range s.statements() {
+		if j == i {
+			return depth > 0
+		}
+		switch stmt {
+		case "BEGIN":
+			depth++
+		case "COMMIT", "ROLLBACK":
+			depth--
+		}
+	}
+	return false
+}
+
+// dump is the statement log for failure messages
+func (s *stubDB) dump() string {
+	return strings.Join(s.statements(), "\n")
+}
+
+func (s *stubDB) record(stmt string, args []driver.NamedValue) {
+	for i := len(args) - 1; i >= 0; i-- {
+		stmt = strings.ReplaceAll(stmt, fmt.Sprintf("$%d", i+1), fmt.Sprintf("%v", args[i].Value))
+	}
+	s.mu.Lock()
+	s.log = append(s.log, stmt)
+	s.mu.Unlock()
+}
+
+func (s *stubDB) rule(query string) *stubRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.rules {
+		if strings.Contains(query, s.rules[i].match) {
+			return &s.rules[i]
+		}
+	}
+	return nil
+}
+
+// driver.Connector
+func (s *stubDB) Connect(context.Context) (driver.Conn, error) { return &stubConn{db: s}, nil }
+func (s *stubDB) Driver() driver.Driver                        { return stubDriver{} }
+
+type stubDriver struct{}
+
+func (stubDriver) Open(string) (driver.Conn, error) { return nil, fmt.Errorf("use sql.OpenDB") }
+
+type stubConn struct{ db *stubDB }
+
+func (c *stubConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("stub: prepared statements not supported")
+}
+func (c *stubConn) Close() error { return nil }
+func (c *stubConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *stubConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.record("BEGIN", nil)
+	return stubTx{db: c.db}, nil
+}
+
+func (c *stubConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query, args)
+	affected := int64(1)
+	if r := c.db.rule(query); r != nil && r.affected != nil {
+		affected = *r.affected
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+func (c *stubConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query, args)
+	if r := c.db.rule(query); r != nil && r.affected == nil {
+		return &stubRows{cols: r.cols, rows: r.rows}, nil
+	}
+	// INSERT ... RETURNING "id": hand out a fresh id
+	if strings.HasPrefix(query, "INSERT") && strings.Contains(query, `RETURNING "id"`) {
+		c.db.mu.Lock()
+		c.db.nextID++
+		id := c.db.nextID
+		c.db.mu.Unlock()
+		return &stubRows{cols: []string{"id"}, rows: [][]driver.Value{{id}}}, nil
+	}
+	return &stubRows{}, nil
+}
+
+type stubTx struct{ db *stubDB }
+
+func (t stubTx) Commit() error   { t.db.record("COMMIT", nil); return nil }
+func (t stubTx) Rollback() error { t.db.record("ROLLBACK", nil); return nil }
+
+type stubRows struct {
+	cols []string
+	rows [][]driver.Value
+	pos  int
+}
+
+func (r *stubRows) Columns() []string { return r.cols }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+
+	"forzashop/backend/config"
+	"forzashop/backend/helpers"
+	"forzashop/backend/models"
+
+	"gorm.io/gorm"
+)
+
+type TaxService struct {
+	DB *gorm.DB
+}
+
+func NewTaxService() *TaxService {
+	return &TaxService{
+		DB: config.DB,
+	}
+}
+
+// TaxConfig is the active PPN configuration (Admin > Settings, group "tax")
+type TaxConfig struct {
+	Enabled         bool
+	Rate            float64 // Percent, e.g. 11
+	Inclusive       bool    // Catalogue prices already include PPN
+	TaxShipping     bool    // Charge PPN on the shipping fee as well
+	ZeroRateExports bool    // Shipments outside Indonesia are zero-rated
+}
+
+// TaxableLine is an order line as seen by the tax engine
+type TaxableLine struct {
+	CategoryID *uint
+	Amount     float64 // Line total after its share of the discount
+}
+
+// TaxBreakdown is the result of a tax calculation. LineTax follows the order of the input lines.
+type TaxBreakdown struct {
+	Rate        float64
+	Inclusive   bool
+	ZeroRated   bool
+	LineTax     []float64
+	ShippingTax float64
+	Total       float64
+}
+
+// Extra is the amount added on top of the prices (exclusive pricing only)
+func (b TaxBreakdown) Extra() float64 {
+	if b.Inclusive {
+		return 0
+	}
+	return b.Total
+}
+
+// LoadTaxConfig reads the tax settings. Defaults keep prices unchanged (PPN 11% included in prices).
+func LoadTaxConfig() TaxConfig {
+	cfg := TaxConfig{
+		Enabled:         helpers.GetSetting("tax_enabled", "true") == "true",
+		Inclusive:       helpers.GetSetting("tax_price_inclusive", "true") == "true",
+		TaxShipping:     helpers.GetSetting("tax_shipping", "false") == "true",
+		ZeroRateExports: helpers.GetSetting("tax_zero_rate_exports", "true") == "true",
+	}
+	cfg.Rate, _ = strconv.ParseFloat(helpers.GetSetting("tax_ppn_rate", "11"), 64)
+	if cfg.Rate < 0 || cfg.Rate > 100 {
+		cfg.Rate = 0
+	}
+	return cfg
+}
+
+// Calculate computes PPN for a set of lines and the shipping fee delivered to country/postalCode.
+// Lines in exempt categories and zero-rated destinations carry no tax.
+func (s *TaxService) Calculate(lines []TaxableLine, shipping float64, country, postalCode string) TaxBreakdown {
+	cfg := LoadTaxConfig()
+	breakdown := TaxBreakdown{
+		Rate:      cfg.Rate,
+		Inclusive: cfg.Inclusive,
+		LineTax:   make([]float64, len(lines)),
+	}
+
+	if !cfg.Enabled || cfg.Rate <= 0 {
+		breakdown.Rate = 0
+		return breakdown
+	}
+	if s.isZeroRated(cfg, country, postalCode) {
+		breakdown.Rate = 0
+		breakdown.ZeroRated = true
+		return breakdown
+	}
+
+	exempt := s.exemptCategories()
+	for i, line := range lines {
+		if line.CategoryID != nil && exempt[*line.CategoryID] {
+			continue
+		}
+		breakdown.LineTax[i] = taxOn(line.Amount, cfg.Rate, cfg.Inclusive)
+		breakdown.Total += breakdown.LineTax[i]
+	}
+	if cfg.TaxShipping && shipping > 0 {
+		breakdown.ShippingTax = taxOn(shipping, cfg.Rate, cfg.Inclusive)
+		breakdown.Total += breakdown.ShippingTax
+	}
+	breakdown.Total = roundMoney(breakdown.Total)
+
+	return breakdown
+}
+
+// CalculateForItems is Calculate for order lines whose products aren't loaded yet (POS, manual orders).
+// discount is spread over the lines pro-rata before tax.
+func (s *TaxService) CalculateForItems(items []models.OrderItem, discount, shipping float64, country, postalCode string) TaxBreakdown {
+	shares := allocateLineAmounts(items, discount)
+	lines := make([]TaxableLine, len(items))
+	for i, item := range items {
+		var product models.Product
+		s.DB.Select("id", "category_id").First(&product, item.ProductID)
+		lines[i] = TaxableLine{CategoryID: product.CategoryID, Amount: shares[i]}
+	}
+	return s.Calculate(lines, shipping, country, postalCode)
+}
+
+// ApplyTax stamps a breakdown on an order being built. The caller adds Extra() to the total.
+func ApplyTax(order *models.Order, items []models.OrderItem, breakdown TaxBreakdown) {
+	for i := range items {
+		if i < len(breakdown.LineTax) {
+			items[i].TaxAmount = breakdown.LineTax[i]
+		}
+	}
+	order.TaxAmount = breakdown.Total
+	order.TaxRate = breakdown.Rate
+	order.TaxInclusive = breakdown.Inclusive
+}
+
+// isZeroRated: destination zone flagged as zero-rated, or any export when that setting is on
+func (s *TaxService) isZeroRated(cfg TaxConfig, country, postalCode string) bool {
+	iso := NormalizeCountryISO(country)
+	if cfg.ZeroRateExports && iso != "ID" {
+		return true
+	}
+
+	var zones []models.ShippingZone
+	if err := s.DB.Where("is_active = ?", true).Find(&zones).Error; err != nil || len(zones) == 0 {
+		return false
+	}
+	zone := MatchShippingZone(zones, strings.TrimSpace(postalCode), iso, country)
+	return zone != nil && zone.TaxZeroRated
+}
+
+// exemptCategories returns exempt categories and their direct sub-categories
+func (s *TaxService) exemptCategories() map[uint]bool {
+	exempt := map[uint]bool{}
+	var ids []uint
+	s.DB.Model(&models.Category{}).Where("tax_exempt = ?", true).Pluck("id", &ids)
+	if len(ids) == 0 {
+		return exempt
+	}
+	for _, id := range ids {
+		exempt[id] = true
+	}
+	var children []uint
+	s.DB.Model(&models.Category{}).Where("parent_id IN ?", ids).Pluck("id", &children)
+	for _, id := range children {
+		exempt[id] = true
+	}
+	return exempt
+}
+
+// taxOn returns the PPN for an amount: extracted from it when prices are inclusive, added on top otherwise
+func taxOn(amount, rate float64, inclusive bool) float64 {
+	if amount <= 0 {
+		return 0
+	}
+	if inclusive {
+		return roundMoney(amount * rate / (100 + rate))
+	}
+	return roundMoney(amount * rate / 100)
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"forzashop/backend/config"
+	"forzashop/backend/models"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ===============================================
+// WEBHOOK INBOX
+// ===============================================
+
+const (
+	webhookMaxAttempts  = 8
+	webhookBaseBackoff  = 30 * time.Second
+	webhookMaxBackoff   = 2 * time.Hour
+	webhookStaleTimeout = 10 * time.Minute // A "processing" event older than this was abandoned (e.g. restart)
+)
+
+// ErrWebhookRejected marks a permanent failure (bad signature, unreadable payload): the event is not retried
+var ErrWebhookRejected = errors.New("webhook rejected")
+
+// WebhookHandler turns a stored event into business effects. Process must be safe to run more than once.
+type WebhookHandler interface {
+	// EventKey identifies the provider event so retried deliveries are recognized; eventType is informative
+	EventKey(header http.Header, body []byte) (key string, eventType string)
+	Process(event models.WebhookEvent, header http.Header) (string, error)
+}
+
+// WebhookAuthenticator is implemented by handlers that can check a delivery's signature on receipt.
+// Deliveries failing it never take the event key, so a forged callback cannot shadow the real one.
+type WebhookAuthenticator interface {
+	Authenticate(header http.Header, body []byte) error
+}
+
+var webhookHandlers = map[string]WebhookHandler{}
+
+// RegisterWebhookHandler makes a provider's events processable by the inbox
+func RegisterWebhookHandler(provider string, h WebhookHandler) {
+	webhookHandlers[provider] = h
+}
+
+// webhookWake nudges the worker as soon as a new event is stored
+var webhookWake = make(chan struct{}, 1)
+
+// WebhookWakeups is read by the inbox worker next to its polling ticker
+func WebhookWakeups() <-chan struct{} {
+	return webhookWake
+}
+
+type WebhookService struct {
+	DB *gorm.DB
+}
+
+func NewWebhookService() *WebhookService {
+	return &WebhookService{
+		DB: config.DB,
+	}
+}
+
+// Receive stores a callback before anything else is done with it. A delivery whose event key was
+// already stored is not stored again; duplicate reports it so the caller can simply acknowledge.
+// A delivery failing the handler's signature check is kept as rejected under its body hash and
+// returned with an ErrWebhookRejected error.
+func (s *WebhookService) Receive(provider string, header http.Header, body []byte) (*models.WebhookEvent, bool, error) {
+	handler, ok := webhookHandlers[provider]
+	if !ok {
+		return nil, false, fmt.Errorf("no webhook handler for %s", provider)
+	}
+
+	headers, _ := json.Marshal(storableHeaders(header))
+	now := time.Now()
+	event := models.WebhookEvent{
+		Provider:      provider,
+		Headers:       datatypes.JSON(headers),
+		Payload:       string(body),
+		Status:        models.WebhookStatusReceived,
+		NextAttemptAt: &now,
+		ReceivedAt:    now,
+	}
+
+	if auth, ok := handler.(WebhookAuthenticator); ok {
+		if err := auth.Authenticate(header, body); err != nil {
+			sum := sha256.Sum256(body)
+			event.EventKey = "unverified:" + hex.EncodeToString(sum[:])
+			event.Status = models.WebhookStatusRejected
+			event.NextAttemptAt = nil
+			event.LastError = err.Error()
+			if res := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&event); res.Error != nil {
+				return nil, false, res.Error
+			}
+			log.Printf("❌ %s webhook rejected on receipt: %v", provider, err)
+			return &event, false, fmt.Errorf("%w: %v", ErrWebhookRejected, err)
+		}
+	}
+
+	key, eventType := handler.EventKey(header, body)
+	if key == "" {
+		sum := sha256.Sum256(body)
+		key = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	if len(key) > 191 {
+		sum := sha256.Sum256([]byte(key))
+		key = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	event.EventKey = key
+	event.EventType = eventType
+
+	res := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		var existing models.WebhookEvent
+		if err := s.DB.Where("provider = ? AND event_key = ?", provider, key).First(&existing).Error; err != nil {
+			return nil, true, err
+		}
+		// A rejected event does not hold on to its key: the new delivery replaces it and is processed
+		if existing.Status == models.WebhookStatusRejected {
+			res := s.DB.Model(&models.WebhookEvent{}).
+				Where("id = ? AND status = ?", existing.ID, models.WebhookStatusRejected).
+				Updates(map[string]interface{}{
+					"headers": event.Headers, "payload": event.Payload, "status": models.WebhookStatusReceived,
+					"attempts": 0, "next_attempt_at": now, "last_error": "", "received_at": now,
+				})
+			if res.Error != nil {
+				return nil, false, res.Error
+			}
+			if res.RowsAffected > 0 {
+				s.DB.First(&existing, existing.ID)
+				log.Printf("♻️ %s webhook %s replaces rejected event #%d", provider, key, existing.ID)
+				select {
+				case webhookWake <- struct{}{}:
+				default:
+				}
+				return &existing, false, nil
+			}
+		}
+		s.DB.Model(&existing).UpdateColumn("duplicate_hits", gorm.Expr("duplicate_hits + 1"))
+		log.Printf("🔁 Duplicate %s webhook %s ignored (event #%d is %s)", provider, key, existing.ID, existing.Status)
+		return &existing, true, nil
+	}
+
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+	return &event, false, nil
+}
+
+// Process claims one event and runs its handler, scheduling a retry with exponential backoff on failure
+func (s *WebhookService) Process(id uint) (*models.WebhookEvent, error) {
+	// Claim: only one worker may move an event to processing
+	res := s.DB.Model(&models.WebhookEvent{}).
+		Where("id = ? AND status IN ?", id, []string{models.WebhookStatusReceived, models.WebhookStatusFailed}).
+		Updates(map[string]interface{}{"status": models.WebhookStatusProcessing, "attempts": gorm.Expr("attempts + 1")})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	var event models.WebhookEvent
+	if err := s.DB.First(&event, id).Error; err != nil {
+		return nil, fmt.Errorf("webhook event not found")
+	}
+	if res.RowsAffected == 0 {
+		return &event, fmt.Errorf("webhook event #%d is %s", event.ID, event.Status)
+	}
+
+	handler, ok := webhookHandlers[event.Provider]
+	var result string
+	var err error
+	if !ok {
+		err = fmt.Errorf("%w: no handler for %s", ErrWebhookRejected, event.Provider)
+	} else {
+		var header http.Header
+		json.Unmarshal(event.Headers, &header)
+		result, err = s.runHandler(handler, event, header)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{}
+	switch {
+	case err == nil:
+		updates["status"] = models.WebhookStatusProcessed
+		updates["processed_at"] = now
+		updates["next_attempt_at"] = nil
+		updates["last_error"] = ""
+		updates["result"] = result
+	case errors.Is(err, ErrWebhookRejected):
+		updates["status"] = models.WebhookStatusRejected
+		updates["next_attempt_at"] = nil
+		updates["last_error"] = err.Error()
+	case event.Attempts >= webhookMaxAttempts:
+		updates["status"] = models.WebhookStatusDead
+		updates["next_attempt_at"] = nil
+		updates["last_error"] = err.Error()
+	default:
+		next := now.Add(webhookBackoff(event.Attempts))
+		updates["status"] = models.WebhookStatusFailed
+		updates["next_attempt_at"] = next
+		updates["last_error"] = err.Error()
+	}
+	if err != nil {
+		log.Printf("❌ %s webhook #%d attempt %d: %v", event.Provider, event.ID, event.Attempts, err)
+	}
+	s.DB.Model(&event).Updates(updates)
+	s.DB.First(&event, event.ID)
+	return &event, nil
+}
+
+// runHandler keeps a panicking handler from killing the worker; the panic becomes a retryable error
+func (s *WebhookService) runHandler(handler WebhookHandler, event models.WebhookEvent, header http.Header) (result string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+	}()
+	return handler.Process(event, header)
+}
+
+// ProcessDue runs every event that is new or due for a retry, and re-queues abandoned ones
+func (s *WebhookService) ProcessDue() {
+	s.DB.Model(&models.WebhookEvent{}).
+		Where("status = ? AND updated_at < ?", models.WebhookStatusProcessing, time.Now().Add(-webhookStaleTimeout)).
+		Updates(map[string]interface{}{"status": models.WebhookStatusFailed, "next_attempt_at": time.Now(), "last_error": "processing abandoned"})
+
+	var ids []uint
+	s.DB.Model(&models.WebhookEvent{}).
+		Where("status IN ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", []string{models.WebhookStatusReceived, models.WebhookStatusFailed}, time.Now()).
+		Order("id").Limit(100).Pluck("id", &ids)
+	for _, id := range ids {
+		s.Process(id)
+	}
+}
+
+// Replay re-queues a failed, dead or rejected event (e.g. after fixing a secret) and runs it right away
+func (s *WebhookService) Replay(id uint) (*models.WebhookEvent, error) {
+	res := s.DB.Model(&models.WebhookEvent{}).
+		Where("id = ? AND status IN ?", id, []string{models.WebhookStatusFailed, models.WebhookStatusDead, models.WebhookStatusRejected}).
+		Updates(map[string]interface{}{"status": models.WebhookStatusReceived, "attempts": 0, "next_attempt_at": time.Now()})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		var event models.WebhookEvent
+		if err := s.DB.First(&event, id).Error; err != nil {
+			return nil, fmt.Errorf("webhook event not found")
+		}
+		return nil, fmt.Errorf("only failed, dead or rejected events can be replayed (status: %s)", event.Status)
+	}
+	return s.Process(id)
+}
+
+// GetEvent loads one inbox event
+func (s *WebhookService) GetEvent(id uint) (*models.WebhookEvent, error) {
+	var event models.WebhookEvent
+	if err := s.DB.First(&event, id).Error; err != nil {
+		return nil, fmt.Errorf("webhook event not found")
+	}
+	return &event, nil
+}
+
+// ListEvents - paginated inbox, newest first
+func (s *WebhookService) ListEvents(provider, status, search string, page, limit int) ([]models.WebhookEvent, int64, error) {
+	query := s.DB.Model(&models.WebhookEvent{})
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if search != "" {
+		like := "%" + search + "%"
+		query = query.Where("event_key ILIKE ? OR payload ILIKE ?", like, like)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var events []models.WebhookEvent
+	err := query.Order("id desc").Limit(limit).Offset((page - 1) * limit).Find(&events).Error
+	return events, total, err
+}
+
+// webhookBackoff: 30s, 1m, 2m, 4m ... capped at 2h
+func webhookBackoff(attempts int) time.Duration {
+	d := webhookBaseBackoff
+	for i := 1; i < attempts && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > webhookMaxBackoff {
+		d = webhookMaxBackoff
+	}
+	return d
+}
+
+// storableHeaders drops credentials a proxy may forward; signature headers are kept for re-verification
+func storableHeaders(header http.Header) http.Header {
+	out := http.Header{}
+	for k, v := range header {
+		switch strings.ToLower(k) {
+		case "authorization", "cookie", "proxy-authorization":
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -295,11 +295,22 @@ const CartPage = () => {
             const phone = userProfile?.user?.phone || '';
             const email = userProfile?.user?.email || '';
 
+            const cartLines = cartItems.map(item => ({
+                product_id: item.id,
+                variant_id: item.variant_id || null,
+                quantity: item.quantity
+            }));
+
+            // Lock shipping cost & item prices on the server before placing the order
+            const quote = await customerService.getShippingQuote(cartLines, {
+                country: addrData.country || 'ID',
+                state: addrData.state || '',
+                city: addrData.city || '',
+                postal_code: addrData.postal_code || ''
+            }, selectedMethod?.id);
+
             const orderData = {
-                items: cartItems.map(item => ({
-                    product_id: item.id,
-                    quantity: item.quantity
-                })),
+                items: cartLines,
 
                 // Billing Details (WooCommerce Standard)
                 billing_first_name: firstName,
@@ -327,8 +338,7 @@ const CartPage = () => {
                 shipping_postcode: addrData.postal_code || '',
 
                 // Shipping & Payment Method
-                shipping_method: selectedMethod?.name || 'Standard Shipping',
-                shipping_cost: voucherFreeShipping ? 0 : (selectedMethod?.cost || 0),
+                shipping_quote_id: quote.quote_id,
                 payment_method: 'bank_transfer',
                 payment_method_title: 'Bank Transfer',
                 coupon_code: voucherCode,
@@ -427,10 +437,11 @@ const CartPage = () => {
         try {
             const token = localStorage.getItem('token');
             const productIds = cartItems.map(i => i.id);
+            const voucherLines = cartItems.map(i => ({ product_id: i.id, variant_id: i.variant_id || null }));
             const res = await fetch(`${import.meta.env.VITE_API_URL || 'http://localhost:5000'}/api/customer/vouchers/validate`, {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json', Authorization: `Bearer ${token}` },
-                body: JSON.stringify({ code: voucherInput, cart_total: cartTotal, product_ids: productIds }),
+                body: JSON.stringify({ code: voucherInput, cart_total: cartTotal, product_ids: productIds, items: voucherLines }),
             });
             const data = await res.json();
             if (!res.ok) throw new Error(data.error || 'Invalid Voucher');
@@ -203,10 +203,13 @@ const PaymentPage = () => {
             }
 
             if (selectedMethod.id === 'manual') {
+                // The backend adds a unique code so the transfer can be matched automatically
+                const transfer = await customerService.chooseBankTransfer(invoiceId);
                 setPaymentData({
                     method: 'manual',
-                    bank_info: storeSettings.bank_account,
-                    amount: invoice.amount,
+                    bank_info: transfer.bank_account || storeSettings.bank_account,
+                    amount: transfer.transfer_amount,
+                    unique_code: transfer.unique_code,
                     reference: invoice.invoice_number
                 });
                 setProcessingPayment(false);
@@ -723,6 +726,19 @@ const PaymentPage = () => {
                                                 </div>
                                             </div>
 
+                                            <div className="p-5 border border-white/10 rounded-2xl bg-white/[0.02]">
+                                                <p className="text-[10px] text-gray-500 uppercase font-black tracking-widest mb-3">Transfer Exactly</p>
+                                                <div className="flex items-center justify-between">
+                                                    <p className="text-xl font-mono font-bold text-white tracking-widest leading-none">Rp {Number(paymentData.amount).toLocaleString('id-ID')}</p>
+                                                    <button onClick={() => handleCopyCode(String(Math.round(paymentData.amount)))} className="p-2 hover:bg-white/10 rounded-lg text-gray-400 hover:text-white transition-all">
+                                                        <HiClipboardCopy className="w-5 h-5" />
+                                                    </button>
+                                                </div>
+                                                {paymentData.unique_code > 0 && (
+                                                    <p className="text-xs text-gray-500 mt-2">Includes unique code {paymentData.unique_code}. Transfer this exact amount so your payment is verified automatically.</p>
+                                                )}
+                                            </div>
+
                                             <div className="p-5 border border-blue-500/20 rounded-2xl bg-blue-500/5">
                                                 <div className="flex gap-4">
                                                     <HiExclamationCircle className="w-6 h-6 text-blue-400 flex-shrink-0" />
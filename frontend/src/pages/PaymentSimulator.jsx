@@ -13,6 +13,7 @@ const PaymentSimulator = () => {
     const orderNumber = searchParams.get('order');
     const invoiceNumber = searchParams.get('invoice');
     const amount = parseFloat(searchParams.get('amount') || '0');
+    const chargeRef = searchParams.get('ref'); // the charge's merchant_ref_no
 
     const [paymentStatus, setPaymentStatus] = useState('pending'); // pending, showing_va, processing, success, failed
     const [selectedMethod, setSelectedMethod] = useState('');
@@ -66,14 +67,9 @@ const PaymentSimulator = () => {
 
         if (isSuccess) {
             try {
-                // Trigger Webhook
-                await axios.post(`${API_BASE_URL}/webhooks/prismalink`, {
-                    transaction_id: `SIM-${Date.now()}`,
-                    order_id: invoiceNumber,
-                    status: 'success',
-                    amount: amount,
-                    signature: 'SIMULATED', // Backend should skip verification in dev or handle this
-                    payment_type: selectedMethod
+                // Sandbox gateway settles the charge and delivers its own signed webhook
+                await axios.post(`${API_BASE_URL}/sandbox-gateway/charges/${encodeURIComponent(chargeRef)}/simulate`, {
+                    status: 'paid'
                 });
 
                 setPaymentStatus('success');
@@ -821,6 +821,28 @@ const OrderDetail = ({ orderId: propOrderId, onBack }) => {
                                     </>
                                 ) : (
                                     <div className="space-y-3">
+                                        {showModal === 'refund' && (
+                                            <div className="grid grid-cols-2 gap-3">
+                                                <input
+                                                    type="number"
+                                                    min="0"
+                                                    value={formData.amount || ''}
+                                                    onChange={(e) => setFormData({ ...formData, amount: parseFloat(e.target.value) || 0 })}
+                                                    className="w-full bg-white/5 border border-white/10 rounded-2xl p-4 text-white font-mono focus:outline-none focus:border-blue-500/50"
+                                                    placeholder="Nominal (kosong = full)"
+                                                />
+                                                <select
+                                                    value={formData.method || ''}
+                                                    onChange={(e) => setFormData({ ...formData, method: e.target.value })}
+                                                    className="w-full bg-white/5 border border-white/10 rounded-2xl p-4 text-white focus:outline-none focus:border-blue-500/50"
+                                                >
+                                                    <option value="">Otomatis (gateway asal)</option>
+                                                    <option value="gateway">Payment Gateway</option>
+                                                    <option value="wallet">Forza Wallet</option>
+                                                    <option value="manual">Transfer Manual</option>
+                                                </select>
+                                            </div>
+                                        )}
                                         <label className="text-gray-500 text-[10px] uppercase font-black tracking-widest">Alasan Operasional</label>
                                         <textarea value={formData.reason || formData.note || ''} onChange={(e) => setFormData({ ...formData, [showModal === 'note' ? 'note' : 'reason']: e.target.value })} className="w-full bg-white/5 border border-white/5 rounded-2xl p-4 text-white h-32 focus:outline-none focus:border-blue-500/50 resize-none italic normal-case" required />
                                     </div>
@@ -93,7 +93,6 @@ const OrderList = () => {
     const mapPaymentToVariant = (status) => {
         switch (status) {
             case 'paid': return 'success';
-            case 'paid_full': return 'success';
             case 'refunded': return 'error';
             case 'balance_due': return 'warning';
             default: return 'light';
@@ -296,10 +295,9 @@ const OrderList = () => {
                                         <TableCell className="px-5 py-4">
                                             <Badge variant="light" color={mapPaymentToVariant(order.payment_status)}>
                                                 {order.payment_status === 'paid' ? 'LUNAS' :
-                                                    order.payment_status === 'paid_full' ? 'LUNAS' :
-                                                        order.payment_status === 'refunded' ? 'DIKEMBALIKAN' :
-                                                            order.payment_status === 'balance_due' ? 'SISA BAYAR' :
-                                                                order.payment_status === 'pending' ? 'BELUM BAYAR' : order.payment_status?.replace('_', ' ')}
+                                                    order.payment_status === 'refunded' ? 'DIKEMBALIKAN' :
+                                                        order.payment_status === 'balance_due' ? 'SISA BAYAR' :
+                                                            order.payment_status === 'pending' ? 'BELUM BAYAR' : order.payment_status?.replace('_', ' ')}
                                             </Badge>
                                         </TableCell>
                                         <TableCell className="px-5 py-4">
@@ -73,6 +73,7 @@ const Procurement = () => {
         const styles = {
             draft: 'bg-gray-500/10 text-gray-400 border-gray-500/20',
             ordered: 'bg-blue-500/10 text-blue-500 border-blue-500/20',
+            partial: 'bg-amber-500/10 text-amber-500 border-amber-500/20',
             received: 'bg-emerald-500/10 text-emerald-500 border-emerald-500/20',
             cancelled: 'bg-rose-500/10 text-rose-500 border-rose-500/20'
         };
@@ -175,6 +176,7 @@ const Procurement = () => {
                         <option value="" className="bg-slate-900">SEMUA STATUS</option>
                         <option value="draft" className="bg-slate-900">DRAFT</option>
                         <option value="ordered" className="bg-slate-900">DIPESAN</option>
+                        <option value="partial" className="bg-slate-900">DITERIMA SEBAGIAN</option>
                         <option value="received" className="bg-slate-900">DITERIMA</option>
                         <option value="cancelled" className="bg-slate-900">DIBATALKAN</option>
                     </select>
@@ -240,8 +242,9 @@ const Procurement = () => {
                                         <span className={`px-4 py-1.5 rounded-xl text-[10px] font-black uppercase tracking-wider border backdrop-blur-md ${getStatusBadge(po.status)}`}>
                                             {po.status === 'draft' ? 'DRAF' :
                                                 po.status === 'ordered' ? 'DIPESAN' :
-                                                    po.status === 'received' ? 'DITERIMA' :
-                                                        po.status === 'cancelled' ? 'DIBATALKAN' : po.status}
+                                                    po.status === 'partial' ? 'DITERIMA SEBAGIAN' :
+                                                        po.status === 'received' ? 'DITERIMA' :
+                                                            po.status === 'cancelled' ? 'DIBATALKAN' : po.status}
                                         </span>
                                     </td>
                                     <td className="p-6">
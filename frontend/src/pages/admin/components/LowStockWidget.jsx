@@ -5,6 +5,7 @@ import { HiExclamationCircle, HiOutlineRefresh, HiOutlineBell } from 'react-icon
 
 const LowStockWidget = () => {
     const [products, setProducts] = useState([]);
+    const [locations, setLocations] = useState([]);
     const [loading, setLoading] = useState(true);
     const [sending, setSending] = useState(false);
     const [alertSent, setAlertSent] = useState(false);
@@ -15,8 +16,10 @@ const LowStockWidget = () => {
         try {
             const res = await adminService.getLowStockProducts();
             setProducts(res.data || []);
+            setLocations(res.locations || []);
         } catch {
             setProducts([]);
+            setLocations([]);
         } finally {
             setLoading(false);
         }
@@ -60,7 +63,7 @@ const LowStockWidget = () => {
                     <div>
                         <p className="text-white font-bold text-sm">Low Stock Alert</p>
                         <p className="text-gray-500 text-xs">
-                            {loading ? 'Checking...' : products.length === 0 ? 'All products are well-stocked ✓' : `${products.length} product${products.length !== 1 ? 's' : ''} need restocking`}
+                            {loading ? 'Checking...' : products.length + locations.length === 0 ? 'All products are well-stocked ✓' : `${products.length} product${products.length !== 1 ? 's' : ''} need restocking${locations.length > 0 ? `, ${locations.length} low at a location` : ''}`}
                         </p>
                     </div>
                 </div>
@@ -72,7 +75,7 @@ const LowStockWidget = () => {
                     >
                         <HiOutlineRefresh className={`w-4 h-4 ${loading ? 'animate-spin' : ''}`} />
                     </button>
-                    {products.length > 0 && (
+                    {products.length + locations.length > 0 && (
                         <button
                             onClick={sendAlert}
                             disabled={sending}
@@ -92,7 +95,7 @@ const LowStockWidget = () => {
             {/* Content */}
             {loading ? (
                 <div className="p-6 text-center text-gray-600 text-sm">Checking inventory...</div>
-            ) : products.length === 0 ? (
+            ) : products.length + locations.length === 0 ? (
                 <div className="p-8 text-center">
                     <div className="text-3xl mb-2">✅</div>
                     <p className="text-gray-500 text-sm">All products have sufficient stock</p>
@@ -134,11 +137,38 @@ const LowStockWidget = () => {
                             </div>
                         );
                     })}
+                    {locations.map((l) => (
+                        <div
+                            key={`${l.location_id}-${l.product_id}-${l.variant_id || 0}`}
+                            className="flex items-center gap-3 px-5 py-3 hover:bg-white/[0.02] transition-colors cursor-pointer group"
+                            onClick={() => navigate(`/admin/products/${l.product_id}/edit`)}
+                        >
+                            <div className="flex-shrink-0 w-1.5 h-10 rounded-full bg-white/5 overflow-hidden">
+                                <div
+                                    className={`w-full rounded-full transition-all ${l.available_stock <= 0 ? 'bg-red-500' : 'bg-amber-500'}`}
+                                    style={{ height: getBarWidth(l.available_stock, l.min_stock_level) }}
+                                />
+                            </div>
+                            <div className="flex-1 min-w-0">
+                                <p className="text-white text-xs font-semibold truncate group-hover:text-amber-400 transition-colors">
+                                    {l.product_name}{l.variant_name ? ` - ${l.variant_name}` : ''}
+                                </p>
+                                <p className="text-gray-600 text-[10px] font-mono">{l.location_name} · {l.sku}</p>
+                            </div>
+                            <div className="text-right flex-shrink-0">
+                                <p className={`text-sm font-black ${getStockColor(l.available_stock, l.min_stock_level)}`}>
+                                    {l.available_stock <= 0 ? 'OUT' : l.available_stock}
+                                    {l.available_stock > 0 && <span className="text-[10px] font-normal text-gray-600 ml-1">left</span>}
+                                </p>
+                                <p className="text-[10px] text-gray-600">min: {l.min_stock_level}</p>
+                            </div>
+                        </div>
+                    ))}
                 </div>
             )}
 
             {/* Footer */}
-            {products.length > 0 && (
+            {products.length + locations.length > 0 && (
                 <div className="px-5 py-3 border-t border-white/5">
                     <button
                         onClick={() => navigate('/admin/products?stock_status=outofstock')}
@@ -146,8 +146,9 @@ export const adminService = {
         return response.data;
     },
     // Low Stock
-    getLowStockProducts: async () => {
-        const response = await api.get('/admin/products/low-stock');
+    getLowStockProducts: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/products/low-stock?${params}`);
         return response.data;
     },
     sendLowStockAlert: async () => {
@@ -504,6 +505,35 @@ export const adminService = {
         const response = await api.post('/admin/finance/journals', data);
         return response.data;
     },
+    reverseJournal: async (id, data = {}) => {
+        const response = await api.post(`/admin/finance/journals/${id}/reverse`, data);
+        return response.data;
+    },
+
+    // ============================================
+    // FINANCE - ACCOUNTING PERIODS
+    // ============================================
+    getAccountingPeriods: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/finance/periods?${params}`);
+        return response.data;
+    },
+    openAccountingPeriod: async (year, month) => {
+        const response = await api.post('/admin/finance/periods', { year, month });
+        return response.data;
+    },
+    closeAccountingPeriod: async (id, note = '') => {
+        const response = await api.post(`/admin/finance/periods/${id}/close`, { note });
+        return response.data;
+    },
+    lockAccountingPeriod: async (id) => {
+        const response = await api.post(`/admin/finance/periods/${id}/lock`);
+        return response.data;
+    },
+    closeFiscalYear: async (year) => {
+        const response = await api.post(`/admin/finance/periods/year/${year}/close`);
+        return response.data;
+    },
 
     // ============================================
     // FINANCE - EXPENSES
@@ -535,6 +565,30 @@ export const adminService = {
         return response.data;
     },
 
+    // ============================================
+    // FINANCE - RECURRING EXPENSES
+    // ============================================
+    getRecurringExpenses: async () => {
+        const response = await api.get('/admin/finance/recurring-expenses');
+        return response.data;
+    },
+    createRecurringExpense: async (data) => {
+        const response = await api.post('/admin/finance/recurring-expenses', data);
+        return response.data;
+    },
+    updateRecurringExpense: async (id, data) => {
+        const response = await api.put(`/admin/finance/recurring-expenses/${id}`, data);
+        return response.data;
+    },
+    deleteRecurringExpense: async (id) => {
+        const response = await api.delete(`/admin/finance/recurring-expenses/${id}`);
+        return response.data;
+    },
+    runRecurringExpenses: async () => {
+        const response = await api.post('/admin/finance/recurring-expenses/run');
+        return response.data;
+    },
+
     // ============================================
     // FINANCE - STATS & REPORTS
     // ============================================
@@ -553,6 +607,75 @@ export const adminService = {
         const response = await api.get(`/admin/finance/reports/pnl?${params}`);
         return response.data;
     },
+    getTrialBalance: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/finance/reports/trial-balance?${params}`);
+        return response.data;
+    },
+    getBalanceSheet: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/finance/reports/balance-sheet?${params}`);
+        return response.data;
+    },
+    getGeneralLedger: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/finance/reports/general-ledger?${params}`);
+        return response.data;
+    },
+    getCashFlowStatement: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/finance/reports/cash-flow?${params}`);
+        return response.data;
+    },
+
+    // ============================================
+    // FINANCE - ACCOUNTS PAYABLE
+    // ============================================
+    getSupplierBills: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/finance/payables/bills?${params}`);
+        return response.data;
+    },
+    getSupplierBill: async (id) => {
+        const response = await api.get(`/admin/finance/payables/bills/${id}`);
+        return response.data;
+    },
+    createSupplierBill: async (data) => {
+        const response = await api.post('/admin/finance/payables/bills', data);
+        return response.data;
+    },
+    paySupplierBill: async (id, data) => {
+        const response = await api.post(`/admin/finance/payables/bills/${id}/payments`, data);
+        return response.data;
+    },
+    getPayableAging: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/finance/payables/aging?${params}`);
+        return response.data;
+    },
+    getSupplierStatement: async (supplierId, filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/finance/payables/suppliers/${supplierId}/statement?${params}`);
+        return response.data;
+    },
+
+    // ============================================
+    // FINANCE - ACCOUNTING EXPORT
+    // ============================================
+    // dataset: journals, invoices, expenses, pnl; filters: from_date, to_date, coa_id, format, profile
+    exportAccounting: async (dataset, filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/finance/exports/${dataset}?${params}`, { responseType: 'blob' });
+        return response.data;
+    },
+    getExportMappings: async (target) => {
+        const response = await api.get(`/admin/finance/exports/mappings?target=${target}`);
+        return response.data;
+    },
+    saveExportMappings: async (target, mappings) => {
+        const response = await api.put('/admin/finance/exports/mappings', { target, mappings });
+        return response.data;
+    },
 
     // ============================================
     // ANNOUNCEMENTS
@@ -676,14 +799,195 @@ export const adminService = {
         const response = await api.put(`/admin/procurement/orders/${id}`, data);
         return response.data;
     },
-    receivePurchaseOrder: async (id) => {
-        const response = await api.post(`/admin/procurement/orders/${id}/receive`);
+    // data (optional): { items: [{ item_id, quantity }], freight, customs, import_duty, allocation_method, landed_cost_coa_id, location_id, notes }
+    receivePurchaseOrder: async (id, data) => {
+        const response = await api.post(`/admin/procurement/orders/${id}/receive`, data);
+        return response.data;
+    },
+    getPurchaseReceipts: async (id) => {
+        const response = await api.get(`/admin/procurement/orders/${id}/receipts`);
         return response.data;
     },
     deletePurchaseOrder: async (id) => {
         const response = await api.delete(`/admin/procurement/orders/${id}`);
         return response.data;
     },
+    // Approve several draft / submitted POs in one click (within the role's limit, not one's own POs)
+    approvePurchaseOrders: async (ids) => {
+        const response = await api.post('/admin/procurement/orders/approve', { ids });
+        return response.data;
+    },
+    // Lifecycle: draft -> pending_approval -> approved -> ordered (sent) -> partial / received
+    submitPurchaseOrder: async (id) => {
+        const response = await api.post(`/admin/procurement/orders/${id}/submit`);
+        return response.data;
+    },
+    approvePurchaseOrder: async (id) => {
+        const response = await api.post(`/admin/procurement/orders/${id}/approve`);
+        return response.data;
+    },
+    rejectPurchaseOrder: async (id, reason) => {
+        const response = await api.post(`/admin/procurement/orders/${id}/reject`, { reason });
+        return response.data;
+    },
+    // email (optional): defaults to the supplier's email
+    sendPurchaseOrder: async (id, email = '') => {
+        const response = await api.post(`/admin/procurement/orders/${id}/send`, { email });
+        return response.data;
+    },
+    cancelPurchaseOrder: async (id) => {
+        const response = await api.post(`/admin/procurement/orders/${id}/cancel`);
+        return response.data;
+    },
+    // date: YYYY-MM-DD
+    setPurchaseOrderExpectedDate: async (id, date) => {
+        const response = await api.put(`/admin/procurement/orders/${id}/expected-date`, { expected_date: date });
+        return response.data;
+    },
+    downloadPurchaseOrderPDF: async (id) => {
+        const response = await api.get(`/admin/procurement/orders/${id}/pdf`, { responseType: 'blob' });
+        return response.data;
+    },
+    getOverduePurchaseOrders: async () => {
+        const response = await api.get('/admin/procurement/orders/overdue');
+        return response.data;
+    },
+    // limit: largest PO total the role may approve (0 = cannot approve)
+    updateRolePOApprovalLimit: async (roleId, limit) => {
+        const response = await api.put(`/admin/roles/${roleId}/po-approval-limit`, { po_approval_limit: limit });
+        return response.data;
+    },
+    // filters: { supplier_id, all: true }
+    getReorderSuggestions: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/procurement/reorder-suggestions?${params}`);
+        return response.data;
+    },
+    // data (optional): { supplier_ids: [], lines: [{ product_id, variant_id, supplier_id, quantity, unit_cost }] }
+    createReorderDrafts: async (data) => {
+        const response = await api.post('/admin/procurement/reorder-suggestions/drafts', data);
+        return response.data;
+    },
+    getProductSuppliers: async (productId) => {
+        const response = await api.get(`/admin/procurement/product-suppliers?product_id=${productId}`);
+        return response.data;
+    },
+    // data: { product_id, supplier_id, cost, is_primary, lead_time_days, min_order_qty }
+    saveProductSupplier: async (data) => {
+        const response = await api.post('/admin/procurement/product-suppliers', data);
+        return response.data;
+    },
+    deleteProductSupplier: async (id) => {
+        const response = await api.delete(`/admin/procurement/product-suppliers/${id}`);
+        return response.data;
+    },
+
+    // ============================================
+    // INVENTORY (Locations & Transfers)
+    // ============================================
+    getLocations: async () => {
+        const response = await api.get('/admin/inventory/locations');
+        return response.data;
+    },
+    createLocation: async (data) => {
+        const response = await api.post('/admin/inventory/locations', data);
+        return response.data;
+    },
+    updateLocation: async (id, data) => {
+        const response = await api.put(`/admin/inventory/locations/${id}`, data);
+        return response.data;
+    },
+    deleteLocation: async (id) => {
+        const response = await api.delete(`/admin/inventory/locations/${id}`);
+        return response.data;
+    },
+    // locationIds: web checkout allocation order, first is tried first
+    setLocationPriority: async (locationIds) => {
+        const response = await api.put('/admin/inventory/locations/priority', { location_ids: locationIds });
+        return response.data;
+    },
+    setLocationMinStock: async (locationId, data) => {
+        const response = await api.put(`/admin/inventory/locations/${locationId}/min-stock`, data);
+        return response.data;
+    },
+    getStockLevels: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/inventory/levels?${params}`);
+        return response.data;
+    },
+    syncStockLocations: async () => {
+        const response = await api.post('/admin/inventory/levels/sync');
+        return response.data;
+    },
+    getStockTransfers: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/inventory/transfers?${params}`);
+        return response.data;
+    },
+    getStockTransfer: async (id) => {
+        const response = await api.get(`/admin/inventory/transfers/${id}`);
+        return response.data;
+    },
+    // data: { from_location_id, to_location_id, notes, items: [{ product_id, variant_id, quantity }] }
+    createStockTransfer: async (data) => {
+        const response = await api.post('/admin/inventory/transfers', data);
+        return response.data;
+    },
+    dispatchStockTransfer: async (id) => {
+        const response = await api.post(`/admin/inventory/transfers/${id}/dispatch`);
+        return response.data;
+    },
+    receiveStockTransfer: async (id) => {
+        const response = await api.post(`/admin/inventory/transfers/${id}/receive`);
+        return response.data;
+    },
+    cancelStockTransfer: async (id) => {
+        const response = await api.post(`/admin/inventory/transfers/${id}/cancel`);
+        return response.data;
+    },
+
+    // ============================================
+    // STOCK TAKE (Stock Opname)
+    // ============================================
+    getStockTakes: async (filters = {}) => {
+        const params = new URLSearchParams(filters).toString();
+        const response = await api.get(`/admin/inventory/stock-takes?${params}`);
+        return response.data;
+    },
+    getStockTake: async (id) => {
+        const response = await api.get(`/admin/inventory/stock-takes/${id}`);
+        return response.data;
+    },
+    // data: { location_id, category_id, brand_id, notes }
+    createStockTake: async (data) => {
+        const response = await api.post('/admin/inventory/stock-takes', data);
+        return response.data;
+    },
+    // code: scanned QR code or SKU; set replaces the count instead of adding to it
+    scanStockTake: async (id, code, quantity = 1, set = false) => {
+        const response = await api.post(`/admin/inventory/stock-takes/${id}/scan`, { code, quantity, set });
+        return response.data;
+    },
+    setStockTakeCount: async (id, itemId, countedQty) => {
+        const response = await api.put(`/admin/inventory/stock-takes/${id}/items/${itemId}`, { counted_qty: countedQty });
+        return response.data;
+    },
+    submitStockTake: async (id, zeroUncounted = false) => {
+        const response = await api.post(`/admin/inventory/stock-takes/${id}/submit`, { zero_uncounted: zeroUncounted });
+        return response.data;
+    },
+    approveStockTake: async (id) => {
+        const response = await api.post(`/admin/inventory/stock-takes/${id}/approve`);
+        return response.data;
+    },
+    rejectStockTake: async (id, reason) => {
+        const response = await api.post(`/admin/inventory/stock-takes/${id}/reject`, { reason });
+        return response.data;
+    },
+    cancelStockTake: async (id) => {
+        const response = await api.post(`/admin/inventory/stock-takes/${id}/cancel`);
+        return response.data;
+    },
 
     // ============================================
     // SETTINGS
@@ -76,6 +76,19 @@ export const customerService = {
         const response = await customerApi.post('/customer/checkout/shipping-options', payload);
         return response.data;
     },
+    // Server-side price lock: returns a short-lived quote_id accepted by /customer/checkout
+    getShippingQuote: async (items, address = {}, optionId) => {
+        const payload = {
+            items,
+            country: address.country,
+            state: address.state,
+            city: address.city,
+            postal_code: address.postal_code || '',
+            option_id: optionId
+        };
+        const response = await customerApi.post('/customer/checkout/shipping-quote', payload);
+        return response.data;
+    },
 
     confirmReceived: async (id) => {
         const response = await customerApi.post(`/customer/orders/${id}/confirm`, {});
@@ -147,6 +160,10 @@ export const customerService = {
         const response = await customerApi.post(`/customer/payment/${invoiceId}/generate`, paymentData);
         return response.data;
     },
+    chooseBankTransfer: async (invoiceId) => {
+        const response = await customerApi.post(`/customer/payment/${invoiceId}/bank-transfer`);
+        return response.data;
+    },
     submitCreditCard: async (invoiceId, cardData) => {
         const response = await customerApi.post(`/customer/payment/${invoiceId}/submit-card`, cardData);
         return response.data;